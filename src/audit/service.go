@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultListLimit = 100
+
+// Service 审计日志查询接口
+type Service struct{}
+
+// NewService 创建审计日志服务
+func NewService() *Service {
+	return &Service{}
+}
+
+// Start 注册审计日志路由
+func (s *Service) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	// 列出审计记录，可按 target 过滤
+	apiGroup.GET("/audit_logs", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultListLimit)))
+		if err != nil || limit <= 0 {
+			limit = defaultListLimit
+		}
+		logs, err := List(c.Query("target"), limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": logs})
+	})
+	return nil
+}
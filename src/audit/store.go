@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/database"
+)
+
+// 审计操作类型
+const (
+	ActionClearMemory        = "clear_memory"        // 通过接口清除设备的长期记忆
+	ActionForgetConversation = "forget_conversation" // 设备上的语音指令清空当前会话历史
+	ActionDisconnectDevice   = "disconnect_device"   // 通过管理接口强制断开设备连接
+)
+
+// Log 一条审计记录，记录谁在什么时候对什么做了不可恢复的操作
+type Log struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Actor     string    `gorm:"size:128" json:"actor"`        // 操作者：接口调用方标识，或 device:<设备ID> 表示设备上的语音指令
+	Action    string    `gorm:"size:64" json:"action"`        // 操作类型
+	Target    string    `gorm:"size:128;index" json:"target"` // 操作对象，如设备ID
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+func init() {
+	database.RegisterModel(&Log{})
+}
+
+// Record 写入一条审计记录
+func Record(actor, action, target, detail string) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	if err := db.Create(&Log{Actor: actor, Action: action, Target: target, Detail: detail}).Error; err != nil {
+		return fmt.Errorf("写入审计日志失败: %v", err)
+	}
+	return nil
+}
+
+// List 按时间倒序列出审计记录，target 为空时列出全部
+func List(target string, limit int) ([]Log, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	query := db.Order("id desc").Limit(limit)
+	if target != "" {
+		query = query.Where("target = ?", target)
+	}
+	var logs []Log
+	if err := query.Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("查询审计日志失败: %v", err)
+	}
+	return logs, nil
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// issueToken 为调用方签发 JWT
+func issueToken(secret, subject string, ttl time.Duration) (string, time.Time, error) {
+	if secret == "" {
+		return "", time.Time{}, fmt.Errorf("未配置 jwt_secret，无法签发 JWT")
+	}
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := jwt.RegisteredClaims{
+		Subject:   subject,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+		Issuer:    "xiaozhi-server",
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("签发 JWT 失败: %v", err)
+	}
+	return token, expiresAt, nil
+}
+
+// parseToken 校验 JWT 并返回 subject
+func parseToken(secret, tokenString string) (string, error) {
+	if secret == "" {
+		return "", fmt.Errorf("未配置 jwt_secret")
+	}
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", fmt.Errorf("无效的 JWT: %v", err)
+	}
+	return claims.Subject, nil
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/database"
+)
+
+// keyPrefix API key 明文前缀，便于在日志与配置中识别
+const keyPrefix = "xz_"
+
+// APIKey API 密钥，数据库只保存哈希，明文仅在创建时返回一次
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Name       string     `gorm:"size:64" json:"name"`
+	Prefix     string     `gorm:"size:16;index" json:"prefix"` // 明文前若干位，用于展示
+	Hash       string     `gorm:"size:64;uniqueIndex" json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Revoked    bool       `json:"revoked"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func init() {
+	database.RegisterModel(&APIKey{})
+}
+
+func hashKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateKey 创建新的 API key，返回明文与记录
+func CreateKey(name string, ttl time.Duration) (string, *APIKey, error) {
+	db := database.GetDB()
+	if db == nil {
+		return "", nil, fmt.Errorf("数据库未初始化")
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("生成密钥失败: %v", err)
+	}
+	plain := keyPrefix + hex.EncodeToString(buf)
+
+	key := &APIKey{
+		Name:   name,
+		Prefix: plain[:len(keyPrefix)+6],
+		Hash:   hashKey(plain),
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := db.Create(key).Error; err != nil {
+		return "", nil, fmt.Errorf("保存密钥失败: %v", err)
+	}
+	return plain, key, nil
+}
+
+// ListKeys 列出所有 API key（不含明文）
+func ListKeys() ([]APIKey, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var keys []APIKey
+	if err := db.Order("id desc").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("查询密钥失败: %v", err)
+	}
+	return keys, nil
+}
+
+// RevokeKey 吊销 API key
+func RevokeKey(id uint) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	result := db.Model(&APIKey{}).Where("id = ?", id).Update("revoked", true)
+	if result.Error != nil {
+		return fmt.Errorf("吊销密钥失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("密钥 %d 不存在", id)
+	}
+	return nil
+}
+
+// validateKey 校验 API key，返回调用方标识
+func validateKey(plain, adminKey string) (string, error) {
+	if plain == "" {
+		return "", fmt.Errorf("缺少 API key")
+	}
+	if adminKey != "" && subtle.ConstantTimeCompare([]byte(plain), []byte(adminKey)) == 1 {
+		return SubjectAdmin, nil
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		return "", fmt.Errorf("无效的 API key")
+	}
+	var key APIKey
+	if err := db.Where("hash = ?", hashKey(plain)).First(&key).Error; err != nil {
+		return "", fmt.Errorf("无效的 API key")
+	}
+	if key.Revoked {
+		return "", fmt.Errorf("API key 已吊销")
+	}
+	now := time.Now()
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return "", fmt.Errorf("API key 已过期")
+	}
+	db.Model(&key).Update("last_used_at", now)
+	return fmt.Sprintf("key:%d", key.ID), nil
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 鉴权模式
+const (
+	ModeNone   = "none"    // 不鉴权
+	ModeAPIKey = "api_key" // 仅 API key
+	ModeJWT    = "jwt"     // 仅 JWT
+	ModeAny    = "any"     // API key 或 JWT 任一
+	ModeAdmin  = "admin"   // 仅管理员：admin_key 或由 admin_key 换取的 JWT
+)
+
+// SubjectAdmin 使用 admin_key 认证的调用方标识
+const SubjectAdmin = "admin"
+
+// ContextKeySubject gin.Context 中保存调用方标识的键
+const ContextKeySubject = "auth_subject"
+
+// ContextKeyJWT gin.Context 中标记本次请求凭证为 JWT 的键
+const ContextKeyJWT = "auth_jwt"
+
+// routeRule 路由前缀与鉴权模式
+type routeRule struct {
+	prefix string
+	mode   string
+}
+
+// Authenticator /api 统一鉴权
+type Authenticator struct {
+	enabled     bool
+	adminKey    string
+	jwtSecret   string
+	jwtExpire   time.Duration
+	defaultMode string
+	rules       []routeRule // 按前缀长度降序
+	logger      *utils.Logger
+}
+
+// NewAuthenticator 根据配置创建鉴权器
+func NewAuthenticator(config *configs.Config, logger *utils.Logger) (*Authenticator, error) {
+	cfg := config.Web.Auth
+	a := &Authenticator{
+		enabled:     cfg.Enabled,
+		adminKey:    cfg.AdminKey,
+		jwtSecret:   cfg.JWTSecret,
+		jwtExpire:   24 * time.Hour,
+		defaultMode: cfg.DefaultMode,
+		logger:      logger,
+	}
+	if a.defaultMode == "" {
+		a.defaultMode = ModeAny
+	}
+	if !validMode(a.defaultMode) {
+		return nil, fmt.Errorf("无效的默认鉴权模式: %s", a.defaultMode)
+	}
+	if cfg.JWTExpire != "" {
+		d, err := time.ParseDuration(cfg.JWTExpire)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 jwt_expire: %v", err)
+		}
+		a.jwtExpire = d
+	}
+	for prefix, mode := range cfg.Routes {
+		if !validMode(mode) {
+			return nil, fmt.Errorf("路由 %s 的鉴权模式无效: %s", prefix, mode)
+		}
+		// 前缀按路径段匹配，末尾的 / 不影响匹配
+		a.rules = append(a.rules, routeRule{prefix: strings.TrimRight(prefix, "/"), mode: mode})
+	}
+	sort.Slice(a.rules, func(i, j int) bool {
+		return len(a.rules[i].prefix) > len(a.rules[j].prefix)
+	})
+	if a.enabled && a.adminKey == "" {
+		logger.Warn("已启用 /api 鉴权但未配置 admin_key，仅能使用数据库中已有的 API key")
+	}
+	return a, nil
+}
+
+func validMode(mode string) bool {
+	switch mode {
+	case ModeNone, ModeAPIKey, ModeJWT, ModeAny, ModeAdmin:
+		return true
+	}
+	return false
+}
+
+// modeFor 按最长前缀匹配路由的鉴权模式，前缀只匹配完整的路径段，/api/ota 不匹配 /api/otaX
+func (a *Authenticator) modeFor(path string) string {
+	for _, rule := range a.rules {
+		if path == rule.prefix || strings.HasPrefix(path, rule.prefix+"/") {
+			return rule.mode
+		}
+	}
+	return a.defaultMode
+}
+
+// credentials 从请求中提取凭证，isJWT 表示是否为 JWT 格式
+func credentials(r *http.Request) (token string, isJWT bool) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key, false
+	}
+	authHeader := r.Header.Get("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+		return token, strings.Count(token, ".") == 2
+	}
+	return "", false
+}
+
+// Authenticate 校验请求凭证，返回调用方标识
+func (a *Authenticator) Authenticate(c *gin.Context, mode string) (string, error) {
+	return a.AuthenticateRequest(c.Request, mode)
+}
+
+// Enabled 是否启用鉴权
+func (a *Authenticator) Enabled() bool {
+	return a.enabled
+}
+
+// AuthenticateRequest 校验 HTTP 请求的凭证，供 gin 之外的入口（如 WebSocket 握手）使用
+func (a *Authenticator) AuthenticateRequest(r *http.Request, mode string) (string, error) {
+	token, isJWT := credentials(r)
+	if token == "" {
+		return "", fmt.Errorf("缺少认证信息")
+	}
+	if mode == ModeAdmin {
+		return a.authenticateAdmin(token, isJWT)
+	}
+	switch {
+	case isJWT && (mode == ModeJWT || mode == ModeAny):
+		return parseToken(a.jwtSecret, token)
+	case !isJWT && (mode == ModeAPIKey || mode == ModeAny):
+		return validateKey(token, a.adminKey)
+	case isJWT:
+		return "", fmt.Errorf("该接口仅支持 API key 认证")
+	default:
+		return "", fmt.Errorf("该接口仅支持 JWT 认证")
+	}
+}
+
+// authenticateAdmin 只接受 admin_key 与由 admin_key 换取的 JWT，数据库中的 API key 无权访问
+func (a *Authenticator) authenticateAdmin(token string, isJWT bool) (string, error) {
+	var subject string
+	var err error
+	if isJWT {
+		subject, err = parseToken(a.jwtSecret, token)
+	} else {
+		subject, err = validateKey(token, a.adminKey)
+	}
+	if err != nil {
+		return "", err
+	}
+	if subject != SubjectAdmin {
+		return "", fmt.Errorf("该接口仅限管理员访问")
+	}
+	return subject, nil
+}
+
+// IsAdmin 请求是否由管理员发起，未启用鉴权时视为管理员
+func (a *Authenticator) IsAdmin(c *gin.Context) bool {
+	return !a.enabled || c.GetString(ContextKeySubject) == SubjectAdmin
+}
+
+// Middleware 返回挂载到路由组的 gin 中间件
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.enabled || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+		mode := a.modeFor(c.Request.URL.Path)
+		if mode == ModeNone {
+			c.Next()
+			return
+		}
+		subject, err := a.Authenticate(c, mode)
+		if err != nil {
+			a.logger.Warn(fmt.Sprintf("接口鉴权失败 %s %s (%s): %v", c.Request.Method, c.Request.URL.Path, c.ClientIP(), err))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		_, isJWT := credentials(c.Request)
+		c.Set(ContextKeySubject, subject)
+		c.Set(ContextKeyJWT, isJWT)
+		c.Next()
+	}
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Service 密钥管理与 JWT 签发接口
+type Service struct {
+	authenticator *Authenticator
+}
+
+// NewService 创建密钥管理服务
+func NewService(authenticator *Authenticator) *Service {
+	return &Service{authenticator: authenticator}
+}
+
+// Start 注册鉴权相关路由，与 OTAService 保持一致的注册方式
+func (s *Service) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	group := apiGroup.Group("/auth")
+
+	// 使用 API key 换取 JWT，不能用 JWT 续签，否则有效期形同虚设
+	group.POST("/token", func(c *gin.Context) {
+		if c.GetBool(ContextKeyJWT) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "JWT 不能用于换取新的 JWT，请使用 API key"})
+			return
+		}
+		subject := c.GetString(ContextKeySubject)
+		if subject == "" {
+			subject = "anonymous"
+		}
+		token, expiresAt, err := issueToken(s.authenticator.jwtSecret, subject, s.authenticator.jwtExpire)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+			"token":      token,
+			"expires_at": expiresAt,
+		}})
+	})
+
+	// 查询所有密钥
+	group.GET("/keys", func(c *gin.Context) {
+		if !s.requireAdmin(c) {
+			return
+		}
+		keys, err := ListKeys()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": keys})
+	})
+
+	// 创建密钥，明文只在本次响应中返回
+	group.POST("/keys", func(c *gin.Context) {
+		if !s.requireAdmin(c) {
+			return
+		}
+		var req struct {
+			Name      string `json:"name"`
+			ExpiresIn string `json:"expires_in"` // 如 720h，留空表示永不过期
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "解析失败: " + err.Error()})
+			return
+		}
+		var ttl time.Duration
+		if req.ExpiresIn != "" {
+			d, err := time.ParseDuration(req.ExpiresIn)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效的 expires_in: " + err.Error()})
+				return
+			}
+			ttl = d
+		}
+		plain, key, err := CreateKey(req.Name, ttl)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		s.authenticator.logger.Info(fmt.Sprintf("创建 API key: %s (%s)，操作者: %s", key.Prefix, req.Name, c.GetString(ContextKeySubject)))
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+			"key":  plain,
+			"info": key,
+		}})
+	})
+
+	// 吊销密钥
+	group.DELETE("/keys/:id", func(c *gin.Context) {
+		if !s.requireAdmin(c) {
+			return
+		}
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效的 id"})
+			return
+		}
+		if err := RevokeKey(uint(id)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		s.authenticator.logger.Info(fmt.Sprintf("吊销 API key: %d，操作者: %s", id, c.GetString(ContextKeySubject)))
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	return nil
+}
+
+// requireAdmin 密钥的创建与吊销仅限管理员，路由鉴权模式配置得较宽时也不放行其他 API key
+func (s *Service) requireAdmin(c *gin.Context) bool {
+	if s.authenticator.IsAdmin(c) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "仅管理员可以管理密钥"})
+	return false
+}
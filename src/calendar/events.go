@@ -0,0 +1,387 @@
+package calendar
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+const maxRecurrences = 1000 // 展开重复日程时最多迭代的次数
+
+// Event 一条日程
+type Event struct {
+	Summary  string
+	Location string
+	Start    time.Time
+	End      time.Time
+	AllDay   bool
+}
+
+var eventClient = &http.Client{Timeout: 10 * time.Second}
+
+// FetchEvents 从日历源拉取与 [from, to) 有交集的日程，按开始时间排序
+// 没有时区信息的时间按 loc 解释
+func FetchEvents(ctx context.Context, source configs.CalendarSource, from, to time.Time, loc *time.Location) ([]Event, error) {
+	var texts []string
+	switch source.Type {
+	case "", "ics":
+		text, err := fetchICS(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		texts = []string{text}
+	case "caldav":
+		var err error
+		if texts, err = queryCalDAV(ctx, source, from, to); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("不支持的日历类型: %s", source.Type)
+	}
+
+	var events []Event
+	for _, text := range texts {
+		for _, e := range parseICS(text, loc) {
+			events = append(events, e.occurrences(from, to)...)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events, nil
+}
+
+func fetchICS(ctx context.Context, source configs.CalendarSource) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("创建日历请求失败: %v", err)
+	}
+	if source.Username != "" {
+		req.SetBasicAuth(source.Username, source.Password)
+	}
+	resp, err := eventClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("拉取日历失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("拉取日历失败: HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取日历失败: %v", err)
+	}
+	return string(body), nil
+}
+
+const calDAVQuery = `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <c:calendar-data><c:expand start="%[1]s" end="%[2]s"/></c:calendar-data>
+  </d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT"><c:time-range start="%[1]s" end="%[2]s"/></c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`
+
+// queryCalDAV 用 calendar-query 查询时间范围内的日程，重复日程由服务端展开
+func queryCalDAV(ctx context.Context, source configs.CalendarSource, from, to time.Time) ([]string, error) {
+	const layout = "20060102T150405Z"
+	body := fmt.Sprintf(calDAVQuery, from.UTC().Format(layout), to.UTC().Format(layout))
+	req, err := http.NewRequestWithContext(ctx, "REPORT", source.URL, strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建 CalDAV 请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", "1")
+	if source.Username != "" {
+		req.SetBasicAuth(source.Username, source.Password)
+	}
+	resp, err := eventClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("查询 CalDAV 失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("查询 CalDAV 失败: HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Responses []struct {
+			Propstat []struct {
+				Prop struct {
+					CalendarData string `xml:"calendar-data"`
+				} `xml:"prop"`
+			} `xml:"propstat"`
+		} `xml:"response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("解析 CalDAV 响应失败: %v", err)
+	}
+	var texts []string
+	for _, r := range result.Responses {
+		for _, p := range r.Propstat {
+			if p.Prop.CalendarData != "" {
+				texts = append(texts, p.Prop.CalendarData)
+			}
+		}
+	}
+	return texts, nil
+}
+
+// icsEvent 解析出来的 VEVENT，保留重复规则待展开
+type icsEvent struct {
+	Event
+	rule map[string]string
+}
+
+// parseICS 解析 iCalendar 文本中的日程，已取消的日程不返回
+func parseICS(text string, loc *time.Location) []icsEvent {
+	var events []icsEvent
+	var current *icsEvent
+	var duration time.Duration
+	var hasEnd, cancelled bool
+	nested := 0
+	for _, line := range unfoldLines(text) {
+		name, params, value := parseProperty(line)
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			current, duration, hasEnd, cancelled, nested = &icsEvent{}, 0, false, false, 0
+			continue
+		case current == nil:
+			continue
+		case name == "BEGIN":
+			nested++ // VALARM 等子组件
+			continue
+		case name == "END" && value != "VEVENT":
+			nested--
+			continue
+		case name == "END":
+			if !hasEnd {
+				current.End = current.Start.Add(duration)
+				if current.AllDay && duration == 0 {
+					current.End = current.Start.AddDate(0, 0, 1)
+				}
+			}
+			if !cancelled && !current.Start.IsZero() {
+				events = append(events, *current)
+			}
+			current = nil
+			continue
+		case nested > 0:
+			continue
+		}
+
+		switch name {
+		case "SUMMARY":
+			current.Summary = unescapeText(value)
+		case "LOCATION":
+			current.Location = unescapeText(value)
+		case "DTSTART":
+			current.Start, current.AllDay = parseICSTime(value, params, loc)
+		case "DTEND":
+			current.End, _ = parseICSTime(value, params, loc)
+			hasEnd = !current.End.IsZero()
+		case "DURATION":
+			duration = parseICSDuration(value)
+		case "STATUS":
+			cancelled = value == "CANCELLED"
+		case "RRULE":
+			current.rule = make(map[string]string)
+			for _, part := range strings.Split(value, ";") {
+				if k, v, ok := strings.Cut(part, "="); ok {
+					current.rule[k] = v
+				}
+			}
+		}
+	}
+	return events
+}
+
+// unfoldLines 拆分内容行，以空格或制表符开头的行是上一行的续行
+func unfoldLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseProperty 拆分 NAME;PARAM=VALUE:value 形式的内容行
+func parseProperty(line string) (string, map[string]string, string) {
+	inQuote := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		} else if r == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return "", nil, ""
+	}
+	parts := strings.Split(line[:colon], ";")
+	params := make(map[string]string)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, line[colon+1:]
+}
+
+// parseICSTime 解析 DTSTART/DTEND，返回时间与是否为全天日程
+func parseICSTime(value string, params map[string]string, loc *time.Location) (time.Time, bool) {
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t.In(loc), false
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, false
+}
+
+// parseICSDuration 解析 P1DT2H30M 形式的时长
+func parseICSDuration(value string) time.Duration {
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	var d time.Duration
+	num := ""
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+			continue
+		case r == 'T':
+			continue
+		}
+		n, _ := strconv.Atoi(num)
+		num = ""
+		switch r {
+		case 'W':
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case 'D':
+			d += time.Duration(n) * 24 * time.Hour
+		case 'H':
+			d += time.Duration(n) * time.Hour
+		case 'M':
+			d += time.Duration(n) * time.Minute
+		case 'S':
+			d += time.Duration(n) * time.Second
+		}
+	}
+	return d
+}
+
+func unescapeText(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
+
+// occurrences 返回与 [from, to) 有交集的各次日程
+// 重复规则支持 FREQ、INTERVAL、COUNT、UNTIL 以及每周重复的 BYDAY，其余规则只返回首次
+func (e icsEvent) occurrences(from, to time.Time) []Event {
+	overlaps := func(ev Event) bool { return ev.Start.Before(to) && ev.End.After(from) }
+	if e.rule == nil {
+		if overlaps(e.Event) {
+			return []Event{e.Event}
+		}
+		return nil
+	}
+
+	interval, _ := strconv.Atoi(e.rule["INTERVAL"])
+	if interval <= 0 {
+		interval = 1
+	}
+	count, _ := strconv.Atoi(e.rule["COUNT"])
+	var until time.Time
+	if v := e.rule["UNTIL"]; v != "" {
+		until, _ = parseICSTime(v, nil, e.Start.Location())
+		if e.AllDay {
+			until = until.AddDate(0, 0, 1)
+		}
+	}
+	weekdays := map[time.Weekday]bool{}
+	if e.rule["FREQ"] == "WEEKLY" && e.rule["BYDAY"] != "" {
+		names := map[string]time.Weekday{"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday}
+		for _, d := range strings.Split(e.rule["BYDAY"], ",") {
+			if w, ok := names[d]; ok {
+				weekdays[w] = true
+			}
+		}
+	}
+
+	length := e.End.Sub(e.Start)
+	var result []Event
+	emitted := 0
+	for i := 0; i < maxRecurrences; i++ {
+		var base time.Time
+		switch e.rule["FREQ"] {
+		case "DAILY":
+			base = e.Start.AddDate(0, 0, i*interval)
+		case "WEEKLY":
+			base = e.Start.AddDate(0, 0, 7*i*interval)
+		case "MONTHLY":
+			base = e.Start.AddDate(0, i*interval, 0)
+		case "YEARLY":
+			base = e.Start.AddDate(i*interval, 0, 0)
+		default:
+			if overlaps(e.Event) {
+				return []Event{e.Event}
+			}
+			return nil
+		}
+
+		starts := []time.Time{base}
+		if len(weekdays) > 0 {
+			// 展开本周内的各个星期几，周从首次日程所在的那天起算
+			starts = starts[:0]
+			for d := 0; d < 7; d++ {
+				if t := base.AddDate(0, 0, d); weekdays[t.Weekday()] {
+					starts = append(starts, t)
+				}
+			}
+		}
+		for _, start := range starts {
+			if !start.Before(to) || (!until.IsZero() && start.After(until)) || (count > 0 && emitted >= count) {
+				return result
+			}
+			emitted++
+			ev := e.Event
+			ev.Start, ev.End = start, start.Add(length)
+			if overlaps(ev) {
+				result = append(result, ev)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,136 @@
+package calendar
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+const (
+	holidayRefresh    = 24 * time.Hour // 节假日数据刷新间隔，年底公布次年安排后能及时拿到
+	holidayRetryAfter = time.Hour      // 拉取失败后多久再重试
+)
+
+// Holiday 某天的法定节假日安排
+type Holiday struct {
+	Name string // 节日名称，如“国庆节”
+	Off  bool   // true 放假，false 调休上班
+}
+
+// HolidayBook 法定节假日查询，手工配置优先，其次按年从 holiday_url 拉取并缓存，所有连接共享
+type HolidayBook struct {
+	url    string
+	manual map[string]Holiday
+	client *http.Client
+
+	mu    sync.Mutex
+	years map[int]*holidayYear
+}
+
+type holidayYear struct {
+	days      map[string]Holiday
+	err       error
+	fetchedAt time.Time
+}
+
+// NewHolidayBook 按配置创建节假日查询
+func NewHolidayBook(config configs.CalendarConfig) *HolidayBook {
+	b := &HolidayBook{
+		url:    config.HolidayURL,
+		manual: make(map[string]Holiday),
+		client: &http.Client{Timeout: 10 * time.Second},
+		years:  make(map[int]*holidayYear),
+	}
+	for _, h := range config.Holidays {
+		b.manual[h.Date] = Holiday{Name: h.Name, Off: h.Off}
+	}
+	return b
+}
+
+// Lookup 查询某天的法定节假日安排，ok 为 false 表示当天没有特别安排
+// 拉取数据失败时返回 error，调用方可按周末判断
+func (b *HolidayBook) Lookup(ctx context.Context, date time.Time) (Holiday, bool, error) {
+	key := date.Format("2006-01-02")
+	if h, ok := b.manual[key]; ok {
+		return h, true, nil
+	}
+	if b.url == "" {
+		return Holiday{}, false, nil
+	}
+	days, err := b.year(ctx, date.Year())
+	if err != nil {
+		return Holiday{}, false, err
+	}
+	h, ok := days[key]
+	return h, ok, nil
+}
+
+// year 返回缓存的某年节假日，过期或没有时重新拉取
+func (b *HolidayBook) year(ctx context.Context, year int) (map[string]Holiday, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cached := b.years[year]
+	if cached != nil {
+		ttl := holidayRefresh
+		if cached.err != nil {
+			ttl = holidayRetryAfter
+		}
+		if time.Since(cached.fetchedAt) < ttl {
+			return cached.days, cached.err
+		}
+	}
+
+	days, err := b.fetch(ctx, year)
+	if err != nil && cached != nil && cached.err == nil {
+		// 刷新失败时继续使用上次拉到的数据
+		cached.fetchedAt = time.Now()
+		return cached.days, nil
+	}
+	b.years[year] = &holidayYear{days: days, err: err, fetchedAt: time.Now()}
+	return days, err
+}
+
+// fetch 拉取一年的节假日数据，格式与 holiday-cn 一致：
+// {"year":2025,"days":[{"name":"元旦","date":"2025-01-01","isOffDay":true}]}
+func (b *HolidayBook) fetch(ctx context.Context, year int) (map[string]Holiday, error) {
+	url := strings.ReplaceAll(b.url, "{year}", strconv.Itoa(year))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建节假日请求失败: %v", err)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("拉取节假日数据失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		// 当年的安排尚未公布
+		return map[string]Holiday{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("拉取节假日数据失败: HTTP %d", resp.StatusCode)
+	}
+
+	var data struct {
+		Days []struct {
+			Name     string `json:"name"`
+			Date     string `json:"date"`
+			IsOffDay bool   `json:"isOffDay"`
+		} `json:"days"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("解析节假日数据失败: %v", err)
+	}
+	days := make(map[string]Holiday, len(data.Days))
+	for _, d := range data.Days {
+		days[d.Date] = Holiday{Name: d.Name, Off: d.IsOffDay}
+	}
+	return days, nil
+}
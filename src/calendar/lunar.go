@@ -0,0 +1,277 @@
+package calendar
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// 农历按天文算法推算：朔日为月首，含冬至的月为十一月，两个冬至之间有十三个月时，
+// 第一个不含中气的月为闰月。日期一律按北京时间（UTC+8）划分
+// 朔望与太阳黄经采用 Meeus《天文算法》的简化公式，误差在几分钟以内，
+// 只有朔或节气恰好落在零点前后时可能与官方历书相差一天
+
+const (
+	synodicMonth = 29.530588861 // 朔望月长度（天）
+	unixEpochJD  = 2440587.5    // 1970-01-01 00:00 UTC 的儒略日
+	chinaOffset  = 8.0 / 24     // 北京时间相对 UTC 的偏移（天）
+	deltaT       = 69.0 / 86400 // 力学时与世界时之差的近似值（天）
+)
+
+var (
+	heavenlyStems   = []string{"甲", "乙", "丙", "丁", "戊", "己", "庚", "辛", "壬", "癸"}
+	earthlyBranches = []string{"子", "丑", "寅", "卯", "辰", "巳", "午", "未", "申", "酉", "戌", "亥"}
+	zodiacs         = []string{"鼠", "牛", "虎", "兔", "龙", "蛇", "马", "羊", "猴", "鸡", "狗", "猪"}
+	lunarMonthNames = []string{"正", "二", "三", "四", "五", "六", "七", "八", "九", "十", "冬", "腊"}
+	lunarDayNames   = []string{"初", "十", "廿", "三"}
+	digitNames      = []string{"", "一", "二", "三", "四", "五", "六", "七", "八", "九", "十"}
+
+	// solarTermNames 二十四节气，从黄经 0° 的春分开始，每 15° 一个
+	solarTermNames = []string{"春分", "清明", "谷雨", "立夏", "小满", "芒种", "夏至", "小暑", "大暑", "立秋", "处暑", "白露",
+		"秋分", "寒露", "霜降", "立冬", "小雪", "大雪", "冬至", "小寒", "大寒", "立春", "雨水", "惊蛰"}
+
+	// lunarFestivals 农历节日，键为 月*100+日，闰月不算
+	lunarFestivals = map[int]string{
+		101: "春节", 115: "元宵节", 505: "端午节", 707: "七夕", 715: "中元节", 815: "中秋节", 909: "重阳节", 1208: "腊八节",
+	}
+)
+
+// LunarDate 农历日期
+type LunarDate struct {
+	Year  int  // 农历年，以正月初一为界，数值取所在公历年
+	Month int  // 1-12
+	Day   int  // 1-30
+	Leap  bool // 是否为闰月
+}
+
+// String 返回如“乙巳年（蛇年）闰六月初一”的中文写法
+func (d LunarDate) String() string {
+	month := lunarMonthNames[d.Month-1] + "月"
+	if d.Leap {
+		month = "闰" + month
+	}
+	return fmt.Sprintf("%s年（%s年）%s%s", d.GanZhi(), d.Zodiac(), month, lunarDayName(d.Day))
+}
+
+// GanZhi 返回农历年的干支
+func (d LunarDate) GanZhi() string {
+	i := mod(d.Year-4, 60)
+	return heavenlyStems[i%10] + earthlyBranches[i%12]
+}
+
+// Zodiac 返回农历年的生肖
+func (d LunarDate) Zodiac() string {
+	return zodiacs[mod(d.Year-4, 12)]
+}
+
+func lunarDayName(day int) string {
+	switch day {
+	case 10:
+		return "初十"
+	case 20:
+		return "二十"
+	case 30:
+		return "三十"
+	}
+	return lunarDayNames[day/10] + digitNames[day%10]
+}
+
+// Lunar 返回某个公历日期（取年月日，不看时区）对应的农历日期
+func Lunar(date time.Time) LunarDate {
+	day := dayNumber(date)
+	year := date.Year()
+
+	// 找到 day 所在的两个冬至之间
+	ws1, ws2 := winterSolstice(year-1), winterSolstice(year)
+	if day >= ws2 {
+		ws1, ws2 = ws2, winterSolstice(year+1)
+	}
+	m11a, m11b := newMoonOnOrBefore(ws1), newMoonOnOrBefore(ws2)
+
+	// 从上一个十一月起依次列出月首
+	starts := []int{m11a}
+	for k := newMoonIndex(m11a); ; {
+		k++
+		next := newMoonDay(k)
+		if next > m11b {
+			break
+		}
+		starts = append(starts, next)
+	}
+	leapIndex := -1
+	if len(starts) == 14 { // 含两端的十一月，共十三个月
+		for i := 0; i+1 < len(starts); i++ {
+			if !hasPrincipalTerm(starts[i], starts[i+1]) {
+				leapIndex = i
+				break
+			}
+		}
+	}
+
+	i := len(starts) - 1
+	for starts[i] > day {
+		i--
+	}
+	lunar := LunarDate{Day: day - starts[i] + 1}
+	n := i
+	if leapIndex >= 0 && i >= leapIndex {
+		n--
+		lunar.Leap = i == leapIndex
+	}
+	lunar.Month = mod(10+n, 12) + 1
+	lunar.Year = year
+	if lunar.Month >= 11 && date.Month() <= time.March {
+		lunar.Year--
+	}
+	return lunar
+}
+
+// LunarFestival 返回农历节日名称，不是节日时返回空字符串
+func LunarFestival(date time.Time) string {
+	d := Lunar(date)
+	if d.Leap {
+		return ""
+	}
+	if d.Month == 12 && d.Day >= 29 {
+		if next := Lunar(date.AddDate(0, 0, 1)); next.Month == 1 && next.Day == 1 {
+			return "除夕"
+		}
+	}
+	return lunarFestivals[d.Month*100+d.Day]
+}
+
+// SolarTerm 返回当天交节的节气名称，没有时返回空字符串
+func SolarTerm(date time.Time) string {
+	day := dayNumber(date)
+	a, b := sunLongitude(dayStartJD(day)), sunLongitude(dayStartJD(day+1))
+	if math.Floor(a/15) == math.Floor(b/15) {
+		return ""
+	}
+	return solarTermNames[int(math.Floor(b/15))%24]
+}
+
+// dayNumber 公历日期距 1970-01-01 的天数
+func dayNumber(date time.Time) int {
+	y, m, d := date.Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// dayStartJD 北京时间某天零点对应的力学时儒略日
+func dayStartJD(day int) float64 {
+	return float64(day) + unixEpochJD - chinaOffset + deltaT
+}
+
+// jdToDay 力学时儒略日所在的北京时间日期
+func jdToDay(jde float64) int {
+	return int(math.Floor(jde - deltaT + chinaOffset - unixEpochJD))
+}
+
+// winterSolstice 公历某年冬至所在的日期
+func winterSolstice(year int) int {
+	jd := float64(dayNumber(time.Date(year, time.December, 22, 0, 0, 0, 0, time.UTC))) + unixEpochJD
+	for i := 0; i < 10; i++ {
+		diff := normalizeDegrees(270 - sunLongitude(jd))
+		if diff > 180 {
+			diff -= 360
+		}
+		jd += diff * 365.2422 / 360
+		if math.Abs(diff) < 1e-7 {
+			break
+		}
+	}
+	return jdToDay(jd)
+}
+
+// hasPrincipalTerm 判断 [start, end) 这几天中是否有中气（太阳黄经为 30° 的整数倍）
+func hasPrincipalTerm(start, end int) bool {
+	return math.Floor(sunLongitude(dayStartJD(start))/30) != math.Floor(sunLongitude(dayStartJD(end))/30)
+}
+
+// newMoonIndex 返回朔日不晚于 day 的最后一个朔的序号
+func newMoonIndex(day int) int {
+	k := int(math.Floor((dayStartJD(day) - 2451550.09766) / synodicMonth))
+	for newMoonDay(k) > day {
+		k--
+	}
+	for newMoonDay(k+1) <= day {
+		k++
+	}
+	return k
+}
+
+// newMoonOnOrBefore 返回不晚于 day 的最近一个朔日
+func newMoonOnOrBefore(day int) int {
+	return newMoonDay(newMoonIndex(day))
+}
+
+// newMoonDay 第 k 个朔（k=0 为 2000 年 1 月 6 日）所在的日期
+func newMoonDay(k int) int {
+	return jdToDay(newMoonJDE(float64(k)))
+}
+
+// newMoonJDE 按 Meeus 第 49 章计算第 k 个朔的力学时儒略日
+func newMoonJDE(k float64) float64 {
+	t := k / 1236.85
+	t2, t3, t4 := t*t, t*t*t, t*t*t*t
+	jde := 2451550.09766 + synodicMonth*k + 0.00015437*t2 - 0.000000150*t3 + 0.00000000073*t4
+	e := 1 - 0.002516*t - 0.0000074*t2
+	m := radians(2.5534 + 29.10535670*k - 0.0000014*t2 - 0.00000011*t3)
+	mp := radians(201.5643 + 385.81693528*k + 0.0107582*t2 + 0.00001238*t3 - 0.000000058*t4)
+	f := radians(160.7108 + 390.67050284*k - 0.0016118*t2 - 0.00000227*t3 + 0.000000011*t4)
+	omega := radians(124.7746 - 1.56375588*k + 0.0020672*t2 + 0.00000215*t3)
+
+	jde += -0.40720*math.Sin(mp) +
+		0.17241*e*math.Sin(m) +
+		0.01608*math.Sin(2*mp) +
+		0.01039*math.Sin(2*f) +
+		0.00739*e*math.Sin(mp-m) -
+		0.00514*e*math.Sin(mp+m) +
+		0.00208*e*e*math.Sin(2*m) -
+		0.00111*math.Sin(mp-2*f) -
+		0.00057*math.Sin(mp+2*f) +
+		0.00056*e*math.Sin(2*mp+m) -
+		0.00042*math.Sin(3*mp) +
+		0.00042*e*math.Sin(m+2*f) +
+		0.00038*e*math.Sin(m-2*f) -
+		0.00024*e*math.Sin(2*mp-m) -
+		0.00017*math.Sin(omega) -
+		0.00007*math.Sin(mp+2*m) +
+		0.00004*math.Sin(2*mp-2*f) +
+		0.00004*math.Sin(3*m) +
+		0.00003*math.Sin(mp+m-2*f) +
+		0.00003*math.Sin(2*mp+2*f) -
+		0.00003*math.Sin(mp+m+2*f) +
+		0.00003*math.Sin(mp-m+2*f) -
+		0.00002*math.Sin(mp-m-2*f) -
+		0.00002*math.Sin(3*mp+m) +
+		0.00002*math.Sin(4*mp)
+	return jde
+}
+
+// sunLongitude 按 Meeus 第 25 章的低精度公式计算太阳视黄经（度）
+func sunLongitude(jde float64) float64 {
+	t := (jde - 2451545) / 36525
+	l0 := 280.46646 + 36000.76983*t + 0.0003032*t*t
+	m := radians(357.52911 + 35999.05029*t - 0.0001537*t*t)
+	c := (1.914602-0.004817*t-0.000014*t*t)*math.Sin(m) +
+		(0.019993-0.000101*t)*math.Sin(2*m) +
+		0.000289*math.Sin(3*m)
+	omega := radians(125.04 - 1934.136*t)
+	return normalizeDegrees(l0 + c - 0.00569 - 0.00478*math.Sin(omega))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func normalizeDegrees(deg float64) float64 {
+	deg = math.Mod(deg, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+func mod(a, b int) int {
+	return (a%b + b) % b
+}
@@ -21,6 +21,16 @@ type Config struct {
 			AllowedDevices []string      `yaml:"allowed_devices"`
 			Tokens         []TokenConfig `yaml:"tokens"`
 		} `yaml:"auth"`
+		TrustedProxies  []string `yaml:"trusted_proxies"` // 可信代理列表（IP或CIDR）
+		RealIPHeaders   []string `yaml:"real_ip_headers"` // 解析真实IP的请求头，按顺序尝试
+		GracefulRestart struct {
+			Enabled      bool   `yaml:"enabled"`       // 是否允许 SIGUSR2 触发平滑重启
+			DrainTimeout string `yaml:"drain_timeout"` // 老进程等待现有会话结束的最长时间
+		} `yaml:"graceful_restart"`
+		IdleTimeout  string `yaml:"idle_timeout"`  // 连接无交互多久后道别并断开，为空时不断开
+		IdleFarewell string `yaml:"idle_farewell"` // 空闲断开前播报的告别语，为空时直接断开
+		Prewarm      bool   `yaml:"prewarm"`       // 连接建立时并行预建 ASR 流、预热 LLM 连接，降低首轮延迟
+		Timezone     string `yaml:"timezone"`      // 设备默认时区（IANA 名称，如 Asia/Shanghai），为空时使用服务器本地时区
 	} `yaml:"server"`
 
 	Log struct {
@@ -35,13 +45,49 @@ type Config struct {
 		Port      int    `yaml:"port"`
 		StaticDir string `yaml:"static_dir"`
 		Websocket string `yaml:"websocket"`
+		Auth      struct {
+			Enabled     bool              `yaml:"enabled"`      // 是否启用 /api 鉴权
+			AdminKey    string            `yaml:"admin_key"`    // 引导用管理员密钥，用于创建其他 API key
+			JWTSecret   string            `yaml:"jwt_secret"`   // JWT 签名密钥
+			JWTExpire   string            `yaml:"jwt_expire"`   // JWT 有效期
+			DefaultMode string            `yaml:"default_mode"` // 未匹配路由组时的鉴权模式
+			Routes      map[string]string `yaml:"routes"`       // 路由前缀 -> 鉴权模式（none/api_key/jwt/any）
+		} `yaml:"auth"`
+		CORS      CORSConfig      `yaml:"cors"`
+		AccessLog AccessLogConfig `yaml:"access_log"`
+		Metrics   MetricsConfig   `yaml:"metrics"`
+		OTA       OTAConfig       `yaml:"ota"`
 	} `yaml:"web"`
 
-	DefaultPrompt    string `yaml:"prompt"`
-	DeleteAudio      bool   `yaml:"delete_audio"`
-	UsePrivateConfig bool   `yaml:"use_private_config"`
+	Database struct {
+		Type string `yaml:"type"` // 数据库类型，目前支持 sqlite
+		DSN  string `yaml:"dsn"`  // 连接串，sqlite 为数据库文件路径
+	} `yaml:"database"`
+
+	DefaultPrompt    string        `yaml:"prompt"`
+	Roles            []RoleConfig  `yaml:"roles"`  // 可切换的角色，配置后 LLM 可调用 change_role 切换
+	Scenes           []SceneConfig `yaml:"scenes"` // 预定义场景，配置后 LLM 可调用 run_scene 依次执行一组工具
+	DeleteAudio      bool          `yaml:"delete_audio"`
+	UsePrivateConfig bool          `yaml:"use_private_config"`
+
+	SelectedModule map[string]string   `yaml:"selected_module"`
+	TTSFallback    []string            `yaml:"tts_fallback"`  // 主 TTS 合成失败时依次尝试的备用 TTS
+	LLMCache       LLMCacheConfig      `yaml:"llm_cache"`     // 相同问题的 LLM 回复缓存
+	Punctuation    PunctuationConfig   `yaml:"punctuation"`   // ASR 结果标点恢复
+	Silence        SilenceConfig       `yaml:"silence"`       // 静音提示与结束对话
+	Greeting       GreetingConfig      `yaml:"greeting"`      // 设备连接后自动播报的欢迎语
+	AudioOutput    AudioOutputConfig   `yaml:"audio_output"`  // 下发音频的处理
+	QuickReply     QuickReplyConfig    `yaml:"quick_reply"`   // 只说唤醒词时的快速回复
+	Care           CareConfig          `yaml:"care"`          // 久未交互时的主动关怀
+	Calendar       CalendarConfig      `yaml:"calendar"`      // 节假日数据，供 get_calendar 查询
+	Translation    TranslationConfig   `yaml:"translation"`   // 翻译模式，配置语言后可用语音指令进入
+	Transcription  TranscriptionConfig `yaml:"transcription"` // 会议转写模式
+	Companion      CompanionConfig     `yaml:"companion"`     // 同一设备的 App 伴随连接
+	History        HistoryConfig       `yaml:"history"`       // 对话记录落库
+	Paging         PagingConfig        `yaml:"paging"`        // 长回复分批播报
+	ToolHistory    ToolHistoryConfig   `yaml:"tool_history"`  // 工具调用在对话历史中的保留方式
 
-	SelectedModule map[string]string `yaml:"selected_module"`
+	Devices map[string]DeviceConfig `yaml:"devices"` // 按设备ID覆盖的配置
 
 	VAD   map[string]VADConfig  `yaml:"VAD"`
 	ASR   map[string]ASRConfig  `yaml:"ASR"`
@@ -69,13 +115,54 @@ type ASRConfig map[string]interface{}
 
 // TTSConfig TTS配置结构
 type TTSConfig struct {
-	Type      string `yaml:"type"`
-	Voice     string `yaml:"voice"`
-	Format    string `yaml:"format"`
-	OutputDir string `yaml:"output_dir"`
-	AppID     string `yaml:"appid"`
-	Token     string `yaml:"token"`
-	Cluster   string `yaml:"cluster"`
+	Type       string `yaml:"type"`
+	Voice      string `yaml:"voice"`
+	Format     string `yaml:"format"`
+	OutputDir  string `yaml:"output_dir"`
+	AppID      string `yaml:"appid"`
+	Token      string `yaml:"token"`
+	Cluster    string `yaml:"cluster"`
+	Timeout    string `yaml:"timeout"`     // 单次合成超时时间
+	MaxRetries *int   `yaml:"max_retries"` // 网络失败重试次数，不配置时使用默认值
+
+	SurportedVoices []VoiceInfo            `yaml:"surported_voices"` // 可切换的音色列表
+	Extra           map[string]interface{} `yaml:",inline"`          // 各提供者的专有参数
+}
+
+// RoleConfig 角色配置，切换角色时同时替换提示词与 TTS 音色
+type RoleConfig struct {
+	Name        string `yaml:"name"`        // 角色名称，LLM 按名称切换
+	Description string `yaml:"description"` // 角色简介，供 LLM 判断用户想切换的角色
+	Prompt      string `yaml:"prompt"`      // 角色提示词
+	Voice       string `yaml:"voice"`       // 主 TTS 使用的音色，为空时保持当前音色
+	Language    string `yaml:"language"`    // 角色说的语言，如 en-US，用于选择快速回复词组
+}
+
+// SceneConfig 场景配置，一句话顺序执行一组工具调用
+type SceneConfig struct {
+	Name        string      `yaml:"name"`        // 场景名称，LLM 按名称执行
+	Description string      `yaml:"description"` // 场景说明，供 LLM 判断用户想执行的场景，如“用户说要睡觉时”
+	Steps       []SceneStep `yaml:"steps"`       // 按顺序执行的步骤
+	Reply       string      `yaml:"reply"`       // 全部成功后的播报，为空时使用默认回复
+}
+
+// SceneStep 场景中的一步，失败时按相反顺序执行已完成步骤的回滚工具
+type SceneStep struct {
+	Name              string                 `yaml:"name"`               // 步骤名称，用于失败播报，如“关灯”
+	Tool              string                 `yaml:"tool"`               // 工具名称，可以是设备端/外部 MCP 工具或本地函数
+	Arguments         map[string]interface{} `yaml:"arguments"`          // 工具参数
+	Rollback          string                 `yaml:"rollback"`           // 回滚工具，为空时该步骤不回滚
+	RollbackArguments map[string]interface{} `yaml:"rollback_arguments"` // 回滚工具参数
+	Optional          bool                   `yaml:"optional"`           // 失败时跳过，不触发回滚
+}
+
+// VoiceInfo 音色信息
+type VoiceInfo struct {
+	Name        string `yaml:"name" json:"name"`                           // 音色id，合成时使用
+	DisplayName string `yaml:"display_name" json:"display_name,omitempty"` // 展示名称
+	Language    string `yaml:"language" json:"language,omitempty"`         // 语言，如 zh-CN
+	Gender      string `yaml:"gender" json:"gender,omitempty"`             // male / female
+	Description string `yaml:"description" json:"description,omitempty"`   // 音色描述，供 LLM 选择音色参考
 }
 
 // LLMConfig LLM配置结构
@@ -87,9 +174,250 @@ type LLMConfig struct {
 	Temperature float64                `yaml:"temperature"`
 	MaxTokens   int                    `yaml:"max_tokens"`
 	TopP        float64                `yaml:"top_p"`
+	Timeout     string                 `yaml:"timeout"`     // 单次请求超时时间
+	MaxRetries  *int                   `yaml:"max_retries"` // 建立请求失败时的重试次数，不配置时使用默认值
 	Extra       map[string]interface{} `yaml:",inline"`
 }
 
+// LLMCacheConfig LLM 回复缓存配置结构
+type LLMCacheConfig struct {
+	Enabled      bool   `yaml:"enabled"`       // 是否启用回复缓存
+	TTL          string `yaml:"ttl"`           // 缓存有效期
+	MaxEntries   int    `yaml:"max_entries"`   // 最多缓存的回复条数
+	HistoryTurns int    `yaml:"history_turns"` // 参与匹配的最近对话轮数，0 表示只匹配提示词和问题
+}
+
+// GreetingConfig 连接欢迎语配置结构
+type GreetingConfig struct {
+	Enabled bool             `yaml:"enabled"` // 是否在设备连接后自动播报欢迎语
+	Text    string           `yaml:"text"`    // 没有匹配到时间段时的欢迎语
+	Periods []GreetingPeriod `yaml:"periods"` // 按时间段使用不同的欢迎语，按顺序取第一个匹配的
+}
+
+// AudioOutputConfig 下发音频处理配置结构
+type AudioOutputConfig struct {
+	Loudness        LoudnessConfig   `yaml:"loudness"`          // 编码前的响度归一化
+	FrameDuration   int              `yaml:"frame_duration"`    // Opus 帧时长(ms)，可选 10/20/40/60，默认 60
+	PreBufferFrames *int             `yaml:"pre_buffer_frames"` // 每句开头不等待直接发送的帧数，默认 3
+	SendAhead       string           `yaml:"send_ahead"`        // 发送领先实时播放的时长，如 120ms，默认 0
+	Progress        ProgressConfig   `yaml:"progress"`          // 长音频的播放进度
+	Opus            OpusConfig       `yaml:"opus"`              // Opus 编码参数
+	StreamTTS       bool             `yaml:"stream_tts"`        // 主TTS支持时边合成边下发
+	Cache           AudioCacheConfig `yaml:"cache"`             // 重复播放的音频文件缓存编码结果
+}
+
+// AudioCacheConfig 音频帧缓存配置结构
+type AudioCacheConfig struct {
+	Enabled bool `yaml:"enabled"`
+	MaxSize int  `yaml:"max_size_mb"` // 缓存上限(MB)，默认 32
+}
+
+// OpusConfig 下发音频的 Opus 编码配置结构
+type OpusConfig struct {
+	Bitrate       int                `yaml:"bitrate"`         // 固定码率(bps)，0 由编码器自动选择
+	Complexity    int                `yaml:"complexity"`      // 固定复杂度 1-10，0 使用编码器默认值
+	Adaptive      AdaptiveOpusConfig `yaml:"adaptive"`        // 按网络状况自适应，启用后忽略固定码率与复杂度
+	DTX           bool               `yaml:"dtx"`             // 静音段只输出极小的 DTX 包
+	CBR           bool               `yaml:"cbr"`             // 固定码率，关闭 VBR
+	SkipDTXFrames bool               `yaml:"skip_dtx_frames"` // 开启 DTX 时不下发静音包
+}
+
+// AdaptiveOpusConfig 自适应 Opus 码率配置结构
+type AdaptiveOpusConfig struct {
+	Enabled      bool        `yaml:"enabled"`
+	Levels       []OpusLevel `yaml:"levels"`        // 从低到高的编码档位，初始为中间一档
+	UpgradeAfter int         `yaml:"upgrade_after"` // 连续几句发送顺畅后升一档，默认 3
+}
+
+// OpusLevel 自适应码率的一个编码档位
+type OpusLevel struct {
+	Bitrate    int `yaml:"bitrate"`    // 码率(bps)
+	Complexity int `yaml:"complexity"` // 复杂度 1-10
+}
+
+// ProgressConfig 播放进度消息配置结构，仅下发给 hello 中声明了 progress 的客户端
+type ProgressConfig struct {
+	Interval    string `yaml:"interval"`     // 进度上报间隔，默认 1s
+	MinDuration string `yaml:"min_duration"` // 单段音频不短于该时长才上报进度，默认 10s
+}
+
+// LoudnessConfig 响度归一化配置结构
+type LoudnessConfig struct {
+	Enabled    bool    `yaml:"enabled"`     // 是否把 TTS 与音乐统一到目标响度
+	TargetLUFS float64 `yaml:"target_lufs"` // 目标积分响度，默认 -16
+	MaxGain    float64 `yaml:"max_gain"`    // 最大提升量(dB)，默认 12，避免把很轻的音频或噪声放大过度
+	Ceiling    float64 `yaml:"ceiling"`     // 限幅器峰值上限(dBFS)，默认 -1
+}
+
+// QuickReplyConfig 唤醒词快速回复配置结构
+type QuickReplyConfig struct {
+	Enabled   bool              `yaml:"enabled"`    // 客户端上报的文本只是唤醒词时，不请求 LLM，直接播报快速回复
+	WakeWords []string          `yaml:"wake_words"` // 唤醒词，为空时使用内置唤醒词
+	Words     []string          `yaml:"words"`      // 默认回复词组，为空时使用内置词组
+	Groups    []QuickReplyGroup `yaml:"groups"`     // 按角色或语言配置的回复词组
+}
+
+// QuickReplyGroup 一组快速回复，role 匹配当前角色的优先，其次 language 匹配角色语言的
+type QuickReplyGroup struct {
+	Role     string   `yaml:"role"`     // 角色名称
+	Language string   `yaml:"language"` // 语言，如 en-US，与角色的 language 比较
+	Words    []string `yaml:"words"`
+}
+
+// GreetingPeriod 欢迎语时间段，start 大于 end 时表示跨过零点
+type GreetingPeriod struct {
+	Start string `yaml:"start"` // HH:MM，包含
+	End   string `yaml:"end"`   // HH:MM，不包含
+	Text  string `yaml:"text"`  // 支持 {{.Time}} {{.Weekday}} {{.Date}} 模板变量
+}
+
+// CareConfig 主动关怀配置结构：设备在线但久未交互时，在允许时段内主动播报一句问候或提醒
+type CareConfig struct {
+	Enabled     bool     `yaml:"enabled"`
+	IdleAfter   string   `yaml:"idle_after"`   // 多久没有交互后主动关怀，如 2h
+	MinInterval string   `yaml:"min_interval"` // 同一设备两次主动关怀的最小间隔
+	MaxPerDay   int      `yaml:"max_per_day"`  // 同一设备每天最多主动关怀次数，0 表示不限
+	ActiveHours []string `yaml:"active_hours"` // 允许主动关怀的时段，如 "09:00-21:00"，为空表示全天
+	QuietHours  []string `yaml:"quiet_hours"`  // 免打扰时段，优先于 active_hours
+	Messages    []string `yaml:"messages"`     // 依次轮换的关怀语，支持 {{.Time}} {{.Weekday}} {{.Date}} 模板变量
+	Prompt      string   `yaml:"prompt"`       // 配置后由 LLM 按该要求生成关怀语，失败时使用 messages
+}
+
+// SilenceConfig 静音提示与结束对话配置结构
+type SilenceConfig struct {
+	NoVoiceTimeout   string `yaml:"no_voice_timeout"`   // 拾音后多久没有识别到语音算一次静音，为空时不检测
+	MaxSilenceRounds int    `yaml:"max_silence_rounds"` // 连续静音几次后结束对话，0 或负数表示不结束，设备配置用 -1 关闭
+	Prompt           string `yaml:"prompt"`             // 静音提示话术，为空时不提示
+	EndPrompt        string `yaml:"end_prompt"`         // 结束对话时的告别话术
+}
+
+// PagingConfig 长回复分批播报配置结构
+type PagingConfig struct {
+	Enabled       bool     `yaml:"enabled"`        // 是否启用分批播报
+	PageChars     int      `yaml:"page_chars"`     // 每批至少播报的字数，达到后在段落结尾暂停
+	Prompt        string   `yaml:"prompt"`         // 暂停时的询问话术
+	ContinueWords []string `yaml:"continue_words"` // 触发继续播报的肯定回答，忽略标点后完全匹配
+}
+
+// ToolHistoryConfig 工具调用历史配置结构
+type ToolHistoryConfig struct {
+	Fold           bool `yaml:"fold"`             // LLM 根据工具结果作答后，把工具往返折叠为一条摘要消息
+	MaxResultChars int  `yaml:"max_result_chars"` // 摘要中保留的工具结果字数，0 表示不截断
+}
+
+// DeviceConfig 按设备覆盖的配置，未配置的字段沿用全局配置
+type DeviceConfig struct {
+	Silence      SilenceConfig    `yaml:"silence"`
+	IdleTimeout  string           `yaml:"idle_timeout"`  // 覆盖 server.idle_timeout
+	IdleFarewell string           `yaml:"idle_farewell"` // 覆盖 server.idle_farewell
+	Care         *CareConfig      `yaml:"care"`          // 整体覆盖全局 care 配置
+	Timezone     string           `yaml:"timezone"`      // 设备所在时区，覆盖 server.timezone
+	City         string           `yaml:"city"`          // 设备所在城市，随时间一起告知 LLM
+	Calendars    []CalendarSource `yaml:"calendars"`     // 设备绑定的日程日历
+
+	// 按设备选择的模块，填写 ASR/LLM/TTS 下的配置名称，为空时沿用 selected_module
+	ASR    string `yaml:"ASR"`
+	LLM    string `yaml:"LLM"`
+	TTS    string `yaml:"TTS"`
+	Prompt string `yaml:"prompt"` // 覆盖全局 prompt
+}
+
+// ForDevice 返回按设备覆盖了模块选择与提示词的配置，以及与全局不同的模块选择；
+// 设备没有覆盖时原样返回全局配置与空的模块选择
+func (c *Config) ForDevice(deviceID string) (*Config, map[string]string) {
+	device, ok := c.Devices[deviceID]
+	if !ok || deviceID == "" {
+		return c, nil
+	}
+	overrides := map[string]string{}
+	for module, name := range map[string]string{"ASR": device.ASR, "LLM": device.LLM, "TTS": device.TTS} {
+		if name != "" && name != c.SelectedModule[module] {
+			overrides[module] = name
+		}
+	}
+	if len(overrides) == 0 && device.Prompt == "" {
+		return c, nil
+	}
+
+	// 浅拷贝即可，连接只读取配置
+	cfg := *c
+	cfg.SelectedModule = make(map[string]string, len(c.SelectedModule))
+	for module, name := range c.SelectedModule {
+		cfg.SelectedModule[module] = name
+	}
+	for module, name := range overrides {
+		cfg.SelectedModule[module] = name
+	}
+	if device.Prompt != "" {
+		cfg.DefaultPrompt = device.Prompt
+	}
+	return &cfg, overrides
+}
+
+// TranslationConfig 翻译模式配置结构：进入后用户的每句话经 LLM 翻译，用目标语言音色播报
+// 双向口译时自动判断每句话是两种语言中的哪一种，翻译成另一种并用对应音色播报
+type TranslationConfig struct {
+	Languages []TranslationLanguage `yaml:"languages"`  // 可选的语言
+	Prompt    string                `yaml:"prompt"`     // 翻译提示词，{{.Language}} 替换为目标语言，为空时使用内置提示词
+	ExitWords []string              `yaml:"exit_words"` // 说出包含这些词的话时退出翻译模式
+}
+
+// TranslationLanguage 翻译目标语言
+type TranslationLanguage struct {
+	Name  string `yaml:"name"`  // 语言名称，如“英语”
+	Code  string `yaml:"code"`  // 语言代码，如 en、zh，双向口译时与 ASR 检测到的语种比对
+	Voice string `yaml:"voice"` // 主 TTS 播报译文使用的音色，为空时保持当前音色
+}
+
+// TranscriptionConfig 会议转写配置结构：转写期间只记录带时间戳与说话人的文本，不触发 LLM 与 TTS
+type TranscriptionConfig struct {
+	SummaryPrompt string   `yaml:"summary_prompt"` // 结束后生成摘要的提示词，为空时使用内置提示词
+	EndWords      []string `yaml:"end_words"`      // 语音开始的转写，说出包含这些词的话时结束
+}
+
+// HistoryConfig 对话记录配置结构
+type HistoryConfig struct {
+	Enabled       bool   `yaml:"enabled"`        // 是否把带设备ID的连接的对话写入数据库，供 /api/devices/{id}/sessions 查询
+	RestoreTurns  int    `yaml:"restore_turns"`  // 设备重新连接时载入最近几轮对话，0 表示不载入
+	RestoreWithin string `yaml:"restore_within"` // 只载入这段时间内的对话，如 24h，为空时不限
+}
+
+// CompanionConfig 伴随连接配置结构
+type CompanionConfig struct {
+	Enabled  bool   `yaml:"enabled"`   // 是否允许 App 以 role=companion 连接
+	AuthMode string `yaml:"auth_mode"` // 握手鉴权方式 none/apikey/jwt/any，web.auth 未启用时不鉴权，默认 any
+}
+
+// CalendarConfig 日历查询配置结构
+type CalendarConfig struct {
+	HolidayURL string          `yaml:"holiday_url"` // 法定节假日数据地址，{year} 替换为年份，格式同 holiday-cn
+	Holidays   []HolidayConfig `yaml:"holidays"`    // 手工配置的节假日与调休，优先于 holiday_url
+}
+
+// HolidayConfig 某天的法定节假日安排
+type HolidayConfig struct {
+	Date string `yaml:"date"` // YYYY-MM-DD
+	Name string `yaml:"name"` // 节日名称
+	Off  bool   `yaml:"off"`  // true 放假，false 调休上班
+}
+
+// CalendarSource 日程日历源
+type CalendarSource struct {
+	Name     string `yaml:"name"` // 日历名称，如“工作”
+	Type     string `yaml:"type"` // ics 订阅地址 / caldav 日历集合地址，默认 ics
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"` // 需要认证时填写，使用 Basic 认证
+	Password string `yaml:"password"`
+}
+
+// PunctuationConfig ASR 结果标点恢复配置结构
+type PunctuationConfig struct {
+	Enabled    bool   `yaml:"enabled"`     // 是否启用标点恢复
+	Type       string `yaml:"type"`        // rule 规则补全 / sherpa_onnx 标点模型
+	Model      string `yaml:"model"`       // sherpa_onnx 标点模型文件路径
+	NumThreads int    `yaml:"num_threads"` // 模型推理线程数
+}
+
 // SecurityConfig 图片安全配置结构
 type SecurityConfig struct {
 	MaxFileSize       int64    `yaml:"max_file_size"`      // 最大文件大小（字节）
@@ -101,6 +429,38 @@ type SecurityConfig struct {
 	ValidationTimeout string   `yaml:"validation_timeout"` // 验证超时时间
 }
 
+// CORSConfig 跨域配置结构
+type CORSConfig struct {
+	Enabled          bool     `yaml:"enabled"`           // 是否启用跨域处理
+	AllowOrigins     []string `yaml:"allow_origins"`     // 允许的 origin 列表，* 表示全部
+	AllowMethods     []string `yaml:"allow_methods"`     // 允许的方法
+	AllowHeaders     []string `yaml:"allow_headers"`     // 允许的请求头
+	ExposeHeaders    []string `yaml:"expose_headers"`    // 允许浏览器读取的响应头
+	AllowCredentials bool     `yaml:"allow_credentials"` // 是否允许携带凭证
+	MaxAge           string   `yaml:"max_age"`           // 预检结果缓存时间
+}
+
+// AccessLogConfig HTTP 访问日志配置结构
+type AccessLogConfig struct {
+	Enabled       bool     `yaml:"enabled"`        // 是否记录访问日志
+	SampleRate    float64  `yaml:"sample_rate"`    // 正常请求的采样率（0~1），错误与慢请求总是记录
+	SlowThreshold string   `yaml:"slow_threshold"` // 慢请求阈值
+	SkipPaths     []string `yaml:"skip_paths"`     // 不记录的路径
+}
+
+// MetricsConfig 监控指标配置结构
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"` // 是否开放 Prometheus 指标接口
+	Path    string `yaml:"path"`    // 指标接口路径，默认 /metrics
+}
+
+// OTAConfig OTA 固件下发配置结构
+type OTAConfig struct {
+	SigningKey        string `yaml:"signing_key"`         // ed25519 签名私钥（base64），为空时只下发 sha256
+	RequireChecksum   bool   `yaml:"require_checksum"`    // 只下发通过接口上传、已记录校验和的固件
+	DownloadRateLimit string `yaml:"download_rate_limit"` // 所有固件下载合计的带宽上限，如 10MB，为空时不限速
+}
+
 // ConnectivityCheckConfig 连通性检查配置结构
 type ConnectivityCheckConfig struct {
 	Enabled       bool   `yaml:"enabled"`        // 是否启用连通性检查
@@ -123,6 +483,7 @@ type VLLMConfig struct {
 	Temperature float64                `yaml:"temperature"` // 温度参数
 	MaxTokens   int                    `yaml:"max_tokens"`  // 最大令牌数
 	TopP        float64                `yaml:"top_p"`       // TopP参数
+	Timeout     string                 `yaml:"timeout"`     // 单次请求超时时间
 	Security    SecurityConfig         `yaml:"security"`    // 图片安全配置
 	Extra       map[string]interface{} `yaml:",inline"`     // 额外配置
 }
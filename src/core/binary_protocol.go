@@ -0,0 +1,106 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// 二进制帧的负载类型
+const (
+	binaryTypeAudio = 0 // 音频数据
+	binaryTypeJSON  = 1 // JSON 文本
+)
+
+// 各协议版本的帧头长度
+const (
+	binaryHeaderV2 = 16 // version(2) + type(2) + reserved(4) + timestamp(4) + payload_size(4)
+	binaryHeaderV3 = 4  // type(1) + reserved(1) + payload_size(2)
+)
+
+// binaryFrame 解析后的二进制帧，v1 没有帧头，整帧都是音频
+type binaryFrame struct {
+	Type      int
+	Timestamp uint32 // 仅 v2 携带，单位毫秒
+	Payload   []byte
+}
+
+// parseBinaryFrame 按协议版本解析客户端上传的二进制帧，多字节字段均为网络字节序
+func parseBinaryFrame(version int, data []byte) (binaryFrame, error) {
+	switch version {
+	case 2:
+		if len(data) < binaryHeaderV2 {
+			return binaryFrame{}, fmt.Errorf("v2 帧长度不足: %d", len(data))
+		}
+		if v := binary.BigEndian.Uint16(data[0:2]); v != 2 {
+			return binaryFrame{}, fmt.Errorf("v2 帧头版本不匹配: %d", v)
+		}
+		size := binary.BigEndian.Uint32(data[12:16])
+		if int(size) > len(data)-binaryHeaderV2 {
+			return binaryFrame{}, fmt.Errorf("v2 帧负载长度 %d 超出帧长度 %d", size, len(data))
+		}
+		return binaryFrame{
+			Type:      int(binary.BigEndian.Uint16(data[2:4])),
+			Timestamp: binary.BigEndian.Uint32(data[8:12]),
+			Payload:   data[binaryHeaderV2 : binaryHeaderV2+int(size)],
+		}, nil
+	case 3:
+		if len(data) < binaryHeaderV3 {
+			return binaryFrame{}, fmt.Errorf("v3 帧长度不足: %d", len(data))
+		}
+		size := binary.BigEndian.Uint16(data[2:4])
+		if int(size) > len(data)-binaryHeaderV3 {
+			return binaryFrame{}, fmt.Errorf("v3 帧负载长度 %d 超出帧长度 %d", size, len(data))
+		}
+		return binaryFrame{
+			Type:    int(data[0]),
+			Payload: data[binaryHeaderV3 : binaryHeaderV3+int(size)],
+		}, nil
+	default:
+		return binaryFrame{Type: binaryTypeAudio, Payload: data}, nil
+	}
+}
+
+// packBinaryFrame 按协议版本封装下发给客户端的二进制帧
+func packBinaryFrame(version int, frameType int, timestamp uint32, payload []byte) []byte {
+	switch version {
+	case 2:
+		data := make([]byte, binaryHeaderV2+len(payload))
+		binary.BigEndian.PutUint16(data[0:2], 2)
+		binary.BigEndian.PutUint16(data[2:4], uint16(frameType))
+		binary.BigEndian.PutUint32(data[8:12], timestamp)
+		binary.BigEndian.PutUint32(data[12:16], uint32(len(payload)))
+		copy(data[binaryHeaderV2:], payload)
+		return data
+	case 3:
+		data := make([]byte, binaryHeaderV3+len(payload))
+		data[0] = byte(frameType)
+		binary.BigEndian.PutUint16(data[2:4], uint16(len(payload)))
+		copy(data[binaryHeaderV3:], payload)
+		return data
+	default:
+		return payload
+	}
+}
+
+// protocolVersion 返回本连接协商后的协议版本
+func (h *ConnectionHandler) protocolVersion() int {
+	return int(h.caps.version.Load())
+}
+
+// writeAudioFrame 按协商的协议版本封装并下发一帧音频
+func (h *ConnectionHandler) writeAudioFrame(payload []byte) error {
+	return h.conn.WriteMessage(2, packBinaryFrame(h.protocolVersion(), binaryTypeAudio, h.downstreamTimestamp(), payload))
+}
+
+// downstreamTimestamp 下行帧的时间戳，沿用客户端时钟：最近一帧上行时间戳加上此后经过的时长，
+// 客户端用当前时间减去它即可得到端到端延迟，也可据此对齐回声消除的参考信号；
+// 尚未收到带时间戳的上行帧时使用自连接建立起的毫秒数
+func (h *ConnectionHandler) downstreamTimestamp() uint32 {
+	stamp := h.lastClientStamp.Load()
+	if stamp < 0 {
+		return uint32(time.Since(h.protocolEpoch).Milliseconds())
+	}
+	elapsed := time.Since(time.Unix(0, h.lastClientRecv.Load()))
+	return uint32(stamp + elapsed.Milliseconds())
+}
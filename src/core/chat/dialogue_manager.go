@@ -2,6 +2,10 @@ package chat
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"xiaozhi-server-go/src/core/types"
 	"xiaozhi-server-go/src/core/utils"
@@ -9,9 +13,10 @@ import (
 
 type Message = types.Message
 
-// DialogueManager 管理对话上下文和历史
+// DialogueManager 管理对话上下文和历史，并发安全：对话、主动播报与推送会在不同协程中写入
 type DialogueManager struct {
 	logger   *utils.Logger
+	mu       sync.Mutex
 	dialogue []Message
 	memory   MemoryInterface
 }
@@ -29,6 +34,8 @@ func (dm *DialogueManager) SetSystemMessage(systemMessage string) {
 	if systemMessage == "" {
 		return
 	}
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 
 	// 如果对话中已经有系统消息，则不再添加
 	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
@@ -44,12 +51,97 @@ func (dm *DialogueManager) SetSystemMessage(systemMessage string) {
 
 // Put 添加新消息到对话
 func (dm *DialogueManager) Put(message Message) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 	dm.dialogue = append(dm.dialogue, message)
 }
 
-// GetLLMDialogue 获取完整对话历史
+// UpdateLastAssistant 修改最后一条助手消息的内容，最后一条不是助手消息时返回 false
+func (dm *DialogueManager) UpdateLastAssistant(content string) bool {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	if len(dm.dialogue) == 0 || dm.dialogue[len(dm.dialogue)-1].Role != "assistant" {
+		return false
+	}
+	dm.dialogue[len(dm.dialogue)-1].Content = content
+	return true
+}
+
+// FoldToolCalls 把已被消化的工具往返折叠为一条助手摘要消息
+// assistant(tool_calls) 与其后的 tool 消息之后还有其他消息时，视为 LLM 已根据结果作答，
+// 折叠后摘要中的工具结果最多保留 maxResultRunes 个字符，返回折叠的往返数
+func (dm *DialogueManager) FoldToolCalls(maxResultRunes int) int {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	folded := 0
+	result := make([]Message, 0, len(dm.dialogue))
+	for i := 0; i < len(dm.dialogue); i++ {
+		msg := dm.dialogue[i]
+		if msg.Role != "assistant" || len(msg.ToolCalls) == 0 {
+			result = append(result, msg)
+			continue
+		}
+		end := i + 1
+		for end < len(dm.dialogue) && dm.dialogue[end].Role == "tool" {
+			end++
+		}
+		if end >= len(dm.dialogue) {
+			// 结果还没有被 LLM 消化，保持原样
+			result = append(result, dm.dialogue[i:]...)
+			break
+		}
+		result = append(result, Message{
+			Role:    "assistant",
+			Content: summarizeToolCalls(msg.ToolCalls, dm.dialogue[i+1:end], maxResultRunes),
+		})
+		folded++
+		i = end - 1
+	}
+	dm.dialogue = result
+	return folded
+}
+
+// summarizeToolCalls 生成工具往返的摘要，如（调用了工具 get_weather {"city":"北京"}，结果：晴）
+func summarizeToolCalls(calls []types.ToolCall, results []Message, maxResultRunes int) string {
+	resultByID := make(map[string]string, len(results))
+	for _, result := range results {
+		resultByID[result.ToolCallID] = result.Content
+	}
+	parts := make([]string, 0, len(calls))
+	for i, call := range calls {
+		content, ok := resultByID[call.ID]
+		if !ok && i < len(results) {
+			content = results[i].Content
+		}
+		if maxResultRunes > 0 && utf8.RuneCountInString(content) > maxResultRunes {
+			content = string([]rune(content)[:maxResultRunes]) + "…"
+		}
+		parts = append(parts, fmt.Sprintf("调用了工具 %s %s，结果：%s", call.Function.Name, call.Function.Arguments, content))
+	}
+	return "（" + strings.Join(parts, "；") + "）"
+}
+
+// InterruptedContent 被打断回复写入历史的内容：已播出的部分加上打断标记
+func InterruptedContent(played string) string {
+	if played == "" {
+		return "（回复尚未播出即被用户打断）"
+	}
+	return played + "……（说到这里被用户打断）"
+}
+
+// ServerInterruptedContent 被服务端打断（如推送播报）的回复写入历史的内容
+func ServerInterruptedContent(played string) string {
+	if played == "" {
+		return "（回复尚未播出即被服务端打断）"
+	}
+	return played + "……（说到这里被服务端打断）"
+}
+
+// GetLLMDialogue 获取完整对话历史的副本
 func (dm *DialogueManager) GetLLMDialogue() []Message {
-	return dm.dialogue
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	return append([]Message(nil), dm.dialogue...)
 }
 
 // GetLLMDialogueWithMemory 获取带记忆的对话
@@ -63,6 +155,8 @@ func (dm *DialogueManager) GetLLMDialogueWithMemory(memoryStr string) []Message
 		Content: memoryStr,
 	}
 
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 	dialogue := make([]Message, 0, len(dm.dialogue)+1)
 	dialogue = append(dialogue, memoryMsg)
 	dialogue = append(dialogue, dm.dialogue...)
@@ -70,13 +164,30 @@ func (dm *DialogueManager) GetLLMDialogueWithMemory(memoryStr string) []Message
 	return dialogue
 }
 
+// ClearKeepSystem 清空对话历史但保留开头的系统消息，返回清除的消息数
+func (dm *DialogueManager) ClearKeepSystem() int {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	kept := make([]Message, 0, 1)
+	if len(dm.dialogue) > 0 && dm.dialogue[0].Role == "system" {
+		kept = append(kept, dm.dialogue[0])
+	}
+	removed := len(dm.dialogue) - len(kept)
+	dm.dialogue = kept
+	return removed
+}
+
 // Clear 清空对话历史
 func (dm *DialogueManager) Clear() {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 	dm.dialogue = make([]Message, 0)
 }
 
 // ToJSON 将对话历史转换为JSON字符串
 func (dm *DialogueManager) ToJSON() (string, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 	bytes, err := json.Marshal(dm.dialogue)
 	if err != nil {
 		return "", err
@@ -86,5 +197,7 @@ func (dm *DialogueManager) ToJSON() (string, error) {
 
 // LoadFromJSON 从JSON字符串加载对话历史
 func (dm *DialogueManager) LoadFromJSON(jsonStr string) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
 	return json.Unmarshal([]byte(jsonStr), &dm.dialogue)
 }
@@ -0,0 +1,128 @@
+package chat
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ResponseCache LLM 回复缓存，所有连接共享
+// 相同的系统提示词、最近历史与问题命中时直接复用上次的回复分段，跳过 LLM 请求；
+// 超过 maxEntries 时淘汰最久未使用的条目
+type ResponseCache struct {
+	mu           sync.Mutex
+	ttl          time.Duration
+	maxEntries   int
+	historyTurns int
+	entries      map[string]*list.Element
+	lru          *list.List
+}
+
+type responseCacheEntry struct {
+	key       string
+	segments  []string
+	expiresAt time.Time
+}
+
+// NewResponseCache 创建回复缓存，historyTurns 为参与计算 key 的最近对话轮数，0 表示只看系统提示词和问题
+func NewResponseCache(ttl time.Duration, maxEntries, historyTurns int) *ResponseCache {
+	return &ResponseCache{
+		ttl:          ttl,
+		maxEntries:   maxEntries,
+		historyTurns: historyTurns,
+		entries:      make(map[string]*list.Element),
+		lru:          list.New(),
+	}
+}
+
+// Key 计算缓存 key，最后一条消息不是用户提问时返回空字符串（如工具调用结果后的续写）
+// scope 用于区分不同的 LLM 配置
+func (c *ResponseCache) Key(scope string, messages []Message) string {
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		return ""
+	}
+	question := normalizeCacheText(messages[len(messages)-1].Content)
+	if question == "" {
+		return ""
+	}
+
+	var systems, history []string
+	for _, msg := range messages[:len(messages)-1] {
+		switch msg.Role {
+		case "system":
+			systems = append(systems, msg.Content)
+		case "user", "assistant":
+			if msg.Content != "" {
+				history = append(history, msg.Role+":"+normalizeCacheText(msg.Content))
+			}
+		}
+	}
+	if limit := c.historyTurns * 2; len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+
+	h := sha256.New()
+	for _, part := range []string{scope, strings.Join(systems, "\n"), strings.Join(history, "\n"), question} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get 查询缓存的回复分段
+func (c *ResponseCache) Get(key string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*responseCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.segments, true
+}
+
+// Put 写入回复分段
+func (c *ResponseCache) Put(key string, segments []string) {
+	if key == "" || len(segments) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*responseCacheEntry)
+		entry.segments, entry.expiresAt = segments, expiresAt
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&responseCacheEntry{key: key, segments: segments, expiresAt: expiresAt})
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*responseCacheEntry).key)
+	}
+}
+
+// normalizeCacheText 归一化文本：去掉空白与标点并转为小写，使“今天天气怎么样？”与“今天天气怎么样”命中同一条缓存
+func normalizeCacheText(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
@@ -0,0 +1,251 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"xiaozhi-server-go/src/auth"
+)
+
+// roleCompanion 伴随连接的握手角色：同一设备的手机 App 建立的第二条连接，只收发文本、图片与状态
+const roleCompanion = "companion"
+
+// companionMirroredTypes 设备连接下发的消息中同步给伴随连接的类型
+var companionMirroredTypes = map[string]bool{
+	"stt":        true,
+	"tts":        true,
+	"llm":        true,
+	"progress":   true,
+	"caption":    true,
+	"transcript": true,
+}
+
+// companionAllowedTypes 伴随连接可发送的消息类型，转交设备连接按原流程处理
+// listen 只接受 state=detect，用于打字对话与推送图片
+var companionAllowedTypes = map[string]bool{
+	"listen": true,
+	"image":  true,
+	"abort":  true,
+}
+
+// companionConn 一条伴随连接，设备连接的多个协程都会向它写消息
+type companionConn struct {
+	conn Conn
+	mu   sync.Mutex
+}
+
+func (c *companionConn) write(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteMessage(1, data)
+}
+
+// companionHub 按设备ID管理伴随连接
+type companionHub struct {
+	mu    sync.Mutex
+	conns map[string]map[*companionConn]struct{}
+}
+
+func newCompanionHub() *companionHub {
+	return &companionHub{conns: make(map[string]map[*companionConn]struct{})}
+}
+
+func (hub *companionHub) add(deviceID string, c *companionConn) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.conns[deviceID] == nil {
+		hub.conns[deviceID] = make(map[*companionConn]struct{})
+	}
+	hub.conns[deviceID][c] = struct{}{}
+}
+
+func (hub *companionHub) remove(deviceID string, c *companionConn) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	delete(hub.conns[deviceID], c)
+	if len(hub.conns[deviceID]) == 0 {
+		delete(hub.conns, deviceID)
+	}
+}
+
+// list 返回设备当前的伴随连接
+func (hub *companionHub) list(deviceID string) []*companionConn {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	list := make([]*companionConn, 0, len(hub.conns[deviceID]))
+	for c := range hub.conns[deviceID] {
+		list = append(list, c)
+	}
+	return list
+}
+
+// broadcast 向设备的所有伴随连接发送消息，写失败的连接由其读协程退出时移除
+func (hub *companionHub) broadcast(deviceID string, data []byte) {
+	for _, c := range hub.list(deviceID) {
+		c.write(data)
+	}
+}
+
+// broadcastStatus 通知伴随连接设备上线或离线
+func (hub *companionHub) broadcastStatus(deviceID string, online bool) {
+	hub.broadcast(deviceID, companionStatusMessage(deviceID, online))
+}
+
+// closeAll 服务关闭时断开所有伴随连接
+func (hub *companionHub) closeAll() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for _, conns := range hub.conns {
+		for c := range conns {
+			c.conn.Close()
+		}
+	}
+}
+
+func companionStatusMessage(deviceID string, online bool) []byte {
+	state := "offline"
+	if online {
+		state = "online"
+	}
+	data, _ := json.Marshal(map[string]interface{}{"type": "companion", "state": state, "device_id": deviceID})
+	return data
+}
+
+func companionErrorMessage(message string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{"type": "companion", "state": "error", "message": message})
+	return data
+}
+
+// mirrorConn 包装设备连接，下发的字幕、识别结果与播报状态同时发给伴随连接，音频不同步
+type mirrorConn struct {
+	Conn
+	deviceID string
+	hub      *companionHub
+}
+
+func (c *mirrorConn) WriteMessage(messageType int, data []byte) error {
+	err := c.Conn.WriteMessage(messageType, data)
+	if messageType == 1 {
+		var msg struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(data, &msg) == nil && companionMirroredTypes[msg.Type] {
+			c.hub.broadcast(c.deviceID, data)
+		}
+	}
+	return err
+}
+
+// enqueueCompanionText 把伴随连接的消息交给设备连接的文本队列处理，连接已关闭或队列已满时返回 false
+func (h *ConnectionHandler) enqueueCompanionText(text string) (ok bool) {
+	// 连接关闭时文本队列会被关闭，与关闭并发时向已关闭的通道发送会 panic
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	select {
+	case <-h.stopChan:
+		return false
+	default:
+	}
+	select {
+	case h.clientTextQueue <- text:
+		return true
+	default:
+		return false
+	}
+}
+
+// isCompanionRequest 判断握手请求是否为伴随连接
+func isCompanionRequest(r *http.Request) bool {
+	return r.URL.Query().Get("role") == roleCompanion || r.Header.Get("Role") == roleCompanion
+}
+
+// handleCompanion 处理伴随连接：握手时鉴权，之后把允许的消息转交设备当前的连接
+func (ws *WebSocketServer) handleCompanion(w http.ResponseWriter, r *http.Request) {
+	cfg := ws.config.Companion
+	if !cfg.Enabled {
+		http.Error(w, "未启用伴随连接", http.StatusForbidden)
+		return
+	}
+	deviceID := r.Header.Get("Device-Id")
+	if deviceID == "" {
+		deviceID = r.URL.Query().Get("device-id")
+	}
+	if deviceID == "" {
+		http.Error(w, "缺少设备ID", http.StatusBadRequest)
+		return
+	}
+	subject, err := ws.authenticateCompanion(r)
+	if err != nil {
+		ws.logger.Warn(fmt.Sprintf("伴随连接鉴权失败 (%s): %v", ws.realIP.ClientIP(r), err))
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := ws.upgrader.Upgrade(w, r)
+	if err != nil {
+		ws.logger.Error(fmt.Sprintf("伴随连接WebSocket升级失败: %v", err))
+		return
+	}
+	defer conn.Close()
+
+	companion := &companionConn{conn: conn}
+	ws.companions.add(deviceID, companion)
+	defer ws.companions.remove(deviceID, companion)
+	ws.logger.Info(fmt.Sprintf("设备 %s 的伴随连接已建立，调用方: %s", deviceID, subject))
+	companion.write(companionStatusMessage(deviceID, ws.findHandler(deviceID) != nil))
+
+	for {
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			ws.logger.Info(fmt.Sprintf("设备 %s 的伴随连接已断开: %v", deviceID, err))
+			return
+		}
+		if messageType != 1 {
+			companion.write(companionErrorMessage("伴随连接不支持音频"))
+			continue
+		}
+		var msg struct {
+			Type  string `json:"type"`
+			State string `json:"state"`
+		}
+		if err := json.Unmarshal(message, &msg); err != nil {
+			companion.write(companionErrorMessage("消息格式错误"))
+			continue
+		}
+		if !companionAllowedTypes[msg.Type] || (msg.Type == "listen" && msg.State != "detect") {
+			companion.write(companionErrorMessage(fmt.Sprintf("伴随连接不支持该消息: %s", msg.Type)))
+			continue
+		}
+		handler := ws.findHandler(deviceID)
+		if handler == nil {
+			companion.write(companionStatusMessage(deviceID, false))
+			continue
+		}
+		if !handler.enqueueCompanionText(string(message)) {
+			companion.write(companionErrorMessage("设备繁忙，请稍后再试"))
+		}
+	}
+}
+
+// authenticateCompanion 按 companion.auth_mode 校验 App 的凭证，浏览器无法设置握手头时可用 token 查询参数
+func (ws *WebSocketServer) authenticateCompanion(r *http.Request) (string, error) {
+	if ws.authenticator == nil || !ws.authenticator.Enabled() {
+		return "anonymous", nil
+	}
+	mode := ws.config.Companion.AuthMode
+	if mode == "" {
+		mode = auth.ModeAny
+	}
+	if mode == auth.ModeNone {
+		return "anonymous", nil
+	}
+	if token := r.URL.Query().Get("token"); token != "" && r.Header.Get("Authorization") == "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return ws.authenticator.AuthenticateRequest(r, mode)
+}
@@ -7,11 +7,14 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
+	"xiaozhi-server-go/src/calendar"
 	"xiaozhi-server-go/src/configs"
 	"xiaozhi-server-go/src/core/chat"
 	"xiaozhi-server-go/src/core/function"
@@ -20,31 +23,80 @@ import (
 	"xiaozhi-server-go/src/core/pool"
 	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/vlllm"
+	"xiaozhi-server-go/src/core/punctuation"
 	"xiaozhi-server-go/src/core/types"
 	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/metrics"
 	"xiaozhi-server-go/src/task"
+	"xiaozhi-server-go/src/transcript"
 
 	"github.com/google/uuid"
 )
 
+// ttsFallbackCooldown 主TTS失败后改用备用TTS的时长，到期后重新尝试主TTS
+const ttsFallbackCooldown = time.Minute
+
+// maxTTSTextLen 单次TTS合成的最大字节数，超过时拆分为多段
+const maxTTSTextLen = 255
+
+// duplicateSimilarity 同一轮次内与上一分段的相似度达到该值时视为重复，不再播报
+const duplicateSimilarity = 0.92
+
+// prewarmTimeout 连接预热的最长耗时
+const prewarmTimeout = 10 * time.Second
+
+// maxToolRetries 同一轮对话中工具调用失败（含参数校验不通过）后最多让 LLM 修正重试的次数
+const maxToolRetries = 2
+
+// errDuplicateSegment 分段与上一分段重复，已丢弃
+var errDuplicateSegment = errors.New("分段与上一分段重复，已丢弃")
+
 // ConnectionHandler 连接处理器结构
 type ConnectionHandler struct {
 	// 确保实现 AsrEventListener 接口
-	_         providers.AsrEventListener
-	config    *configs.Config
-	logger    *utils.Logger
-	conn      Conn
-	closeOnce sync.Once
-	taskMgr   *task.TaskManager
-	providers struct {
+	_            providers.AsrEventListener
+	config       *configs.Config
+	logger       *utils.Logger
+	conn         Conn
+	closeOnce    sync.Once
+	taskMgr      *task.TaskManager
+	reminders    *reminderScheduler    // 服务端共享的提醒调度，为 nil 时不支持提醒
+	greetings    *greetingCache        // 服务端共享的欢迎语音频缓存，未启用欢迎语时为 nil
+	greetOnce    sync.Once             // hello 可能重复发送，欢迎语只播一次
+	quickReplies *greetingCache        // 服务端共享的快速回复音频缓存，未启用快速回复时为 nil
+	careLimiter  *careLimiter          // 服务端共享的主动关怀频控，为 nil 时不主动关怀
+	holidays     *calendar.HolidayBook // 服务端共享的节假日查询
+	providers    struct {
 		asr   providers.ASRProvider
 		llm   providers.LLMProvider
 		tts   providers.TTSProvider
 		vlllm *vlllm.Provider // VLLLM提供者，可选
+
+		ttsFallbacks []pool.TTSFallback // 备用TTS，主TTS失败时按顺序尝试
 	}
+	ttsDegradedUntil time.Time // 主TTS失败后的冷却截止时间，期间直接使用备用TTS
+	ttsVoice         string    // 本连接切换后的音色，为空表示使用配置的音色
+	roleName         string    // 当前角色，为空表示使用默认提示词
+
+	locOnce sync.Once
+	loc     *time.Location // 设备所在时区，首次使用时按配置解析
+
+	translation *translationMode // 翻译模式状态，为 nil 表示正常对话
+	asrLanguage string           // 本轮识别结果的语种，ASR 未检测语种时为空
+
+	transcribeMu        sync.Mutex
+	transcription       *transcription                      // 进行中的会议转写，为 nil 表示正常对话
+	summarizeTranscript func(record *transcript.Transcript) // 服务端生成转写摘要，为 nil 时不生成
+
+	prewarmQuickReplies func(voice string, words []string) // 服务端在后台预合成快速回复，为 nil 时不预合成
 
 	// 会话相关
-	sessionID string
+	sessionID      string
+	historyMu      sync.Mutex
+	historyStarted bool   // 会话记录已创建，首条消息时创建
+	historyReplyID uint   // 本轮回复在会话记录中的消息ID，被打断时据此截断
+	clientIP       string // 真实客户端IP（已按可信代理解析）
+	deviceID       string // 设备ID，取自握手请求头 Device-Id
 	// 客户端音频相关
 	clientAudioFormat        string
 	clientAudioSampleRate    int
@@ -55,32 +107,45 @@ type ConnectionHandler struct {
 	serverAudioSampleRate    int
 	serverAudioChannels      int
 	serverAudioFrameDuration int
+	preBufferFrames          int           // 每句开头不等待直接发送的帧数
+	sendAhead                time.Duration // 发送时间领先实时播放位置的量
+	playbackNegotiated       bool          // 客户端在 hello 中协商过下发节奏
+	opusAdaptor              *opusAdaptor  // 自适应 Opus 码率，未启用时为 nil
 
 	clientListenMode string
 	isDeviceVerified bool
-	closeAfterChat   bool
+	caps             clientCapabilities // hello 协商的协议版本与能力
+	protocolEpoch    time.Time          // 下行二进制帧时间戳的起点
+	connectedAt      time.Time          // 连接建立时间
+	lastClientStamp  atomic.Int64       // 最近一帧上行音频的时间戳（毫秒），v2 协议携带，-1 表示未收到
+	lastClientRecv   atomic.Int64       // 收到该帧的本地时间（纳秒）
 
 	// 语音处理相关
-	clientVoiceStop bool  // true客户端语音停止, 不再上传语音数据
-	serverVoiceStop int32 // 1表示true服务端语音停止, 不再下发语音数据
+	voice         *VoiceStateMachine // 讲话状态：拾音、播报、打断、告别
+	closeConnOnce sync.Once
 
 	opusDecoder *utils.OpusDecoder // Opus解码器
 
 	// 对话相关
 	dialogueManager     *chat.DialogueManager
-	tts_last_text_index int
-	client_asr_text     string // 客户端ASR文本
+	tts_last_text_index atomic.Int64 // 本轮最后一个分段的序号，该分段播完后发送 tts stop；LLM、播报与发送协程都会读写
+	client_asr_text     string       // 客户端ASR文本
+
+	lastAsrResult *providers.AsrResult // 最近一次结构化ASR结果（分句、起止时间、置信度）
+	asrResultMu   sync.Mutex
 
 	// 并发控制
 	stopChan         chan struct{}
 	clientAudioQueue chan []byte
 	clientTextQueue  chan string
+	pushQueue        chan *pushTask // 推送播报交给文本处理协程执行，与对话串行开始新一轮
 
 	// TTS任务队列
 	ttsQueue chan struct {
 		text      string
 		round     int // 轮次
 		textIndex int
+		partial   bool // 长文本拆分后的非末段，播完不结束本句
 	}
 
 	audioMessagesQueue chan struct {
@@ -88,10 +153,45 @@ type ConnectionHandler struct {
 		text      string
 		round     int // 轮次
 		textIndex int
+		partial   bool
+		stream    *ttsStream // 流式合成的音频，非空时 filepath 为空
 	}
 
-	talkRound      int       // 轮次计数
-	roundStartTime time.Time // 轮次开始时间
+	talkRound      atomic.Int64 // 轮次计数，对话、主动播报与推送都会开始新的一轮，需跨协程读写
+	roundStartTime atomic.Int64 // 轮次开始时间（UnixNano）
+	toolRetryRound int          // toolRetries 所属的轮次
+	toolRetries    int          // 本轮工具调用失败的次数
+
+	lastVoiceTime  atomic.Int64 // 最近一次语音活动时间（UnixNano），用于静音检测
+	silenceRounds  atomic.Int32 // 连续静音次数，识别到语音时清零
+	lastActiveTime atomic.Int64 // 最近一次交互时间（UnixNano），用于会话空闲超时
+	dialogueRounds atomic.Int64 // 用户发起的对话轮次，供设备管理接口跨协程读取
+	listenStopAt   atomic.Int64 // 客户端停止拾音的时间（UnixNano），用于统计识别耗时，0 表示未停止
+
+	usageMu  sync.Mutex
+	llmUsage types.Usage // 本连接累计的 LLM token 用量
+
+	spokenMu          sync.Mutex // 对话与主动播报可能在不同协程中同时入队分段
+	lastSpokenText    string     // 上一个入队播报的分段，用于重复抑制
+	lastSpokenRound   int
+	duplicateSegments int // 本连接被抑制的重复分段数
+
+	playedMu       sync.Mutex
+	playedRound    int      // playedSegments 所属的轮次
+	playedSegments []string // 本轮已开始播放的分段，被打断时据此截断写入历史
+	historyRound   int      // 最近一次写入历史的助手回复所属轮次，已按打断截断后清零
+	historyPrefix  string   // 分批播报时该条助手回复中此前各批的内容，按打断截断时保留
+
+	pagingMu     sync.Mutex
+	pendingPages []string // 分批播报暂停后尚未播报的内容
+
+	clarifyMu       sync.Mutex
+	pendingQuestion string      // ask_user 追问后等待回答的问题
+	clarifyTimer    *time.Timer // 追问超时计时
+
+	responseCache *chat.ResponseCache    // LLM 回复缓存，由服务端共享，未启用时为 nil
+	audioCache    *utils.AudioFrameCache // 音频帧缓存，由服务端共享，未启用时为 nil
+	punctuation   punctuation.Restorer   // ASR 结果标点恢复，由服务端共享，未启用时为 nil
 	// functions
 	functionRegister *function.FunctionRegistry
 	mcpManager       *mcp.Manager
@@ -106,30 +206,32 @@ func NewConnectionHandler(
 	handler := &ConnectionHandler{
 		config:           config,
 		logger:           logger,
+		sessionID:        uuid.New().String(),
 		clientListenMode: "auto",
+		protocolEpoch:    time.Now(),
+		connectedAt:      time.Now(),
 		stopChan:         make(chan struct{}),
 		clientAudioQueue: make(chan []byte, 100),
 		clientTextQueue:  make(chan string, 100),
+		pushQueue:        make(chan *pushTask, pushQueueSize),
 		ttsQueue: make(chan struct {
 			text      string
 			round     int // 轮次
 			textIndex int
+			partial   bool // 长文本拆分后的非末段，播完不结束本句
 		}, 100),
 		audioMessagesQueue: make(chan struct {
 			filepath  string
 			text      string
 			round     int // 轮次
 			textIndex int
+			partial   bool
+			stream    *ttsStream // 流式合成的音频，非空时 filepath 为空
 		}, 100),
 
-		tts_last_text_index: -1,
-
-		talkRound: 0,
-
-		serverAudioFormat:        "opus", // 默认使用Opus格式
-		serverAudioSampleRate:    24000,
-		serverAudioChannels:      1,
-		serverAudioFrameDuration: 60,
+		serverAudioFormat:     "opus", // 默认使用Opus格式
+		serverAudioSampleRate: 24000,
+		serverAudioChannels:   1,
 	}
 
 	// 正确设置providers
@@ -137,14 +239,22 @@ func NewConnectionHandler(
 		handler.providers.asr = providerSet.ASR
 		handler.providers.llm = providerSet.LLM
 		handler.providers.tts = providerSet.TTS
+		handler.providers.ttsFallbacks = providerSet.TTSFallbacks
 		handler.providers.vlllm = providerSet.VLLLM
 		handler.mcpManager = providerSet.MCP
 	}
 
+	handler.initPlayback()
+	handler.caps.version.Store(minProtocolVersion)
+	handler.lastClientStamp.Store(-1)
+	handler.tts_last_text_index.Store(-1)
+	handler.voice = NewVoiceStateMachine(handler.onVoiceTransition)
+
 	// 初始化对话管理器
 	handler.dialogueManager = chat.NewDialogueManager(handler.logger, nil)
 	handler.dialogueManager.SetSystemMessage(config.DefaultPrompt)
 	handler.functionRegister = function.NewFunctionRegistry()
+	handler.registerLocalFunctions()
 
 	return handler
 }
@@ -166,6 +276,12 @@ func (h *ConnectionHandler) Handle(conn Conn) {
 	go h.processClientTextMessagesCoroutine()  // 添加客户端文本消息处理协程
 	go h.processTTSQueueCoroutine()            // 添加TTS队列处理协程
 	go h.sendAudioMessageCoroutine()           // 添加音频消息发送协程
+	go h.silenceWatchCoroutine()               // 添加静音检测协程
+	go h.idleWatchCoroutine()                  // 添加会话空闲检测协程
+	go h.careWatchCoroutine()                  // 添加主动关怀协程
+	if h.config.Server.Prewarm {
+		go h.prewarmProviders() // 与 MCP 绑定并行预热首轮要用到的连接
+	}
 
 	// 优化后的MCP管理器处理
 	if h.mcpManager == nil {
@@ -175,6 +291,8 @@ func (h *ConnectionHandler) Handle(conn Conn) {
 		if err := h.mcpManager.InitializeServers(context.Background()); err != nil {
 			h.logger.Error(fmt.Sprintf("初始化MCP服务器失败: %v", err))
 		}
+	} else if !h.caps.has(FeatureMCP) {
+		h.logger.Info("客户端未启用 mcp 能力，跳过绑定设备端 MCP")
 	} else {
 		h.logger.Info("使用从资源池获取的MCP管理器，快速绑定连接")
 		// 池化的管理器已经预初始化，只需要绑定连接
@@ -186,6 +304,15 @@ func (h *ConnectionHandler) Handle(conn Conn) {
 		h.logger.Info("MCP管理器连接绑定完成，跳过重复初始化")
 	}
 
+	h.applyDeviceVoice()
+	h.applyDevicePrompt()
+	h.applyDeviceProfile()
+	h.restoreHistory()
+	h.warmQuickReplies()
+	if h.reminders != nil && h.deviceID != "" {
+		time.AfterFunc(reminderDeliverDelay, func() { h.reminders.deliverDue(h) })
+	}
+
 	// 主消息循环
 	for {
 		select {
@@ -200,7 +327,7 @@ func (h *ConnectionHandler) Handle(conn Conn) {
 
 			if err := h.handleMessage(messageType, message); err != nil {
 				h.logger.Error(fmt.Sprintf("处理消息失败: %v", err))
-				if h.closeAfterChat {
+				if h.voice.State() == VoiceClosing {
 					return
 				}
 			}
@@ -208,6 +335,32 @@ func (h *ConnectionHandler) Handle(conn Conn) {
 	}
 }
 
+// prewarmProviders 并行预热 ASR 与 LLM 的网络连接，失败只记录日志，首轮请求时按原流程建立连接
+func (h *ConnectionHandler) prewarmProviders() {
+	ctx, cancel := context.WithTimeout(context.Background(), prewarmTimeout)
+	defer cancel()
+
+	targets := map[string]interface{}{"ASR": h.providers.asr, "LLM": h.providers.llm}
+	var wg sync.WaitGroup
+	for name, provider := range targets {
+		warmer, ok := provider.(providers.Prewarmer)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(name string, warmer providers.Prewarmer) {
+			defer wg.Done()
+			start := time.Now()
+			if err := warmer.Prewarm(ctx); err != nil {
+				h.logger.Warn(fmt.Sprintf("%s 预热失败: %v", name, err))
+				return
+			}
+			h.logger.Info(fmt.Sprintf("%s 预热完成，耗时 %v", name, time.Since(start)))
+		}(name, warmer)
+	}
+	wg.Wait()
+}
+
 // processClientTextMessagesCoroutine 处理文本消息队列
 func (h *ConnectionHandler) processClientTextMessagesCoroutine() {
 	for {
@@ -215,9 +368,12 @@ func (h *ConnectionHandler) processClientTextMessagesCoroutine() {
 		case <-h.stopChan:
 			return
 		case text := <-h.clientTextQueue:
+			h.touchActiveTime()
 			if err := h.processClientTextMessage(context.Background(), text); err != nil {
 				h.logger.Error(fmt.Sprintf("处理文本数据失败: %v", err))
 			}
+		case task := <-h.pushQueue:
+			h.runPushTask(task)
 		}
 	}
 }
@@ -242,7 +398,7 @@ func (h *ConnectionHandler) sendAudioMessageCoroutine() {
 		case <-h.stopChan:
 			return
 		case task := <-h.audioMessagesQueue:
-			h.sendAudioMessage(task.filepath, task.text, task.textIndex, task.round)
+			h.sendAudioMessage(task.filepath, task.text, task.textIndex, task.round, task.partial, task.stream)
 		}
 	}
 }
@@ -250,7 +406,21 @@ func (h *ConnectionHandler) sendAudioMessageCoroutine() {
 // OnAsrResult 实现 AsrEventListener 接口
 // 返回true则停止语音识别，返回false会继续语音识别
 func (h *ConnectionHandler) OnAsrResult(result string) bool {
+	// 本地 ASR 的结果没有标点，先补全再交给 LLM 与字幕
+	if h.punctuation != nil && result != "" {
+		result = h.restorePunctuation(result)
+	}
+	if result != "" {
+		h.resetSilence()
+	}
+	// 会议转写中只记录，不进入对话
+	if h.transcribing() {
+		return h.onTranscriptResult(result)
+	}
 	//h.logger.Info(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
+	if h.clientListenMode != "manual" || !h.voice.Listening() {
+		h.recordAsrResult(result)
+	}
 	if h.clientListenMode == "auto" {
 		if result == "" {
 			return false
@@ -263,11 +433,22 @@ func (h *ConnectionHandler) OnAsrResult(result string) bool {
 		if result != "" {
 			h.logger.Info(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, h.client_asr_text))
 		}
-		if h.clientVoiceStop {
+		if !h.voice.Listening() {
 			h.handleChatMessage(context.Background(), h.client_asr_text)
 			return true
 		}
 		return false
+	} else if h.clientListenMode == listenModeDictation {
+		// 听写只把识别结果回传客户端，不进入对话
+		if result == "" {
+			return false
+		}
+		h.logger.Info(fmt.Sprintf("[%s] ASR识别结果: %s", h.clientListenMode, result))
+		if err := h.sendSTTMessage(result); err != nil {
+			h.logger.Error(fmt.Sprintf("发送听写结果失败: %v", err))
+		}
+		h.providers.asr.Reset() // 重置ASR状态，继续识别下一句
+		return true
 	} else if h.clientListenMode == "realtime" {
 		if result == "" {
 			return false
@@ -281,6 +462,92 @@ func (h *ConnectionHandler) OnAsrResult(result string) bool {
 	return false
 }
 
+// recordAsrResult 统计ASR最终结果，manual 模式在松开按键后统计一次
+func (h *ConnectionHandler) recordAsrResult(result string) {
+	if h.clientListenMode == "manual" {
+		result = h.client_asr_text + result
+	}
+	asrType := h.providerType("ASR", h.config.SelectedModule["ASR"])
+	metrics.ASRResults.WithLabelValues(asrType, strconv.FormatBool(result == "")).Inc()
+	if stop := h.listenStopAt.Swap(0); stop > 0 {
+		metrics.ObserveProvider(metrics.StageASR, asrType, time.Since(time.Unix(0, stop)), nil)
+	}
+}
+
+// providerType 返回已配置 provider 的 type，用作监控标签
+func (h *ConnectionHandler) providerType(module, name string) string {
+	switch module {
+	case "ASR":
+		if t, ok := h.config.ASR[name]["type"].(string); ok {
+			return t
+		}
+	case "TTS":
+		if cfg, ok := h.config.TTS[name]; ok {
+			return cfg.Type
+		}
+	case "LLM":
+		if cfg, ok := h.config.LLM[name]; ok {
+			return cfg.Type
+		}
+	}
+	return name
+}
+
+// OnAsrDetail 结构化ASR结果回调，在 OnAsrResult 之前触发
+func (h *ConnectionHandler) OnAsrDetail(result *providers.AsrResult) {
+	h.asrResultMu.Lock()
+	h.lastAsrResult = result
+	h.asrResultMu.Unlock()
+
+	h.logger.Debug(fmt.Sprintf("ASR结构化结果: 分句%d个, 置信度%.2f, 时长%dms",
+		len(result.Utterances), result.Confidence, result.Duration))
+}
+
+// takeAsrResult 取出与 text 对应的结构化结果，取出后清空，避免串到下一轮
+func (h *ConnectionHandler) takeAsrResult(text string) *providers.AsrResult {
+	h.asrResultMu.Lock()
+	defer h.asrResultMu.Unlock()
+	result := h.lastAsrResult
+	h.lastAsrResult = nil
+	if result == nil || result.Text != text {
+		return nil
+	}
+	return result
+}
+
+// restorePunctuation 补全识别文本的标点，并同步更新结构化结果的文本，
+// 否则字幕与会议转写按补全后的文本取结构化结果时匹配不上，分句与时间戳随之丢失
+func (h *ConnectionHandler) restorePunctuation(raw string) string {
+	restored := h.punctuation.Restore(raw)
+	h.asrResultMu.Lock()
+	if h.lastAsrResult != nil && h.lastAsrResult.Text == raw {
+		h.lastAsrResult.Text = restored
+	}
+	h.asrResultMu.Unlock()
+	return restored
+}
+
+// OnAsrInterim 流式ASR中间结果回调，realtime模式下用户一开口即打断服务端播报
+func (h *ConnectionHandler) OnAsrInterim(text string) {
+	if text == "" {
+		return
+	}
+	if h.caps.has(FeaturePartialSTT) {
+		if err := h.sendPartialSTTMessage(text); err != nil {
+			h.logger.Error(fmt.Sprintf("发送STT中间结果失败: %v", err))
+		}
+	}
+	// 识别定稿后才开始新一轮，中间结果属于下一轮
+	h.sendCaption(captionRoleUser, h.currentRound()+1, 0, text, false)
+	if h.clientListenMode != "realtime" {
+		return
+	}
+	if h.voice.State() == VoiceSpeaking {
+		h.logger.Info(fmt.Sprintf("[%s] ASR中间结果: %s，打断服务端播报", h.clientListenMode, text))
+		h.stopServerSpeak()
+	}
+}
+
 // clientAbortChat 处理中止消息
 func (h *ConnectionHandler) clientAbortChat() error {
 	h.logger.Info("收到客户端中止消息，停止语音识别")
@@ -297,11 +564,17 @@ func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string)
 		h.clientAbortChat()
 		return nil
 	}
+	h.resetSilence()
+
+	// 分批播报暂停中，肯定回答直接播报下一批
+	if h.continuePaging(text) {
+		return nil
+	}
 
 	// 增加对话轮次
-	h.talkRound++
-	h.roundStartTime = time.Now()
-	currentRound := h.talkRound
+	currentRound := h.startRound()
+	h.dialogueRounds.Add(1)
+	metrics.DialogueRounds.WithLabelValues(h.providerType("LLM", h.config.SelectedModule["LLM"])).Inc()
 	h.logger.Info(fmt.Sprintf("开始新的对话轮次: %d", currentRound))
 
 	// 判断是否需要验证
@@ -352,7 +625,7 @@ func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string)
 		})
 
 		// 重置语音状态，确保能够播放提示音
-		atomic.StoreInt32(&h.serverVoiceStop, 0)
+		h.voice.Fire(EventSpeakStart)
 
 		// 立即合成并播放提示音（使用索引0确保优先播放）
 		if err := h.SpeakAndPlay(immediateResponse, 0, currentRound); err != nil {
@@ -371,6 +644,7 @@ func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string)
 			Role:    "user",
 			Content: userMessage,
 		})
+		h.recordHistory("user", userMessage)
 
 		// 获取对话历史（排除当前图片消息）
 		messages := make([]providers.Message, 0)
@@ -410,18 +684,26 @@ func (h *ConnectionHandler) handleChatMessage(ctx context.Context, text string)
 
 	h.logger.Info("收到聊天消息: " + text)
 
-	// 添加用户消息到对话历史
+	// 翻译模式下不进入对话，直接翻译后播报
+	if h.translation != nil {
+		return h.translate(ctx, text, currentRound)
+	}
+
+	// 添加用户消息到对话历史，是对追问的回答时附带说明
 	h.dialogueManager.Put(chat.Message{
 		Role:    "user",
-		Content: text,
+		Content: h.withClarification(text),
 	})
+	h.recordHistory("user", text)
 
 	// 转换消息格式并使用LLM生成回复
 	messages := make([]providers.Message, 0)
 	for _, msg := range h.dialogueManager.GetLLMDialogue() {
 		messages = append(messages, providers.Message{
-			Role:    msg.Role,
-			Content: msg.Content,
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
 		})
 	}
 
@@ -434,30 +716,64 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 	for _, msg := range messages {
 		msg.Print()
 	}
+
+	// 相同的问题直接播报缓存的回复
+	cacheKey := ""
+	if h.responseCache != nil {
+		cacheKey = h.responseCache.Key(h.config.SelectedModule["LLM"], messages)
+		if segments, ok := h.responseCache.Get(cacheKey); ok {
+			return h.replayCachedResponse(segments, round)
+		}
+	}
+
 	// 使用LLM生成回复
 	tools := h.functionRegister.GetAllFunctions()
+	llmType := h.providerType("LLM", h.config.SelectedModule["LLM"])
+	requestStart := time.Now()
 	responses, err := h.providers.llm.ResponseWithFunctions(ctx, h.sessionID, messages, tools)
 	if err != nil {
+		metrics.ObserveProvider(metrics.StageLLMFirstToken, llmType, time.Since(requestStart), err)
 		return fmt.Errorf("LLM生成回复失败: %v", err)
 	}
+	firstToken := true
 
 	// 处理回复
 	var responseMessage []string
 	processedChars := 0
 	textIndex := 0
 
-	atomic.StoreInt32(&h.serverVoiceStop, 0)
+	h.voice.Fire(EventSpeakStart)
 
 	// 处理流式响应
 	toolCallFlag := false
-	functionName := ""
-	functionID := ""
-	functionArguments := ""
+	toolCalls := function.NewToolCallAccumulator()
 	contentArguments := ""
+	var spokenSegments []string // 已播报的分段，用于写入回复缓存
+	llmFailed := false
+
+	// 分批播报：本批播报满 pageChars 字后在段落结尾暂停，剩余内容等用户确认后再播
+	pageChars := h.pageChars()
+	pageRunes := 0
+	paused := false
+	h.setPendingPages(nil)
 
 	for response := range responses {
+		if response.Usage != nil {
+			h.recordLLMUsage(response.Usage)
+			continue
+		}
+		if response.Error != "" {
+			llmFailed = true
+		}
+		if firstToken {
+			firstToken = false
+			var tokenErr error
+			if response.Error != "" {
+				tokenErr = errors.New(response.Error)
+			}
+			metrics.ObserveProvider(metrics.StageLLMFirstToken, llmType, time.Since(requestStart), tokenErr)
+		}
 		content := response.Content
-		toolCall := response.ToolCalls
 
 		if content != "" {
 			// 累加content_arguments
@@ -468,27 +784,38 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 			toolCallFlag = true
 		}
 
-		if len(toolCall) > 0 {
+		if len(response.ToolCalls) > 0 {
 			toolCallFlag = true
-			if toolCall[0].ID != "" {
-				functionID = toolCall[0].ID
-			}
-			if toolCall[0].Function.Name != "" {
-				functionName = toolCall[0].Function.Name
-			}
-			if toolCall[0].Function.Arguments != "" {
-				functionArguments += toolCall[0].Function.Arguments
-			}
+			toolCalls.Add(response.ToolCalls)
 		}
 
 		if content != "" {
 			if !toolCallFlag {
 				responseMessage = append(responseMessage, content)
 			}
+			if paused {
+				continue
+			}
 			// 处理分段
 			fullText := utils.JoinStrings(responseMessage)
 			currentText := fullText[processedChars:]
 
+			if pageChars > 0 && !toolCallFlag {
+				if idx := strings.Index(currentText, "\n"); idx >= 0 && pageRunes+utf8.RuneCountInString(currentText[:idx]) >= pageChars {
+					if segment := strings.TrimSpace(currentText[:idx]); segment != "" {
+						textIndex++
+						if err := h.SpeakAndPlay(segment, textIndex, round); err == nil {
+							h.tts_last_text_index.Store(int64(textIndex))
+						}
+						spokenSegments = append(spokenSegments, segment)
+					}
+					processedChars += idx + 1
+					paused = true
+					h.logger.Info(fmt.Sprintf("分批播报: 本批已播报 %d 字，在段落结尾暂停, round:%d", pageRunes, round))
+					continue
+				}
+			}
+
 			// 按标点符号分割
 			if segment, chars := utils.SplitAtLastPunctuation(currentText); chars > 0 {
 				textIndex++
@@ -502,87 +829,213 @@ func (h *ConnectionHandler) genResponseByLLM(ctx context.Context, messages []pro
 
 				err := h.SpeakAndPlay(segment, textIndex, round)
 				if err == nil {
-					h.tts_last_text_index = textIndex
-				} else {
+					h.tts_last_text_index.Store(int64(textIndex))
+				} else if !errors.Is(err, errDuplicateSegment) {
 					h.logger.Error(fmt.Sprintf("播放LLM回复分段失败: %v", err))
 				}
+				spokenSegments = append(spokenSegments, segment)
 				processedChars += chars
+				pageRunes += utf8.RuneCountInString(segment)
 			}
 		}
 	}
 
 	if toolCallFlag {
-		bHasError := false
-		if functionID == "" {
-			a := utils.Extract_json_from_string(contentArguments)
-			if a != nil {
-				functionName = a["name"].(string)
-				functionArguments = a["arguments"].(string)
-				functionID = uuid.New().String()
+		calls := toolCalls.Calls()
+		if len(calls) == 0 {
+			// 不支持原生工具调用的模型以 <tool_call>{...}</tool_call> 文本形式返回
+			if call, ok := parseTextToolCall(contentArguments); ok {
+				calls = append(calls, call)
 			} else {
-				bHasError = true
-			}
-			if bHasError {
-				h.logger.Error(fmt.Sprintf("函数调用参数解析失败: %v", err))
+				h.logger.Error(fmt.Sprintf("函数调用参数解析失败: %s", contentArguments))
 			}
 		}
-		if !bHasError {
+		if len(calls) > 0 {
 			// 清空responseMessage
 			responseMessage = []string{}
-			arguments := make(map[string]interface{})
-			if err := json.Unmarshal([]byte(functionArguments), &arguments); err != nil {
-				h.logger.Error(fmt.Sprintf("函数调用参数解析失败: %v", err))
-			}
-			functionCallData := map[string]interface{}{
-				"id":        functionID,
-				"name":      functionName,
-				"arguments": functionArguments,
-			}
-			h.logger.Info(fmt.Sprintf("函数调用: %v", arguments))
-			if h.mcpManager.IsMCPTool(functionName) {
-				// 处理MCP函数调用
-				result, err := h.mcpManager.ExecuteTool(ctx, functionName, arguments)
-				if err != nil {
-					h.logger.Error(fmt.Sprintf("MCP函数调用失败: %v", err))
-				}
-				h.logger.Info(fmt.Sprintf("MCP函数调用结果: %v", result))
-				actionResult := types.ActionResponse{
-					Action: types.ActionTypeReqLLM, // 动作类型
-					Result: result,                 // 动作产生的结果
-				}
-				h.handleFunctionResult(actionResult, functionCallData, textIndex)
-
-			} else {
-				// 处理普通函数调用
-				//h.functionRegister.CallFunction(functionName, functionCallData)
-			}
+			textIndex = h.handleToolCalls(ctx, calls, textIndex)
 		}
 	}
 
 	// 处理剩余文本
 	remainingText := utils.JoinStrings(responseMessage)[processedChars:]
-	if remainingText != "" {
+	if paused {
+		if pages := splitPages(remainingText, pageChars); len(pages) > 0 {
+			h.setPendingPages(pages)
+			textIndex++
+			if err := h.SpeakAndPlay(h.pagingPrompt(), textIndex, round); err == nil {
+				h.tts_last_text_index.Store(int64(textIndex))
+			}
+		}
+	} else if remainingText != "" {
 		textIndex++
 		h.logger.Info(fmt.Sprintf("LLM回复分段[剩余文本]: %s, index: %d, round:%d", remainingText, textIndex, round))
 		err := h.SpeakAndPlay(remainingText, textIndex, round)
 		if err == nil {
-			h.tts_last_text_index = textIndex
+			h.tts_last_text_index.Store(int64(textIndex))
 		}
+		spokenSegments = append(spokenSegments, remainingText)
+	}
+
+	// 只缓存完整的纯文本回复，调用工具、请求失败或被打断的回复不缓存
+	if cacheKey != "" && !toolCallFlag && !paused && !llmFailed && ctx.Err() == nil && !h.voice.Interrupted() {
+		h.responseCache.Put(cacheKey, spokenSegments)
 	}
 
 	// 分析回复并发送相应的情绪
 	content := utils.JoinStrings(responseMessage)
+	if paused && processedChars <= len(content) {
+		// 暂停后剩余的内容尚未播报，播报时再逐批追加到这条回复
+		content = strings.TrimSpace(content[:processedChars])
+	}
 
-	// 添加助手回复到对话历史
-	h.dialogueManager.Put(chat.Message{
-		Role:    "assistant",
-		Content: content,
-	})
+	// 添加助手回复到对话历史，工具调用的回复已在递归请求中写入
+	if content != "" || !toolCallFlag {
+		h.putAssistantReply(content, round)
+	}
+	h.endSpeakIfNothingQueued()
 
 	return nil
 }
 
-func (h *ConnectionHandler) handleFunctionResult(result types.ActionResponse, functionCallData map[string]interface{}, textIndex int) {
+// parseTextToolCall 解析文本形式的工具调用，arguments 可能是字符串或对象
+func parseTextToolCall(content string) (types.ToolCall, bool) {
+	data := utils.Extract_json_from_string(content)
+	name, _ := data["name"].(string)
+	if name == "" {
+		return types.ToolCall{}, false
+	}
+	arguments, ok := data["arguments"].(string)
+	if !ok && data["arguments"] != nil {
+		raw, err := json.Marshal(data["arguments"])
+		if err != nil {
+			return types.ToolCall{}, false
+		}
+		arguments = string(raw)
+	}
+	return types.ToolCall{
+		ID:       uuid.New().String(),
+		Type:     "function",
+		Function: types.FunctionCall{Name: name, Arguments: arguments},
+	}, true
+}
+
+// handleToolCalls 依次执行模型发起的工具调用，需要回传 LLM 的结果合并后只请求一次 LLM，
+// 返回更新后的分段序号
+func (h *ConnectionHandler) handleToolCalls(ctx context.Context, calls []types.ToolCall, textIndex int) int {
+	var pendingCalls []types.ToolCall
+	var pendingResults []string
+
+	for _, call := range calls {
+		if call.ID == "" {
+			call.ID = uuid.New().String()
+		}
+		functionName := call.Function.Name
+		arguments := make(map[string]interface{})
+		var parseErr error
+		if call.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &arguments); err != nil {
+				h.logger.Error(fmt.Sprintf("函数调用参数解析失败: %v", err))
+				parseErr = fmt.Errorf("arguments 不是合法的 JSON 对象: %v", err)
+			}
+		}
+		h.logger.Info(fmt.Sprintf("函数调用: %s %v", functionName, arguments))
+
+		var result types.ActionResponse
+		if feedback, invalid := h.checkToolArguments(functionName, arguments, parseErr); invalid {
+			result = feedback
+		} else if h.mcpManager.IsMCPTool(functionName) {
+			// 处理MCP函数调用
+			mcpResult, err := h.mcpManager.ExecuteTool(ctx, functionName, arguments)
+			if err != nil {
+				h.logger.Error(fmt.Sprintf("MCP函数调用失败: %v", err))
+				result = h.toolFailureFeedback(functionName, err.Error())
+			} else {
+				h.logger.Info(fmt.Sprintf("MCP函数调用结果: %v", mcpResult))
+				result = types.ActionResponse{
+					Action: types.ActionTypeReqLLM, // 动作类型
+					Result: mcpResult,              // 动作产生的结果
+				}
+			}
+		} else if h.functionRegister.IsLocalFunction(functionName) {
+			// 处理本地函数调用
+			result = h.functionRegister.CallFunction(ctx, functionName, arguments)
+			if result.Action == types.ActionTypeError {
+				h.logger.Error(fmt.Sprintf("函数调用错误: %v", result.Result))
+				result = h.toolFailureFeedback(functionName, fmt.Sprint(result.Result))
+			}
+		} else {
+			h.logger.Error(fmt.Sprintf("未知的函数调用: %s", functionName))
+			result = h.toolFailureFeedback(functionName, "没有这个工具，请只使用已声明的工具")
+		}
+
+		if result.Action != types.ActionTypeReqLLM {
+			textIndex = h.handleFunctionResult(result, textIndex)
+			continue
+		}
+		h.logger.Info(fmt.Sprintf("函数调用后请求LLM: %v", result.Result))
+		text, ok := result.Result.(string)
+		if !ok || len(text) == 0 {
+			h.logger.Error(fmt.Sprintf("函数调用结果解析失败: %v", result.Result))
+			// 发送错误消息
+			textIndex++
+			errorMessage := fmt.Sprintf("函数调用结果解析失败 %v", result.Result)
+			h.SpeakAndPlay(errorMessage, textIndex, h.currentRound())
+			continue
+		}
+		h.logger.Info(fmt.Sprintf("函数调用结果: %s, 名称: %s, ID: %s, 参数: %s",
+			text, functionName, call.ID, call.Function.Arguments))
+		pendingCalls = append(pendingCalls, call)
+		pendingResults = append(pendingResults, text)
+	}
+
+	if len(pendingCalls) > 0 {
+		h.requestLLMWithToolResults(pendingCalls, pendingResults)
+	}
+	return textIndex
+}
+
+// checkToolArguments 调用前按工具声明的参数 schema 校验 arguments，不合法时把错误回传 LLM 让其修正后重试
+func (h *ConnectionHandler) checkToolArguments(name string, args map[string]interface{}, parseErr error) (types.ActionResponse, bool) {
+	err := parseErr
+	if err == nil {
+		tool, lookupErr := h.functionRegister.GetFunction(name)
+		if lookupErr != nil || tool.Function == nil {
+			return types.ActionResponse{}, false
+		}
+		err = function.ValidateArguments(tool.Function.Parameters, args)
+	}
+	if err == nil {
+		return types.ActionResponse{}, false
+	}
+
+	h.logger.Warn(fmt.Sprintf("函数 %s 参数校验失败: %v", name, err))
+	return h.toolFailureFeedback(name, fmt.Sprintf("参数不合法: %v，请按照工具的参数说明修正 arguments", err)), true
+}
+
+// toolFailureFeedback 工具调用失败时把原因作为工具结果回传 LLM，让其换参数或换工具重试；
+// 同一轮对话中失败超过 maxToolRetries 次后直接告知用户，避免反复调用
+func (h *ConnectionHandler) toolFailureFeedback(name, reason string) types.ActionResponse {
+	if h.toolRetryRound != h.currentRound() {
+		h.toolRetryRound = h.currentRound()
+		h.toolRetries = 0
+	}
+	h.toolRetries++
+	if h.toolRetries > maxToolRetries {
+		h.logger.Warn(fmt.Sprintf("本轮工具调用已失败 %d 次，不再重试", h.toolRetries))
+		return types.ActionResponse{
+			Action:   types.ActionTypeResponse,
+			Response: "抱歉，这件事我暂时没能办成，请稍后再试或者换个说法",
+		}
+	}
+	return types.ActionResponse{
+		Action: types.ActionTypeReqLLM,
+		Result: fmt.Sprintf("调用 %s 失败: %s。可以修正参数或换用其他工具重试，确实无法完成时请如实告诉用户原因", name, reason),
+	}
+}
+
+// handleFunctionResult 处理不需要回传 LLM 的函数调用结果，返回更新后的分段序号
+func (h *ConnectionHandler) handleFunctionResult(result types.ActionResponse, textIndex int) int {
 	switch result.Action {
 	case types.ActionTypeError:
 		h.logger.Error(fmt.Sprintf("函数调用错误: %v", result.Result))
@@ -592,60 +1045,64 @@ func (h *ConnectionHandler) handleFunctionResult(result types.ActionResponse, fu
 		h.logger.Info(fmt.Sprintf("函数调用无操作: %v", result.Result))
 	case types.ActionTypeResponse:
 		h.logger.Info(fmt.Sprintf("函数调用直接回复: %v", result.Response))
-		h.SpeakAndPlay(result.Response.(string), textIndex, h.talkRound)
-	case types.ActionTypeReqLLM:
-		h.logger.Info(fmt.Sprintf("函数调用后请求LLM: %v", result.Result))
-		text, ok := result.Result.(string)
-		if ok && len(text) > 0 {
-			functionID := functionCallData["id"].(string)
-			functionName := functionCallData["name"].(string)
-			functionArguments := functionCallData["arguments"].(string)
-			h.logger.Info(fmt.Sprintf("函数调用结果: %s", text))
-			h.logger.Info(fmt.Sprintf("函数调用参数: %s", functionArguments))
-			h.logger.Info(fmt.Sprintf("函数调用名称: %s", functionName))
-			h.logger.Info(fmt.Sprintf("函数调用ID: %s", functionID))
-
-			// 添加 assistant 消息，包含 tool_calls
-			h.dialogueManager.Put(chat.Message{
-				Role: "assistant",
-				ToolCalls: []types.ToolCall{{
-					ID: functionID,
-					Function: types.FunctionCall{
-						Arguments: functionArguments,
-						Name:      functionName,
-					},
-					Type:  "function",
-					Index: 0,
-				}},
-			})
+		text, _ := result.Response.(string)
+		textIndex++
+		if err := h.SpeakAndPlay(text, textIndex, h.currentRound()); err == nil {
+			h.tts_last_text_index.Store(int64(textIndex))
+		}
+		h.dialogueManager.Put(chat.Message{
+			Role:    "assistant",
+			Content: text,
+		})
+		h.recordHistory("assistant", text)
+	}
+	return textIndex
+}
 
-			// 添加 tool 消息
-			toolCallID := functionID
-			if toolCallID == "" {
-				toolCallID = uuid.New().String()
-			}
-			h.dialogueManager.Put(chat.Message{
-				Role:       "tool",
-				ToolCallID: toolCallID,
-				Content:    text,
-			})
+// requestLLMWithToolResults 把工具调用及结果写入对话历史后再次请求LLM
+// 多个调用合并为一条带 tool_calls 的 assistant 消息，每个结果对应一条 tool 消息
+func (h *ConnectionHandler) requestLLMWithToolResults(calls []types.ToolCall, results []string) {
+	// 添加 assistant 消息，包含 tool_calls
+	toolCalls := make([]types.ToolCall, len(calls))
+	for i, call := range calls {
+		toolCalls[i] = types.ToolCall{
+			ID:       call.ID,
+			Type:     "function",
+			Function: call.Function,
+			Index:    i,
+		}
+	}
+	h.dialogueManager.Put(chat.Message{
+		Role:      "assistant",
+		ToolCalls: toolCalls,
+	})
 
-			messages := make([]providers.Message, 0)
-			for _, msg := range h.dialogueManager.GetLLMDialogue() {
-				messages = append(messages, providers.Message{
-					Role:       msg.Role,
-					Content:    msg.Content,
-					ToolCalls:  msg.ToolCalls,
-					ToolCallID: msg.ToolCallID,
-				})
-			}
-			// 递归调用 chat_with_function_calling 逻辑
-			h.genResponseByLLM(context.Background(), messages, h.talkRound)
-		} else {
-			h.logger.Error(fmt.Sprintf("函数调用结果解析失败: %v", result.Result))
-			// 发送错误消息
-			errorMessage := fmt.Sprintf("函数调用结果解析失败 %v", result.Result)
-			h.SpeakAndPlay(errorMessage, textIndex, h.talkRound)
+	// 添加 tool 消息
+	for i, call := range calls {
+		h.dialogueManager.Put(chat.Message{
+			Role:       "tool",
+			ToolCallID: call.ID,
+			Content:    results[i],
+		})
+	}
+	h.recordToolHistory(toolCalls, results)
+
+	messages := make([]providers.Message, 0)
+	for _, msg := range h.dialogueManager.GetLLMDialogue() {
+		messages = append(messages, providers.Message{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
+		})
+	}
+	// 递归调用 chat_with_function_calling 逻辑
+	h.genResponseByLLM(context.Background(), messages, h.currentRound())
+
+	// LLM 已根据工具结果作答，折叠工具往返
+	if h.config.ToolHistory.Fold {
+		if folded := h.dialogueManager.FoldToolCalls(h.config.ToolHistory.MaxResultChars); folded > 0 {
+			h.logger.Info(fmt.Sprintf("已折叠 %d 次工具往返", folded))
 		}
 	}
 }
@@ -662,7 +1119,7 @@ func (h *ConnectionHandler) isNeedAuth() bool {
 func (h *ConnectionHandler) checkAndBroadcastAuthCode() error {
 	// 这里简化了认证逻辑，实际需要根据具体需求实现
 	text := "请联系管理员进行设备认证"
-	return h.SpeakAndPlay(text, 0, h.talkRound)
+	return h.SpeakAndPlay(text, 0, h.currentRound())
 }
 
 // processTTSQueueCoroutine 处理TTS队列
@@ -672,7 +1129,7 @@ func (h *ConnectionHandler) processTTSQueueCoroutine() {
 		case <-h.stopChan:
 			return
 		case task := <-h.ttsQueue:
-			h.processTTSTask(task.text, task.textIndex, task.round)
+			h.processTTSTask(task.text, task.textIndex, task.round, task.partial)
 		}
 	}
 }
@@ -680,7 +1137,27 @@ func (h *ConnectionHandler) processTTSQueueCoroutine() {
 // 服务端打断说话
 func (h *ConnectionHandler) stopServerSpeak() {
 	h.logger.Info("服务端停止说话")
-	atomic.StoreInt32(&h.serverVoiceStop, 1)
+	t := h.voice.Fire(EventUserBargeIn)
+	if t.From == VoiceSpeaking {
+		metrics.BargeIns.WithLabelValues(h.clientListenMode).Inc()
+		h.markReplyInterrupted()
+	}
+	h.drainSpeakQueues()
+}
+
+// stopSpeakByServer 服务端主动打断播报（如推送播报），不计入用户打断，
+// 同时放弃暂停的分批回复，避免之后的肯定回答接着播报旧的回复
+func (h *ConnectionHandler) stopSpeakByServer() {
+	h.logger.Info("服务端打断当前播报")
+	if t := h.voice.Fire(EventServerInterrupt); t.From == VoiceSpeaking {
+		h.markReplyInterrupted()
+	}
+	h.setPendingPages(nil)
+	h.drainSpeakQueues()
+}
+
+// drainSpeakQueues 丢弃尚未合成的文本与尚未下发的音频
+func (h *ConnectionHandler) drainSpeakQueues() {
 	// 终止tts任务，不再继续将文本加入到tts队列，清空ttsQueue队列
 	for {
 		select {
@@ -699,8 +1176,9 @@ clearAudioQueue:
 		select {
 		case task := <-h.audioMessagesQueue:
 			h.logger.Info(fmt.Sprintf("丢弃一个音频任务: %s", task.text))
+			task.stream.close()
 			// 根据配置删除被丢弃的音频文件
-			if h.config.DeleteAudio && task.filepath != "" {
+			if h.deleteAfterPlay(task.filepath) {
 				if err := os.Remove(task.filepath); err != nil {
 					h.logger.Error(fmt.Sprintf("删除被丢弃的音频文件失败: %v", err))
 				} else {
@@ -716,15 +1194,18 @@ clearAudioQueue:
 }
 
 // processTTSTask 处理单个TTS任务
-func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int) {
+func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int, partial bool) {
 	filepath := ""
+	var stream *ttsStream
 	defer func() {
 		h.audioMessagesQueue <- struct {
 			filepath  string
 			text      string
 			round     int
 			textIndex int
-		}{filepath, text, round, textIndex}
+			partial   bool
+			stream    *ttsStream
+		}{filepath, text, round, textIndex, partial, stream}
 	}()
 
 	ttsStartTime := time.Now()
@@ -736,15 +1217,23 @@ func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int
 		return
 	}
 
+	// 流式合成收到首个数据块即交给发送协程，边合成边下发
+	if stream = h.openTTSStream(text, textIndex); stream != nil {
+		if textIndex == 1 {
+			h.logger.Info(fmt.Sprintf("TTS首包耗时: %s, 文本: %s, 索引: %d", time.Since(ttsStartTime), text, textIndex))
+		}
+		return
+	}
+
 	// 生成语音文件
-	filepath, err := h.providers.tts.ToTTS(text)
+	filepath, err := h.synthesize(text, textIndex)
 	if err != nil {
 		h.logger.Error(fmt.Sprintf("TTS转换失败:text(%s) %v", text, err))
 		return
 	} else {
 		h.logger.Info(fmt.Sprintf("TTS转换成功: text(%s), index(%d) %s", text, textIndex, filepath))
 	}
-	if atomic.LoadInt32(&h.serverVoiceStop) == 1 { // 服务端语音停止
+	if h.voice.Interrupted() { // 服务端语音停止
 		h.logger.Info(fmt.Sprintf("processTTSTask 服务端语音停止, 不再发送音频数据：%s", text))
 		// 服务端语音停止时，根据配置删除已生成的音频文件
 		if h.config.DeleteAudio && filepath != "" {
@@ -765,6 +1254,40 @@ func (h *ConnectionHandler) processTTSTask(text string, textIndex int, round int
 
 }
 
+// synthesize 调用主TTS合成，失败时按 tts_fallback 顺序切换备用TTS
+// 主TTS失败后的冷却期内直接使用备用TTS，避免每句都等待主TTS超时
+func (h *ConnectionHandler) synthesize(text string, textIndex int) (string, error) {
+	primary := h.config.SelectedModule["TTS"]
+	if len(h.providers.ttsFallbacks) == 0 || time.Now().After(h.ttsDegradedUntil) {
+		start := time.Now()
+		filepath, err := h.providers.tts.ToTTS(text)
+		metrics.ObserveProvider(metrics.StageTTS, h.providerType("TTS", primary), time.Since(start), err)
+		if err != nil {
+			metrics.TTSFailures.WithLabelValues(h.providerType("TTS", primary)).Inc()
+		}
+		if err == nil || len(h.providers.ttsFallbacks) == 0 {
+			return filepath, err
+		}
+		h.ttsDegradedUntil = time.Now().Add(ttsFallbackCooldown)
+		h.logger.Warn(fmt.Sprintf("TTS降级: 主TTS %s 合成失败: %v，%v内改用备用TTS", primary, err, ttsFallbackCooldown))
+	}
+
+	var lastErr error
+	for _, fallback := range h.providers.ttsFallbacks {
+		start := time.Now()
+		filepath, err := fallback.Provider.ToTTS(text)
+		metrics.ObserveProvider(metrics.StageTTS, h.providerType("TTS", fallback.Name), time.Since(start), err)
+		if err == nil {
+			h.logger.Info(fmt.Sprintf("TTS降级: 使用备用TTS %s 合成成功, 索引: %d", fallback.Name, textIndex))
+			return filepath, nil
+		}
+		lastErr = err
+		metrics.TTSFailures.WithLabelValues(h.providerType("TTS", fallback.Name)).Inc()
+		h.logger.Warn(fmt.Sprintf("TTS降级: 备用TTS %s 合成失败: %v", fallback.Name, err))
+	}
+	return "", fmt.Errorf("主TTS与所有备用TTS均合成失败: %v", lastErr)
+}
+
 // speakAndPlay 合成并播放语音
 func (h *ConnectionHandler) SpeakAndPlay(text string, textIndex int, round int) error {
 	originText := text // 保存原始文本用于日志
@@ -775,29 +1298,90 @@ func (h *ConnectionHandler) SpeakAndPlay(text string, textIndex int, round int)
 		return errors.New("收到空文本，无法合成语音")
 	}
 
-	if atomic.LoadInt32(&h.serverVoiceStop) == 1 { // 服务端语音停止
+	if h.voice.Interrupted() { // 服务端语音停止
 		h.logger.Info(fmt.Sprintf("speakAndPlay 服务端语音停止, 不再发送音频数据：%s", text))
 		return errors.New("服务端语音已停止，无法合成语音")
 	}
+	// 推送或主动播报已开始新的一轮，仍在输出的旧轮次分段不再入队
+	if round != h.currentRound() {
+		h.logger.Info(fmt.Sprintf("speakAndPlay 轮次已过期(%d, 当前 %d), 丢弃分段：%s", round, h.currentRound(), text))
+		return errors.New("轮次已过期，无法合成语音")
+	}
 
-	if len(text) > 255 {
-		h.logger.Warn(fmt.Sprintf("文本过长，超过255字符限制，无法合成语音: %s", text))
-		return errors.New("文本过长，超过255字符限制，无法合成语音")
+	// LLM 偶尔重复生成同一句，同一轮次内与上一分段高度相似时丢弃
+	h.spokenMu.Lock()
+	if round == h.lastSpokenRound && utils.TextSimilarity(h.lastSpokenText, text) >= duplicateSimilarity {
+		h.duplicateSegments++
+		duplicates := h.duplicateSegments
+		h.spokenMu.Unlock()
+		h.logger.Info(fmt.Sprintf("丢弃重复分段(累计 %d 次): %s, 索引: %d", duplicates, text, textIndex))
+		return errDuplicateSegment
+	}
+	h.lastSpokenText, h.lastSpokenRound = text, round
+	h.spokenMu.Unlock()
+	h.sendCaption(captionRoleAssistant, round, textIndex, text, false)
+
+	// 超长文本按句拆成多段依次合成，同一 textIndex 只在末段播完后结束
+	pieces := []string{text}
+	if len(text) > maxTTSTextLen {
+		pieces = utils.SplitTextByLength(text, maxTTSTextLen)
+		h.logger.Info(fmt.Sprintf("文本过长(%d字节)，拆分为 %d 段合成, 索引: %d", len(text), len(pieces), textIndex))
 	}
 
 	// 将任务加入队列，不阻塞当前流程
-	h.ttsQueue <- struct {
-		text      string
-		round     int
-		textIndex int
-	}{text, round, textIndex}
+	for i, piece := range pieces {
+		h.ttsQueue <- struct {
+			text      string
+			round     int
+			textIndex int
+			partial   bool
+		}{piece, round, textIndex, i < len(pieces)-1}
+	}
 
 	return nil
 }
 
+// startRound 开始新的一轮并返回轮次
+func (h *ConnectionHandler) startRound() int {
+	round := int(h.talkRound.Add(1))
+	h.roundStartTime.Store(time.Now().UnixNano())
+	return round
+}
+
+// currentRound 返回当前轮次
+func (h *ConnectionHandler) currentRound() int {
+	return int(h.talkRound.Load())
+}
+
+// roundStart 返回当前轮次的开始时间
+func (h *ConnectionHandler) roundStart() time.Time {
+	return time.Unix(0, h.roundStartTime.Load())
+}
+
+// endSpeakIfNothingQueued 本轮回复没有入队任何分段时，不会有播放结束的回调，直接结束播报状态
+func (h *ConnectionHandler) endSpeakIfNothingQueued() {
+	if h.tts_last_text_index.Load() == -1 {
+		h.voice.Fire(EventTTSDone)
+	}
+}
+
+// onVoiceTransition 讲话状态变化后的处理，告别语播完后关闭连接
+func (h *ConnectionHandler) onVoiceTransition(t VoiceTransition) {
+	if t.From != t.To {
+		h.logger.Debug(fmt.Sprintf("讲话状态: %s -[%s]-> %s", t.From, t.Event, t.To))
+	}
+	if t.Event == EventTTSDone && t.To == VoiceClosing {
+		h.logger.Info("告别语播放完毕")
+		h.closeConn()
+	}
+}
+
 func (h *ConnectionHandler) clearSpeakStatus() {
 	h.logger.Info("清除服务端讲话状态 ")
-	h.tts_last_text_index = -1
+	h.tts_last_text_index.Store(-1)
+	h.voice.Fire(EventTTSDone)
+	h.touchVoiceTime() // 播报结束后重新开始静音计时
+	h.touchActiveTime()
 	h.providers.asr.Reset() // 重置ASR状态
 }
 
@@ -816,6 +1400,10 @@ func (h *ConnectionHandler) Close() {
 
 		close(h.stopChan)
 
+		// 连接断开时结束进行中的会议转写，摘要在后台生成
+		h.stopTranscription()
+		h.endHistory()
+
 		// 清理待处理的音频文件
 		if h.config.DeleteAudio {
 			// 清理TTS队列中的任务（这些任务还没有生成音频文件，无需删除）
@@ -834,7 +1422,8 @@ func (h *ConnectionHandler) Close() {
 				select {
 				case task := <-h.audioMessagesQueue:
 					h.logger.Info(fmt.Sprintf("连接关闭，丢弃音频任务: %s", task.text))
-					if task.filepath != "" {
+					task.stream.close()
+					if h.deleteAfterPlay(task.filepath) {
 						if err := os.Remove(task.filepath); err != nil {
 							h.logger.Error(fmt.Sprintf("连接关闭时删除音频文件失败: %v", err))
 						} else {
@@ -848,6 +1437,19 @@ func (h *ConnectionHandler) Close() {
 		}
 
 	closeChannels:
+		h.usageMu.Lock()
+		if h.llmUsage.PromptTokens > 0 {
+			h.logger.Info(fmt.Sprintf("连接LLM累计用量: 输入 %d tokens（缓存命中 %d），输出 %d tokens",
+				h.llmUsage.PromptTokens, h.llmUsage.CachedTokens, h.llmUsage.CompletionTokens))
+		}
+		h.usageMu.Unlock()
+		h.spokenMu.Lock()
+		duplicates := h.duplicateSegments
+		h.spokenMu.Unlock()
+		if duplicates > 0 {
+			h.logger.Info(fmt.Sprintf("连接累计抑制重复分段 %d 次", duplicates))
+		}
+
 		close(h.clientAudioQueue)
 		close(h.clientTextQueue)
 
@@ -855,6 +1457,120 @@ func (h *ConnectionHandler) Close() {
 	})
 }
 
+// replayCachedResponse 按原分段播报缓存的回复，并写入对话历史
+func (h *ConnectionHandler) replayCachedResponse(segments []string, round int) error {
+	h.logger.Info(fmt.Sprintf("命中LLM回复缓存，共 %d 段, round:%d", len(segments), round))
+	h.voice.Fire(EventSpeakStart)
+	for i, segment := range segments {
+		textIndex := i + 1
+		if err := h.SpeakAndPlay(segment, textIndex, round); err == nil {
+			h.tts_last_text_index.Store(int64(textIndex))
+		} else {
+			h.logger.Error(fmt.Sprintf("播放缓存回复分段失败: %v", err))
+		}
+	}
+	h.putAssistantReply(strings.Join(segments, ""), round)
+	h.endSpeakIfNothingQueued()
+	return nil
+}
+
+// recordPlayed 记录开始播放的分段
+func (h *ConnectionHandler) recordPlayed(text string, round int) {
+	h.playedMu.Lock()
+	defer h.playedMu.Unlock()
+	if round != h.playedRound {
+		h.playedRound = round
+		h.playedSegments = nil
+	}
+	h.playedSegments = append(h.playedSegments, text)
+}
+
+// playedText 返回指定轮次已开始播放的内容
+func (h *ConnectionHandler) playedText(round int) string {
+	h.playedMu.Lock()
+	defer h.playedMu.Unlock()
+	if round != h.playedRound {
+		return ""
+	}
+	return strings.Join(h.playedSegments, "")
+}
+
+// putAssistantReply 助手回复写入历史，生成过程中已被打断时只写入已播出的部分
+func (h *ConnectionHandler) putAssistantReply(content string, round int) {
+	if content != "" && h.voice.Interrupted() {
+		played := h.playedText(round)
+		h.sendCaption(captionRoleAssistant, round, 0, played, true)
+		content = h.interruptedContent(played)
+		h.logger.Info(fmt.Sprintf("本轮回复被打断，写入历史: %s, round:%d", content, round))
+		h.historyRound = 0
+	} else {
+		h.sendCaption(captionRoleAssistant, round, 0, content, true)
+		h.historyRound = round
+	}
+	h.historyPrefix = ""
+	h.dialogueManager.Put(chat.Message{
+		Role:    "assistant",
+		Content: content,
+	})
+	h.historyReplyID = h.recordHistory("assistant", content)
+}
+
+// appendAssistantReply 分批播报的后续一批追加到上一条助手回复，历史轮次指向本批所在的轮次
+func (h *ConnectionHandler) appendAssistantReply(page string, round int) {
+	dialogue := h.dialogueManager.GetLLMDialogue()
+	if len(dialogue) == 0 || dialogue[len(dialogue)-1].Role != "assistant" {
+		h.putAssistantReply(page, round)
+		return
+	}
+	prefix := dialogue[len(dialogue)-1].Content + "\n"
+	content := prefix + page
+	if h.voice.Interrupted() {
+		played := h.playedText(round)
+		h.sendCaption(captionRoleAssistant, round, 0, played, true)
+		content = prefix + h.interruptedContent(played)
+		h.logger.Info(fmt.Sprintf("分批回复被打断，写入历史: %s, round:%d", content, round))
+		h.historyRound = 0
+	} else {
+		h.sendCaption(captionRoleAssistant, round, 0, page, true)
+		h.historyRound = round
+	}
+	h.historyPrefix = prefix
+	h.dialogueManager.UpdateLastAssistant(content)
+	h.updateHistory(h.historyReplyID, content)
+}
+
+// interruptedContent 被打断的回复写入历史的内容，按用户打断与服务端打断使用不同的标记
+func (h *ConnectionHandler) interruptedContent(played string) string {
+	if h.voice.InterruptedByServer() {
+		return chat.ServerInterruptedContent(played)
+	}
+	return chat.InterruptedContent(played)
+}
+
+// markReplyInterrupted 播报中被打断时，把已写入历史的本轮回复截断为已播出的部分
+// 回复还在生成时由 putAssistantReply 处理
+func (h *ConnectionHandler) markReplyInterrupted() {
+	round := h.currentRound()
+	if h.historyRound != round {
+		return
+	}
+	content := h.historyPrefix + h.interruptedContent(h.playedText(round))
+	if h.dialogueManager.UpdateLastAssistant(content) {
+		h.logger.Info(fmt.Sprintf("本轮回复被打断，历史截断为: %s, round:%d", content, round))
+		h.updateHistory(h.historyReplyID, content)
+	}
+	h.historyRound = 0
+}
+
+// recordLLMUsage 记录单次请求的 token 用量并累加到连接统计
+func (h *ConnectionHandler) recordLLMUsage(usage *types.Usage) {
+	h.logger.Info(fmt.Sprintf("LLM用量: 输入 %d tokens（缓存命中 %d，写入缓存 %d），输出 %d tokens",
+		usage.PromptTokens, usage.CachedTokens, usage.CacheCreationTokens, usage.CompletionTokens))
+	h.usageMu.Lock()
+	h.llmUsage.Add(usage)
+	h.usageMu.Unlock()
+}
+
 // detectImageURL 检测文本中的图片URL
 func (h *ConnectionHandler) detectImageURL(text string) (imageURL string, remainingText string, detected bool) {
 	// 定义图片URL的正则表达式
@@ -932,7 +1648,7 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 	processedChars := 0
 	textIndex := 0
 
-	atomic.StoreInt32(&h.serverVoiceStop, 0)
+	h.voice.Fire(EventSpeakStart)
 
 	for response := range responses {
 		if response == "" {
@@ -949,7 +1665,7 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 			textIndex++
 			err := h.SpeakAndPlay(segment, textIndex, round)
 			if err == nil {
-				h.tts_last_text_index = textIndex
+				h.tts_last_text_index.Store(int64(textIndex))
 			}
 			processedChars += chars
 		}
@@ -961,7 +1677,7 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 		textIndex++
 		err := h.SpeakAndPlay(remainingText, textIndex, round)
 		if err == nil {
-			h.tts_last_text_index = textIndex
+			h.tts_last_text_index.Store(int64(textIndex))
 		}
 	}
 
@@ -973,11 +1689,13 @@ func (h *ConnectionHandler) genResponseByVLLM(ctx context.Context, messages []pr
 		Role:    "assistant",
 		Content: content,
 	})
+	h.recordHistory("assistant", content)
 
 	h.logger.Info("VLLLM回复处理完成", map[string]interface{}{
 		"content_length": len(content),
 		"text_segments":  textIndex,
 	})
+	h.endSpeakIfNothingQueued()
 
 	return nil
 }
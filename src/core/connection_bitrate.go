@@ -0,0 +1,158 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const defaultOpusUpgradeAfter = 3
+
+// defaultOpusLevels 自适应码率的默认档位，从低到高
+var defaultOpusLevels = []configs.OpusLevel{
+	{Bitrate: 12000, Complexity: 5},
+	{Bitrate: 16000, Complexity: 8},
+	{Bitrate: 24000, Complexity: 10},
+	{Bitrate: 32000, Complexity: 10},
+}
+
+// 客户端 network 消息上报的网络状况
+const (
+	networkPoor = "poor"
+	networkGood = "good"
+)
+
+// opusAdaptor 按网络状况在档位间切换 Opus 编码码率与复杂度
+// 发送协程每句结束后上报发送情况，主消息循环转交客户端反馈，需加锁
+type opusAdaptor struct {
+	mu           sync.Mutex
+	levels       []configs.OpusLevel
+	level        int
+	upgradeAfter int
+	goodStreak   int // 连续发送顺畅的句数
+}
+
+// newOpusAdaptor 按配置创建自适应码率，未启用时返回 nil；初始档位为中间一档，无效的档位忽略
+func newOpusAdaptor(cfg configs.AdaptiveOpusConfig, logger *utils.Logger) *opusAdaptor {
+	if !cfg.Enabled {
+		return nil
+	}
+	var levels []configs.OpusLevel
+	for _, level := range cfg.Levels {
+		if level.Bitrate < 500 || level.Bitrate > 512000 || level.Complexity < 0 || level.Complexity > 10 {
+			logger.Warn(fmt.Sprintf("Opus 编码档位无效: 码率 %dbps, 复杂度 %d，已忽略", level.Bitrate, level.Complexity))
+			continue
+		}
+		levels = append(levels, level)
+	}
+	if len(levels) == 0 {
+		levels = defaultOpusLevels
+	}
+	upgradeAfter := cfg.UpgradeAfter
+	if upgradeAfter <= 0 {
+		upgradeAfter = defaultOpusUpgradeAfter
+	}
+	return &opusAdaptor{levels: levels, level: len(levels) / 2, upgradeAfter: upgradeAfter}
+}
+
+// current 返回当前档位
+func (a *opusAdaptor) current() configs.OpusLevel {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.levels[a.level]
+}
+
+// observe 按一句音频的发送情况调整档位，返回是否切换
+// 超过五分之一的帧落后于播放进度一帧以上，或写入耗时超过音频时长一半时判为弱网，立即降一档；
+// 没有落后且写入耗时不到音频时长十分之一时计为顺畅，连续 upgradeAfter 句顺畅后升一档
+func (a *opusAdaptor) observe(frames, lateFrames int, writeTime, audioTime time.Duration) bool {
+	if frames == 0 {
+		return false
+	}
+	switch {
+	case lateFrames*5 > frames || writeTime > audioTime/2:
+		return a.step(-1)
+	case lateFrames == 0 && writeTime < audioTime/10:
+		a.mu.Lock()
+		a.goodStreak++
+		upgrade := a.goodStreak >= a.upgradeAfter
+		a.mu.Unlock()
+		if upgrade {
+			return a.step(1)
+		}
+	default:
+		a.mu.Lock()
+		a.goodStreak = 0
+		a.mu.Unlock()
+	}
+	return false
+}
+
+// feedback 按客户端上报的网络状况升降一档
+func (a *opusAdaptor) feedback(quality string) bool {
+	switch quality {
+	case networkPoor:
+		return a.step(-1)
+	case networkGood:
+		return a.step(1)
+	}
+	return false
+}
+
+// step 升降档位并清零顺畅计数，已在最高或最低档时不变
+func (a *opusAdaptor) step(delta int) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.goodStreak = 0
+	level := a.level + delta
+	if level < 0 || level >= len(a.levels) {
+		return false
+	}
+	a.level = level
+	return true
+}
+
+// opusOptions 返回下一句音频使用的 Opus 编码参数，未启用自适应时使用固定的码率与复杂度
+func (h *ConnectionHandler) opusOptions() utils.OpusEncodeOptions {
+	cfg := h.config.AudioOutput.Opus
+	opts := utils.OpusEncodeOptions{Bitrate: cfg.Bitrate, Complexity: cfg.Complexity, DTX: cfg.DTX, CBR: cfg.CBR}
+	if h.opusAdaptor != nil {
+		level := h.opusAdaptor.current()
+		opts.Bitrate, opts.Complexity = level.Bitrate, level.Complexity
+	}
+	return opts
+}
+
+// observeSend 每句音频发送完成后上报发送情况
+func (h *ConnectionHandler) observeSend(frames, lateFrames int, writeTime time.Duration) {
+	if h.opusAdaptor == nil || h.serverAudioFormat != "opus" {
+		return
+	}
+	audioTime := time.Duration(frames*h.serverAudioFrameDuration) * time.Millisecond
+	if h.opusAdaptor.observe(frames, lateFrames, writeTime, audioTime) {
+		h.logOpusLevel(fmt.Sprintf("落后帧 %d/%d, 写入耗时 %v", lateFrames, frames, writeTime))
+	}
+}
+
+// handleNetworkMessage 处理客户端上报的网络状况 {"type":"network","quality":"poor|good"}
+func (h *ConnectionHandler) handleNetworkMessage(msgMap map[string]interface{}) error {
+	quality, _ := msgMap["quality"].(string)
+	if quality != networkPoor && quality != networkGood {
+		return fmt.Errorf("network 消息的 quality 无效: %v", msgMap["quality"])
+	}
+	if h.opusAdaptor == nil {
+		return nil
+	}
+	if h.opusAdaptor.feedback(quality) {
+		h.logOpusLevel("客户端反馈网络" + quality)
+	}
+	return nil
+}
+
+func (h *ConnectionHandler) logOpusLevel(reason string) {
+	level := h.opusAdaptor.current()
+	h.logger.Info(fmt.Sprintf("调整Opus编码参数(%s): 码率 %dbps, 复杂度 %d", reason, level.Bitrate, level.Complexity))
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/calendar"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	calendarMaxDays = 7                // get_calendar 一次最多查询的天数
+	calendarTimeout = 10 * time.Second // 拉取节假日与日程的超时
+)
+
+// registerCalendarFunction 注册 get_calendar，查询节假日、农历与设备绑定的日程
+func (h *ConnectionHandler) registerCalendarFunction() {
+	tool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name: "get_calendar",
+			Description: "查询某天是否放假或调休、农历日期、节气，以及用户日历中的日程安排。" +
+				"用户问“明天放假吗”“今天农历几号”“我下午有什么安排”“这周有什么日程”时调用",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"day_offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "相对今天的天数，今天为 0，明天为 1，昨天为 -1，默认 0",
+					},
+					"date": map[string]interface{}{
+						"type":        "string",
+						"description": "要查询的公历日期 YYYY-MM-DD，传了则忽略 day_offset",
+					},
+					"days": map[string]interface{}{
+						"type":        "integer",
+						"minimum":     1,
+						"maximum":     calendarMaxDays,
+						"description": "从该天起连续查询几天，问“这周”时可传 7，默认 1",
+					},
+				},
+			},
+		},
+	}
+	if err := h.functionRegister.RegisterLocalFunction("get_calendar", tool, h.handleGetCalendar); err != nil {
+		h.logger.Error(fmt.Sprintf("注册本地函数失败: get_calendar, 错误: %v", err))
+	}
+}
+
+func (h *ConnectionHandler) handleGetCalendar(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	now := h.now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if date, _ := args["date"].(string); date != "" {
+		t, err := time.ParseInLocation("2006-01-02", date, now.Location())
+		if err != nil {
+			return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: "日期格式应为 YYYY-MM-DD: " + date}
+		}
+		start = t
+	} else if offset, ok := intArg(args, "day_offset"); ok {
+		start = start.AddDate(0, 0, offset)
+	}
+	days, _ := intArg(args, "days")
+	if days <= 0 {
+		days = 1
+	}
+	if days > calendarMaxDays {
+		days = calendarMaxDays
+	}
+	end := start.AddDate(0, 0, days)
+
+	ctx, cancel := context.WithTimeout(ctx, calendarTimeout)
+	defer cancel()
+	events, eventNote := h.calendarEvents(ctx, start, end)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("今天是 %s。\n", now.Format("2006-01-02")))
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		sb.WriteString(h.describeDay(ctx, d))
+		if eventNote == "" {
+			sb.WriteString("；日程: ")
+			sb.WriteString(describeEvents(events, d, d.AddDate(0, 0, 1)))
+		}
+		sb.WriteString("\n")
+	}
+	if eventNote != "" {
+		sb.WriteString(eventNote)
+	}
+	return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: strings.TrimSpace(sb.String())}
+}
+
+// describeDay 描述某天的星期、农历、节日节气与是否放假
+func (h *ConnectionHandler) describeDay(ctx context.Context, d time.Time) string {
+	parts := []string{
+		fmt.Sprintf("%s %s", d.Format("2006-01-02"), weekdayNames[d.Weekday()]),
+		"农历" + calendar.Lunar(d).String(),
+	}
+	if festival := calendar.LunarFestival(d); festival != "" {
+		parts = append(parts, festival)
+	}
+	if term := calendar.SolarTerm(d); term != "" {
+		parts = append(parts, "节气"+term)
+	}
+
+	weekend := d.Weekday() == time.Saturday || d.Weekday() == time.Sunday
+	var holiday calendar.Holiday
+	var found bool
+	var err error
+	if h.holidays != nil {
+		holiday, found, err = h.holidays.Lookup(ctx, d)
+	}
+	switch {
+	case err != nil:
+		h.logger.Warn(fmt.Sprintf("查询法定节假日失败: %v", err))
+		if weekend {
+			parts = append(parts, "周末（未获取到法定节假日安排，可能有调休）")
+		} else {
+			parts = append(parts, "工作日（未获取到法定节假日安排）")
+		}
+	case found && holiday.Off:
+		parts = append(parts, fmt.Sprintf("法定节假日放假（%s）", holiday.Name))
+	case found:
+		parts = append(parts, fmt.Sprintf("调休上班（%s）", holiday.Name))
+	case weekend:
+		parts = append(parts, "周末休息")
+	default:
+		parts = append(parts, "工作日")
+	}
+	return strings.Join(parts, "，")
+}
+
+// calendarEvents 拉取设备绑定日历在 [start, end) 内的日程
+// 返回的说明不为空时表示没有可用的日程数据
+func (h *ConnectionHandler) calendarEvents(ctx context.Context, start, end time.Time) ([]calendar.Event, string) {
+	device, ok := h.config.Devices[h.deviceID]
+	if h.deviceID == "" || !ok || len(device.Calendars) == 0 {
+		return nil, "设备未绑定日程日历，无法查询日程。"
+	}
+	var events []calendar.Event
+	var failed []string
+	for _, source := range device.Calendars {
+		list, err := calendar.FetchEvents(ctx, source, start, end, start.Location())
+		if err != nil {
+			h.logger.Warn(fmt.Sprintf("拉取日历失败: %s, %v", source.Name, err))
+			failed = append(failed, source.Name)
+			continue
+		}
+		events = append(events, list...)
+	}
+	if len(failed) == len(device.Calendars) {
+		return nil, "日程日历暂时无法访问，无法查询日程。"
+	}
+	if len(failed) > 0 {
+		h.logger.Info(fmt.Sprintf("部分日历未能读取: %s", strings.Join(failed, "、")))
+	}
+	return events, ""
+}
+
+// describeEvents 列出与 [from, to) 有交集的日程
+func describeEvents(events []calendar.Event, from, to time.Time) string {
+	var items []string
+	for _, e := range events {
+		if !e.Start.Before(to) || !e.End.After(from) {
+			continue
+		}
+		var when string
+		switch {
+		case e.AllDay:
+			when = "全天"
+		case e.End.After(to) || e.Start.Before(from):
+			when = fmt.Sprintf("%s 至 %s", e.Start.Format("01-02 15:04"), e.End.Format("01-02 15:04"))
+		default:
+			when = fmt.Sprintf("%s-%s", e.Start.Format("15:04"), e.End.Format("15:04"))
+		}
+		item := when + " " + e.Summary
+		if e.Location != "" {
+			item += "（" + e.Location + "）"
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return "无"
+	}
+	return strings.Join(items, "；")
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// 字幕消息的说话方
+const (
+	captionRoleUser      = "user"
+	captionRoleAssistant = "assistant"
+)
+
+// sendCaption 下发统一格式的字幕，仅声明了 caption 的客户端接收
+// 用户字幕 index 为 0，final 为 false 时是流式识别的中间结果；
+// 助手字幕按分段 index 逐段下发，本轮结束时再下发一条 final 为 true 的完整文本，被打断时为已播出的部分
+func (h *ConnectionHandler) sendCaption(role string, round, index int, text string, final bool) {
+	if !h.caps.has(FeatureCaption) {
+		return
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"type":       "caption",
+		"role":       role,
+		"round":      round,
+		"index":      index,
+		"text":       text,
+		"final":      final,
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("序列化字幕消息失败: %v", err))
+		return
+	}
+	if err := h.conn.WriteMessage(1, data); err != nil {
+		h.logger.Error(fmt.Sprintf("发送字幕消息失败: %v", err))
+	}
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/dnd"
+)
+
+const (
+	careCheckInterval = 30 * time.Second // 主动关怀的检查间隔
+	careLLMTimeout    = 10 * time.Second // LLM 生成关怀语的最长等待时间
+)
+
+// careLimiter 主动关怀频控，所有连接共享，设备断线重连后仍然生效
+type careLimiter struct {
+	mu      sync.Mutex
+	records map[string]*careRecord
+}
+
+type careRecord struct {
+	last  time.Time
+	day   string // 计数所属的日期
+	count int
+}
+
+func newCareLimiter() *careLimiter {
+	return &careLimiter{records: make(map[string]*careRecord)}
+}
+
+// allow 检查并记录一次主动关怀，距上次不足 minInterval 或当天次数已满时返回 false
+func (l *careLimiter) allow(key string, now time.Time, minInterval time.Duration, maxPerDay int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r, ok := l.records[key]
+	if !ok {
+		r = &careRecord{}
+		l.records[key] = r
+	}
+	day := now.Format("2006-01-02")
+	if r.day != day {
+		r.day, r.count = day, 0
+	}
+	if !r.last.IsZero() && now.Sub(r.last) < minInterval {
+		return false
+	}
+	if maxPerDay > 0 && r.count >= maxPerDay {
+		return false
+	}
+	r.last = now
+	r.count++
+	return true
+}
+
+// careConfig 返回本连接生效的主动关怀配置，设备配置了 care 时整体覆盖
+func (h *ConnectionHandler) careConfig() configs.CareConfig {
+	if device, ok := h.config.Devices[h.deviceID]; ok && h.deviceID != "" && device.Care != nil {
+		return *device.Care
+	}
+	return h.config.Care
+}
+
+// careAllowedAt 当前时间是否允许主动关怀
+func careAllowedAt(cfg configs.CareConfig, now time.Time) bool {
+	if inClockRanges(cfg.QuietHours, now) {
+		return false
+	}
+	return len(cfg.ActiveHours) == 0 || inClockRanges(cfg.ActiveHours, now)
+}
+
+// careWatchCoroutine 主动关怀协程，服务端空闲且超过 idle_after 没有交互时主动播报
+func (h *ConnectionHandler) careWatchCoroutine() {
+	cfg := h.careConfig()
+	if !cfg.Enabled || h.careLimiter == nil {
+		return
+	}
+	idleAfter := utils.ParseTimeout(cfg.IdleAfter, 0)
+	if idleAfter <= 0 {
+		h.logger.Warn(fmt.Sprintf("主动关怀 idle_after 配置无效: %s，不主动关怀", cfg.IdleAfter))
+		return
+	}
+	minInterval := utils.ParseTimeout(cfg.MinInterval, idleAfter)
+	key := h.deviceID
+	if key == "" {
+		key = h.sessionID
+	}
+
+	ticker := time.NewTicker(careCheckInterval)
+	defer ticker.Stop()
+	next := 0
+	for {
+		select {
+		case <-h.stopChan:
+			return
+		case <-ticker.C:
+			if state := h.voice.State(); state == VoiceSpeaking || state == VoiceClosing {
+				continue
+			}
+			now := h.now()
+			if now.Sub(time.Unix(0, h.lastActiveTime.Load())) < idleAfter || !careAllowedAt(cfg, now) {
+				continue
+			}
+			if dnd.Check(h.deviceID, now) != nil {
+				continue
+			}
+			if !h.careLimiter.allow(key, now, minInterval, cfg.MaxPerDay) {
+				continue
+			}
+			text := h.careText(cfg, now, next)
+			next++
+			if text == "" {
+				continue
+			}
+			h.logger.Info(fmt.Sprintf("主动关怀: %s", text))
+			h.touchActiveTime()
+			if err := h.proactiveSpeak(text); err != nil {
+				h.logger.Error(fmt.Sprintf("播报主动关怀失败: %v", err))
+			}
+		}
+	}
+}
+
+// careText 生成关怀语：配置了 prompt 时由 LLM 生成，失败或未配置时轮换 messages
+func (h *ConnectionHandler) careText(cfg configs.CareConfig, now time.Time, index int) string {
+	if cfg.Prompt != "" && h.providers.llm != nil {
+		text, err := h.generateCareText(cfg.Prompt, now)
+		if err == nil && text != "" {
+			return text
+		}
+		h.logger.Warn(fmt.Sprintf("LLM 生成关怀语失败，使用预设关怀语: %v", err))
+	}
+	if len(cfg.Messages) == 0 {
+		return ""
+	}
+	text, err := renderTimeTemplate(cfg.Messages[index%len(cfg.Messages)], now)
+	if err != nil {
+		h.logger.Warn(err.Error())
+		return ""
+	}
+	return text
+}
+
+func (h *ConnectionHandler) generateCareText(prompt string, now time.Time) (string, error) {
+	prompt, err := renderTimeTemplate(prompt, now)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), careLLMTimeout)
+	defer cancel()
+	messages := []providers.Message{
+		{Role: "system", Content: h.config.DefaultPrompt},
+		{Role: "user", Content: prompt},
+	}
+	responseChan, err := h.providers.llm.Response(ctx, h.sessionID, messages)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for content := range responseChan {
+		sb.WriteString(content)
+	}
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	clarifyTimeout       = 30 * time.Second // 追问后等待用户回答的时长
+	clarifyTimeoutPrompt = "那我先不等了，想好了再告诉我吧。"
+)
+
+// registerClarifyFunction 注册 ask_user，LLM 信息不足时向用户追问而不是让任务失败
+func (h *ConnectionHandler) registerClarifyFunction() {
+	tool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name: "ask_user",
+			Description: "完成用户的请求还缺少必要信息时调用，向用户追问一个简短的问题并等待回答，不要自行猜测缺少的信息；" +
+				"用户的回答会随下一条消息给出，届时结合回答继续完成原来的任务",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"question": map[string]interface{}{
+						"type":        "string",
+						"description": "要追问的问题，口语化的一句话，如“你想定几点的闹钟？”",
+					},
+					"options": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "可选的候选答案，会一并念给用户",
+					},
+				},
+				"required": []string{"question"},
+			},
+		},
+	}
+	if err := h.functionRegister.RegisterLocalFunction("ask_user", tool, h.handleAskUser); err != nil {
+		h.logger.Error(fmt.Sprintf("注册本地函数失败: ask_user, 错误: %v", err))
+	}
+}
+
+func (h *ConnectionHandler) handleAskUser(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	question, _ := args["question"].(string)
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return types.ActionResponse{Action: types.ActionTypeError, Result: "question 不能为空"}
+	}
+	spoken := question
+	if raw, ok := args["options"].([]interface{}); ok && len(raw) > 0 {
+		options := make([]string, 0, len(raw))
+		for _, option := range raw {
+			if text, ok := option.(string); ok && strings.TrimSpace(text) != "" {
+				options = append(options, strings.TrimSpace(text))
+			}
+		}
+		if len(options) > 0 {
+			spoken += "可以选" + strings.Join(options, "、")
+		}
+	}
+	h.setPendingQuestion(question)
+	h.logger.Info(fmt.Sprintf("向用户追问: %s，等待回答 %v", question, clarifyTimeout))
+	return types.ActionResponse{Action: types.ActionTypeResponse, Response: spoken}
+}
+
+// setPendingQuestion 记录待回答的追问，超时未回答时放弃
+func (h *ConnectionHandler) setPendingQuestion(question string) {
+	h.clarifyMu.Lock()
+	defer h.clarifyMu.Unlock()
+	if h.clarifyTimer != nil {
+		h.clarifyTimer.Stop()
+	}
+	h.pendingQuestion = question
+	var timer *time.Timer
+	timer = time.AfterFunc(clarifyTimeout, func() { h.clarifyTimedOut(timer) })
+	h.clarifyTimer = timer
+}
+
+// clarifyTimedOut 追问超时：清除待回答状态，服务端空闲时告知用户不再等待
+func (h *ConnectionHandler) clarifyTimedOut(timer *time.Timer) {
+	h.clarifyMu.Lock()
+	if h.clarifyTimer != timer {
+		h.clarifyMu.Unlock()
+		return
+	}
+	question := h.pendingQuestion
+	h.pendingQuestion, h.clarifyTimer = "", nil
+	h.clarifyMu.Unlock()
+
+	h.logger.Info(fmt.Sprintf("追问超时未得到回答: %s", question))
+	select {
+	case <-h.stopChan:
+		return
+	default:
+	}
+	if h.voice.State() == VoiceSpeaking || h.voice.State() == VoiceClosing {
+		return
+	}
+	if err := h.proactiveSpeak(clarifyTimeoutPrompt); err != nil {
+		h.logger.Error(fmt.Sprintf("播报追问超时提示失败: %v", err))
+	}
+}
+
+// withClarification 有待回答的追问时，把用户输入标注为对追问的回答后写入对话历史
+func (h *ConnectionHandler) withClarification(text string) string {
+	h.clarifyMu.Lock()
+	question := h.pendingQuestion
+	if h.clarifyTimer != nil {
+		h.clarifyTimer.Stop()
+	}
+	h.pendingQuestion, h.clarifyTimer = "", nil
+	h.clarifyMu.Unlock()
+	if question == "" {
+		return text
+	}
+	h.logger.Info(fmt.Sprintf("收到追问的回答: %s -> %s", question, text))
+	return fmt.Sprintf("%s\n（这是对你刚才追问“%s”的回答，请结合它继续完成之前的任务）", text, question)
+}
@@ -0,0 +1,289 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 设备端 MCP 工具名称，与官方固件保持一致
+const (
+	deviceToolStatus    = "self.get_device_status"
+	deviceToolSetVolume = "self.audio_speaker.set_volume"
+	deviceToolSetBright = "self.screen.set_brightness"
+	deviceToolSetTheme  = "self.screen.set_theme"
+)
+
+// deviceStatus self.get_device_status 返回的设备状态，只解析用到的字段
+type deviceStatus struct {
+	AudioSpeaker struct {
+		Volume *int `json:"volume"`
+	} `json:"audio_speaker"`
+	Screen struct {
+		Brightness *int   `json:"brightness"`
+		Theme      string `json:"theme"`
+	} `json:"screen"`
+}
+
+// registerDeviceFunctions 注册转发到设备端 MCP 工具的本地函数，结果整理为口语化的回复直接播报
+func (h *ConnectionHandler) registerDeviceFunctions() {
+	functions := []struct {
+		tool    openai.Tool
+		handler func(ctx context.Context, args map[string]interface{}) types.ActionResponse
+	}{
+		{getVolumeTool(), h.handleGetVolume},
+		{setVolumeTool(), h.handleSetVolume},
+		{setBrightnessTool(), h.handleSetBrightness},
+		{setThemeTool(), h.handleSetTheme},
+	}
+	for _, f := range functions {
+		if err := h.functionRegister.RegisterLocalFunction(f.tool.Function.Name, f.tool, f.handler); err != nil {
+			h.logger.Error(fmt.Sprintf("注册本地函数失败: %s, 错误: %v", f.tool.Function.Name, err))
+		}
+	}
+}
+
+func getVolumeTool() openai.Tool {
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "get_volume",
+			Description: "查询设备当前的音量（0~100），用户问现在音量多大时调用",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+	}
+}
+
+func setVolumeTool() openai.Tool {
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name: "set_volume",
+			Description: "调节设备音量。用户说出具体音量时传 volume；说大声点、小声点等相对调节时传 delta，" +
+				"如大一点传 10，小一点传 -10。音量相关的请求优先使用本函数",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"volume": map[string]interface{}{
+						"type":        "integer",
+						"description": "目标音量，0~100",
+					},
+					"delta": map[string]interface{}{
+						"type":        "integer",
+						"description": "在当前音量基础上的调整量，正数调大，负数调小",
+					},
+				},
+			},
+		},
+	}
+}
+
+func setBrightnessTool() openai.Tool {
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name: "set_screen_brightness",
+			Description: "调节设备屏幕亮度。用户说出具体亮度时传 brightness；说调亮一点、调暗一点等相对调节时传 delta，" +
+				"如亮一点传 10，暗一点传 -10",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"brightness": map[string]interface{}{
+						"type":        "integer",
+						"description": "目标亮度，0~100",
+					},
+					"delta": map[string]interface{}{
+						"type":        "integer",
+						"description": "在当前亮度基础上的调整量，正数调亮，负数调暗",
+					},
+				},
+			},
+		},
+	}
+}
+
+func setThemeTool() openai.Tool {
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "set_screen_theme",
+			Description: "切换设备屏幕主题，用户说切换到深色模式、夜间模式或浅色模式时调用",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"theme": map[string]interface{}{
+						"type":        "string",
+						"description": "light 为浅色主题，dark 为深色主题",
+						"enum":        []string{"light", "dark"},
+					},
+				},
+				"required": []string{"theme"},
+			},
+		},
+	}
+}
+
+// callDeviceTool 调用设备端 MCP 工具，返回工具输出的文本
+func (h *ConnectionHandler) callDeviceTool(ctx context.Context, name string, args map[string]interface{}) (string, error) {
+	if h.mcpManager == nil {
+		return "", fmt.Errorf("设备未连接 MCP")
+	}
+	result, err := h.mcpManager.ExecuteTool(ctx, name, args)
+	if err != nil {
+		return "", err
+	}
+	text, _ := result.(string)
+	return text, nil
+}
+
+// deviceStatus 查询设备当前状态
+func (h *ConnectionHandler) deviceStatus(ctx context.Context) (*deviceStatus, error) {
+	text, err := h.callDeviceTool(ctx, deviceToolStatus, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	var status deviceStatus
+	if err := json.Unmarshal([]byte(text), &status); err != nil {
+		return nil, fmt.Errorf("解析设备状态失败: %v", err)
+	}
+	return &status, nil
+}
+
+// deviceVolume 读取设备当前音量
+func (h *ConnectionHandler) deviceVolume(ctx context.Context) (int, error) {
+	status, err := h.deviceStatus(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if status.AudioSpeaker.Volume == nil {
+		return 0, fmt.Errorf("设备状态中没有音量信息")
+	}
+	return *status.AudioSpeaker.Volume, nil
+}
+
+// deviceBrightness 读取设备当前屏幕亮度
+func (h *ConnectionHandler) deviceBrightness(ctx context.Context) (int, error) {
+	status, err := h.deviceStatus(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if status.Screen.Brightness == nil {
+		return 0, fmt.Errorf("设备状态中没有屏幕亮度信息")
+	}
+	return *status.Screen.Brightness, nil
+}
+
+// targetPercent 根据绝对值参数 key 或相对调整量 delta 计算目标百分比，相对调整时读取当前值
+// ok 为 false 表示两种参数都没有传
+func targetPercent(ctx context.Context, args map[string]interface{}, key string, current func(context.Context) (int, error)) (value int, ok bool, err error) {
+	value, hasValue := intArg(args, key)
+	delta, hasDelta := intArg(args, "delta")
+	if !hasValue && !hasDelta {
+		return 0, false, nil
+	}
+	if !hasValue {
+		now, err := current(ctx)
+		if err != nil {
+			return 0, true, err
+		}
+		value = now + delta
+	}
+	return clampPercent(value), true, nil
+}
+
+func (h *ConnectionHandler) handleGetVolume(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	volume, err := h.deviceVolume(ctx)
+	if err != nil {
+		h.logger.Warn(fmt.Sprintf("查询设备音量失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "暂时没能查到音量，稍后再试试吧"}
+	}
+	return types.ActionResponse{Action: types.ActionTypeResponse, Response: fmt.Sprintf("现在的音量是%d", volume)}
+}
+
+func (h *ConnectionHandler) handleSetVolume(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	volume, ok, err := targetPercent(ctx, args, "volume", h.deviceVolume)
+	if !ok {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "想把音量调到多少呢？"}
+	}
+	if err != nil {
+		h.logger.Warn(fmt.Sprintf("查询设备音量失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "暂时没能调节音量，稍后再试试吧"}
+	}
+
+	if _, err := h.callDeviceTool(ctx, deviceToolSetVolume, map[string]interface{}{"volume": volume}); err != nil {
+		h.logger.Warn(fmt.Sprintf("设置设备音量失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "暂时没能调节音量，稍后再试试吧"}
+	}
+	h.logger.Info(fmt.Sprintf("设备音量设置为 %d", volume))
+	switch volume {
+	case 0:
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "好的，已经静音了"}
+	case 100:
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "好的，音量已经调到最大了"}
+	default:
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: fmt.Sprintf("好的，音量已经调到%d了", volume)}
+	}
+}
+
+func (h *ConnectionHandler) handleSetBrightness(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	brightness, ok, err := targetPercent(ctx, args, "brightness", h.deviceBrightness)
+	if !ok {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "想把屏幕亮度调到多少呢？"}
+	}
+	if err != nil {
+		h.logger.Warn(fmt.Sprintf("查询屏幕亮度失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "暂时没能调节屏幕亮度，稍后再试试吧"}
+	}
+
+	if _, err := h.callDeviceTool(ctx, deviceToolSetBright, map[string]interface{}{"brightness": brightness}); err != nil {
+		h.logger.Warn(fmt.Sprintf("设置屏幕亮度失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "暂时没能调节屏幕亮度，稍后再试试吧"}
+	}
+	h.logger.Info(fmt.Sprintf("屏幕亮度设置为 %d", brightness))
+	return types.ActionResponse{Action: types.ActionTypeResponse, Response: fmt.Sprintf("好的，屏幕亮度已经调到%d了", brightness)}
+}
+
+func (h *ConnectionHandler) handleSetTheme(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	theme, _ := args["theme"].(string)
+	names := map[string]string{"light": "浅色", "dark": "深色"}
+	name, ok := names[theme]
+	if !ok {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "目前只能切换浅色或深色主题哦"}
+	}
+	if _, err := h.callDeviceTool(ctx, deviceToolSetTheme, map[string]interface{}{"theme": theme}); err != nil {
+		h.logger.Warn(fmt.Sprintf("切换屏幕主题失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "暂时没能切换主题，稍后再试试吧"}
+	}
+	h.logger.Info(fmt.Sprintf("屏幕主题切换为 %s", theme))
+	return types.ActionResponse{Action: types.ActionTypeResponse, Response: fmt.Sprintf("好的，已经切换成%s主题了", name)}
+}
+
+// intArg 读取 LLM 传入的整数参数，JSON 数字解析为 float64
+func intArg(args map[string]interface{}, key string) (int, bool) {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// clampPercent 将百分比限制在 0~100
+func clampPercent(v int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > 100 {
+		return 100
+	}
+	return v
+}
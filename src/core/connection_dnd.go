@@ -0,0 +1,173 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/dnd"
+	"xiaozhi-server-go/src/reminder"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// registerDNDFunctions 注册开启与关闭免打扰的本地函数
+func (h *ConnectionHandler) registerDNDFunctions() {
+	functions := []struct {
+		tool    openai.Tool
+		handler func(ctx context.Context, args map[string]interface{}) types.ActionResponse
+	}{
+		{setDNDTool(), h.handleSetDND},
+		{cancelDNDTool(), h.handleCancelDND},
+	}
+	for _, f := range functions {
+		if err := h.functionRegister.RegisterLocalFunction(f.tool.Function.Name, f.tool, f.handler); err != nil {
+			h.logger.Error(fmt.Sprintf("注册本地函数失败: %s, 错误: %v", f.tool.Function.Name, err))
+		}
+	}
+}
+
+func setDNDTool() openai.Tool {
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name: "set_do_not_disturb",
+			Description: "开启免打扰，期间不主动播报，到点的提醒延后或静默。临时免打扰（如“今晚别吵我”“两小时内别打扰我”）传 delay 或 until_time；" +
+				"每天固定的免打扰时段（如“每天晚上十点到早上七点别打扰我”）传 start 和 end",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"delay": map[string]interface{}{
+						"type":        "string",
+						"description": "从现在起免打扰的时长，格式如 2h、30m",
+					},
+					"until_time": map[string]interface{}{
+						"type":        "string",
+						"description": "免打扰到什么时刻结束，24 小时制 HH:MM，“今晚别吵我”可传 07:00",
+					},
+					"day_offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "until_time 相对今天的天数，明天为 1；不传时已过的时刻顺延到明天",
+					},
+					"start": map[string]interface{}{
+						"type":        "string",
+						"description": "每天免打扰的开始时刻 HH:MM",
+					},
+					"end": map[string]interface{}{
+						"type":        "string",
+						"description": "每天免打扰的结束时刻 HH:MM",
+					},
+					"reminder_mode": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{dnd.ReminderDelay, dnd.ReminderSilent},
+						"description": "免打扰期间到点的提醒：delay 延后到结束后播报，silent 不再播报，默认 delay",
+					},
+				},
+			},
+		},
+	}
+}
+
+func cancelDNDTool() openai.Tool {
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "cancel_do_not_disturb",
+			Description: "关闭免打扰。默认只结束临时免打扰，用户要求连每天的固定时段也取消时 all 传 true",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"all": map[string]interface{}{
+						"type":        "boolean",
+						"description": "是否同时取消每天固定的免打扰时段",
+					},
+				},
+			},
+		},
+	}
+}
+
+func (h *ConnectionHandler) handleSetDND(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	if h.deviceID == "" {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "这台设备暂时不能设置免打扰哦"}
+	}
+	setting, err := dnd.Get(h.deviceID)
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("查询免打扰设置失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "免打扰没能设置成功，稍后再试试吧"}
+	}
+	if setting == nil {
+		setting = &dnd.Setting{DeviceID: h.deviceID}
+	}
+
+	now := h.now()
+	delay, _ := args["delay"].(string)
+	clock, _ := args["until_time"].(string)
+	start, _ := args["start"].(string)
+	end, _ := args["end"].(string)
+	if mode, ok := args["reminder_mode"].(string); ok && mode != "" {
+		setting.ReminderMode = mode
+	}
+
+	var reply string
+	switch {
+	case delay != "" || clock != "":
+		dayOffset, _ := intArg(args, "day_offset")
+		until, err := reminder.ResolveTime(now, delay, clock, dayOffset)
+		if err != nil {
+			h.logger.Warn(fmt.Sprintf("解析免打扰截止时间失败: %v, 参数: %v", err, args))
+			return types.ActionResponse{Action: types.ActionTypeResponse, Response: "没听清免打扰到什么时候，可以再说一次吗？"}
+		}
+		setting.Until = &until
+		reply = fmt.Sprintf("好的，%s之前我不会打扰你", reminder.Describe(now, until))
+	case start != "" && end != "":
+		setting.Start, setting.End = start, end
+		reply = fmt.Sprintf("好的，以后每天%s到%s我都不会打扰你", start, end)
+	default:
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "要免打扰到什么时候呢？"}
+	}
+
+	if _, err := dnd.Save(setting); err != nil {
+		h.logger.Warn(fmt.Sprintf("保存免打扰设置失败: %v, 参数: %v", err, args))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "免打扰没能设置成功，时间可以再说一次吗？"}
+	}
+	h.logger.Info(fmt.Sprintf("设备 %s 开启免打扰, 参数: %v", h.deviceID, args))
+	if setting.ReminderMode == dnd.ReminderSilent {
+		reply += "，期间的提醒也不会再响"
+	}
+	return types.ActionResponse{Action: types.ActionTypeResponse, Response: reply}
+}
+
+func (h *ConnectionHandler) handleCancelDND(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	if h.deviceID == "" {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "这台设备没有设置免打扰"}
+	}
+	setting, err := dnd.Get(h.deviceID)
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("查询免打扰设置失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "免打扰没能关闭，稍后再试试吧"}
+	}
+	if setting == nil {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "现在没有开启免打扰哦"}
+	}
+
+	all, _ := args["all"].(bool)
+	if all || setting.Start == "" {
+		err = dnd.Delete(h.deviceID)
+	} else {
+		setting.Until = nil
+		_, err = dnd.Save(setting)
+	}
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("关闭免打扰失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "免打扰没能关闭，稍后再试试吧"}
+	}
+	h.logger.Info(fmt.Sprintf("设备 %s 关闭免打扰, 全部: %t", h.deviceID, all))
+	if !all && setting.Start != "" {
+		return types.ActionResponse{
+			Action:   types.ActionTypeResponse,
+			Response: fmt.Sprintf("好的，已经关闭免打扰，每天%s到%s的免打扰时段还保留着", setting.Start, setting.End),
+		}
+	}
+	return types.ActionResponse{Action: types.ActionTypeResponse, Response: "好的，已经关闭免打扰"}
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/audit"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/history"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// registerForgetFunction 注册 forget_conversation，用户要求忘掉刚才的对话时清空会话历史
+func (h *ConnectionHandler) registerForgetFunction() {
+	tool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "forget_conversation",
+			Description: "用户要求忘掉刚才聊过的内容时调用，如“忘掉我们刚才聊的”“把刚才的对话忘了”，清空本次对话的上下文，之前的内容不再参考",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+	}
+	if err := h.functionRegister.RegisterLocalFunction("forget_conversation", tool, h.handleForgetConversation); err != nil {
+		h.logger.Error(fmt.Sprintf("注册本地函数失败: forget_conversation, 错误: %v", err))
+	}
+}
+
+func (h *ConnectionHandler) handleForgetConversation(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	removed := h.dialogueManager.ClearKeepSystem()
+	h.historyRound = 0
+	h.setPendingPages(nil)
+	h.clarifyMu.Lock()
+	if h.clarifyTimer != nil {
+		h.clarifyTimer.Stop()
+	}
+	h.pendingQuestion, h.clarifyTimer = "", nil
+	h.clarifyMu.Unlock()
+	h.markForget()
+
+	actor := "device:" + h.deviceID
+	detail := fmt.Sprintf("会话 %s 清除对话历史 %d 条", h.sessionID, removed)
+	if err := audit.Record(actor, audit.ActionForgetConversation, h.deviceID, detail); err != nil {
+		h.logger.Error(err.Error())
+	}
+	h.logger.Info(fmt.Sprintf("按用户要求清空对话历史: %s", detail))
+	return types.ActionResponse{Action: types.ActionTypeResponse, Response: "好的，刚才聊的我都忘掉了"}
+}
+
+// markForget 记录遗忘时间，之后重新连接时不再载入此前持久化的对话
+func (h *ConnectionHandler) markForget() {
+	if !h.historyEnabled() {
+		return
+	}
+	if err := history.MarkForget(h.deviceID, time.Now()); err != nil {
+		h.logger.Error(fmt.Sprintf("记录遗忘时间失败: %v", err))
+	}
+}
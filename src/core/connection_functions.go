@@ -0,0 +1,206 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/device"
+	"xiaozhi-server-go/src/profile"
+	"xiaozhi-server-go/src/voiceclone"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// registerLocalFunctions 注册在服务端本地执行的函数
+func (h *ConnectionHandler) registerLocalFunctions() {
+	if len(h.config.Roles) > 0 {
+		if err := h.functionRegister.RegisterLocalFunction("change_role", h.changeRoleTool(), h.handleChangeRole); err != nil {
+			h.logger.Error(fmt.Sprintf("注册本地函数失败: change_role, 错误: %v", err))
+		}
+	}
+	h.registerDeviceFunctions()
+	h.registerReminderFunctions()
+	h.registerSceneFunction()
+	h.registerClarifyFunction()
+	h.registerDNDFunctions()
+	h.registerTimeFunction()
+	h.registerCalendarFunction()
+	h.registerTranslationFunction()
+	h.registerTranscriptionFunction()
+	h.registerForgetFunction()
+	h.registerVoiceFunction()
+}
+
+// changeRoleTool 构造切换角色的函数描述，可选角色以枚举形式告知 LLM
+func (h *ConnectionHandler) changeRoleTool() openai.Tool {
+	names := make([]string, 0, len(h.config.Roles))
+	descriptions := make([]string, 0, len(h.config.Roles))
+	for _, role := range h.config.Roles {
+		names = append(names, role.Name)
+		if role.Description != "" {
+			descriptions = append(descriptions, fmt.Sprintf("%s: %s", role.Name, role.Description))
+		} else {
+			descriptions = append(descriptions, role.Name)
+		}
+	}
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "change_role",
+			Description: "当用户想切换角色、人设或让你扮演其他人物时调用，可选角色: " + strings.Join(descriptions, "; "),
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"role_name": map[string]interface{}{
+						"type":        "string",
+						"description": "要切换的角色名称",
+						"enum":        names,
+					},
+				},
+				"required": []string{"role_name"},
+			},
+		},
+	}
+}
+
+// findRole 按名称查找角色配置
+func (h *ConnectionHandler) findRole(name string) *configs.RoleConfig {
+	name = strings.TrimSpace(name)
+	for i := range h.config.Roles {
+		if h.config.Roles[i].Name == name {
+			return &h.config.Roles[i]
+		}
+	}
+	return nil
+}
+
+// handleChangeRole 切换角色：替换系统提示词，并将主TTS切换为角色绑定的音色
+func (h *ConnectionHandler) handleChangeRole(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	name, _ := args["role_name"].(string)
+	role := h.findRole(name)
+	if role == nil {
+		return types.ActionResponse{
+			Action:   types.ActionTypeResponse,
+			Response: fmt.Sprintf("没有找到叫%s的角色哦", name),
+		}
+	}
+
+	h.dialogueManager.SetSystemMessage(role.Prompt)
+	h.roleName = role.Name
+	if h.deviceID != "" {
+		if err := profile.SaveRole(h.deviceID, role.Name, role.Voice != ""); err != nil {
+			h.logger.Warn(fmt.Sprintf("保存设备 %s 的角色失败: %v", h.deviceID, err))
+		}
+	}
+	reply := fmt.Sprintf("好的，我现在是%s啦", role.Name)
+	if role.Voice != "" {
+		if err := h.setTTSVoice(role.Voice); err != nil {
+			h.logger.Warn(fmt.Sprintf("切换角色 %s 的音色 %s 失败: %v", role.Name, role.Voice, err))
+			reply += "，不过声音暂时没能换过来"
+		} else {
+			reply += "，声音也换好了"
+		}
+	}
+	h.logger.Info(fmt.Sprintf("切换角色: %s, 音色: %s", role.Name, role.Voice))
+	h.warmQuickReplies()
+	return types.ActionResponse{Action: types.ActionTypeResponse, Response: reply}
+}
+
+// setTTSVoice 切换当前连接主TTS的音色，连接结束归还资源池时恢复默认音色
+func (h *ConnectionHandler) setTTSVoice(voice string) error {
+	setter, ok := h.providers.tts.(tts.VoiceSetter)
+	if !ok {
+		return fmt.Errorf("当前TTS不支持切换音色")
+	}
+	if err := setter.SetVoice(voice); err != nil {
+		return err
+	}
+	h.ttsVoice = voice
+	return nil
+}
+
+// applyDevicePrompt 管理接口为设备设置了提示词时替换配置的提示词，之后恢复或切换的角色仍然优先
+func (h *ConnectionHandler) applyDevicePrompt() {
+	if h.deviceID == "" {
+		return
+	}
+	record, err := device.Get(h.deviceID)
+	if err != nil {
+		h.logger.Warn(fmt.Sprintf("查询设备 %s 的记录失败: %v", h.deviceID, err))
+		return
+	}
+	if record == nil || record.Prompt == "" {
+		return
+	}
+	h.dialogueManager.SetSystemMessage(record.Prompt)
+	h.logger.Info(fmt.Sprintf("设备 %s 使用管理接口设置的提示词", h.deviceID))
+}
+
+// applyDeviceProfile 按设备档案恢复上次切换的角色与音色，用户单独指定的音色优先于角色音色
+func (h *ConnectionHandler) applyDeviceProfile() {
+	if h.deviceID == "" {
+		return
+	}
+	record, err := profile.Get(h.deviceID)
+	if err != nil {
+		h.logger.Warn(fmt.Sprintf("查询设备 %s 的档案失败: %v", h.deviceID, err))
+		return
+	}
+	if record == nil {
+		return
+	}
+	if record.Role != "" {
+		if role := h.findRole(record.Role); role == nil {
+			h.logger.Warn(fmt.Sprintf("设备 %s 档案中的角色 %s 已不在配置中，使用默认提示词", h.deviceID, record.Role))
+		} else {
+			h.dialogueManager.SetSystemMessage(role.Prompt)
+			h.roleName = role.Name
+			if role.Voice != "" {
+				if err := h.setTTSVoice(role.Voice); err != nil {
+					h.logger.Warn(fmt.Sprintf("恢复角色 %s 的音色 %s 失败: %v", role.Name, role.Voice, err))
+				}
+			}
+			h.logger.Info(fmt.Sprintf("设备 %s 恢复上次的角色: %s", h.deviceID, role.Name))
+		}
+	}
+	// 音色随主TTS保存，切换了主TTS或音色已不在可切换列表中时不恢复
+	if record.Voice != "" && record.TTS == h.config.SelectedModule["TTS"] {
+		if !hasVoice(h.supportedVoices(), record.Voice) {
+			h.logger.Warn(fmt.Sprintf("设备 %s 档案中的音色 %s 已不可用，使用默认音色", h.deviceID, record.Voice))
+			return
+		}
+		if err := h.setTTSVoice(record.Voice); err != nil {
+			h.logger.Warn(fmt.Sprintf("恢复设备 %s 的音色 %s 失败: %v", h.deviceID, record.Voice, err))
+			return
+		}
+		h.logger.Info(fmt.Sprintf("设备 %s 恢复上次的音色: %s", h.deviceID, record.Voice))
+	}
+}
+
+// applyDeviceVoice 设备注册了克隆音色且与主TTS一致时，本连接使用克隆音色合成
+func (h *ConnectionHandler) applyDeviceVoice() {
+	if h.deviceID == "" {
+		return
+	}
+	record, err := voiceclone.GetDeviceVoice(h.deviceID)
+	if err != nil {
+		h.logger.Warn(fmt.Sprintf("查询设备 %s 的克隆音色失败: %v", h.deviceID, err))
+		return
+	}
+	if record == nil {
+		return
+	}
+	if record.TTS != h.config.SelectedModule["TTS"] {
+		h.logger.Warn(fmt.Sprintf("设备 %s 的克隆音色属于 %s，与当前主TTS不一致，使用默认音色", h.deviceID, record.TTS))
+		return
+	}
+	if err := h.setTTSVoice(record.Voice); err != nil {
+		h.logger.Warn(fmt.Sprintf("设备 %s 切换克隆音色失败: %v", h.deviceID, err))
+		return
+	}
+	h.logger.Info(fmt.Sprintf("设备 %s 使用克隆音色: %s", h.deviceID, record.Voice))
+}
@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
-	"sync/atomic"
+	"time"
 	"xiaozhi-server-go/src/core/chat"
 	"xiaozhi-server-go/src/core/image"
 	"xiaozhi-server-go/src/core/providers"
@@ -20,6 +20,20 @@ func (h *ConnectionHandler) handleMessage(messageType int, message []byte) error
 		h.clientTextQueue <- string(message)
 		return nil
 	case 2: // 二进制消息（音频数据）
+		frame, err := parseBinaryFrame(h.protocolVersion(), message)
+		if err != nil {
+			h.logger.Warn(fmt.Sprintf("解析二进制帧失败: %v", err))
+			return nil
+		}
+		if frame.Type == binaryTypeJSON {
+			h.clientTextQueue <- string(frame.Payload)
+			return nil
+		}
+		if frame.Timestamp != 0 {
+			h.lastClientRecv.Store(time.Now().UnixNano())
+			h.lastClientStamp.Store(int64(frame.Timestamp))
+		}
+		message = frame.Payload
 		if h.clientAudioFormat == "pcm" {
 			// 直接将PCM数据放入队列
 			h.clientAudioQueue <- message
@@ -92,7 +106,13 @@ func (h *ConnectionHandler) processClientTextMessage(ctx context.Context, text s
 	case "image":
 		return h.handleImageMessage(ctx, msgMap)
 	case "mcp":
+		if !h.caps.has(FeatureMCP) {
+			h.logger.Warn("客户端未声明 mcp 能力，忽略 MCP 消息")
+			return nil
+		}
 		return h.mcpManager.HandleXiaoZhiMCPMessage(msgMap)
+	case "network":
+		return h.handleNetworkMessage(msgMap)
 	default:
 		return fmt.Errorf("未知的消息类型: %s", msgType)
 	}
@@ -123,6 +143,11 @@ func (h *ConnectionHandler) handleVisionMessage(msgMap map[string]interface{}) e
 // 客户端会上传语音格式和采样率等信息
 func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) error {
 	h.logger.Info("收到客户端欢迎消息: " + fmt.Sprintf("%v", msgMap))
+	// 协商协议版本与能力，结果需要通过 hello 回复给客户端
+	resendHello := h.negotiateHello(msgMap)
+	if h.negotiatePlayback(msgMap) {
+		resendHello = true
+	}
 	// 获取客户端编码格式
 	if audioParams, ok := msgMap["audio_params"].(map[string]interface{}); ok {
 		if format, ok := audioParams["format"].(string); ok {
@@ -131,7 +156,7 @@ func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) er
 			if format == "pcm" {
 				// 客户端使用PCM格式，服务端也使用PCM格式
 				h.serverAudioFormat = "pcm"
-				h.sendHelloMessage()
+				resendHello = true
 			}
 		}
 		if sampleRate, ok := audioParams["sample_rate"].(float64); ok {
@@ -148,6 +173,15 @@ func (h *ConnectionHandler) handleHelloMessage(msgMap map[string]interface{}) er
 		}
 	}
 
+	if resendHello {
+		if err := h.sendHelloMessage(); err != nil {
+			h.logger.Error(fmt.Sprintf("回复欢迎消息失败: %v", err))
+		}
+	}
+	if h.config.Greeting.Enabled {
+		h.greetOnce.Do(func() { go h.speakGreeting() })
+	}
+
 	h.closeOpusDecoder()
 	// 初始化opus解码器
 	opusDecoder, err := utils.NewOpusDecoder(&utils.OpusDecoderConfig{
@@ -185,11 +219,27 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 		if h.client_asr_text != "" && h.clientListenMode == "manual" {
 			h.clientAbortChat()
 		}
-		h.clientVoiceStop = false
+		if h.clientListenMode == listenModeTranscribe {
+			if err := h.startTranscription(true); err != nil {
+				h.logger.Error(fmt.Sprintf("开始会议转写失败: %v", err))
+			}
+		}
+		h.voice.Fire(EventListenStart)
 		h.client_asr_text = ""
+		h.listenStopAt.Store(0)
+		h.touchVoiceTime()
 	case "stop":
-		h.clientVoiceStop = true
+		h.voice.Fire(EventListenStop)
+		h.listenStopAt.Store(time.Now().UnixNano())
+		if h.clientListenMode == listenModeTranscribe {
+			h.stopTranscription()
+		}
 		h.logger.Info("客户端停止语音识别")
+	case "continue":
+		// 分批播报暂停后，客户端按键等方式要求继续
+		if err := h.playNextPage(); err != nil {
+			h.logger.Warn(fmt.Sprintf("继续播报失败: %v", err))
+		}
 	case "detect":
 		// 检查是否包含图片数据
 		imageBase64, hasImage := msgMap["image"].(string)
@@ -219,6 +269,9 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 
 		} else if hasText && text != "" {
 			// 只有文本，使用普通LLM处理
+			if h.isWakeWord(text) {
+				return h.quickReply()
+			}
 			h.logger.Info("检测到纯文本消息，使用LLM处理", map[string]interface{}{
 				"text": text,
 			})
@@ -235,8 +288,8 @@ func (h *ConnectionHandler) handleListenMessage(msgMap map[string]interface{}) e
 // handleImageWithText 处理包含图片和文本的消息
 func (h *ConnectionHandler) handleImageWithText(ctx context.Context, imageData image.ImageData, text string) error {
 	// 增加对话轮次
-	h.talkRound++
-	currentRound := h.talkRound
+	currentRound := h.startRound()
+	h.dialogueRounds.Add(1)
 	h.logger.Info(fmt.Sprintf("开始新的图片对话轮次: %d", currentRound))
 
 	// 判断是否需要验证
@@ -281,7 +334,7 @@ func (h *ConnectionHandler) handleImageWithText(ctx context.Context, imageData i
 	})
 
 	// 重置语音状态，确保能够播放提示音
-	atomic.StoreInt32(&h.serverVoiceStop, 0)
+	h.voice.Fire(EventSpeakStart)
 
 	// 立即合成并播放提示音（使用索引0确保优先播放）
 	if err := h.SpeakAndPlay(immediateResponse, 0, currentRound); err != nil {
@@ -300,6 +353,7 @@ func (h *ConnectionHandler) handleImageWithText(ctx context.Context, imageData i
 		Role:    "user",
 		Content: userMessage,
 	})
+	h.recordHistory("user", userMessage)
 
 	// 获取对话历史（排除当前图片消息）
 	messages := make([]providers.Message, 0)
@@ -335,8 +389,8 @@ func (h *ConnectionHandler) handleIotMessage(msgMap map[string]interface{}) erro
 // handleImageMessage 处理图片消息
 func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[string]interface{}) error {
 	// 增加对话轮次
-	h.talkRound++
-	currentRound := h.talkRound
+	currentRound := h.startRound()
+	h.dialogueRounds.Add(1)
 	h.logger.Info(fmt.Sprintf("开始新的图片对话轮次: %d", currentRound))
 
 	// 判断是否需要验证
@@ -416,6 +470,7 @@ func (h *ConnectionHandler) handleImageMessage(ctx context.Context, msgMap map[s
 		Role:    "user",
 		Content: userMessage,
 	})
+	h.recordHistory("user", userMessage)
 
 	// 获取对话历史
 	messages := make([]providers.Message, 0)
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/history"
+)
+
+// historyEnabled 是否把本连接的对话写入数据库
+func (h *ConnectionHandler) historyEnabled() bool {
+	return h.config.History.Enabled && h.deviceID != ""
+}
+
+// recordHistory 把一条用户提问或助手回复写入会话记录，首条消息时创建会话
+// 返回消息ID，未启用或写入失败时为 0
+func (h *ConnectionHandler) recordHistory(role, content string) uint {
+	if content == "" {
+		return 0
+	}
+	return h.recordHistoryMessage(&history.Message{Role: role, Content: content})
+}
+
+// recordToolHistory 把一次工具往返写入会话记录：带 tool_calls 的助手消息及每个调用的结果
+func (h *ConnectionHandler) recordToolHistory(calls []types.ToolCall, results []string) {
+	if h.recordHistoryMessage(&history.Message{Role: "assistant", ToolCalls: calls}) == 0 {
+		return
+	}
+	for i, call := range calls {
+		h.recordHistoryMessage(&history.Message{Role: "tool", ToolCallID: call.ID, Content: results[i]})
+	}
+}
+
+// recordHistoryMessage 写入一条会话消息，首条消息时创建会话，返回消息ID，未启用或写入失败时为 0
+func (h *ConnectionHandler) recordHistoryMessage(message *history.Message) uint {
+	if !h.historyEnabled() {
+		return 0
+	}
+	h.historyMu.Lock()
+	defer h.historyMu.Unlock()
+	if !h.historyStarted {
+		if _, err := history.StartSession(h.sessionID, h.deviceID, h.clientIP, time.Now()); err != nil {
+			h.logger.Error(fmt.Sprintf("创建会话记录失败: %v", err))
+			return 0
+		}
+		h.historyStarted = true
+	}
+	message.SessionID = h.sessionID
+	if err := history.AddMessage(message); err != nil {
+		h.logger.Error(fmt.Sprintf("写入会话记录失败: %v", err))
+		return 0
+	}
+	return message.ID
+}
+
+// restoreHistory 设备重新连接时把最近几轮对话载入对话上下文，工具往返折叠为摘要
+func (h *ConnectionHandler) restoreHistory() {
+	turns := h.config.History.RestoreTurns
+	if !h.historyEnabled() || turns <= 0 {
+		return
+	}
+	var since time.Time
+	if within := utils.ParseTimeout(h.config.History.RestoreWithin, 0); within > 0 {
+		since = time.Now().Add(-within)
+	}
+	// 用户要求忘掉的对话重新连接后也不再载入
+	forgetAt, err := history.ForgetTime(h.deviceID)
+	if err != nil {
+		h.logger.Warn(fmt.Sprintf("查询设备 %s 的遗忘时间失败: %v", h.deviceID, err))
+		return
+	}
+	if forgetAt.After(since) {
+		since = forgetAt
+	}
+	messages, err := history.RecentMessages(h.deviceID, h.sessionID, turns, since)
+	if err != nil {
+		h.logger.Warn(fmt.Sprintf("载入设备 %s 的历史对话失败: %v", h.deviceID, err))
+		return
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	restored := chat.NewDialogueManager(h.logger, nil)
+	for _, msg := range messages {
+		restored.Put(chat.Message{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
+		})
+	}
+	restored.FoldToolCalls(h.config.ToolHistory.MaxResultChars)
+	// 上次断开时还没有被 LLM 消化的工具往返无法继续，丢弃
+	dialogue := restored.GetLLMDialogue()
+	for len(dialogue) > 0 {
+		last := dialogue[len(dialogue)-1]
+		if last.Role != "tool" && len(last.ToolCalls) == 0 {
+			break
+		}
+		dialogue = dialogue[:len(dialogue)-1]
+	}
+	for _, msg := range dialogue {
+		h.dialogueManager.Put(msg)
+	}
+	h.logger.Info(fmt.Sprintf("设备 %s 载入最近 %d 条历史对话", h.deviceID, len(dialogue)))
+}
+
+// updateHistory 修改已写入的消息，用于回复被打断后截断
+func (h *ConnectionHandler) updateHistory(id uint, content string) {
+	if id == 0 {
+		return
+	}
+	if err := history.UpdateMessage(id, content); err != nil {
+		h.logger.Error(fmt.Sprintf("更新会话记录失败: %v", err))
+	}
+}
+
+// endHistory 连接关闭时记录会话结束时间
+func (h *ConnectionHandler) endHistory() {
+	h.historyMu.Lock()
+	defer h.historyMu.Unlock()
+	if !h.historyStarted {
+		return
+	}
+	if err := history.EndSession(h.sessionID, time.Now()); err != nil {
+		h.logger.Error(fmt.Sprintf("更新会话记录失败: %v", err))
+	}
+}
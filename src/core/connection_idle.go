@@ -0,0 +1,70 @@
+package core
+
+import (
+	"fmt"
+	"time"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+// idleConfig 返回本连接生效的空闲超时与告别语，设备配置优先
+func (h *ConnectionHandler) idleConfig() (string, string) {
+	timeout, farewell := h.config.Server.IdleTimeout, h.config.Server.IdleFarewell
+	if device, ok := h.config.Devices[h.deviceID]; ok && h.deviceID != "" {
+		if device.IdleTimeout != "" {
+			timeout = device.IdleTimeout
+		}
+		if device.IdleFarewell != "" {
+			farewell = device.IdleFarewell
+		}
+	}
+	return timeout, farewell
+}
+
+// touchActiveTime 记录一次交互，重新开始空闲计时
+func (h *ConnectionHandler) touchActiveTime() {
+	h.lastActiveTime.Store(time.Now().UnixNano())
+}
+
+// lastActive 最近一次交互时间，尚无交互时为连接建立时间
+func (h *ConnectionHandler) lastActive() time.Time {
+	if at := h.lastActiveTime.Load(); at > 0 {
+		return time.Unix(0, at)
+	}
+	return h.connectedAt
+}
+
+// idleWatchCoroutine 会话空闲检测协程，超时后播报告别语并断开连接
+// 拾音期间持续上传的静音音频不算交互，只有识别结果、文本消息和服务端播报会刷新计时
+func (h *ConnectionHandler) idleWatchCoroutine() {
+	value, farewell := h.idleConfig()
+	if value == "" {
+		return
+	}
+	timeout := utils.ParseTimeout(value, 0)
+	if timeout <= 0 {
+		h.logger.Warn(fmt.Sprintf("会话空闲超时配置无效: %s，不检测空闲", value))
+		return
+	}
+
+	h.touchActiveTime()
+	ticker := time.NewTicker(silenceCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stopChan:
+			return
+		case <-ticker.C:
+			if state := h.voice.State(); state == VoiceSpeaking || state == VoiceClosing {
+				h.touchActiveTime()
+				continue
+			}
+			idle := time.Since(time.Unix(0, h.lastActiveTime.Load()))
+			if idle < timeout {
+				continue
+			}
+			h.logger.Info(fmt.Sprintf("会话空闲 %v，道别后关闭连接", idle.Round(time.Second)))
+			h.speakAndClose(farewell)
+			return
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 分批播报的默认参数
+const (
+	defaultPageChars    = 120
+	defaultPagingPrompt = "要继续吗？"
+)
+
+// defaultContinueWords 未配置 continue_words 时触发继续播报的肯定回答
+var defaultContinueWords = []string{"继续", "要", "好", "好的", "嗯", "是", "是的", "可以", "继续说", "接着说", "往下说"}
+
+// pageChars 返回每批至少播报的字数，未启用分批播报时返回 0
+func (h *ConnectionHandler) pageChars() int {
+	if !h.config.Paging.Enabled {
+		return 0
+	}
+	if h.config.Paging.PageChars > 0 {
+		return h.config.Paging.PageChars
+	}
+	return defaultPageChars
+}
+
+// pagingPrompt 返回暂停时的询问话术
+func (h *ConnectionHandler) pagingPrompt() string {
+	if h.config.Paging.Prompt != "" {
+		return h.config.Paging.Prompt
+	}
+	return defaultPagingPrompt
+}
+
+// splitPages 按段落把剩余回复分批，每批至少 pageChars 字
+func splitPages(text string, pageChars int) []string {
+	var pages []string
+	var page strings.Builder
+	for _, paragraph := range strings.Split(text, "\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		if page.Len() > 0 {
+			page.WriteString("\n")
+		}
+		page.WriteString(paragraph)
+		if utf8.RuneCountInString(page.String()) >= pageChars {
+			pages = append(pages, page.String())
+			page.Reset()
+		}
+	}
+	if page.Len() > 0 {
+		pages = append(pages, page.String())
+	}
+	return pages
+}
+
+// setPendingPages 保存暂停后尚未播报的内容
+func (h *ConnectionHandler) setPendingPages(pages []string) {
+	h.pagingMu.Lock()
+	h.pendingPages = pages
+	h.pagingMu.Unlock()
+}
+
+// isContinueReply 判断用户的回答是否为继续播报
+func (h *ConnectionHandler) isContinueReply(text string) bool {
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) || unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, text)
+	words := h.config.Paging.ContinueWords
+	if len(words) == 0 {
+		words = defaultContinueWords
+	}
+	for _, word := range words {
+		if text == word {
+			return true
+		}
+	}
+	return false
+}
+
+// continuePaging 有暂停的回复时处理用户输入：肯定回答播报下一批并返回 true，
+// 其他输入放弃剩余内容并返回 false，按普通对话处理
+func (h *ConnectionHandler) continuePaging(text string) bool {
+	h.pagingMu.Lock()
+	pending := len(h.pendingPages) > 0
+	h.pagingMu.Unlock()
+	if !pending {
+		return false
+	}
+	if !h.isContinueReply(text) {
+		h.logger.Info("用户未要求继续，放弃剩余的分批回复")
+		h.setPendingPages(nil)
+		return false
+	}
+	if err := h.sendSTTMessage(text); err != nil {
+		h.logger.Error(fmt.Sprintf("发送STT消息失败: %v", err))
+	}
+	if err := h.playNextPage(); err != nil {
+		h.logger.Error(fmt.Sprintf("继续播报失败: %v", err))
+	}
+	return true
+}
+
+// playNextPage 播报下一批回复并追加到历史中的这条回复，之后还有剩余内容时再次询问是否继续
+func (h *ConnectionHandler) playNextPage() error {
+	h.pagingMu.Lock()
+	if len(h.pendingPages) == 0 {
+		h.pagingMu.Unlock()
+		return fmt.Errorf("没有待播报的内容")
+	}
+	page := h.pendingPages[0]
+	h.pendingPages = h.pendingPages[1:]
+	texts := []string{page}
+	if len(h.pendingPages) > 0 {
+		texts = append(texts, h.pagingPrompt())
+	}
+	h.pagingMu.Unlock()
+
+	round := h.startRound()
+	h.logger.Info(fmt.Sprintf("继续播报分批回复, round: %d", round))
+	h.voice.Fire(EventSpeakStart)
+	if err := h.sendTTSMessage("start", "", 0); err != nil {
+		return err
+	}
+	h.tts_last_text_index.Store(int64(len(texts)))
+	for i, text := range texts {
+		if err := h.SpeakAndPlay(text, i+1, round); err != nil {
+			h.logger.Error(fmt.Sprintf("播放分批回复失败: %v", err))
+			if i == len(texts)-1 {
+				h.sendTTSMessage("stop", "", 0)
+				h.clearSpeakStatus()
+			}
+		}
+	}
+	h.appendAssistantReply(page, round)
+	return nil
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	defaultPreBufferFrames = 3
+	maxPreBufferFrames     = 50
+	maxSendAhead           = 2 * time.Second
+)
+
+// initPlayback 按配置初始化下发音频的帧时长、预缓冲帧数与发送提前量
+func (h *ConnectionHandler) initPlayback() {
+	cfg := h.config.AudioOutput
+	h.serverAudioFrameDuration = 60
+	if cfg.FrameDuration != 0 {
+		if utils.ValidOpusFrameDuration(cfg.FrameDuration) {
+			h.serverAudioFrameDuration = cfg.FrameDuration
+		} else {
+			h.logger.Warn(fmt.Sprintf("audio_output.frame_duration %dms 无效，使用 60ms", cfg.FrameDuration))
+		}
+	}
+	h.preBufferFrames = defaultPreBufferFrames
+	if cfg.PreBufferFrames != nil && *cfg.PreBufferFrames >= 0 && *cfg.PreBufferFrames <= maxPreBufferFrames {
+		h.preBufferFrames = *cfg.PreBufferFrames
+	}
+	h.sendAhead = utils.ParseTimeout(cfg.SendAhead, 0)
+	if h.sendAhead > maxSendAhead {
+		h.sendAhead = maxSendAhead
+	}
+	h.opusAdaptor = newOpusAdaptor(cfg.Opus.Adaptive, h.logger)
+}
+
+// negotiatePlayback 按 hello 中的 playback 参数调整下发节奏，适配缓冲能力不同的硬件，
+// send_ahead 单位为毫秒，无效的参数忽略，返回是否有变化
+func (h *ConnectionHandler) negotiatePlayback(msgMap map[string]interface{}) bool {
+	params, ok := msgMap["playback"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	h.playbackNegotiated = true
+	if v, ok := params["frame_duration"].(float64); ok {
+		if utils.ValidOpusFrameDuration(int(v)) {
+			h.serverAudioFrameDuration = int(v)
+		} else {
+			h.logger.Warn(fmt.Sprintf("客户端请求的帧时长 %vms 不受支持，使用 %dms", v, h.serverAudioFrameDuration))
+		}
+	}
+	if v, ok := params["pre_buffer_frames"].(float64); ok && v >= 0 && v <= maxPreBufferFrames {
+		h.preBufferFrames = int(v)
+	}
+	if v, ok := params["send_ahead"].(float64); ok && v >= 0 {
+		h.sendAhead = time.Duration(v) * time.Millisecond
+		if h.sendAhead > maxSendAhead {
+			h.sendAhead = maxSendAhead
+		}
+	}
+	h.logger.Info(fmt.Sprintf("协商后的下发节奏: 帧时长 %dms, 预缓冲 %d 帧, 提前量 %v",
+		h.serverAudioFrameDuration, h.preBufferFrames, h.sendAhead))
+	return true
+}
+
+// helloPlayback 服务端 hello 中回复的下发节奏
+func (h *ConnectionHandler) helloPlayback() map[string]interface{} {
+	return map[string]interface{}{
+		"frame_duration":    h.serverAudioFrameDuration,
+		"pre_buffer_frames": h.preBufferFrames,
+		"send_ahead":        h.sendAhead.Milliseconds(),
+	}
+}
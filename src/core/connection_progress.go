@@ -0,0 +1,110 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	defaultProgressInterval    = time.Second
+	defaultProgressMinDuration = 10 * time.Second
+	minProgressInterval        = 200 * time.Millisecond
+)
+
+// 播放进度消息的状态
+const (
+	progressPlaying     = "playing"
+	progressEnd         = "end"
+	progressInterrupted = "interrupted"
+)
+
+// playbackProgress 一段音频的播放进度，按下发节奏估算客户端已播时长
+type playbackProgress struct {
+	h          *ConnectionHandler
+	round      int
+	index      int
+	text       string
+	total      time.Duration
+	interval   time.Duration
+	start      time.Time
+	lastReport time.Duration
+}
+
+// newPlaybackProgress 客户端声明了 progress 且音频不短于 min_duration 时返回进度跟踪，否则返回 nil
+func (h *ConnectionHandler) newPlaybackProgress(round, index int, text string, frames int) *playbackProgress {
+	if !h.caps.has(FeatureProgress) {
+		return nil
+	}
+	cfg := h.config.AudioOutput.Progress
+	total := time.Duration(frames*h.serverAudioFrameDuration) * time.Millisecond
+	if total < utils.ParseTimeout(cfg.MinDuration, defaultProgressMinDuration) {
+		return nil
+	}
+	interval := utils.ParseTimeout(cfg.Interval, defaultProgressInterval)
+	if interval < minProgressInterval {
+		interval = minProgressInterval
+	}
+	return &playbackProgress{h: h, round: round, index: index, text: text, total: total, interval: interval}
+}
+
+// begin 记录开始下发的时间，并下发位置为 0 的进度
+func (p *playbackProgress) begin(start time.Time) {
+	if p == nil {
+		return
+	}
+	p.start = start
+	p.send(progressPlaying, 0)
+}
+
+// update 每发送一帧后调用，sent 为已发送的音频时长；距上次上报超过间隔时下发进度
+func (p *playbackProgress) update(sent time.Duration) {
+	if p == nil {
+		return
+	}
+	played := p.played(sent)
+	if played-p.lastReport < p.interval {
+		return
+	}
+	p.lastReport = played
+	p.send(progressPlaying, played)
+}
+
+// finish 下发结束时调用，被打断时上报中断位置，供客户端断点续播
+func (p *playbackProgress) finish(sent time.Duration, interrupted bool) {
+	if p == nil {
+		return
+	}
+	if interrupted {
+		p.send(progressInterrupted, p.played(sent))
+		return
+	}
+	p.send(progressEnd, p.total)
+}
+
+// played 已播时长按开始下发后经过的时间估算，不超过已发送的时长
+func (p *playbackProgress) played(sent time.Duration) time.Duration {
+	return utils.MinDuration(time.Since(p.start), sent)
+}
+
+func (p *playbackProgress) send(state string, position time.Duration) {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":        "progress",
+		"state":       state,
+		"round":       p.round,
+		"index":       p.index,
+		"text":        p.text,
+		"position_ms": position.Milliseconds(),
+		"duration_ms": p.total.Milliseconds(),
+		"session_id":  p.h.sessionID,
+	})
+	if err != nil {
+		p.h.logger.Error(fmt.Sprintf("序列化播放进度消息失败: %v", err))
+		return
+	}
+	if err := p.h.conn.WriteMessage(1, data); err != nil {
+		p.h.logger.Error(fmt.Sprintf("发送播放进度消息失败: %v", err))
+	}
+}
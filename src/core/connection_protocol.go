@@ -0,0 +1,154 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// 支持的协议版本，对应客户端 hello 中的 version（二进制音频帧格式）
+const (
+	minProtocolVersion = 1
+	maxProtocolVersion = 3
+)
+
+// 客户端可在 hello 的 features 中声明的能力
+const (
+	FeaturePartialSTT = "partial_stt" // 接收流式识别的中间结果
+	FeatureEmotion    = "emotion"     // 接收情绪消息
+	FeatureEncryption = "encryption"  // 音频加密传输
+	FeatureMCP        = "mcp"         // 设备端 MCP 工具
+	FeatureCaption    = "caption"     // 接收统一格式的实时字幕
+	FeatureProgress   = "progress"    // 接收长音频的播放进度
+)
+
+// serverFeatures 服务端已实现的能力
+var serverFeatures = map[string]bool{
+	FeaturePartialSTT: true,
+	FeatureEmotion:    true,
+	FeatureEncryption: false,
+	FeatureMCP:        true,
+	FeatureCaption:    true,
+	FeatureProgress:   true,
+}
+
+// defaultFeatures 客户端没有声明 features 时按 v1 协议的默认行为
+var defaultFeatures = map[string]bool{
+	FeaturePartialSTT: false,
+	FeatureEmotion:    true,
+	FeatureEncryption: false,
+	FeatureMCP:        true,
+	FeatureCaption:    false,
+	FeatureProgress:   false,
+}
+
+// clientCapabilities 与客户端协商后的协议版本与能力
+type clientCapabilities struct {
+	version  atomic.Int32                    // 音频读取协程按版本解析二进制帧，需原子访问
+	features atomic.Pointer[map[string]bool] // 协商结果，为 nil 时客户端未声明，使用默认行为；hello 可能与其他协程的读取并发
+}
+
+// has 判断本连接是否启用某项能力
+func (c *clientCapabilities) has(feature string) bool {
+	features := c.features.Load()
+	if features == nil {
+		return defaultFeatures[feature]
+	}
+	return (*features)[feature]
+}
+
+// negotiated 判断客户端是否在 hello 中声明过能力
+func (c *clientCapabilities) negotiated() bool {
+	return c.features.Load() != nil
+}
+
+// negotiateVersion 客户端版本超出支持范围时降到服务端支持的最高版本
+func negotiateVersion(requested int) int {
+	switch {
+	case requested < minProtocolVersion:
+		return minProtocolVersion
+	case requested > maxProtocolVersion:
+		return maxProtocolVersion
+	default:
+		return requested
+	}
+}
+
+// negotiateFeatures 取客户端声明且服务端支持的能力
+// 兼容 features 与 capabilities 两种字段名，值为 true 或非空对象都视为声明支持
+func negotiateFeatures(msgMap map[string]interface{}) (map[string]bool, []string) {
+	declared, ok := msgMap["features"].(map[string]interface{})
+	if !ok {
+		declared, ok = msgMap["capabilities"].(map[string]interface{})
+	}
+	if !ok {
+		return nil, nil
+	}
+	features := make(map[string]bool, len(serverFeatures))
+	var unsupported []string
+	for name, value := range declared {
+		enabled := false
+		switch v := value.(type) {
+		case bool:
+			enabled = v
+		case map[string]interface{}:
+			enabled = true
+		}
+		if !enabled {
+			continue
+		}
+		if serverFeatures[name] {
+			features[name] = true
+		} else {
+			unsupported = append(unsupported, name)
+		}
+	}
+	sort.Strings(unsupported)
+	return features, unsupported
+}
+
+// negotiateHello 处理客户端 hello 中的版本与能力，返回是否需要重新回复 hello
+func (h *ConnectionHandler) negotiateHello(msgMap map[string]interface{}) bool {
+	changed := false
+	if version, ok := msgMap["version"].(float64); ok {
+		negotiated := negotiateVersion(int(version))
+		h.caps.version.Store(int32(negotiated))
+		if negotiated != int(version) {
+			h.logger.Warn(fmt.Sprintf("客户端协议版本 %d 不受支持，使用版本 %d", int(version), negotiated))
+		}
+		changed = negotiated != minProtocolVersion
+	}
+
+	features, unsupported := negotiateFeatures(msgMap)
+	if features != nil {
+		h.caps.features.Store(&features)
+		changed = true
+		h.logger.Info(fmt.Sprintf("协商后的客户端能力: %s", featureList(features)))
+	}
+	if len(unsupported) > 0 {
+		h.logger.Warn(fmt.Sprintf("客户端声明了服务端不支持的能力: %s", strings.Join(unsupported, ", ")))
+	}
+	return changed
+}
+
+// featureList 按名称排序输出已启用的能力
+func featureList(features map[string]bool) string {
+	names := make([]string, 0, len(features))
+	for name, enabled := range features {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// helloFeatures 服务端 hello 中回复的能力，值为是否启用
+func (h *ConnectionHandler) helloFeatures() map[string]bool {
+	features := make(map[string]bool, len(serverFeatures))
+	for name := range serverFeatures {
+		features[name] = h.caps.has(name)
+	}
+	return features
+}
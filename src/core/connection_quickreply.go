@@ -0,0 +1,116 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+	"unicode"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+// defaultWakeWords 未配置 wake_words 时识别的唤醒词
+var defaultWakeWords = []string{"你好小智", "你好小志", "小智小智", "嘿你好呀"}
+
+// defaultQuickReplyWords 未配置回复词组时使用的快速回复
+var defaultQuickReplyWords = []string{"我在", "在呢", "你说", "嗯，我在听"}
+
+// isWakeWord 判断 detect 文本是否只是唤醒词，忽略空白与标点
+func (h *ConnectionHandler) isWakeWord(text string) bool {
+	if !h.config.QuickReply.Enabled {
+		return false
+	}
+	words := h.config.QuickReply.WakeWords
+	if len(words) == 0 {
+		words = defaultWakeWords
+	}
+	text = normalizeWakeWord(text)
+	for _, word := range words {
+		if text == normalizeWakeWord(word) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeWakeWord(text string) string {
+	return strings.ToLower(strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) {
+			return -1
+		}
+		return r
+	}, text))
+}
+
+// quickReplyWords 当前角色使用的回复词组
+func (h *ConnectionHandler) quickReplyWords() []string {
+	language := ""
+	if role := h.findRole(h.roleName); role != nil {
+		language = role.Language
+	}
+	return selectQuickReplyWords(h.config.QuickReply, h.roleName, language)
+}
+
+// selectQuickReplyWords 按角色与角色语言选择回复词组：角色匹配的优先，其次语言匹配的，都没有时使用默认词组
+func selectQuickReplyWords(cfg configs.QuickReplyConfig, roleName, language string) []string {
+	var byLanguage *configs.QuickReplyGroup
+	for i := range cfg.Groups {
+		group := &cfg.Groups[i]
+		if len(group.Words) == 0 {
+			continue
+		}
+		if group.Role != "" && group.Role == roleName {
+			return group.Words
+		}
+		if byLanguage == nil && group.Role == "" && group.Language != "" && sameLanguage(group.Language, language) {
+			byLanguage = group
+		}
+	}
+	if byLanguage != nil {
+		return byLanguage.Words
+	}
+	if len(cfg.Words) > 0 {
+		return cfg.Words
+	}
+	return defaultQuickReplyWords
+}
+
+// quickReply 用户只说了唤醒词时，不请求 LLM，随机播报一句快速回复，命中缓存时直接下发音频
+func (h *ConnectionHandler) quickReply() error {
+	words := h.quickReplyWords()
+	text := words[rand.Intn(len(words))]
+
+	key := greetingKey(h.config.SelectedModule["TTS"], h.ttsVoice, text)
+	path, ok := "", false
+	if h.quickReplies != nil {
+		path, ok = h.quickReplies.load(key)
+	}
+	if !ok {
+		var err error
+		path, err = h.synthesize(text, 1)
+		if err != nil {
+			return fmt.Errorf("合成快速回复失败: %v", err)
+		}
+		// 降级到备用TTS时音色不同，不写入缓存
+		if h.quickReplies != nil && time.Now().After(h.ttsDegradedUntil) {
+			h.quickReplies.store(key, path)
+		}
+	}
+	h.logger.Info(fmt.Sprintf("唤醒词快速回复: %s, 命中缓存: %v", text, ok))
+	round, err := h.playFile(text, path)
+	if err != nil {
+		return err
+	}
+	h.sendCaption(captionRoleAssistant, round, 1, text, false)
+	h.sendCaption(captionRoleAssistant, round, 0, text, true)
+	return nil
+}
+
+// warmQuickReplies 音色确定或切换后，在后台用当前音色预合成本角色的回复词组
+func (h *ConnectionHandler) warmQuickReplies() {
+	if h.prewarmQuickReplies == nil {
+		return
+	}
+	go h.prewarmQuickReplies(h.ttsVoice, h.quickReplyWords())
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/reminder"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// registerReminderFunctions 注册设置、查询与取消提醒的本地函数
+func (h *ConnectionHandler) registerReminderFunctions() {
+	functions := []struct {
+		tool    openai.Tool
+		handler func(ctx context.Context, args map[string]interface{}) types.ActionResponse
+	}{
+		{setReminderTool(), h.handleSetReminder},
+		{listRemindersTool(), h.handleListReminders},
+		{cancelReminderTool(), h.handleCancelReminder},
+	}
+	for _, f := range functions {
+		if err := h.functionRegister.RegisterLocalFunction(f.tool.Function.Name, f.tool, f.handler); err != nil {
+			h.logger.Error(fmt.Sprintf("注册本地函数失败: %s, 错误: %v", f.tool.Function.Name, err))
+		}
+	}
+}
+
+func setReminderTool() openai.Tool {
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name: "set_reminder",
+			Description: "设置定时提醒，到点后设备会语音播报。相对时间（如十分钟后、一个半小时后）传 delay；" +
+				"具体时刻（如下午三点、明早八点）传 time 为 24 小时制 HH:MM，明天、后天用 day_offset 表示，不需要知道今天的日期",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"content": map[string]interface{}{
+						"type":        "string",
+						"description": "提醒内容，如“开会”“吃药”",
+					},
+					"delay": map[string]interface{}{
+						"type":        "string",
+						"description": "相对现在的时长，格式如 10m、1h30m、45s",
+					},
+					"time": map[string]interface{}{
+						"type":        "string",
+						"description": "提醒时刻，24 小时制 HH:MM",
+					},
+					"day_offset": map[string]interface{}{
+						"type":        "integer",
+						"description": "time 相对今天的天数，明天为 1，后天为 2；不传时已过的时刻顺延到明天",
+					},
+				},
+				"required": []string{"content"},
+			},
+		},
+	}
+}
+
+func listRemindersTool() openai.Tool {
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "list_reminders",
+			Description: "查询还没到点的提醒，用户问设置了哪些提醒时调用",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+	}
+}
+
+func cancelReminderTool() openai.Tool {
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "cancel_reminder",
+			Description: "取消提醒。知道提醒编号时传 id，否则传提醒内容中的关键词 keyword，匹配的提醒都会取消",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"id": map[string]interface{}{
+						"type":        "integer",
+						"description": "提醒编号，来自 list_reminders 的结果",
+					},
+					"keyword": map[string]interface{}{
+						"type":        "string",
+						"description": "提醒内容关键词",
+					},
+				},
+			},
+		},
+	}
+}
+
+// reminderUnavailable 没有设备ID或服务端未启用提醒时的回复
+func (h *ConnectionHandler) reminderUnavailable() *types.ActionResponse {
+	if h.reminders != nil && h.deviceID != "" {
+		return nil
+	}
+	return &types.ActionResponse{Action: types.ActionTypeResponse, Response: "这台设备暂时不能设置提醒哦"}
+}
+
+func (h *ConnectionHandler) handleSetReminder(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	if resp := h.reminderUnavailable(); resp != nil {
+		return *resp
+	}
+	content, _ := args["content"].(string)
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "要提醒你做什么呢？"}
+	}
+	delay, _ := args["delay"].(string)
+	clock, _ := args["time"].(string)
+	dayOffset, _ := intArg(args, "day_offset")
+
+	now := h.now()
+	at, err := reminder.ResolveTime(now, delay, clock, dayOffset)
+	if err != nil {
+		h.logger.Warn(fmt.Sprintf("解析提醒时间失败: %v, 参数: %v", err, args))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "没听清要什么时候提醒你，可以再说一次吗？"}
+	}
+	record, err := reminder.Create(h.deviceID, content, at)
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("创建提醒失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "提醒没能设置成功，稍后再试试吧"}
+	}
+	if err := h.reminders.schedule(*record, at); err != nil {
+		h.logger.Error(fmt.Sprintf("提醒 %d 加入定时任务失败: %v", record.ID, err))
+		reminder.SetStatus(record.ID, reminder.StatusCancelled)
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "提醒没能设置成功，稍后再试试吧"}
+	}
+	h.logger.Info(fmt.Sprintf("设置提醒 %d: %s, 时间: %s", record.ID, content, at.Format("2006-01-02 15:04:05")))
+	return types.ActionResponse{
+		Action:   types.ActionTypeResponse,
+		Response: fmt.Sprintf("好的，%s提醒你%s", reminder.Describe(now, at), content),
+	}
+}
+
+func (h *ConnectionHandler) handleListReminders(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	if resp := h.reminderUnavailable(); resp != nil {
+		return *resp
+	}
+	pending, err := reminder.ListPending(h.deviceID)
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("查询提醒失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "暂时没能查到提醒，稍后再试试吧"}
+	}
+	if len(pending) == 0 {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "现在没有设置提醒"}
+	}
+	now := h.now()
+	items := make([]string, 0, len(pending))
+	for _, r := range pending {
+		items = append(items, fmt.Sprintf("%d号，%s，%s", r.ID, reminder.Describe(now, r.RemindAt), r.Content))
+	}
+	return types.ActionResponse{
+		Action:   types.ActionTypeResponse,
+		Response: fmt.Sprintf("你有%d个提醒：%s", len(pending), strings.Join(items, "；")),
+	}
+}
+
+func (h *ConnectionHandler) handleCancelReminder(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	if resp := h.reminderUnavailable(); resp != nil {
+		return *resp
+	}
+	pending, err := reminder.ListPending(h.deviceID)
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("查询提醒失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "暂时没能取消提醒，稍后再试试吧"}
+	}
+	id, hasID := intArg(args, "id")
+	keyword, _ := args["keyword"].(string)
+	keyword = strings.TrimSpace(keyword)
+
+	var cancelled []string
+	for _, r := range pending {
+		if hasID && int(r.ID) != id {
+			continue
+		}
+		if !hasID && (keyword == "" || !strings.Contains(r.Content, keyword)) {
+			continue
+		}
+		ok, err := reminder.SetStatus(r.ID, reminder.StatusCancelled)
+		if err != nil {
+			h.logger.Error(fmt.Sprintf("取消提醒 %d 失败: %v", r.ID, err))
+			continue
+		}
+		if ok {
+			h.reminders.cancel(r.ID)
+			cancelled = append(cancelled, r.Content)
+		}
+	}
+	if len(cancelled) == 0 {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "没有找到要取消的提醒"}
+	}
+	h.logger.Info(fmt.Sprintf("取消提醒: %v", cancelled))
+	return types.ActionResponse{
+		Action:   types.ActionTypeResponse,
+		Response: fmt.Sprintf("好的，已经取消了%s的提醒", strings.Join(cancelled, "、")),
+	}
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// registerSceneFunction 配置了场景时注册 run_scene，可选场景以枚举形式告知 LLM
+func (h *ConnectionHandler) registerSceneFunction() {
+	if len(h.config.Scenes) == 0 {
+		return
+	}
+	if err := h.functionRegister.RegisterLocalFunction("run_scene", h.runSceneTool(), h.handleRunScene); err != nil {
+		h.logger.Error(fmt.Sprintf("注册本地函数失败: run_scene, 错误: %v", err))
+	}
+}
+
+func (h *ConnectionHandler) runSceneTool() openai.Tool {
+	names := make([]string, 0, len(h.config.Scenes))
+	descriptions := make([]string, 0, len(h.config.Scenes))
+	for _, scene := range h.config.Scenes {
+		names = append(names, scene.Name)
+		if scene.Description != "" {
+			descriptions = append(descriptions, fmt.Sprintf("%s: %s", scene.Name, scene.Description))
+		} else {
+			descriptions = append(descriptions, scene.Name)
+		}
+	}
+	return openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "run_scene",
+			Description: "执行预定义的智能家居场景，依次完成一组设备操作，可选场景: " + strings.Join(descriptions, "; "),
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"scene_name": map[string]interface{}{
+						"type":        "string",
+						"description": "要执行的场景名称",
+						"enum":        names,
+					},
+				},
+				"required": []string{"scene_name"},
+			},
+		},
+	}
+}
+
+// findScene 按名称查找场景配置
+func (h *ConnectionHandler) findScene(name string) *configs.SceneConfig {
+	name = strings.TrimSpace(name)
+	for i := range h.config.Scenes {
+		if h.config.Scenes[i].Name == name {
+			return &h.config.Scenes[i]
+		}
+	}
+	return nil
+}
+
+// handleRunScene 顺序执行场景步骤，某一步失败时回滚已完成的步骤
+func (h *ConnectionHandler) handleRunScene(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	name, _ := args["scene_name"].(string)
+	scene := h.findScene(name)
+	if scene == nil {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: fmt.Sprintf("没有找到叫%s的场景哦", name)}
+	}
+	h.logger.Info(fmt.Sprintf("执行场景: %s, 共 %d 步", scene.Name, len(scene.Steps)))
+
+	var done []configs.SceneStep
+	var skipped []string
+	for i, step := range scene.Steps {
+		if err := h.invokeTool(ctx, step.Tool, step.Arguments); err != nil {
+			label := stepLabel(step, i)
+			if step.Optional {
+				h.logger.Warn(fmt.Sprintf("场景 %s 第 %d 步 %s 失败，跳过: %v", scene.Name, i+1, label, err))
+				skipped = append(skipped, label)
+				continue
+			}
+			h.logger.Error(fmt.Sprintf("场景 %s 第 %d 步 %s 失败，开始回滚: %v", scene.Name, i+1, label, err))
+			failed := h.rollbackScene(ctx, scene.Name, done)
+			reply := fmt.Sprintf("%s没有成功", label)
+			switch {
+			case len(done) == 0:
+			case failed == 0:
+				reply += "，前面的操作已经恢复原样了"
+			default:
+				reply += "，有些操作没能恢复，请检查一下设备"
+			}
+			return types.ActionResponse{Action: types.ActionTypeResponse, Response: reply}
+		}
+		done = append(done, step)
+	}
+
+	reply := scene.Reply
+	if reply == "" {
+		reply = fmt.Sprintf("好的，%s场景已经执行完了", scene.Name)
+	}
+	if len(skipped) > 0 {
+		reply += fmt.Sprintf("，不过%s没有成功", strings.Join(skipped, "、"))
+	}
+	return types.ActionResponse{Action: types.ActionTypeResponse, Response: reply}
+}
+
+// rollbackScene 按相反顺序回滚已完成的步骤，返回回滚失败的步骤数
+func (h *ConnectionHandler) rollbackScene(ctx context.Context, sceneName string, done []configs.SceneStep) int {
+	failed := 0
+	for i := len(done) - 1; i >= 0; i-- {
+		step := done[i]
+		if step.Rollback == "" {
+			continue
+		}
+		if err := h.invokeTool(ctx, step.Rollback, step.RollbackArguments); err != nil {
+			h.logger.Error(fmt.Sprintf("场景 %s 回滚 %s 失败: %v", sceneName, stepLabel(step, i), err))
+			failed++
+		}
+	}
+	return failed
+}
+
+// invokeTool 执行一次工具调用，本地函数优先，其余交给 MCP 管理器
+func (h *ConnectionHandler) invokeTool(ctx context.Context, name string, args map[string]interface{}) error {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	if h.functionRegister.IsLocalFunction(name) {
+		result := h.functionRegister.CallFunction(ctx, name, args)
+		if result.Action == types.ActionTypeError || result.Action == types.ActionTypeNotFound {
+			return fmt.Errorf("%v", result.Result)
+		}
+		return nil
+	}
+	if h.mcpManager == nil {
+		return fmt.Errorf("工具 %s 不可用", name)
+	}
+	_, err := h.mcpManager.ExecuteTool(ctx, name, args)
+	return err
+}
+
+// stepLabel 步骤的播报名称，未配置名称时使用序号
+func stepLabel(step configs.SceneStep, index int) string {
+	if step.Name != "" {
+		return step.Name
+	}
+	return fmt.Sprintf("第%d步", index+1)
+}
@@ -4,16 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"sync/atomic"
+	"strconv"
 	"time"
 	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/metrics"
 )
 
 // sendHelloMessage 发送欢迎消息
 func (h *ConnectionHandler) sendHelloMessage() error {
 	hello := make(map[string]interface{})
 	hello["type"] = "hello"
-	hello["version"] = 1
+	hello["version"] = h.protocolVersion()
 	hello["transport"] = "websocket"
 	hello["session_id"] = h.sessionID
 	hello["audio_params"] = map[string]interface{}{
@@ -22,6 +23,12 @@ func (h *ConnectionHandler) sendHelloMessage() error {
 		"channels":       h.serverAudioChannels,
 		"frame_duration": h.serverAudioFrameDuration,
 	}
+	if h.caps.negotiated() {
+		hello["features"] = h.helloFeatures()
+	}
+	if h.playbackNegotiated {
+		hello["playback"] = h.helloPlayback()
+	}
 	data, err := json.Marshal(hello)
 	if err != nil {
 		return fmt.Errorf("序列化欢迎消息失败: %v", err)
@@ -50,12 +57,49 @@ func (h *ConnectionHandler) sendTTSMessage(state string, text string, textIndex
 	return nil
 }
 
+// sendPartialSTTMessage 发送流式识别的中间结果，仅声明了 partial_stt 的客户端接收
+func (h *ConnectionHandler) sendPartialSTTMessage(text string) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":       "stt",
+		"state":      "partial",
+		"text":       text,
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化 STT 中间结果失败: %v", err)
+	}
+	return h.conn.WriteMessage(1, data)
+}
+
 func (h *ConnectionHandler) sendSTTMessage(text string) error {
 	sttMsg := map[string]interface{}{
 		"type":       "stt",
 		"text":       text,
 		"session_id": h.sessionID,
 	}
+	if h.clientListenMode == listenModeDictation {
+		sttMsg["mode"] = listenModeDictation
+	}
+	// 听写与会议转写不开始新一轮，与中间结果保持相同的轮次
+	round := h.currentRound()
+	if h.clientListenMode == listenModeDictation || h.transcribing() {
+		round++
+	}
+	h.sendCaption(captionRoleUser, round, 0, text, true)
+	// 附带分句与置信度，供客户端字幕使用
+	h.asrLanguage = ""
+	if result := h.takeAsrResult(text); result != nil {
+		h.asrLanguage = result.Language
+		if result.Language != "" {
+			sttMsg["language"] = result.Language
+		}
+		if result.Confidence > 0 {
+			sttMsg["confidence"] = result.Confidence
+		}
+		if len(result.Utterances) > 0 {
+			sttMsg["utterances"] = result.Utterances
+		}
+	}
 	jsonData, err := json.Marshal(sttMsg)
 	if err != nil {
 		return fmt.Errorf("序列化 STT 消息失败: %v", err)
@@ -69,6 +113,9 @@ func (h *ConnectionHandler) sendSTTMessage(text string) error {
 
 // sendEmotionMessage 发送情绪消息
 func (h *ConnectionHandler) sendEmotionMessage(emotion string) error {
+	if !h.caps.has(FeatureEmotion) {
+		return nil
+	}
 	data := map[string]interface{}{
 		"type":       "llm",
 		"text":       utils.GetEmotionEmoji(emotion),
@@ -82,11 +129,12 @@ func (h *ConnectionHandler) sendEmotionMessage(emotion string) error {
 	return h.conn.WriteMessage(1, jsonData)
 }
 
-func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textIndex int, round int) {
+func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textIndex int, round int, partial bool, stream *ttsStream) {
 	bFinishSuccess := false
 	defer func() {
+		stream.close()
 		// 音频发送完成后，根据配置决定是否删除文件
-		if h.config.DeleteAudio && len(filepath) > 0 {
+		if h.deleteAfterPlay(filepath) {
 			if err := os.Remove(filepath); err != nil {
 				h.logger.Error(fmt.Sprintf("删除TTS音频文件失败: %v", err))
 			} else {
@@ -94,22 +142,22 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 			}
 		}
 
-		h.logger.Info(fmt.Sprintf("TTS音频发送任务结束(%t): %s, 索引: %d/%d", bFinishSuccess, text, textIndex, h.tts_last_text_index))
-		if textIndex == h.tts_last_text_index {
+		h.logger.Info(fmt.Sprintf("TTS音频发送任务结束(%t): %s, 索引: %d/%d", bFinishSuccess, text, textIndex, h.tts_last_text_index.Load()))
+		if int64(textIndex) == h.tts_last_text_index.Load() && !partial {
 			h.sendTTSMessage("stop", "", textIndex)
 			h.clearSpeakStatus()
 		}
 	}()
 
-	if len(filepath) == 0 {
+	if len(filepath) == 0 && stream == nil {
 		return
 	}
 	// 检查轮次
-	if round != h.talkRound {
+	if round != h.currentRound() {
 		h.logger.Info(fmt.Sprintf("sendAudioMessage: 跳过过期轮次的音频: 任务轮次=%d, 当前轮次=%d, 文本=%s",
-			round, h.talkRound, text))
+			round, h.currentRound(), text))
 		// 即使跳过，也要根据配置删除音频文件
-		if h.config.DeleteAudio {
+		if h.deleteAfterPlay(filepath) {
 			if err := os.Remove(filepath); err != nil {
 				h.logger.Error(fmt.Sprintf("删除跳过的音频文件失败: %v", err))
 			} else {
@@ -119,10 +167,10 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 		return
 	}
 
-	if atomic.LoadInt32(&h.serverVoiceStop) == 1 { // 服务端语音停止
+	if h.voice.Interrupted() { // 服务端语音停止
 		h.logger.Info(fmt.Sprintf("sendAudioMessage 服务端语音停止, 不再发送音频数据：%s", text))
 		// 服务端语音停止时也要根据配置删除音频文件
-		if h.config.DeleteAudio {
+		if h.deleteAfterPlay(filepath) {
 			if err := os.Remove(filepath); err != nil {
 				h.logger.Error(fmt.Sprintf("删除停止的音频文件失败: %v", err))
 			} else {
@@ -132,24 +180,15 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 		return
 	}
 
-	var audioData [][]byte
-	var duration float64
-	var err error
+	if stream != nil {
+		bFinishSuccess = h.sendStreamAudio(stream, text, textIndex, round)
+		return
+	}
 
-	// 使用TTS提供者的方法将音频转为Opus格式
-	if h.serverAudioFormat == "pcm" {
-		h.logger.Info("服务端音频格式为PCM，直接发送")
-		audioData, duration, err = utils.AudioToPCMData(filepath)
-		if err != nil {
-			h.logger.Error(fmt.Sprintf("音频转PCM失败: %v", err))
-			return
-		}
-	} else if h.serverAudioFormat == "opus" {
-		audioData, duration, err = utils.AudioToOpusData(filepath)
-		if err != nil {
-			h.logger.Error(fmt.Sprintf("音频转Opus失败: %v", err))
-			return
-		}
+	audioData, duration, err := h.loadAudioFrames(filepath)
+	if err != nil {
+		h.logger.Error(err.Error())
+		return
 	}
 
 	// 发送TTS状态开始通知
@@ -157,16 +196,17 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 		h.logger.Error(fmt.Sprintf("发送TTS开始状态失败: %v", err))
 		return
 	}
+	h.recordPlayed(text, round)
 
 	if textIndex == 1 {
 		now := time.Now()
-		spentTime := now.Sub(h.roundStartTime)
+		spentTime := now.Sub(h.roundStart())
 		h.logger.Info(fmt.Sprintf("回复首句耗时 %s 第一句话【%s】, round: %d", spentTime, text, round))
 	}
-	h.logger.Info(fmt.Sprintf("TTS发送(%s): \"%s\" (索引:%d/%d，时长:%f，帧数:%d)", h.serverAudioFormat, text, textIndex, h.tts_last_text_index, duration, len(audioData)))
+	h.logger.Info(fmt.Sprintf("TTS发送(%s): \"%s\" (索引:%d/%d，时长:%f，帧数:%d)", h.serverAudioFormat, text, textIndex, h.tts_last_text_index.Load(), duration, len(audioData)))
 
 	// 分时发送音频数据
-	if err := h.sendAudioFrames(audioData, text, round); err != nil {
+	if err := h.sendAudioFrames(audioData, text, textIndex, round); err != nil {
 		h.logger.Error(fmt.Sprintf("分时发送音频数据失败: %v", err))
 		return
 	}
@@ -180,108 +220,224 @@ func (h *ConnectionHandler) sendAudioMessage(filepath string, text string, textI
 	bFinishSuccess = true
 }
 
+// deleteAfterPlay 按 delete_audio 配置，音频播放或丢弃后是否删除文件，欢迎语与快速回复的缓存文件始终保留
+func (h *ConnectionHandler) deleteAfterPlay(path string) bool {
+	return h.config.DeleteAudio && path != "" && !isCachedAudio(path)
+}
+
+// loadAudioFrames 把音频文件转为下发用的帧与时长(秒)
+// 播放后保留的文件会被重复播放，启用音频帧缓存时按编码参数缓存结果，再次播放跳过解码与编码
+func (h *ConnectionHandler) loadAudioFrames(path string) ([][]byte, float64, error) {
+	cacheable := h.audioCache != nil && !h.deleteAfterPlay(path)
+	var variant string
+	if cacheable {
+		variant = h.audioCacheVariant()
+		if frames, duration, ok := h.audioCache.Get(path, variant); ok {
+			h.logger.Debug(fmt.Sprintf("命中音频帧缓存: %s", path))
+			return frames, duration, nil
+		}
+	}
+
+	// 解码为PCM并做响度归一化，opus 格式再编码为Opus帧
+	audioData, duration, err := utils.AudioToPCMData(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("音频转PCM失败: %v", err)
+	}
+	audioData = h.normalizeLoudness(audioData)
+	if h.serverAudioFormat == "pcm" {
+		h.logger.Info("服务端音频格式为PCM，直接发送")
+	} else if h.serverAudioFormat == "opus" {
+		if len(audioData) == 0 {
+			return nil, 0, fmt.Errorf("音频转Opus失败: PCM转换结果为空")
+		}
+		audioData, err = utils.PCMSlicesToOpusFrames(audioData, utils.OutputSampleRate, 1, h.opusOptions(), h.serverAudioFrameDuration)
+		if err != nil {
+			return nil, 0, fmt.Errorf("PCM转Opus失败: %v", err)
+		}
+	}
+	if cacheable {
+		h.audioCache.Put(path, variant, audioData, duration)
+	}
+	return audioData, duration, nil
+}
+
+// audioCacheVariant 影响下发帧内容的参数：下发格式、帧时长、Opus 编码参数与响度归一化配置
+func (h *ConnectionHandler) audioCacheVariant() string {
+	variant := fmt.Sprintf("%s|%+v", h.serverAudioFormat, h.config.AudioOutput.Loudness)
+	if h.serverAudioFormat == "opus" {
+		variant += fmt.Sprintf("|%d|%+v", h.serverAudioFrameDuration, h.opusOptions())
+	}
+	return variant
+}
+
+// normalizeLoudness 启用响度归一化时，把下发的 PCM 调整到目标响度并限幅
+func (h *ConnectionHandler) normalizeLoudness(pcm [][]byte) [][]byte {
+	cfg := h.config.AudioOutput.Loudness
+	if !cfg.Enabled {
+		return pcm
+	}
+	opts := utils.LoudnessOptions{TargetLUFS: cfg.TargetLUFS, MaxGain: cfg.MaxGain, Ceiling: cfg.Ceiling}
+	if opts.TargetLUFS == 0 {
+		opts.TargetLUFS = -16
+	}
+	if opts.MaxGain == 0 {
+		opts.MaxGain = 12
+	}
+	if opts.Ceiling == 0 {
+		opts.Ceiling = -1
+	}
+	for i, slice := range pcm {
+		pcm[i] = utils.NormalizeLoudness(slice, utils.OutputSampleRate, opts)
+	}
+	return pcm
+}
+
 // sendAudioFrames 分时发送音频帧，避免撑爆客户端缓冲区
-func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, round int) error {
+func (h *ConnectionHandler) sendAudioFrames(audioData [][]byte, text string, textIndex, round int) error {
 	if len(audioData) == 0 {
 		return nil
 	}
+	frames := make(chan []byte, len(audioData))
+	for _, frame := range audioData {
+		frames <- frame
+	}
+	close(frames)
+	return h.sendAudioStream(frames, len(audioData), text, textIndex, round)
+}
 
+// sendAudioStream 按实时播放节奏下发通道中的音频帧，通道关闭表示本句结束
+// total 为总帧数，流式合成时未知传 0；流式合成跟不上播放时以帧到达时间重新计时，不计为发送落后
+func (h *ConnectionHandler) sendAudioStream(frames <-chan []byte, total int, text string, textIndex, round int) error {
 	// 流控参数
 	frameDuration := time.Duration(h.serverAudioFrameDuration) * time.Millisecond // 帧时长，默认60ms
 	startTime := time.Now()
 	playPosition := 0 // 播放位置（毫秒）
 
-	// 预缓冲：发送前几帧，提升播放流畅度
-	preBufferFrames := 3
-	if len(audioData) < preBufferFrames {
-		preBufferFrames = len(audioData)
-	}
+	// 长音频按间隔下发播放进度，结束或被打断时再上报一次；流式合成时总时长未知，不上报
+	progress := h.newPlaybackProgress(round, textIndex, text, total)
+	progress.begin(startTime)
+	completed := false
+	sent := 0 // 实际写出的帧数，不含跳过的静音包
+	defer func() {
+		progress.finish(time.Duration(playPosition)*time.Millisecond, !completed)
+		metrics.AudioFramesSent.WithLabelValues(h.serverAudioFormat).Add(float64(sent))
+	}()
 
-	// 发送预缓冲帧
-	for i := 0; i < preBufferFrames; i++ {
-		// 检查是否被打断
-		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
-			h.logger.Info(fmt.Sprintf("音频发送被中断(预缓冲阶段): 帧=%d/%d, 文本=%s", i+1, preBufferFrames, text))
-			return nil
-		}
+	// 发送情况，供自适应码率判断网络状况
+	lateFrames := 0
+	var writeTime time.Duration
+	// 开启 DTX 并配置跳过时，静音包不下发，播放位置照常推进
+	skipDTX := h.serverAudioFormat == "opus" && h.config.AudioOutput.Opus.DTX && h.config.AudioOutput.Opus.SkipDTXFrames
+	skipped := 0
 
-		if err := h.conn.WriteMessage(2, audioData[i]); err != nil {
-			return fmt.Errorf("发送预缓冲音频帧失败: %v", err)
-		}
-		playPosition += h.serverAudioFrameDuration
+	// 预缓冲：前几帧不等待直接发送，提升播放流畅度
+	preBufferFrames := h.preBufferFrames
+	totalLabel := "?"
+	if total > 0 {
+		totalLabel = strconv.Itoa(total)
 	}
 
-	// 发送剩余音频帧
-	remainingFrames := audioData[preBufferFrames:]
-	for i, chunk := range remainingFrames {
-		// 检查是否被打断或轮次变化
-		if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
-			h.logger.Info(fmt.Sprintf("音频发送被中断: 帧=%d/%d, 文本=%s", i+preBufferFrames+1, len(audioData), text))
-			return nil
-		}
-
-		// 检查连接是否关闭
+	count := 0 // 已取出的帧数
+	for {
+		var chunk []byte
+		ok, starved := true, false
 		select {
-		case <-h.stopChan:
-			return nil
+		case chunk, ok = <-frames:
 		default:
+			// 流式合成的下一帧尚未编码出来
+			starved = true
+			select {
+			case chunk, ok = <-frames:
+			case <-h.stopChan:
+				return nil
+			}
+		}
+		if !ok {
+			break
 		}
+		count++
 
-		// 计算预期发送时间
-		expectedTime := startTime.Add(time.Duration(playPosition) * time.Millisecond)
-		currentTime := time.Now()
-		delay := expectedTime.Sub(currentTime)
-
-		// 如果需要延迟，则等待
-		if delay > 0 {
-			// 使用可中断的延迟
-			checkInterval := frameDuration / 2 // 使用帧时长的一半作为检查间隔
-			if checkInterval < 10*time.Millisecond {
-				checkInterval = 10 * time.Millisecond // 最小10ms
+		// 检查是否被打断或轮次变化
+		if h.voice.Interrupted() || round != h.currentRound() {
+			stage := ""
+			if count <= preBufferFrames {
+				stage = "(预缓冲阶段)"
 			}
+			h.logger.Info(fmt.Sprintf("音频发送被中断%s: 帧=%d/%s, 文本=%s", stage, count, totalLabel, text))
+			return nil
+		}
 
+		if count > preBufferFrames {
+			// 检查连接是否关闭
 			select {
-			case <-time.After(delay):
-				// 正常延迟结束
 			case <-h.stopChan:
-				// 连接关闭
 				return nil
-			case <-time.After(checkInterval):
-				// 定期检查中断条件
-				if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
-					h.logger.Info(fmt.Sprintf("音频发送在延迟中被中断: 帧=%d/%d, 文本=%s", i+preBufferFrames+1, len(audioData), text))
-					return nil
-				}
-				// 如果还需要继续延迟，重新计算剩余时间
-				newCurrentTime := time.Now()
-				remainingDelay := expectedTime.Sub(newCurrentTime)
-				if remainingDelay > 0 {
-					// 递归式等待剩余时间
-					for remainingDelay > 0 {
-						select {
-						case <-time.After(utils.MinDuration(remainingDelay, checkInterval)):
-							if atomic.LoadInt32(&h.serverVoiceStop) == 1 || round != h.talkRound {
-								h.logger.Info(fmt.Sprintf("音频发送在延迟中被中断: 帧=%d/%d, 文本=%s", i+preBufferFrames+1, len(audioData), text))
-								return nil
-							}
-							newCurrentTime = time.Now()
-							remainingDelay = expectedTime.Sub(newCurrentTime)
-						case <-h.stopChan:
-							return nil
-						}
-					}
+			default:
+			}
+
+			// 计算预期发送时间，按发送提前量提前于实时播放位置
+			expectedTime := startTime.Add(time.Duration(playPosition)*time.Millisecond - h.sendAhead)
+			delay := time.Until(expectedTime)
+			if delay < -frameDuration {
+				if starved {
+					// 合成慢于播放，客户端已经播完缓冲的音频，从这一帧重新计时
+					startTime = startTime.Add(-delay)
+				} else {
+					lateFrames++
 				}
 			}
+
+			// 如果需要延迟，则等待，等待中定期检查中断条件
+			if delay > 0 && !h.waitFrameTime(expectedTime, frameDuration, round) {
+				h.logger.Info(fmt.Sprintf("音频发送在延迟中被中断: 帧=%d/%s, 文本=%s", count, totalLabel, text))
+				return nil
+			}
 		}
 
 		// 发送音频帧
-		if err := h.conn.WriteMessage(2, chunk); err != nil {
-			return fmt.Errorf("发送音频帧失败: %v", err)
+		if skipDTX && utils.IsOpusDTXPacket(chunk) {
+			skipped++
+		} else {
+			writeStart := time.Now()
+			if err := h.writeAudioFrame(chunk); err != nil {
+				if count <= preBufferFrames {
+					return fmt.Errorf("发送预缓冲音频帧失败: %v", err)
+				}
+				return fmt.Errorf("发送音频帧失败: %v", err)
+			}
+			writeTime += time.Since(writeStart)
+			sent++
 		}
 
 		playPosition += h.serverAudioFrameDuration
+		progress.update(time.Duration(playPosition) * time.Millisecond)
 	}
 
-	h.logger.Info(fmt.Sprintf("音频帧发送完成: 总帧数=%d, 总时长=%dms, 文本=%s", len(audioData), playPosition, text))
+	completed = true
+	h.observeSend(count, lateFrames, writeTime)
+
+	h.logger.Info(fmt.Sprintf("音频帧发送完成: 总帧数=%d, 跳过静音帧=%d, 总时长=%dms, 文本=%s", count, skipped, playPosition, text))
 	return nil
 }
+
+// waitFrameTime 等待到帧的预期发送时间，被打断、轮次变化或连接关闭时返回 false
+func (h *ConnectionHandler) waitFrameTime(expectedTime time.Time, frameDuration time.Duration, round int) bool {
+	checkInterval := frameDuration / 2 // 使用帧时长的一半作为检查间隔
+	if checkInterval < 10*time.Millisecond {
+		checkInterval = 10 * time.Millisecond // 最小10ms
+	}
+	for {
+		remaining := time.Until(expectedTime)
+		if remaining <= 0 {
+			return true
+		}
+		select {
+		case <-time.After(utils.MinDuration(remaining, checkInterval)):
+			if h.voice.Interrupted() || round != h.currentRound() {
+				return false
+			}
+		case <-h.stopChan:
+			return false
+		}
+	}
+}
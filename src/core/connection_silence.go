@@ -0,0 +1,183 @@
+package core
+
+import (
+	"fmt"
+	"time"
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/chat"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	silenceCheckInterval = time.Second      // 静音检测的轮询间隔
+	farewellCloseWait    = 15 * time.Second // 告别语未能正常播完时，最长等待多久后强制关闭连接
+)
+
+// silenceConfig 返回本连接生效的静音配置，设备配置中非零的字段覆盖全局配置
+func (h *ConnectionHandler) silenceConfig() configs.SilenceConfig {
+	cfg := h.config.Silence
+	device, ok := h.config.Devices[h.deviceID]
+	if h.deviceID == "" || !ok {
+		return cfg
+	}
+	if device.Silence.NoVoiceTimeout != "" {
+		cfg.NoVoiceTimeout = device.Silence.NoVoiceTimeout
+	}
+	if device.Silence.MaxSilenceRounds != 0 {
+		cfg.MaxSilenceRounds = device.Silence.MaxSilenceRounds
+	}
+	if device.Silence.Prompt != "" {
+		cfg.Prompt = device.Silence.Prompt
+	}
+	if device.Silence.EndPrompt != "" {
+		cfg.EndPrompt = device.Silence.EndPrompt
+	}
+	return cfg
+}
+
+// touchVoiceTime 重新开始静音计时，不清零静音次数
+func (h *ConnectionHandler) touchVoiceTime() {
+	h.lastVoiceTime.Store(time.Now().UnixNano())
+}
+
+// resetSilence 用户说话后重新计时并清零静音次数
+func (h *ConnectionHandler) resetSilence() {
+	h.touchVoiceTime()
+	h.touchActiveTime()
+	h.silenceRounds.Store(0)
+}
+
+// silenceWatchCoroutine 静音检测协程
+// 客户端拾音且服务端没有播报时，超过 no_voice_timeout 没有识别到语音计为一次静音
+func (h *ConnectionHandler) silenceWatchCoroutine() {
+	cfg := h.silenceConfig()
+	if cfg.NoVoiceTimeout == "" {
+		return
+	}
+	timeout := utils.ParseTimeout(cfg.NoVoiceTimeout, 0)
+	if timeout <= 0 {
+		h.logger.Warn(fmt.Sprintf("静音检测时长配置无效: %s，不检测静音", cfg.NoVoiceTimeout))
+		return
+	}
+	h.logger.Info(fmt.Sprintf("启用静音检测: 超时 %v, 最多连续静音 %d 次", timeout, cfg.MaxSilenceRounds))
+
+	h.touchVoiceTime()
+	ticker := time.NewTicker(silenceCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stopChan:
+			return
+		case <-ticker.C:
+			if h.voice.State() != VoiceListening || h.transcribing() || h.clientListenMode == listenModeDictation {
+				h.touchVoiceTime()
+				continue
+			}
+			if time.Since(time.Unix(0, h.lastVoiceTime.Load())) < timeout {
+				continue
+			}
+			h.touchVoiceTime()
+			rounds := int(h.silenceRounds.Add(1))
+			if cfg.MaxSilenceRounds > 0 && rounds >= cfg.MaxSilenceRounds {
+				h.logger.Info(fmt.Sprintf("连续静音 %d 次，结束对话", rounds))
+				h.speakAndClose(cfg.EndPrompt)
+				return
+			}
+			h.logger.Info(fmt.Sprintf("检测到静音，第 %d 次", rounds))
+			if cfg.Prompt != "" {
+				if err := h.proactiveSpeak(cfg.Prompt); err != nil {
+					h.logger.Error(fmt.Sprintf("播报静音提示失败: %v", err))
+				}
+			}
+		}
+	}
+}
+
+// proactiveSpeak 服务端主动播报一句话，作为新的一轮写入对话历史
+func (h *ConnectionHandler) proactiveSpeak(text string) error {
+	round := h.startRound()
+	h.voice.Fire(EventSpeakStart)
+
+	if err := h.sendTTSMessage("start", "", 0); err != nil {
+		return err
+	}
+	h.tts_last_text_index.Store(1)
+	if err := h.SpeakAndPlay(text, 1, round); err != nil {
+		h.sendTTSMessage("stop", "", 0)
+		h.clearSpeakStatus()
+		return err
+	}
+	h.sendCaption(captionRoleAssistant, round, 0, text, true)
+	h.dialogueManager.Put(chat.Message{
+		Role:    "assistant",
+		Content: text,
+	})
+	h.recordHistory("assistant", text)
+	return nil
+}
+
+// proactivePlay 服务端主动播放已合成的音频，作为新的一轮写入对话历史
+func (h *ConnectionHandler) proactivePlay(text, filepath string) error {
+	round, err := h.playFile(text, filepath)
+	if err != nil {
+		return err
+	}
+	h.sendCaption(captionRoleAssistant, round, 1, text, false)
+	h.sendCaption(captionRoleAssistant, round, 0, text, true)
+	h.dialogueManager.Put(chat.Message{
+		Role:    "assistant",
+		Content: text,
+	})
+	h.recordHistory("assistant", text)
+	return nil
+}
+
+// playFile 开始新的一轮并下发已合成的音频，返回本轮轮次
+func (h *ConnectionHandler) playFile(text, filepath string) (int, error) {
+	round := h.startRound()
+	h.voice.Fire(EventSpeakStart)
+
+	if err := h.sendTTSMessage("start", "", 0); err != nil {
+		return round, err
+	}
+	h.tts_last_text_index.Store(1)
+	h.audioMessagesQueue <- struct {
+		filepath  string
+		text      string
+		round     int
+		textIndex int
+		partial   bool
+		stream    *ttsStream
+	}{filepath, text, round, 1, false, nil}
+	return round, nil
+}
+
+// speakAndClose 播报告别语，播放完毕后关闭连接；告别语为空或播报失败时直接关闭
+func (h *ConnectionHandler) speakAndClose(text string) {
+	h.voice.Fire(EventFarewell)
+	if text != "" {
+		err := h.proactiveSpeak(text)
+		if err == nil {
+			// 告别语被打断或合成卡住时不会走到播放结束的回调，超时后强制关闭
+			time.AfterFunc(farewellCloseWait, h.closeConn)
+			return
+		}
+		h.logger.Error(fmt.Sprintf("播报告别语失败: %v", err))
+	}
+	h.closeConn()
+}
+
+// closeConn 服务端主动断开连接，主消息循环读取失败后退出并归还资源
+func (h *ConnectionHandler) closeConn() {
+	select {
+	case <-h.stopChan:
+		return
+	default:
+	}
+	h.closeConnOnce.Do(func() {
+		h.logger.Info("服务端主动关闭连接")
+		if err := h.conn.Close(); err != nil {
+			h.logger.Error(fmt.Sprintf("关闭连接失败: %v", err))
+		}
+	})
+}
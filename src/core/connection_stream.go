@@ -0,0 +1,171 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/metrics"
+)
+
+// maxStreamAudio 流式合成时编码好但尚未下发的音频最多缓存多长，超过后暂停读取合成结果
+const maxStreamAudio = 2 * time.Minute
+
+// ttsStream 一句正在流式合成的音频，由发送协程解码下发，丢弃时需调用 close 结束合成
+type ttsStream struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	audio    *tts.Stream // 合成输出的 mp3 数据块
+	provider string      // 提供者 type，用于耗时统计
+	start    time.Time
+}
+
+// close 结束合成，可重复调用
+func (s *ttsStream) close() {
+	if s == nil {
+		return
+	}
+	s.cancel()
+}
+
+// streamTTSEnabled 是否使用流式合成
+// 响度归一化需要完整音频，PCM 下发按整句发送，这两种情况仍走整句合成
+func (h *ConnectionHandler) streamTTSEnabled() bool {
+	cfg := h.config.AudioOutput
+	return cfg.StreamTTS && !cfg.Loudness.Enabled && h.serverAudioFormat == "opus"
+}
+
+// openTTSStream 用主TTS开始流式合成，收到首个数据块后返回；
+// 未启用、主TTS不支持流式、处于降级期或开始合成失败时返回 nil，由调用方改用整句合成
+func (h *ConnectionHandler) openTTSStream(text string, textIndex int) *ttsStream {
+	if !h.streamTTSEnabled() {
+		return nil
+	}
+	synth, ok := h.providers.tts.(tts.StreamSynthesizer)
+	if !ok {
+		return nil
+	}
+	if len(h.providers.ttsFallbacks) > 0 && time.Now().Before(h.ttsDegradedUntil) {
+		return nil
+	}
+
+	provider := h.providerType("TTS", h.config.SelectedModule["TTS"])
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	audio, err := synth.ToTTSStream(ctx, text)
+	if err != nil {
+		cancel()
+		metrics.ObserveProvider(metrics.StageTTS, provider, time.Since(start), err)
+		metrics.TTSFailures.WithLabelValues(provider).Inc()
+		h.logger.Warn(fmt.Sprintf("流式合成失败，改用整句合成: %v, 索引: %d", err, textIndex))
+		return nil
+	}
+	return &ttsStream{ctx: ctx, cancel: cancel, audio: audio, provider: provider, start: start}
+}
+
+// sendStreamAudio 边解码编码边下发流式合成的音频，返回是否完整发送
+func (h *ConnectionHandler) sendStreamAudio(stream *ttsStream, text string, textIndex int, round int) bool {
+	bufferFrames := int(maxStreamAudio / (time.Duration(h.serverAudioFrameDuration) * time.Millisecond))
+	frames := make(chan []byte, bufferFrames)
+	started := make(chan struct{})
+	decoded := make(chan error, 1)
+	go func() {
+		decoded <- h.encodeTTSStream(stream, frames, started)
+	}()
+
+	// 等到编码出首帧再通知客户端开始播放本句
+	select {
+	case <-started:
+	case err := <-decoded:
+		select {
+		case <-started:
+			decoded <- err
+		default:
+			if err != nil {
+				h.logger.Error(fmt.Sprintf("流式音频解码失败: %v", err))
+			} else {
+				h.logger.Error("流式合成没有可下发的音频")
+			}
+			return false
+		}
+	case <-h.stopChan:
+		return false
+	}
+
+	if err := h.sendTTSMessage("sentence_start", text, textIndex); err != nil {
+		h.logger.Error(fmt.Sprintf("发送TTS开始状态失败: %v", err))
+		return false
+	}
+	h.recordPlayed(text, round)
+
+	if textIndex == 1 {
+		spentTime := time.Since(h.roundStart())
+		h.logger.Info(fmt.Sprintf("回复首句耗时 %s 第一句话【%s】, round: %d", spentTime, text, round))
+	}
+	h.logger.Info(fmt.Sprintf("TTS流式发送(%s): \"%s\" (索引:%d/%d)", h.serverAudioFormat, text, textIndex, h.tts_last_text_index.Load()))
+
+	sendErr := h.sendAudioStream(frames, 0, text, textIndex, round)
+	// 发送提前结束时停止合成，解码协程随之退出
+	stream.close()
+	if err := <-decoded; err != nil && !errors.Is(err, context.Canceled) {
+		h.logger.Warn(fmt.Sprintf("流式合成中途失败，本句音频不完整: %v", err))
+	}
+	if sendErr != nil {
+		h.logger.Error(fmt.Sprintf("分时发送音频数据失败: %v", sendErr))
+		return false
+	}
+
+	// 发送TTS状态结束通知
+	if err := h.sendTTSMessage("sentence_end", text, textIndex); err != nil {
+		h.logger.Error(fmt.Sprintf("发送TTS结束状态失败: %v", err))
+		return false
+	}
+	return true
+}
+
+// encodeTTSStream 把合成返回的 mp3 数据块解码并编码为 Opus 帧写入 frames，写入首帧后关闭 started
+func (h *ConnectionHandler) encodeTTSStream(stream *ttsStream, frames chan<- []byte, started chan struct{}) error {
+	defer close(frames)
+	encoder, err := utils.NewOpusStreamEncoder(utils.OutputSampleRate, 1, h.opusOptions(), h.serverAudioFrameDuration)
+	if err != nil {
+		return err
+	}
+	defer encoder.Close()
+
+	first := true
+	push := func(packets [][]byte) error {
+		for _, packet := range packets {
+			select {
+			case frames <- packet:
+			case <-stream.ctx.Done():
+				return stream.ctx.Err()
+			}
+			if first {
+				first = false
+				close(started)
+			}
+		}
+		return nil
+	}
+	err = utils.DecodeMP3Stream(utils.ChunkReader(stream.audio.Chunks), func(pcm []byte) error {
+		return push(encoder.Encode(pcm))
+	})
+	if err == nil {
+		err = push(encoder.Flush())
+	}
+	// 合成中途失败时已收到的部分照常下发，失败原因交给调用方记录
+	if err == nil {
+		err = stream.audio.Err()
+	}
+	// 被打断而取消的合成不计入耗时统计
+	if stream.ctx.Err() == nil {
+		metrics.ObserveProvider(metrics.StageTTS, stream.provider, time.Since(stream.start), err)
+		if err != nil {
+			metrics.TTSFailures.WithLabelValues(stream.provider).Inc()
+		}
+	}
+	return err
+}
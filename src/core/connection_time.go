@@ -0,0 +1,65 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// location 返回设备所在时区：设备配置优先，其次 server.timezone，都未配置或无效时使用服务器本地时区
+func (h *ConnectionHandler) location() *time.Location {
+	h.locOnce.Do(func() {
+		h.loc = time.Local
+		name := h.config.Server.Timezone
+		if device, ok := h.config.Devices[h.deviceID]; ok && h.deviceID != "" && device.Timezone != "" {
+			name = device.Timezone
+		}
+		if name == "" {
+			return
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			h.logger.Warn(fmt.Sprintf("时区配置无效: %s，使用服务器本地时区: %v", name, err))
+			return
+		}
+		h.loc = loc
+	})
+	return h.loc
+}
+
+// now 返回设备时区的当前时间
+func (h *ConnectionHandler) now() time.Time {
+	return time.Now().In(h.location())
+}
+
+// registerTimeFunction 注册 get_time，按设备时区回答当前日期与时间
+func (h *ConnectionHandler) registerTimeFunction() {
+	tool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "get_time",
+			Description: "获取设备所在地的当前日期、星期与时间，用户问现在几点、今天几号、星期几时调用",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+	}
+	if err := h.functionRegister.RegisterLocalFunction("get_time", tool, h.handleGetTime); err != nil {
+		h.logger.Error(fmt.Sprintf("注册本地函数失败: get_time, 错误: %v", err))
+	}
+}
+
+func (h *ConnectionHandler) handleGetTime(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	now := h.now()
+	result := fmt.Sprintf("当前时间: %d年%d月%d日 %s %s，时区: %s",
+		now.Year(), now.Month(), now.Day(), weekdayNames[now.Weekday()], now.Format("15:04"), now.Location())
+	if device, ok := h.config.Devices[h.deviceID]; ok && h.deviceID != "" && device.City != "" {
+		result += "，所在城市: " + device.City
+	}
+	return types.ActionResponse{Action: types.ActionTypeReqLLM, Result: result}
+}
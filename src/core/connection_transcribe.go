@@ -0,0 +1,251 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/transcript"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// 客户端 listen 消息中不进入对话的拾音模式
+const (
+	listenModeTranscribe = "transcribe" // 会议转写，开始拾音即开始转写，停止拾音时结束
+	listenModeDictation  = "dictation"  // 听写，识别结果以 stt 消息回传客户端，供输入法、备忘录使用
+)
+
+// defaultTranscriptionEndWords 未配置 end_words 时语音结束转写的说法
+var defaultTranscriptionEndWords = []string{"结束记录", "停止记录", "结束会议记录", "结束录音"}
+
+// transcription 连接上进行中的会议转写
+type transcription struct {
+	record   *transcript.Transcript
+	byClient bool // 由 listen mode=transcribe 开始，客户端停止拾音时结束
+}
+
+// registerTranscriptionFunction 注册 start_transcription，用户用语音开始会议记录
+func (h *ConnectionHandler) registerTranscriptionFunction() {
+	tool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "start_transcription",
+			Description: "开始会议记录（录音转写），之后只记录大家说的话，不再回答，结束后生成摘要。用户说“开始会议记录”“帮我录一下”时调用",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+	}
+	if err := h.functionRegister.RegisterLocalFunction("start_transcription", tool, h.handleStartTranscription); err != nil {
+		h.logger.Error(fmt.Sprintf("注册本地函数失败: start_transcription, 错误: %v", err))
+	}
+}
+
+func (h *ConnectionHandler) handleStartTranscription(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	if err := h.startTranscription(false); err != nil {
+		h.logger.Error(fmt.Sprintf("开始会议转写失败: %v", err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "会议记录没能开始，稍后再试试吧"}
+	}
+	return types.ActionResponse{
+		Action:   types.ActionTypeResponse,
+		Response: fmt.Sprintf("好的，开始会议记录，说“%s”就可以结束", h.transcriptionEndWords()[0]),
+	}
+}
+
+func (h *ConnectionHandler) transcriptionEndWords() []string {
+	if words := h.config.Transcription.EndWords; len(words) > 0 {
+		return words
+	}
+	return defaultTranscriptionEndWords
+}
+
+// transcribing 是否正在会议转写
+func (h *ConnectionHandler) transcribing() bool {
+	h.transcribeMu.Lock()
+	defer h.transcribeMu.Unlock()
+	return h.transcription != nil
+}
+
+// startTranscription 开始会议转写，已在转写时不重复开始
+func (h *ConnectionHandler) startTranscription(byClient bool) error {
+	h.transcribeMu.Lock()
+	defer h.transcribeMu.Unlock()
+	if h.transcription != nil {
+		return nil
+	}
+	record, err := transcript.Create(h.deviceID, h.sessionID, time.Now())
+	if err != nil {
+		return err
+	}
+	h.transcription = &transcription{record: record, byClient: byClient}
+	h.logger.Info(fmt.Sprintf("开始会议转写, 记录ID: %d", record.ID))
+	if byClient {
+		h.sendTranscriptMessage("start", record)
+	}
+	return nil
+}
+
+// stopTranscription 结束会议转写并在后台生成摘要，没有在转写时返回 nil
+func (h *ConnectionHandler) stopTranscription() *transcript.Transcript {
+	h.transcribeMu.Lock()
+	session := h.transcription
+	h.transcription = nil
+	h.transcribeMu.Unlock()
+	if session == nil {
+		return nil
+	}
+
+	record := session.record
+	endedAt := time.Now()
+	record.EndedAt = &endedAt
+	record.Status = transcript.StatusSummarizing
+	if len(record.Segments) == 0 || h.summarizeTranscript == nil {
+		record.Status = transcript.StatusDone
+	}
+	if err := transcript.Save(record); err != nil {
+		h.logger.Error(fmt.Sprintf("保存转写记录失败: %v", err))
+	}
+	h.logger.Info(fmt.Sprintf("结束会议转写, 记录ID: %d, 共 %d 句", record.ID, len(record.Segments)))
+	if session.byClient {
+		h.sendTranscriptMessage("stop", record)
+	}
+	if record.Status == transcript.StatusSummarizing {
+		go h.summarizeTranscript(record)
+	}
+	return record
+}
+
+// onTranscriptResult 转写模式下的 ASR 结果：带时间戳与说话人记录，不进入对话
+// 返回值同 OnAsrResult，一句结束后复位 ASR 继续识别下一句
+func (h *ConnectionHandler) onTranscriptResult(text string) bool {
+	if text == "" {
+		return false
+	}
+	detail := h.takeAsrResult(text)
+	for _, word := range h.transcriptionEndWords() {
+		if strings.Contains(text, word) {
+			h.providers.asr.Reset()
+			record := h.stopTranscription()
+			if record != nil {
+				reply := fmt.Sprintf("会议记录已结束，共记录 %d 句", len(record.Segments))
+				if record.Status == transcript.StatusSummarizing {
+					reply += "，摘要生成后可以下载"
+				}
+				if err := h.proactiveSpeak(reply); err != nil {
+					h.logger.Error(fmt.Sprintf("播报转写结束提示失败: %v", err))
+				}
+			}
+			return true
+		}
+	}
+
+	h.transcribeMu.Lock()
+	session := h.transcription
+	if session == nil {
+		h.transcribeMu.Unlock()
+		return false
+	}
+	record := session.record
+	segment := transcript.Segment{End: time.Since(record.StartedAt).Milliseconds(), Text: text}
+	segment.Start = segment.End
+	if detail != nil && len(detail.Utterances) > 0 {
+		first, last := detail.Utterances[0], detail.Utterances[len(detail.Utterances)-1]
+		segment.Start -= int64(last.EndTime - first.StartTime)
+		segment.Speaker = first.Speaker
+	}
+	if segment.Start < 0 {
+		segment.Start = 0
+	}
+	record.Segments = append(record.Segments, segment)
+	err := transcript.Save(record)
+	h.transcribeMu.Unlock()
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("保存转写内容失败: %v", err))
+	}
+
+	if err := h.sendSTTMessage(text); err != nil {
+		h.logger.Error(fmt.Sprintf("发送转写结果失败: %v", err))
+	}
+	h.providers.asr.Reset()
+	return true
+}
+
+// sendTranscriptMessage 通知以转写模式拾音的客户端转写开始或结束，客户端可按记录ID下载结果
+func (h *ConnectionHandler) sendTranscriptMessage(state string, record *transcript.Transcript) {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":       "transcript",
+		"state":      state,
+		"id":         record.ID,
+		"session_id": h.sessionID,
+	})
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("序列化转写消息失败: %v", err))
+		return
+	}
+	if err := h.conn.WriteMessage(1, data); err != nil {
+		h.logger.Error(fmt.Sprintf("发送转写消息失败: %v", err))
+	}
+}
+
+const (
+	transcriptSummaryTimeout  = 2 * time.Minute
+	transcriptSummaryMaxRunes = 30000 // 送给 LLM 的转写内容上限，超出部分截断
+
+	defaultTranscriptSummaryPrompt = "下面是一段会议的逐句转写，请用中文整理会议摘要：先用一两句话概括主题，再分条列出讨论要点、结论和待办事项（有负责人时注明）。只根据转写内容整理，不要编造。"
+)
+
+// summarizeTranscript 借用一组提供者，由 LLM 为转写记录生成摘要
+// 在后台执行，连接断开后也能完成
+func (ws *WebSocketServer) summarizeTranscript(record *transcript.Transcript) {
+	defer func() {
+		record.Status = transcript.StatusDone
+		if err := transcript.Save(record); err != nil {
+			ws.logger.Error(fmt.Sprintf("保存转写摘要失败: %v", err))
+		}
+	}()
+	set, err := ws.poolManager.GetProviderSet()
+	if err != nil {
+		ws.logger.Warn(fmt.Sprintf("生成转写摘要时获取提供者失败: %v", err))
+		return
+	}
+	defer ws.poolManager.ReturnProviderSet(set)
+	if set.LLM == nil {
+		return
+	}
+
+	content := []rune(record.Text())
+	if len(content) > transcriptSummaryMaxRunes {
+		content = append(content[:transcriptSummaryMaxRunes], []rune("\n（后续内容过长已省略）")...)
+	}
+	prompt := ws.config.Transcription.SummaryPrompt
+	if prompt == "" {
+		prompt = defaultTranscriptSummaryPrompt
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), transcriptSummaryTimeout)
+	defer cancel()
+	messages := []providers.Message{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: string(content)},
+	}
+	responseChan, err := set.LLM.Response(ctx, fmt.Sprintf("transcript-%d", record.ID), messages)
+	if err != nil {
+		ws.logger.Warn(fmt.Sprintf("生成转写摘要失败: %v", err))
+		return
+	}
+	var sb strings.Builder
+	for text := range responseChan {
+		sb.WriteString(text)
+	}
+	if ctx.Err() != nil {
+		ws.logger.Warn(fmt.Sprintf("生成转写摘要超时, 记录ID: %d", record.ID))
+		return
+	}
+	record.Summary = strings.TrimSpace(sb.String())
+	ws.logger.Info(fmt.Sprintf("转写摘要已生成, 记录ID: %d", record.ID))
+}
@@ -0,0 +1,323 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	translationTimeout = 20 * time.Second // 单句翻译的超时
+
+	defaultTranslationPrompt = "你是同声传译，把用户说的话翻译成{{.Language}}，只输出译文，不要解释或回答其中的问题。" +
+		"如果用户说的已经是{{.Language}}，就翻译成中文。"
+	defaultInterpretationPrompt = "你是现场口译员，把这句话翻译成{{.Language}}，只输出译文，不要解释或回答其中的问题。"
+)
+
+// defaultTranslationExitWords 未配置 exit_words 时退出翻译模式的说法
+var defaultTranslationExitWords = []string{"退出翻译", "结束翻译", "关闭翻译", "停止翻译"}
+
+// translationMode 连接上的翻译模式状态
+type translationMode struct {
+	language  configs.TranslationLanguage  // 单向翻译的目标语言，双向口译时为其中一种语言
+	peer      *configs.TranslationLanguage // 双向口译的另一种语言，为 nil 表示单向翻译
+	prevVoice string                       // 进入前的音色，为空表示使用配置的音色
+}
+
+// name 模式名称，用于播报与日志
+func (m *translationMode) name() string {
+	if m.peer != nil {
+		return fmt.Sprintf("%s和%s双向口译", m.language.Name, m.peer.Name)
+	}
+	return m.language.Name + "翻译"
+}
+
+// direction 判断这句话的源语言，返回源语言与目标语言
+// 优先使用 ASR 检测到的语种，ASR 未检测语种时按文字判断，仍无法判断时按第一种语言翻译成第二种
+func (m *translationMode) direction(text, asrLanguage string) (configs.TranslationLanguage, configs.TranslationLanguage) {
+	a, b := m.language, *m.peer
+	for _, lang := range []string{asrLanguage, scriptLanguage(text)} {
+		if lang == "" {
+			continue
+		}
+		if sameLanguage(b.Code, lang) {
+			return b, a
+		}
+		if sameLanguage(a.Code, lang) {
+			return a, b
+		}
+	}
+	// 拉丁字母的句子，另一种语言是中日韩语言时视为非中日韩一方
+	if scriptLanguage(text) == "latin" && isCJKLanguage(a.Code) != isCJKLanguage(b.Code) {
+		if isCJKLanguage(a.Code) {
+			return b, a
+		}
+	}
+	return a, b
+}
+
+// scriptLanguage 按文字粗略判断语种：假名为 ja，谚文为 ko，汉字为 zh，拉丁字母为 latin
+func scriptLanguage(text string) string {
+	var han, latin bool
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			return "ja"
+		case unicode.Is(unicode.Hangul, r):
+			return "ko"
+		case unicode.Is(unicode.Han, r):
+			han = true
+		case unicode.Is(unicode.Latin, r):
+			latin = true
+		}
+	}
+	switch {
+	case han:
+		return "zh"
+	case latin:
+		return "latin"
+	}
+	return ""
+}
+
+// sameLanguage 比较语言代码的主语种，en 与 en-US 视为相同
+func sameLanguage(code, lang string) bool {
+	if code == "" {
+		return false
+	}
+	primary := func(s string) string {
+		s, _, _ = strings.Cut(strings.ReplaceAll(s, "_", "-"), "-")
+		return strings.ToLower(s)
+	}
+	return primary(code) == primary(lang)
+}
+
+func isCJKLanguage(code string) bool {
+	return sameLanguage(code, "zh") || sameLanguage(code, "ja") || sameLanguage(code, "ko")
+}
+
+// findTranslationLanguage 按名称查找配置的翻译语言
+func (h *ConnectionHandler) findTranslationLanguage(name string) *configs.TranslationLanguage {
+	name = strings.TrimSpace(name)
+	for i := range h.config.Translation.Languages {
+		if h.config.Translation.Languages[i].Name == name {
+			return &h.config.Translation.Languages[i]
+		}
+	}
+	return nil
+}
+
+// registerTranslationFunction 配置了翻译语言时注册 start_translation，配置两种以上时同时注册 start_interpretation
+func (h *ConnectionHandler) registerTranslationFunction() {
+	languages := h.config.Translation.Languages
+	if len(languages) == 0 {
+		return
+	}
+	names := make([]string, 0, len(languages))
+	for _, l := range languages {
+		names = append(names, l.Name)
+	}
+	if len(languages) >= 2 {
+		h.registerInterpretationFunction(names)
+	}
+	tool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name:        "start_translation",
+			Description: "进入翻译模式，之后用户说的每句话都直接翻译成目标语言播报，用户说“进入英语翻译模式”“帮我翻译成日语”时调用",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"language": map[string]interface{}{
+						"type":        "string",
+						"description": "目标语言",
+						"enum":        names,
+					},
+				},
+				"required": []string{"language"},
+			},
+		},
+	}
+	if err := h.functionRegister.RegisterLocalFunction("start_translation", tool, h.handleStartTranslation); err != nil {
+		h.logger.Error(fmt.Sprintf("注册本地函数失败: start_translation, 错误: %v", err))
+	}
+}
+
+func (h *ConnectionHandler) registerInterpretationFunction(names []string) {
+	languageParam := func(description string) map[string]interface{} {
+		return map[string]interface{}{"type": "string", "description": description, "enum": names}
+	}
+	tool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name: "start_interpretation",
+			Description: "进入双向口译模式，自动判断每句话是哪种语言并翻译成另一种播报，适合两个人用不同语言对话。" +
+				"用户说“开启中英同声传译”“帮我和他做口译”时调用",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"language_a": languageParam("其中一种语言，通常是用户自己说的语言"),
+					"language_b": languageParam("另一种语言"),
+				},
+				"required": []string{"language_a", "language_b"},
+			},
+		},
+	}
+	if err := h.functionRegister.RegisterLocalFunction("start_interpretation", tool, h.handleStartInterpretation); err != nil {
+		h.logger.Error(fmt.Sprintf("注册本地函数失败: start_interpretation, 错误: %v", err))
+	}
+}
+
+func (h *ConnectionHandler) handleStartTranslation(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	name, _ := args["language"].(string)
+	language := h.findTranslationLanguage(name)
+	if language == nil {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: fmt.Sprintf("暂时还不能翻译成%s哦", name)}
+	}
+	h.enterTranslation(&translationMode{language: *language})
+	if language.Voice != "" {
+		if err := h.setTTSVoice(language.Voice); err != nil {
+			h.logger.Warn(fmt.Sprintf("切换翻译音色 %s 失败: %v", language.Voice, err))
+		}
+	}
+	return types.ActionResponse{
+		Action:   types.ActionTypeResponse,
+		Response: fmt.Sprintf("好的，已进入%s模式，说“退出翻译”就可以结束", h.translation.name()),
+	}
+}
+
+func (h *ConnectionHandler) handleStartInterpretation(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	nameA, _ := args["language_a"].(string)
+	nameB, _ := args["language_b"].(string)
+	a, b := h.findTranslationLanguage(nameA), h.findTranslationLanguage(nameB)
+	switch {
+	case a == nil:
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: fmt.Sprintf("暂时还不支持%s的口译哦", nameA)}
+	case b == nil:
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: fmt.Sprintf("暂时还不支持%s的口译哦", nameB)}
+	case a.Name == b.Name:
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "口译需要两种不同的语言，你们分别说什么语言呢？"}
+	}
+	h.enterTranslation(&translationMode{language: *a, peer: b})
+	return types.ActionResponse{
+		Action:   types.ActionTypeResponse,
+		Response: fmt.Sprintf("好的，已进入%s模式，说“退出翻译”就可以结束", h.translation.name()),
+	}
+}
+
+// enterTranslation 进入翻译模式，从一种翻译模式切换到另一种时保留最初的音色
+func (h *ConnectionHandler) enterTranslation(mode *translationMode) {
+	mode.prevVoice = h.ttsVoice
+	if h.translation != nil {
+		mode.prevVoice = h.translation.prevVoice
+	}
+	h.translation = mode
+	h.logger.Info(fmt.Sprintf("进入翻译模式: %s", mode.name()))
+}
+
+// isTranslationExit 判断用户是否要退出翻译模式
+func (h *ConnectionHandler) isTranslationExit(text string) bool {
+	words := h.config.Translation.ExitWords
+	if len(words) == 0 {
+		words = defaultTranslationExitWords
+	}
+	for _, word := range words {
+		if strings.Contains(text, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// exitTranslation 退出翻译模式并恢复进入前的音色
+func (h *ConnectionHandler) exitTranslation() {
+	mode := h.translation
+	h.translation = nil
+	if h.ttsVoice == mode.prevVoice {
+		return
+	}
+	if mode.prevVoice != "" {
+		if err := h.setTTSVoice(mode.prevVoice); err != nil {
+			h.logger.Warn(fmt.Sprintf("恢复音色 %s 失败: %v", mode.prevVoice, err))
+		}
+		return
+	}
+	if resetter, ok := h.providers.tts.(interface{ Reset() error }); ok {
+		if err := resetter.Reset(); err != nil {
+			h.logger.Warn(fmt.Sprintf("恢复默认音色失败: %v", err))
+			return
+		}
+		h.ttsVoice = ""
+	}
+}
+
+// translate 翻译模式下的一轮：不写入对话历史，由 LLM 翻译后用目标语言音色播报
+func (h *ConnectionHandler) translate(ctx context.Context, text string, round int) error {
+	h.voice.Fire(EventSpeakStart)
+	mode := h.translation
+	if h.isTranslationExit(text) {
+		h.exitTranslation()
+		h.logger.Info(fmt.Sprintf("退出翻译模式: %s", mode.name()))
+		return h.speakTranslation(fmt.Sprintf("好的，已退出%s模式", mode.name()), round)
+	}
+
+	target := mode.language
+	prompt := h.config.Translation.Prompt
+	if mode.peer != nil {
+		var source configs.TranslationLanguage
+		source, target = mode.direction(text, h.asrLanguage)
+		h.logger.Debug(fmt.Sprintf("口译方向: %s -> %s, ASR语种: %s", source.Name, target.Name, h.asrLanguage))
+		if prompt == "" {
+			prompt = defaultInterpretationPrompt
+		}
+		// 双向口译每句按目标语言切换音色
+		if target.Voice != "" && target.Voice != h.ttsVoice {
+			if err := h.setTTSVoice(target.Voice); err != nil {
+				h.logger.Warn(fmt.Sprintf("切换口译音色 %s 失败: %v", target.Voice, err))
+			}
+		}
+	}
+	if prompt == "" {
+		prompt = defaultTranslationPrompt
+	}
+	messages := []providers.Message{
+		{Role: "system", Content: strings.ReplaceAll(prompt, "{{.Language}}", target.Name)},
+		{Role: "user", Content: text},
+	}
+	ctx, cancel := context.WithTimeout(ctx, translationTimeout)
+	defer cancel()
+	responseChan, err := h.providers.llm.Response(ctx, h.sessionID, messages)
+	if err != nil {
+		h.endSpeakIfNothingQueued()
+		return fmt.Errorf("LLM翻译失败: %v", err)
+	}
+	var sb strings.Builder
+	for content := range responseChan {
+		sb.WriteString(content)
+	}
+	translated := strings.TrimSpace(sb.String())
+	if ctx.Err() != nil || translated == "" {
+		h.logger.Warn(fmt.Sprintf("翻译失败: %s, %v", text, ctx.Err()))
+		return h.speakTranslation("抱歉，这句没能翻译出来，请再说一次", round)
+	}
+	h.logger.Info(fmt.Sprintf("翻译结果(%s): %s -> %s", target.Name, text, translated))
+	return h.speakTranslation(translated, round)
+}
+
+func (h *ConnectionHandler) speakTranslation(text string, round int) error {
+	if err := h.SpeakAndPlay(text, 1, round); err != nil {
+		h.logger.Error(fmt.Sprintf("播放翻译结果失败: %v", err))
+		h.endSpeakIfNothingQueued()
+		return nil
+	}
+	h.tts_last_text_index.Store(1)
+	return nil
+}
@@ -0,0 +1,259 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/profile"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// maxVoicesInTool 函数描述中最多列出的音色数，音色过多时只列前面的部分
+const maxVoicesInTool = 30
+
+// voiceWords 描述音色时的通用词，模糊匹配前去掉
+var voiceWords = []string{"换成", "换个", "切换成", "切换到", "改成", "用", "一个", "的", "声音", "音色", "嗓音", "声"}
+
+// voiceLanguageWords 描述语言的词与音色 language 前缀的对应
+var voiceLanguageWords = map[string]string{
+	"普通话": "zh", "中文": "zh", "英文": "en", "英语": "en", "日语": "ja", "日文": "ja",
+	"韩语": "ko", "粤语": "yue", "台湾": "zh-TW", "法语": "fr", "德语": "de", "西班牙语": "es",
+}
+
+// 音色列表缓存，同一TTS配置的连接共用，避免每个连接都去查询提供者
+const (
+	voiceListTTL      = 10 * time.Minute
+	voiceListRetryTTL = time.Minute // 查询失败时改用配置的音色，过一段时间再重新查询
+	voiceListTimeout  = 3 * time.Second
+)
+
+type voiceListEntry struct {
+	mu        sync.Mutex
+	voices    []tts.VoiceInfo
+	expiresAt time.Time
+}
+
+var (
+	voiceListMu    sync.Mutex
+	voiceListCache = make(map[string]*voiceListEntry)
+)
+
+// supportedVoices 主TTS可切换的音色列表，不支持切换音色时返回 nil；
+// 提供者实现了 VoiceLister 时使用其查询结果，否则使用配置的音色
+func (h *ConnectionHandler) supportedVoices() []tts.VoiceInfo {
+	if _, ok := h.providers.tts.(tts.VoiceSetter); !ok {
+		return nil
+	}
+	var configured []tts.VoiceInfo
+	if catalog, ok := h.providers.tts.(interface{ SurportedVoices() []tts.VoiceInfo }); ok {
+		configured = catalog.SurportedVoices()
+	}
+	lister, ok := h.providers.tts.(tts.VoiceLister)
+	if !ok {
+		return configured
+	}
+
+	name := h.config.SelectedModule["TTS"]
+	voiceListMu.Lock()
+	entry, ok := voiceListCache[name]
+	if !ok {
+		entry = &voiceListEntry{}
+		voiceListCache[name] = entry
+	}
+	voiceListMu.Unlock()
+
+	// 同一配置同时只查询一次，其余连接等待结果
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if time.Now().Before(entry.expiresAt) {
+		return entry.voices
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), voiceListTimeout)
+	defer cancel()
+	voices, err := lister.ListVoices(ctx)
+	if err != nil || len(voices) == 0 {
+		if err != nil {
+			h.logger.Warn(fmt.Sprintf("查询TTS %s 的音色列表失败，使用配置的音色: %v", name, err))
+		}
+		entry.voices, entry.expiresAt = configured, time.Now().Add(voiceListRetryTTL)
+		return configured
+	}
+	entry.voices, entry.expiresAt = voices, time.Now().Add(voiceListTTL)
+	return voices
+}
+
+// registerVoiceFunction 主TTS配置了可切换音色时注册 change_voice
+func (h *ConnectionHandler) registerVoiceFunction() {
+	voices := h.supportedVoices()
+	if len(voices) == 0 {
+		return
+	}
+	descriptions := make([]string, 0, len(voices))
+	for i, voice := range voices {
+		if i == maxVoicesInTool {
+			break
+		}
+		descriptions = append(descriptions, describeVoice(voice))
+	}
+	tool := openai.Tool{
+		Type: openai.ToolTypeFunction,
+		Function: &openai.FunctionDefinition{
+			Name: "change_voice",
+			Description: "用户要求换一种声音时调用，如“换成男声”“用温柔一点的女声”。可选音色：" + strings.Join(descriptions, "；") +
+				"。能确定具体音色时传音色名称，否则直接传用户的描述",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"voice": map[string]interface{}{
+						"type":        "string",
+						"description": "音色名称，或对声音的描述如“男声”“英文女声”",
+					},
+				},
+				"required": []string{"voice"},
+			},
+		},
+	}
+	if err := h.functionRegister.RegisterLocalFunction("change_voice", tool, h.handleChangeVoice); err != nil {
+		h.logger.Error(fmt.Sprintf("注册本地函数失败: change_voice, 错误: %v", err))
+	}
+}
+
+// describeVoice 音色的简短描述，如 longxiaocheng_v2（龙小诚，男声，zh-CN，阳光男声）
+func describeVoice(voice tts.VoiceInfo) string {
+	var parts []string
+	if voice.DisplayName != "" {
+		parts = append(parts, voice.DisplayName)
+	}
+	switch voice.Gender {
+	case "male":
+		parts = append(parts, "男声")
+	case "female":
+		parts = append(parts, "女声")
+	}
+	if voice.Language != "" {
+		parts = append(parts, voice.Language)
+	}
+	if voice.Description != "" {
+		parts = append(parts, voice.Description)
+	}
+	if len(parts) == 0 {
+		return voice.Name
+	}
+	return voice.Name + "（" + strings.Join(parts, "，") + "）"
+}
+
+func (h *ConnectionHandler) handleChangeVoice(ctx context.Context, args map[string]interface{}) types.ActionResponse {
+	query, _ := args["voice"].(string)
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return types.ActionResponse{Action: types.ActionTypeError, Result: "voice 不能为空"}
+	}
+	voice := matchVoice(h.supportedVoices(), query, h.ttsVoice)
+	if voice == nil {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: fmt.Sprintf("没有找到%s这样的声音哦", query)}
+	}
+	name := voice.DisplayName
+	if name == "" {
+		name = voice.Name
+	}
+	if voice.Name == h.ttsVoice {
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: fmt.Sprintf("现在用的就是%s的声音哦", name)}
+	}
+	if err := h.setTTSVoice(voice.Name); err != nil {
+		h.logger.Warn(fmt.Sprintf("切换音色 %s 失败: %v", voice.Name, err))
+		return types.ActionResponse{Action: types.ActionTypeResponse, Response: "声音暂时没能换过来，请稍后再试"}
+	}
+	if h.deviceID != "" {
+		if err := profile.SaveVoice(h.deviceID, h.config.SelectedModule["TTS"], voice.Name); err != nil {
+			h.logger.Warn(fmt.Sprintf("保存设备 %s 的音色失败: %v", h.deviceID, err))
+		}
+	}
+	h.logger.Info(fmt.Sprintf("切换音色: %s（查询: %s）", voice.Name, query))
+	h.warmQuickReplies()
+	return types.ActionResponse{Action: types.ActionTypeResponse, Response: fmt.Sprintf("好的，已经换成%s的声音啦", name)}
+}
+
+// hasVoice 音色名称是否在可切换列表中
+func hasVoice(voices []tts.VoiceInfo, name string) bool {
+	for _, voice := range voices {
+		if voice.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// matchVoice 按名称或描述在可切换音色中查找，名称完全相同时直接返回；
+// 否则按名称包含、性别、语言、描述打分，同分时优先选与当前音色不同的，没有匹配时返回 nil
+func matchVoice(voices []tts.VoiceInfo, query, current string) *tts.VoiceInfo {
+	lower := strings.ToLower(query)
+	for i := range voices {
+		if strings.ToLower(voices[i].Name) == lower || voices[i].DisplayName == query {
+			return &voices[i]
+		}
+	}
+
+	keyword := lower
+	for _, word := range voiceWords {
+		keyword = strings.ReplaceAll(keyword, word, "")
+	}
+	gender := ""
+	switch {
+	case strings.Contains(keyword, "男"):
+		gender = "male"
+		keyword = strings.ReplaceAll(keyword, "男", "")
+	case strings.Contains(keyword, "女"):
+		gender = "female"
+		keyword = strings.ReplaceAll(keyword, "女", "")
+	}
+	language := ""
+	for word, code := range voiceLanguageWords {
+		if strings.Contains(keyword, word) {
+			language = code
+			keyword = strings.ReplaceAll(keyword, word, "")
+			break
+		}
+	}
+	keyword = strings.TrimSpace(keyword)
+
+	var best *tts.VoiceInfo
+	bestScore := 0
+	for i := range voices {
+		voice := &voices[i]
+		if gender != "" && voice.Gender != "" && voice.Gender != gender {
+			continue
+		}
+		if language != "" && !strings.HasPrefix(strings.ToLower(voice.Language), strings.ToLower(language)) {
+			continue
+		}
+		score := 0
+		if gender != "" && voice.Gender == gender {
+			score += 10
+		}
+		if language != "" {
+			score += 10
+		}
+		if keyword != "" {
+			switch {
+			case strings.Contains(strings.ToLower(voice.Name), keyword),
+				voice.DisplayName != "" && (strings.Contains(voice.DisplayName, keyword) || strings.Contains(keyword, voice.DisplayName)):
+				score += 50
+			case strings.Contains(voice.Description, keyword):
+				score += 20
+			}
+		}
+		if score == 0 {
+			continue
+		}
+		if score > bestScore || (score == bestScore && best.Name == current) {
+			best, bestScore = voice, score
+		}
+	}
+	return best
+}
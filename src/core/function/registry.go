@@ -1,19 +1,52 @@
 package function
 
 import (
+	"context"
 	"fmt"
+	"sort"
+
+	"xiaozhi-server-go/src/core/types"
 
 	"github.com/sashabaranov/go-openai"
 )
 
+// FunctionHandler 本地函数的执行逻辑，参数为 LLM 传入的 arguments
+type FunctionHandler func(ctx context.Context, args map[string]interface{}) types.ActionResponse
+
 type FunctionRegistry struct {
 	functions map[string]openai.Tool
+	handlers  map[string]FunctionHandler // 在服务端本地执行的函数
 }
 
 func NewFunctionRegistry() *FunctionRegistry {
 	return &FunctionRegistry{
 		functions: make(map[string]openai.Tool),
+		handlers:  make(map[string]FunctionHandler),
+	}
+}
+
+// RegisterLocalFunction 注册在服务端本地执行的函数
+func (fr *FunctionRegistry) RegisterLocalFunction(name string, function openai.Tool, handler FunctionHandler) error {
+	if err := fr.RegisterFunction(name, function); err != nil {
+		return err
 	}
+	fr.handlers[name] = handler
+	return nil
+}
+
+// IsLocalFunction 判断是否为本地函数
+func (fr *FunctionRegistry) IsLocalFunction(name string) bool {
+	_, exists := fr.handlers[name]
+	return exists
+}
+
+// CallFunction 执行本地函数
+func (fr *FunctionRegistry) CallFunction(ctx context.Context, name string, args map[string]interface{}) types.ActionResponse {
+	handler, exists := fr.handlers[name]
+	if !exists {
+		return types.ActionResponse{Action: types.ActionTypeNotFound, Result: name}
+	}
+	return handler(ctx, args)
 }
 
 func (fr *FunctionRegistry) RegisterFunction(name string, function openai.Tool) error {
@@ -31,10 +64,16 @@ func (fr *FunctionRegistry) GetFunction(name string) (openai.Tool, error) {
 	return openai.Tool{}, fmt.Errorf("function not found: %s", name)
 }
 
+// GetAllFunctions 按名称排序返回所有函数，保持每次请求的工具声明一致以便命中 LLM 的 prompt 缓存
 func (fr *FunctionRegistry) GetAllFunctions() []openai.Tool {
-	functions := make([]openai.Tool, 0, len(fr.functions))
-	for _, function := range fr.functions {
-		functions = append(functions, function)
+	names := make([]string, 0, len(fr.functions))
+	for name := range fr.functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	functions := make([]openai.Tool, 0, len(names))
+	for _, name := range names {
+		functions = append(functions, fr.functions[name])
 	}
 	return functions
 }
@@ -44,6 +83,9 @@ func (fr *FunctionRegistry) UnregisterAllFunctions() error {
 	for name := range fr.functions {
 		delete(fr.functions, name)
 	}
+	for name := range fr.handlers {
+		delete(fr.handlers, name)
+	}
 	return nil
 }
 
@@ -51,6 +93,7 @@ func (fr *FunctionRegistry) UnregisterFunction(name string) error {
 	// Unregister a specific function
 	if _, exists := fr.functions[name]; exists {
 		delete(fr.functions, name)
+		delete(fr.handlers, name)
 	} else {
 		return fmt.Errorf("function not found: %s", name)
 	}
@@ -0,0 +1,189 @@
+package function
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema 工具参数 JSON Schema 中用于预校验的子集
+type Schema struct {
+	Type                 interface{}        `json:"type"` // 字符串或字符串数组
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *Schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	AdditionalProperties interface{}        `json:"additionalProperties"`
+}
+
+// ParseSchema 把工具声明中的 Parameters（map、结构体或 json.RawMessage）统一解析为 Schema
+func ParseSchema(parameters interface{}) (*Schema, error) {
+	if parameters == nil {
+		return nil, nil
+	}
+	var raw []byte
+	switch p := parameters.(type) {
+	case json.RawMessage:
+		raw = p
+	case []byte:
+		raw = p
+	default:
+		data, err := json.Marshal(parameters)
+		if err != nil {
+			return nil, fmt.Errorf("序列化参数声明失败: %v", err)
+		}
+		raw = data
+	}
+	var schema Schema
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, fmt.Errorf("解析参数声明失败: %v", err)
+	}
+	return &schema, nil
+}
+
+// ValidateArguments 按工具的参数声明校验 LLM 给出的 arguments，返回全部不合法之处
+// 只校验必填、类型、枚举与数值范围，声明中没有的字段仅在 additionalProperties 为 false 时报错
+func ValidateArguments(parameters interface{}, args map[string]interface{}) error {
+	schema, err := ParseSchema(parameters)
+	if err != nil || schema == nil {
+		// 声明本身无法解析时不拦截调用
+		return nil
+	}
+	var problems []string
+	schema.validateObject("", args, &problems)
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(problems, "; "))
+}
+
+func (s *Schema) validate(path string, value interface{}, problems *[]string) {
+	if !s.matchType(value) {
+		*problems = append(*problems, fmt.Sprintf("参数 %s 应为 %s 类型，实际为 %s", path, s.typeNames(), jsonType(value)))
+		return
+	}
+	if len(s.Enum) > 0 && !s.inEnum(value) {
+		*problems = append(*problems, fmt.Sprintf("参数 %s 的取值 %v 不在可选范围 %v 内", path, value, s.Enum))
+	}
+	switch v := value.(type) {
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			*problems = append(*problems, fmt.Sprintf("参数 %s 不能小于 %v", path, *s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			*problems = append(*problems, fmt.Sprintf("参数 %s 不能大于 %v", path, *s.Maximum))
+		}
+	case map[string]interface{}:
+		s.validateObject(path, v, problems)
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+	}
+}
+
+func (s *Schema) validateObject(path string, args map[string]interface{}, problems *[]string) {
+	for _, name := range s.Required {
+		if value, ok := args[name]; !ok || value == nil {
+			*problems = append(*problems, fmt.Sprintf("缺少必填参数 %s", joinPath(path, name)))
+		}
+	}
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := args[name]
+		prop, ok := s.Properties[name]
+		if !ok || prop == nil {
+			if allowed, isBool := s.AdditionalProperties.(bool); isBool && !allowed {
+				*problems = append(*problems, fmt.Sprintf("不支持的参数 %s", joinPath(path, name)))
+			}
+			continue
+		}
+		if value == nil {
+			continue
+		}
+		prop.validate(joinPath(path, name), value, problems)
+	}
+}
+
+// types 返回声明的类型列表，未声明时为空表示不限
+func (s *Schema) types() []string {
+	switch t := s.Type.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		names := make([]string, 0, len(t))
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+func (s *Schema) typeNames() string {
+	return strings.Join(s.types(), "/")
+}
+
+func (s *Schema) matchType(value interface{}) bool {
+	declared := s.types()
+	if len(declared) == 0 {
+		return true
+	}
+	actual := jsonType(value)
+	for _, t := range declared {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) inEnum(value interface{}) bool {
+	for _, option := range s.Enum {
+		if jsonType(option) == jsonType(value) && fmt.Sprint(option) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType 返回 json.Unmarshal 结果对应的 JSON Schema 类型名
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,55 @@
+package function
+
+import "xiaozhi-server-go/src/core/types"
+
+// ToolCallAccumulator 聚合流式响应中的工具调用分片
+// 分片按 index 分桶，arguments 在桶内按到达顺序拼接；同一 index 上出现新的 id 时视为新的调用，
+// 兼容把每个调用都标记为 index 0 的服务
+type ToolCallAccumulator struct {
+	calls   []*types.ToolCall
+	byIndex map[int]*types.ToolCall
+}
+
+// NewToolCallAccumulator 创建工具调用聚合器
+func NewToolCallAccumulator() *ToolCallAccumulator {
+	return &ToolCallAccumulator{byIndex: make(map[int]*types.ToolCall)}
+}
+
+// Add 合并一批分片
+func (a *ToolCallAccumulator) Add(fragments []types.ToolCall) {
+	for _, fragment := range fragments {
+		call, ok := a.byIndex[fragment.Index]
+		if !ok || (fragment.ID != "" && call.ID != "" && fragment.ID != call.ID) {
+			call = &types.ToolCall{Index: fragment.Index, Type: "function"}
+			a.byIndex[fragment.Index] = call
+			a.calls = append(a.calls, call)
+		}
+		if fragment.ID != "" {
+			call.ID = fragment.ID
+		}
+		if fragment.Type != "" {
+			call.Type = fragment.Type
+		}
+		if fragment.Function.Name != "" {
+			call.Function.Name = fragment.Function.Name
+		}
+		call.Function.Arguments += fragment.Function.Arguments
+	}
+}
+
+// Len 返回已聚合的调用数量
+func (a *ToolCallAccumulator) Len() int {
+	return len(a.calls)
+}
+
+// Calls 按首次出现的顺序返回聚合后的调用，跳过没有函数名的残缺分片
+func (a *ToolCallAccumulator) Calls() []types.ToolCall {
+	result := make([]types.ToolCall, 0, len(a.calls))
+	for _, call := range a.calls {
+		if call.Function.Name == "" {
+			continue
+		}
+		result = append(result, *call)
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package function
+
+import (
+	"encoding/json"
+	"testing"
+
+	"xiaozhi-server-go/src/core/types"
+)
+
+func fragment(index int, id, name, args string) types.ToolCall {
+	return types.ToolCall{Index: index, ID: id, Function: types.FunctionCall{Name: name, Arguments: args}}
+}
+
+// checkCall 校验调用的 id、函数名，以及拼接后的参数是合法 JSON 且与预期一致
+func checkCall(t *testing.T, call types.ToolCall, id, name string, want map[string]interface{}) {
+	t.Helper()
+	if call.ID != id || call.Function.Name != name {
+		t.Fatalf("调用为 %s/%s，期望 %s/%s", call.ID, call.Function.Name, id, name)
+	}
+	if call.Type != "function" {
+		t.Fatalf("调用 %s 的 type 为 %q，期望 function", id, call.Type)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &got); err != nil {
+		t.Fatalf("调用 %s 的参数不是合法 JSON: %q: %v", id, call.Function.Arguments, err)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Fatalf("调用 %s 的参数 %s 为 %v，期望 %v", id, key, got[key], value)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("调用 %s 的参数为 %v，期望 %v", id, got, want)
+	}
+}
+
+func TestToolCallAccumulatorInterleavedIndices(t *testing.T) {
+	acc := NewToolCallAccumulator()
+	acc.Add([]types.ToolCall{fragment(0, "call_a", "get_weather", "")})
+	acc.Add([]types.ToolCall{fragment(1, "call_b", "play_music", "")})
+	acc.Add([]types.ToolCall{fragment(0, "", "", `{"city":`)})
+	acc.Add([]types.ToolCall{fragment(1, "", "", `{"song":"晴天"`)})
+	acc.Add([]types.ToolCall{fragment(0, "", "", `"北京"}`), fragment(1, "", "", `}`)})
+
+	calls := acc.Calls()
+	if len(calls) != 2 || acc.Len() != 2 {
+		t.Fatalf("聚合出 %d 个调用，期望 2 个", len(calls))
+	}
+	checkCall(t, calls[0], "call_a", "get_weather", map[string]interface{}{"city": "北京"})
+	checkCall(t, calls[1], "call_b", "play_music", map[string]interface{}{"song": "晴天"})
+}
+
+func TestToolCallAccumulatorIndexReusedWithNewID(t *testing.T) {
+	acc := NewToolCallAccumulator()
+	acc.Add([]types.ToolCall{fragment(0, "call_a", "get_weather", `{"city":"上海"}`)})
+	acc.Add([]types.ToolCall{fragment(0, "call_b", "get_time", "")})
+	acc.Add([]types.ToolCall{fragment(0, "", "", `{"zone":"UTC+8"}`)})
+
+	calls := acc.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("聚合出 %d 个调用，期望 2 个", len(calls))
+	}
+	checkCall(t, calls[0], "call_a", "get_weather", map[string]interface{}{"city": "上海"})
+	checkCall(t, calls[1], "call_b", "get_time", map[string]interface{}{"zone": "UTC+8"})
+}
+
+func TestToolCallAccumulatorArgumentsBeforeHeader(t *testing.T) {
+	acc := NewToolCallAccumulator()
+	acc.Add([]types.ToolCall{fragment(0, "", "", `{"volume":`)})
+	if calls := acc.Calls(); len(calls) != 0 {
+		t.Fatalf("没有函数名的分片不应返回，实际返回 %d 个调用", len(calls))
+	}
+	acc.Add([]types.ToolCall{fragment(0, "call_a", "set_volume", "")})
+	acc.Add([]types.ToolCall{fragment(0, "", "", `80}`)})
+
+	calls := acc.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("聚合出 %d 个调用，期望 1 个", len(calls))
+	}
+	checkCall(t, calls[0], "call_a", "set_volume", map[string]interface{}{"volume": float64(80)})
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+// greetingCacheDir 欢迎语音频缓存目录
+const greetingCacheDir = "tmp/greeting"
+
+var weekdayNames = []string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// greetingText 按当前时间选择并渲染欢迎语，没有匹配的时间段时使用 text
+func greetingText(config configs.GreetingConfig, now time.Time) (string, error) {
+	text := config.Text
+	for _, period := range config.Periods {
+		if inClockRange(period.Start, period.End, now) {
+			text = period.Text
+			break
+		}
+	}
+	return renderTimeTemplate(text, now)
+}
+
+// renderTimeTemplate 渲染文本中的 {{.Time}} {{.Weekday}} {{.Date}} 模板变量
+func renderTimeTemplate(text string, now time.Time) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("text").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("解析模板失败: %v", err)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]string{
+		"Time":    now.Format("15:04"),
+		"Weekday": weekdayNames[now.Weekday()],
+		"Date":    fmt.Sprintf("%d月%d日", now.Month(), now.Day()),
+	})
+	if err != nil {
+		return "", fmt.Errorf("渲染模板失败: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// inClockRange 判断 now 是否在 [start, end) 时段内，start 大于 end 时表示跨过零点，格式错误时返回 false
+func inClockRange(start, end string, now time.Time) bool {
+	from, okFrom := parseClock(start)
+	to, okTo := parseClock(end)
+	if !okFrom || !okTo {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if from > to {
+		return minute >= from || minute < to
+	}
+	return minute >= from && minute < to
+}
+
+// inClockRanges 判断 now 是否落在任一 "HH:MM-HH:MM" 时段内
+func inClockRanges(ranges []string, now time.Time) bool {
+	for _, r := range ranges {
+		start, end, ok := strings.Cut(r, "-")
+		if ok && inClockRange(start, end, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock 解析 HH:MM，返回当天的分钟数
+func parseClock(clock string) (int, bool) {
+	t, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// greetingCache 欢迎语音频缓存，所有连接共享，按 TTS、音色与文本缓存合成结果
+type greetingCache struct {
+	logger *utils.Logger
+	mu     sync.Mutex
+	files  map[string]string
+}
+
+func newGreetingCache(logger *utils.Logger) *greetingCache {
+	return &greetingCache{logger: logger, files: make(map[string]string)}
+}
+
+func greetingKey(tts, voice, text string) string {
+	sum := sha1.Sum([]byte(tts + "\x00" + voice + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// has 是否已缓存
+func (c *greetingCache) has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.files[key]
+	return ok
+}
+
+// load 返回可交给发送流程的缓存音频文件，缓存目录中的文件播放后不删除，路径不变以便命中音频帧缓存
+func (c *greetingCache) load(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.files[key]
+	return cached, ok
+}
+
+// isCachedAudio 是否为欢迎语、快速回复缓存目录中的音频文件
+func isCachedAudio(path string) bool {
+	return filepath.Dir(path) == greetingCacheDir
+}
+
+// store 把合成好的音频复制到缓存目录
+func (c *greetingCache) store(key, src string) {
+	dst := filepath.Join(greetingCacheDir, key+filepath.Ext(src))
+	if err := copyFile(src, dst); err != nil {
+		c.logger.Warn(fmt.Sprintf("缓存欢迎语音频失败: %v", err))
+		return
+	}
+	c.mu.Lock()
+	c.files[key] = dst
+	c.mu.Unlock()
+}
+
+// preload 启动时用默认音色预先合成不含模板变量的欢迎语
+func (c *greetingCache) preload(config *configs.Config, tts providers.TTSProvider) {
+	texts := []string{config.Greeting.Text}
+	for _, period := range config.Greeting.Periods {
+		texts = append(texts, period.Text)
+	}
+	for _, text := range texts {
+		if text == "" || strings.Contains(text, "{{") {
+			continue
+		}
+		path, err := tts.ToTTS(text)
+		if err != nil {
+			c.logger.Warn(fmt.Sprintf("预合成欢迎语失败: %s, %v", text, err))
+			continue
+		}
+		c.store(greetingKey(config.SelectedModule["TTS"], "", text), path)
+		if config.DeleteAudio {
+			os.Remove(path)
+		}
+	}
+	c.mu.Lock()
+	count := len(c.files)
+	c.mu.Unlock()
+	c.logger.Info(fmt.Sprintf("欢迎语音频预合成完成，共 %d 条", count))
+}
+
+func copyFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// speakGreeting 设备连接后播报欢迎语，命中缓存时直接下发音频
+func (h *ConnectionHandler) speakGreeting() {
+	text, err := greetingText(h.config.Greeting, h.now())
+	if err != nil {
+		h.logger.Warn(err.Error())
+		return
+	}
+	if text == "" {
+		return
+	}
+
+	key := greetingKey(h.config.SelectedModule["TTS"], h.ttsVoice, text)
+	path, ok := "", false
+	if h.greetings != nil {
+		path, ok = h.greetings.load(key)
+	}
+	if !ok {
+		path, err = h.synthesize(text, 1)
+		if err != nil {
+			h.logger.Error(fmt.Sprintf("合成欢迎语失败: %v", err))
+			return
+		}
+		// 降级到备用TTS时音色不同，不写入缓存
+		if h.greetings != nil && time.Now().After(h.ttsDegradedUntil) {
+			h.greetings.store(key, path)
+		}
+	}
+	h.logger.Info(fmt.Sprintf("播报欢迎语: %s", text))
+	if err := h.proactivePlay(text, path); err != nil {
+		h.logger.Error(fmt.Sprintf("播报欢迎语失败: %v", err))
+	}
+}
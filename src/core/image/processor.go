@@ -41,7 +41,7 @@ func NewImageProcessor(config *configs.VLLMConfig, logger *utils.Logger) (*Image
 
 	// 配置HTTP客户端
 	httpClient := &http.Client{
-		Timeout: 30 * time.Second,
+		Timeout: utils.ParseTimeout(config.Timeout, 30*time.Second),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			// 限制重定向次数为3次
 			if len(via) >= 3 {
@@ -145,8 +145,20 @@ func (p *ImageProcessor) processURLImage(ctx context.Context, url string, format
 		}
 	}()
 
-	// 下载图片
-	if err := p.downloadImage(ctx, url, tempPath); err != nil {
+	// 下载图片，网络错误与 429/5xx 时退避重试
+	policy := utils.DefaultRetryPolicy()
+	policy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		p.logger.Warn("下载图片失败，准备重试", map[string]interface{}{
+			"url":     url,
+			"attempt": attempt,
+			"delay":   delay.String(),
+			"error":   err.Error(),
+		})
+	}
+	err := utils.Retry(ctx, policy, func(int) error {
+		return p.downloadImage(ctx, url, tempPath)
+	})
+	if err != nil {
 		return "", fmt.Errorf("下载图片失败: %v", err)
 	}
 
@@ -189,7 +201,7 @@ func (p *ImageProcessor) downloadImage(ctx context.Context, url string, tempPath
 
 	// 检查响应状态
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP响应错误: %d %s", resp.StatusCode, resp.Status)
+		return &utils.HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
 	// 检查Content-Type
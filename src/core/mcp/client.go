@@ -15,16 +15,28 @@ import (
 
 // Config 定义MCP客户端配置
 type Config struct {
-	Enabled       bool     `yaml:"enabled"`
-	ServerAddress string   `yaml:"server_address"`
-	ServerPort    int      `yaml:"server_port"`
-	Namespace     string   `yaml:"namespace"`
-	NodeID        string   `yaml:"node_id"`
-	ResourceTypes []string `yaml:"resource_types"`
-	Command       string   `yaml:"command,omitempty"` // 命令行连接方式
-	Args          []string `yaml:"args,omitempty"`    // 命令行参数
-	Env           []string `yaml:"env,omitempty"`     // 环境变量
-	URL           string   `yaml:"url,omitempty"`     // SSE连接URL
+	Enabled       bool          `yaml:"enabled"`
+	ServerAddress string        `yaml:"server_address"`
+	ServerPort    int           `yaml:"server_port"`
+	Namespace     string        `yaml:"namespace"`
+	NodeID        string        `yaml:"node_id"`
+	ResourceTypes []string      `yaml:"resource_types"`
+	Command       string        `yaml:"command,omitempty"` // 命令行连接方式
+	Args          []string      `yaml:"args,omitempty"`    // 命令行参数
+	Env           []string      `yaml:"env,omitempty"`     // 环境变量
+	URL           string        `yaml:"url,omitempty"`     // SSE连接URL
+	Timeout       time.Duration `yaml:"timeout,omitempty"` // 初始化与单次工具调用超时
+}
+
+// DefaultTimeout MCP 初始化与工具调用的默认超时
+const DefaultTimeout = 30 * time.Second
+
+// timeout 返回配置的超时，未配置时使用默认值
+func (c *Config) timeout() time.Duration {
+	if c == nil || c.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return c.Timeout
 }
 
 // Client 封装MCP客户端功能
@@ -85,7 +97,7 @@ func (c *Client) Start(ctx context.Context) error {
 		}
 
 		// 设置超时上下文
-		initCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		initCtx, cancel := context.WithTimeout(ctx, c.config.timeout())
 		defer cancel()
 
 		// 初始化客户端
@@ -231,7 +243,9 @@ func (c *Client) CallTool(ctx context.Context, name string, args map[string]any)
 		callRequest.Params.Name = name
 		callRequest.Params.Arguments = args
 
-		result, err := c.stdioClient.CallTool(ctx, callRequest)
+		callCtx, cancel := context.WithTimeout(ctx, c.config.timeout())
+		defer cancel()
+		result, err := c.stdioClient.CallTool(callCtx, callRequest)
 		if err != nil {
 			return nil, fmt.Errorf("failed to call tool %s: %w", name, err)
 		}
@@ -240,6 +254,15 @@ func (c *Client) CallTool(ctx context.Context, name string, args map[string]any)
 		if result == nil || len(result.Content) == 0 {
 			return nil, nil
 		}
+		if result.IsError {
+			// 工具执行报错时错误详情在文本内容中，作为错误返回便于回传 LLM
+			for _, content := range result.Content {
+				if textContent, ok := content.(mcp.TextContent); ok && textContent.Text != "" {
+					return nil, fmt.Errorf("工具 %s 执行出错: %s", name, textContent.Text)
+				}
+			}
+			return nil, fmt.Errorf("工具 %s 执行出错", name)
+		}
 
 		// 返回第一个内容项，或整个内容列表
 		if len(result.Content) == 1 {
@@ -10,6 +10,7 @@ import (
 	"time"
 	"xiaozhi-server-go/src/core/types"
 	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/metrics"
 
 	go_openai "github.com/sashabaranov/go-openai"
 )
@@ -292,6 +293,14 @@ func (m *Manager) InitializeServers(ctx context.Context) error {
 			continue
 		}
 
+		// "xiaozhi" 为设备端MCP保留名称，仅支持配置 timeout
+		if name == "xiaozhi" {
+			if timeout, ok := srvConfigMap["timeout"]; ok && m.XiaoZhiMCPClient != nil {
+				m.XiaoZhiMCPClient.SetTimeout(utils.ParseTimeout(timeout, DefaultTimeout))
+			}
+			continue
+		}
+
 		if _, hasCmd := srvConfigMap["command"]; !hasCmd {
 			if _, hasURL := srvConfigMap["url"]; !hasURL {
 				m.logger.Warn(fmt.Sprintf("Skipping server %s: neither command nor url specified", name))
@@ -406,6 +415,11 @@ func convertConfig(cfg map[string]interface{}) (*Config, error) {
 		config.URL = url
 	}
 
+	// 超时，支持 "45s" 形式或秒数
+	if timeout, ok := cfg["timeout"]; ok {
+		config.Timeout = utils.ParseTimeout(timeout, DefaultTimeout)
+	}
+
 	return config, nil
 }
 
@@ -456,7 +470,10 @@ func (m *Manager) ExecuteTool(ctx context.Context, toolName string, arguments ma
 
 	for _, client := range m.clients {
 		if client.HasTool(toolName) {
-			return client.CallTool(ctx, toolName, arguments)
+			start := time.Now()
+			result, err := client.CallTool(ctx, toolName, arguments)
+			metrics.ObserveTool(toolName, time.Since(start), err)
+			return result, err
 		}
 	}
 
@@ -35,6 +35,8 @@ type XiaoZhiMCPClient struct {
 	callResults     map[int]chan interface{}
 	callResultsLock sync.Mutex
 	nextID          int
+
+	timeout time.Duration // 单次工具调用超时
 }
 
 // NewXiaoZhiMCPClient 创建一个新的MCP客户端
@@ -46,9 +48,20 @@ func NewXiaoZhiMCPClient(logger *utils.Logger, conn Conn) *XiaoZhiMCPClient {
 		ready:       false,
 		callResults: make(map[int]chan interface{}),
 		nextID:      1,
+		timeout:     DefaultTimeout,
 	}
 }
 
+// SetTimeout 设置工具调用超时，非正值时忽略
+func (c *XiaoZhiMCPClient) SetTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timeout = timeout
+}
+
 // SetConnection 设置新的连接
 func (c *XiaoZhiMCPClient) SetConnection(conn Conn) {
 	c.mu.Lock()
@@ -202,6 +215,14 @@ func (c *XiaoZhiMCPClient) CallTool(ctx context.Context, name string, args map[s
 				if errorMsg, ok := resultMap["error"].(string); ok {
 					return nil, fmt.Errorf("工具调用错误: %s", errorMsg)
 				}
+				// 设备端通常把错误详情放在 content 的文本中
+				if content, ok := resultMap["content"].([]interface{}); ok && len(content) > 0 {
+					if textMap, ok := content[0].(map[string]interface{}); ok {
+						if text, ok := textMap["text"].(string); ok && text != "" {
+							return nil, fmt.Errorf("工具调用错误: %s", text)
+						}
+					}
+				}
 				return nil, fmt.Errorf("工具调用返回错误，但未提供具体错误信息")
 			}
 			// 检查content字段是否存在且为非空数组
@@ -220,7 +241,7 @@ func (c *XiaoZhiMCPClient) CallTool(ctx context.Context, name string, args map[s
 		delete(c.callResults, id)
 		c.callResultsLock.Unlock()
 		return nil, ctx.Err()
-	case <-time.After(30 * time.Second):
+	case <-time.After(c.getTimeout()):
 		// 请求超时
 		c.callResultsLock.Lock()
 		delete(c.callResults, id)
@@ -229,6 +250,12 @@ func (c *XiaoZhiMCPClient) CallTool(ctx context.Context, name string, args map[s
 	}
 }
 
+func (c *XiaoZhiMCPClient) getTimeout() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.timeout
+}
+
 // IsReady 检查客户端是否已初始化完成并准备就绪
 func (c *XiaoZhiMCPClient) IsReady() bool {
 	c.mu.RLock()
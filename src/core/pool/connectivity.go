@@ -210,6 +210,7 @@ func (hc *HealthChecker) checkASRProvider(ctx context.Context, asrType string, m
 		return result.Error
 	}
 
+	describeProvider(result, asrFactory)
 	testInstance, err := hc.createWithRetry(ctx, asrFactory)
 	if err != nil {
 		result.Success = false
@@ -308,6 +309,7 @@ func (hc *HealthChecker) checkLLMProvider(ctx context.Context, llmType string, m
 		return result.Error
 	}
 
+	describeProvider(result, llmFactory)
 	testInstance, err := hc.createWithRetry(ctx, llmFactory)
 	if err != nil {
 		result.Success = false
@@ -407,6 +409,7 @@ func (hc *HealthChecker) checkTTSProvider(ctx context.Context, ttsType string, m
 		return result.Error
 	}
 
+	describeProvider(result, ttsFactory)
 	testInstance, err := hc.createWithRetry(ctx, ttsFactory)
 	if err != nil {
 		result.Success = false
@@ -491,6 +494,7 @@ func (hc *HealthChecker) checkVLLLMProvider(ctx context.Context, vlllmType strin
 		return result.Error
 	}
 
+	describeProvider(result, vlllmFactory)
 	testInstance, err := hc.createWithRetry(ctx, vlllmFactory)
 	if err != nil {
 		result.Success = false
@@ -610,38 +614,50 @@ func (hc *HealthChecker) generateTestAudioData() ([]byte, error) {
 	return testAudioData, nil
 }
 
-// createWithRetry 带重试的创建实例
+// createWithRetry 带重试的创建实例，固定间隔、所有错误均重试
 func (hc *HealthChecker) createWithRetry(ctx context.Context, factory ResourceFactory) (interface{}, error) {
-	var lastErr error
-
-	for attempt := 0; attempt < hc.connConfig.RetryAttempts; attempt++ {
-		if attempt > 0 {
+	policy := utils.RetryPolicy{
+		MaxAttempts:  hc.connConfig.RetryAttempts,
+		InitialDelay: hc.connConfig.RetryDelay,
+		Multiplier:   1,
+		Retryable:    func(error) bool { return true },
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			hc.logger.FormatWarn("连接尝试 %d/%d 失败: %v", attempt, hc.connConfig.RetryAttempts, err)
 			hc.logger.FormatInfo("连接重试 %d/%d", attempt+1, hc.connConfig.RetryAttempts)
+		},
+	}
 
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(hc.connConfig.RetryDelay):
-			}
-		}
-
+	instance, err := utils.RetryWithResult(ctx, policy, func(int) (interface{}, error) {
 		// 设置超时上下文
 		_, cancel := context.WithTimeout(ctx, hc.connConfig.Timeout)
+		defer cancel()
 
 		// 创建实例
-		instance, err := factory.Create()
-		cancel()
-
-		if err != nil {
-			lastErr = err
-			hc.logger.FormatWarn("连接尝试 %d/%d 失败: %v", attempt+1, hc.connConfig.RetryAttempts, err)
-			continue
-		}
-
-		return instance, nil
+		return factory.Create()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("重试 %d 次后仍然失败: %v", hc.connConfig.RetryAttempts, err)
 	}
+	return instance, nil
+}
 
-	return nil, fmt.Errorf("重试 %d 次后仍然失败: %v", hc.connConfig.RetryAttempts, lastErr)
+// describeProvider 把注册表中的提供者元数据写入检查结果
+func describeProvider(result *CheckResult, factory ResourceFactory) {
+	pf, ok := factory.(*ProviderFactory)
+	if !ok {
+		return
+	}
+	meta, ok := pf.Meta()
+	if !ok {
+		return
+	}
+	result.Details["provider"] = meta.Name
+	if meta.Description != "" {
+		result.Details["description"] = meta.Description
+	}
+	if len(meta.Capabilities) > 0 {
+		result.Details["capabilities"] = meta.Capabilities
+	}
 }
 
 // GetResults 获取所有检查结果
@@ -60,7 +60,7 @@ func (f *ProviderFactory) createProvider() (interface{}, error) {
 		cfg := f.config.(*tts.Config)
 		params := f.params
 		delete_audio, _ := params["delete_audio"].(bool)
-		return tts.Create(cfg.Type, cfg, delete_audio)
+		return tts.Create(cfg.Type, cfg, delete_audio, f.logger)
 	case "vlllm":
 		cfg := f.config.(*configs.VLLMConfig)
 		return vlllm.Create(cfg.Type, cfg, f.logger)
@@ -73,6 +73,23 @@ func (f *ProviderFactory) createProvider() (interface{}, error) {
 	}
 }
 
+// Meta 从注册表查找本工厂所创建提供者的元数据
+func (f *ProviderFactory) Meta() (providers.ProviderMeta, bool) {
+	switch f.providerType {
+	case "asr":
+		asrType, _ := f.params["type"].(string)
+		return providers.LookupProvider("ASR", asrType)
+	case "llm":
+		return providers.LookupProvider("LLM", f.config.(*llm.Config).Type)
+	case "tts":
+		return providers.LookupProvider("TTS", f.config.(*tts.Config).Type)
+	case "vlllm":
+		return providers.LookupProvider("VLLLM", f.config.(*configs.VLLMConfig).Type)
+	default:
+		return providers.ProviderMeta{}, false
+	}
+}
+
 // 创建各类型工厂的便利函数
 func NewASRFactory(asrType string, config *configs.Config, logger *utils.Logger) ResourceFactory {
 	if asrCfg, ok := config.ASR[asrType]; ok {
@@ -104,6 +121,8 @@ func NewLLMFactory(llmType string, config *configs.Config, logger *utils.Logger)
 				Temperature: llmCfg.Temperature,
 				MaxTokens:   llmCfg.MaxTokens,
 				TopP:        llmCfg.TopP,
+				Timeout:     llmCfg.Timeout,
+				MaxRetries:  llmCfg.MaxRetries,
 				Extra:       llmCfg.Extra,
 			},
 			logger: logger,
@@ -117,13 +136,18 @@ func NewTTSFactory(ttsType string, config *configs.Config, logger *utils.Logger)
 		return &ProviderFactory{
 			providerType: "tts",
 			config: &tts.Config{
-				Type:      ttsCfg.Type,
-				Voice:     ttsCfg.Voice,
-				Format:    ttsCfg.Format,
-				OutputDir: ttsCfg.OutputDir,
-				AppID:     ttsCfg.AppID,
-				Token:     ttsCfg.Token,
-				Cluster:   ttsCfg.Cluster,
+				Type:       ttsCfg.Type,
+				Voice:      ttsCfg.Voice,
+				Format:     ttsCfg.Format,
+				OutputDir:  ttsCfg.OutputDir,
+				AppID:      ttsCfg.AppID,
+				Token:      ttsCfg.Token,
+				Cluster:    ttsCfg.Cluster,
+				Timeout:    ttsCfg.Timeout,
+				MaxRetries: ttsCfg.MaxRetries,
+
+				SurportedVoices: ttsCfg.SurportedVoices,
+				Extra:           ttsCfg.Extra,
 			},
 			logger: logger,
 			params: map[string]interface{}{
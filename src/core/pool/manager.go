@@ -3,12 +3,14 @@ package pool
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 	"xiaozhi-server-go/src/configs"
 	"xiaozhi-server-go/src/core/mcp"
 	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/vlllm"
 	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/metrics"
 )
 
 // PoolManager 资源池管理器
@@ -19,6 +21,39 @@ type PoolManager struct {
 	vlllmPool *ResourcePool
 	mcpPool   *ResourcePool
 	logger    *utils.Logger
+
+	ttsFallbackPools []*ResourcePool // 与 ttsFallbackNames 一一对应
+	ttsFallbackNames []string
+
+	healthChecker *HealthChecker // 启动时的连通性检查，保留结果供健康检查接口查询
+
+	config           *configs.Config
+	overrideMu       sync.Mutex
+	overridePools    map[string]*ResourcePool       // 设备覆盖的模块，按 模块:配置名称 在首次使用时创建
+	overrideFailures map[string]overridePoolFailure // 最近创建失败的设备覆盖资源池，重试间隔内直接返回错误
+}
+
+// overridePoolFailure 设备覆盖资源池的创建失败记录
+type overridePoolFailure struct {
+	err      error
+	failedAt time.Time
+}
+
+// overrideRetryInterval 设备覆盖资源池创建失败后，间隔多久才再次尝试创建
+const overrideRetryInterval = 30 * time.Second
+
+// overridePoolConfig 设备覆盖模块的资源池较小，只有少数设备使用
+var overridePoolConfig = PoolConfig{
+	MinSize:       1,
+	MaxSize:       20,
+	RefillSize:    1,
+	CheckInterval: 30 * time.Second,
+}
+
+// TTSFallback 备用 TTS 提供者
+type TTSFallback struct {
+	Name     string
+	Provider providers.TTSProvider
 }
 
 // ProviderSet 提供者集合
@@ -28,12 +63,19 @@ type ProviderSet struct {
 	TTS   providers.TTSProvider
 	VLLLM *vlllm.Provider
 	MCP   *mcp.Manager
+
+	TTSFallbacks []TTSFallback // 按配置顺序排列的备用 TTS，可为空
+
+	Modules map[string]string // 与 selected_module 不同的模块选择，归还时据此找到对应的资源池
 }
 
 // NewPoolManager 创建资源池管理器
 func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager, error) {
 	pm := &PoolManager{
-		logger: logger,
+		logger:           logger,
+		config:           config,
+		overridePools:    make(map[string]*ResourcePool),
+		overrideFailures: make(map[string]overridePoolFailure),
 	}
 
 	// 执行连通性检查
@@ -96,6 +138,32 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 		logger.FormatInfo("TTS资源池初始化成功，类型: %s, 数量：%d", ttsType, cnt)
 	}
 
+	// 初始化备用TTS池（可选），只在主TTS失败时使用，保持较小的池
+	fallbackPoolConfig := PoolConfig{
+		MinSize:       1,
+		MaxSize:       poolConfig.MaxSize,
+		RefillSize:    1,
+		CheckInterval: poolConfig.CheckInterval,
+	}
+	for _, name := range config.TTSFallback {
+		if name == "" || name == selectedModule["TTS"] {
+			continue
+		}
+		fallbackFactory := NewTTSFactory(name, config, logger)
+		if fallbackFactory == nil {
+			logger.Warn("创建备用TTS工厂失败: 找不到配置 %s", name)
+			continue
+		}
+		fallbackPool, err := NewResourcePool(fallbackFactory, fallbackPoolConfig, logger)
+		if err != nil {
+			logger.Warn("初始化备用TTS资源池失败 %s: %v", name, err)
+			continue
+		}
+		pm.ttsFallbackPools = append(pm.ttsFallbackPools, fallbackPool)
+		pm.ttsFallbackNames = append(pm.ttsFallbackNames, name)
+		logger.FormatInfo("备用TTS资源池初始化成功，类型: %s", name)
+	}
+
 	// 初始化VLLLM池（可选）
 	if vlllmType, ok := selectedModule["VLLLM"]; ok && vlllmType != "" {
 		vlllmFactory := NewVLLLMFactory(vlllmType, config, logger)
@@ -139,37 +207,98 @@ func NewPoolManager(config *configs.Config, logger *utils.Logger) (*PoolManager,
 		logger.Warn("创建MCP工厂失败，MCP功能将不可用")
 	}
 
+	metrics.PoolResources.Set(pm.poolSamples)
 	return pm, nil
 }
 
+// poolSamples 采集各资源池的空闲数与总数，供 Prometheus 导出
+func (pm *PoolManager) poolSamples() []metrics.Sample {
+	stats := pm.GetStats()
+	samples := make([]metrics.Sample, 0, len(stats)*2)
+	for name, s := range stats {
+		samples = append(samples,
+			metrics.Sample{Labels: []string{name, "available"}, Value: float64(s["available"])},
+			metrics.Sample{Labels: []string{name, "total"}, Value: float64(s["total"])},
+		)
+	}
+	return samples
+}
+
 // GetProviderSet 获取一套提供者
 func (pm *PoolManager) GetProviderSet() (*ProviderSet, error) {
+	return pm.GetProviderSetFor(nil)
+}
+
+// GetProviderSetFor 按模块选择获取一套提供者，modules 为 模块 -> 配置名称，未列出的模块使用 selected_module
+func (pm *PoolManager) GetProviderSetFor(modules map[string]string) (*ProviderSet, error) {
 	set := &ProviderSet{}
+	for module, name := range modules {
+		if name != "" && name != pm.config.SelectedModule[module] {
+			if set.Modules == nil {
+				set.Modules = make(map[string]string)
+			}
+			set.Modules[module] = name
+		}
+	}
 
-	if pm.asrPool != nil {
-		asr, err := pm.asrPool.Get()
+	// 先确定各模块的资源池，设备选择的配置不存在时不占用任何提供者
+	asrPool, err := pm.modulePool("ASR", set.Modules["ASR"], true)
+	if err != nil {
+		return nil, err
+	}
+	llmPool, err := pm.modulePool("LLM", set.Modules["LLM"], true)
+	if err != nil {
+		return nil, err
+	}
+	ttsPool, err := pm.modulePool("TTS", set.Modules["TTS"], true)
+	if err != nil {
+		return nil, err
+	}
+
+	// 后面的模块获取失败时归还已取得的提供者，否则每次失败的连接都会占住池中的实例
+	if asrPool != nil {
+		asr, err := asrPool.Get()
 		if err != nil {
 			return nil, fmt.Errorf("获取ASR提供者失败: %v", err)
 		}
 		set.ASR = asr.(providers.ASRProvider)
 	}
 
-	if pm.llmPool != nil {
-		llm, err := pm.llmPool.Get()
+	if llmPool != nil {
+		llm, err := llmPool.Get()
 		if err != nil {
+			pm.ReturnProviderSet(set)
 			return nil, fmt.Errorf("获取LLM提供者失败: %v", err)
 		}
 		set.LLM = llm.(providers.LLMProvider)
 	}
 
-	if pm.ttsPool != nil {
-		tts, err := pm.ttsPool.Get()
+	if ttsPool != nil {
+		tts, err := ttsPool.Get()
 		if err != nil {
+			pm.ReturnProviderSet(set)
 			return nil, fmt.Errorf("获取TTS提供者失败: %v", err)
 		}
 		set.TTS = tts.(providers.TTSProvider)
 	}
 
+	for i, fallbackPool := range pm.ttsFallbackPools {
+		// 设备选择的TTS恰好是备用TTS时不再重复作为备用
+		if pm.ttsFallbackNames[i] == set.Modules["TTS"] {
+			continue
+		}
+		fallback, err := fallbackPool.Get()
+		if err != nil {
+			// 备用TTS不可用时不影响主流程
+			pm.logger.Warn("获取备用TTS提供者 %s 失败: %v", pm.ttsFallbackNames[i], err)
+			continue
+		}
+		set.TTSFallbacks = append(set.TTSFallbacks, TTSFallback{
+			Name:     pm.ttsFallbackNames[i],
+			Provider: fallback.(providers.TTSProvider),
+		})
+	}
+
 	if pm.vlllmPool != nil {
 		vlllmProvider, err := pm.vlllmPool.Get()
 		if err == nil {
@@ -189,6 +318,87 @@ func (pm *PoolManager) GetProviderSet() (*ProviderSet, error) {
 	return set, nil
 }
 
+// modulePool 返回模块对应配置名称的资源池，name 为空或与 selected_module 相同时为全局资源池；
+// 其他配置的资源池在 create 为 true 时首次使用创建，创建过程不持有锁，以免拨号较慢时阻塞归还
+func (pm *PoolManager) modulePool(module, name string, create bool) (*ResourcePool, error) {
+	if name == "" || name == pm.config.SelectedModule[module] {
+		switch module {
+		case "ASR":
+			return pm.asrPool, nil
+		case "LLM":
+			return pm.llmPool, nil
+		case "TTS":
+			return pm.ttsPool, nil
+		}
+		return nil, nil
+	}
+
+	key := module + ":" + name
+	pm.overrideMu.Lock()
+	pool, ok := pm.overridePools[key]
+	failure, failed := pm.overrideFailures[key]
+	pm.overrideMu.Unlock()
+	if ok || !create {
+		return pool, nil
+	}
+	if failed && time.Since(failure.failedAt) < overrideRetryInterval {
+		return nil, failure.err
+	}
+
+	var factory ResourceFactory
+	switch module {
+	case "ASR":
+		factory = NewASRFactory(name, pm.config, pm.logger)
+	case "LLM":
+		factory = NewLLMFactory(name, pm.config, pm.logger)
+	case "TTS":
+		factory = NewTTSFactory(name, pm.config, pm.logger)
+	default:
+		return nil, fmt.Errorf("不支持按设备选择的模块: %s", module)
+	}
+	if factory == nil {
+		return nil, pm.recordOverrideFailure(key, fmt.Errorf("创建%s工厂失败: 找不到配置 %s", module, name))
+	}
+	pool, err := NewResourcePool(factory, overridePoolConfig, pm.logger)
+	if err != nil {
+		return nil, pm.recordOverrideFailure(key, fmt.Errorf("初始化%s资源池 %s 失败: %v", module, name, err))
+	}
+
+	pm.overrideMu.Lock()
+	existing, ok := pm.overridePools[key]
+	if !ok {
+		pm.overridePools[key] = pool
+		delete(pm.overrideFailures, key)
+	}
+	pm.overrideMu.Unlock()
+	if ok {
+		// 其他连接同时创建了同一个资源池，使用先创建的
+		pool.Close()
+		return existing, nil
+	}
+	pm.logger.FormatInfo("%s资源池初始化成功，类型: %s（设备覆盖）", module, name)
+	return pool, nil
+}
+
+// recordOverrideFailure 记录设备覆盖资源池的创建失败，重试间隔内的连接直接返回该错误
+func (pm *PoolManager) recordOverrideFailure(key string, err error) error {
+	pm.overrideMu.Lock()
+	pm.overrideFailures[key] = overridePoolFailure{err: err, failedAt: time.Now()}
+	pm.overrideMu.Unlock()
+	return err
+}
+
+// deviceOverridePools 已创建的设备覆盖资源池的快照
+func (pm *PoolManager) deviceOverridePools() map[string]*ResourcePool {
+	pm.overrideMu.Lock()
+	defer pm.overrideMu.Unlock()
+	pools := make(map[string]*ResourcePool, len(pm.overridePools))
+	for key, pool := range pm.overridePools {
+		pools[key] = pool
+	}
+	return pools
+}
+
 // Close 关闭所有资源池
 func (pm *PoolManager) Close() {
 	if pm.asrPool != nil {
@@ -200,12 +410,18 @@ func (pm *PoolManager) Close() {
 	if pm.ttsPool != nil {
 		pm.ttsPool.Close()
 	}
+	for _, fallbackPool := range pm.ttsFallbackPools {
+		fallbackPool.Close()
+	}
 	if pm.vlllmPool != nil {
 		pm.vlllmPool.Close()
 	}
 	if pm.mcpPool != nil {
 		pm.mcpPool.Close()
 	}
+	for _, pool := range pm.deviceOverridePools() {
+		pool.Close()
+	}
 }
 
 // ReturnProviderSet 归还提供者集合到池中
@@ -216,14 +432,18 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 
 	var errs []error
 
+	asrPool, _ := pm.modulePool("ASR", set.Modules["ASR"], false)
+	llmPool, _ := pm.modulePool("LLM", set.Modules["LLM"], false)
+	ttsPool, _ := pm.modulePool("TTS", set.Modules["TTS"], false)
+
 	// 归还ASR提供者
-	if set.ASR != nil && pm.asrPool != nil {
+	if set.ASR != nil && asrPool != nil {
 		// 重置资源状态
-		if err := pm.asrPool.Reset(set.ASR); err != nil {
+		if err := asrPool.Reset(set.ASR); err != nil {
 			pm.logger.Warn("重置ASR资源状态失败: %v", err)
 		}
 		// 归还到池中
-		if err := pm.asrPool.Put(set.ASR); err != nil {
+		if err := asrPool.Put(set.ASR); err != nil {
 			errs = append(errs, fmt.Errorf("归还ASR提供者失败: %v", err))
 			pm.logger.Error("归还ASR提供者失败: %v", err)
 		} else {
@@ -232,11 +452,11 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	}
 
 	// 归还LLM提供者
-	if set.LLM != nil && pm.llmPool != nil {
-		if err := pm.llmPool.Reset(set.LLM); err != nil {
+	if set.LLM != nil && llmPool != nil {
+		if err := llmPool.Reset(set.LLM); err != nil {
 			pm.logger.Warn("重置LLM资源状态失败: %v", err)
 		}
-		if err := pm.llmPool.Put(set.LLM); err != nil {
+		if err := llmPool.Put(set.LLM); err != nil {
 			errs = append(errs, fmt.Errorf("归还LLM提供者失败: %v", err))
 			pm.logger.Error("归还LLM提供者失败: %v", err)
 		} else {
@@ -245,11 +465,11 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	}
 
 	// 归还TTS提供者
-	if set.TTS != nil && pm.ttsPool != nil {
-		if err := pm.ttsPool.Reset(set.TTS); err != nil {
+	if set.TTS != nil && ttsPool != nil {
+		if err := ttsPool.Reset(set.TTS); err != nil {
 			pm.logger.Warn("重置TTS资源状态失败: %v", err)
 		}
-		if err := pm.ttsPool.Put(set.TTS); err != nil {
+		if err := ttsPool.Put(set.TTS); err != nil {
 			errs = append(errs, fmt.Errorf("归还TTS提供者失败: %v", err))
 			pm.logger.Error("归还TTS提供者失败: %v", err)
 		} else {
@@ -257,6 +477,21 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 		}
 	}
 
+	// 归还备用TTS提供者
+	for _, fallback := range set.TTSFallbacks {
+		fallbackPool := pm.ttsFallbackPool(fallback.Name)
+		if fallbackPool == nil {
+			continue
+		}
+		if err := fallbackPool.Reset(fallback.Provider); err != nil {
+			pm.logger.Warn("重置备用TTS资源状态失败: %v", err)
+		}
+		if err := fallbackPool.Put(fallback.Provider); err != nil {
+			errs = append(errs, fmt.Errorf("归还备用TTS提供者 %s 失败: %v", fallback.Name, err))
+			pm.logger.Error("归还备用TTS提供者失败: %v", err)
+		}
+	}
+
 	// 归还VLLLM提供者
 	if set.VLLLM != nil && pm.vlllmPool != nil {
 		if err := pm.vlllmPool.Reset(set.VLLLM); err != nil {
@@ -291,6 +526,16 @@ func (pm *PoolManager) ReturnProviderSet(set *ProviderSet) error {
 	return nil
 }
 
+// ttsFallbackPool 按名称查找备用TTS池
+func (pm *PoolManager) ttsFallbackPool(name string) *ResourcePool {
+	for i, fallbackName := range pm.ttsFallbackNames {
+		if fallbackName == name {
+			return pm.ttsFallbackPools[i]
+		}
+	}
+	return nil
+}
+
 // GetStats 获取所有池的统计信息
 func (pm *PoolManager) GetStats() map[string]map[string]int {
 	stats := make(map[string]map[string]int)
@@ -310,6 +555,11 @@ func (pm *PoolManager) GetStats() map[string]map[string]int {
 		stats["tts"] = map[string]int{"available": available, "total": total}
 	}
 
+	for i, fallbackPool := range pm.ttsFallbackPools {
+		available, total := fallbackPool.GetStats()
+		stats["tts_fallback:"+pm.ttsFallbackNames[i]] = map[string]int{"available": available, "total": total}
+	}
+
 	if pm.vlllmPool != nil {
 		available, total := pm.vlllmPool.GetStats()
 		stats["vlllm"] = map[string]int{"available": available, "total": total}
@@ -320,6 +570,11 @@ func (pm *PoolManager) GetStats() map[string]map[string]int {
 		stats["mcp"] = map[string]int{"available": available, "total": total}
 	}
 
+	for key, pool := range pm.deviceOverridePools() {
+		available, total := pool.GetStats()
+		stats["device:"+key] = map[string]int{"available": available, "total": total}
+	}
+
 	return stats
 }
 
@@ -334,6 +589,7 @@ func (pm *PoolManager) performConnectivityCheck(config *configs.Config, logger *
 
 	// 创建健康检查器
 	healthChecker := NewHealthChecker(config, connConfig, logger)
+	pm.healthChecker = healthChecker
 
 	// 执行功能性连通性检查
 	ctx, cancel := context.WithTimeout(context.Background(), connConfig.Timeout*3) // 给功能性检查更多时间
@@ -347,6 +603,14 @@ func (pm *PoolManager) performConnectivityCheck(config *configs.Config, logger *
 	return err
 }
 
+// GetHealthResults 返回启动时连通性检查的结果，按模块名索引
+func (pm *PoolManager) GetHealthResults() map[string]*CheckResult {
+	if pm.healthChecker == nil {
+		return nil
+	}
+	return pm.healthChecker.GetResults()
+}
+
 // GetDetailedStats 获取所有池的详细统计信息
 func (pm *PoolManager) GetDetailedStats() map[string]map[string]int {
 	stats := make(map[string]map[string]int)
@@ -363,6 +627,10 @@ func (pm *PoolManager) GetDetailedStats() map[string]map[string]int {
 		stats["tts"] = pm.ttsPool.GetDetailedStats()
 	}
 
+	for i, fallbackPool := range pm.ttsFallbackPools {
+		stats["tts_fallback:"+pm.ttsFallbackNames[i]] = fallbackPool.GetDetailedStats()
+	}
+
 	if pm.vlllmPool != nil {
 		stats["vlllm"] = pm.vlllmPool.GetDetailedStats()
 	}
@@ -371,5 +639,9 @@ func (pm *PoolManager) GetDetailedStats() map[string]map[string]int {
 		stats["mcp"] = pm.mcpPool.GetDetailedStats()
 	}
 
+	for key, pool := range pm.deviceOverridePools() {
+		stats["device:"+key] = pool.GetDetailedStats()
+	}
+
 	return stats
 }
@@ -23,6 +23,19 @@ type ResourceFactory interface {
 	Destroy(resource interface{}) error
 }
 
+// healthChecker 可选接口，资源实现后资源池会在借出、归还与定期维护时剔除不健康的资源
+type healthChecker interface {
+	IsHealthy() bool
+}
+
+// isHealthy 未实现 healthChecker 的资源视为健康
+func isHealthy(resource interface{}) bool {
+	if checker, ok := resource.(healthChecker); ok {
+		return checker.IsHealthy()
+	}
+	return true
+}
+
 // ResourcePool 通用资源池
 type ResourcePool struct {
 	factory     ResourceFactory
@@ -72,23 +85,34 @@ func NewResourcePool(factory ResourceFactory, config PoolConfig, logger *utils.L
 
 // Get 获取资源
 func (p *ResourcePool) Get() (interface{}, error) {
-	select {
-	case resource := <-p.pool:
-		p.mutex.Lock()
-		p.currentSize--
-		p.mutex.Unlock()
-		return resource, nil
-	default:
-		// 池中没有资源时，检查是否可以创建新资源
-		p.mutex.Lock()
-		if p.currentSize >= p.maxSize {
+	for {
+		select {
+		case resource := <-p.pool:
+			p.mutex.Lock()
+			p.currentSize--
 			p.mutex.Unlock()
-			return nil, fmt.Errorf("资源池已达到最大容量 %d，无法创建新资源", p.maxSize)
+			if !isHealthy(resource) {
+				p.logger.Warn("剔除不健康的资源")
+				p.factory.Destroy(resource)
+				continue
+			}
+			return resource, nil
+		default:
+			return p.create()
 		}
-		p.currentSize++
+	}
+}
+
+// create 池中无可用资源时按容量上限新建
+func (p *ResourcePool) create() (interface{}, error) {
+	p.mutex.Lock()
+	if p.currentSize >= p.maxSize {
 		p.mutex.Unlock()
-		return p.factory.Create()
+		return nil, fmt.Errorf("资源池已达到最大容量 %d，无法创建新资源", p.maxSize)
 	}
+	p.currentSize++
+	p.mutex.Unlock()
+	return p.factory.Create()
 }
 
 // initializePool 初始化资源池
@@ -114,11 +138,37 @@ func (p *ResourcePool) maintain(refillSize int, checkInterval time.Duration) {
 		case <-p.ctx.Done():
 			return
 		case <-ticker.C:
+			p.evictUnhealthy()
 			p.refillPool(refillSize)
 		}
 	}
 }
 
+// evictUnhealthy 检查池中空闲资源，销毁不健康的资源，由 refillPool 补充
+func (p *ResourcePool) evictUnhealthy() {
+	for i := len(p.pool); i > 0; i-- {
+		var resource interface{}
+		select {
+		case resource = <-p.pool:
+		default:
+			return
+		}
+		if isHealthy(resource) {
+			select {
+			case p.pool <- resource:
+				continue
+			default:
+			}
+		} else {
+			p.logger.Warn("资源健康检查失败，已从池中剔除")
+		}
+		p.mutex.Lock()
+		p.currentSize--
+		p.mutex.Unlock()
+		p.factory.Destroy(resource)
+	}
+}
+
 // refillPool 补充资源池
 func (p *ResourcePool) refillPool(refillSize int) {
 	p.mutex.RLock()
@@ -171,6 +221,12 @@ func (p *ResourcePool) Put(resource interface{}) error {
 	default:
 	}
 
+	// 不健康的资源不再归还，由维护协程补充新资源
+	if !isHealthy(resource) {
+		p.logger.Warn("归还的资源不健康，直接销毁")
+		return p.factory.Destroy(resource)
+	}
+
 	// 设置归还超时
 	timeout := time.NewTimer(5 * time.Second)
 	defer timeout.Stop()
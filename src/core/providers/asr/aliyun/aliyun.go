@@ -0,0 +1,445 @@
+package aliyun
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultWsURL       = "wss://dashscope.aliyuncs.com/api-ws/v1/inference"
+	defaultModel       = "paraformer-realtime-v2"
+	defaultTimeout     = 10 * time.Second
+	transcribeChunk    = 3200                  // Transcribe 每帧发送100ms音频
+	transcribeInterval = 20 * time.Millisecond // 帧间隔，避免瞬时发送过多数据
+	transcribeWindow   = 30 * time.Second      // Transcribe 等待任务结束的上限
+)
+
+// Ensure Provider implements asr.Provider interface
+var _ asr.Provider = (*Provider)(nil)
+
+// Provider 阿里云百炼 DashScope Paraformer 实时语音识别
+// 协议：发送 run-task 指令，收到 task-started 后发送 PCM 二进制帧，发送 finish-task 结束任务；
+// 服务端通过 result-generated 事件返回结果，sentence_end 为 true 表示一句话结束
+type Provider struct {
+	*asr.BaseProvider
+	apiKey      string
+	wsURL       string
+	model       string
+	parameters  map[string]interface{}
+	timeout     time.Duration
+	retryPolicy utils.RetryPolicy
+	logger      *utils.Logger
+
+	mu          sync.Mutex
+	writeMu     sync.Mutex // gorilla websocket 不支持并发写
+	conn        *websocket.Conn
+	taskID      string
+	isStreaming bool
+	lastResult  *providers.AsrResult
+}
+
+// dashscopeHeader 指令与事件的公共头
+type dashscopeHeader struct {
+	Action       string `json:"action,omitempty"`
+	Event        string `json:"event,omitempty"`
+	TaskID       string `json:"task_id"`
+	Streaming    string `json:"streaming,omitempty"`
+	ErrorCode    string `json:"error_code,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// dashscopeEvent 服务端事件
+type dashscopeEvent struct {
+	Header  dashscopeHeader `json:"header"`
+	Payload struct {
+		Output struct {
+			Sentence sentence `json:"sentence"`
+		} `json:"output"`
+	} `json:"payload"`
+}
+
+// sentence 识别结果，时间单位毫秒
+type sentence struct {
+	BeginTime   int    `json:"begin_time"`
+	EndTime     *int   `json:"end_time"` // 句子未结束时为 null
+	Text        string `json:"text"`
+	Heartbeat   bool   `json:"heartbeat"`
+	SentenceEnd bool   `json:"sentence_end"`
+	Words       []word `json:"words"`
+}
+
+type word struct {
+	BeginTime   int    `json:"begin_time"`
+	EndTime     int    `json:"end_time"`
+	Text        string `json:"text"`
+	Punctuation string `json:"punctuation"`
+}
+
+// NewProvider 创建阿里云 Paraformer ASR 提供者
+func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Provider, error) {
+	apiKey, _ := config.Data["api_key"].(string)
+	if apiKey == "" {
+		return nil, fmt.Errorf("阿里云 ASR 缺少 api_key 配置")
+	}
+	wsURL, _ := config.Data["ws_url"].(string)
+	if wsURL == "" {
+		wsURL = defaultWsURL
+	}
+	model, _ := config.Data["model"].(string)
+	if model == "" {
+		model = defaultModel
+	}
+	vocabularyID, _ := config.Data["vocabulary_id"].(string)
+
+	parameters := map[string]interface{}{
+		"format":                             "pcm",
+		"sample_rate":                        16000,
+		"punctuation_prediction_enabled":     true,
+		"inverse_text_normalization_enabled": true,
+	}
+	if v, ok := config.Data["punctuation"].(bool); ok {
+		parameters["punctuation_prediction_enabled"] = v
+	}
+	if v, ok := config.Data["itn"].(bool); ok {
+		parameters["inverse_text_normalization_enabled"] = v
+	}
+	if v, ok := config.Data["disfluency_removal"].(bool); ok {
+		parameters["disfluency_removal_enabled"] = v
+	}
+	if v, ok := config.Data["max_sentence_silence"].(int); ok {
+		parameters["max_sentence_silence"] = v
+	}
+	if hints, ok := config.Data["language_hints"].([]interface{}); ok && len(hints) > 0 {
+		parameters["language_hints"] = hints
+	}
+	if vocabularyID != "" {
+		parameters["vocabulary_id"] = vocabularyID
+	}
+
+	p := &Provider{
+		BaseProvider: asr.NewBaseProvider(config, deleteFile),
+		apiKey:       apiKey,
+		wsURL:        wsURL,
+		model:        model,
+		parameters:   parameters,
+		timeout:      utils.ParseTimeout(config.Data["timeout"], defaultTimeout),
+		retryPolicy:  utils.DefaultRetryPolicy(),
+		logger:       logger,
+	}
+	if retries, ok := config.Data["max_retries"].(int); ok {
+		p.retryPolicy = p.retryPolicy.WithMaxRetries(retries)
+	}
+	p.retryPolicy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		p.logger.Warn(fmt.Sprintf("阿里云 ASR 连接失败(尝试%d/%d): %v, 将在%v后重试",
+			attempt, p.retryPolicy.MaxAttempts, err, delay))
+	}
+
+	p.InitAudioProcessing()
+	return p, nil
+}
+
+// runTask 构造 run-task 指令
+func (p *Provider) runTask(taskID string) map[string]interface{} {
+	return map[string]interface{}{
+		"header": dashscopeHeader{Action: "run-task", TaskID: taskID, Streaming: "duplex"},
+		"payload": map[string]interface{}{
+			"task_group": "audio",
+			"task":       "asr",
+			"function":   "recognition",
+			"model":      p.model,
+			"parameters": p.parameters,
+			"input":      map[string]interface{}{},
+		},
+	}
+}
+
+// finishTask 构造 finish-task 指令
+func finishTask(taskID string) map[string]interface{} {
+	return map[string]interface{}{
+		"header":  dashscopeHeader{Action: "finish-task", TaskID: taskID, Streaming: "duplex"},
+		"payload": map[string]interface{}{"input": map[string]interface{}{}},
+	}
+}
+
+// dial 建立连接、下发 run-task 并等待 task-started
+func (p *Provider) dial(ctx context.Context) (*websocket.Conn, string, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: p.timeout}
+	headers := http.Header{
+		"Authorization": {"bearer " + p.apiKey},
+	}
+	conn, err := utils.RetryWithResult(ctx, p.retryPolicy, func(int) (*websocket.Conn, error) {
+		conn, resp, err := dialer.DialContext(ctx, p.wsURL, headers)
+		if err != nil && resp != nil {
+			return nil, fmt.Errorf("%v: %w", err, &utils.HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+		}
+		return conn, err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("连接阿里云 ASR 失败: %v", err)
+	}
+
+	taskID := uuid.New().String()
+	conn.SetWriteDeadline(time.Now().Add(p.timeout))
+	if err := conn.WriteJSON(p.runTask(taskID)); err != nil {
+		conn.Close()
+		return nil, "", fmt.Errorf("发送 run-task 失败: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(p.timeout))
+	var event dashscopeEvent
+	if err := conn.ReadJSON(&event); err != nil {
+		conn.Close()
+		return nil, "", fmt.Errorf("等待 task-started 失败: %v", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+	if event.Header.Event != "task-started" {
+		conn.Close()
+		return nil, "", fmt.Errorf("阿里云 ASR 任务启动失败(%s): %s", event.Header.ErrorCode, event.Header.ErrorMessage)
+	}
+	return conn, taskID, nil
+}
+
+// AddAudio 发送16k单声道 int16 PCM，首帧时启动识别任务
+func (p *Provider) AddAudio(data []byte) error {
+	p.mu.Lock()
+	if !p.isStreaming {
+		p.logger.Info("----开始阿里云流式识别----")
+		conn, taskID, err := p.dial(context.Background())
+		if err != nil {
+			p.mu.Unlock()
+			return err
+		}
+		p.conn = conn
+		p.taskID = taskID
+		p.isStreaming = true
+		p.lastResult = nil
+		go p.readLoop(conn)
+	}
+	conn := p.conn
+	p.mu.Unlock()
+
+	p.SetLastChunkTime(time.Now())
+	if len(data) == 0 {
+		return nil
+	}
+
+	p.writeMu.Lock()
+	conn.SetWriteDeadline(time.Now().Add(p.timeout))
+	err := conn.WriteMessage(websocket.BinaryMessage, data)
+	p.writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("发送音频数据失败: %v", err)
+	}
+	return nil
+}
+
+// readLoop 读取识别事件，监听器表示本轮结束、任务结束或失败时退出
+func (p *Provider) readLoop(conn *websocket.Conn) {
+	defer func() {
+		p.mu.Lock()
+		if p.conn == conn {
+			// 任务已结束，下一帧音频重新启动任务
+			p.isStreaming = false
+			p.conn = nil
+			conn.Close()
+		}
+		p.mu.Unlock()
+	}()
+
+	for {
+		var event dashscopeEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			return
+		}
+		switch event.Header.Event {
+		case "result-generated":
+			if finished := p.handleSentence(event.Payload.Output.Sentence); finished {
+				return
+			}
+		case "task-finished":
+			return
+		case "task-failed":
+			p.logger.Error(fmt.Sprintf("阿里云 ASR 任务失败(%s): %s", event.Header.ErrorCode, event.Header.ErrorMessage))
+			return
+		}
+	}
+}
+
+// handleSentence 分发中间结果与句尾结果，返回监听器是否结束本轮识别
+func (p *Provider) handleSentence(s sentence) bool {
+	listener := p.GetListener()
+	text := strings.TrimSpace(s.Text)
+	if s.Heartbeat || text == "" {
+		return false
+	}
+
+	if !s.SentenceEnd {
+		if interimListener, ok := listener.(providers.AsrInterimListener); ok {
+			interimListener.OnAsrInterim(text)
+		}
+		return false
+	}
+
+	detail := toAsrResult(s)
+	p.mu.Lock()
+	p.lastResult = detail
+	p.mu.Unlock()
+
+	if listener == nil {
+		return false
+	}
+	if detailListener, ok := listener.(providers.AsrDetailListener); ok {
+		detailListener.OnAsrDetail(detail)
+	}
+	return listener.OnAsrResult(text)
+}
+
+// toAsrResult 将句尾结果转换为结构化结果
+func toAsrResult(s sentence) *providers.AsrResult {
+	utterance := providers.AsrUtterance{
+		Text:      s.Text,
+		StartTime: s.BeginTime,
+		Definite:  true,
+	}
+	if s.EndTime != nil {
+		utterance.EndTime = *s.EndTime
+	}
+	for _, w := range s.Words {
+		utterance.Words = append(utterance.Words, providers.AsrWord{
+			Text:      w.Text + w.Punctuation,
+			StartTime: w.BeginTime,
+			EndTime:   w.EndTime,
+		})
+	}
+	return &providers.AsrResult{
+		Text:       s.Text,
+		IsFinal:    true,
+		Duration:   utterance.EndTime,
+		Utterances: []providers.AsrUtterance{utterance},
+	}
+}
+
+// LastResult 最近一次句尾识别的结构化结果
+func (p *Provider) LastResult() *providers.AsrResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastResult
+}
+
+// Transcribe 使用独立任务识别一段完整音频，拼接所有句尾结果
+func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	conn, taskID, err := p.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	sendErr := make(chan error, 1)
+	go func() {
+		for start := 0; start < len(audioData); start += transcribeChunk {
+			end := start + transcribeChunk
+			if end > len(audioData) {
+				end = len(audioData)
+			}
+			if err := conn.WriteMessage(websocket.BinaryMessage, audioData[start:end]); err != nil {
+				sendErr <- fmt.Errorf("发送音频数据失败: %v", err)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				sendErr <- ctx.Err()
+				return
+			case <-time.After(transcribeInterval):
+			}
+		}
+		sendErr <- conn.WriteJSON(finishTask(taskID))
+	}()
+
+	deadline := time.Now().Add(transcribeWindow)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	var text strings.Builder
+	for {
+		var event dashscopeEvent
+		if err := conn.ReadJSON(&event); err != nil {
+			select {
+			case e := <-sendErr:
+				if e != nil {
+					return "", e
+				}
+			default:
+			}
+			return "", fmt.Errorf("读取阿里云 ASR 结果失败: %v", err)
+		}
+		switch event.Header.Event {
+		case "result-generated":
+			if s := event.Payload.Output.Sentence; s.SentenceEnd {
+				text.WriteString(s.Text)
+			}
+		case "task-finished":
+			return text.String(), nil
+		case "task-failed":
+			return "", fmt.Errorf("阿里云 ASR 任务失败(%s): %s", event.Header.ErrorCode, event.Header.ErrorMessage)
+		}
+	}
+}
+
+// Reset 结束当前任务并关闭连接，下一帧音频重新启动任务
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	conn := p.conn
+	taskID := p.taskID
+	p.conn = nil
+	p.taskID = ""
+	p.isStreaming = false
+	p.lastResult = nil
+	p.mu.Unlock()
+
+	if conn != nil {
+		p.writeMu.Lock()
+		conn.SetWriteDeadline(time.Now().Add(p.timeout))
+		_ = conn.WriteJSON(finishTask(taskID))
+		p.writeMu.Unlock()
+		conn.Close()
+	}
+	p.InitAudioProcessing()
+	return nil
+}
+
+// Cleanup 关闭连接
+func (p *Provider) Cleanup() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	p.isStreaming = false
+	return nil
+}
+
+func init() {
+	// 注册阿里云 Paraformer ASR 提供者
+	asr.RegisterProvider(providers.ProviderMeta{
+		Name:         "aliyun",
+		Description:  "阿里云 Paraformer 实时识别",
+		Capabilities: []string{providers.CapabilityStreaming},
+		Required:     []string{"api_key"},
+	}, func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
+		return NewProvider(config, deleteFile, logger)
+	})
+}
@@ -93,20 +93,26 @@ func (p *BaseProvider) Cleanup() error {
 // Factory ASR工厂函数类型
 type Factory func(config *Config, deleteFile bool, logger *utils.Logger) (Provider, error)
 
-var (
-	factories = make(map[string]Factory)
-)
+var factories = providers.NewRegistry[Factory]("ASR")
 
-// Register 注册ASR提供者工厂
+// Register 注册ASR提供者工厂，不声明元数据
 func Register(name string, factory Factory) {
-	factories[name] = factory
+	RegisterProvider(providers.ProviderMeta{Name: name}, factory)
+}
+
+// RegisterProvider 注册ASR提供者工厂及其能力与必填配置
+func RegisterProvider(meta providers.ProviderMeta, factory Factory) {
+	factories.Register(meta, factory)
 }
 
-// Create 创建ASR提供者实例
+// Create 创建ASR提供者实例，先按元数据校验必填配置
 func Create(name string, config *Config, deleteFile bool, logger *utils.Logger) (Provider, error) {
-	factory, ok := factories[name]
+	factory, meta, ok := factories.Lookup(name)
 	if !ok {
-		return nil, fmt.Errorf("未知的ASR提供者: %s", name)
+		return nil, factories.UnknownError(name)
+	}
+	if err := providers.CheckRequired(meta, config.Data); err != nil {
+		return nil, err
 	}
 
 	provider, err := factory(config, deleteFile, logger)
@@ -117,11 +123,30 @@ func Create(name string, config *Config, deleteFile bool, logger *utils.Logger)
 	return provider, nil
 }
 
-// 初始化音频处理
+// 初始化音频处理，已通过 SetSilenceDetection 配置的参数保持不变
 func (p *BaseProvider) InitAudioProcessing() {
 	p.audioBuffer = new(bytes.Buffer)
-	p.silenceThreshold = 0.01 // 默认能量阈值
-	p.silenceDuration = 800   // 默认静音判断时长(ms)
+	if p.silenceThreshold <= 0 {
+		p.silenceThreshold = 0.01 // 默认能量阈值
+	}
+	if p.silenceDuration <= 0 {
+		p.silenceDuration = 800 // 默认静音判断时长(ms)
+	}
+}
+
+// SetSilenceDetection 设置静音检测的能量阈值与判停时长(ms)，非正数表示沿用默认值
+func (p *BaseProvider) SetSilenceDetection(threshold float64, durationMs int) {
+	if threshold > 0 {
+		p.silenceThreshold = threshold
+	}
+	if durationMs > 0 {
+		p.silenceDuration = durationMs
+	}
+}
+
+// SilenceDuration 静音判停时长
+func (p *BaseProvider) SilenceDuration() time.Duration {
+	return time.Duration(p.silenceDuration) * time.Millisecond
 }
 
 // 计算音频能量
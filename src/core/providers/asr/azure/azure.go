@@ -0,0 +1,550 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultLanguage    = "zh-CN"
+	defaultTimeout     = 10 * time.Second
+	sampleRate         = 16000
+	ticksPerMillis     = 10000 // Offset/Duration 以100纳秒为单位
+	transcribeChunk    = 3200
+	transcribeInterval = 10 * time.Millisecond
+	transcribeWindow   = 30 * time.Second
+)
+
+// Ensure Provider implements asr.Provider interface
+var _ asr.Provider = (*Provider)(nil)
+
+// Provider Azure 语音服务实时识别，使用 Speech SDK 的 websocket 协议
+// 文本消息为 HTTP 风格的头部加 JSON 正文；音频消息为二进制帧，前2字节大端表示头部长度，
+// 首帧音频需带 WAV 头，正文为空的音频帧表示音频结束。conversation 模式下一轮中可连续返回多句 speech.phrase
+type Provider struct {
+	*asr.BaseProvider
+	key         string
+	region      string
+	endpoint    string
+	language    string
+	languages   []string // 配置多个语言时开启语种自动检测
+	profanity   string
+	timeout     time.Duration
+	retryPolicy utils.RetryPolicy
+	logger      *utils.Logger
+
+	mu          sync.Mutex
+	writeMu     sync.Mutex // gorilla websocket 不支持并发写
+	conn        *websocket.Conn
+	requestID   string
+	isStreaming bool
+	lastResult  *providers.AsrResult
+}
+
+// hypothesis speech.hypothesis 中间结果
+type hypothesis struct {
+	Text     string `json:"Text"`
+	Offset   int64  `json:"Offset"`
+	Duration int64  `json:"Duration"`
+}
+
+// phrase speech.phrase 一句话的最终结果
+type phrase struct {
+	RecognitionStatus string `json:"RecognitionStatus"`
+	DisplayText       string `json:"DisplayText"`
+	Offset            int64  `json:"Offset"`
+	Duration          int64  `json:"Duration"`
+	PrimaryLanguage   *struct {
+		Language   string `json:"Language"`
+		Confidence string `json:"Confidence"`
+	} `json:"PrimaryLanguage"`
+	NBest []struct {
+		Confidence float64 `json:"Confidence"`
+		Display    string  `json:"Display"`
+		Words      []struct {
+			Word     string `json:"Word"`
+			Offset   int64  `json:"Offset"`
+			Duration int64  `json:"Duration"`
+		} `json:"Words"`
+	} `json:"NBest"`
+}
+
+// NewProvider 创建 Azure ASR 提供者
+func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Provider, error) {
+	key, _ := config.Data["key"].(string)
+	region, _ := config.Data["region"].(string)
+	endpoint, _ := config.Data["endpoint"].(string)
+	if key == "" || (region == "" && endpoint == "") {
+		return nil, fmt.Errorf("Azure ASR 缺少 key/region 配置")
+	}
+
+	language, _ := config.Data["language"].(string)
+	if language == "" {
+		language = defaultLanguage
+	}
+	var languages []string
+	if items, ok := config.Data["languages"].([]interface{}); ok {
+		for _, item := range items {
+			if code, ok := item.(string); ok && code != "" {
+				languages = append(languages, code)
+			}
+		}
+	}
+	profanity, _ := config.Data["profanity"].(string)
+	if profanity == "" {
+		profanity = "masked"
+	}
+
+	p := &Provider{
+		BaseProvider: asr.NewBaseProvider(config, deleteFile),
+		key:          key,
+		region:       region,
+		endpoint:     endpoint,
+		language:     language,
+		languages:    languages,
+		profanity:    profanity,
+		timeout:      utils.ParseTimeout(config.Data["timeout"], defaultTimeout),
+		retryPolicy:  utils.DefaultRetryPolicy(),
+		logger:       logger,
+	}
+	if retries, ok := config.Data["max_retries"].(int); ok {
+		p.retryPolicy = p.retryPolicy.WithMaxRetries(retries)
+	}
+	p.retryPolicy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		p.logger.Warn(fmt.Sprintf("Azure ASR 连接失败(尝试%d/%d): %v, 将在%v后重试",
+			attempt, p.retryPolicy.MaxAttempts, err, delay))
+	}
+
+	p.InitAudioProcessing()
+	return p, nil
+}
+
+// buildURL 构造识别地址，开启语种检测时由 speech.context 指定候选语言
+func (p *Provider) buildURL() string {
+	base := p.endpoint
+	if base == "" {
+		base = fmt.Sprintf("wss://%s.stt.speech.microsoft.com/speech/recognition/conversation/cognitiveservices/v1", p.region)
+	}
+	query := url.Values{}
+	query.Set("format", "detailed")
+	query.Set("profanity", p.profanity)
+	if len(p.languages) > 1 {
+		query.Set("lidEnabled", "true")
+	} else {
+		query.Set("language", p.language)
+	}
+	return base + "?" + query.Encode()
+}
+
+// newID 生成协议要求的无连字符 uuid
+func newID() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// textMessage 构造文本消息
+func textMessage(path, requestID string, body interface{}) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("Path: %s\r\nX-RequestId: %s\r\nX-Timestamp: %s\r\nContent-Type: application/json\r\n\r\n",
+		path, requestID, time.Now().UTC().Format("2006-01-02T15:04:05.000Z"))
+	return append([]byte(header), data...), nil
+}
+
+// audioMessage 构造音频消息，data 为空表示音频结束
+func audioMessage(requestID string, data []byte) []byte {
+	header := fmt.Sprintf("Path: audio\r\nX-RequestId: %s\r\nX-Timestamp: %s\r\nContent-Type: audio/x-wav\r\n",
+		requestID, time.Now().UTC().Format("2006-01-02T15:04:05.000Z"))
+	msg := make([]byte, 2, 2+len(header)+len(data))
+	binary.BigEndian.PutUint16(msg, uint16(len(header)))
+	msg = append(msg, header...)
+	return append(msg, data...)
+}
+
+// wavHeader 流式音频长度未知，数据长度字段填0
+func wavHeader() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1)) // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(1)) // 单声道
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*2))
+	binary.Write(&buf, binary.LittleEndian, uint16(2))
+	binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	return buf.Bytes()
+}
+
+// speechConfig speech.config 消息正文
+func speechConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"context": map[string]interface{}{
+			"system": map[string]interface{}{"name": "SpeechSDK", "version": "1.40.0", "build": "Go", "lang": "Go"},
+			"os":     map[string]interface{}{"platform": "Linux", "name": "xiaozhi-server-go", "version": "1.0"},
+			"audio": map[string]interface{}{
+				"source": map[string]interface{}{"bitspersample": 16, "channelcount": 1, "connectivity": "Unknown",
+					"manufacturer": "Speech SDK", "model": "xiaozhi", "samplerate": sampleRate, "type": "Stream"},
+			},
+		},
+	}
+}
+
+// speechContext 语种检测配置
+func (p *Provider) speechContext() map[string]interface{} {
+	return map[string]interface{}{
+		"languageId": map[string]interface{}{
+			"mode":      "DetectAtAudioStart",
+			"priority":  "PrioritizeLatency",
+			"languages": p.languages,
+			"onSuccess": map[string]interface{}{"action": "Recognize"},
+			"onUnknown": map[string]interface{}{"action": "None"},
+		},
+		"phraseOutput": map[string]interface{}{
+			"interimResults": map[string]interface{}{"resultType": "Auto"},
+			"phraseResults":  map[string]interface{}{"resultType": "Always"},
+		},
+	}
+}
+
+// dial 建立连接并发送 speech.config、speech.context 与 WAV 头
+func (p *Provider) dial(ctx context.Context) (*websocket.Conn, string, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: p.timeout}
+	conn, err := utils.RetryWithResult(ctx, p.retryPolicy, func(int) (*websocket.Conn, error) {
+		headers := http.Header{
+			"Ocp-Apim-Subscription-Key": {p.key},
+			"X-ConnectionId":            {newID()},
+		}
+		conn, resp, err := dialer.DialContext(ctx, p.buildURL(), headers)
+		if err != nil && resp != nil {
+			return nil, fmt.Errorf("%v: %w", err, &utils.HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+		}
+		return conn, err
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("连接 Azure ASR 失败: %v", err)
+	}
+
+	requestID := newID()
+	messages := make([][]byte, 0, 2)
+	msg, err := textMessage("speech.config", requestID, speechConfig())
+	if err != nil {
+		conn.Close()
+		return nil, "", fmt.Errorf("构造 speech.config 失败: %v", err)
+	}
+	messages = append(messages, msg)
+	if len(p.languages) > 1 {
+		msg, err := textMessage("speech.context", requestID, p.speechContext())
+		if err != nil {
+			conn.Close()
+			return nil, "", fmt.Errorf("构造 speech.context 失败: %v", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(p.timeout))
+	for _, msg := range messages {
+		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			conn.Close()
+			return nil, "", fmt.Errorf("发送 Azure ASR 配置失败: %v", err)
+		}
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, audioMessage(requestID, wavHeader())); err != nil {
+		conn.Close()
+		return nil, "", fmt.Errorf("发送 WAV 头失败: %v", err)
+	}
+	return conn, requestID, nil
+}
+
+// parseMessage 拆分文本消息的 Path 与 JSON 正文
+func parseMessage(data []byte) (string, []byte) {
+	parts := bytes.SplitN(data, []byte("\r\n\r\n"), 2)
+	path := ""
+	for _, line := range strings.Split(string(parts[0]), "\r\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(name), "Path") {
+			path = strings.TrimSpace(value)
+		}
+	}
+	if len(parts) < 2 {
+		return path, nil
+	}
+	return path, parts[1]
+}
+
+// AddAudio 发送16k单声道 int16 PCM，首帧时建立识别会话
+func (p *Provider) AddAudio(data []byte) error {
+	p.mu.Lock()
+	if !p.isStreaming {
+		p.logger.Info("----开始 Azure 流式识别----")
+		conn, requestID, err := p.dial(context.Background())
+		if err != nil {
+			p.mu.Unlock()
+			return err
+		}
+		p.conn = conn
+		p.requestID = requestID
+		p.isStreaming = true
+		p.lastResult = nil
+		go p.readLoop(conn)
+	}
+	conn := p.conn
+	requestID := p.requestID
+	p.mu.Unlock()
+
+	p.SetLastChunkTime(time.Now())
+	if len(data) == 0 {
+		return nil
+	}
+
+	p.writeMu.Lock()
+	conn.SetWriteDeadline(time.Now().Add(p.timeout))
+	err := conn.WriteMessage(websocket.BinaryMessage, audioMessage(requestID, data))
+	p.writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("发送音频数据失败: %v", err)
+	}
+	return nil
+}
+
+// readLoop 读取识别消息，turn.end、出错或监听器表示结束时退出
+func (p *Provider) readLoop(conn *websocket.Conn) {
+	defer func() {
+		p.mu.Lock()
+		if p.conn == conn {
+			p.isStreaming = false
+			p.conn = nil
+			conn.Close()
+		}
+		p.mu.Unlock()
+	}()
+
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if msgType != websocket.TextMessage {
+			continue
+		}
+		path, body := parseMessage(data)
+		switch path {
+		case "speech.hypothesis":
+			var h hypothesis
+			if err := json.Unmarshal(body, &h); err != nil || h.Text == "" {
+				continue
+			}
+			if interimListener, ok := p.GetListener().(providers.AsrInterimListener); ok {
+				interimListener.OnAsrInterim(h.Text)
+			}
+		case "speech.phrase":
+			var ph phrase
+			if err := json.Unmarshal(body, &ph); err != nil {
+				p.logger.Warn(fmt.Sprintf("Azure ASR 解析结果失败: %v", err))
+				continue
+			}
+			if finished := p.handlePhrase(ph); finished {
+				return
+			}
+		case "turn.end":
+			return
+		}
+	}
+}
+
+// handlePhrase 处理一句话的最终结果，返回监听器是否结束本轮识别
+func (p *Provider) handlePhrase(ph phrase) bool {
+	if ph.RecognitionStatus != "Success" {
+		if ph.RecognitionStatus != "NoMatch" && ph.RecognitionStatus != "InitialSilenceTimeout" {
+			p.logger.Warn(fmt.Sprintf("Azure ASR 识别状态: %s", ph.RecognitionStatus))
+		}
+		return false
+	}
+	detail := toAsrResult(ph)
+	if detail.Text == "" {
+		return false
+	}
+	if ph.PrimaryLanguage != nil {
+		p.logger.Debug(fmt.Sprintf("Azure ASR 检测到语种: %s", ph.PrimaryLanguage.Language))
+		detail.Language = ph.PrimaryLanguage.Language
+	}
+	p.mu.Lock()
+	p.lastResult = detail
+	p.mu.Unlock()
+
+	listener := p.GetListener()
+	if listener == nil {
+		return false
+	}
+	if detailListener, ok := listener.(providers.AsrDetailListener); ok {
+		detailListener.OnAsrDetail(detail)
+	}
+	return listener.OnAsrResult(detail.Text)
+}
+
+// toAsrResult 将 detailed 格式结果转换为结构化结果
+func toAsrResult(ph phrase) *providers.AsrResult {
+	text := strings.TrimSpace(ph.DisplayText)
+	utterance := providers.AsrUtterance{
+		Text:      text,
+		StartTime: int(ph.Offset / ticksPerMillis),
+		EndTime:   int((ph.Offset + ph.Duration) / ticksPerMillis),
+		Definite:  true,
+	}
+	if len(ph.NBest) > 0 {
+		best := ph.NBest[0]
+		if text == "" {
+			text = strings.TrimSpace(best.Display)
+			utterance.Text = text
+		}
+		utterance.Confidence = best.Confidence
+		for _, w := range best.Words {
+			utterance.Words = append(utterance.Words, providers.AsrWord{
+				Text:      w.Word,
+				StartTime: int(w.Offset / ticksPerMillis),
+				EndTime:   int((w.Offset + w.Duration) / ticksPerMillis),
+			})
+		}
+	}
+	return &providers.AsrResult{
+		Text:       text,
+		IsFinal:    true,
+		Confidence: utterance.Confidence,
+		Duration:   utterance.EndTime,
+		Utterances: []providers.AsrUtterance{utterance},
+	}
+}
+
+// LastResult 最近一次最终识别的结构化结果
+func (p *Provider) LastResult() *providers.AsrResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastResult
+}
+
+// Transcribe 使用独立会话识别一段完整音频，拼接所有句子
+func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	conn, requestID, err := p.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	sendErr := make(chan error, 1)
+	go func() {
+		for start := 0; start < len(audioData); start += transcribeChunk {
+			end := start + transcribeChunk
+			if end > len(audioData) {
+				end = len(audioData)
+			}
+			if err := conn.WriteMessage(websocket.BinaryMessage, audioMessage(requestID, audioData[start:end])); err != nil {
+				sendErr <- fmt.Errorf("发送音频数据失败: %v", err)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				sendErr <- ctx.Err()
+				return
+			case <-time.After(transcribeInterval):
+			}
+		}
+		sendErr <- conn.WriteMessage(websocket.BinaryMessage, audioMessage(requestID, nil))
+	}()
+
+	deadline := time.Now().Add(transcribeWindow)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	var text strings.Builder
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			select {
+			case e := <-sendErr:
+				if e != nil {
+					return "", e
+				}
+			default:
+			}
+			return "", fmt.Errorf("读取 Azure ASR 结果失败: %v", err)
+		}
+		if msgType != websocket.TextMessage {
+			continue
+		}
+		path, body := parseMessage(data)
+		switch path {
+		case "speech.phrase":
+			var ph phrase
+			if err := json.Unmarshal(body, &ph); err == nil && ph.RecognitionStatus == "Success" {
+				text.WriteString(toAsrResult(ph).Text)
+			}
+		case "turn.end":
+			return text.String(), nil
+		}
+	}
+}
+
+// Reset 发送音频结束帧并关闭连接，下一帧音频重新建立会话
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	conn := p.conn
+	requestID := p.requestID
+	p.conn = nil
+	p.isStreaming = false
+	p.lastResult = nil
+	p.mu.Unlock()
+
+	if conn != nil {
+		p.writeMu.Lock()
+		conn.SetWriteDeadline(time.Now().Add(p.timeout))
+		_ = conn.WriteMessage(websocket.BinaryMessage, audioMessage(requestID, nil))
+		p.writeMu.Unlock()
+		conn.Close()
+	}
+	p.InitAudioProcessing()
+	return nil
+}
+
+// Cleanup 关闭连接
+func (p *Provider) Cleanup() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	p.isStreaming = false
+	return nil
+}
+
+func init() {
+	// 注册 Azure ASR 提供者
+	asr.RegisterProvider(providers.ProviderMeta{
+		Name:         "azure",
+		Description:  "Azure 语音识别",
+		Capabilities: []string{providers.CapabilityStreaming},
+		Required:     []string{"key"},
+	}, func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
+		return NewProvider(config, deleteFile, logger)
+	})
+}
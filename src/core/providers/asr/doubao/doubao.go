@@ -13,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/asr"
 	"xiaozhi-server-go/src/core/utils"
 
@@ -43,9 +44,22 @@ const (
 	customCompression = 0xF
 
 	// 超时设置
-	idleTimeout = 10 * time.Second // 10秒没有新数据就结束识别
+	defaultIdleTimeout = 10 * time.Second // 默认10秒没有新数据就结束识别
+
+	defaultTimeout = 10 * time.Second // 建连（握手+初始请求）默认超时
 )
 
+// 识别端点
+const (
+	// nostreamURL 流式输入模式，分句结束后才返回结果
+	nostreamURL = "wss://openspeech.bytedance.com/api/v3/sauc/bigmodel_nostream"
+	// streamingURL 双向流式模式，实时返回中间结果与分句
+	streamingURL = "wss://openspeech.bytedance.com/api/v3/sauc/bigmodel"
+)
+
+// warmConnMaxAge 预热连接的最长保留时间，超过后服务端可能已断开空闲连接，改为重新建连
+const warmConnMaxAge = 20 * time.Second
+
 // Ensure Provider implements asr.Provider interface
 var _ asr.Provider = (*Provider)(nil)
 
@@ -59,31 +73,48 @@ type Provider struct {
 	wsURL         string
 	chunkDuration int
 	connectID     string
+	timeout       time.Duration // 建连超时，配置项 timeout
+	retryPolicy   utils.RetryPolicy
 	logger        *utils.Logger // 添加日志记录器
 
 	// 配置
 	modelName     string
 	endWindowSize int
+	idleTimeout   time.Duration // 识别中超过该时长没有新音频则结束本次识别
 	enablePunc    bool
 	enableITN     bool
 	enableDDC     bool
+	streaming     bool // 是否使用双向流式端点，开启中间结果与分句
 
 	// 流式识别相关字段
 	conn        *websocket.Conn
 	isStreaming bool
 	reqID       string
 	result      string
+	lastResult  *providers.AsrResult // 最近一次结构化结果
 	err         error
 	connMutex   sync.Mutex // 添加互斥锁保护连接状态
+
+	warmConn *websocket.Conn // 预热建立、尚未使用的连接
+	warmAt   time.Time
+}
+
+// Word 词级结果
+type Word struct {
+	Text       string  `json:"text"`
+	StartTime  int     `json:"start_time"`
+	EndTime    int     `json:"end_time"`
+	Confidence float64 `json:"confidence,omitempty"`
 }
 
-// responsePayload 响应数据结构
+// Utterance 分句结果
 type Utterance struct {
-	Text      string `json:"text"`
-	StartTime int    `json:"start_time"`
-	EndTime   int    `json:"end_time"`
-	Definite  bool   `json:"definite"`
-	// Words     []Word // Assuming Word struct might be needed later if word-level details are parsed
+	Text       string  `json:"text"`
+	StartTime  int     `json:"start_time"`
+	EndTime    int     `json:"end_time"`
+	Definite   bool    `json:"definite"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Words      []Word  `json:"words,omitempty"`
 }
 
 type AudioInfo struct {
@@ -91,6 +122,7 @@ type AudioInfo struct {
 }
 type ResultPayload struct {
 	Text       string                 `json:"text"`
+	Confidence float64                `json:"confidence,omitempty"`
 	Utterances []Utterance            `json:"utterances,omitempty"`
 	Additions  map[string]interface{} `json:"additions,omitempty"` // 新增 Additions 字段
 }
@@ -135,9 +167,12 @@ func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Pr
 		accessToken:   accessToken,
 		outputDir:     outputDir,
 		host:          "openspeech.bytedance.com",
-		wsURL:         "wss://openspeech.bytedance.com/api/v3/sauc/bigmodel_nostream",
+		wsURL:         nostreamURL,
 		chunkDuration: 200, // 固定使用200ms分片
 		connectID:     connectID,
+		timeout:       utils.ParseTimeout(config.Data["timeout"], defaultTimeout),
+		idleTimeout:   utils.ParseTimeout(config.Data["idle_timeout"], defaultIdleTimeout),
+		retryPolicy:   utils.DefaultRetryPolicy(),
 		logger:        logger, // 使用简单的logger
 
 		// 默认配置
@@ -148,6 +183,33 @@ func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Pr
 		enableDDC:     false,
 	}
 
+	// 双向流式端点：实时返回中间结果，utterance 定稿后才作为最终结果
+	if streaming, ok := config.Data["streaming"].(bool); ok && streaming {
+		provider.streaming = true
+		provider.wsURL = streamingURL
+	}
+	if wsURL, ok := config.Data["ws_url"].(string); ok && wsURL != "" {
+		provider.wsURL = wsURL
+	}
+	if endWindowSize, ok := config.Data["end_window_size"].(int); ok && endWindowSize > 0 {
+		provider.endWindowSize = endWindowSize
+	}
+	// 识别结果后处理：标点、逆文本规整（"一百二十"转为"120"）、语义顺滑（去掉"嗯""那个"等口语词）
+	if enablePunc, ok := config.Data["enable_punc"].(bool); ok {
+		provider.enablePunc = enablePunc
+	}
+	if enableITN, ok := config.Data["enable_itn"].(bool); ok {
+		provider.enableITN = enableITN
+	}
+	if enableDDC, ok := config.Data["enable_ddc"].(bool); ok {
+		provider.enableDDC = enableDDC
+	}
+
+	// 建连重试次数，不含首次
+	if retries, ok := config.Data["max_retries"].(int); ok {
+		provider.retryPolicy = provider.retryPolicy.WithMaxRetries(retries)
+	}
+
 	// 初始化音频处理
 	provider.InitAudioProcessing()
 
@@ -245,7 +307,7 @@ func (p *Provider) constructRequest() map[string]interface{} {
 			"enable_itn":      p.enableITN,
 			"enable_ddc":      p.enableDDC,
 			"result_type":     "single",
-			"show_utterances": false, // Added show_utterances, default to false
+			"show_utterances": true, // 返回分句、起止时间与置信度，流式模式还依赖 definite 判断分句
 		},
 	}
 }
@@ -382,92 +444,29 @@ func (p *Provider) AddAudioWithContext(ctx context.Context, data []byte) error {
 			p.closeConnection()
 		}
 
-		// 建立WebSocket连接
-		dialer := websocket.Dialer{
-			HandshakeTimeout: 10 * time.Second, // 设置握手超时
-		}
-		headers := map[string][]string{
-			"X-Api-App-Key":     {p.appID},
-			"X-Api-Access-Key":  {p.accessToken},
-			"X-Api-Resource-Id": {"volc.bigasr.sauc.duration"},
-			"X-Api-Connect-Id":  {p.connectID},
-		}
-
-		// 重试机制
-		var conn *websocket.Conn
-		var resp *http.Response
-		var err error
-		maxRetries := 2
-
-		for i := 0; i <= maxRetries; i++ {
-			conn, resp, err = dialer.DialContext(ctx, p.wsURL, headers)
-			if err == nil {
-				break
-			}
-
-			if i < maxRetries {
-				backoffTime := time.Duration(500*(i+1)) * time.Millisecond
-				fmt.Printf("WebSocket连接失败(尝试%d/%d): %v, 将在%v后重试\n",
-					i+1, maxRetries+1, err, backoffTime)
-				time.Sleep(backoffTime)
+		// 优先复用预热的连接，预热连接已失效时重新建连一次
+		conn, warm := p.takeWarmConn(), true
+		if conn == nil {
+			warm = false
+			var err error
+			if conn, err = p.dial(ctx); err != nil {
+				return err
 			}
 		}
-
-		if err != nil {
-			statusCode := 0
-			if resp != nil {
-				statusCode = resp.StatusCode
-			}
-			return fmt.Errorf("WebSocket连接失败(状态码:%d): %v", statusCode, err)
-		}
-
 		p.conn = conn
-
-		// 发送初始请求
-		p.reqID = fmt.Sprintf("%d", time.Now().UnixNano())
-		request := p.constructRequest()
-		requestBytes, err := json.Marshal(request)
-		if err != nil {
-			return fmt.Errorf("构造请求数据失败: %v", err)
-		}
-
-		var buf bytes.Buffer
-		gzipWriter := gzip.NewWriter(&buf)
-		if _, err := gzipWriter.Write(requestBytes); err != nil {
-			return fmt.Errorf("压缩请求数据失败: %v", err)
-		}
-		gzipWriter.Close()
-
-		compressedRequest := buf.Bytes()
-		header := p.generateHeader(clientFullRequest, noSequence, jsonFormat)
-
-		// 构造完整请求
-		size := make([]byte, 4)
-		binary.BigEndian.PutUint32(size, uint32(len(compressedRequest)))
-		fullRequest := append(header, size...)
-		fullRequest = append(fullRequest, compressedRequest...)
-
-		// 发送请求
-		if err := p.conn.WriteMessage(websocket.BinaryMessage, fullRequest); err != nil {
-			return fmt.Errorf("发送请求失败: %v", err)
-		}
-
-		// 读取响应
-		_, response, err := p.conn.ReadMessage()
-		if err != nil {
-			return fmt.Errorf("读取响应失败: %v", err)
-		}
-
-		initialResult, err := p.parseResponse(response)
-		if err != nil {
-			return fmt.Errorf("解析响应失败: %v", err)
-		}
-
-		// 检查初始响应状态
-		if msg, ok := initialResult["payload_msg"].(map[string]interface{}); ok {
-			// Doubao ASR v3 uses 20000000 for success code in initial response
-			if code, ok := msg["code"].(float64); ok && int(code) != 20000000 {
-				return fmt.Errorf("ASR初始化错误: %v", msg)
+		if err := p.startSession(); err != nil {
+			if !warm {
+				return err
+			}
+			p.logger.Warn(fmt.Sprintf("预热的ASR连接不可用，重新建立连接: %v", err))
+			p.closeConnection()
+			conn, err := p.dial(ctx)
+			if err != nil {
+				return err
+			}
+			p.conn = conn
+			if err := p.startSession(); err != nil {
+				return err
 			}
 		}
 
@@ -509,11 +508,23 @@ func (p *Provider) AddAudioWithContext(ctx context.Context, data []byte) error {
 				}
 
 				if respPayload.Code == 20000000 || respPayload.Code == 0 {
+					if p.streaming {
+						if finished := p.handleStreamingResult(respPayload); finished {
+							return
+						}
+						continue
+					}
+
+					detail := toAsrResult(respPayload, respPayload.Result.Utterances, true)
 					p.connMutex.Lock()
 					p.result = respPayload.Result.Text
+					p.lastResult = detail
 					p.connMutex.Unlock()
 
 					if listener := p.BaseProvider.GetListener(); listener != nil {
+						if detailListener, ok := listener.(providers.AsrDetailListener); ok && detail.Text != "" {
+							detailListener.OnAsrDetail(detail)
+						}
 						if finished := listener.OnAsrResult(respPayload.Result.Text); finished {
 							return
 						}
@@ -525,6 +536,7 @@ func (p *Provider) AddAudioWithContext(ctx context.Context, data []byte) error {
 
 	// 直接处理传入的音频数据，不使用缓冲区
 	now := time.Now()
+	idle := now.Sub(p.GetLastChunkTime())
 	p.BaseProvider.SetLastChunkTime(now)
 
 	// 检查是否有实际数据需要发送
@@ -535,8 +547,8 @@ func (p *Provider) AddAudioWithContext(ctx context.Context, data []byte) error {
 		}
 	}
 
-	// 检查是否超时
-	if p.isStreaming && now.Sub(p.GetLastChunkTime()) > idleTimeout {
+	// 检查是否超时，本次新建的识别不检查
+	if isStreaming && p.isStreaming && idle > p.idleTimeout {
 		fmt.Println("超时, 发送最后的音频数据")
 		if err := p.sendAudioData(data, true); err != nil {
 			return fmt.Errorf("发送最后的音频数据失败: %v", err)
@@ -547,6 +559,226 @@ func (p *Provider) AddAudioWithContext(ctx context.Context, data []byte) error {
 	return nil
 }
 
+// dial 建立到识别服务的 WebSocket 连接，失败时按重试策略重试
+func (p *Provider) dial(ctx context.Context) (*websocket.Conn, error) {
+	// 建立WebSocket连接
+	dialer := websocket.Dialer{
+		HandshakeTimeout: p.timeout, // 设置握手超时
+	}
+	headers := map[string][]string{
+		"X-Api-App-Key":     {p.appID},
+		"X-Api-Access-Key":  {p.accessToken},
+		"X-Api-Resource-Id": {"volc.bigasr.sauc.duration"},
+		"X-Api-Connect-Id":  {p.connectID},
+	}
+
+	// 重试机制
+	var resp *http.Response
+	policy := p.retryPolicy
+	policy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		fmt.Printf("WebSocket连接失败(尝试%d/%d): %v, 将在%v后重试\n",
+			attempt, policy.MaxAttempts, err, delay)
+	}
+	conn, err := utils.RetryWithResult(ctx, policy, func(int) (*websocket.Conn, error) {
+		var conn *websocket.Conn
+		var err error
+		conn, resp, err = dialer.DialContext(ctx, p.wsURL, headers)
+		if err != nil && resp != nil {
+			return nil, fmt.Errorf("%v: %w", err, &utils.HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+		}
+		return conn, err
+	})
+
+	if err != nil {
+		statusCode := 0
+		if resp != nil {
+			statusCode = resp.StatusCode
+		}
+		return nil, fmt.Errorf("WebSocket连接失败(状态码:%d): %v", statusCode, err)
+	}
+	return conn, nil
+}
+
+// startSession 在 p.conn 上发送初始请求并校验响应，调用方需持有 connMutex
+func (p *Provider) startSession() error {
+	// 发送初始请求
+	p.reqID = fmt.Sprintf("%d", time.Now().UnixNano())
+	request := p.constructRequest()
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("构造请求数据失败: %v", err)
+	}
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	if _, err := gzipWriter.Write(requestBytes); err != nil {
+		return fmt.Errorf("压缩请求数据失败: %v", err)
+	}
+	gzipWriter.Close()
+
+	compressedRequest := buf.Bytes()
+	header := p.generateHeader(clientFullRequest, noSequence, jsonFormat)
+
+	// 构造完整请求
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(compressedRequest)))
+	fullRequest := append(header, size...)
+	fullRequest = append(fullRequest, compressedRequest...)
+
+	// 发送请求，初始请求的往返同样受 timeout 约束
+	p.conn.SetReadDeadline(time.Now().Add(p.timeout))
+	if err := p.conn.WriteMessage(websocket.BinaryMessage, fullRequest); err != nil {
+		return fmt.Errorf("发送请求失败: %v", err)
+	}
+
+	// 读取响应
+	_, response, err := p.conn.ReadMessage()
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %v", err)
+	}
+	// 识别过程中的读取由 idle_timeout 与 Reset 控制
+	p.conn.SetReadDeadline(time.Time{})
+
+	initialResult, err := p.parseResponse(response)
+	if err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	// 检查初始响应状态
+	if msg, ok := initialResult["payload_msg"].(map[string]interface{}); ok {
+		// Doubao ASR v3 uses 20000000 for success code in initial response
+		if code, ok := msg["code"].(float64); ok && int(code) != 20000000 {
+			return fmt.Errorf("ASR初始化错误: %v", msg)
+		}
+	}
+	return nil
+}
+
+// Prewarm 实现 providers.Prewarmer，预先建立 WebSocket 连接，首次识别时直接复用以省去握手耗时
+func (p *Provider) Prewarm(ctx context.Context) error {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return err
+	}
+	p.connMutex.Lock()
+	defer p.connMutex.Unlock()
+	if p.isStreaming || p.warmConn != nil {
+		conn.Close()
+		return nil
+	}
+	p.warmConn = conn
+	p.warmAt = time.Now()
+	return nil
+}
+
+// takeWarmConn 取出未过期的预热连接，调用方需持有 connMutex
+func (p *Provider) takeWarmConn() *websocket.Conn {
+	conn := p.warmConn
+	p.warmConn = nil
+	if conn == nil {
+		return nil
+	}
+	if time.Since(p.warmAt) > warmConnMaxAge {
+		conn.Close()
+		return nil
+	}
+	return conn
+}
+
+// handleStreamingResult 处理双向流式端点的结果：未定稿的分句作为中间结果通知，
+// 出现 definite 分句时才作为最终结果交给监听器，返回是否结束本轮识别
+func (p *Provider) handleStreamingResult(resp responsePayload) bool {
+	listener := p.BaseProvider.GetListener()
+	result := resp.Result
+
+	final := ""
+	interim := ""
+	var definite []Utterance
+	for _, utterance := range result.Utterances {
+		if utterance.Definite {
+			final += utterance.Text
+			definite = append(definite, utterance)
+		} else {
+			interim += utterance.Text
+		}
+	}
+	// 未返回 utterances 时以整体文本作为中间结果
+	if len(result.Utterances) == 0 {
+		interim = result.Text
+	}
+
+	if final == "" {
+		if interim != "" {
+			if interimListener, ok := listener.(providers.AsrInterimListener); ok {
+				interimListener.OnAsrInterim(interim)
+			}
+		}
+		return false
+	}
+
+	detail := toAsrResult(resp, definite, true)
+	detail.Text = final
+	p.connMutex.Lock()
+	p.result = final
+	p.lastResult = detail
+	p.connMutex.Unlock()
+
+	if listener != nil {
+		if detailListener, ok := listener.(providers.AsrDetailListener); ok {
+			detailListener.OnAsrDetail(detail)
+		}
+		return listener.OnAsrResult(final)
+	}
+	return false
+}
+
+// toAsrResult 将豆包响应转换为结构化识别结果
+func toAsrResult(resp responsePayload, utterances []Utterance, isFinal bool) *providers.AsrResult {
+	result := &providers.AsrResult{
+		Text:       resp.Result.Text,
+		IsFinal:    isFinal,
+		Confidence: resp.Result.Confidence,
+		Duration:   resp.AudioInfo.Duration,
+		Utterances: make([]providers.AsrUtterance, 0, len(utterances)),
+	}
+
+	var confidenceSum float64
+	var confidenceCount int
+	for _, u := range utterances {
+		utterance := providers.AsrUtterance{
+			Text:       u.Text,
+			StartTime:  u.StartTime,
+			EndTime:    u.EndTime,
+			Definite:   u.Definite,
+			Confidence: u.Confidence,
+		}
+		for _, w := range u.Words {
+			utterance.Words = append(utterance.Words, providers.AsrWord{
+				Text:       w.Text,
+				StartTime:  w.StartTime,
+				EndTime:    w.EndTime,
+				Confidence: w.Confidence,
+			})
+		}
+		if u.Confidence > 0 {
+			confidenceSum += u.Confidence
+			confidenceCount++
+		}
+		result.Utterances = append(result.Utterances, utterance)
+	}
+	if result.Confidence == 0 && confidenceCount > 0 {
+		result.Confidence = confidenceSum / float64(confidenceCount)
+	}
+	return result
+}
+
+// LastResult 获取最近一次结构化识别结果，尚无结果时返回 nil
+func (p *Provider) LastResult() *providers.AsrResult {
+	p.connMutex.Lock()
+	defer p.connMutex.Unlock()
+	return p.lastResult
+}
+
 func (p *Provider) closeConnection() {
 	defer func() {
 		if r := recover(); r != nil {
@@ -618,6 +850,7 @@ func (p *Provider) Reset() error {
 
 	p.reqID = ""
 	p.result = ""
+	p.lastResult = nil
 	p.err = nil
 
 	// 重置音频处理
@@ -645,6 +878,10 @@ func (p *Provider) Cleanup() error {
 
 	// 确保WebSocket连接关闭
 	p.closeConnection()
+	if p.warmConn != nil {
+		p.warmConn.Close()
+		p.warmConn = nil
+	}
 
 	p.logger.Info("ASR资源已清理")
 
@@ -653,7 +890,12 @@ func (p *Provider) Cleanup() error {
 
 func init() {
 	// 注册豆包ASR提供者
-	asr.Register("doubao", func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
+	asr.RegisterProvider(providers.ProviderMeta{
+		Name:         "doubao",
+		Description:  "豆包（火山引擎）语音识别",
+		Capabilities: []string{providers.CapabilityStreaming},
+		Required:     []string{"appid", "access_token"},
+	}, func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
 		return NewProvider(config, deleteFile, logger)
 	})
 }
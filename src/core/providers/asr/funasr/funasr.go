@@ -0,0 +1,459 @@
+package funasr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultAddr           = "ws://127.0.0.1:10095"
+	defaultMode           = "2pass"
+	defaultTimeout        = 10 * time.Second
+	defaultHotwordWeight  = 20
+	defaultChunkInterval  = 10
+	defaultAudioFs        = 16000
+	defaultWavName        = "xiaozhi"
+	defaultResponseWindow = 30 * time.Second // Transcribe 等待离线结果的上限
+)
+
+// Ensure Provider implements asr.Provider interface
+var _ asr.Provider = (*Provider)(nil)
+
+// Provider FunASR runtime websocket 服务的 ASR 提供者
+// 协议：先发送 JSON 配置帧，随后发送 16k int16 PCM 二进制帧，发送 is_speaking=false 表示结束；
+// 2pass 模式下服务端先返回 2pass-online 中间结果，VAD 判停后返回带标点的 2pass-offline 结果
+type Provider struct {
+	*asr.BaseProvider
+	addr          string
+	mode          string
+	hotwords      string // JSON 字符串，形如 {"小智":20}
+	itn           bool
+	chunkSize     []int
+	chunkInterval int
+	timeout       time.Duration
+	retryPolicy   utils.RetryPolicy
+	logger        *utils.Logger
+
+	mu          sync.Mutex
+	writeMu     sync.Mutex // gorilla websocket 不支持并发写
+	conn        *websocket.Conn
+	isStreaming bool
+	interim     string // 当前句已累积的 online 结果
+	lastResult  *providers.AsrResult
+}
+
+// funasrResult 服务端返回的识别结果
+type funasrResult struct {
+	Mode       string      `json:"mode"`
+	Text       string      `json:"text"`
+	WavName    string      `json:"wav_name"`
+	IsFinal    bool        `json:"is_final"`
+	Timestamp  string      `json:"timestamp"`
+	StampSents []stampSent `json:"stamp_sents"`
+}
+
+// stampSent 离线结果中的分句时间戳，单位毫秒
+type stampSent struct {
+	TextSeg string  `json:"text_seg"`
+	Punc    string  `json:"punc"`
+	Start   int     `json:"start"`
+	End     int     `json:"end"`
+	TsList  [][]int `json:"ts_list"`
+}
+
+// NewProvider 创建 FunASR 提供者
+func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Provider, error) {
+	addr, _ := config.Data["addr"].(string)
+	if addr == "" {
+		addr = defaultAddr
+	}
+	mode, _ := config.Data["mode"].(string)
+	if mode == "" {
+		mode = defaultMode
+	}
+	switch mode {
+	case "2pass", "online", "offline":
+	default:
+		return nil, fmt.Errorf("不支持的 FunASR 识别模式: %s", mode)
+	}
+
+	hotwords, err := parseHotwords(config.Data["hotwords"])
+	if err != nil {
+		return nil, err
+	}
+
+	itn := true
+	if v, ok := config.Data["itn"].(bool); ok {
+		itn = v
+	}
+
+	p := &Provider{
+		BaseProvider:  asr.NewBaseProvider(config, deleteFile),
+		addr:          addr,
+		mode:          mode,
+		hotwords:      hotwords,
+		itn:           itn,
+		chunkSize:     parseChunkSize(config.Data["chunk_size"]),
+		chunkInterval: defaultChunkInterval,
+		timeout:       utils.ParseTimeout(config.Data["timeout"], defaultTimeout),
+		retryPolicy:   utils.DefaultRetryPolicy(),
+		logger:        logger,
+	}
+	if interval, ok := config.Data["chunk_interval"].(int); ok && interval > 0 {
+		p.chunkInterval = interval
+	}
+	if retries, ok := config.Data["max_retries"].(int); ok {
+		p.retryPolicy = p.retryPolicy.WithMaxRetries(retries)
+	}
+	// 本地部署的服务重启期间连接会被拒绝，所有建连错误都值得重试
+	p.retryPolicy.Retryable = func(error) bool { return true }
+	p.retryPolicy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		p.logger.Warn(fmt.Sprintf("FunASR 连接失败(尝试%d/%d): %v, 将在%v后重试",
+			attempt, p.retryPolicy.MaxAttempts, err, delay))
+	}
+
+	p.InitAudioProcessing()
+	return p, nil
+}
+
+// parseHotwords 热词支持三种写法：{"词": 权重} 映射、词列表（使用默认权重）、
+// 或 "词1 权重1 词2 权重2" 形式的字符串，统一转为 FunASR 需要的 JSON 字符串
+func parseHotwords(value interface{}) (string, error) {
+	weights := make(map[string]int)
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case map[string]interface{}:
+		for word, weight := range v {
+			switch w := weight.(type) {
+			case int:
+				weights[word] = w
+			case float64:
+				weights[word] = int(w)
+			default:
+				weights[word] = defaultHotwordWeight
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if word, ok := item.(string); ok && word != "" {
+				weights[word] = defaultHotwordWeight
+			}
+		}
+	case string:
+		fields := strings.Fields(v)
+		for i := 0; i < len(fields); i++ {
+			weight := defaultHotwordWeight
+			if i+1 < len(fields) {
+				if w, err := strconv.Atoi(fields[i+1]); err == nil {
+					weight = w
+					weights[fields[i]] = weight
+					i++
+					continue
+				}
+			}
+			weights[fields[i]] = weight
+		}
+	default:
+		return "", fmt.Errorf("FunASR 热词配置格式错误: %T", value)
+	}
+	if len(weights) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(weights)
+	if err != nil {
+		return "", fmt.Errorf("序列化 FunASR 热词失败: %v", err)
+	}
+	return string(data), nil
+}
+
+// parseChunkSize 解析流式分片参数，默认 [5,10,5] 即 600ms 分片、300ms 前后看
+func parseChunkSize(value interface{}) []int {
+	items, ok := value.([]interface{})
+	if !ok || len(items) != 3 {
+		return []int{5, 10, 5}
+	}
+	sizes := make([]int, 0, 3)
+	for _, item := range items {
+		switch v := item.(type) {
+		case int:
+			sizes = append(sizes, v)
+		case float64:
+			sizes = append(sizes, int(v))
+		default:
+			return []int{5, 10, 5}
+		}
+	}
+	return sizes
+}
+
+// startMessage 构造会话配置帧
+func (p *Provider) startMessage(mode string) map[string]interface{} {
+	msg := map[string]interface{}{
+		"mode":           mode,
+		"chunk_size":     p.chunkSize,
+		"chunk_interval": p.chunkInterval,
+		"wav_name":       defaultWavName,
+		"wav_format":     "pcm",
+		"audio_fs":       defaultAudioFs,
+		"is_speaking":    true,
+		"itn":            p.itn,
+	}
+	if p.hotwords != "" {
+		msg["hotwords"] = p.hotwords
+	}
+	return msg
+}
+
+// dial 带退避重试地建立连接并发送配置帧
+func (p *Provider) dial(ctx context.Context, mode string) (*websocket.Conn, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: p.timeout}
+	conn, err := utils.RetryWithResult(ctx, p.retryPolicy, func(int) (*websocket.Conn, error) {
+		conn, _, err := dialer.DialContext(ctx, p.addr, nil)
+		return conn, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("连接 FunASR 服务 %s 失败: %v", p.addr, err)
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(p.timeout))
+	if err := conn.WriteJSON(p.startMessage(mode)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("发送 FunASR 配置失败: %v", err)
+	}
+	return conn, nil
+}
+
+// AddAudio 发送16k单声道 int16 PCM，首帧时建立识别会话
+func (p *Provider) AddAudio(data []byte) error {
+	p.mu.Lock()
+	if !p.isStreaming {
+		p.logger.Info("----开始 FunASR 流式识别----")
+		conn, err := p.dial(context.Background(), p.mode)
+		if err != nil {
+			p.mu.Unlock()
+			return err
+		}
+		p.conn = conn
+		p.isStreaming = true
+		p.interim = ""
+		p.lastResult = nil
+		go p.readLoop(conn)
+	}
+	conn := p.conn
+	p.mu.Unlock()
+
+	p.SetLastChunkTime(time.Now())
+	if len(data) == 0 {
+		return nil
+	}
+
+	p.writeMu.Lock()
+	conn.SetWriteDeadline(time.Now().Add(p.timeout))
+	err := conn.WriteMessage(websocket.BinaryMessage, data)
+	p.writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("发送音频数据失败: %v", err)
+	}
+	return nil
+}
+
+// readLoop 读取识别结果，监听器表示本轮结束或连接关闭时退出
+func (p *Provider) readLoop(conn *websocket.Conn) {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			p.mu.Lock()
+			if p.conn == conn {
+				// 服务端主动断开，下一帧音频重新建立会话
+				p.isStreaming = false
+				p.conn = nil
+				p.logger.Warn(fmt.Sprintf("FunASR 连接断开: %v", err))
+			}
+			p.mu.Unlock()
+			conn.Close()
+			return
+		}
+
+		var result funasrResult
+		if err := json.Unmarshal(message, &result); err != nil {
+			p.logger.Warn(fmt.Sprintf("FunASR 解析结果失败: %v", err))
+			continue
+		}
+		if finished := p.handleResult(result); finished {
+			return
+		}
+	}
+}
+
+// handleResult 分发中间结果与最终结果，返回监听器是否结束本轮识别
+func (p *Provider) handleResult(result funasrResult) bool {
+	listener := p.GetListener()
+
+	if isOnline(result.Mode) {
+		if result.Text == "" {
+			return false
+		}
+		p.mu.Lock()
+		p.interim += result.Text
+		interim := p.interim
+		p.mu.Unlock()
+		if interimListener, ok := listener.(providers.AsrInterimListener); ok {
+			interimListener.OnAsrInterim(interim)
+		}
+		// 纯 online 模式没有离线修正，is_final 即为句尾
+		if p.mode != "online" || !result.IsFinal {
+			return false
+		}
+		result.Text = interim
+	}
+
+	text := strings.TrimSpace(result.Text)
+	detail := toAsrResult(text, result.StampSents)
+	p.mu.Lock()
+	p.interim = ""
+	p.lastResult = detail
+	p.mu.Unlock()
+
+	if listener == nil || text == "" {
+		return false
+	}
+	if detailListener, ok := listener.(providers.AsrDetailListener); ok {
+		detailListener.OnAsrDetail(detail)
+	}
+	return listener.OnAsrResult(text)
+}
+
+func isOnline(mode string) bool {
+	return mode == "online" || mode == "2pass-online"
+}
+
+// toAsrResult 将离线结果的分句时间戳转换为结构化结果
+func toAsrResult(text string, sents []stampSent) *providers.AsrResult {
+	result := &providers.AsrResult{Text: text, IsFinal: true}
+	for _, sent := range sents {
+		utterance := providers.AsrUtterance{
+			Text:      sent.TextSeg + sent.Punc,
+			StartTime: sent.Start,
+			EndTime:   sent.End,
+			Definite:  true,
+		}
+		// text_seg 以空格分隔字词，与 ts_list 一一对应
+		words := strings.Fields(sent.TextSeg)
+		if len(words) == len(sent.TsList) {
+			utterance.Text = strings.Join(words, "") + sent.Punc
+			for i, word := range words {
+				if len(sent.TsList[i]) != 2 {
+					continue
+				}
+				utterance.Words = append(utterance.Words, providers.AsrWord{
+					Text:      word,
+					StartTime: sent.TsList[i][0],
+					EndTime:   sent.TsList[i][1],
+				})
+			}
+		}
+		result.Utterances = append(result.Utterances, utterance)
+		if sent.End > result.Duration {
+			result.Duration = sent.End
+		}
+	}
+	return result
+}
+
+// LastResult 最近一次最终识别的结构化结果
+func (p *Provider) LastResult() *providers.AsrResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastResult
+}
+
+// Transcribe 使用独立的 offline 会话识别一段完整音频
+func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	conn, err := p.dial(ctx, "offline")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, audioData); err != nil {
+		return "", fmt.Errorf("发送音频数据失败: %v", err)
+	}
+	if err := conn.WriteJSON(map[string]interface{}{"is_speaking": false}); err != nil {
+		return "", fmt.Errorf("发送结束标记失败: %v", err)
+	}
+
+	deadline := time.Now().Add(defaultResponseWindow)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return "", fmt.Errorf("读取 FunASR 结果失败: %v", err)
+		}
+		var result funasrResult
+		if err := json.Unmarshal(message, &result); err != nil {
+			continue
+		}
+		if result.Mode == "offline" || result.IsFinal {
+			return strings.TrimSpace(result.Text), nil
+		}
+	}
+}
+
+// Reset 通知服务端结束当前会话并关闭连接，下一帧音频重新建立会话
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	conn := p.conn
+	p.conn = nil
+	p.isStreaming = false
+	p.interim = ""
+	p.lastResult = nil
+	p.mu.Unlock()
+
+	if conn != nil {
+		p.writeMu.Lock()
+		conn.SetWriteDeadline(time.Now().Add(p.timeout))
+		_ = conn.WriteJSON(map[string]interface{}{"is_speaking": false})
+		p.writeMu.Unlock()
+		conn.Close()
+	}
+	p.InitAudioProcessing()
+	return nil
+}
+
+// Cleanup 关闭连接
+func (p *Provider) Cleanup() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	p.isStreaming = false
+	return nil
+}
+
+func init() {
+	// 注册 FunASR 提供者
+	asr.RegisterProvider(providers.ProviderMeta{
+		Name:         "funasr",
+		Description:  "FunASR 本地识别服务",
+		Capabilities: []string{providers.CapabilityStreaming, providers.CapabilityLocal},
+	}, func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
+		return NewProvider(config, deleteFile, logger)
+	})
+}
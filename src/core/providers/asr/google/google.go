@@ -0,0 +1,388 @@
+package google
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/utils"
+
+	speech "cloud.google.com/go/speech/apiv1"
+	"cloud.google.com/go/speech/apiv1/speechpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultLanguage = "zh-CN"
+	defaultTimeout  = 10 * time.Second
+	sampleRate      = 16000
+)
+
+// Ensure Provider implements asr.Provider interface
+var _ asr.Provider = (*Provider)(nil)
+
+// Provider Google Cloud Speech-to-Text，流式识别走 StreamingRecognize gRPC 双向流
+// 每轮识别打开一条流，首条消息携带识别配置，之后发送 PCM 音频；is_final 的结果作为最终结果
+type Provider struct {
+	*asr.BaseProvider
+	client      *speech.Client
+	config      *speechpb.RecognitionConfig
+	single      bool // single_utterance，服务端检测到一句话结束后自动关闭流
+	retryPolicy utils.RetryPolicy
+	logger      *utils.Logger
+
+	mu          sync.Mutex
+	stream      speechpb.Speech_StreamingRecognizeClient
+	cancel      context.CancelFunc
+	isStreaming bool
+	lastResult  *providers.AsrResult
+}
+
+// NewProvider 创建 Google ASR 提供者，使用 service account json 鉴权
+func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Provider, error) {
+	var opts []option.ClientOption
+	if file, ok := config.Data["credentials_file"].(string); ok && file != "" {
+		opts = append(opts, option.WithCredentialsFile(file))
+	} else if content, ok := config.Data["credentials_json"].(string); ok && content != "" {
+		opts = append(opts, option.WithCredentialsJSON([]byte(content)))
+	}
+	// 均未配置时回退到 GOOGLE_APPLICATION_CREDENTIALS 环境变量
+	if endpoint, ok := config.Data["endpoint"].(string); ok && endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+
+	language, _ := config.Data["language"].(string)
+	if language == "" {
+		language = defaultLanguage
+	}
+	recognitionConfig := &speechpb.RecognitionConfig{
+		Encoding:                   speechpb.RecognitionConfig_LINEAR16,
+		SampleRateHertz:            sampleRate,
+		AudioChannelCount:          1,
+		LanguageCode:               language,
+		EnableAutomaticPunctuation: true,
+		EnableWordTimeOffsets:      true,
+		EnableWordConfidence:       true,
+	}
+	// 多语言：识别时在主语言与备选语言间自动选择，最多3个备选
+	if alternatives, ok := config.Data["alternative_languages"].([]interface{}); ok {
+		for _, item := range alternatives {
+			if code, ok := item.(string); ok && code != "" {
+				recognitionConfig.AlternativeLanguageCodes = append(recognitionConfig.AlternativeLanguageCodes, code)
+			}
+		}
+	}
+	// 说话人区分，会议转写时为每句标注说话人
+	if v, ok := config.Data["diarization"].(bool); ok && v {
+		recognitionConfig.DiarizationConfig = &speechpb.SpeakerDiarizationConfig{EnableSpeakerDiarization: true}
+	}
+	if v, ok := config.Data["punctuation"].(bool); ok {
+		recognitionConfig.EnableAutomaticPunctuation = v
+	}
+	if model, ok := config.Data["model"].(string); ok && model != "" {
+		recognitionConfig.Model = model
+	}
+	if phrases, ok := config.Data["phrases"].([]interface{}); ok && len(phrases) > 0 {
+		speechContext := &speechpb.SpeechContext{}
+		for _, item := range phrases {
+			if phrase, ok := item.(string); ok && phrase != "" {
+				speechContext.Phrases = append(speechContext.Phrases, phrase)
+			}
+		}
+		recognitionConfig.SpeechContexts = []*speechpb.SpeechContext{speechContext}
+	}
+
+	timeout := utils.ParseTimeout(config.Data["timeout"], defaultTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	client, err := speech.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Google Speech 客户端失败: %v", err)
+	}
+
+	p := &Provider{
+		BaseProvider: asr.NewBaseProvider(config, deleteFile),
+		client:       client,
+		config:       recognitionConfig,
+		single:       true,
+		retryPolicy:  utils.DefaultRetryPolicy(),
+		logger:       logger,
+	}
+	if v, ok := config.Data["single_utterance"].(bool); ok {
+		p.single = v
+	}
+	if retries, ok := config.Data["max_retries"].(int); ok {
+		p.retryPolicy = p.retryPolicy.WithMaxRetries(retries)
+	}
+	p.retryPolicy.Retryable = isRetryable
+	p.retryPolicy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		p.logger.Warn(fmt.Sprintf("Google ASR 请求失败(尝试%d/%d): %v, 将在%v后重试",
+			attempt, p.retryPolicy.MaxAttempts, err, delay))
+	}
+
+	p.InitAudioProcessing()
+	return p, nil
+}
+
+// isRetryable gRPC 错误按状态码判断，服务不可用、限流与超时可重试
+func isRetryable(err error) bool {
+	if st, ok := status.FromError(err); ok && st.Code() != codes.Unknown {
+		switch st.Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted:
+			return true
+		}
+		return false
+	}
+	return utils.IsRetryableError(err)
+}
+
+// openStream 打开流并发送识别配置
+func (p *Provider) openStream() (speechpb.Speech_StreamingRecognizeClient, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := utils.RetryWithResult(ctx, p.retryPolicy, func(int) (speechpb.Speech_StreamingRecognizeClient, error) {
+		return p.client.StreamingRecognize(ctx)
+	})
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("打开 Google 识别流失败: %v", err)
+	}
+
+	err = stream.Send(&speechpb.StreamingRecognizeRequest{
+		StreamingRequest: &speechpb.StreamingRecognizeRequest_StreamingConfig{
+			StreamingConfig: &speechpb.StreamingRecognitionConfig{
+				Config:          p.config,
+				InterimResults:  true,
+				SingleUtterance: p.single,
+			},
+		},
+	})
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("发送 Google 识别配置失败: %v", err)
+	}
+	return stream, cancel, nil
+}
+
+// AddAudio 发送16k单声道 int16 PCM，首帧时打开识别流
+func (p *Provider) AddAudio(data []byte) error {
+	p.mu.Lock()
+	if !p.isStreaming {
+		p.logger.Info("----开始 Google 流式识别----")
+		stream, cancel, err := p.openStream()
+		if err != nil {
+			p.mu.Unlock()
+			return err
+		}
+		p.stream = stream
+		p.cancel = cancel
+		p.isStreaming = true
+		p.lastResult = nil
+		go p.readLoop(stream)
+	}
+	stream := p.stream
+	p.mu.Unlock()
+
+	p.SetLastChunkTime(time.Now())
+	if len(data) == 0 {
+		return nil
+	}
+
+	// gRPC 流的 Send 不支持并发调用，音频由单个协程顺序送入
+	err := stream.Send(&speechpb.StreamingRecognizeRequest{
+		StreamingRequest: &speechpb.StreamingRecognizeRequest_AudioContent{AudioContent: data},
+	})
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("发送音频数据失败: %v", err)
+	}
+	return nil
+}
+
+// readLoop 读取识别结果，流结束、出错或监听器表示结束时退出
+func (p *Provider) readLoop(stream speechpb.Speech_StreamingRecognizeClient) {
+	defer p.closeStream(stream)
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if err != io.EOF && !strings.Contains(err.Error(), "context canceled") {
+				p.logger.Warn(fmt.Sprintf("Google 识别流结束: %v", err))
+			}
+			return
+		}
+		if resp.Error != nil {
+			p.logger.Error(fmt.Sprintf("Google ASR 识别错误(code:%d): %s", resp.Error.Code, resp.Error.Message))
+			return
+		}
+		for _, result := range resp.Results {
+			if finished := p.handleResult(result); finished {
+				return
+			}
+		}
+	}
+}
+
+// closeStream 释放流，仅当其仍为当前流时重置状态
+func (p *Provider) closeStream(stream speechpb.Speech_StreamingRecognizeClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stream != stream {
+		return
+	}
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+	p.stream = nil
+	p.isStreaming = false
+}
+
+// handleResult 分发中间结果与最终结果，返回监听器是否结束本轮识别
+func (p *Provider) handleResult(result *speechpb.StreamingRecognitionResult) bool {
+	if len(result.Alternatives) == 0 {
+		return false
+	}
+	listener := p.GetListener()
+	text := strings.TrimSpace(result.Alternatives[0].Transcript)
+	if text == "" {
+		return false
+	}
+
+	if !result.IsFinal {
+		if interimListener, ok := listener.(providers.AsrInterimListener); ok {
+			interimListener.OnAsrInterim(text)
+		}
+		return false
+	}
+
+	detail := toAsrResult(result)
+	p.mu.Lock()
+	p.lastResult = detail
+	p.mu.Unlock()
+
+	if listener == nil {
+		return false
+	}
+	if detailListener, ok := listener.(providers.AsrDetailListener); ok {
+		detailListener.OnAsrDetail(detail)
+	}
+	return listener.OnAsrResult(text)
+}
+
+// toAsrResult 将最终结果转换为结构化结果
+func toAsrResult(result *speechpb.StreamingRecognitionResult) *providers.AsrResult {
+	alternative := result.Alternatives[0]
+	utterance := providers.AsrUtterance{
+		Text:       alternative.Transcript,
+		Definite:   true,
+		Confidence: float64(alternative.Confidence),
+	}
+	for _, w := range alternative.Words {
+		utterance.Words = append(utterance.Words, providers.AsrWord{
+			Text:       w.Word,
+			StartTime:  int(w.StartTime.AsDuration().Milliseconds()),
+			EndTime:    int(w.EndTime.AsDuration().Milliseconds()),
+			Confidence: float64(w.Confidence),
+		})
+	}
+	if len(utterance.Words) > 0 {
+		utterance.StartTime = utterance.Words[0].StartTime
+	}
+	if len(alternative.Words) > 0 {
+		first := alternative.Words[0]
+		if first.SpeakerLabel != "" {
+			utterance.Speaker = first.SpeakerLabel
+		} else if first.SpeakerTag > 0 {
+			utterance.Speaker = strconv.Itoa(int(first.SpeakerTag))
+		}
+	}
+	if result.ResultEndTime != nil {
+		utterance.EndTime = int(result.ResultEndTime.AsDuration().Milliseconds())
+	}
+	return &providers.AsrResult{
+		Text:       alternative.Transcript,
+		IsFinal:    true,
+		Confidence: utterance.Confidence,
+		Duration:   utterance.EndTime,
+		Language:   result.LanguageCode,
+		Utterances: []providers.AsrUtterance{utterance},
+	}
+}
+
+// LastResult 最近一次最终识别的结构化结果
+func (p *Provider) LastResult() *providers.AsrResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastResult
+}
+
+// Transcribe 使用同步 Recognize 接口识别一段完整音频（不超过1分钟）
+func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	resp, err := utils.RetryWithResult(ctx, p.retryPolicy, func(int) (*speechpb.RecognizeResponse, error) {
+		return p.client.Recognize(ctx, &speechpb.RecognizeRequest{
+			Config: p.config,
+			Audio: &speechpb.RecognitionAudio{
+				AudioSource: &speechpb.RecognitionAudio_Content{Content: audioData},
+			},
+		})
+	})
+	if err != nil {
+		return "", fmt.Errorf("Google ASR 识别失败: %v", err)
+	}
+
+	var text strings.Builder
+	for _, result := range resp.Results {
+		if len(result.Alternatives) > 0 {
+			text.WriteString(result.Alternatives[0].Transcript)
+		}
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// Reset 结束当前识别流，下一帧音频重新打开
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	stream := p.stream
+	cancel := p.cancel
+	p.stream = nil
+	p.cancel = nil
+	p.isStreaming = false
+	p.lastResult = nil
+	p.mu.Unlock()
+
+	if stream != nil {
+		_ = stream.CloseSend()
+	}
+	if cancel != nil {
+		cancel()
+	}
+	p.InitAudioProcessing()
+	return nil
+}
+
+// Cleanup 关闭识别流与 gRPC 连接
+func (p *Provider) Cleanup() error {
+	p.Reset()
+	if err := p.client.Close(); err != nil {
+		return fmt.Errorf("关闭 Google Speech 客户端失败: %v", err)
+	}
+	return nil
+}
+
+func init() {
+	// 注册 Google ASR 提供者
+	asr.RegisterProvider(providers.ProviderMeta{
+		Name:         "google",
+		Description:  "Google Cloud Speech-to-Text",
+		Capabilities: []string{providers.CapabilityStreaming},
+	}, func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
+		return NewProvider(config, deleteFile, logger)
+	})
+}
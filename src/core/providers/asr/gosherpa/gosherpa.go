@@ -0,0 +1,374 @@
+package gosherpa
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultAddr         = "ws://127.0.0.1:6006"
+	defaultTimeout      = 10 * time.Second
+	defaultPingInterval = 15 * time.Second
+)
+
+// Ensure Provider implements asr.Provider interface
+var _ asr.Provider = (*Provider)(nil)
+
+// Provider 自建 sherpa-onnx websocket 服务的 ASR 提供者
+// 协议：客户端发送 float32 小端 PCM 二进制帧，发送文本 "Done" 表示结束；
+// 服务端返回 JSON 结果，is_final 为 true 表示端点检测到一句话结束
+type Provider struct {
+	*asr.BaseProvider
+	addr         string
+	timeout      time.Duration
+	pingInterval time.Duration
+	retryPolicy  utils.RetryPolicy
+	logger       *utils.Logger
+
+	mu        sync.Mutex
+	writeMu   sync.Mutex // gorilla websocket 不支持并发写
+	conn      *websocket.Conn
+	connGen   int   // 连接代数，旧连接的读协程退出时据此判断是否需要重连
+	lastErr   error // 最近一次重连失败的原因，非空时视为不健康
+	closed    bool
+	stopPing  chan struct{}
+	reconnect chan struct{}
+}
+
+// sherpaResult 服务端返回的识别结果
+type sherpaResult struct {
+	Text    string `json:"text"`
+	Segment int    `json:"segment"`
+	IsFinal bool   `json:"is_final"`
+}
+
+// NewProvider 创建 gosherpa ASR 提供者
+func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Provider, error) {
+	addr, _ := config.Data["addr"].(string)
+	if addr == "" {
+		addr = defaultAddr
+	}
+
+	p := &Provider{
+		BaseProvider: asr.NewBaseProvider(config, deleteFile),
+		addr:         addr,
+		timeout:      utils.ParseTimeout(config.Data["timeout"], defaultTimeout),
+		pingInterval: utils.ParseTimeout(config.Data["ping_interval"], defaultPingInterval),
+		retryPolicy:  utils.DefaultRetryPolicy(),
+		logger:       logger,
+		reconnect:    make(chan struct{}, 1),
+	}
+	if retries, ok := config.Data["max_retries"].(int); ok {
+		p.retryPolicy = p.retryPolicy.WithMaxRetries(retries)
+	}
+	// 所有错误都按网络错误处理，服务重启期间持续退避重试
+	p.retryPolicy.Retryable = func(error) bool { return true }
+	p.retryPolicy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		p.logger.Warn(fmt.Sprintf("gosherpa 连接失败(尝试%d/%d): %v, 将在%v后重试",
+			attempt, p.retryPolicy.MaxAttempts, err, delay))
+	}
+
+	p.InitAudioProcessing()
+
+	// 创建时即建立长连接，服务不可用时由资源池感知创建失败
+	if err := p.connect(context.Background()); err != nil {
+		return nil, err
+	}
+	go p.reconnectLoop()
+	return p, nil
+}
+
+// Initialize 确保长连接可用
+func (p *Provider) Initialize() error {
+	p.mu.Lock()
+	connected := p.conn != nil
+	p.mu.Unlock()
+	if connected {
+		return nil
+	}
+	return p.connect(context.Background())
+}
+
+// connect 带退避重试地建立连接，成功后启动读协程与心跳
+func (p *Provider) connect(ctx context.Context) error {
+	dialer := websocket.Dialer{HandshakeTimeout: p.timeout}
+	conn, err := utils.RetryWithResult(ctx, p.retryPolicy, func(int) (*websocket.Conn, error) {
+		conn, _, err := dialer.DialContext(ctx, p.addr, nil)
+		return conn, err
+	})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.lastErr = fmt.Errorf("连接 sherpa 服务 %s 失败: %v", p.addr, err)
+		return p.lastErr
+	}
+	if p.closed {
+		conn.Close()
+		return fmt.Errorf("gosherpa 提供者已关闭")
+	}
+
+	// 并发重连时关闭上一条连接，其读协程因代数不匹配不会再触发重连
+	if p.stopPing != nil {
+		close(p.stopPing)
+	}
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	p.conn = conn
+	p.connGen++
+	p.lastErr = nil
+	p.stopPing = make(chan struct{})
+	go p.readLoop(conn, p.connGen)
+	go p.pingLoop(conn, p.stopPing)
+	p.logger.Info(fmt.Sprintf("gosherpa 已连接 %s", p.addr))
+	return nil
+}
+
+// reconnectLoop 收到断线通知后重新建连，失败时记录错误供池剔除
+func (p *Provider) reconnectLoop() {
+	for range p.reconnect {
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			return
+		}
+		if err := p.connect(context.Background()); err != nil {
+			p.logger.Error(fmt.Sprintf("gosherpa 重连失败，等待资源池剔除: %v", err))
+		}
+	}
+}
+
+// markDisconnected 标记连接失效并触发重连，仅对当前代连接生效
+func (p *Provider) markDisconnected(gen int, err error) {
+	p.mu.Lock()
+	if gen != p.connGen || p.conn == nil {
+		p.mu.Unlock()
+		return
+	}
+	p.conn.Close()
+	p.conn = nil
+	if p.stopPing != nil {
+		close(p.stopPing)
+		p.stopPing = nil
+	}
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	// 持锁发送，避免与 Cleanup 关闭通道并发
+	select {
+	case p.reconnect <- struct{}{}:
+	default:
+	}
+	p.mu.Unlock()
+
+	p.logger.Warn(fmt.Sprintf("gosherpa 连接断开: %v，准备重连", err))
+}
+
+// readLoop 读取识别结果，读失败即视为断线
+func (p *Provider) readLoop(conn *websocket.Conn, gen int) {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			p.markDisconnected(gen, err)
+			return
+		}
+
+		var result sherpaResult
+		if err := json.Unmarshal(message, &result); err != nil {
+			p.logger.Warn(fmt.Sprintf("gosherpa 解析结果失败: %v", err))
+			continue
+		}
+		p.handleResult(result)
+	}
+}
+
+// pingLoop 定期发送心跳，写失败时由读协程感知断线
+func (p *Provider) pingLoop(conn *websocket.Conn, stop chan struct{}) {
+	ticker := time.NewTicker(p.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.writeMu.Lock()
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(p.timeout))
+			p.writeMu.Unlock()
+			if err != nil {
+				// 关闭连接使 ReadMessage 立即返回
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// handleResult 分发中间结果与最终结果
+func (p *Provider) handleResult(result sherpaResult) {
+	listener := p.GetListener()
+	if !result.IsFinal {
+		if result.Text == "" {
+			return
+		}
+		if interimListener, ok := listener.(providers.AsrInterimListener); ok {
+			interimListener.OnAsrInterim(result.Text)
+		}
+		return
+	}
+
+	if listener != nil && result.Text != "" {
+		listener.OnAsrResult(result.Text)
+	}
+}
+
+// AddAudio 发送16k单声道 int16 PCM，断线时先同步重连
+func (p *Provider) AddAudio(data []byte) error {
+	p.mu.Lock()
+	conn := p.conn
+	p.mu.Unlock()
+
+	if conn == nil {
+		if err := p.connect(context.Background()); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		conn = p.conn
+		p.mu.Unlock()
+	}
+
+	p.SetLastChunkTime(time.Now())
+	if len(data) == 0 {
+		return nil
+	}
+
+	p.writeMu.Lock()
+	conn.SetWriteDeadline(time.Now().Add(p.timeout))
+	err := conn.WriteMessage(websocket.BinaryMessage, pcmToFloat32(data))
+	p.writeMu.Unlock()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("发送音频数据失败: %v", err)
+	}
+	return nil
+}
+
+// Transcribe 使用独立连接识别一段完整音频
+func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: p.timeout}
+	conn, err := utils.RetryWithResult(ctx, p.retryPolicy, func(int) (*websocket.Conn, error) {
+		conn, _, err := dialer.DialContext(ctx, p.addr, nil)
+		return conn, err
+	})
+	if err != nil {
+		return "", fmt.Errorf("连接 sherpa 服务失败: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, pcmToFloat32(audioData)); err != nil {
+		return "", fmt.Errorf("发送音频数据失败: %v", err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("Done")); err != nil {
+		return "", fmt.Errorf("发送结束标记失败: %v", err)
+	}
+
+	// 按 segment 收集结果，服务端处理完成后主动关闭连接
+	segments := make(map[int]string)
+	maxSegment := -1
+	for {
+		conn.SetReadDeadline(time.Now().Add(p.timeout))
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		var result sherpaResult
+		if err := json.Unmarshal(message, &result); err != nil {
+			continue
+		}
+		segments[result.Segment] = result.Text
+		if result.Segment > maxSegment {
+			maxSegment = result.Segment
+		}
+	}
+
+	text := ""
+	for i := 0; i <= maxSegment; i++ {
+		text += segments[i]
+	}
+	return text, nil
+}
+
+// Reset 重置音频状态，长连接保持不变
+func (p *Provider) Reset() error {
+	p.InitAudioProcessing()
+	return nil
+}
+
+// IsHealthy 连接可用或仍在重连中视为健康，重连次数耗尽后返回 false 供资源池剔除
+func (p *Provider) IsHealthy() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return !p.closed && p.lastErr == nil
+}
+
+// LastError 最近一次重连失败的原因
+func (p *Provider) LastError() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastErr
+}
+
+// Cleanup 关闭连接并停止后台协程
+func (p *Provider) Cleanup() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.stopPing != nil {
+		close(p.stopPing)
+		p.stopPing = nil
+	}
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	close(p.reconnect)
+	return nil
+}
+
+// pcmToFloat32 将 int16 小端 PCM 转为 sherpa 需要的 float32 小端样本
+func pcmToFloat32(data []byte) []byte {
+	samples := len(data) / 2
+	out := make([]byte, samples*4)
+	for i := 0; i < samples; i++ {
+		sample := int16(binary.LittleEndian.Uint16(data[i*2:]))
+		binary.LittleEndian.PutUint32(out[i*4:], math.Float32bits(float32(sample)/32768.0))
+	}
+	return out
+}
+
+func init() {
+	// 注册 gosherpa ASR 提供者
+	asr.RegisterProvider(providers.ProviderMeta{
+		Name:         "gosherpa",
+		Description:  "sherpa-onnx 本地识别服务",
+		Capabilities: []string{providers.CapabilityStreaming, providers.CapabilityLocal},
+	}, func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
+		return NewProvider(config, deleteFile, logger)
+	})
+}
@@ -0,0 +1,469 @@
+package iflytek
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultWsURL       = "wss://iat-api.xfyun.cn/v2/iat"
+	defaultTimeout     = 10 * time.Second
+	defaultVadEos      = 2000 // 尾部静音判停时长(ms)
+	audioFormat        = "audio/L16;rate=16000"
+	transcribeChunk    = 1280                  // Transcribe 每帧发送40ms音频，与官方建议一致
+	transcribeInterval = 10 * time.Millisecond // 帧间隔，避免瞬时发送过多数据
+	transcribeWindow   = 30 * time.Second      // Transcribe 等待最终结果的上限
+)
+
+// 音频帧状态：0 第一帧，1 中间帧，2 最后一帧
+const (
+	statusFirst    = 0
+	statusContinue = 1
+	statusLast     = 2
+)
+
+// Ensure Provider implements asr.Provider interface
+var _ asr.Provider = (*Provider)(nil)
+
+// Provider 讯飞语音听写（流式版）websocket 接口
+// 鉴权使用 HMAC-SHA256 签名放在 URL 中；开启 dwa=wpgs 动态修正后，服务端会用 rpl 结果替换之前的若干片段，
+// 需按 sn 维护片段并在每次收到结果时重新拼接
+type Provider struct {
+	*asr.BaseProvider
+	appID       string
+	apiKey      string
+	apiSecret   string
+	wsURL       string
+	business    map[string]interface{}
+	timeout     time.Duration
+	retryPolicy utils.RetryPolicy
+	logger      *utils.Logger
+
+	mu          sync.Mutex
+	writeMu     sync.Mutex // gorilla websocket 不支持并发写
+	conn        *websocket.Conn
+	isStreaming bool
+	lastResult  *providers.AsrResult
+}
+
+// iflytekResponse 服务端返回的消息
+type iflytekResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Sid     string `json:"sid"`
+	Data    struct {
+		Status int           `json:"status"`
+		Result iflytekResult `json:"result"`
+	} `json:"data"`
+}
+
+// iflytekResult 单次返回的识别片段
+type iflytekResult struct {
+	Sn  int    `json:"sn"`  // 片段序号
+	Ls  bool   `json:"ls"`  // 是否最后一片
+	Pgs string `json:"pgs"` // apd 追加，rpl 替换 rg 范围内的片段
+	Rg  []int  `json:"rg"`  // 替换范围 [起始sn, 结束sn]
+	Bg  int    `json:"bg"`
+	Ed  int    `json:"ed"`
+	Ws  []struct {
+		Bg int `json:"bg"`
+		Cw []struct {
+			W  string  `json:"w"`
+			Sc float64 `json:"sc"`
+		} `json:"cw"`
+	} `json:"ws"`
+}
+
+// text 拼接片段文字，每个词位取第一候选
+func (r iflytekResult) text() string {
+	var sb strings.Builder
+	for _, ws := range r.Ws {
+		if len(ws.Cw) > 0 {
+			sb.WriteString(ws.Cw[0].W)
+		}
+	}
+	return sb.String()
+}
+
+// resultMerger 按 sn 合并动态修正结果
+type resultMerger struct {
+	segments map[int]string
+}
+
+func newResultMerger() *resultMerger {
+	return &resultMerger{segments: make(map[int]string)}
+}
+
+// add 写入一个片段并返回当前完整文本
+func (m *resultMerger) add(r iflytekResult) string {
+	if r.Pgs == "rpl" && len(r.Rg) == 2 {
+		for sn := r.Rg[0]; sn <= r.Rg[1]; sn++ {
+			delete(m.segments, sn)
+		}
+	}
+	m.segments[r.Sn] = r.text()
+
+	sns := make([]int, 0, len(m.segments))
+	for sn := range m.segments {
+		sns = append(sns, sn)
+	}
+	sort.Ints(sns)
+	var sb strings.Builder
+	for _, sn := range sns {
+		sb.WriteString(m.segments[sn])
+	}
+	return sb.String()
+}
+
+// NewProvider 创建讯飞 ASR 提供者
+func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Provider, error) {
+	appID, _ := config.Data["appid"].(string)
+	apiKey, _ := config.Data["api_key"].(string)
+	apiSecret, _ := config.Data["api_secret"].(string)
+	if appID == "" || apiKey == "" || apiSecret == "" {
+		return nil, fmt.Errorf("讯飞 ASR 缺少 appid/api_key/api_secret 配置")
+	}
+	wsURL, _ := config.Data["ws_url"].(string)
+	if wsURL == "" {
+		wsURL = defaultWsURL
+	}
+
+	business := map[string]interface{}{
+		"language": "zh_cn",
+		"domain":   "iat",
+		"accent":   "mandarin",
+		"vad_eos":  defaultVadEos,
+		"dwa":      "wpgs", // 开启动态修正
+		"ptt":      1,      // 标点
+	}
+	for _, key := range []string{"language", "domain", "accent"} {
+		if v, ok := config.Data[key].(string); ok && v != "" {
+			business[key] = v
+		}
+	}
+	if v, ok := config.Data["vad_eos"].(int); ok && v > 0 {
+		business["vad_eos"] = v
+	}
+	if v, ok := config.Data["punctuation"].(bool); ok && !v {
+		business["ptt"] = 0
+	}
+
+	p := &Provider{
+		BaseProvider: asr.NewBaseProvider(config, deleteFile),
+		appID:        appID,
+		apiKey:       apiKey,
+		apiSecret:    apiSecret,
+		wsURL:        wsURL,
+		business:     business,
+		timeout:      utils.ParseTimeout(config.Data["timeout"], defaultTimeout),
+		retryPolicy:  utils.DefaultRetryPolicy(),
+		logger:       logger,
+	}
+	if retries, ok := config.Data["max_retries"].(int); ok {
+		p.retryPolicy = p.retryPolicy.WithMaxRetries(retries)
+	}
+	p.retryPolicy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		p.logger.Warn(fmt.Sprintf("讯飞 ASR 连接失败(尝试%d/%d): %v, 将在%v后重试",
+			attempt, p.retryPolicy.MaxAttempts, err, delay))
+	}
+
+	p.InitAudioProcessing()
+	return p, nil
+}
+
+// buildURL 构造带鉴权参数的连接地址
+// 签名原文为 host、date 与请求行，使用 APISecret 做 HMAC-SHA256
+func (p *Provider) buildURL() (string, error) {
+	u, err := url.Parse(p.wsURL)
+	if err != nil {
+		return "", fmt.Errorf("讯飞 ASR 地址格式错误: %v", err)
+	}
+	date := time.Now().UTC().Format(time.RFC1123)
+	date = strings.Replace(date, "UTC", "GMT", 1)
+
+	origin := fmt.Sprintf("host: %s\ndate: %s\nGET %s HTTP/1.1", u.Host, date, u.Path)
+	mac := hmac.New(sha256.New, []byte(p.apiSecret))
+	mac.Write([]byte(origin))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	authorization := fmt.Sprintf(`api_key="%s", algorithm="hmac-sha256", headers="host date request-line", signature="%s"`,
+		p.apiKey, signature)
+	query := url.Values{}
+	query.Set("authorization", base64.StdEncoding.EncodeToString([]byte(authorization)))
+	query.Set("date", date)
+	query.Set("host", u.Host)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// dial 建立连接，签名带时间戳，每次重试重新生成
+func (p *Provider) dial(ctx context.Context) (*websocket.Conn, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: p.timeout}
+	conn, err := utils.RetryWithResult(ctx, p.retryPolicy, func(int) (*websocket.Conn, error) {
+		wsURL, err := p.buildURL()
+		if err != nil {
+			return nil, utils.Permanent(err)
+		}
+		conn, resp, err := dialer.DialContext(ctx, wsURL, nil)
+		if err != nil && resp != nil {
+			return nil, fmt.Errorf("%v: %w", err, &utils.HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+		}
+		return conn, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("连接讯飞 ASR 失败: %v", err)
+	}
+	return conn, nil
+}
+
+// frame 构造音频帧，第一帧附带公共参数与业务参数
+func (p *Provider) frame(status int, audio []byte) map[string]interface{} {
+	msg := map[string]interface{}{
+		"data": map[string]interface{}{
+			"status":   status,
+			"format":   audioFormat,
+			"encoding": "raw",
+			"audio":    base64.StdEncoding.EncodeToString(audio),
+		},
+	}
+	if status == statusFirst {
+		msg["common"] = map[string]interface{}{"app_id": p.appID}
+		msg["business"] = p.business
+	}
+	return msg
+}
+
+// writeFrame 发送一帧
+func (p *Provider) writeFrame(conn *websocket.Conn, status int, audio []byte) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(p.timeout))
+	return conn.WriteJSON(p.frame(status, audio))
+}
+
+// AddAudio 发送16k单声道 int16 PCM，首帧时建立识别会话
+func (p *Provider) AddAudio(data []byte) error {
+	p.mu.Lock()
+	status := statusContinue
+	if !p.isStreaming {
+		p.logger.Info("----开始讯飞流式识别----")
+		conn, err := p.dial(context.Background())
+		if err != nil {
+			p.mu.Unlock()
+			return err
+		}
+		p.conn = conn
+		p.isStreaming = true
+		p.lastResult = nil
+		status = statusFirst
+		go p.readLoop(conn)
+	}
+	conn := p.conn
+	p.mu.Unlock()
+
+	p.SetLastChunkTime(time.Now())
+	if len(data) == 0 && status != statusFirst {
+		return nil
+	}
+	if err := p.writeFrame(conn, status, data); err != nil {
+		return fmt.Errorf("发送音频数据失败: %v", err)
+	}
+	return nil
+}
+
+// readLoop 读取识别结果，会话结束（服务端判停或最后一帧）、出错或监听器表示结束时退出
+func (p *Provider) readLoop(conn *websocket.Conn) {
+	defer func() {
+		p.mu.Lock()
+		if p.conn == conn {
+			// 讯飞每个会话只识别一句话，下一帧音频重新建立会话
+			p.isStreaming = false
+			p.conn = nil
+			conn.Close()
+		}
+		p.mu.Unlock()
+	}()
+
+	merger := newResultMerger()
+	for {
+		var resp iflytekResponse
+		if err := conn.ReadJSON(&resp); err != nil {
+			return
+		}
+		if resp.Code != 0 {
+			p.logger.Error(fmt.Sprintf("讯飞 ASR 识别错误(code:%d, sid:%s): %s", resp.Code, resp.Sid, resp.Message))
+			return
+		}
+
+		text := merger.add(resp.Data.Result)
+		if resp.Data.Status != statusLast {
+			if interimListener, ok := p.GetListener().(providers.AsrInterimListener); ok && text != "" {
+				interimListener.OnAsrInterim(text)
+			}
+			continue
+		}
+
+		p.handleFinal(text, resp.Data.Result)
+		return
+	}
+}
+
+// handleFinal 会话结束时交付合并后的最终结果
+func (p *Provider) handleFinal(text string, last iflytekResult) {
+	text = strings.TrimSpace(text)
+	detail := &providers.AsrResult{
+		Text:    text,
+		IsFinal: true,
+		// bg/ed 以10ms为单位
+		Duration: last.Ed * 10,
+	}
+	if text != "" {
+		detail.Utterances = []providers.AsrUtterance{{
+			Text:      text,
+			StartTime: last.Bg * 10,
+			EndTime:   last.Ed * 10,
+			Definite:  true,
+		}}
+	}
+	p.mu.Lock()
+	p.lastResult = detail
+	p.mu.Unlock()
+
+	listener := p.GetListener()
+	if listener == nil || text == "" {
+		return
+	}
+	if detailListener, ok := listener.(providers.AsrDetailListener); ok {
+		detailListener.OnAsrDetail(detail)
+	}
+	listener.OnAsrResult(text)
+}
+
+// LastResult 最近一次最终识别的结构化结果
+func (p *Provider) LastResult() *providers.AsrResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastResult
+}
+
+// Transcribe 使用独立会话识别一段完整音频
+func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	sendErr := make(chan error, 1)
+	go func() {
+		status := statusFirst
+		for start := 0; start < len(audioData); start += transcribeChunk {
+			end := start + transcribeChunk
+			if end > len(audioData) {
+				end = len(audioData)
+			}
+			if err := p.writeFrame(conn, status, audioData[start:end]); err != nil {
+				sendErr <- fmt.Errorf("发送音频数据失败: %v", err)
+				return
+			}
+			status = statusContinue
+			select {
+			case <-ctx.Done():
+				sendErr <- ctx.Err()
+				return
+			case <-time.After(transcribeInterval):
+			}
+		}
+		if status == statusFirst {
+			// 空音频也需先发送携带参数的第一帧
+			if err := p.writeFrame(conn, statusFirst, nil); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- p.writeFrame(conn, statusLast, nil)
+	}()
+
+	deadline := time.Now().Add(transcribeWindow)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	merger := newResultMerger()
+	for {
+		var resp iflytekResponse
+		if err := conn.ReadJSON(&resp); err != nil {
+			select {
+			case e := <-sendErr:
+				if e != nil {
+					return "", e
+				}
+			default:
+			}
+			return "", fmt.Errorf("读取讯飞 ASR 结果失败: %v", err)
+		}
+		if resp.Code != 0 {
+			return "", fmt.Errorf("讯飞 ASR 识别错误(code:%d, sid:%s): %s", resp.Code, resp.Sid, resp.Message)
+		}
+		text := merger.add(resp.Data.Result)
+		if resp.Data.Status == statusLast {
+			return strings.TrimSpace(text), nil
+		}
+	}
+}
+
+// Reset 发送最后一帧并关闭连接，下一帧音频重新建立会话
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	conn := p.conn
+	p.conn = nil
+	p.isStreaming = false
+	p.lastResult = nil
+	p.mu.Unlock()
+
+	if conn != nil {
+		_ = p.writeFrame(conn, statusLast, nil)
+		conn.Close()
+	}
+	p.InitAudioProcessing()
+	return nil
+}
+
+// Cleanup 关闭连接
+func (p *Provider) Cleanup() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	p.isStreaming = false
+	return nil
+}
+
+func init() {
+	// 注册讯飞 ASR 提供者
+	asr.RegisterProvider(providers.ProviderMeta{
+		Name:         "iflytek",
+		Description:  "讯飞语音听写",
+		Capabilities: []string{providers.CapabilityStreaming},
+		Required:     []string{"appid", "api_key", "api_secret"},
+	}, func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
+		return NewProvider(config, deleteFile, logger)
+	})
+}
@@ -0,0 +1,278 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	sampleRate       = 16000
+	bytesPerMs       = sampleRate * 2 / 1000
+	defaultTimeout   = 30 * time.Second
+	defaultMaxSpeech = 30 * time.Second       // 单段语音上限，超过后立即转写
+	defaultMinSpeech = 300 * time.Millisecond // 短于该时长的语音视为噪声丢弃
+	preRoll          = 300 * time.Millisecond // 检测到语音前保留的音频，避免吞掉首字
+)
+
+// Ensure Provider implements asr.Provider interface
+var _ asr.Provider = (*Provider)(nil)
+
+// Provider 通过 OpenAI /audio/transcriptions 接口整段转写的 ASR 提供者
+// 音频先缓存在本地，按能量检测到语音后持续静音 silence_duration_ms 视为一句话结束，
+// 随后把整段音频编码为 wav 上传转写；无中间结果，延迟高于流式识别但准确率更好
+type Provider struct {
+	*asr.BaseProvider
+	client      *openai.Client
+	model       string
+	language    string
+	prompt      string
+	temperature float32
+	format      openai.AudioResponseFormat
+	maxSpeech   time.Duration
+	minSpeech   time.Duration
+	timeout     time.Duration
+	retryPolicy utils.RetryPolicy
+	logger      *utils.Logger
+
+	mu          sync.Mutex
+	hasSpeech   bool
+	speechStart time.Time
+	lastVoice   time.Time
+	gen         int // Reset 时递增，丢弃已失效的转写结果
+	lastResult  *providers.AsrResult
+}
+
+// NewProvider 创建 OpenAI 转写 ASR 提供者
+func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Provider, error) {
+	apiKey, _ := config.Data["api_key"].(string)
+	baseURL, _ := config.Data["base_url"].(string)
+	// 自建的 whisper.cpp、faster-whisper 等兼容服务通常不校验密钥，配置了 base_url 时 api_key 可留空
+	if apiKey == "" && baseURL == "" {
+		return nil, fmt.Errorf("OpenAI ASR 缺少 api_key 配置，使用自建兼容服务时需配置 base_url")
+	}
+	clientConfig := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		clientConfig.BaseURL = baseURL
+	}
+
+	model, _ := config.Data["model"].(string)
+	if model == "" {
+		model = openai.Whisper1
+	}
+	language, _ := config.Data["language"].(string)
+	prompt, _ := config.Data["prompt"].(string)
+	format := openai.AudioResponseFormatVerboseJSON
+	if v, ok := config.Data["response_format"].(string); ok && v != "" {
+		// 部分兼容服务与 gpt-4o-transcribe 不支持 verbose_json，可改为 json
+		format = openai.AudioResponseFormat(v)
+	}
+
+	p := &Provider{
+		BaseProvider: asr.NewBaseProvider(config, deleteFile),
+		client:       openai.NewClientWithConfig(clientConfig),
+		model:        model,
+		language:     language,
+		prompt:       prompt,
+		format:       format,
+		maxSpeech:    utils.ParseTimeout(config.Data["max_duration"], defaultMaxSpeech),
+		minSpeech:    defaultMinSpeech,
+		timeout:      utils.ParseTimeout(config.Data["timeout"], defaultTimeout),
+		retryPolicy:  utils.DefaultRetryPolicy(),
+		logger:       logger,
+	}
+	switch v := config.Data["temperature"].(type) {
+	case float64:
+		p.temperature = float32(v)
+	case int:
+		p.temperature = float32(v)
+	}
+	if v, ok := config.Data["min_speech_ms"].(int); ok && v >= 0 {
+		p.minSpeech = time.Duration(v) * time.Millisecond
+	}
+	if retries, ok := config.Data["max_retries"].(int); ok {
+		p.retryPolicy = p.retryPolicy.WithMaxRetries(retries)
+	}
+	p.retryPolicy.Retryable = llm.IsRetryableError
+	p.retryPolicy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		p.logger.Warn(fmt.Sprintf("OpenAI 转写失败(尝试%d/%d): %v, 将在%v后重试",
+			attempt, p.retryPolicy.MaxAttempts, err, delay))
+	}
+
+	threshold := 0.0
+	switch v := config.Data["silence_threshold"].(type) {
+	case float64:
+		threshold = v
+	case int:
+		threshold = float64(v)
+	}
+	silenceMs, _ := config.Data["silence_duration_ms"].(int)
+	p.SetSilenceDetection(threshold, silenceMs)
+	p.InitAudioProcessing()
+	return p, nil
+}
+
+// AddAudio 缓存16k单声道 int16 PCM，检测到句尾后异步整段转写
+func (p *Provider) AddAudio(data []byte) error {
+	now := time.Now()
+	p.SetLastChunkTime(now)
+	if len(data) == 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	buffer := p.GetAudioBuffer()
+	buffer.Write(data)
+
+	if !p.IsSilence(data) {
+		if !p.hasSpeech {
+			p.hasSpeech = true
+			p.speechStart = now
+		}
+		p.lastVoice = now
+	} else if !p.hasSpeech {
+		// 尚未开口时只保留最近一小段音频
+		if keep := int(preRoll.Milliseconds()) * bytesPerMs; buffer.Len() > keep {
+			tail := append([]byte(nil), buffer.Bytes()[buffer.Len()-keep:]...)
+			buffer.Reset()
+			buffer.Write(tail)
+		}
+	}
+
+	endOfSpeech := p.hasSpeech && now.Sub(p.lastVoice) >= p.SilenceDuration()
+	tooLong := p.hasSpeech && time.Duration(buffer.Len()/bytesPerMs)*time.Millisecond >= p.maxSpeech
+	if !endOfSpeech && !tooLong {
+		p.mu.Unlock()
+		return nil
+	}
+
+	speech := p.lastVoice.Sub(p.speechStart)
+	audio := append([]byte(nil), buffer.Bytes()...)
+	buffer.Reset()
+	p.hasSpeech = false
+	gen := p.gen
+	p.mu.Unlock()
+
+	if speech < p.minSpeech && !tooLong {
+		p.logger.Debug(fmt.Sprintf("语音过短(%v)，忽略", speech))
+		return nil
+	}
+	go p.transcribeAndNotify(audio, gen)
+	return nil
+}
+
+// transcribeAndNotify 转写一段语音并通知监听器，期间发生 Reset 时丢弃结果
+func (p *Provider) transcribeAndNotify(audio []byte, gen int) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout*time.Duration(p.retryPolicy.MaxAttempts))
+	defer cancel()
+
+	start := time.Now()
+	resp, err := p.transcribe(ctx, audio)
+	if err != nil {
+		p.logger.Error(fmt.Sprintf("OpenAI 转写失败: %v", err))
+		return
+	}
+	text := strings.TrimSpace(resp.Text)
+	p.logger.Info(fmt.Sprintf("OpenAI 转写完成，音频%dms，耗时%v", len(audio)/bytesPerMs, time.Since(start)))
+
+	detail := toAsrResult(resp, len(audio)/bytesPerMs)
+	p.mu.Lock()
+	if gen != p.gen {
+		p.mu.Unlock()
+		return
+	}
+	p.lastResult = detail
+	p.mu.Unlock()
+
+	listener := p.GetListener()
+	if listener == nil || text == "" {
+		return
+	}
+	if detailListener, ok := listener.(providers.AsrDetailListener); ok {
+		detailListener.OnAsrDetail(detail)
+	}
+	listener.OnAsrResult(text)
+}
+
+// transcribe 上传 wav 并转写，失败时按策略重试
+func (p *Provider) transcribe(ctx context.Context, audio []byte) (openai.AudioResponse, error) {
+	wav := utils.PCMToWav(audio, sampleRate, 1, 16)
+	return utils.RetryWithResult(ctx, p.retryPolicy, func(int) (openai.AudioResponse, error) {
+		attemptCtx, cancel := context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+		return p.client.CreateTranscription(attemptCtx, openai.AudioRequest{
+			Model:       p.model,
+			FilePath:    "audio.wav",
+			Reader:      bytes.NewReader(wav),
+			Prompt:      p.prompt,
+			Temperature: p.temperature,
+			Language:    p.language,
+			Format:      p.format,
+		})
+	})
+}
+
+// toAsrResult 将 verbose_json 的分段转换为结构化结果
+func toAsrResult(resp openai.AudioResponse, durationMs int) *providers.AsrResult {
+	result := &providers.AsrResult{
+		Text:     strings.TrimSpace(resp.Text),
+		IsFinal:  true,
+		Duration: durationMs,
+	}
+	for _, segment := range resp.Segments {
+		result.Utterances = append(result.Utterances, providers.AsrUtterance{
+			Text:      strings.TrimSpace(segment.Text),
+			StartTime: int(segment.Start * 1000),
+			EndTime:   int(segment.End * 1000),
+			Definite:  true,
+		})
+	}
+	return result
+}
+
+// LastResult 最近一次转写的结构化结果
+func (p *Provider) LastResult() *providers.AsrResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastResult
+}
+
+// Transcribe 直接转写一段完整音频
+func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	resp, err := p.transcribe(ctx, audioData)
+	if err != nil {
+		return "", fmt.Errorf("OpenAI 转写失败: %v", err)
+	}
+	return strings.TrimSpace(resp.Text), nil
+}
+
+// Reset 丢弃缓存的音频与进行中的转写结果
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gen++
+	p.hasSpeech = false
+	p.lastResult = nil
+	p.InitAudioProcessing()
+	return nil
+}
+
+func init() {
+	// 注册 OpenAI 转写 ASR 提供者
+	asr.RegisterProvider(providers.ProviderMeta{
+		Name:        "openai",
+		Description: "OpenAI 兼容的音频转写接口（OpenAI、Groq、whisper.cpp 等）",
+	}, func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
+		return NewProvider(config, deleteFile, logger)
+	})
+}
@@ -0,0 +1,272 @@
+//go:build sherpa_onnx
+
+package sherpaonnx
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/utils"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+const sampleRate = 16000
+
+// Ensure Provider implements asr.Provider interface
+var _ asr.Provider = (*Provider)(nil)
+
+var (
+	recognizersMu sync.Mutex
+	recognizers   = make(map[string]*sherpa.OnlineRecognizer) // 按模型配置共享，避免资源池中每个实例重复加载模型
+)
+
+// Provider 进程内 sherpa-onnx 流式识别，每个实例持有独立的识别流
+type Provider struct {
+	*asr.BaseProvider
+	logger     *utils.Logger
+	recognizer *sherpa.OnlineRecognizer
+
+	mu      sync.Mutex
+	stream  *sherpa.OnlineStream
+	interim string // 当前句最近一次中间结果，用于去重
+}
+
+// NewProvider 创建 sherpa_onnx ASR 提供者，模型在首次创建时加载
+func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Provider, error) {
+	recognizerConfig, err := buildRecognizerConfig(config.Data)
+	if err != nil {
+		return nil, err
+	}
+	recognizer, err := getRecognizer(recognizerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Provider{
+		BaseProvider: asr.NewBaseProvider(config, deleteFile),
+		logger:       logger,
+		recognizer:   recognizer,
+		stream:       sherpa.NewOnlineStream(recognizer),
+	}
+	p.InitAudioProcessing()
+	return p, nil
+}
+
+// buildRecognizerConfig 从配置构造识别器参数
+func buildRecognizerConfig(data map[string]interface{}) (*sherpa.OnlineRecognizerConfig, error) {
+	config := &sherpa.OnlineRecognizerConfig{}
+	config.FeatConfig.SampleRate = sampleRate
+	config.FeatConfig.FeatureDim = getInt(data, "feature_dim", 80)
+
+	modelType := strings.ToLower(getString(data, "model_type", "transducer"))
+	var required []string
+	switch modelType {
+	case "transducer":
+		config.ModelConfig.Transducer.Encoder = getString(data, "encoder", "")
+		config.ModelConfig.Transducer.Decoder = getString(data, "decoder", "")
+		config.ModelConfig.Transducer.Joiner = getString(data, "joiner", "")
+		required = []string{config.ModelConfig.Transducer.Encoder, config.ModelConfig.Transducer.Decoder, config.ModelConfig.Transducer.Joiner}
+	case "paraformer":
+		config.ModelConfig.Paraformer.Encoder = getString(data, "encoder", "")
+		config.ModelConfig.Paraformer.Decoder = getString(data, "decoder", "")
+		required = []string{config.ModelConfig.Paraformer.Encoder, config.ModelConfig.Paraformer.Decoder}
+	case "zipformer2_ctc":
+		config.ModelConfig.Zipformer2Ctc.Model = getString(data, "model", "")
+		required = []string{config.ModelConfig.Zipformer2Ctc.Model}
+	default:
+		return nil, fmt.Errorf("不支持的 sherpa_onnx 模型类型: %s", modelType)
+	}
+
+	config.ModelConfig.Tokens = getString(data, "tokens", "")
+	required = append(required, config.ModelConfig.Tokens)
+	for _, path := range required {
+		if path == "" {
+			return nil, fmt.Errorf("sherpa_onnx 缺少模型文件配置(model_type=%s)", modelType)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("sherpa_onnx 模型文件不可用: %v", err)
+		}
+	}
+
+	config.ModelConfig.NumThreads = getInt(data, "num_threads", 2)
+	config.ModelConfig.Provider = getString(data, "provider", "cpu")
+	config.DecodingMethod = getString(data, "decoding_method", "greedy_search")
+	config.MaxActivePaths = getInt(data, "max_active_paths", 4)
+	config.HotwordsFile = getString(data, "hotwords_file", "")
+	config.HotwordsScore = getFloat(data, "hotwords_score", 1.5)
+
+	// 端点检测：规则1无有效文本时的静音时长，规则2有文本后的静音时长，规则3单句最长时长（秒）
+	if getBool(data, "enable_endpoint", true) {
+		config.EnableEndpoint = 1
+	}
+	config.Rule1MinTrailingSilence = getFloat(data, "rule1_min_trailing_silence", 2.4)
+	config.Rule2MinTrailingSilence = getFloat(data, "rule2_min_trailing_silence", 0.8)
+	config.Rule3MinUtteranceLength = getFloat(data, "rule3_min_utterance_length", 20)
+	return config, nil
+}
+
+// getRecognizer 获取共享识别器，不存在时加载模型
+func getRecognizer(config *sherpa.OnlineRecognizerConfig) (*sherpa.OnlineRecognizer, error) {
+	key := fmt.Sprintf("%+v", *config)
+
+	recognizersMu.Lock()
+	defer recognizersMu.Unlock()
+	if recognizer, ok := recognizers[key]; ok {
+		return recognizer, nil
+	}
+	recognizer := sherpa.NewOnlineRecognizer(config)
+	if recognizer == nil {
+		return nil, fmt.Errorf("加载 sherpa_onnx 模型失败，请检查模型路径与类型")
+	}
+	recognizers[key] = recognizer
+	return recognizer, nil
+}
+
+// AddAudio 送入16k单声道 int16 PCM，端点检测到句尾时回调最终结果
+func (p *Provider) AddAudio(data []byte) error {
+	p.SetLastChunkTime(time.Now())
+	if len(data) == 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	if p.stream == nil {
+		p.mu.Unlock()
+		return fmt.Errorf("sherpa_onnx 识别流已释放")
+	}
+	p.stream.AcceptWaveform(sampleRate, pcmToFloat32(data))
+	for p.recognizer.IsReady(p.stream) {
+		p.recognizer.Decode(p.stream)
+	}
+	text := strings.TrimSpace(p.recognizer.GetResult(p.stream).Text)
+	isEndpoint := p.recognizer.IsEndpoint(p.stream)
+	if isEndpoint {
+		p.recognizer.Reset(p.stream)
+		p.interim = ""
+	}
+	changed := !isEndpoint && text != "" && text != p.interim
+	if changed {
+		p.interim = text
+	}
+	p.mu.Unlock()
+
+	listener := p.GetListener()
+	if isEndpoint {
+		if text != "" && listener != nil {
+			listener.OnAsrResult(text)
+		}
+		return nil
+	}
+	if changed {
+		if interimListener, ok := listener.(providers.AsrInterimListener); ok {
+			interimListener.OnAsrInterim(text)
+		}
+	}
+	return nil
+}
+
+// Transcribe 使用独立识别流识别一段完整音频
+func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	stream := sherpa.NewOnlineStream(p.recognizer)
+	defer sherpa.DeleteOnlineStream(stream)
+
+	stream.AcceptWaveform(sampleRate, pcmToFloat32(audioData))
+	// 补0.3秒静音，保证尾部音频被解码
+	stream.AcceptWaveform(sampleRate, make([]float32, sampleRate*3/10))
+	stream.InputFinished()
+
+	for p.recognizer.IsReady(stream) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+		p.recognizer.Decode(stream)
+	}
+	return strings.TrimSpace(p.recognizer.GetResult(stream).Text), nil
+}
+
+// Reset 丢弃当前句的识别状态
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stream != nil {
+		p.recognizer.Reset(p.stream)
+	}
+	p.interim = ""
+	p.InitAudioProcessing()
+	return nil
+}
+
+// Cleanup 释放识别流，共享的识别器随进程退出释放
+func (p *Provider) Cleanup() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stream != nil {
+		sherpa.DeleteOnlineStream(p.stream)
+		p.stream = nil
+	}
+	return nil
+}
+
+// pcmToFloat32 将 int16 小端 PCM 转为 [-1, 1] 的 float32 样本
+func pcmToFloat32(data []byte) []float32 {
+	samples := make([]float32, len(data)/2)
+	for i := range samples {
+		samples[i] = float32(int16(binary.LittleEndian.Uint16(data[i*2:]))) / 32768.0
+	}
+	return samples
+}
+
+func getString(data map[string]interface{}, key, def string) string {
+	if v, ok := data[key].(string); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+func getInt(data map[string]interface{}, key string, def int) int {
+	switch v := data[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return def
+}
+
+func getFloat(data map[string]interface{}, key string, def float32) float32 {
+	switch v := data[key].(type) {
+	case int:
+		return float32(v)
+	case float64:
+		return float32(v)
+	}
+	return def
+}
+
+func getBool(data map[string]interface{}, key string, def bool) bool {
+	if v, ok := data[key].(bool); ok {
+		return v
+	}
+	return def
+}
+
+func init() {
+	// 注册 sherpa_onnx ASR 提供者
+	asr.RegisterProvider(providers.ProviderMeta{
+		Name:         "sherpa_onnx",
+		Description:  "sherpa-onnx 进程内离线识别",
+		Capabilities: []string{providers.CapabilityStreaming, providers.CapabilityLocal},
+	}, func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
+		return NewProvider(config, deleteFile, logger)
+	})
+}
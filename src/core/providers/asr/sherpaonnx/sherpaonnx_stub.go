@@ -0,0 +1,22 @@
+//go:build !sherpa_onnx
+
+package sherpaonnx
+
+import (
+	"fmt"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+func init() {
+	// 未启用 sherpa_onnx 编译标签时仅注册占位，避免默认构建依赖 cgo 模型库
+	asr.RegisterProvider(providers.ProviderMeta{
+		Name:         "sherpa_onnx",
+		Description:  "sherpa-onnx 进程内离线识别（未编译）",
+		Capabilities: []string{providers.CapabilityStreaming, providers.CapabilityLocal},
+	}, func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
+		return nil, fmt.Errorf("当前程序未包含 sherpa_onnx 支持，请使用 go build -tags sherpa_onnx 重新编译")
+	})
+}
@@ -0,0 +1,465 @@
+package tencent
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	host               = "asr.cloud.tencent.com"
+	defaultEngineModel = "16k_zh"
+	defaultTimeout     = 10 * time.Second
+	signatureExpire    = 24 * time.Hour
+	transcribeChunk    = 6400                  // Transcribe 每帧发送200ms音频
+	transcribeInterval = 40 * time.Millisecond // 帧间隔，服务端限制发送速率不超过实时的数倍
+	transcribeWindow   = 30 * time.Second      // Transcribe 等待最终结果的上限
+)
+
+// 分片类型：0 一句话开始，1 识别中（非稳态），2 一句话结束（稳态）
+const (
+	sliceStart = 0
+	sliceMid   = 1
+	sliceEnd   = 2
+)
+
+// Ensure Provider implements asr.Provider interface
+var _ asr.Provider = (*Provider)(nil)
+
+// Provider 腾讯云实时语音识别（websocket 协议）
+// 鉴权参数与 HMAC-SHA1 签名放在 URL 中，音频以二进制帧发送，发送 {"type":"end"} 表示结束
+type Provider struct {
+	*asr.BaseProvider
+	appID           string
+	secretID        string
+	secretKey       string
+	engineModelType string
+	hotwordID       string
+	needVad         bool
+	filterPunc      int
+	convertNumMode  int
+	vadSilenceTime  int
+	timeout         time.Duration
+	retryPolicy     utils.RetryPolicy
+	logger          *utils.Logger
+
+	mu          sync.Mutex
+	writeMu     sync.Mutex // gorilla websocket 不支持并发写
+	conn        *websocket.Conn
+	isStreaming bool
+	lastResult  *providers.AsrResult
+}
+
+// tencentResponse 服务端返回的消息
+type tencentResponse struct {
+	Code      int           `json:"code"`
+	Message   string        `json:"message"`
+	VoiceID   string        `json:"voice_id"`
+	MessageID string        `json:"message_id"`
+	Result    tencentResult `json:"result"`
+	Final     int           `json:"final"`
+}
+
+// tencentResult 单个分片的识别结果，时间单位毫秒
+type tencentResult struct {
+	SliceType    int           `json:"slice_type"`
+	Index        int           `json:"index"`
+	StartTime    int           `json:"start_time"`
+	EndTime      int           `json:"end_time"`
+	VoiceTextStr string        `json:"voice_text_str"`
+	WordSize     int           `json:"word_size"`
+	WordList     []tencentWord `json:"word_list"`
+}
+
+// tencentWord 词级结果，时间相对于句首
+type tencentWord struct {
+	Word       string `json:"word"`
+	StartTime  int    `json:"start_time"`
+	EndTime    int    `json:"end_time"`
+	StableFlag int    `json:"stable_flag"`
+}
+
+// NewProvider 创建腾讯云 ASR 提供者
+func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Provider, error) {
+	appID := stringValue(config.Data["appid"])
+	secretID, _ := config.Data["secret_id"].(string)
+	secretKey, _ := config.Data["secret_key"].(string)
+	if appID == "" || secretID == "" || secretKey == "" {
+		return nil, fmt.Errorf("腾讯云 ASR 缺少 appid/secret_id/secret_key 配置")
+	}
+
+	engineModelType, _ := config.Data["engine_model_type"].(string)
+	if engineModelType == "" {
+		engineModelType = defaultEngineModel
+	}
+	hotwordID, _ := config.Data["hotword_id"].(string)
+
+	p := &Provider{
+		BaseProvider:    asr.NewBaseProvider(config, deleteFile),
+		appID:           appID,
+		secretID:        secretID,
+		secretKey:       secretKey,
+		engineModelType: engineModelType,
+		hotwordID:       hotwordID,
+		needVad:         true,
+		convertNumMode:  1,
+		timeout:         utils.ParseTimeout(config.Data["timeout"], defaultTimeout),
+		retryPolicy:     utils.DefaultRetryPolicy(),
+		logger:          logger,
+	}
+	if v, ok := config.Data["need_vad"].(bool); ok {
+		p.needVad = v
+	}
+	if v, ok := config.Data["filter_punc"].(int); ok {
+		p.filterPunc = v
+	}
+	if v, ok := config.Data["convert_num_mode"].(int); ok {
+		p.convertNumMode = v
+	}
+	if v, ok := config.Data["vad_silence_time"].(int); ok {
+		p.vadSilenceTime = v
+	}
+	if retries, ok := config.Data["max_retries"].(int); ok {
+		p.retryPolicy = p.retryPolicy.WithMaxRetries(retries)
+	}
+	p.retryPolicy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		p.logger.Warn(fmt.Sprintf("腾讯云 ASR 连接失败(尝试%d/%d): %v, 将在%v后重试",
+			attempt, p.retryPolicy.MaxAttempts, err, delay))
+	}
+
+	p.InitAudioProcessing()
+	return p, nil
+}
+
+// stringValue appid 在 yaml 中可能写成数字
+func stringValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case int:
+		return strconv.Itoa(val)
+	case float64:
+		return strconv.FormatInt(int64(val), 10)
+	}
+	return ""
+}
+
+// buildURL 构造带签名的连接地址
+// 签名原文为 host+path+按字典序排列的查询参数，使用 SecretKey 做 HMAC-SHA1 后 base64 编码
+func (p *Provider) buildURL() string {
+	now := time.Now()
+	params := map[string]string{
+		"secretid":          p.secretID,
+		"timestamp":         strconv.FormatInt(now.Unix(), 10),
+		"expired":           strconv.FormatInt(now.Add(signatureExpire).Unix(), 10),
+		"nonce":             strconv.FormatInt(now.UnixNano()%1e9, 10),
+		"engine_model_type": p.engineModelType,
+		"voice_id":          uuid.New().String(),
+		"voice_format":      "1", // pcm
+		"convert_num_mode":  strconv.Itoa(p.convertNumMode),
+		"filter_punc":       strconv.Itoa(p.filterPunc),
+		"word_info":         "1",
+	}
+	if p.needVad {
+		params["needvad"] = "1"
+		if p.vadSilenceTime > 0 {
+			params["vad_silence_time"] = strconv.Itoa(p.vadSilenceTime)
+		}
+	}
+	if p.hotwordID != "" {
+		params["hotword_id"] = p.hotwordID
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+params[k])
+	}
+	query := strings.Join(pairs, "&")
+	path := "/asr/v2/" + p.appID
+
+	mac := hmac.New(sha1.New, []byte(p.secretKey))
+	mac.Write([]byte(host + path + "?" + query))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	// 签名原文使用未编码的参数，实际请求中的参数值需 URL 编码
+	encoded := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		encoded = append(encoded, k+"="+url.QueryEscape(params[k]))
+	}
+	encoded = append(encoded, "signature="+url.QueryEscape(signature))
+	return "wss://" + host + path + "?" + strings.Join(encoded, "&")
+}
+
+// dial 建立连接并校验握手结果
+func (p *Provider) dial(ctx context.Context) (*websocket.Conn, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: p.timeout}
+	conn, err := utils.RetryWithResult(ctx, p.retryPolicy, func(int) (*websocket.Conn, error) {
+		// 每次重试重新生成签名与 voice_id
+		conn, resp, err := dialer.DialContext(ctx, p.buildURL(), nil)
+		if err != nil && resp != nil {
+			return nil, fmt.Errorf("%v: %w", err, &utils.HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+		}
+		return conn, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("连接腾讯云 ASR 失败: %v", err)
+	}
+
+	// 握手成功后服务端先返回一条 code=0 的确认消息，鉴权失败时返回错误码后断开
+	conn.SetReadDeadline(time.Now().Add(p.timeout))
+	var resp tencentResponse
+	if err := conn.ReadJSON(&resp); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("读取腾讯云 ASR 握手结果失败: %v", err)
+	}
+	conn.SetReadDeadline(time.Time{})
+	if resp.Code != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("腾讯云 ASR 鉴权失败(code:%d): %s", resp.Code, resp.Message)
+	}
+	return conn, nil
+}
+
+// AddAudio 发送16k单声道 int16 PCM，首帧时建立识别会话
+func (p *Provider) AddAudio(data []byte) error {
+	p.mu.Lock()
+	if !p.isStreaming {
+		p.logger.Info("----开始腾讯云流式识别----")
+		conn, err := p.dial(context.Background())
+		if err != nil {
+			p.mu.Unlock()
+			return err
+		}
+		p.conn = conn
+		p.isStreaming = true
+		p.lastResult = nil
+		go p.readLoop(conn)
+	}
+	conn := p.conn
+	p.mu.Unlock()
+
+	p.SetLastChunkTime(time.Now())
+	if len(data) == 0 {
+		return nil
+	}
+
+	p.writeMu.Lock()
+	conn.SetWriteDeadline(time.Now().Add(p.timeout))
+	err := conn.WriteMessage(websocket.BinaryMessage, data)
+	p.writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("发送音频数据失败: %v", err)
+	}
+	return nil
+}
+
+// readLoop 读取识别结果，监听器表示本轮结束、服务端报错或连接关闭时退出
+func (p *Provider) readLoop(conn *websocket.Conn) {
+	defer func() {
+		p.mu.Lock()
+		if p.conn == conn {
+			// 会话已结束，下一帧音频重新建立会话
+			p.isStreaming = false
+			p.conn = nil
+			conn.Close()
+		}
+		p.mu.Unlock()
+	}()
+
+	for {
+		var resp tencentResponse
+		if err := conn.ReadJSON(&resp); err != nil {
+			return
+		}
+		if resp.Code != 0 {
+			p.logger.Error(fmt.Sprintf("腾讯云 ASR 识别错误(code:%d): %s", resp.Code, resp.Message))
+			return
+		}
+		if finished := p.handleResult(resp.Result); finished || resp.Final == 1 {
+			return
+		}
+	}
+}
+
+// handleResult 分发中间结果与句尾结果，返回监听器是否结束本轮识别
+func (p *Provider) handleResult(result tencentResult) bool {
+	listener := p.GetListener()
+	text := strings.TrimSpace(result.VoiceTextStr)
+	if text == "" {
+		return false
+	}
+
+	if result.SliceType != sliceEnd {
+		if interimListener, ok := listener.(providers.AsrInterimListener); ok {
+			interimListener.OnAsrInterim(text)
+		}
+		return false
+	}
+
+	detail := toAsrResult(result)
+	p.mu.Lock()
+	p.lastResult = detail
+	p.mu.Unlock()
+
+	if listener == nil {
+		return false
+	}
+	if detailListener, ok := listener.(providers.AsrDetailListener); ok {
+		detailListener.OnAsrDetail(detail)
+	}
+	return listener.OnAsrResult(text)
+}
+
+// toAsrResult 将句尾分片转换为结构化结果，词时间换算为相对音频开始的绝对时间
+func toAsrResult(result tencentResult) *providers.AsrResult {
+	utterance := providers.AsrUtterance{
+		Text:      result.VoiceTextStr,
+		StartTime: result.StartTime,
+		EndTime:   result.EndTime,
+		Definite:  true,
+	}
+	for _, word := range result.WordList {
+		utterance.Words = append(utterance.Words, providers.AsrWord{
+			Text:      word.Word,
+			StartTime: result.StartTime + word.StartTime,
+			EndTime:   result.StartTime + word.EndTime,
+		})
+	}
+	return &providers.AsrResult{
+		Text:       result.VoiceTextStr,
+		IsFinal:    true,
+		Duration:   result.EndTime,
+		Utterances: []providers.AsrUtterance{utterance},
+	}
+}
+
+// LastResult 最近一次句尾识别的结构化结果
+func (p *Provider) LastResult() *providers.AsrResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastResult
+}
+
+// Transcribe 使用独立会话识别一段完整音频，拼接所有句尾结果
+func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	sendErr := make(chan error, 1)
+	go func() {
+		for start := 0; start < len(audioData); start += transcribeChunk {
+			end := start + transcribeChunk
+			if end > len(audioData) {
+				end = len(audioData)
+			}
+			if err := conn.WriteMessage(websocket.BinaryMessage, audioData[start:end]); err != nil {
+				sendErr <- fmt.Errorf("发送音频数据失败: %v", err)
+				return
+			}
+			select {
+			case <-ctx.Done():
+				sendErr <- ctx.Err()
+				return
+			case <-time.After(transcribeInterval):
+			}
+		}
+		sendErr <- conn.WriteJSON(map[string]string{"type": "end"})
+	}()
+
+	deadline := time.Now().Add(transcribeWindow)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	var text strings.Builder
+	for {
+		var resp tencentResponse
+		if err := conn.ReadJSON(&resp); err != nil {
+			select {
+			case e := <-sendErr:
+				if e != nil {
+					return "", e
+				}
+			default:
+			}
+			return "", fmt.Errorf("读取腾讯云 ASR 结果失败: %v", err)
+		}
+		if resp.Code != 0 {
+			return "", fmt.Errorf("腾讯云 ASR 识别错误(code:%d): %s", resp.Code, resp.Message)
+		}
+		if resp.Result.SliceType == sliceEnd {
+			text.WriteString(resp.Result.VoiceTextStr)
+		}
+		if resp.Final == 1 {
+			return text.String(), nil
+		}
+	}
+}
+
+// Reset 通知服务端结束当前会话并关闭连接，下一帧音频重新建立会话
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	conn := p.conn
+	p.conn = nil
+	p.isStreaming = false
+	p.lastResult = nil
+	p.mu.Unlock()
+
+	if conn != nil {
+		p.writeMu.Lock()
+		conn.SetWriteDeadline(time.Now().Add(p.timeout))
+		_ = conn.WriteJSON(map[string]string{"type": "end"})
+		p.writeMu.Unlock()
+		conn.Close()
+	}
+	p.InitAudioProcessing()
+	return nil
+}
+
+// Cleanup 关闭连接
+func (p *Provider) Cleanup() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	p.isStreaming = false
+	return nil
+}
+
+func init() {
+	// 注册腾讯云 ASR 提供者
+	asr.RegisterProvider(providers.ProviderMeta{
+		Name:         "tencent",
+		Description:  "腾讯云实时语音识别",
+		Capabilities: []string{providers.CapabilityStreaming},
+		Required:     []string{"appid", "secret_id", "secret_key"},
+	}, func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
+		return NewProvider(config, deleteFile, logger)
+	})
+}
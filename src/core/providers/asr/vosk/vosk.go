@@ -0,0 +1,349 @@
+package vosk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/asr"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultAddr      = "ws://127.0.0.1:2700"
+	defaultTimeout   = 10 * time.Second
+	sampleRate       = 16000
+	transcribeWindow = 30 * time.Second // Transcribe 等待最终结果的上限
+)
+
+// Ensure Provider implements asr.Provider interface
+var _ asr.Provider = (*Provider)(nil)
+
+// Provider 对接 vosk-server 的离线 ASR 提供者
+// 协议：先发送 {"config":...}，随后发送 int16 PCM 二进制帧，发送 {"eof":1} 表示结束；
+// 服务端返回 {"partial":...} 中间结果，检测到句尾时返回 {"text":...,"result":[...]}
+type Provider struct {
+	*asr.BaseProvider
+	addr         string
+	removeSpaces bool // 中文模型按词输出并以空格分隔，去掉汉字之间的空格
+	timeout      time.Duration
+	retryPolicy  utils.RetryPolicy
+	logger       *utils.Logger
+
+	mu          sync.Mutex
+	writeMu     sync.Mutex // gorilla websocket 不支持并发写
+	conn        *websocket.Conn
+	isStreaming bool
+	interim     string
+	lastResult  *providers.AsrResult
+}
+
+// voskResult 服务端返回的识别结果，partial 与 text 二者只出现其一
+type voskResult struct {
+	Partial *string    `json:"partial"`
+	Text    *string    `json:"text"`
+	Result  []voskWord `json:"result"`
+}
+
+// voskWord 词级结果，时间单位秒
+type voskWord struct {
+	Conf  float64 `json:"conf"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Word  string  `json:"word"`
+}
+
+// NewProvider 创建 vosk ASR 提供者
+func NewProvider(config *asr.Config, deleteFile bool, logger *utils.Logger) (*Provider, error) {
+	addr, _ := config.Data["addr"].(string)
+	if addr == "" {
+		addr = defaultAddr
+	}
+
+	p := &Provider{
+		BaseProvider: asr.NewBaseProvider(config, deleteFile),
+		addr:         addr,
+		removeSpaces: true,
+		timeout:      utils.ParseTimeout(config.Data["timeout"], defaultTimeout),
+		retryPolicy:  utils.DefaultRetryPolicy(),
+		logger:       logger,
+	}
+	if v, ok := config.Data["remove_spaces"].(bool); ok {
+		p.removeSpaces = v
+	}
+	if retries, ok := config.Data["max_retries"].(int); ok {
+		p.retryPolicy = p.retryPolicy.WithMaxRetries(retries)
+	}
+	// 本地服务重启期间连接会被拒绝，所有建连错误都值得重试
+	p.retryPolicy.Retryable = func(error) bool { return true }
+	p.retryPolicy.OnRetry = func(attempt int, err error, delay time.Duration) {
+		p.logger.Warn(fmt.Sprintf("vosk 连接失败(尝试%d/%d): %v, 将在%v后重试",
+			attempt, p.retryPolicy.MaxAttempts, err, delay))
+	}
+
+	p.InitAudioProcessing()
+	return p, nil
+}
+
+// dial 建立连接并发送识别配置
+func (p *Provider) dial(ctx context.Context) (*websocket.Conn, error) {
+	dialer := websocket.Dialer{HandshakeTimeout: p.timeout}
+	conn, err := utils.RetryWithResult(ctx, p.retryPolicy, func(int) (*websocket.Conn, error) {
+		conn, _, err := dialer.DialContext(ctx, p.addr, nil)
+		return conn, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("连接 vosk 服务 %s 失败: %v", p.addr, err)
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(p.timeout))
+	config := map[string]interface{}{
+		"config": map[string]interface{}{
+			"sample_rate": sampleRate,
+			"words":       1,
+		},
+	}
+	if err := conn.WriteJSON(config); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("发送 vosk 配置失败: %v", err)
+	}
+	return conn, nil
+}
+
+// normalize 去掉汉字之间的空格，保留英文单词间的空格
+func (p *Provider) normalize(text string) string {
+	text = strings.TrimSpace(text)
+	if !p.removeSpaces {
+		return text
+	}
+	runes := []rune(text)
+	var sb strings.Builder
+	for i, r := range runes {
+		if r == ' ' && i > 0 && i+1 < len(runes) &&
+			(unicode.Is(unicode.Han, runes[i-1]) || unicode.Is(unicode.Han, runes[i+1])) {
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// AddAudio 发送16k单声道 int16 PCM，首帧时建立识别会话
+func (p *Provider) AddAudio(data []byte) error {
+	p.mu.Lock()
+	if !p.isStreaming {
+		p.logger.Info("----开始 vosk 流式识别----")
+		conn, err := p.dial(context.Background())
+		if err != nil {
+			p.mu.Unlock()
+			return err
+		}
+		p.conn = conn
+		p.isStreaming = true
+		p.interim = ""
+		p.lastResult = nil
+		go p.readLoop(conn)
+	}
+	conn := p.conn
+	p.mu.Unlock()
+
+	p.SetLastChunkTime(time.Now())
+	if len(data) == 0 {
+		return nil
+	}
+
+	p.writeMu.Lock()
+	conn.SetWriteDeadline(time.Now().Add(p.timeout))
+	err := conn.WriteMessage(websocket.BinaryMessage, data)
+	p.writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("发送音频数据失败: %v", err)
+	}
+	return nil
+}
+
+// readLoop 读取识别结果，连接关闭或监听器表示结束时退出
+func (p *Provider) readLoop(conn *websocket.Conn) {
+	defer func() {
+		p.mu.Lock()
+		if p.conn == conn {
+			p.isStreaming = false
+			p.conn = nil
+			conn.Close()
+		}
+		p.mu.Unlock()
+	}()
+
+	for {
+		var result voskResult
+		if err := conn.ReadJSON(&result); err != nil {
+			return
+		}
+		if finished := p.handleResult(result); finished {
+			return
+		}
+	}
+}
+
+// handleResult 分发中间结果与句尾结果，返回监听器是否结束本轮识别
+func (p *Provider) handleResult(result voskResult) bool {
+	listener := p.GetListener()
+	if result.Partial != nil {
+		text := p.normalize(*result.Partial)
+		p.mu.Lock()
+		changed := text != "" && text != p.interim
+		p.interim = text
+		p.mu.Unlock()
+		if changed {
+			if interimListener, ok := listener.(providers.AsrInterimListener); ok {
+				interimListener.OnAsrInterim(text)
+			}
+		}
+		return false
+	}
+	if result.Text == nil {
+		return false
+	}
+
+	detail := p.toAsrResult(result)
+	p.mu.Lock()
+	p.interim = ""
+	p.lastResult = detail
+	p.mu.Unlock()
+
+	if listener == nil || detail.Text == "" {
+		return false
+	}
+	if detailListener, ok := listener.(providers.AsrDetailListener); ok {
+		detailListener.OnAsrDetail(detail)
+	}
+	return listener.OnAsrResult(detail.Text)
+}
+
+// toAsrResult 将句尾结果转换为结构化结果，置信度取词平均值
+func (p *Provider) toAsrResult(result voskResult) *providers.AsrResult {
+	text := p.normalize(*result.Text)
+	utterance := providers.AsrUtterance{Text: text, Definite: true}
+	var confidence float64
+	for _, w := range result.Result {
+		utterance.Words = append(utterance.Words, providers.AsrWord{
+			Text:       w.Word,
+			StartTime:  int(w.Start * 1000),
+			EndTime:    int(w.End * 1000),
+			Confidence: w.Conf,
+		})
+		confidence += w.Conf
+	}
+	if n := len(utterance.Words); n > 0 {
+		utterance.StartTime = utterance.Words[0].StartTime
+		utterance.EndTime = utterance.Words[n-1].EndTime
+		utterance.Confidence = confidence / float64(n)
+	}
+	detail := &providers.AsrResult{
+		Text:       text,
+		IsFinal:    true,
+		Confidence: utterance.Confidence,
+		Duration:   utterance.EndTime,
+	}
+	if text != "" {
+		detail.Utterances = []providers.AsrUtterance{utterance}
+	}
+	return detail
+}
+
+// LastResult 最近一次句尾识别的结构化结果
+func (p *Provider) LastResult() *providers.AsrResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastResult
+}
+
+// Transcribe 使用独立会话识别一段完整音频，拼接所有句子
+func (p *Provider) Transcribe(ctx context.Context, audioData []byte) (string, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, audioData); err != nil {
+		return "", fmt.Errorf("发送音频数据失败: %v", err)
+	}
+	if err := conn.WriteJSON(map[string]int{"eof": 1}); err != nil {
+		return "", fmt.Errorf("发送结束标记失败: %v", err)
+	}
+
+	deadline := time.Now().Add(transcribeWindow)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	// vosk-server 在 eof 后返回最后一句并关闭连接
+	var texts []string
+	for {
+		var result voskResult
+		if err := conn.ReadJSON(&result); err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) || len(texts) > 0 {
+				break
+			}
+			return "", fmt.Errorf("读取 vosk 结果失败: %v", err)
+		}
+		if result.Text != nil {
+			if text := p.normalize(*result.Text); text != "" {
+				texts = append(texts, text)
+			}
+		}
+	}
+	return p.normalize(strings.Join(texts, " ")), nil
+}
+
+// Reset 通知服务端结束并关闭连接，下一帧音频重新建立会话
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	conn := p.conn
+	p.conn = nil
+	p.isStreaming = false
+	p.interim = ""
+	p.lastResult = nil
+	p.mu.Unlock()
+
+	if conn != nil {
+		p.writeMu.Lock()
+		conn.SetWriteDeadline(time.Now().Add(p.timeout))
+		_ = conn.WriteJSON(map[string]int{"eof": 1})
+		p.writeMu.Unlock()
+		conn.Close()
+	}
+	p.InitAudioProcessing()
+	return nil
+}
+
+// Cleanup 关闭连接
+func (p *Provider) Cleanup() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	p.isStreaming = false
+	return nil
+}
+
+func init() {
+	// 注册 vosk ASR 提供者
+	asr.RegisterProvider(providers.ProviderMeta{
+		Name:         "vosk",
+		Description:  "Vosk 本地识别服务",
+		Capabilities: []string{providers.CapabilityStreaming, providers.CapabilityLocal},
+	}, func(config *asr.Config, deleteFile bool, logger *utils.Logger) (asr.Provider, error) {
+		return NewProvider(config, deleteFile, logger)
+	})
+}
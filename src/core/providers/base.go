@@ -15,6 +15,50 @@ type AsrEventListener interface {
 	OnAsrResult(result string) bool
 }
 
+// AsrInterimListener 可选接口，流式识别时接收尚未定稿的中间结果
+type AsrInterimListener interface {
+	OnAsrInterim(text string)
+}
+
+// AsrWord 词级识别结果，时间单位为毫秒
+type AsrWord struct {
+	Text       string  `json:"text"`
+	StartTime  int     `json:"start_time"`
+	EndTime    int     `json:"end_time"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// AsrUtterance 分句识别结果，时间单位为毫秒，Confidence 为 0 表示服务未返回
+type AsrUtterance struct {
+	Text       string    `json:"text"`
+	StartTime  int       `json:"start_time"`
+	EndTime    int       `json:"end_time"`
+	Definite   bool      `json:"definite"`
+	Confidence float64   `json:"confidence,omitempty"`
+	Speaker    string    `json:"speaker,omitempty"` // 说话人标识，ASR 开启说话人区分时返回
+	Words      []AsrWord `json:"words,omitempty"`
+}
+
+// AsrResult 结构化识别结果，供字幕、打断判断与质检使用
+type AsrResult struct {
+	Text       string         `json:"text"`
+	IsFinal    bool           `json:"is_final"`
+	Confidence float64        `json:"confidence,omitempty"` // 整体置信度，未返回时取各分句平均值
+	Duration   int            `json:"duration,omitempty"`   // 已识别音频时长(ms)
+	Language   string         `json:"language,omitempty"`   // 识别出的语种，如 en-US，服务端未检测语种时为空
+	Utterances []AsrUtterance `json:"utterances,omitempty"`
+}
+
+// AsrDetailListener 可选接口，接收包含分句、起止时间与置信度的结构化结果
+type AsrDetailListener interface {
+	OnAsrDetail(result *AsrResult)
+}
+
+// Prewarmer 可选接口，连接建立时预先建立到服务端的连接，省去首轮请求的握手耗时
+type Prewarmer interface {
+	Prewarm(ctx context.Context) error
+}
+
 // ASRProvider 语音识别提供者接口
 type ASRProvider interface {
 	Provider
@@ -0,0 +1,378 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultBaseURL   = "https://api.anthropic.com"
+	defaultModel     = "claude-sonnet-4-5"
+	defaultMaxTokens = 500
+	apiVersion       = "2023-06-01"
+)
+
+// Provider Anthropic Claude LLM提供者
+// 调用 Messages 流式接口，system 消息合并为顶层 system 字段；
+// 工具声明转换为 input_schema，工具调用与结果分别对应 tool_use / tool_result 内容块
+type Provider struct {
+	*llm.BaseProvider
+	apiKey    string
+	baseURL   string
+	maxTokens int
+	client    *http.Client
+}
+
+// message Messages 接口的消息，content 为内容块列表
+type message struct {
+	Role    string                   `json:"role"`
+	Content []map[string]interface{} `json:"content"`
+}
+
+// streamEvent 流式事件，不同事件类型使用其中不同的字段
+type streamEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		Usage usage `json:"usage"`
+	} `json:"message"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *usage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// usage 用量，input_tokens 不含命中与写入缓存的部分
+type usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+}
+
+// 注册提供者
+func init() {
+	llm.RegisterProvider(providers.ProviderMeta{
+		Name:         "anthropic",
+		Description:  "Anthropic Claude",
+		Capabilities: []string{providers.CapabilityStreaming, providers.CapabilityFunctionCall},
+	}, NewProvider)
+}
+
+// NewProvider 创建Anthropic提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	provider := &Provider{
+		BaseProvider: llm.NewBaseProvider(config),
+		maxTokens:    config.MaxTokens,
+	}
+	if provider.maxTokens <= 0 {
+		provider.maxTokens = defaultMaxTokens
+	}
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	config := p.Config()
+	p.apiKey = config.APIKey
+	if p.apiKey == "" {
+		p.apiKey = os.Getenv("ANTHROPIC_API_KEY")
+	}
+	if p.apiKey == "" {
+		return fmt.Errorf("缺少Anthropic api_key 配置")
+	}
+	p.baseURL = defaultBaseURL
+	if config.BaseURL != "" {
+		p.baseURL = config.BaseURL
+	}
+	p.baseURL = strings.TrimRight(p.baseURL, "/")
+	// 流式响应的总时长由 context 控制，client 不设置超时
+	p.client = &http.Client{}
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		err := p.chat(ctx, messages, nil, func(chunk types.Response) {
+			if chunk.Content != "" {
+				responseChan <- chunk.Content
+			}
+		})
+		if err != nil {
+			responseChan <- fmt.Sprintf("【Anthropic服务响应异常: %v】", err)
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		err := p.chat(ctx, messages, tools, func(chunk types.Response) {
+			responseChan <- chunk
+		})
+		if err != nil {
+			responseChan <- types.Response{
+				Content: fmt.Sprintf("【Anthropic服务响应异常: %v】", err),
+				Error:   err.Error(),
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// convertMessages 转换为 Messages 接口的 system 与消息列表
+// 相邻的同角色消息合并为一条，连续的多个工具结果放在同一条 user 消息中
+func convertMessages(messages []types.Message) ([]string, []message) {
+	var system []string
+	var result []message
+	appendBlocks := func(role string, blocks ...map[string]interface{}) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			result[n-1].Content = append(result[n-1].Content, blocks...)
+			return
+		}
+		result = append(result, message{Role: role, Content: blocks})
+	}
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				system = append(system, msg.Content)
+			}
+		case "tool":
+			appendBlocks("user", map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": msg.ToolCallID,
+				"content":     msg.Content,
+			})
+		case "assistant":
+			var blocks []map[string]interface{}
+			if msg.Content != "" {
+				blocks = append(blocks, map[string]interface{}{"type": "text", "text": msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				// input 必须是 JSON 对象，参数为空或无法解析时传空对象
+				input := map[string]interface{}{}
+				if tc.Function.Arguments != "" {
+					_ = json.Unmarshal([]byte(tc.Function.Arguments), &input)
+				}
+				blocks = append(blocks, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    tc.ID,
+					"name":  tc.Function.Name,
+					"input": input,
+				})
+			}
+			appendBlocks("assistant", blocks...)
+		default:
+			if msg.Content != "" {
+				appendBlocks("user", map[string]interface{}{"type": "text", "text": msg.Content})
+			}
+		}
+	}
+	// 第一条消息必须是 user，以助手开场白开头的对话补一条占位消息
+	if len(result) > 0 && result[0].Role != "user" {
+		result = append([]message{{Role: "user", Content: []map[string]interface{}{{"type": "text", "text": "你好"}}}}, result...)
+	}
+	return system, result
+}
+
+// convertTools 转换为 Messages 接口的工具声明
+func convertTools(tools []openai.Tool) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(tools))
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		var schema interface{} = tool.Function.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		result = append(result, map[string]interface{}{
+			"name":         tool.Function.Name,
+			"description":  tool.Function.Description,
+			"input_schema": schema,
+		})
+	}
+	return result
+}
+
+// buildRequest 构造请求体
+func (p *Provider) buildRequest(messages []types.Message, tools []openai.Tool) map[string]interface{} {
+	config := p.Config()
+	system, chatMessages := convertMessages(messages)
+
+	model := config.ModelName
+	if model == "" {
+		model = defaultModel
+	}
+	request := map[string]interface{}{
+		"model":      model,
+		"messages":   chatMessages,
+		"max_tokens": p.maxTokens,
+		"stream":     true,
+	}
+	if len(system) > 0 {
+		block := map[string]interface{}{"type": "text", "text": strings.Join(system, "\n\n")}
+		// 工具声明在 system 之前，标记 system 即可把两者一起缓存
+		if config.GetBool("prompt_cache", false) {
+			block["cache_control"] = map[string]string{"type": "ephemeral"}
+		}
+		request["system"] = []map[string]interface{}{block}
+	}
+	if config.Temperature > 0 {
+		request["temperature"] = config.Temperature
+	}
+	if config.TopP > 0 {
+		request["top_p"] = config.TopP
+	}
+	if len(tools) > 0 {
+		request["tools"] = convertTools(tools)
+	}
+	return request
+}
+
+// chat 发起流式对话，逐片段回调增量文本与工具调用
+func (p *Provider) chat(ctx context.Context, messages []types.Message, tools []openai.Tool, output func(chunk types.Response)) error {
+	// 整个请求（含流式读取）受 timeout 约束
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+	defer cancel()
+
+	payload, err := json.Marshal(p.buildRequest(messages, tools))
+	if err != nil {
+		return fmt.Errorf("序列化请求参数失败: %v", err)
+	}
+
+	// 仅对建立流式请求重试，已开始输出后不再重试
+	resp, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(payload))
+		if err != nil {
+			return nil, utils.Permanent(fmt.Errorf("创建请求失败: %v", err))
+		}
+		req.Header.Set("x-api-key", p.apiKey)
+		req.Header.Set("anthropic-version", apiVersion)
+		req.Header.Set("Content-Type", "application/json")
+		return utils.DoHTTP(p.client, req)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 内容块序号 -> 工具调用序号，没有参数分片的工具调用在块结束时补空对象
+	toolIndex := map[int]int{}
+	toolArgs := map[int]bool{}
+	var total usage
+
+	var streamErr error
+	readErr := llm.ReadSSE(resp.Body, func(event llm.SSEEvent) bool {
+		var data streamEvent
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+			return true
+		}
+		switch data.Type {
+		case "message_start":
+			total = data.Message.Usage
+		case "content_block_start":
+			if data.ContentBlock.Type == "tool_use" {
+				index := len(toolIndex)
+				toolIndex[data.Index] = index
+				output(types.Response{ToolCalls: []types.ToolCall{{
+					ID:       data.ContentBlock.ID,
+					Type:     "function",
+					Index:    index,
+					Function: types.FunctionCall{Name: data.ContentBlock.Name},
+				}}})
+			}
+		case "content_block_delta":
+			switch data.Delta.Type {
+			case "text_delta":
+				if data.Delta.Text != "" {
+					output(types.Response{Content: data.Delta.Text})
+				}
+			case "input_json_delta":
+				if index, ok := toolIndex[data.Index]; ok && data.Delta.PartialJSON != "" {
+					toolArgs[data.Index] = true
+					output(types.Response{ToolCalls: []types.ToolCall{{
+						Index:    index,
+						Function: types.FunctionCall{Arguments: data.Delta.PartialJSON},
+					}}})
+				}
+			}
+		case "content_block_stop":
+			if index, ok := toolIndex[data.Index]; ok && !toolArgs[data.Index] {
+				output(types.Response{ToolCalls: []types.ToolCall{{
+					Index:    index,
+					Function: types.FunctionCall{Arguments: "{}"},
+				}}})
+			}
+		case "message_delta":
+			if data.Usage != nil {
+				total.OutputTokens = data.Usage.OutputTokens
+			}
+		case "message_stop":
+			output(types.Response{Usage: &types.Usage{
+				PromptTokens:        total.InputTokens + total.CacheReadInputTokens + total.CacheCreationInputTokens,
+				CompletionTokens:    total.OutputTokens,
+				CachedTokens:        total.CacheReadInputTokens,
+				CacheCreationTokens: total.CacheCreationInputTokens,
+			}})
+			return false
+		case "error":
+			streamErr = fmt.Errorf("流式响应错误(%s): %s", data.Error.Type, data.Error.Message)
+			return false
+		}
+		return true
+	})
+	if streamErr != nil {
+		return streamErr
+	}
+	if readErr != nil {
+		return fmt.Errorf("读取流式响应失败: %v", readErr)
+	}
+	return nil
+}
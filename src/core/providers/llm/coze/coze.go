@@ -0,0 +1,326 @@
+package coze
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultBaseURL = "https://api.coze.cn"
+
+	// plugin_output 插件结果的输出方式
+	pluginOutputNone     = "none"     // 只输出 bot 的回答
+	pluginOutputFallback = "fallback" // bot 没有回答时输出插件结果，适合直接返回插件结果的工作流
+	pluginOutputAlways   = "always"   // 插件结果与回答都输出
+)
+
+// Provider Coze Bot LLM提供者
+// 对话转发给 Coze bot（v3 chat 接口，SSE 流式），历史由 Coze 会话保存，
+// 同一 sessionID 复用 conversation_id，之后每轮只提交最新的用户消息；
+// 插件与工作流在 Coze 侧执行，本服务注册的函数不会传给 bot
+type Provider struct {
+	*llm.BaseProvider
+	apiKey       string
+	baseURL      string
+	botID        string
+	pluginOutput string
+	client       *http.Client
+
+	mu            sync.Mutex
+	conversations map[string]string // sessionID -> Coze conversation_id
+}
+
+// chatMessage Coze 消息
+type chatMessage struct {
+	Role        string `json:"role"`
+	Type        string `json:"type,omitempty"`
+	Content     string `json:"content"`
+	ContentType string `json:"content_type"`
+}
+
+// 注册提供者
+func init() {
+	llm.RegisterProvider(providers.ProviderMeta{
+		Name:         "coze",
+		Description:  "Coze 智能体",
+		Capabilities: []string{providers.CapabilityStreaming},
+		Required:     []string{"bot_id"},
+	}, NewProvider)
+}
+
+// NewProvider 创建Coze提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	provider := &Provider{
+		BaseProvider:  llm.NewBaseProvider(config),
+		conversations: make(map[string]string),
+	}
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	config := p.Config()
+	p.apiKey = config.APIKey
+	if p.apiKey == "" {
+		p.apiKey = os.Getenv("COZE_API_TOKEN")
+	}
+	if p.apiKey == "" {
+		return fmt.Errorf("缺少Coze访问令牌 api_key 配置")
+	}
+	p.botID = config.GetString("bot_id", "")
+	if p.botID == "" {
+		return fmt.Errorf("缺少Coze bot_id 配置")
+	}
+	p.pluginOutput = config.GetString("plugin_output", pluginOutputFallback)
+	switch p.pluginOutput {
+	case pluginOutputNone, pluginOutputFallback, pluginOutputAlways:
+	default:
+		return fmt.Errorf("不支持的 plugin_output: %s", p.pluginOutput)
+	}
+
+	p.baseURL = defaultBaseURL
+	if config.BaseURL != "" {
+		p.baseURL = config.BaseURL
+	}
+	p.baseURL = strings.TrimRight(p.baseURL, "/")
+	// 流式响应的总时长由 context 控制，client 不设置超时
+	p.client = &http.Client{}
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Reset 清除会话映射，资源归还池时调用
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	p.conversations = make(map[string]string)
+	p.mu.Unlock()
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		err := p.chat(ctx, sessionID, messages, func(content string) {
+			responseChan <- content
+		})
+		if err != nil {
+			responseChan <- fmt.Sprintf("【Coze服务响应异常: %v】", err)
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现，工具由 Coze bot 自身的插件提供，tools 参数不生效
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		err := p.chat(ctx, sessionID, messages, func(content string) {
+			responseChan <- types.Response{Content: content}
+		})
+		if err != nil {
+			responseChan <- types.Response{
+				Content: fmt.Sprintf("【Coze服务响应异常: %v】", err),
+				Error:   err.Error(),
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// conversation 获取 sessionID 对应的 Coze 会话
+func (p *Provider) conversation(sessionID string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.conversations[sessionID]
+}
+
+// setConversation 记录 Coze 为 sessionID 创建的会话
+func (p *Provider) setConversation(sessionID, conversationID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conversations[sessionID] = conversationID
+}
+
+// buildMessages 构造本轮提交的消息
+// 已有会话时 Coze 保存了历史，只提交最新的用户消息；否则带上本地已有的对话历史
+// 系统提示词由 bot 的人设决定，函数调用相关消息 Coze 无法识别，均不提交
+func buildMessages(messages []types.Message, hasConversation bool) []chatMessage {
+	if hasConversation {
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].Role == "user" {
+				return []chatMessage{{Role: "user", Type: "question", Content: messages[i].Content, ContentType: "text"}}
+			}
+		}
+		return nil
+	}
+
+	result := make([]chatMessage, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Content == "" || len(msg.ToolCalls) > 0 {
+			continue
+		}
+		switch msg.Role {
+		case "user":
+			result = append(result, chatMessage{Role: "user", Type: "question", Content: msg.Content, ContentType: "text"})
+		case "assistant":
+			result = append(result, chatMessage{Role: "assistant", Type: "answer", Content: msg.Content, ContentType: "text"})
+		}
+	}
+	return result
+}
+
+// chat 发起流式对话，bot 的回答与插件结果通过 output 依次输出
+func (p *Provider) chat(ctx context.Context, sessionID string, messages []types.Message, output func(content string)) error {
+	// 整个请求（含流式读取）受 timeout 约束
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+	defer cancel()
+
+	config := p.Config()
+	conversationID := p.conversation(sessionID)
+	userID := config.GetString("user_id", sessionID)
+	if userID == "" {
+		userID = "xiaozhi"
+	}
+	body := map[string]interface{}{
+		"bot_id":              p.botID,
+		"user_id":             userID,
+		"stream":              true,
+		"auto_save_history":   true,
+		"additional_messages": buildMessages(messages, conversationID != ""),
+	}
+	if vars, ok := config.Extra["custom_variables"].(map[string]interface{}); ok {
+		body["custom_variables"] = vars
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("序列化请求参数失败: %v", err)
+	}
+
+	endpoint := p.baseURL + "/v3/chat"
+	if conversationID != "" {
+		endpoint += "?conversation_id=" + url.QueryEscape(conversationID)
+	}
+
+	// 仅对建立流式请求重试，已开始输出后不再重试
+	resp, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return nil, utils.Permanent(fmt.Errorf("创建请求失败: %v", err))
+		}
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "text/event-stream")
+		return p.do(req)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	answered := false
+	var pluginResults []string
+	var streamErr error
+	readErr := llm.ReadSSE(resp.Body, func(event llm.SSEEvent) bool {
+		switch event.Event {
+		case "conversation.chat.created":
+			var chat struct {
+				ConversationID string `json:"conversation_id"`
+			}
+			if json.Unmarshal([]byte(event.Data), &chat) == nil && chat.ConversationID != "" && chat.ConversationID != conversationID {
+				p.setConversation(sessionID, chat.ConversationID)
+			}
+		case "conversation.message.delta":
+			var msg chatMessage
+			if json.Unmarshal([]byte(event.Data), &msg) == nil && msg.Type == "answer" && msg.ContentType == "text" && msg.Content != "" {
+				answered = true
+				output(msg.Content)
+			}
+		case "conversation.message.completed":
+			// 插件的返回结果，bot 会基于它继续生成回答
+			var msg chatMessage
+			if json.Unmarshal([]byte(event.Data), &msg) == nil && msg.Type == "tool_response" && msg.Content != "" {
+				if p.pluginOutput == pluginOutputAlways {
+					output(msg.Content)
+				} else {
+					pluginResults = append(pluginResults, msg.Content)
+				}
+			}
+		case "conversation.chat.requires_action":
+			streamErr = fmt.Errorf("bot 使用了端插件，需要提交插件执行结果，暂不支持")
+			return false
+		case "conversation.chat.failed":
+			var chat struct {
+				LastError struct {
+					Code int    `json:"code"`
+					Msg  string `json:"msg"`
+				} `json:"last_error"`
+			}
+			json.Unmarshal([]byte(event.Data), &chat)
+			streamErr = fmt.Errorf("对话失败(%d): %s", chat.LastError.Code, chat.LastError.Msg)
+			return false
+		case "error":
+			streamErr = fmt.Errorf("流式响应错误: %s", event.Data)
+			return false
+		case "done":
+			return false
+		}
+		return true
+	})
+	if streamErr != nil {
+		return streamErr
+	}
+	if readErr != nil {
+		return fmt.Errorf("读取流式响应失败: %v", readErr)
+	}
+
+	if !answered && p.pluginOutput == pluginOutputFallback && len(pluginResults) > 0 {
+		output(strings.Join(pluginResults, "\n"))
+	}
+	return nil
+}
+
+// do 发送请求，非 2xx 响应返回 HTTPStatusError 以便按状态码决定是否重试
+// Coze 鉴权失败等错误以 200 + JSON 返回，同样视为失败且不重试
+func (p *Provider) do(req *http.Request) (*http.Response, error) {
+	resp, err := utils.DoHTTP(p.client, req)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		defer resp.Body.Close()
+		var result struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		json.Unmarshal([]byte(utils.ReadErrorBody(resp.Body)), &result)
+		return nil, utils.Permanent(fmt.Errorf("Coze 请求失败(%d): %s", result.Code, result.Msg))
+	}
+	return resp, nil
+}
@@ -0,0 +1,315 @@
+package dashscope
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultBaseURL = "https://dashscope.aliyuncs.com/api/v1"
+	defaultModel   = "qwen-plus"
+)
+
+// Provider 通义千问 DashScope 原生接口 LLM提供者
+// 使用 text-generation 原生协议，支持 enable_search 联网搜索；
+// 默认开启 incremental_output 增量输出，关闭时每个片段为截至当前的完整内容，由本地计算增量
+type Provider struct {
+	*llm.BaseProvider
+	apiKey      string
+	baseURL     string
+	incremental bool
+	client      *http.Client
+}
+
+// chatMessage 请求消息
+type chatMessage struct {
+	Role       string           `json:"role"`
+	Content    interface{}      `json:"content"`
+	ToolCalls  []types.ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// streamChunk 流式响应片段
+type streamChunk struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Output  struct {
+		Choices []struct {
+			Message struct {
+				Content   string           `json:"content"`
+				ToolCalls []types.ToolCall `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	} `json:"output"`
+	Usage *struct {
+		InputTokens         int `json:"input_tokens"`
+		OutputTokens        int `json:"output_tokens"`
+		PromptTokensDetails struct {
+			CachedTokens             int `json:"cached_tokens"`
+			CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+		} `json:"prompt_tokens_details"`
+	} `json:"usage"`
+}
+
+// cacheMarkedContent 显式缓存标记，system 消息及之前的工具声明作为缓存前缀
+func cacheMarkedContent(text string) []map[string]interface{} {
+	return []map[string]interface{}{{
+		"type":          "text",
+		"text":          text,
+		"cache_control": map[string]string{"type": "ephemeral"},
+	}}
+}
+
+// 注册提供者
+func init() {
+	llm.RegisterProvider(providers.ProviderMeta{
+		Name:         "dashscope",
+		Description:  "阿里云百炼 DashScope",
+		Capabilities: []string{providers.CapabilityStreaming, providers.CapabilityFunctionCall},
+	}, NewProvider)
+}
+
+// NewProvider 创建DashScope提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	provider := &Provider{
+		BaseProvider: llm.NewBaseProvider(config),
+		incremental:  config.GetBool("incremental_output", true),
+	}
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	config := p.Config()
+	p.apiKey = config.APIKey
+	if p.apiKey == "" {
+		p.apiKey = os.Getenv("DASHSCOPE_API_KEY")
+	}
+	if p.apiKey == "" {
+		return fmt.Errorf("缺少DashScope api_key 配置")
+	}
+	p.baseURL = defaultBaseURL
+	if config.BaseURL != "" {
+		p.baseURL = config.BaseURL
+	}
+	p.baseURL = strings.TrimRight(p.baseURL, "/")
+	// 流式响应的总时长由 context 控制，client 不设置超时
+	p.client = &http.Client{}
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		err := p.chat(ctx, messages, nil, func(chunk types.Response) {
+			if chunk.Content != "" {
+				responseChan <- chunk.Content
+			}
+		})
+		if err != nil {
+			responseChan <- fmt.Sprintf("【DashScope服务响应异常: %v】", err)
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		err := p.chat(ctx, messages, tools, func(chunk types.Response) {
+			responseChan <- chunk
+		})
+		if err != nil {
+			responseChan <- types.Response{
+				Content: fmt.Sprintf("【DashScope服务响应异常: %v】", err),
+				Error:   err.Error(),
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// buildRequest 构造请求体
+func (p *Provider) buildRequest(messages []types.Message, tools []openai.Tool) map[string]interface{} {
+	config := p.Config()
+	promptCache := config.GetBool("prompt_cache", false)
+	chatMessages := make([]chatMessage, 0, len(messages))
+	for _, msg := range messages {
+		var content interface{} = msg.Content
+		if promptCache && msg.Role == "system" && msg.Content != "" {
+			content = cacheMarkedContent(msg.Content)
+		}
+		chatMessages = append(chatMessages, chatMessage{
+			Role:       msg.Role,
+			Content:    content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
+		})
+	}
+
+	parameters := map[string]interface{}{
+		"result_format":      "message",
+		"incremental_output": p.incremental,
+	}
+	if config.Temperature > 0 {
+		parameters["temperature"] = config.Temperature
+	}
+	if config.TopP > 0 {
+		parameters["top_p"] = config.TopP
+	}
+	if config.MaxTokens > 0 {
+		parameters["max_tokens"] = config.MaxTokens
+	}
+	if len(tools) > 0 {
+		parameters["tools"] = tools
+	}
+	if config.GetBool("enable_search", false) {
+		parameters["enable_search"] = true
+		if options, ok := config.Extra["search_options"].(map[string]interface{}); ok {
+			parameters["search_options"] = options
+		}
+	}
+	// qwen3 等混合推理模型可关闭思考以降低首字延迟
+	if enable, ok := config.Extra["enable_thinking"].(bool); ok {
+		parameters["enable_thinking"] = enable
+	}
+
+	model := config.ModelName
+	if model == "" {
+		model = defaultModel
+	}
+	return map[string]interface{}{
+		"model":      model,
+		"input":      map[string]interface{}{"messages": chatMessages},
+		"parameters": parameters,
+	}
+}
+
+// chat 发起流式对话，逐片段回调增量文本与工具调用
+func (p *Provider) chat(ctx context.Context, messages []types.Message, tools []openai.Tool, output func(chunk types.Response)) error {
+	// 整个请求（含流式读取）受 timeout 约束
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+	defer cancel()
+
+	payload, err := json.Marshal(p.buildRequest(messages, tools))
+	if err != nil {
+		return fmt.Errorf("序列化请求参数失败: %v", err)
+	}
+
+	// 仅对建立流式请求重试，已开始输出后不再重试
+	resp, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+			p.baseURL+"/services/aigc/text-generation/generation", bytes.NewReader(payload))
+		if err != nil {
+			return nil, utils.Permanent(fmt.Errorf("创建请求失败: %v", err))
+		}
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-DashScope-SSE", "enable")
+		return utils.DoHTTP(p.client, req)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 非增量模式下记录已输出的长度，只输出新增部分
+	emittedContent := 0
+	emittedArgs := map[int]int{}
+
+	var streamErr error
+	readErr := llm.ReadSSE(resp.Body, func(event llm.SSEEvent) bool {
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+			return true
+		}
+		if event.Event == "error" || chunk.Code != "" {
+			streamErr = fmt.Errorf("流式响应错误(%s): %s", chunk.Code, chunk.Message)
+			return false
+		}
+		if len(chunk.Output.Choices) == 0 {
+			return true
+		}
+
+		message := chunk.Output.Choices[0].Message
+		response := types.Response{Content: message.Content}
+		toolCalls := message.ToolCalls
+		if !p.incremental {
+			response.Content = suffix(message.Content, &emittedContent)
+			toolCalls = make([]types.ToolCall, 0, len(message.ToolCalls))
+			for _, tc := range message.ToolCalls {
+				emitted, seen := emittedArgs[tc.Index]
+				args := suffix(tc.Function.Arguments, &emitted)
+				if seen && args == "" {
+					continue
+				}
+				// 名称与 id 只在首个片段下发
+				if seen {
+					tc.ID, tc.Function.Name = "", ""
+				}
+				tc.Function.Arguments = args
+				emittedArgs[tc.Index] = emitted
+				toolCalls = append(toolCalls, tc)
+			}
+		}
+		response.ToolCalls = toolCalls
+		if response.Content != "" || len(response.ToolCalls) > 0 {
+			output(response)
+		}
+		// 每个片段都带累计用量，只在结束时输出一次
+		if finish := chunk.Output.Choices[0].FinishReason; chunk.Usage != nil && finish != "" && finish != "null" {
+			output(types.Response{Usage: &types.Usage{
+				PromptTokens:        chunk.Usage.InputTokens,
+				CompletionTokens:    chunk.Usage.OutputTokens,
+				CachedTokens:        chunk.Usage.PromptTokensDetails.CachedTokens,
+				CacheCreationTokens: chunk.Usage.PromptTokensDetails.CacheCreationInputTokens,
+			}})
+		}
+		return true
+	})
+	if streamErr != nil {
+		return streamErr
+	}
+	if readErr != nil {
+		return fmt.Errorf("读取流式响应失败: %v", readErr)
+	}
+	return nil
+}
+
+// suffix 返回完整内容中尚未输出的部分，并更新已输出长度
+func suffix(full string, emitted *int) string {
+	if len(full) <= *emitted {
+		return ""
+	}
+	s := full[*emitted:]
+	*emitted = len(full)
+	return s
+}
@@ -0,0 +1,270 @@
+package dify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultBaseURL = "https://api.dify.ai/v1"
+)
+
+// errConversationNotFound Dify 会话已被删除或过期
+var errConversationNotFound = errors.New("Dify 会话不存在")
+
+// Provider Dify 应用 LLM提供者
+// 调用 chat-messages 流式接口，编排、知识库与工具均在 Dify 应用内完成；
+// 每轮只提交最新的用户消息，历史由 Dify 按 conversation_id 维护
+type Provider struct {
+	*llm.BaseProvider
+	apiKey  string
+	baseURL string
+	client  *http.Client
+
+	mu            sync.Mutex
+	conversations map[string]string // sessionID -> Dify conversation_id
+}
+
+// streamEvent chat-messages 流式事件，事件类型在 data 的 event 字段中
+type streamEvent struct {
+	Event          string `json:"event"`
+	Answer         string `json:"answer"`
+	ConversationID string `json:"conversation_id"`
+	Status         int    `json:"status"`
+	Code           string `json:"code"`
+	Message        string `json:"message"`
+}
+
+// 注册提供者
+func init() {
+	llm.RegisterProvider(providers.ProviderMeta{
+		Name:         "dify",
+		Description:  "Dify 应用",
+		Capabilities: []string{providers.CapabilityStreaming},
+	}, NewProvider)
+}
+
+// NewProvider 创建Dify提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	provider := &Provider{
+		BaseProvider:  llm.NewBaseProvider(config),
+		conversations: make(map[string]string),
+	}
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	config := p.Config()
+	p.apiKey = config.APIKey
+	if p.apiKey == "" {
+		p.apiKey = os.Getenv("DIFY_API_KEY")
+	}
+	if p.apiKey == "" {
+		return fmt.Errorf("缺少Dify应用 api_key 配置")
+	}
+
+	p.baseURL = defaultBaseURL
+	if config.BaseURL != "" {
+		p.baseURL = config.BaseURL
+	}
+	p.baseURL = strings.TrimRight(p.baseURL, "/")
+	// 流式响应的总时长由 context 控制，client 不设置超时
+	p.client = &http.Client{}
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Reset 清除会话映射，资源归还池时调用
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	p.conversations = make(map[string]string)
+	p.mu.Unlock()
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		err := p.chat(ctx, sessionID, messages, func(content string) {
+			responseChan <- content
+		})
+		if err != nil {
+			responseChan <- fmt.Sprintf("【Dify服务响应异常: %v】", err)
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现，工具在 Dify 应用内编排，tools 参数不生效
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		err := p.chat(ctx, sessionID, messages, func(content string) {
+			responseChan <- types.Response{Content: content}
+		})
+		if err != nil {
+			responseChan <- types.Response{
+				Content: fmt.Sprintf("【Dify服务响应异常: %v】", err),
+				Error:   err.Error(),
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// conversation 获取 sessionID 对应的 Dify 会话
+func (p *Provider) conversation(sessionID string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.conversations[sessionID]
+}
+
+// setConversation 记录 sessionID 对应的 Dify 会话，空值表示清除
+func (p *Provider) setConversation(sessionID, conversationID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if conversationID == "" {
+		delete(p.conversations, sessionID)
+		return
+	}
+	p.conversations[sessionID] = conversationID
+}
+
+// lastUserMessage 取最新的用户消息作为本轮 query
+func lastUserMessage(messages []types.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// chat 发起流式对话，会话在 Dify 侧失效时以新会话重试一次
+func (p *Provider) chat(ctx context.Context, sessionID string, messages []types.Message, output func(content string)) error {
+	// 整个请求（含流式读取）受 timeout 约束
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+	defer cancel()
+
+	query := lastUserMessage(messages)
+	if query == "" {
+		return fmt.Errorf("没有可提交的用户消息")
+	}
+	conversationID := p.conversation(sessionID)
+	err := p.stream(ctx, sessionID, query, conversationID, output)
+	if errors.Is(err, errConversationNotFound) && conversationID != "" {
+		p.setConversation(sessionID, "")
+		err = p.stream(ctx, sessionID, query, "", output)
+	}
+	return err
+}
+
+// stream 提交一轮对话并逐段输出回答
+func (p *Provider) stream(ctx context.Context, sessionID, query, conversationID string, output func(content string)) error {
+	config := p.Config()
+	inputs, _ := config.Extra["inputs"].(map[string]interface{})
+	if inputs == nil {
+		inputs = map[string]interface{}{}
+	}
+	user := config.GetString("user", sessionID)
+	if user == "" {
+		user = "xiaozhi"
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"inputs":          inputs,
+		"query":           query,
+		"response_mode":   "streaming",
+		"conversation_id": conversationID,
+		"user":            user,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化请求参数失败: %v", err)
+	}
+
+	// 仅对建立流式请求重试，已开始输出后不再重试
+	resp, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat-messages", bytes.NewReader(payload))
+		if err != nil {
+			return nil, utils.Permanent(fmt.Errorf("创建请求失败: %v", err))
+		}
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		return p.do(req)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var streamErr error
+	readErr := llm.ReadSSE(resp.Body, func(event llm.SSEEvent) bool {
+		var data streamEvent
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+			return true
+		}
+		if data.ConversationID != "" && data.ConversationID != conversationID {
+			conversationID = data.ConversationID
+			p.setConversation(sessionID, conversationID)
+		}
+		switch data.Event {
+		case "message", "agent_message":
+			if data.Answer != "" {
+				output(data.Answer)
+			}
+		case "error":
+			streamErr = fmt.Errorf("流式响应错误(%s): %s", data.Code, data.Message)
+			return false
+		case "message_end":
+			return false
+		}
+		return true
+	})
+	if streamErr != nil {
+		return streamErr
+	}
+	if readErr != nil {
+		return fmt.Errorf("读取流式响应失败: %v", readErr)
+	}
+	return nil
+}
+
+// do 发送请求，非 2xx 响应返回 HTTPStatusError 以便按状态码决定是否重试
+func (p *Provider) do(req *http.Request) (*http.Response, error) {
+	resp, err := utils.DoHTTP(p.client, req)
+	var statusErr *utils.HTTPStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		var result streamEvent
+		if json.Unmarshal([]byte(statusErr.Body), &result) == nil && result.Code == "not_found" {
+			return nil, utils.Permanent(errConversationNotFound)
+		}
+	}
+	return resp, err
+}
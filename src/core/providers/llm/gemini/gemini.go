@@ -0,0 +1,450 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	defaultModel   = "gemini-2.5-flash"
+)
+
+// unsupportedSchemaKeys Gemini 函数参数只支持 OpenAPI Schema 子集，转换时去掉这些 JSON Schema 字段
+var unsupportedSchemaKeys = []string{"$schema", "additionalProperties", "strict"}
+
+// callSeq 本地生成工具调用 id 的序号，接口未返回 id 时使用
+var callSeq atomic.Int64
+
+// Provider Google Gemini LLM提供者
+// 调用 streamGenerateContent 流式接口，system 消息转为 systemInstruction；
+// 工具调用与结果对应 functionCall / functionResponse，functionResponse 按工具调用 id 找回函数名
+type Provider struct {
+	*llm.BaseProvider
+	apiKey  string
+	baseURL string
+	client  *http.Client
+
+	mu         sync.Mutex
+	signatures map[string]string // 工具调用 id -> thoughtSignature，回传工具结果时需原样带上
+}
+
+// content 对话内容，role 为 user / model
+type content struct {
+	Role  string                   `json:"role,omitempty"`
+	Parts []map[string]interface{} `json:"parts"`
+}
+
+// streamChunk 流式响应片段
+type streamChunk struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text         string `json:"text"`
+				Thought      bool   `json:"thought"`
+				FunctionCall *struct {
+					ID   string          `json:"id"`
+					Name string          `json:"name"`
+					Args json.RawMessage `json:"args"`
+				} `json:"functionCall"`
+				ThoughtSignature string `json:"thoughtSignature"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata *struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	} `json:"usageMetadata"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// 注册提供者
+func init() {
+	llm.RegisterProvider(providers.ProviderMeta{
+		Name:         "gemini",
+		Description:  "Google Gemini",
+		Capabilities: []string{providers.CapabilityStreaming, providers.CapabilityFunctionCall},
+	}, NewProvider)
+}
+
+// NewProvider 创建Gemini提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	provider := &Provider{
+		BaseProvider: llm.NewBaseProvider(config),
+		signatures:   make(map[string]string),
+	}
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	config := p.Config()
+	p.apiKey = config.APIKey
+	if p.apiKey == "" {
+		p.apiKey = os.Getenv("GEMINI_API_KEY")
+	}
+	if p.apiKey == "" {
+		return fmt.Errorf("缺少Gemini api_key 配置")
+	}
+	p.baseURL = defaultBaseURL
+	if config.BaseURL != "" {
+		p.baseURL = config.BaseURL
+	}
+	p.baseURL = strings.TrimRight(p.baseURL, "/")
+	// 流式响应的总时长由 context 控制，client 不设置超时
+	p.client = &http.Client{}
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Reset 清除记录的 thoughtSignature，资源归还池时调用
+func (p *Provider) Reset() error {
+	p.mu.Lock()
+	p.signatures = make(map[string]string)
+	p.mu.Unlock()
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		err := p.chat(ctx, messages, nil, func(chunk types.Response) {
+			if chunk.Content != "" {
+				responseChan <- chunk.Content
+			}
+		})
+		if err != nil {
+			responseChan <- fmt.Sprintf("【Gemini服务响应异常: %v】", err)
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		err := p.chat(ctx, messages, tools, func(chunk types.Response) {
+			responseChan <- chunk
+		})
+		if err != nil {
+			responseChan <- types.Response{
+				Content: fmt.Sprintf("【Gemini服务响应异常: %v】", err),
+				Error:   err.Error(),
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// signature 工具调用对应的 thoughtSignature
+func (p *Provider) signature(callID string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.signatures[callID]
+}
+
+// setSignature 记录工具调用对应的 thoughtSignature
+func (p *Provider) setSignature(callID, signature string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.signatures[callID] = signature
+}
+
+// convertMessages 转换为 systemInstruction 与对话内容
+// 相邻的同角色内容合并为一条，连续的多个工具结果放在同一条 user 内容中
+func (p *Provider) convertMessages(messages []types.Message) ([]string, []content) {
+	var system []string
+	var result []content
+	appendParts := func(role string, parts ...map[string]interface{}) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			result[n-1].Parts = append(result[n-1].Parts, parts...)
+			return
+		}
+		result = append(result, content{Role: role, Parts: parts})
+	}
+
+	names := map[string]string{} // 工具调用 id -> 函数名
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				system = append(system, msg.Content)
+			}
+		case "tool":
+			// response 必须是 JSON 对象，非对象的工具结果包一层 result
+			var response map[string]interface{}
+			if json.Unmarshal([]byte(msg.Content), &response) != nil || response == nil {
+				response = map[string]interface{}{"result": msg.Content}
+			}
+			appendParts("user", map[string]interface{}{
+				"functionResponse": map[string]interface{}{
+					"id":       msg.ToolCallID,
+					"name":     names[msg.ToolCallID],
+					"response": response,
+				},
+			})
+		case "assistant":
+			var parts []map[string]interface{}
+			if msg.Content != "" {
+				parts = append(parts, map[string]interface{}{"text": msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				names[tc.ID] = tc.Function.Name
+				args := map[string]interface{}{}
+				if tc.Function.Arguments != "" {
+					_ = json.Unmarshal([]byte(tc.Function.Arguments), &args)
+				}
+				part := map[string]interface{}{
+					"functionCall": map[string]interface{}{
+						"id":   tc.ID,
+						"name": tc.Function.Name,
+						"args": args,
+					},
+				}
+				if signature := p.signature(tc.ID); signature != "" {
+					part["thoughtSignature"] = signature
+				}
+				parts = append(parts, part)
+			}
+			appendParts("model", parts...)
+		default:
+			if msg.Content != "" {
+				appendParts("user", map[string]interface{}{"text": msg.Content})
+			}
+		}
+	}
+	// 对话需以 user 开始，以助手开场白开头的对话补一条占位内容
+	if len(result) > 0 && result[0].Role != "user" {
+		result = append([]content{{Role: "user", Parts: []map[string]interface{}{{"text": "你好"}}}}, result...)
+	}
+	return system, result
+}
+
+// convertTools 转换为 functionDeclarations，没有参数的函数不传 parameters
+func convertTools(tools []openai.Tool) []map[string]interface{} {
+	declarations := make([]map[string]interface{}, 0, len(tools))
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		declaration := map[string]interface{}{
+			"name":        tool.Function.Name,
+			"description": tool.Function.Description,
+		}
+		if params := cleanSchema(tool.Function.Parameters); params != nil {
+			declaration["parameters"] = params
+		}
+		declarations = append(declarations, declaration)
+	}
+	if len(declarations) == 0 {
+		return nil
+	}
+	return []map[string]interface{}{{"functionDeclarations": declarations}}
+}
+
+// cleanSchema 把 JSON Schema 转为 Gemini 支持的形式，属性为空的对象返回 nil
+func cleanSchema(schema interface{}) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil
+	}
+	var result map[string]interface{}
+	if json.Unmarshal(data, &result) != nil || result == nil {
+		return nil
+	}
+	stripSchemaKeys(result)
+	if properties, _ := result["properties"].(map[string]interface{}); len(properties) == 0 {
+		return nil
+	}
+	return result
+}
+
+// stripSchemaKeys 递归去掉不支持的字段
+func stripSchemaKeys(v interface{}) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		for _, key := range unsupportedSchemaKeys {
+			delete(node, key)
+		}
+		for _, child := range node {
+			stripSchemaKeys(child)
+		}
+	case []interface{}:
+		for _, child := range node {
+			stripSchemaKeys(child)
+		}
+	}
+}
+
+// buildRequest 构造请求体
+func (p *Provider) buildRequest(messages []types.Message, tools []openai.Tool) map[string]interface{} {
+	config := p.Config()
+	system, contents := p.convertMessages(messages)
+
+	request := map[string]interface{}{"contents": contents}
+	if len(system) > 0 {
+		request["systemInstruction"] = content{Parts: []map[string]interface{}{{"text": strings.Join(system, "\n\n")}}}
+	}
+	if declarations := convertTools(tools); declarations != nil {
+		request["tools"] = declarations
+	}
+
+	generation := map[string]interface{}{}
+	if config.Temperature > 0 {
+		generation["temperature"] = config.Temperature
+	}
+	if config.TopP > 0 {
+		generation["topP"] = config.TopP
+	}
+	if config.MaxTokens > 0 {
+		generation["maxOutputTokens"] = config.MaxTokens
+	}
+	// gemini-2.5-flash 等混合推理模型可设为 0 关闭思考以降低首字延迟
+	if budget := config.GetInt("thinking_budget", -1); budget >= 0 {
+		generation["thinkingConfig"] = map[string]interface{}{"thinkingBudget": budget}
+	}
+	if len(generation) > 0 {
+		request["generationConfig"] = generation
+	}
+	return request
+}
+
+// chat 发起流式对话，逐片段回调增量文本与工具调用
+func (p *Provider) chat(ctx context.Context, messages []types.Message, tools []openai.Tool, output func(chunk types.Response)) error {
+	// 整个请求（含流式读取）受 timeout 约束
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+	defer cancel()
+
+	payload, err := json.Marshal(p.buildRequest(messages, tools))
+	if err != nil {
+		return fmt.Errorf("序列化请求参数失败: %v", err)
+	}
+	model := p.Config().ModelName
+	if model == "" {
+		model = defaultModel
+	}
+	endpoint := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse", p.baseURL, url.PathEscape(model))
+
+	// 仅对建立流式请求重试，已开始输出后不再重试
+	resp, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return nil, utils.Permanent(fmt.Errorf("创建请求失败: %v", err))
+		}
+		req.Header.Set("x-goog-api-key", p.apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		return utils.DoHTTP(p.client, req)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	toolIndex := 0
+	var streamErr error
+	readErr := llm.ReadSSE(resp.Body, func(event llm.SSEEvent) bool {
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+			return true
+		}
+		if chunk.Error != nil {
+			streamErr = fmt.Errorf("流式响应错误(%s): %s", chunk.Error.Status, chunk.Error.Message)
+			return false
+		}
+		if len(chunk.Candidates) == 0 {
+			return true
+		}
+
+		candidate := chunk.Candidates[0]
+		var response types.Response
+		for _, part := range candidate.Content.Parts {
+			// 思考内容不播报
+			if part.Thought {
+				continue
+			}
+			response.Content += part.Text
+			if call := part.FunctionCall; call != nil {
+				// functionCall 一次返回完整参数，未返回 id 时本地生成
+				id := call.ID
+				if id == "" {
+					id = fmt.Sprintf("call_%d", callSeq.Add(1))
+				}
+				if part.ThoughtSignature != "" {
+					p.setSignature(id, part.ThoughtSignature)
+				}
+				args := string(call.Args)
+				if args == "" || args == "null" {
+					args = "{}"
+				}
+				response.ToolCalls = append(response.ToolCalls, types.ToolCall{
+					ID:       id,
+					Type:     "function",
+					Index:    toolIndex,
+					Function: types.FunctionCall{Name: call.Name, Arguments: args},
+				})
+				toolIndex++
+			}
+		}
+		if response.Content != "" || len(response.ToolCalls) > 0 {
+			output(response)
+		}
+		// 每个片段都带累计用量，只在结束时输出一次；思考消耗的 token 计入输出
+		if candidate.FinishReason != "" && chunk.UsageMetadata != nil {
+			output(types.Response{Usage: &types.Usage{
+				PromptTokens:     chunk.UsageMetadata.PromptTokenCount,
+				CompletionTokens: chunk.UsageMetadata.CandidatesTokenCount + chunk.UsageMetadata.ThoughtsTokenCount,
+				CachedTokens:     chunk.UsageMetadata.CachedContentTokenCount,
+			}})
+		}
+		return true
+	})
+	if streamErr != nil {
+		return streamErr
+	}
+	if readErr != nil {
+		return fmt.Errorf("读取流式响应失败: %v", readErr)
+	}
+	return nil
+}
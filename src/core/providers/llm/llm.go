@@ -1,11 +1,20 @@
 package llm
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/sashabaranov/go-openai"
 )
 
+// DefaultTimeout 未配置 timeout 时单次请求（含流式读取）的超时时间
+const DefaultTimeout = 60 * time.Second
+
 // Config LLM配置结构
 type Config struct {
 	Type        string                 `yaml:"type"`
@@ -15,9 +24,38 @@ type Config struct {
 	Temperature float64                `yaml:"temperature,omitempty"`
 	MaxTokens   int                    `yaml:"max_tokens,omitempty"`
 	TopP        float64                `yaml:"top_p,omitempty"`
+	Timeout     string                 `yaml:"timeout,omitempty"`
+	MaxRetries  *int                   `yaml:"max_retries,omitempty"`
 	Extra       map[string]interface{} `yaml:",inline"`
 }
 
+// GetString 读取提供者专有的字符串参数
+func (c *Config) GetString(key, def string) string {
+	if v, ok := c.Extra[key].(string); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// GetInt 读取提供者专有的整数参数
+func (c *Config) GetInt(key string, def int) int {
+	switch v := c.Extra[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return def
+}
+
+// GetBool 读取提供者专有的布尔参数
+func (c *Config) GetBool(key string, def bool) bool {
+	if v, ok := c.Extra[key].(bool); ok {
+		return v
+	}
+	return def
+}
+
 // Provider LLM提供者接口
 type Provider interface {
 	types.LLMProvider
@@ -33,6 +71,72 @@ func (p *BaseProvider) Config() *Config {
 	return p.config
 }
 
+// Timeout 获取单次请求的超时时间
+func (p *BaseProvider) Timeout() time.Duration {
+	return utils.ParseTimeout(p.config.Timeout, DefaultTimeout)
+}
+
+// RetryPolicy 获取建立请求时的重试策略，流式输出开始后不再重试
+func (p *BaseProvider) RetryPolicy() utils.RetryPolicy {
+	policy := utils.DefaultRetryPolicy()
+	policy.Retryable = IsRetryableError
+	if p.config.MaxRetries != nil {
+		policy = policy.WithMaxRetries(*p.config.MaxRetries)
+	}
+	return policy
+}
+
+// IsRetryableError 判断 OpenAI 兼容接口的错误是否可重试，4xx（429除外）直接失败
+func IsRetryableError(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return utils.IsRetryableStatus(apiErr.HTTPStatusCode)
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return utils.IsRetryableStatus(reqErr.HTTPStatusCode)
+	}
+	return utils.IsRetryableError(err)
+}
+
+// ToOpenAIMessages 转换为 OpenAI 兼容接口的消息，保留工具调用与 tool_call_id 以便回传多个工具结果
+func ToOpenAIMessages(messages []types.Message) []openai.ChatCompletionMessage {
+	chatMessages := make([]openai.ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		chatMessages[i] = openai.ChatCompletionMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, tc := range msg.ToolCalls {
+			chatMessages[i].ToolCalls = append(chatMessages[i].ToolCalls, openai.ToolCall{
+				ID:   tc.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				},
+			})
+		}
+	}
+	return chatMessages
+}
+
+// UsageFromOpenAI 转换 OpenAI 兼容接口返回的用量，cached_tokens 为命中 prompt 缓存的输入部分
+func UsageFromOpenAI(usage *openai.Usage) *types.Usage {
+	if usage == nil {
+		return nil
+	}
+	result := &types.Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	}
+	if usage.PromptTokensDetails != nil {
+		result.CachedTokens = usage.PromptTokensDetails.CachedTokens
+	}
+	return result
+}
+
 // NewBaseProvider 创建LLM基础提供者
 func NewBaseProvider(config *Config) *BaseProvider {
 	return &BaseProvider{
@@ -53,20 +157,26 @@ func (p *BaseProvider) Cleanup() error {
 // Factory LLM工厂函数类型
 type Factory func(config *Config) (Provider, error)
 
-var (
-	factories = make(map[string]Factory)
-)
+var factories = providers.NewRegistry[Factory]("LLM")
 
-// Register 注册LLM提供者工厂
+// Register 注册LLM提供者工厂，不声明元数据
 func Register(name string, factory Factory) {
-	factories[name] = factory
+	RegisterProvider(providers.ProviderMeta{Name: name}, factory)
+}
+
+// RegisterProvider 注册LLM提供者工厂及其能力与必填配置
+func RegisterProvider(meta providers.ProviderMeta, factory Factory) {
+	factories.Register(meta, factory)
 }
 
-// Create 创建LLM提供者实例
+// Create 创建LLM提供者实例，先按元数据校验必填配置
 func Create(name string, config *Config) (Provider, error) {
-	factory, ok := factories[name]
+	factory, meta, ok := factories.Lookup(name)
 	if !ok {
-		return nil, fmt.Errorf("未知的LLM提供者: %s", name)
+		return nil, factories.UnknownError(name)
+	}
+	if err := providers.CheckRequired(meta, providers.ConfigValues(config)); err != nil {
+		return nil, err
 	}
 
 	provider, err := factory(config)
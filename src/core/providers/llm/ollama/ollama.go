@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/llm"
 	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
 
 	"github.com/sashabaranov/go-openai"
 )
@@ -20,7 +22,11 @@ type Provider struct {
 
 // 注册提供者
 func init() {
-	llm.Register("ollama", NewProvider)
+	llm.RegisterProvider(providers.ProviderMeta{
+		Name:         "ollama",
+		Description:  "Ollama 本地模型",
+		Capabilities: []string{providers.CapabilityStreaming, providers.CapabilityFunctionCall, providers.CapabilityLocal},
+	}, NewProvider)
 }
 
 // NewProvider 创建Ollama提供者
@@ -76,6 +82,10 @@ func (p *Provider) Response(ctx context.Context, sessionID string, messages []ty
 	go func() {
 		defer close(responseChan)
 
+		// 整个请求（含流式读取）受 timeout 约束
+		ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+		defer cancel()
+
 		// 如果是qwen3模型，在用户最后一条消息中添加/no_think指令
 		if p.isQwen3 {
 			messages = p.addNoThinkDirective(messages)
@@ -90,14 +100,17 @@ func (p *Provider) Response(ctx context.Context, sessionID string, messages []ty
 			}
 		}
 
-		stream, err := p.client.CreateChatCompletionStream(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:    p.modelName,
-				Messages: chatMessages,
-				Stream:   true,
-			},
-		)
+		// 仅对建立流式请求重试，已开始输出后不再重试
+		stream, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*openai.ChatCompletionStream, error) {
+			return p.client.CreateChatCompletionStream(
+				ctx,
+				openai.ChatCompletionRequest{
+					Model:    p.modelName,
+					Messages: chatMessages,
+					Stream:   true,
+				},
+			)
+		})
 		if err != nil {
 			responseChan <- fmt.Sprintf("【Ollama服务响应异常: %v】", err)
 			return
@@ -142,29 +155,29 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 	go func() {
 		defer close(responseChan)
 
+		// 整个请求（含流式读取）受 timeout 约束
+		ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+		defer cancel()
+
 		// 如果是qwen3模型，在用户最后一条消息中添加/no_think指令
 		if p.isQwen3 {
 			messages = p.addNoThinkDirective(messages)
 		}
 
 		// 转换消息格式
-		chatMessages := make([]openai.ChatCompletionMessage, len(messages))
-		for i, msg := range messages {
-			chatMessages[i] = openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
-			}
-		}
-
-		stream, err := p.client.CreateChatCompletionStream(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:    p.modelName,
-				Messages: chatMessages,
-				Tools:    tools,
-				Stream:   true,
-			},
-		)
+		chatMessages := llm.ToOpenAIMessages(messages)
+
+		stream, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*openai.ChatCompletionStream, error) {
+			return p.client.CreateChatCompletionStream(
+				ctx,
+				openai.ChatCompletionRequest{
+					Model:    p.modelName,
+					Messages: chatMessages,
+					Tools:    tools,
+					Stream:   true,
+				},
+			)
+		})
 		if err != nil {
 			responseChan <- types.Response{
 				Content: fmt.Sprintf("【Ollama服务响应异常: %v】", err),
@@ -198,6 +211,10 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 								Arguments: tc.Function.Arguments,
 							},
 						}
+						// 多个工具调用的分片按 index 区分
+						if tc.Index != nil {
+							toolCalls[i].Index = *tc.Index
+						}
 					}
 					responseChan <- types.Response{
 						ToolCalls: toolCalls,
@@ -228,6 +245,41 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 	return responseChan, nil
 }
 
+// ResponseWithSchema types.SchemaLLMProvider接口实现，Ollama 0.5 起支持按 JSON Schema 约束输出
+func (p *Provider) ResponseWithSchema(ctx context.Context, sessionID string, messages []types.Message, schema *types.ResponseSchema) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+	defer cancel()
+
+	messages = llm.SchemaMessages(messages, schema)
+	if p.isQwen3 {
+		messages = p.addNoThinkDirective(messages)
+	}
+	chatMessages := make([]openai.ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		chatMessages[i] = openai.ChatCompletionMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+	}
+
+	// 旧版本 Ollama 只支持 json 模式，可将 schema_mode 设为 json_object
+	request := openai.ChatCompletionRequest{
+		Model:          p.modelName,
+		Messages:       chatMessages,
+		ResponseFormat: llm.ResponseFormat(schema, p.Config().GetString("schema_mode", "json_schema")),
+	}
+	response, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (openai.ChatCompletionResponse, error) {
+		return p.client.CreateChatCompletion(ctx, request)
+	})
+	if err != nil {
+		return "", fmt.Errorf("Ollama结构化输出请求失败: %v", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("Ollama未返回结果")
+	}
+	return response.Choices[0].Message.Content, nil
+}
+
 // addNoThinkDirective 为qwen3模型在用户最后一条消息中添加/no_think指令
 func (p *Provider) addNoThinkDirective(messages []types.Message) []types.Message {
 	// 复制消息列表
@@ -2,23 +2,42 @@ package openai
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/llm"
 	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
 
 	"github.com/sashabaranov/go-openai"
 )
 
 // Provider OpenAI LLM提供者
+// 推理模型（o1/R1 类）按模型名称自动启用兼容模式：使用 max_completion_tokens，
+// 按 reasoning_output 处理 reasoning_content，接口不支持流式时回退为非流式请求
 type Provider struct {
 	*llm.BaseProvider
-	client    *openai.Client
-	maxTokens int
+	client     *openai.Client
+	httpClient openai.HTTPDoer
+	baseURL    string
+	maxTokens  int
+
+	reasoning         bool
+	reasoningOutput   string
+	streamUnsupported atomic.Bool // 服务端拒绝流式请求后改用非流式
 }
 
 // 注册提供者
 func init() {
-	llm.Register("openai", NewProvider)
+	llm.RegisterProvider(providers.ProviderMeta{
+		Name:         "openai",
+		Description:  "OpenAI 兼容接口",
+		Capabilities: []string{providers.CapabilityStreaming, providers.CapabilityFunctionCall},
+		Required:     []string{"api_key"},
+	}, NewProvider)
 }
 
 // NewProvider 创建OpenAI提供者
@@ -31,6 +50,11 @@ func NewProvider(config *llm.Config) (llm.Provider, error) {
 	if provider.maxTokens <= 0 {
 		provider.maxTokens = 500
 	}
+	provider.reasoning = config.GetBool("reasoning", llm.IsReasoningModel(config.ModelName))
+	provider.reasoningOutput = config.GetString("reasoning_output", llm.ReasoningOutputNone)
+	if !config.GetBool("stream", !strings.HasPrefix(config.ModelName, "o1")) {
+		provider.streamUnsupported.Store(true)
+	}
 
 	return provider, nil
 }
@@ -48,6 +72,26 @@ func (p *Provider) Initialize() error {
 	}
 
 	p.client = openai.NewClientWithConfig(clientConfig)
+	p.httpClient = clientConfig.HTTPClient
+	p.baseURL = clientConfig.BaseURL
+	return nil
+}
+
+// Prewarm 实现 providers.Prewarmer，向 base_url 发送一次 HEAD 请求，提前完成 TLS 握手并放入连接池
+func (p *Provider) Prewarm(ctx context.Context) error {
+	if p.httpClient == nil {
+		return fmt.Errorf("LLM 客户端尚未初始化")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, p.baseURL, nil)
+	if err != nil {
+		return fmt.Errorf("构造预热请求失败: %v", err)
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("预热请求失败: %v", err)
+	}
+	// 状态码不影响连接复用，关闭响应体后连接回到连接池
+	resp.Body.Close()
 	return nil
 }
 
@@ -63,6 +107,10 @@ func (p *Provider) Response(ctx context.Context, sessionID string, messages []ty
 	go func() {
 		defer close(responseChan)
 
+		// 整个请求（含流式读取）受 timeout 约束
+		ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+		defer cancel()
+
 		// 转换消息格式
 		chatMessages := make([]openai.ChatCompletionMessage, len(messages))
 		for i, msg := range messages {
@@ -72,15 +120,17 @@ func (p *Provider) Response(ctx context.Context, sessionID string, messages []ty
 			}
 		}
 
-		stream, err := p.client.CreateChatCompletionStream(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:     p.Config().ModelName,
-				Messages:  chatMessages,
-				Stream:    true,
-				MaxTokens: p.maxTokens,
-			},
-		)
+		// 仅对建立流式请求重试，已开始输出后不再重试
+		stream, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*openai.ChatCompletionStream, error) {
+			return p.client.CreateChatCompletionStream(
+				ctx,
+				p.withMaxTokens(openai.ChatCompletionRequest{
+					Model:    p.Config().ModelName,
+					Messages: chatMessages,
+					Stream:   true,
+				}),
+			)
+		})
 		if err != nil {
 			responseChan <- fmt.Sprintf("【OpenAI服务响应异常: %v】", err)
 			return
@@ -116,68 +166,207 @@ func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string,
 	go func() {
 		defer close(responseChan)
 
-		// 转换消息格式
-		chatMessages := make([]openai.ChatCompletionMessage, len(messages))
-		for i, msg := range messages {
-			chatMessages[i] = openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
-			}
+		// 整个请求（含流式读取）受 timeout 约束
+		ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+		defer cancel()
+
+		request := openai.ChatCompletionRequest{
+			Model:    p.Config().ModelName,
+			Messages: llm.ToOpenAIMessages(messages),
+			Tools:    tools,
+		}
+		if p.reasoning {
+			request = p.withMaxTokens(request)
 		}
 
-		stream, err := p.client.CreateChatCompletionStream(
-			ctx,
-			openai.ChatCompletionRequest{
-				Model:    p.Config().ModelName,
-				Messages: chatMessages,
-				Tools:    tools,
-				Stream:   true,
-			},
-		)
+		filter := llm.NewReasoningFilter(p.reasoningOutput, p.Config().GetInt("reasoning_brief_chars", 40))
+		var err error
+		if p.streamUnsupported.Load() {
+			err = p.chat(ctx, request, filter, responseChan)
+		} else {
+			err = p.chatStream(ctx, request, filter, responseChan)
+			if isStreamUnsupported(err) {
+				// 记住服务端不支持流式，后续请求直接走非流式
+				p.streamUnsupported.Store(true)
+				err = p.chat(ctx, request, filter, responseChan)
+			}
+		}
 		if err != nil {
 			responseChan <- types.Response{
 				Content: fmt.Sprintf("【OpenAI服务响应异常: %v】", err),
 				Error:   err.Error(),
 			}
-			return
 		}
-		defer stream.Close()
+	}()
 
-		for {
-			response, err := stream.Recv()
-			if err != nil {
-				break
-			}
+	return responseChan, nil
+}
 
-			if len(response.Choices) > 0 {
-				delta := response.Choices[0].Delta
-				chunk := types.Response{
-					Content: delta.Content,
+// chatStream 流式请求，逐片段输出回答与工具调用
+func (p *Provider) chatStream(ctx context.Context, request openai.ChatCompletionRequest, filter *llm.ReasoningFilter, responseChan chan<- types.Response) error {
+	request.Stream = true
+	// 最后一个片段返回用量，其中包含命中 prompt 缓存的 token 数；不支持 stream_options 的兼容服务可关闭
+	if p.Config().GetBool("stream_usage", true) {
+		request.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+
+	stream, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*openai.ChatCompletionStream, error) {
+		return p.client.CreateChatCompletionStream(
+			ctx,
+			request,
+		)
+	})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	for {
+		response, err := stream.Recv()
+		if err != nil {
+			break
+		}
+
+		if response.Usage != nil {
+			responseChan <- types.Response{Usage: llm.UsageFromOpenAI(response.Usage)}
+		}
+
+		if len(response.Choices) > 0 {
+			delta := response.Choices[0].Delta
+			if delta.ReasoningContent != "" {
+				if text := filter.Reasoning(delta.ReasoningContent); text != "" {
+					responseChan <- types.Response{Content: text}
 				}
-				//fmt.Println("openai delta:", delta)
-
-				if delta.ToolCalls != nil && len(delta.ToolCalls) > 0 {
-					toolCalls := make([]types.ToolCall, len(delta.ToolCalls))
-					for i, tc := range delta.ToolCalls {
-						toolCalls[i] = types.ToolCall{
-							ID:   tc.ID,
-							Type: string(tc.Type),
-							Function: types.FunctionCall{
-								Name:      tc.Function.Name,
-								Arguments: tc.Function.Arguments,
-							},
-						}
+				continue
+			}
+			if delta.Content == "" && len(delta.ToolCalls) == 0 {
+				continue
+			}
+			// 推理结束，开始输出回答
+			if brief := filter.Flush(); brief != "" {
+				responseChan <- types.Response{Content: brief}
+			}
+			chunk := types.Response{
+				Content: delta.Content,
+			}
+			//fmt.Println("openai delta:", delta)
+
+			if delta.ToolCalls != nil && len(delta.ToolCalls) > 0 {
+				toolCalls := make([]types.ToolCall, len(delta.ToolCalls))
+				for i, tc := range delta.ToolCalls {
+					toolCalls[i] = types.ToolCall{
+						ID:   tc.ID,
+						Type: string(tc.Type),
+						Function: types.FunctionCall{
+							Name:      tc.Function.Name,
+							Arguments: tc.Function.Arguments,
+						},
+					}
+					// 多个工具调用的分片按 index 区分
+					if tc.Index != nil {
+						toolCalls[i].Index = *tc.Index
 					}
-					chunk.ToolCalls = toolCalls
-					fmt.Println("openai tool calls:", chunk.ToolCalls)
 				}
-
-				responseChan <- chunk
+				chunk.ToolCalls = toolCalls
+				fmt.Println("openai tool calls:", chunk.ToolCalls)
 			}
+
+			responseChan <- chunk
 		}
-	}()
+	}
+	return nil
+}
 
-	return responseChan, nil
+// chat 非流式请求，拿到完整结果后一次性输出
+func (p *Provider) chat(ctx context.Context, request openai.ChatCompletionRequest, filter *llm.ReasoningFilter, responseChan chan<- types.Response) error {
+	response, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (openai.ChatCompletionResponse, error) {
+		return p.client.CreateChatCompletion(ctx, request)
+	})
+	if err != nil {
+		return err
+	}
+	if len(response.Choices) == 0 {
+		return fmt.Errorf("OpenAI未返回结果")
+	}
+
+	message := response.Choices[0].Message
+	if text := filter.Reasoning(message.ReasoningContent); text != "" {
+		responseChan <- types.Response{Content: text}
+	}
+	if brief := filter.Flush(); brief != "" {
+		responseChan <- types.Response{Content: brief}
+	}
+	chunk := types.Response{Content: message.Content}
+	for i, tc := range message.ToolCalls {
+		chunk.ToolCalls = append(chunk.ToolCalls, types.ToolCall{
+			ID:    tc.ID,
+			Type:  string(tc.Type),
+			Index: i,
+			Function: types.FunctionCall{
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+			},
+		})
+	}
+	if chunk.Content != "" || len(chunk.ToolCalls) > 0 {
+		responseChan <- chunk
+	}
+	if usage := llm.UsageFromOpenAI(&response.Usage); usage.PromptTokens > 0 {
+		responseChan <- types.Response{Usage: usage}
+	}
+	return nil
+}
+
+// withMaxTokens 设置最大输出长度，推理模型不支持 max_tokens，改用 max_completion_tokens
+func (p *Provider) withMaxTokens(request openai.ChatCompletionRequest) openai.ChatCompletionRequest {
+	if p.reasoning {
+		// 推理内容也计入输出长度，上限需要放宽
+		request.MaxCompletionTokens = p.Config().GetInt("max_completion_tokens", p.maxTokens*8)
+	} else {
+		request.MaxTokens = p.maxTokens
+	}
+	return request
+}
+
+// isStreamUnsupported 判断服务端是否因不支持流式而拒绝请求
+func isStreamUnsupported(err error) bool {
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.HTTPStatusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Message), "stream")
+}
+
+// ResponseWithSchema types.SchemaLLMProvider接口实现，通过 response_format 约束输出 JSON
+func (p *Provider) ResponseWithSchema(ctx context.Context, sessionID string, messages []types.Message, schema *types.ResponseSchema) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+	defer cancel()
+
+	messages = llm.SchemaMessages(messages, schema)
+	chatMessages := make([]openai.ChatCompletionMessage, len(messages))
+	for i, msg := range messages {
+		chatMessages[i] = openai.ChatCompletionMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+	}
+
+	// 不支持 json_schema 的兼容服务可将 schema_mode 设为 json_object
+	request := openai.ChatCompletionRequest{
+		Model:          p.Config().ModelName,
+		Messages:       chatMessages,
+		ResponseFormat: llm.ResponseFormat(schema, p.Config().GetString("schema_mode", "json_schema")),
+	}
+	response, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (openai.ChatCompletionResponse, error) {
+		return p.client.CreateChatCompletion(ctx, request)
+	})
+	if err != nil {
+		return "", fmt.Errorf("OpenAI结构化输出请求失败: %v", err)
+	}
+	if len(response.Choices) == 0 {
+		return "", fmt.Errorf("OpenAI未返回结果")
+	}
+	return response.Choices[0].Message.Content, nil
 }
 
 // handleThinkTags 处理思考标签
@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// 推理内容的播报方式
+const (
+	ReasoningOutputNone  = "none"  // 不播报，只输出最终回答
+	ReasoningOutputBrief = "brief" // 回答前简要说一句思路
+	ReasoningOutputFull  = "full"  // 完整播报推理过程
+)
+
+// reasoningModelMarkers 推理模型名称特征，用于自动启用兼容模式
+var reasoningModelMarkers = []string{"deepseek-r1", "deepseek-reasoner", "qwq", "-r1", "reasoner", "thinking"}
+
+// IsReasoningModel 按模型名称判断是否为推理模型（o1/o3/o4、DeepSeek-R1、QwQ 等）
+func IsReasoningModel(model string) bool {
+	model = strings.ToLower(model)
+	if idx := strings.LastIndex(model, "/"); idx >= 0 {
+		model = model[idx+1:]
+	}
+	for _, prefix := range []string{"o1", "o3", "o4"} {
+		if model == prefix || strings.HasPrefix(model, prefix+"-") {
+			return true
+		}
+	}
+	for _, marker := range reasoningModelMarkers {
+		if strings.Contains(model, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReasoningFilter 按播报方式处理推理内容
+// brief 模式下收集推理内容，在最终回答开始前输出第一句思路
+type ReasoningFilter struct {
+	mode     string
+	maxRunes int
+	buf      strings.Builder
+	flushed  bool
+}
+
+// NewReasoningFilter 创建推理内容过滤器，maxRunes 为 brief 模式下思路的最大字数
+func NewReasoningFilter(mode string, maxRunes int) *ReasoningFilter {
+	return &ReasoningFilter{mode: mode, maxRunes: maxRunes}
+}
+
+// Reasoning 处理一段推理内容，返回需要立即输出的文本
+func (f *ReasoningFilter) Reasoning(text string) string {
+	switch f.mode {
+	case ReasoningOutputFull:
+		return text
+	case ReasoningOutputBrief:
+		if !f.flushed {
+			f.buf.WriteString(text)
+		}
+	}
+	return ""
+}
+
+// Flush 最终回答开始前调用，brief 模式下返回简要思路，只输出一次
+func (f *ReasoningFilter) Flush() string {
+	if f.mode != ReasoningOutputBrief || f.flushed {
+		return ""
+	}
+	f.flushed = true
+	brief := strings.TrimSpace(f.buf.String())
+	if idx := strings.IndexAny(brief, "。！？!?\n"); idx >= 0 {
+		brief = brief[:idx]
+	}
+	brief = strings.TrimSpace(brief)
+	if brief == "" {
+		return ""
+	}
+	if utf8.RuneCountInString(brief) > f.maxRunes {
+		brief = string([]rune(brief)[:f.maxRunes])
+	}
+	return "我的思路是：" + brief + "。"
+}
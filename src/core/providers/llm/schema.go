@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"xiaozhi-server-go/src/core/types"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+var thinkPattern = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+// SchemaMessages 在最后一条用户消息后附加输出格式说明
+// 即使服务端支持 response_format，部分兼容接口仍要求提示词中出现 JSON 字样；
+// 只转发最后一条用户消息的提供者（Coze、Dify）也能收到说明
+func SchemaMessages(messages []types.Message, schema *types.ResponseSchema) []types.Message {
+	instruction := "请只输出一个 JSON 对象，不要输出其他内容。"
+	if schema.Description != "" {
+		instruction = schema.Description + "\n" + instruction
+	}
+	if len(schema.Schema) > 0 {
+		instruction += "\nJSON 需符合以下 JSON Schema：\n" + string(schema.Schema)
+	}
+	result := append([]types.Message(nil), messages...)
+	for i := len(result) - 1; i >= 0; i-- {
+		if result[i].Role == "user" {
+			result[i].Content += "\n\n" + instruction
+			return result
+		}
+	}
+	return append(result, types.Message{Role: "user", Content: instruction})
+}
+
+// ResponseFormat 转换为 OpenAI 兼容接口的 response_format
+// mode 为 json_object 时只要求输出 JSON，适用于不支持 json_schema 的服务
+func ResponseFormat(schema *types.ResponseSchema, mode string) *openai.ChatCompletionResponseFormat {
+	if len(schema.Schema) == 0 || mode == string(openai.ChatCompletionResponseFormatTypeJSONObject) {
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+	name := schema.Name
+	if name == "" {
+		name = "response"
+	}
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:        name,
+			Description: schema.Description,
+			Schema:      schema.Schema,
+			Strict:      schema.Strict,
+		},
+	}
+}
+
+// ExtractJSON 去掉思考内容与 markdown 代码块，返回回复中的 JSON 文本
+func ExtractJSON(content string) (string, error) {
+	content = strings.TrimSpace(thinkPattern.ReplaceAllString(content, ""))
+	start := strings.IndexAny(content, "{[")
+	end := strings.LastIndexAny(content, "}]")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("回复中没有 JSON: %s", content)
+	}
+	content = content[start : end+1]
+	if !json.Valid([]byte(content)) {
+		return "", fmt.Errorf("回复不是合法的 JSON: %s", content)
+	}
+	return content, nil
+}
+
+// ResponseWithSchema 请求结构化输出并解析到 v
+// 提供者实现了 SchemaLLMProvider 时使用其原生 JSON 模式，否则通过提示词约束并从流式回复中提取 JSON
+func ResponseWithSchema(ctx context.Context, provider types.LLMProvider, sessionID string,
+	messages []types.Message, schema *types.ResponseSchema, v interface{}) error {
+	var content string
+	if sp, ok := provider.(types.SchemaLLMProvider); ok {
+		result, err := sp.ResponseWithSchema(ctx, sessionID, messages, schema)
+		if err != nil {
+			return err
+		}
+		content = result
+	} else {
+		responses, err := provider.ResponseWithFunctions(ctx, sessionID, SchemaMessages(messages, schema), nil)
+		if err != nil {
+			return err
+		}
+		var sb strings.Builder
+		for response := range responses {
+			if response.Error != "" {
+				return fmt.Errorf("LLM请求失败: %s", response.Error)
+			}
+			sb.WriteString(response.Content)
+		}
+		content = sb.String()
+	}
+
+	data, err := ExtractJSON(content)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return fmt.Errorf("解析结构化输出失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// maxSSELineSize 单行 SSE 数据上限，插件返回的大段 JSON 可能超过 bufio 默认的 64KB
+const maxSSELineSize = 1 << 20
+
+// SSEEvent 一条 Server-Sent Events 事件
+type SSEEvent struct {
+	Event string
+	Data  string
+}
+
+// ReadSSE 逐条解析 SSE 流并回调，回调返回 false 时停止读取
+// 多行 data 按规范以换行拼接，注释与 id/retry 字段忽略
+func ReadSSE(r io.Reader, fn func(event SSEEvent) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+
+	var event SSEEvent
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// 空行表示一条事件结束
+			if len(data) > 0 || event.Event != "" {
+				event.Data = strings.Join(data, "\n")
+				if !fn(event) {
+					return nil
+				}
+			}
+			event, data = SSEEvent{}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	// 流结束时最后一条事件可能没有空行结尾
+	if len(data) > 0 || event.Event != "" {
+		event.Data = strings.Join(data, "\n")
+		fn(event)
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package zhipu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/llm"
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultBaseURL = "https://open.bigmodel.cn/api/paas/v4"
+	defaultModel   = "glm-4-flash"
+)
+
+// Provider 智谱 GLM 原生接口 LLM提供者
+// 在 openai 兼容格式之外支持原生 web_search 工具（联网搜索由智谱服务端执行），
+// 以及 charglm / emohaa 等角色扮演模型的 meta 人设参数
+type Provider struct {
+	*llm.BaseProvider
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+// chatMessage 请求消息
+type chatMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []types.ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// streamChunk 流式响应片段
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				ID       string             `json:"id"`
+				Type     string             `json:"type"`
+				Index    int                `json:"index"`
+				Function types.FunctionCall `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens        int `json:"prompt_tokens"`
+		CompletionTokens    int `json:"completion_tokens"`
+		PromptTokensDetails struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+	} `json:"usage"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// 注册提供者
+func init() {
+	llm.RegisterProvider(providers.ProviderMeta{
+		Name:         "zhipu",
+		Description:  "智谱 GLM",
+		Capabilities: []string{providers.CapabilityStreaming, providers.CapabilityFunctionCall},
+	}, NewProvider)
+}
+
+// NewProvider 创建智谱提供者
+func NewProvider(config *llm.Config) (llm.Provider, error) {
+	provider := &Provider{
+		BaseProvider: llm.NewBaseProvider(config),
+	}
+	return provider, nil
+}
+
+// Initialize 初始化提供者
+func (p *Provider) Initialize() error {
+	config := p.Config()
+	p.apiKey = config.APIKey
+	if p.apiKey == "" {
+		p.apiKey = os.Getenv("ZHIPUAI_API_KEY")
+	}
+	if p.apiKey == "" {
+		return fmt.Errorf("缺少智谱 api_key 配置")
+	}
+	p.baseURL = defaultBaseURL
+	if config.BaseURL != "" {
+		p.baseURL = config.BaseURL
+	}
+	p.baseURL = strings.TrimRight(p.baseURL, "/")
+	// 流式响应的总时长由 context 控制，client 不设置超时
+	p.client = &http.Client{}
+	return nil
+}
+
+// Cleanup 清理资源
+func (p *Provider) Cleanup() error {
+	return nil
+}
+
+// Response types.LLMProvider接口实现
+func (p *Provider) Response(ctx context.Context, sessionID string, messages []types.Message) (<-chan string, error) {
+	responseChan := make(chan string, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		err := p.chat(ctx, sessionID, messages, nil, func(chunk types.Response) {
+			if chunk.Content != "" {
+				responseChan <- chunk.Content
+			}
+		})
+		if err != nil {
+			responseChan <- fmt.Sprintf("【智谱服务响应异常: %v】", err)
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// ResponseWithFunctions types.LLMProvider接口实现
+func (p *Provider) ResponseWithFunctions(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool) (<-chan types.Response, error) {
+	responseChan := make(chan types.Response, 10)
+
+	go func() {
+		defer close(responseChan)
+
+		err := p.chat(ctx, sessionID, messages, tools, func(chunk types.Response) {
+			responseChan <- chunk
+		})
+		if err != nil {
+			responseChan <- types.Response{
+				Content: fmt.Sprintf("【智谱服务响应异常: %v】", err),
+				Error:   err.Error(),
+			}
+		}
+	}()
+
+	return responseChan, nil
+}
+
+// buildTools 合并本服务的函数与智谱原生的 web_search 工具
+func (p *Provider) buildTools(tools []openai.Tool) []interface{} {
+	config := p.Config()
+	result := make([]interface{}, 0, len(tools)+1)
+	for _, tool := range tools {
+		result = append(result, tool)
+	}
+	if config.GetBool("web_search", false) {
+		webSearch := map[string]interface{}{
+			"enable":        true,
+			"search_engine": config.GetString("search_engine", "search_std"),
+			"search_result": false,
+		}
+		if prompt := config.GetString("search_prompt", ""); prompt != "" {
+			webSearch["search_prompt"] = prompt
+		}
+		result = append(result, map[string]interface{}{
+			"type":       "web_search",
+			"web_search": webSearch,
+		})
+	}
+	return result
+}
+
+// buildRequest 构造请求体
+func (p *Provider) buildRequest(sessionID string, messages []types.Message, tools []openai.Tool) map[string]interface{} {
+	config := p.Config()
+	chatMessages := make([]chatMessage, 0, len(messages))
+	for _, msg := range messages {
+		chatMessages = append(chatMessages, chatMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  msg.ToolCalls,
+			ToolCallID: msg.ToolCallID,
+		})
+	}
+
+	model := config.ModelName
+	if model == "" {
+		model = defaultModel
+	}
+	body := map[string]interface{}{
+		"model":    model,
+		"messages": chatMessages,
+		"stream":   true,
+	}
+	if sessionID != "" {
+		body["user_id"] = sessionID
+	}
+	if config.Temperature > 0 {
+		body["temperature"] = config.Temperature
+	}
+	if config.TopP > 0 {
+		body["top_p"] = config.TopP
+	}
+	if config.MaxTokens > 0 {
+		body["max_tokens"] = config.MaxTokens
+	}
+	if allTools := p.buildTools(tools); len(allTools) > 0 {
+		body["tools"] = allTools
+	}
+	// 角色扮演模型的人设：user_info / bot_info / bot_name / user_name
+	if meta, ok := config.Extra["meta"].(map[string]interface{}); ok {
+		body["meta"] = meta
+	}
+	// glm-4.5 等推理模型可关闭深度思考以降低首字延迟
+	if thinking := config.GetString("thinking", ""); thinking != "" {
+		body["thinking"] = map[string]string{"type": thinking}
+	}
+	return body
+}
+
+// chat 发起流式对话，逐片段回调文本与工具调用
+func (p *Provider) chat(ctx context.Context, sessionID string, messages []types.Message, tools []openai.Tool, output func(chunk types.Response)) error {
+	// 整个请求（含流式读取）受 timeout 约束
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout())
+	defer cancel()
+
+	payload, err := json.Marshal(p.buildRequest(sessionID, messages, tools))
+	if err != nil {
+		return fmt.Errorf("序列化请求参数失败: %v", err)
+	}
+
+	// 仅对建立流式请求重试，已开始输出后不再重试
+	resp, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(payload))
+		if err != nil {
+			return nil, utils.Permanent(fmt.Errorf("创建请求失败: %v", err))
+		}
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		return utils.DoHTTP(p.client, req)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var streamErr error
+	readErr := llm.ReadSSE(resp.Body, func(event llm.SSEEvent) bool {
+		if event.Data == "[DONE]" {
+			return false
+		}
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+			return true
+		}
+		if chunk.Error != nil {
+			streamErr = fmt.Errorf("流式响应错误(%s): %s", chunk.Error.Code, chunk.Error.Message)
+			return false
+		}
+		// 用量随最后一个片段返回，智谱自动缓存相同前缀，命中部分记为 cached_tokens
+		if chunk.Usage != nil {
+			output(types.Response{Usage: &types.Usage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				CachedTokens:     chunk.Usage.PromptTokensDetails.CachedTokens,
+			}})
+		}
+		if len(chunk.Choices) == 0 {
+			return true
+		}
+
+		delta := chunk.Choices[0].Delta
+		response := types.Response{Content: delta.Content}
+		for _, tc := range delta.ToolCalls {
+			// web_search 在服务端执行，只把函数调用交给本服务处理
+			if tc.Type != "" && tc.Type != "function" {
+				continue
+			}
+			response.ToolCalls = append(response.ToolCalls, types.ToolCall{
+				ID:       tc.ID,
+				Type:     "function",
+				Index:    tc.Index,
+				Function: tc.Function,
+			})
+		}
+		if response.Content != "" || len(response.ToolCalls) > 0 {
+			output(response)
+		}
+		// 内容触发安全审核时智谱会中断输出
+		if chunk.Choices[0].FinishReason == "sensitive" {
+			streamErr = fmt.Errorf("内容触发安全审核")
+			return false
+		}
+		return true
+	})
+	if streamErr != nil {
+		return streamErr
+	}
+	if readErr != nil {
+		return fmt.Errorf("读取流式响应失败: %v", readErr)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 提供者能力，写入元数据供健康检查与配置校验展示
+const (
+	CapabilityStreaming    = "streaming"     // 流式识别或流式输出
+	CapabilityLocal        = "local"         // 本地推理，不依赖外部服务
+	CapabilityVoices       = "voices"        // 可切换音色
+	CapabilityFunctionCall = "function_call" // 支持函数调用
+	CapabilityVision       = "vision"        // 支持图片输入
+)
+
+// ProviderMeta 提供者元数据，Name 为配置中的 type
+type ProviderMeta struct {
+	Kind         string   `json:"kind"` // ASR/TTS/LLM/VLLLM，注册时填充
+	Name         string   `json:"name"`
+	Description  string   `json:"description,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+	Required     []string `json:"required,omitempty"` // 必填的配置项，创建实例前统一校验
+}
+
+// Has 判断提供者是否具备某项能力
+func (m ProviderMeta) Has(capability string) bool {
+	for _, c := range m.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+type registryEntry[F any] struct {
+	meta    ProviderMeta
+	factory F
+}
+
+// Registry 某一类提供者的工厂注册表，F 为该类提供者的工厂函数类型
+type Registry[F any] struct {
+	kind    string
+	mu      sync.RWMutex
+	entries map[string]registryEntry[F]
+}
+
+// metaLister 供全局目录按类别列出元数据，屏蔽工厂函数类型
+type metaLister interface {
+	metas() []ProviderMeta
+	lookupMeta(name string) (ProviderMeta, bool)
+}
+
+var (
+	catalogMu sync.RWMutex
+	catalog   = make(map[string]metaLister)
+)
+
+// NewRegistry 创建某一类提供者的注册表，并加入全局目录
+func NewRegistry[F any](kind string) *Registry[F] {
+	r := &Registry[F]{kind: kind, entries: make(map[string]registryEntry[F])}
+	catalogMu.Lock()
+	catalog[kind] = r
+	catalogMu.Unlock()
+	return r
+}
+
+// Register 注册提供者工厂，同名的后注册覆盖先注册
+func (r *Registry[F]) Register(meta ProviderMeta, factory F) {
+	meta.Kind = r.kind
+	r.mu.Lock()
+	r.entries[meta.Name] = registryEntry[F]{meta: meta, factory: factory}
+	r.mu.Unlock()
+}
+
+// Lookup 查找提供者工厂与元数据
+func (r *Registry[F]) Lookup(name string) (F, ProviderMeta, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entry, ok := r.entries[name]
+	return entry.factory, entry.meta, ok
+}
+
+// Names 返回已注册的提供者名称，按名称排序
+func (r *Registry[F]) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.entries))
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UnknownError 未注册提供者的错误，附带已注册的名称便于排查配置
+func (r *Registry[F]) UnknownError(name string) error {
+	return fmt.Errorf("未知的%s提供者: %s，已注册: %s", r.kind, name, strings.Join(r.Names(), ", "))
+}
+
+func (r *Registry[F]) metas() []ProviderMeta {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	metas := make([]ProviderMeta, 0, len(r.entries))
+	for _, entry := range r.entries {
+		metas = append(metas, entry.meta)
+	}
+	sort.Slice(metas, func(i, j int) bool { return metas[i].Name < metas[j].Name })
+	return metas
+}
+
+func (r *Registry[F]) lookupMeta(name string) (ProviderMeta, bool) {
+	_, meta, ok := r.Lookup(name)
+	return meta, ok
+}
+
+// ListProviders 列出某一类已注册提供者的元数据，kind 为空时列出全部
+func ListProviders(kind string) []ProviderMeta {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	if kind != "" {
+		if r, ok := catalog[kind]; ok {
+			return r.metas()
+		}
+		return nil
+	}
+	kinds := make([]string, 0, len(catalog))
+	for k := range catalog {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	var all []ProviderMeta
+	for _, k := range kinds {
+		all = append(all, catalog[k].metas()...)
+	}
+	return all
+}
+
+// LookupProvider 按类别与名称查找提供者元数据
+func LookupProvider(kind, name string) (ProviderMeta, bool) {
+	catalogMu.RLock()
+	r, ok := catalog[kind]
+	catalogMu.RUnlock()
+	if !ok {
+		return ProviderMeta{}, false
+	}
+	return r.lookupMeta(name)
+}
+
+// CheckRequired 校验必填配置项，values 为配置展开后的键值，值为空字符串视为未配置
+func CheckRequired(meta ProviderMeta, values map[string]interface{}) error {
+	var missing []string
+	for _, key := range meta.Required {
+		v, ok := values[key]
+		if s, isString := v.(string); !ok || v == nil || (isString && strings.TrimSpace(s) == "") {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s提供者 %s 缺少配置: %s", meta.Kind, meta.Name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ConfigValues 按 yaml 标签把配置结构展开为键值，供 CheckRequired 使用
+func ConfigValues(config interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return values
+	}
+	_ = yaml.Unmarshal(data, &values)
+	return values
+}
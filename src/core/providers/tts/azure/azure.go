@@ -0,0 +1,242 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	defaultVoice        = "zh-CN-XiaoxiaoNeural"
+	defaultOutputFormat = "audio-24khz-48kbitrate-mono-mp3" // 与下发的24k采样率一致，无需重采样
+	userAgent           = "xiaozhi-server-go"
+)
+
+// Ensure Provider implements tts.Provider and tts.VoiceLister interface
+var (
+	_ tts.Provider    = (*Provider)(nil)
+	_ tts.VoiceLister = (*Provider)(nil)
+)
+
+// Provider 微软 Azure 神经语音 TTS 提供者
+// 文本按配置的 voice/style/prosody 组装为 SSML 后调用 REST 合成接口；
+// 文本本身以 <speak 开头时视为完整 SSML 直接提交，便于按句指定情绪或混合多语言
+type Provider struct {
+	*tts.BaseProvider
+	apiKey   string
+	endpoint string
+	format   string
+	client   *http.Client
+}
+
+// NewProvider 创建 Azure TTS 提供者
+func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
+	apiKey := config.GetString("api_key", os.Getenv("AZURE_SPEECH_KEY"))
+	if apiKey == "" {
+		return nil, fmt.Errorf("Azure TTS 缺少 api_key 配置")
+	}
+	endpoint := config.GetString("endpoint", "")
+	if endpoint == "" {
+		region := config.GetString("region", os.Getenv("AZURE_SPEECH_REGION"))
+		if region == "" {
+			return nil, fmt.Errorf("Azure TTS 缺少 region 配置")
+		}
+		endpoint = fmt.Sprintf("https://%s.tts.speech.microsoft.com", region)
+	}
+
+	p := &Provider{
+		BaseProvider: tts.NewBaseProvider(config, deleteFile),
+		apiKey:       apiKey,
+		endpoint:     strings.TrimRight(endpoint, "/"),
+		format:       config.GetString("output_format", defaultOutputFormat),
+	}
+	p.client = &http.Client{Timeout: p.Timeout()}
+	return p, nil
+}
+
+// voice 获取当前音色，未配置时使用默认音色
+func (p *Provider) voice() string {
+	if voice := p.Voice(); voice != "" {
+		return voice
+	}
+	return defaultVoice
+}
+
+// voiceLocale 从音色名称中解析语言，如 zh-CN-XiaoxiaoNeural -> zh-CN
+func voiceLocale(voice string) string {
+	parts := strings.SplitN(voice, "-", 3)
+	if len(parts) < 3 {
+		return "zh-CN"
+	}
+	return parts[0] + "-" + parts[1]
+}
+
+// escape 转义 SSML 中的特殊字符
+func escape(text string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(text))
+	return sb.String()
+}
+
+// buildSSML 按配置组装 SSML
+// language 与音色语言不同时用 <lang> 包裹，供 Multilingual 音色切换朗读语言
+func (p *Provider) buildSSML(text string) string {
+	cfg := p.Config()
+	voice := p.voice()
+	locale := voiceLocale(voice)
+
+	content := escape(text)
+	var prosody []string
+	for _, key := range []string{"rate", "pitch", "volume"} {
+		if v := cfg.GetString(key, ""); v != "" {
+			prosody = append(prosody, fmt.Sprintf(`%s="%s"`, key, escape(v)))
+		}
+	}
+	if len(prosody) > 0 {
+		content = fmt.Sprintf("<prosody %s>%s</prosody>", strings.Join(prosody, " "), content)
+	}
+	if lang := cfg.GetString("language", ""); lang != "" && !strings.EqualFold(lang, locale) {
+		content = fmt.Sprintf(`<lang xml:lang="%s">%s</lang>`, escape(lang), content)
+	}
+	if style := cfg.GetString("style", ""); style != "" {
+		attrs := fmt.Sprintf(`style="%s"`, escape(style))
+		if degree := cfg.GetFloat("style_degree", 0); degree > 0 {
+			attrs += fmt.Sprintf(` styledegree="%.2f"`, degree)
+		}
+		if role := cfg.GetString("role", ""); role != "" {
+			attrs += fmt.Sprintf(` role="%s"`, escape(role))
+		}
+		content = fmt.Sprintf("<mstts:express-as %s>%s</mstts:express-as>", attrs, content)
+	}
+
+	return fmt.Sprintf(`<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" `+
+		`xmlns:mstts="https://www.w3.org/2001/mstts" xml:lang="%s"><voice name="%s">%s</voice></speak>`,
+		locale, escape(voice), content)
+}
+
+// ToTTS 将文本转换为音频文件，并返回文件路径
+func (p *Provider) ToTTS(text string) (string, error) {
+	start := time.Now()
+	ssml := text
+	if !strings.HasPrefix(strings.TrimSpace(text), "<speak") {
+		ssml = p.buildSSML(text)
+	}
+
+	audio, err := utils.RetryWithResult(context.Background(), p.RetryPolicy(), func(int) ([]byte, error) {
+		req, err := http.NewRequest(http.MethodPost, p.endpoint+"/cognitiveservices/v1", strings.NewReader(ssml))
+		if err != nil {
+			return nil, utils.Permanent(fmt.Errorf("创建请求失败: %v", err))
+		}
+		req.Header.Set("Content-Type", "application/ssml+xml")
+		req.Header.Set("X-Microsoft-OutputFormat", p.format)
+		return p.do(req)
+	})
+	if err != nil {
+		return "", fmt.Errorf("Azure TTS 合成失败: %v", err)
+	}
+	if len(audio) == 0 {
+		return "", fmt.Errorf("Azure TTS 未返回音频数据")
+	}
+
+	ext := "mp3"
+	if strings.HasPrefix(p.format, "riff-") {
+		ext = "wav"
+	} else if strings.HasPrefix(p.format, "ogg-") {
+		ext = "ogg"
+	}
+	outputFile, err := p.OutputFile("azure_tts", ext)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(outputFile, audio, 0644); err != nil {
+		return "", fmt.Errorf("写入音频文件失败: %v", err)
+	}
+	if logger := p.Logger(); logger != nil {
+		logger.Debug(fmt.Sprintf("Azure TTS 语音合成完成，耗时: %s", time.Since(start)))
+	}
+	return outputFile, nil
+}
+
+// do 发送请求，非 2xx 响应返回 HTTPStatusError 以便按状态码决定是否重试
+func (p *Provider) do(req *http.Request) ([]byte, error) {
+	req.Header.Set("Ocp-Apim-Subscription-Key", p.apiKey)
+	req.Header.Set("User-Agent", userAgent)
+	return utils.DoHTTPBody(p.client, req)
+}
+
+// ListVoices 返回配置的音色列表；未配置时查询区域内全部神经音色，
+// 配置了 language 时只返回该语言（含支持该语言的多语言音色）
+func (p *Provider) ListVoices(ctx context.Context) ([]tts.VoiceInfo, error) {
+	if voices := p.SurportedVoices(); len(voices) > 0 {
+		return voices, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint+"/cognitiveservices/voices/list", nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	data, err := p.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("查询 Azure 音色列表失败: %v", err)
+	}
+	var list []struct {
+		ShortName           string   `json:"ShortName"`
+		LocalName           string   `json:"LocalName"`
+		Locale              string   `json:"Locale"`
+		Gender              string   `json:"Gender"`
+		StyleList           []string `json:"StyleList"`
+		SecondaryLocaleList []string `json:"SecondaryLocaleList"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("解析音色列表失败: %v", err)
+	}
+
+	language := p.Config().GetString("language", "")
+	voices := make([]tts.VoiceInfo, 0, len(list))
+	for _, v := range list {
+		if language != "" && !strings.EqualFold(v.Locale, language) && !containsFold(v.SecondaryLocaleList, language) {
+			continue
+		}
+		voice := tts.VoiceInfo{
+			Name:        v.ShortName,
+			DisplayName: v.LocalName,
+			Language:    v.Locale,
+			Gender:      strings.ToLower(v.Gender),
+		}
+		if len(v.StyleList) > 0 {
+			voice.Description = "支持风格: " + strings.Join(v.StyleList, ",")
+		}
+		voices = append(voices, voice)
+	}
+	return voices, nil
+}
+
+// containsFold 判断列表中是否包含忽略大小写相等的字符串
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	// 注册 Azure TTS 提供者
+	tts.RegisterProvider(providers.ProviderMeta{
+		Name:         "azure",
+		Description:  "Azure 语音合成",
+		Capabilities: []string{providers.CapabilityVoices},
+	}, func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
+		return NewProvider(config, deleteFile)
+	})
+}
@@ -0,0 +1,447 @@
+package cosyvoice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultWSURL       = "wss://dashscope.aliyuncs.com/api-ws/v1/inference"
+	defaultEnrollURL   = "https://dashscope.aliyuncs.com/api/v1/services/audio/tts/customization"
+	defaultModel       = "cosyvoice-v2"
+	defaultVoice       = "longxiaochun_v2"
+	defaultLocalVoice  = "中文女" // CosyVoice-300M-SFT 预置说话人
+	defaultLocalURL    = "http://127.0.0.1:50000"
+	defaultLocalRate   = 22050 // CosyVoice 本地服务输出采样率
+	defaultDashRate    = 24000
+	modeDashScope      = "dashscope"
+	modeLocal          = "local"
+	listVoicesPageSize = 100
+)
+
+// Ensure Provider implements tts.Provider, tts.VoiceLister and tts.VoiceCloner interface
+var (
+	_ tts.Provider    = (*Provider)(nil)
+	_ tts.VoiceLister = (*Provider)(nil)
+	_ tts.VoiceCloner = (*Provider)(nil)
+)
+
+// Provider 阿里 CosyVoice TTS 提供者
+// mode=dashscope 使用 DashScope 流式语音合成 websocket 接口，输出 mp3；
+// mode=local 调用本地部署的 CosyVoice fastapi 服务，返回的 int16 PCM 封装为 wav
+type Provider struct {
+	*tts.BaseProvider
+	mode   string
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+// dashscopeEvent DashScope websocket 服务端事件
+type dashscopeEvent struct {
+	Header struct {
+		TaskID       string `json:"task_id"`
+		Event        string `json:"event"`
+		ErrorCode    string `json:"error_code"`
+		ErrorMessage string `json:"error_message"`
+	} `json:"header"`
+}
+
+// NewProvider 创建 CosyVoice TTS 提供者
+func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
+	p := &Provider{
+		BaseProvider: tts.NewBaseProvider(config, deleteFile),
+		mode:         config.GetString("mode", modeDashScope),
+		apiKey:       config.GetString("api_key", os.Getenv("DASHSCOPE_API_KEY")),
+		model:        config.GetString("model", defaultModel),
+	}
+	p.client = &http.Client{Timeout: p.Timeout()}
+
+	switch p.mode {
+	case modeDashScope:
+		if p.apiKey == "" {
+			return nil, fmt.Errorf("CosyVoice TTS 缺少 api_key 配置")
+		}
+	case modeLocal:
+	default:
+		return nil, fmt.Errorf("CosyVoice TTS 不支持的 mode: %s", p.mode)
+	}
+	return p, nil
+}
+
+// voice 获取当前音色，未配置时使用默认音色
+func (p *Provider) voice() string {
+	if voice := p.Voice(); voice != "" {
+		return voice
+	}
+	if p.mode == modeLocal {
+		return defaultLocalVoice
+	}
+	return defaultVoice
+}
+
+// ToTTS 将文本转换为音频文件，并返回文件路径
+func (p *Provider) ToTTS(text string) (string, error) {
+	start := time.Now()
+	var (
+		path string
+		err  error
+	)
+	if p.mode == modeLocal {
+		path, err = p.synthesizeLocal(text)
+	} else {
+		path, err = p.synthesizeDashScope(text)
+	}
+	if err != nil {
+		return "", err
+	}
+	if logger := p.Logger(); logger != nil {
+		logger.Debug(fmt.Sprintf("CosyVoice 语音合成完成，耗时: %s", time.Since(start)))
+	}
+	return path, nil
+}
+
+// synthesizeDashScope 通过 DashScope 流式接口合成，边接收边写入 mp3 文件
+func (p *Provider) synthesizeDashScope(text string) (string, error) {
+	timeout := p.Timeout()
+	header := http.Header{"Authorization": []string{"bearer " + p.apiKey}}
+	dialer := websocket.Dialer{HandshakeTimeout: timeout}
+	wsURL := p.Config().GetString("ws_url", defaultWSURL)
+	conn, err := utils.RetryWithResult(context.Background(), p.RetryPolicy(), func(int) (*websocket.Conn, error) {
+		conn, resp, err := dialer.Dial(wsURL, header)
+		if err != nil && resp != nil {
+			// 握手被拒绝时按状态码判断，鉴权失败等不再重试
+			return nil, fmt.Errorf("%v: %w", err, &utils.HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+		}
+		return conn, err
+	})
+	if err != nil {
+		return "", fmt.Errorf("连接 CosyVoice 服务失败: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+
+	taskID := uuid.New().String()
+	runTask := map[string]interface{}{
+		"header": map[string]interface{}{
+			"action":    "run-task",
+			"task_id":   taskID,
+			"streaming": "duplex",
+		},
+		"payload": map[string]interface{}{
+			"task_group": "audio",
+			"task":       "tts",
+			"function":   "SpeechSynthesizer",
+			"model":      p.model,
+			"parameters": map[string]interface{}{
+				"text_type":   "PlainText",
+				"voice":       p.voice(),
+				"format":      "mp3",
+				"sample_rate": p.Config().GetInt("sample_rate", defaultDashRate),
+				"volume":      p.Config().GetInt("volume", 50),
+				"rate":        p.Config().GetFloat("rate", 1.0),
+				"pitch":       p.Config().GetFloat("pitch", 1.0),
+			},
+			"input": map[string]interface{}{},
+		},
+	}
+	if err := conn.WriteJSON(runTask); err != nil {
+		return "", fmt.Errorf("发送 run-task 失败: %v", err)
+	}
+
+	outputFile, err := p.OutputFile("cosyvoice", "mp3")
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return "", fmt.Errorf("创建音频文件失败: %v", err)
+	}
+	defer file.Close()
+
+	written := 0
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			os.Remove(outputFile)
+			return "", fmt.Errorf("读取 CosyVoice 响应失败: %v", err)
+		}
+		if msgType == websocket.BinaryMessage {
+			if _, err := file.Write(data); err != nil {
+				os.Remove(outputFile)
+				return "", fmt.Errorf("写入音频文件失败: %v", err)
+			}
+			written += len(data)
+			continue
+		}
+
+		var event dashscopeEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			continue
+		}
+		switch event.Header.Event {
+		case "task-started":
+			// 任务启动后发送文本并立即结束输入，音频随后以二进制帧流式返回
+			if err := p.sendAction(conn, taskID, "continue-task", map[string]interface{}{"text": text}); err != nil {
+				os.Remove(outputFile)
+				return "", err
+			}
+			if err := p.sendAction(conn, taskID, "finish-task", map[string]interface{}{}); err != nil {
+				os.Remove(outputFile)
+				return "", err
+			}
+		case "task-finished":
+			if written == 0 {
+				os.Remove(outputFile)
+				return "", fmt.Errorf("CosyVoice 未返回音频数据")
+			}
+			return outputFile, nil
+		case "task-failed":
+			os.Remove(outputFile)
+			return "", fmt.Errorf("CosyVoice 合成失败: %s %s", event.Header.ErrorCode, event.Header.ErrorMessage)
+		}
+	}
+}
+
+// sendAction 发送 continue-task / finish-task 指令
+func (p *Provider) sendAction(conn *websocket.Conn, taskID, action string, input map[string]interface{}) error {
+	msg := map[string]interface{}{
+		"header": map[string]interface{}{
+			"action":    action,
+			"task_id":   taskID,
+			"streaming": "duplex",
+		},
+		"payload": map[string]interface{}{
+			"input": input,
+		},
+	}
+	if err := conn.WriteJSON(msg); err != nil {
+		return fmt.Errorf("发送 %s 失败: %v", action, err)
+	}
+	return nil
+}
+
+// synthesizeLocal 调用本地 CosyVoice 服务，配置 instruct_text 时使用指令合成
+func (p *Provider) synthesizeLocal(text string) (string, error) {
+	baseURL := strings.TrimRight(p.Config().GetString("url", defaultLocalURL), "/")
+	fields := map[string]string{"tts_text": text, "spk_id": p.voice()}
+	endpoint := baseURL + "/inference_sft"
+	if instruct := p.Config().GetString("instruct_text", ""); instruct != "" {
+		endpoint = baseURL + "/inference_instruct"
+		fields["instruct_text"] = instruct
+	}
+
+	pcm, err := utils.RetryWithResult(context.Background(), p.RetryPolicy(), func(int) ([]byte, error) {
+		return p.postForm(endpoint, fields)
+	})
+	if err != nil {
+		return "", fmt.Errorf("CosyVoice 本地合成失败: %v", err)
+	}
+	if len(pcm) == 0 {
+		return "", fmt.Errorf("CosyVoice 本地服务未返回音频数据")
+	}
+
+	outputFile, err := p.OutputFile("cosyvoice", "wav")
+	if err != nil {
+		return "", err
+	}
+	wav := utils.PCMToWav(pcm, p.Config().GetInt("sample_rate", defaultLocalRate), 1, 16)
+	if err := os.WriteFile(outputFile, wav, 0644); err != nil {
+		return "", fmt.Errorf("写入音频文件失败: %v", err)
+	}
+	return outputFile, nil
+}
+
+// postForm 以 multipart 表单提交请求并读取完整响应
+func (p *Provider) postForm(endpoint string, fields map[string]string) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for k, v := range fields {
+		if err := writer.WriteField(k, v); err != nil {
+			return nil, utils.Permanent(fmt.Errorf("构造请求失败: %v", err))
+		}
+	}
+	writer.Close()
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, &body)
+	if err != nil {
+		return nil, utils.Permanent(fmt.Errorf("创建请求失败: %v", err))
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return utils.DoHTTPBody(p.client, req)
+}
+
+// ListVoices 返回配置的音色列表，并合并服务端可用音色
+// dashscope 模式追加声音复刻注册的音色，local 模式配置 voices_path 时追加本地服务的说话人列表
+func (p *Provider) ListVoices(ctx context.Context) ([]tts.VoiceInfo, error) {
+	voices := append([]tts.VoiceInfo(nil), p.SurportedVoices()...)
+	known := make(map[string]bool, len(voices))
+	for _, v := range voices {
+		known[v.Name] = true
+	}
+
+	var remote []tts.VoiceInfo
+	var err error
+	if p.mode == modeLocal {
+		remote, err = p.listLocalVoices(ctx)
+	} else {
+		remote, err = p.listEnrolledVoices(ctx)
+	}
+	if err != nil {
+		return voices, err
+	}
+	for _, v := range remote {
+		if !known[v.Name] {
+			known[v.Name] = true
+			voices = append(voices, v)
+		}
+	}
+	return voices, nil
+}
+
+// listEnrolledVoices 查询 DashScope 声音复刻注册的音色
+func (p *Provider) listEnrolledVoices(ctx context.Context) ([]tts.VoiceInfo, error) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"model": "voice-enrollment",
+		"input": map[string]interface{}{
+			"action":     "list_voice",
+			"page_index": 0,
+			"page_size":  listVoicesPageSize,
+		},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		p.Config().GetString("enroll_url", defaultEnrollURL), bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	data, err := utils.DoHTTPBody(p.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("查询 CosyVoice 复刻音色失败: %v", err)
+	}
+	var result struct {
+		Output struct {
+			VoiceList []struct {
+				VoiceID string `json:"voice_id"`
+				Status  string `json:"status"`
+			} `json:"voice_list"`
+		} `json:"output"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("解析复刻音色列表失败: %v", err)
+	}
+
+	voices := make([]tts.VoiceInfo, 0, len(result.Output.VoiceList))
+	for _, v := range result.Output.VoiceList {
+		// 审核中或失败的音色无法用于合成
+		if v.Status != "" && v.Status != "OK" {
+			continue
+		}
+		voices = append(voices, tts.VoiceInfo{Name: v.VoiceID, Description: "声音复刻音色"})
+	}
+	return voices, nil
+}
+
+// CloneVoice 调用 DashScope 声音复刻接口注册音色，返回的 voice_id 可直接用于合成
+// 复刻接口只接受公网可访问的音频地址，需要在请求中提供 AudioURL
+func (p *Provider) CloneVoice(ctx context.Context, clone tts.CloneRequest) (string, error) {
+	if p.mode != modeDashScope {
+		return "", fmt.Errorf("CosyVoice local 模式不支持声音复刻")
+	}
+	if clone.AudioURL == "" {
+		return "", fmt.Errorf("CosyVoice 声音复刻需要提供公网可访问的 audio_url")
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"model": "voice-enrollment",
+		"input": map[string]interface{}{
+			"action":       "create_voice",
+			"target_model": p.model,
+			"prefix":       p.Config().GetString("clone_prefix", "xiaozhi"),
+			"url":          clone.AudioURL,
+		},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		p.Config().GetString("enroll_url", defaultEnrollURL), bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	data, err := utils.DoHTTPBody(p.client, req)
+	if err != nil {
+		return "", fmt.Errorf("CosyVoice 声音复刻失败: %v", err)
+	}
+	var result struct {
+		Output struct {
+			VoiceID string `json:"voice_id"`
+		} `json:"output"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("解析声音复刻响应失败: %v", err)
+	}
+	if result.Output.VoiceID == "" {
+		return "", fmt.Errorf("CosyVoice 未返回复刻音色 id")
+	}
+	return result.Output.VoiceID, nil
+}
+
+// listLocalVoices 查询本地服务的说话人列表，兼容字符串数组与音色对象数组两种返回
+func (p *Provider) listLocalVoices(ctx context.Context) ([]tts.VoiceInfo, error) {
+	path := p.Config().GetString("voices_path", "")
+	if path == "" {
+		return nil, nil
+	}
+	baseURL := strings.TrimRight(p.Config().GetString("url", defaultLocalURL), "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	data, err := utils.DoHTTPBody(p.client, req)
+	if err != nil {
+		return nil, fmt.Errorf("查询 CosyVoice 本地音色失败: %v", err)
+	}
+
+	var names []string
+	if err := json.Unmarshal(data, &names); err == nil {
+		voices := make([]tts.VoiceInfo, 0, len(names))
+		for _, name := range names {
+			voices = append(voices, tts.VoiceInfo{Name: name})
+		}
+		return voices, nil
+	}
+	var voices []tts.VoiceInfo
+	if err := json.Unmarshal(data, &voices); err != nil {
+		return nil, fmt.Errorf("解析本地音色列表失败: %v", err)
+	}
+	return voices, nil
+}
+
+func init() {
+	// 注册 CosyVoice TTS 提供者
+	tts.RegisterProvider(providers.ProviderMeta{
+		Name:         "cosyvoice",
+		Description:  "CosyVoice 语音合成",
+		Capabilities: []string{providers.CapabilityVoices},
+	}, func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
+		return NewProvider(config, deleteFile)
+	})
+}
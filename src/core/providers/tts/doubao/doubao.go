@@ -3,6 +3,7 @@ package doubao
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -13,7 +14,9 @@ import (
 	"path/filepath"
 	"time"
 
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -48,6 +51,8 @@ type synResp struct {
 	IsLast bool
 }
 
+var _ tts.StreamSynthesizer = (*Provider)(nil)
+
 // Provider 豆包 TTS 提供者
 type Provider struct {
 	*tts.BaseProvider
@@ -67,13 +72,63 @@ func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
 
 // ToTTS 实现文本到语音的转换
 func (p *Provider) ToTTS(text string) (string, error) {
-	// 创建WebSocket连接
+	// 创建临时文件
+	outputDir := p.Config().OutputDir
+	if outputDir == "" {
+		outputDir = "tmp"
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("创建输出目录失败: %v", err)
+	}
+
+	tempFile := filepath.Join(outputDir, fmt.Sprintf("doubao_tts_%d.mp3", time.Now().UnixNano()))
+	var audioData []byte
+	err := p.synthesize(context.Background(), text, func(data []byte) error {
+		audioData = append(audioData, data...)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// 写入音频文件
+	if err := os.WriteFile(tempFile, audioData, 0644); err != nil {
+		return "", fmt.Errorf("写入音频文件失败: %v", err)
+	}
+
+	return tempFile, nil
+}
+
+// ToTTSStream 实现 tts.StreamSynthesizer，服务端按序号分片返回 mp3，收到一片输出一片
+func (p *Provider) ToTTSStream(ctx context.Context, text string) (*tts.Stream, error) {
+	return tts.RunStream(ctx, func(emit func([]byte) error) error {
+		return p.synthesize(ctx, text, emit)
+	})
+}
+
+// synthesize 提交一次合成请求，音频分片按到达顺序交给 emit
+func (p *Provider) synthesize(ctx context.Context, text string, emit func([]byte) error) error {
+	// 创建WebSocket连接，握手与整个合成过程都受 timeout 约束
+	timeout := p.Timeout()
 	header := http.Header{"Authorization": []string{fmt.Sprintf("Bearer;%s", p.Config().Token)}}
-	conn, _, err := websocket.DefaultDialer.Dial(p.baseURL, header)
+	dialer := websocket.Dialer{HandshakeTimeout: timeout}
+	conn, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*websocket.Conn, error) {
+		conn, resp, err := dialer.DialContext(ctx, p.baseURL, header)
+		if err != nil && resp != nil {
+			// 握手被拒绝时按状态码判断，鉴权失败等不再重试
+			return nil, fmt.Errorf("%v: %w", err, &utils.HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+		}
+		return conn, err
+	})
 	if err != nil {
-		return "", fmt.Errorf("连接WebSocket服务器失败: %v", err)
+		return fmt.Errorf("连接WebSocket服务器失败: %v", err)
 	}
 	defer conn.Close()
+	// 取消后关闭连接，使阻塞中的读取立即返回
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	conn.SetWriteDeadline(time.Now().Add(timeout))
 
 	// 准备请求参数
 	reqParams := map[string]map[string]interface{}{
@@ -86,7 +141,7 @@ func (p *Provider) ToTTS(text string) (string, error) {
 			"uid": "uid",
 		},
 		"audio": {
-			"voice_type":   p.Config().Voice,
+			"voice_type":   p.Voice(),
 			"encoding":     "mp3",
 			"speed_ratio":  1.0,
 			"volume_ratio": 1.0,
@@ -103,13 +158,13 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	// 序列化并压缩请求参数
 	jsonData, err := json.Marshal(reqParams)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求参数失败: %v", err)
+		return fmt.Errorf("序列化请求参数失败: %v", err)
 	}
 
 	var b bytes.Buffer
 	w := gzip.NewWriter(&b)
 	if _, err := w.Write(jsonData); err != nil {
-		return "", fmt.Errorf("压缩请求数据失败: %v", err)
+		return fmt.Errorf("压缩请求数据失败: %v", err)
 	}
 	w.Close()
 	compressed := b.Bytes()
@@ -124,45 +179,31 @@ func (p *Provider) ToTTS(text string) (string, error) {
 
 	// 发送请求
 	if err := conn.WriteMessage(websocket.BinaryMessage, request); err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
-	}
-
-	// 创建临时文件
-	outputDir := p.Config().OutputDir
-	if outputDir == "" {
-		outputDir = "tmp"
-	}
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return "", fmt.Errorf("创建输出目录失败: %v", err)
+		return fmt.Errorf("发送请求失败: %v", err)
 	}
 
-	tempFile := filepath.Join(outputDir, fmt.Sprintf("doubao_tts_%d.mp3", time.Now().UnixNano()))
-	var audioData []byte
-
 	// 接收音频数据
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
-			return "", fmt.Errorf("接收响应失败: %v", err)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("接收响应失败: %v", err)
 		}
 
 		resp, err := p.parseResponse(message)
 		if err != nil {
-			return "", fmt.Errorf("解析响应失败: %v", err)
+			return fmt.Errorf("解析响应失败: %v", err)
 		}
 
-		audioData = append(audioData, resp.Audio...)
+		if err := emit(resp.Audio); err != nil {
+			return err
+		}
 		if resp.IsLast {
-			break
+			return nil
 		}
 	}
-
-	// 写入音频文件
-	if err := os.WriteFile(tempFile, audioData, 0644); err != nil {
-		return "", fmt.Errorf("写入音频文件失败: %v", err)
-	}
-
-	return tempFile, nil
 }
 
 // parseResponse 解析服务器响应
@@ -212,7 +253,11 @@ func (p *Provider) parseResponse(res []byte) (resp synResp, err error) {
 }
 
 func init() {
-	tts.Register("doubao", func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
+	tts.RegisterProvider(providers.ProviderMeta{
+		Name:         "doubao",
+		Description:  "豆包（火山引擎）语音合成",
+		Capabilities: []string{providers.CapabilityStreaming},
+	}, func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
 		return NewProvider(config, deleteFile)
 	})
 }
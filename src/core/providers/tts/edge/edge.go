@@ -1,37 +1,142 @@
 package edge
 
 import (
+	"context"
+	"encoding/binary"
+	"encoding/xml"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
 
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/wujunwei928/edge-tts-go/edge_tts"
 )
 
+const (
+	defaultHost   = "speech.platform.bing.com"
+	synthesisPath = "/consumer/speech/synthesize/readaloud/edge/v1"
+	voiceCacheTTL = 6 * time.Hour
+)
+
+var (
+	// rate/volume 形如 +10%，pitch 形如 +5Hz 或 -10%
+	percentPattern = regexp.MustCompile(`^[+-]\d+%$`)
+	pitchPattern   = regexp.MustCompile(`^[+-]\d+(Hz|%)$`)
+
+	// 音色列表全局缓存，按语言筛选条件区分，连接池中的实例共享，避免每个实例都请求一次
+	voiceCacheMu sync.Mutex
+	voiceCache   = make(map[string]*cachedVoices)
+)
+
+// cachedVoices 缓存的音色列表
+type cachedVoices struct {
+	voices    []tts.VoiceInfo
+	fetchedAt time.Time
+}
+
+// Ensure Provider implements tts.Provider and tts.VoiceLister interface
+var (
+	_ tts.Provider          = (*Provider)(nil)
+	_ tts.VoiceLister       = (*Provider)(nil)
+	_ tts.StreamSynthesizer = (*Provider)(nil)
+)
+
+// node Edge 服务节点，部分地区直连不稳定时可配置其他域名或代理
+type node struct {
+	Host  string
+	Proxy string
+}
+
 // Provider Edge TTS提供者实现
 type Provider struct {
 	*tts.BaseProvider
+	rate   string
+	volume string
+	pitch  string
+	nodes  []node
+
+	mu      sync.Mutex
+	current int // 上次合成成功的节点，下次优先使用
 }
 
 // NewProvider 创建Edge TTS提供者
 func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
-	base := tts.NewBaseProvider(config, deleteFile)
-	return &Provider{
-		BaseProvider: base,
-	}, nil
+	p := &Provider{
+		BaseProvider: tts.NewBaseProvider(config, deleteFile),
+		rate:         config.GetString("rate", "+0%"),
+		volume:       config.GetString("volume", "+0%"),
+		pitch:        config.GetString("pitch", "+0Hz"),
+	}
+	if !percentPattern.MatchString(p.rate) {
+		return nil, fmt.Errorf("edge TTS rate 格式错误: %s，应形如 +10%%", p.rate)
+	}
+	if !percentPattern.MatchString(p.volume) {
+		return nil, fmt.Errorf("edge TTS volume 格式错误: %s，应形如 -20%%", p.volume)
+	}
+	if !pitchPattern.MatchString(p.pitch) {
+		return nil, fmt.Errorf("edge TTS pitch 格式错误: %s，应形如 +5Hz", p.pitch)
+	}
+
+	nodes, err := parseNodes(config.Extra["nodes"])
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		nodes = []node{{Host: defaultHost, Proxy: config.GetString("proxy", "")}}
+	}
+	p.nodes = nodes
+
+	// 未配置音色列表时后台预取，供 SurportedVoices 使用
+	if len(config.SurportedVoices) == 0 && config.GetBool("list_voices", false) {
+		go p.ListVoices(context.Background())
+	}
+	return p, nil
+}
+
+// parseNodes 解析节点配置，支持字符串(域名)或 {host, proxy} 两种写法
+func parseNodes(raw interface{}) ([]node, error) {
+	list, ok := raw.([]interface{})
+	if !ok {
+		if raw != nil {
+			return nil, fmt.Errorf("edge TTS nodes 配置格式错误")
+		}
+		return nil, nil
+	}
+	nodes := make([]node, 0, len(list))
+	for _, item := range list {
+		switch v := item.(type) {
+		case string:
+			nodes = append(nodes, node{Host: v})
+		case map[string]interface{}:
+			host, _ := v["host"].(string)
+			proxy, _ := v["proxy"].(string)
+			if host == "" {
+				host = defaultHost
+			}
+			nodes = append(nodes, node{Host: host, Proxy: proxy})
+		default:
+			return nil, fmt.Errorf("edge TTS nodes 配置格式错误: %v", item)
+		}
+	}
+	return nodes, nil
 }
 
 // ToTTS 将文本转换为音频文件，并返回文件路径
-// 使用的edge库是github.com/wujunwei928/edge-tts-go，默认使用24k采样率
+// 默认使用24k采样率的 mp3 输出
 func (p *Provider) ToTTS(text string) (string, error) {
 	// 获取配置的声音，如果未配置则使用默认值
 	edgeTTSStartTime := time.Now()
-	voice := p.BaseProvider.Config().Voice
-	if voice == "" {
-		voice = "zh-CN-XiaoxiaoNeural" // 默认声音
-	}
+	voice := p.voice()
 
 	// 创建临时文件路径用于保存 edgeTTS 生成的 MP3
 	outputDir := p.BaseProvider.Config().OutputDir
@@ -45,45 +150,299 @@ func (p *Provider) ToTTS(text string) (string, error) {
 	// Use a unique filename
 	tempFile := filepath.Join(outputDir, fmt.Sprintf("edge_tts_go_%d.mp3", time.Now().UnixNano()))
 
-	// 配置 edge-tts-go 连接选项
-	connOptions := []edge_tts.CommunicateOption{
-		edge_tts.SetVoice(voice),
+	var audioData []byte
+	err := p.synthesize(context.Background(), text, voice, func(data []byte) error {
+		audioData = append(audioData, data...)
+		return nil
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	ttsDuration := time.Since(edgeTTSStartTime)
+	if logger := p.Logger(); logger != nil {
+		logger.Debug(fmt.Sprintf("edge-tts-go 语音合成完成，耗时: %s", ttsDuration))
 	}
 
-	// 创建 Communicate 实例
-	conn, err := edge_tts.NewCommunicate(text, connOptions...)
+	// 将音频数据写入临时文件
+	err = os.WriteFile(tempFile, audioData, 0644)
 	if err != nil {
-		return "", fmt.Errorf("创建 edge-tts-go Communicate 失败: %v", err)
+		return "", fmt.Errorf("写入音频文件 '%s' 失败: %v", tempFile, err)
+	}
+
+	// Return the path to the generated audio file
+	return tempFile, nil
+}
+
+// ToTTSStream 实现 tts.StreamSynthesizer，边接收 Edge 返回的 mp3 数据边输出
+func (p *Provider) ToTTSStream(ctx context.Context, text string) (*tts.Stream, error) {
+	voice := p.voice()
+	return tts.RunStream(ctx, func(emit func([]byte) error) error {
+		emitted := false
+		return p.synthesize(ctx, text, voice, func(data []byte) error {
+			emitted = true
+			return emit(data)
+		}, func() bool { return emitted })
+	})
+}
+
+// voice 当前音色，未配置时使用默认音色
+func (p *Provider) voice() string {
+	if voice := p.BaseProvider.Voice(); voice != "" {
+		return voice
+	}
+	return "zh-CN-XiaoxiaoNeural"
+}
+
+// synthesize 从上次成功的节点开始依次尝试，每个节点内按重试策略重试
+// 整句合成(streamed 为 nil)时每次尝试的音频先暂存，成功后一次交给 onAudio；
+// 流式合成时音频按到达顺序交给 onAudio，streamed 返回 true 表示已输出部分音频，此后失败不再重试与切换节点
+func (p *Provider) synthesize(ctx context.Context, text, voice string, onAudio func([]byte) error, streamed func() bool) error {
+	p.mu.Lock()
+	start := p.current
+	p.mu.Unlock()
+
+	var lastErr error
+	for i := range p.nodes {
+		idx := (start + i) % len(p.nodes)
+		n := p.nodes[idx]
+		// Edge 服务偶发断连，网络错误时整体重试
+		err := utils.Retry(ctx, p.RetryPolicy(), func(int) error {
+			var received []byte
+			emit := onAudio
+			if streamed == nil {
+				// 整句合成只在本次尝试成功后交出音频，失败重试时丢弃不完整的数据
+				emit = func(data []byte) error {
+					received = append(received, data...)
+					return nil
+				}
+			}
+			if err := p.stream(ctx, n, text, voice, emit); err != nil {
+				if streamed != nil && streamed() {
+					return utils.Permanent(err)
+				}
+				return err
+			}
+			if streamed == nil {
+				return onAudio(received)
+			}
+			return nil
+		})
+		if err == nil {
+			if idx != start {
+				if logger := p.Logger(); logger != nil {
+					logger.Info(fmt.Sprintf("edge-tts 已切换到节点 %s", n.Host))
+				}
+				p.mu.Lock()
+				p.current = idx
+				p.mu.Unlock()
+			}
+			return nil
+		}
+		lastErr = err
+		if streamed != nil && streamed() {
+			return err
+		}
+		if logger := p.Logger(); logger != nil && len(p.nodes) > 1 {
+			logger.Warn(fmt.Sprintf("edge-tts 节点 %s 合成失败: %v", n.Host, err))
+		}
+	}
+	return lastErr
+}
+
+// stream 通过指定节点执行一次合成，音频数据按到达顺序交给 emit
+func (p *Provider) stream(ctx context.Context, n node, text, voice string, emit func([]byte) error) error {
+	timeout := p.Timeout()
+	dialer := websocket.Dialer{
+		Proxy:             http.ProxyFromEnvironment,
+		HandshakeTimeout:  timeout,
+		EnableCompression: true,
+	}
+	if n.Proxy != "" {
+		proxyURL, err := url.Parse(n.Proxy)
+		if err != nil {
+			return utils.Permanent(fmt.Errorf("edge TTS 代理地址无效: %v", err))
+		}
+		dialer.Proxy = http.ProxyURL(proxyURL)
+	}
+	header := http.Header{}
+	for k, v := range edge_tts.WSS_HEADERS {
+		header.Set(k, v)
 	}
 
-	// 获取音频流数据
-	audioData, err := conn.Stream()
+	connectionID := strings.ReplaceAll(uuid.New().String(), "-", "")
+	query := url.Values{
+		"TrustedClientToken": {edge_tts.TRUSTED_CLIENT_TOKEN},
+		"Sec-MS-GEC":         {edge_tts.GenerateSecMSGec()},
+		"Sec-MS-GEC-Version": {edge_tts.SEC_MS_GEC_VERSION},
+		"ConnectionId":       {connectionID},
+	}
+	wsURL := fmt.Sprintf("wss://%s%s?%s", n.Host, synthesisPath, query.Encode())
+	conn, resp, err := dialer.DialContext(ctx, wsURL, header)
+	if err != nil && resp != nil && resp.StatusCode == http.StatusForbidden {
+		// 403 多为本机时钟偏差导致令牌失效，按服务端时间校正后重新生成令牌
+		if edge_tts.HandleClientResponseError(resp) == nil {
+			query.Set("Sec-MS-GEC", edge_tts.GenerateSecMSGec())
+			wsURL = fmt.Sprintf("wss://%s%s?%s", n.Host, synthesisPath, query.Encode())
+			conn, resp, err = dialer.DialContext(ctx, wsURL, header)
+		}
+	}
 	if err != nil {
-		return "", fmt.Errorf("edge-tts-go 获取音频流失败: %v", err)
+		if resp != nil {
+			return fmt.Errorf("%v: %w", err, &utils.HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status})
+		}
+		return fmt.Errorf("edge-tts 连接失败: %w", err)
 	}
+	defer conn.Close()
+	// 取消后关闭连接，使阻塞中的读取立即返回
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	conn.SetWriteDeadline(time.Now().Add(timeout))
 
-	ttsDuration := time.Since(edgeTTSStartTime)
-	fmt.Println(fmt.Sprintf("edge-tts-go 语音合成完成，耗时: %s", ttsDuration))
+	timestamp := time.Now().UTC().Format("Mon Jan 02 2006 15:04:05 GMT+0000 (Coordinated Universal Time)")
+	speechConfig := fmt.Sprintf("X-Timestamp:%s\r\nContent-Type:application/json; charset=utf-8\r\nPath:speech.config\r\n\r\n"+
+		`{"context":{"synthesis":{"audio":{"metadataoptions":{"sentenceBoundaryEnabled":"false","wordBoundaryEnabled":"false"},`+
+		`"outputFormat":"audio-24khz-48kbitrate-mono-mp3"}}}}`+"\r\n", timestamp)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(speechConfig)); err != nil {
+		return fmt.Errorf("edge-tts 发送配置失败: %w", err)
+	}
+	ssml := fmt.Sprintf("X-RequestId:%s\r\nContent-Type:application/ssml+xml\r\nX-Timestamp:%sZ\r\nPath:ssml\r\n\r\n%s",
+		connectionID, timestamp, p.buildSSML(text, voice))
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(ssml)); err != nil {
+		return fmt.Errorf("edge-tts 发送文本失败: %w", err)
+	}
 
-	// 将音频数据写入临时文件
-	err = os.WriteFile(tempFile, audioData, 0644)
+	received := 0
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return utils.Permanent(ctx.Err())
+			}
+			return fmt.Errorf("edge-tts 获取音频流失败: %w", err)
+		}
+		switch msgType {
+		case websocket.TextMessage:
+			if strings.Contains(string(data), "Path:turn.end") {
+				if received == 0 {
+					return fmt.Errorf("edge-tts 未返回音频数据，请检查音色 %s 是否可用", voice)
+				}
+				return nil
+			}
+		case websocket.BinaryMessage:
+			// 二进制消息前2字节为消息头长度，消息头之后为音频数据
+			if len(data) < 2 {
+				return fmt.Errorf("edge-tts 音频消息缺少消息头")
+			}
+			headerLength := int(binary.BigEndian.Uint16(data[:2]))
+			if len(data) < headerLength+2 {
+				return fmt.Errorf("edge-tts 音频消息不完整")
+			}
+			if audio := data[2+headerLength:]; len(audio) > 0 {
+				received += len(audio)
+				if err := emit(audio); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// buildSSML 组装带语速、音量、语调的 SSML，文本需转义
+func (p *Provider) buildSSML(text, voice string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(text))
+	return fmt.Sprintf("<speak version='1.0' xmlns='http://www.w3.org/2001/10/synthesis' xml:lang='en-US'>"+
+		"<voice name='%s'><prosody pitch='%s' rate='%s' volume='%s'>%s</prosody></voice></speak>",
+		voice, p.pitch, p.rate, p.volume, escaped.String())
+}
+
+// SurportedVoices 获取可切换的音色列表，未配置时使用拉取到的 Edge 音色
+func (p *Provider) SurportedVoices() []tts.VoiceInfo {
+	if voices := p.BaseProvider.SurportedVoices(); len(voices) > 0 {
+		return voices
+	}
+	voiceCacheMu.Lock()
+	defer voiceCacheMu.Unlock()
+	if cached := voiceCache[strings.Join(p.locales(), ",")]; cached != nil {
+		return cached.voices
+	}
+	return nil
+}
+
+// locales 配置的音色语言筛选条件
+func (p *Provider) locales() []string {
+	var locales []string
+	if raw, ok := p.Config().Extra["voice_locales"].([]interface{}); ok {
+		for _, v := range raw {
+			if s, ok := v.(string); ok {
+				locales = append(locales, s)
+			}
+		}
+	}
+	return locales
+}
+
+// ListVoices 返回配置的音色列表；未配置时拉取 Edge 全部音色，
+// 按 voice_locales(如 zh-CN, en-US)筛选，结果缓存 6 小时
+func (p *Provider) ListVoices(ctx context.Context) ([]tts.VoiceInfo, error) {
+	if voices := p.BaseProvider.SurportedVoices(); len(voices) > 0 {
+		return voices, nil
+	}
+
+	locales := p.locales()
+	key := strings.Join(locales, ",")
+	voiceCacheMu.Lock()
+	defer voiceCacheMu.Unlock()
+	cached := voiceCache[key]
+	if cached != nil && time.Since(cached.fetchedAt) < voiceCacheTTL {
+		return cached.voices, nil
+	}
+
+	// edge-tts-go 不支持 context，音色列表请求不受 ctx 控制
+	list, err := edge_tts.ListVoices(p.nodes[0].Proxy)
 	if err != nil {
-		return "", fmt.Errorf("写入音频文件 '%s' 失败: %v", tempFile, err)
+		if cached != nil {
+			return cached.voices, fmt.Errorf("拉取 Edge 音色列表失败: %v", err)
+		}
+		return nil, fmt.Errorf("拉取 Edge 音色列表失败: %v", err)
 	}
 
-	// 检查文件是否成功创建
-	if _, err := os.Stat(tempFile); os.IsNotExist(err) {
-		return "", fmt.Errorf("edge-tts-go 未能创建音频文件: %s", tempFile)
+	voices := make([]tts.VoiceInfo, 0, len(list))
+	for _, v := range list {
+		if len(locales) > 0 && !matchLocale(v.Locale, locales) {
+			continue
+		}
+		voices = append(voices, tts.VoiceInfo{
+			Name:        v.ShortName,
+			DisplayName: v.FriendlyName,
+			Language:    v.Locale,
+			Gender:      strings.ToLower(v.Gender),
+			Description: strings.Join(v.VoiceTag.VoicePersonalities, ","),
+		})
 	}
-	//fmt.Printf("音频文件已生成: %s\n", tempFile)
+	voiceCache[key] = &cachedVoices{voices: voices, fetchedAt: time.Now()}
+	return voices, nil
+}
 
-	// Return the path to the generated audio file
-	return tempFile, nil
+// matchLocale 判断音色语言是否匹配，zh 可匹配 zh-CN、zh-TW 等
+func matchLocale(locale string, locales []string) bool {
+	for _, l := range locales {
+		if strings.EqualFold(locale, l) || strings.HasPrefix(strings.ToLower(locale), strings.ToLower(l)+"-") {
+			return true
+		}
+	}
+	return false
 }
 
 func init() {
 	// 注册Edge TTS提供者
-	tts.Register("edge", func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
+	tts.RegisterProvider(providers.ProviderMeta{
+		Name:         "edge",
+		Description:  "Edge 在线语音合成",
+		Capabilities: []string{providers.CapabilityVoices, providers.CapabilityStreaming},
+	}, func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
 		return NewProvider(config, deleteFile)
 	})
 }
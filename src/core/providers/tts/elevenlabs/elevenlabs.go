@@ -0,0 +1,277 @@
+package elevenlabs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	defaultBaseURL      = "https://api.elevenlabs.io"
+	defaultModel        = "eleven_multilingual_v2"
+	defaultOutputFormat = "mp3_44100_128"
+	defaultVoice        = "JBFqnCBsd6RMkjVDRZzb" // 官方预置音色 George
+	streamReadSize      = 4096
+)
+
+// Ensure Provider implements tts.Provider, tts.VoiceLister and tts.StreamSynthesizer interface
+var (
+	_ tts.Provider          = (*Provider)(nil)
+	_ tts.VoiceLister       = (*Provider)(nil)
+	_ tts.StreamSynthesizer = (*Provider)(nil)
+)
+
+// Provider ElevenLabs TTS 提供者
+// voice 配置为 voice_id；output_format 形如 mp3_44100_128 或 pcm_24000，pcm 输出封装为 wav 进入音频管线
+type Provider struct {
+	*tts.BaseProvider
+	apiKey  string
+	baseURL string
+	model   string
+	format  string
+	client  *http.Client
+}
+
+// NewProvider 创建 ElevenLabs TTS 提供者
+func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
+	apiKey := config.GetString("api_key", os.Getenv("ELEVENLABS_API_KEY"))
+	if apiKey == "" {
+		return nil, fmt.Errorf("ElevenLabs TTS 缺少 api_key 配置")
+	}
+	format := config.GetString("output_format", defaultOutputFormat)
+	if !strings.HasPrefix(format, "mp3_") && !strings.HasPrefix(format, "pcm_") {
+		return nil, fmt.Errorf("ElevenLabs TTS 不支持的输出格式: %s，仅支持 mp3_* 与 pcm_*", format)
+	}
+
+	// 合成总时长由 ToTTS 的 context 控制，client 不设置超时，流式合成不受限制
+	return &Provider{
+		BaseProvider: tts.NewBaseProvider(config, deleteFile),
+		apiKey:       apiKey,
+		baseURL:      strings.TrimRight(config.GetString("base_url", defaultBaseURL), "/"),
+		model:        config.GetString("model", defaultModel),
+		format:       format,
+		client:       &http.Client{},
+	}, nil
+}
+
+// voice 当前音色，未配置时使用默认音色
+func (p *Provider) voice() string {
+	if voice := p.Voice(); voice != "" {
+		return voice
+	}
+	return defaultVoice
+}
+
+// request 构造合成请求体，未配置的声音参数使用音色默认值
+func (p *Provider) request(text string) map[string]interface{} {
+	cfg := p.Config()
+	req := map[string]interface{}{
+		"text":     text,
+		"model_id": p.model,
+	}
+	if language := cfg.GetString("language", ""); language != "" {
+		req["language_code"] = language
+	}
+
+	settings := map[string]interface{}{}
+	for _, key := range []string{"stability", "similarity_boost", "style", "speed"} {
+		if v := cfg.GetFloat(key, -1); v >= 0 {
+			settings[key] = v
+		}
+	}
+	if len(settings) > 0 {
+		req["voice_settings"] = settings
+	}
+	return req
+}
+
+// post 提交合成请求，只在拿到响应头之前重试；path 为 "" 或 "/stream"
+func (p *Provider) post(ctx context.Context, text, path string) (*http.Response, error) {
+	payload, err := json.Marshal(p.request(text))
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求参数失败: %v", err)
+	}
+	endpoint := fmt.Sprintf("%s/v1/text-to-speech/%s%s?%s", p.baseURL, url.PathEscape(p.voice()), path,
+		url.Values{"output_format": {p.format}}.Encode())
+	return utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return nil, utils.Permanent(fmt.Errorf("创建请求失败: %v", err))
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return p.do(req)
+	})
+}
+
+// ToTTS 将文本转换为音频文件，并返回文件路径
+func (p *Provider) ToTTS(text string) (string, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout())
+	defer cancel()
+
+	resp, err := p.post(ctx, text, "")
+	if err != nil {
+		return "", fmt.Errorf("ElevenLabs 合成失败: %v", err)
+	}
+	defer resp.Body.Close()
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("接收音频失败: %v", err)
+	}
+	if len(audio) == 0 {
+		return "", fmt.Errorf("ElevenLabs 未返回音频数据")
+	}
+
+	ext := "mp3"
+	if rate, ok := p.pcmRate(); ok {
+		ext = "wav"
+		audio = utils.PCMToWav(audio, rate, 1, 16)
+	}
+	outputFile, err := p.OutputFile("elevenlabs", ext)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(outputFile, audio, 0644); err != nil {
+		return "", fmt.Errorf("写入音频文件失败: %v", err)
+	}
+	if logger := p.Logger(); logger != nil {
+		logger.Debug(fmt.Sprintf("ElevenLabs 语音合成完成，耗时: %s", time.Since(start)))
+	}
+	return outputFile, nil
+}
+
+// ToTTSStream 实现 tts.StreamSynthesizer，调用流式接口边接收边输出，仅 mp3 输出格式支持
+func (p *Provider) ToTTSStream(ctx context.Context, text string) (*tts.Stream, error) {
+	if _, ok := p.pcmRate(); ok {
+		return nil, fmt.Errorf("ElevenLabs 流式合成仅支持 mp3 输出格式")
+	}
+	return tts.RunStream(ctx, func(emit func([]byte) error) error {
+		resp, err := p.post(ctx, text, "/stream")
+		if err != nil {
+			return fmt.Errorf("ElevenLabs 合成失败: %v", err)
+		}
+		defer resp.Body.Close()
+		for {
+			buf := make([]byte, streamReadSize)
+			n, err := resp.Body.Read(buf)
+			if n > 0 {
+				if emitErr := emit(buf[:n]); emitErr != nil {
+					return emitErr
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("接收音频流失败: %v", err)
+			}
+		}
+	})
+}
+
+// pcmRate pcm_* 输出格式的采样率
+func (p *Provider) pcmRate() (int, bool) {
+	if !strings.HasPrefix(p.format, "pcm_") {
+		return 0, false
+	}
+	rate, err := strconv.Atoi(strings.TrimPrefix(p.format, "pcm_"))
+	if err != nil || rate <= 0 {
+		return 0, false
+	}
+	return rate, true
+}
+
+// do 发送请求，非 2xx 响应返回 HTTPStatusError 以便按状态码决定是否重试
+func (p *Provider) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("xi-api-key", p.apiKey)
+	return utils.DoHTTP(p.client, req)
+}
+
+// ListVoices 返回配置的音色列表，并合并账号可用的预置与自建音色
+func (p *Provider) ListVoices(ctx context.Context) ([]tts.VoiceInfo, error) {
+	voices := append([]tts.VoiceInfo(nil), p.SurportedVoices()...)
+	known := make(map[string]bool, len(voices))
+	for _, v := range voices {
+		known[v.Name] = true
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/v1/voices", nil)
+	if err != nil {
+		return voices, fmt.Errorf("创建请求失败: %v", err)
+	}
+	resp, err := p.do(req)
+	if err != nil {
+		return voices, fmt.Errorf("查询 ElevenLabs 音色失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Voices []struct {
+			VoiceID     string            `json:"voice_id"`
+			Name        string            `json:"name"`
+			Description string            `json:"description"`
+			Labels      map[string]string `json:"labels"`
+			Languages   []struct {
+				Language string `json:"language"`
+				Locale   string `json:"locale"`
+			} `json:"verified_languages"`
+		} `json:"voices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return voices, fmt.Errorf("解析音色列表失败: %v", err)
+	}
+	for _, item := range result.Voices {
+		if known[item.VoiceID] {
+			continue
+		}
+		known[item.VoiceID] = true
+		voice := tts.VoiceInfo{
+			Name:        item.VoiceID,
+			DisplayName: item.Name,
+			Gender:      strings.ToLower(item.Labels["gender"]),
+			Language:    item.Labels["language"],
+		}
+		if voice.Language == "" && len(item.Languages) > 0 {
+			voice.Language = item.Languages[0].Locale
+			if voice.Language == "" {
+				voice.Language = item.Languages[0].Language
+			}
+		}
+		// 标签中的描述更短，适合放进函数描述
+		var desc []string
+		for _, key := range []string{"accent", "description", "use_case"} {
+			if v := item.Labels[key]; v != "" {
+				desc = append(desc, v)
+			}
+		}
+		if len(desc) == 0 && item.Description != "" {
+			desc = append(desc, item.Description)
+		}
+		voice.Description = strings.Join(desc, ",")
+		voices = append(voices, voice)
+	}
+	return voices, nil
+}
+
+func init() {
+	// 注册 ElevenLabs TTS 提供者
+	tts.RegisterProvider(providers.ProviderMeta{
+		Name:         "elevenlabs",
+		Description:  "ElevenLabs 语音合成",
+		Capabilities: []string{providers.CapabilityVoices, providers.CapabilityStreaming},
+	}, func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
+		return NewProvider(config, deleteFile)
+	})
+}
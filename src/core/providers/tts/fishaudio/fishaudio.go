@@ -0,0 +1,301 @@
+package fishaudio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	defaultBaseURL     = "https://api.fish.audio"
+	defaultModel       = "s1"
+	defaultFormat      = "mp3"
+	defaultPCMRate     = 44100
+	listVoicesPageSize = 100
+)
+
+// Ensure Provider implements tts.Provider, tts.VoiceLister and tts.VoiceCloner interface
+var (
+	_ tts.Provider    = (*Provider)(nil)
+	_ tts.VoiceLister = (*Provider)(nil)
+	_ tts.VoiceCloner = (*Provider)(nil)
+)
+
+// Provider Fish Audio TTS 提供者
+// voice 配置为 reference_id，即 Fish Audio 上的音色模型 id，可直接使用自己克隆的音色；
+// 响应为分块传输的音频流，边接收边写入文件，format 支持 mp3 / opus / wav / pcm
+type Provider struct {
+	*tts.BaseProvider
+	apiKey  string
+	baseURL string
+	model   string
+	format  string
+	client  *http.Client
+}
+
+// NewProvider 创建 Fish Audio TTS 提供者
+func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
+	apiKey := config.GetString("api_key", os.Getenv("FISH_API_KEY"))
+	if apiKey == "" {
+		return nil, fmt.Errorf("Fish Audio TTS 缺少 api_key 配置")
+	}
+	format := defaultFormat
+	if config.Format != "" {
+		format = strings.ToLower(config.Format)
+	}
+	switch format {
+	case "mp3", "opus", "wav", "pcm":
+	default:
+		return nil, fmt.Errorf("Fish Audio TTS 不支持的输出格式: %s", format)
+	}
+
+	// 流式响应的总时长由 ToTTS 的 context 控制，client 不设置超时
+	return &Provider{
+		BaseProvider: tts.NewBaseProvider(config, deleteFile),
+		apiKey:       apiKey,
+		baseURL:      strings.TrimRight(config.GetString("base_url", defaultBaseURL), "/"),
+		model:        config.GetString("model", defaultModel),
+		format:       format,
+		client:       &http.Client{},
+	}, nil
+}
+
+// request 构造合成请求体，reference_id 为空时使用 Fish Audio 默认音色
+func (p *Provider) request(text string) map[string]interface{} {
+	cfg := p.Config()
+	req := map[string]interface{}{
+		"text":         text,
+		"format":       p.format,
+		"latency":      cfg.GetString("latency", "balanced"),
+		"normalize":    cfg.GetBool("normalize", true),
+		"chunk_length": cfg.GetInt("chunk_length", 200),
+	}
+	// 未切换音色时优先使用 reference_id 配置
+	referenceID := p.Voice()
+	if referenceID == cfg.Voice {
+		referenceID = cfg.GetString("reference_id", cfg.Voice)
+	}
+	if referenceID != "" {
+		req["reference_id"] = referenceID
+	}
+	if sampleRate := cfg.GetInt("sample_rate", 0); sampleRate > 0 {
+		req["sample_rate"] = sampleRate
+	}
+	switch p.format {
+	case "mp3":
+		req["mp3_bitrate"] = cfg.GetInt("mp3_bitrate", 128)
+	case "opus":
+		req["opus_bitrate"] = cfg.GetInt("opus_bitrate", 32)
+	}
+
+	prosody := map[string]interface{}{}
+	if speed := cfg.GetFloat("speed", 0); speed > 0 {
+		prosody["speed"] = speed
+	}
+	if volume := cfg.GetFloat("volume", 0); volume != 0 {
+		prosody["volume"] = volume
+	}
+	if len(prosody) > 0 {
+		req["prosody"] = prosody
+	}
+	return req
+}
+
+// ToTTS 将文本转换为音频文件，并返回文件路径
+func (p *Provider) ToTTS(text string) (string, error) {
+	start := time.Now()
+	payload, err := json.Marshal(p.request(text))
+	if err != nil {
+		return "", fmt.Errorf("序列化请求参数失败: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout())
+	defer cancel()
+
+	// 只在拿到响应头之前重试，开始接收音频后出错直接返回
+	resp, err := utils.RetryWithResult(ctx, p.RetryPolicy(), func(int) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/tts", bytes.NewReader(payload))
+		if err != nil {
+			return nil, utils.Permanent(fmt.Errorf("创建请求失败: %v", err))
+		}
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("model", p.model)
+		return utils.DoHTTP(p.client, req)
+	})
+	if err != nil {
+		return "", fmt.Errorf("Fish Audio 合成失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	path, err := p.save(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if logger := p.Logger(); logger != nil {
+		logger.Debug(fmt.Sprintf("Fish Audio 语音合成完成，耗时: %s", time.Since(start)))
+	}
+	return path, nil
+}
+
+// save 将音频流写入输出目录，pcm 格式封装为 wav 以便进入音频管线
+func (p *Provider) save(body io.Reader) (string, error) {
+	if p.format == "pcm" {
+		pcm, err := io.ReadAll(body)
+		if err != nil {
+			return "", fmt.Errorf("读取音频流失败: %v", err)
+		}
+		if len(pcm) == 0 {
+			return "", fmt.Errorf("Fish Audio 未返回音频数据")
+		}
+		sampleRate := p.Config().GetInt("sample_rate", defaultPCMRate)
+		outputFile, err := p.OutputFile("fishaudio", "wav")
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(outputFile, utils.PCMToWav(pcm, sampleRate, 1, 16), 0644); err != nil {
+			return "", fmt.Errorf("写入音频文件失败: %v", err)
+		}
+		return outputFile, nil
+	}
+
+	// opus 输出为 ogg 封装
+	ext := p.format
+	if ext == "opus" {
+		ext = "ogg"
+	}
+	outputFile, err := p.OutputFile("fishaudio", ext)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return "", fmt.Errorf("创建音频文件失败: %v", err)
+	}
+	written, err := io.Copy(file, body)
+	file.Close()
+	if err != nil {
+		os.Remove(outputFile)
+		return "", fmt.Errorf("接收音频流失败: %v", err)
+	}
+	if written == 0 {
+		os.Remove(outputFile)
+		return "", fmt.Errorf("Fish Audio 未返回音频数据")
+	}
+	return outputFile, nil
+}
+
+// ListVoices 返回配置的音色列表，并合并账号下自己创建的音色模型
+func (p *Provider) ListVoices(ctx context.Context) ([]tts.VoiceInfo, error) {
+	voices := append([]tts.VoiceInfo(nil), p.SurportedVoices()...)
+	known := make(map[string]bool, len(voices))
+	for _, v := range voices {
+		known[v.Name] = true
+	}
+
+	query := url.Values{"self": {"true"}, "page_size": {fmt.Sprint(listVoicesPageSize)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/model?"+query.Encode(), nil)
+	if err != nil {
+		return voices, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	resp, err := utils.DoHTTP(p.client, req)
+	if err != nil {
+		return voices, fmt.Errorf("查询 Fish Audio 音色失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Items []struct {
+			ID          string   `json:"_id"`
+			Title       string   `json:"title"`
+			Description string   `json:"description"`
+			Languages   []string `json:"languages"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return voices, fmt.Errorf("解析音色列表失败: %v", err)
+	}
+	for _, item := range result.Items {
+		if known[item.ID] {
+			continue
+		}
+		known[item.ID] = true
+		voice := tts.VoiceInfo{Name: item.ID, DisplayName: item.Title, Description: item.Description}
+		if len(item.Languages) > 0 {
+			voice.Language = item.Languages[0]
+		}
+		voices = append(voices, voice)
+	}
+	return voices, nil
+}
+
+// CloneVoice 上传参考音频创建私有音色模型，返回模型 id 作为 reference_id 使用
+func (p *Provider) CloneVoice(ctx context.Context, clone tts.CloneRequest) (string, error) {
+	if len(clone.Audio) == 0 {
+		return "", fmt.Errorf("Fish Audio 声音克隆需要上传参考音频")
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("type", "tts")
+	writer.WriteField("title", clone.Name)
+	writer.WriteField("train_mode", "fast")
+	writer.WriteField("visibility", "private")
+	writer.WriteField("enhance_audio_quality", "true")
+	if clone.Text != "" {
+		writer.WriteField("texts", clone.Text)
+	}
+	part, err := writer.CreateFormFile("voices", clone.Filename)
+	if err != nil {
+		return "", fmt.Errorf("构造请求失败: %v", err)
+	}
+	part.Write(clone.Audio)
+	writer.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/model", &body)
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := utils.DoHTTP(p.client, req)
+	if err != nil {
+		return "", fmt.Errorf("Fish Audio 创建音色失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ID string `json:"_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("解析创建音色响应失败: %v", err)
+	}
+	if result.ID == "" {
+		return "", fmt.Errorf("Fish Audio 未返回音色 id")
+	}
+	return result.ID, nil
+}
+
+func init() {
+	// 注册 Fish Audio TTS 提供者
+	tts.RegisterProvider(providers.ProviderMeta{
+		Name:         "fishaudio",
+		Description:  "Fish Audio 语音合成",
+		Capabilities: []string{providers.CapabilityVoices},
+	}, func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
+		return NewProvider(config, deleteFile)
+	})
+}
@@ -0,0 +1,192 @@
+package localhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
+)
+
+const (
+	apiGPTSoVITSV2 = "gpt_sovits_v2" // GPT-SoVITS api_v2.py: POST /tts
+	apiGPTSoVITS   = "gpt_sovits"    // GPT-SoVITS api.py: POST /
+	apiCosyVoice   = "cosyvoice"     // CosyVoice fastapi: POST /inference_zero_shot
+
+	defaultURL     = "http://127.0.0.1:9880"
+	defaultPCMRate = 22050 // 服务返回裸 PCM 时的采样率，CosyVoice 为 22050
+)
+
+// Ensure Provider implements tts.Provider interface
+var _ tts.Provider = (*Provider)(nil)
+
+// Provider 对接本地部署推理服务的 TTS 提供者，支持 GPT-SoVITS 与 CosyVoice
+// 通过参考音频与参考文本克隆音色，服务返回 wav 时原样保存，返回裸 PCM 时按 sample_rate 封装为 wav
+type Provider struct {
+	*tts.BaseProvider
+	api    string
+	url    string
+	client *http.Client
+}
+
+// NewProvider 创建本地 HTTP TTS 提供者
+func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
+	api := config.GetString("api", apiGPTSoVITSV2)
+	switch api {
+	case apiGPTSoVITSV2, apiGPTSoVITS:
+	case apiCosyVoice:
+		if config.GetString("ref_audio", "") == "" {
+			return nil, fmt.Errorf("CosyVoice 零样本合成需要配置 ref_audio")
+		}
+	default:
+		return nil, fmt.Errorf("local_http TTS 不支持的 api: %s", api)
+	}
+
+	p := &Provider{
+		BaseProvider: tts.NewBaseProvider(config, deleteFile),
+		api:          api,
+		url:          strings.TrimRight(config.GetString("url", defaultURL), "/"),
+	}
+	p.client = &http.Client{Timeout: p.Timeout()}
+	return p, nil
+}
+
+// ToTTS 将文本转换为音频文件，并返回文件路径
+func (p *Provider) ToTTS(text string) (string, error) {
+	start := time.Now()
+	audio, err := utils.RetryWithResult(context.Background(), p.RetryPolicy(), func(int) ([]byte, error) {
+		req, err := p.newRequest(text)
+		if err != nil {
+			return nil, utils.Permanent(err)
+		}
+		return utils.DoHTTPBody(p.client, req)
+	})
+	if err != nil {
+		return "", fmt.Errorf("本地 TTS 合成失败: %v", err)
+	}
+	if len(audio) == 0 {
+		return "", fmt.Errorf("本地 TTS 服务未返回音频数据")
+	}
+
+	// GPT-SoVITS 返回完整 wav，CosyVoice 返回裸 int16 PCM
+	if !bytes.HasPrefix(audio, []byte("RIFF")) {
+		audio = utils.PCMToWav(audio, p.Config().GetInt("sample_rate", defaultPCMRate), 1, 16)
+	}
+	outputFile, err := p.OutputFile("local_tts", "wav")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(outputFile, audio, 0644); err != nil {
+		return "", fmt.Errorf("写入音频文件失败: %v", err)
+	}
+	if logger := p.Logger(); logger != nil {
+		logger.Debug(fmt.Sprintf("本地 TTS(%s) 语音合成完成，耗时: %s", p.api, time.Since(start)))
+	}
+	return outputFile, nil
+}
+
+// newRequest 按服务类型构造合成请求，每次重试都需要重新构造请求体
+func (p *Provider) newRequest(text string) (*http.Request, error) {
+	cfg := p.Config()
+	refAudio := cfg.GetString("ref_audio", "")
+	promptText := cfg.GetString("prompt_text", "")
+	promptLang := cfg.GetString("prompt_lang", "zh")
+	textLang := cfg.GetString("text_lang", "zh")
+
+	switch p.api {
+	case apiGPTSoVITS:
+		return p.newJSONRequest("/", map[string]interface{}{
+			"refer_wav_path":  refAudio,
+			"prompt_text":     promptText,
+			"prompt_language": promptLang,
+			"text":            text,
+			"text_language":   textLang,
+		})
+	case apiCosyVoice:
+		return p.newZeroShotRequest(text, refAudio, promptText)
+	default:
+		body := map[string]interface{}{
+			"text":              text,
+			"text_lang":         textLang,
+			"ref_audio_path":    refAudio,
+			"prompt_text":       promptText,
+			"prompt_lang":       promptLang,
+			"text_split_method": cfg.GetString("text_split_method", "cut5"),
+			"speed_factor":      cfg.GetFloat("speed", 1.0),
+			"media_type":        "wav",
+			"streaming_mode":    false,
+		}
+		// 其他参数(top_k、temperature 等)原样透传
+		if params, ok := cfg.Extra["params"].(map[string]interface{}); ok {
+			for k, v := range params {
+				body[k] = v
+			}
+		}
+		return p.newJSONRequest("/tts", body)
+	}
+}
+
+// newJSONRequest 构造 JSON 请求
+func (p *Provider) newJSONRequest(path string, body map[string]interface{}) (*http.Request, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求参数失败: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, p.url+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// newZeroShotRequest 构造 CosyVoice 零样本合成请求，参考音频以文件形式上传
+func (p *Provider) newZeroShotRequest(text, refAudio, promptText string) (*http.Request, error) {
+	wav, err := os.ReadFile(refAudio)
+	if err != nil {
+		return nil, fmt.Errorf("读取参考音频失败: %v", err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("tts_text", text)
+	writer.WriteField("prompt_text", promptText)
+	part, err := writer.CreateFormFile("prompt_wav", filepath.Base(refAudio))
+	if err != nil {
+		return nil, fmt.Errorf("构造请求失败: %v", err)
+	}
+	part.Write(wav)
+	writer.Close()
+
+	req, err := http.NewRequest(http.MethodPost, p.url+"/inference_zero_shot", &body)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req, nil
+}
+
+// SetVoice 音色由参考音频决定，不支持按名称切换
+func (p *Provider) SetVoice(voice string) error {
+	return fmt.Errorf("local_http TTS 音色由 ref_audio 决定，不支持切换为 %s", voice)
+}
+
+func init() {
+	// 注册本地 HTTP TTS 提供者
+	tts.RegisterProvider(providers.ProviderMeta{
+		Name:         "local_http",
+		Description:  "本地 HTTP 合成服务（GPT-SoVITS 等）",
+		Capabilities: []string{providers.CapabilityLocal},
+	}, func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
+		return NewProvider(config, deleteFile)
+	})
+}
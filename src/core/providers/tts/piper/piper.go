@@ -0,0 +1,257 @@
+package piper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/providers/tts"
+)
+
+// Ensure Provider implements tts.Provider interface
+var _ tts.Provider = (*Provider)(nil)
+
+// Provider 调用 piper 可执行文件离线合成的 TTS 提供者，输出 22050Hz wav
+// persistent 模式下常驻一个 --json-input 进程，模型只加载一次，逐行提交文本并读取输出文件路径；
+// 否则每次合成启动一个 piper 进程，适合调用不频繁的场景
+type Provider struct {
+	*tts.BaseProvider
+	binary     string
+	args       []string
+	speakerID  int
+	persistent bool
+
+	mu     sync.Mutex // piper 进程按行串行处理请求
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bytes.Buffer
+	done   chan struct{}
+}
+
+// NewProvider 创建 piper TTS 提供者
+func NewProvider(config *tts.Config, deleteFile bool) (*Provider, error) {
+	binary := config.GetString("piper_path", "piper")
+	if _, err := exec.LookPath(binary); err != nil {
+		return nil, fmt.Errorf("未找到 piper 可执行文件 %s: %v", binary, err)
+	}
+	model := config.GetString("model_path", "")
+	if model == "" {
+		return nil, fmt.Errorf("piper TTS 缺少 model_path 配置")
+	}
+	if _, err := os.Stat(model); err != nil {
+		return nil, fmt.Errorf("piper 模型文件不可用: %v", err)
+	}
+
+	args := []string{"--model", model}
+	if modelConfig := config.GetString("config_path", ""); modelConfig != "" {
+		args = append(args, "--config", modelConfig)
+	}
+	for _, key := range []string{"length_scale", "noise_scale", "noise_w", "sentence_silence"} {
+		if v := config.GetFloat(key, 0); v > 0 {
+			args = append(args, "--"+key, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+	if config.GetBool("use_cuda", false) {
+		args = append(args, "--cuda")
+	}
+
+	return &Provider{
+		BaseProvider: tts.NewBaseProvider(config, deleteFile),
+		binary:       binary,
+		args:         args,
+		speakerID:    config.GetInt("speaker_id", -1),
+		persistent:   config.GetBool("persistent", true),
+	}, nil
+}
+
+// ToTTS 将文本转换为音频文件，并返回文件路径
+func (p *Provider) ToTTS(text string) (string, error) {
+	start := time.Now()
+	outputFile, err := p.OutputFile("piper", "wav")
+	if err != nil {
+		return "", err
+	}
+	// piper 以换行分隔输入，合成前合并为单行
+	text = strings.Join(strings.Fields(text), " ")
+
+	if p.persistent {
+		err = p.synthesizePersistent(text, outputFile)
+	} else {
+		err = p.synthesizeOnce(text, outputFile)
+	}
+	if err != nil {
+		os.Remove(outputFile)
+		return "", err
+	}
+	if logger := p.Logger(); logger != nil {
+		logger.Debug(fmt.Sprintf("piper 语音合成完成，耗时: %s", time.Since(start)))
+	}
+	return outputFile, nil
+}
+
+// speaker 获取当前说话人编号，切换的音色优先于 speaker_id 配置
+func (p *Provider) speaker() int {
+	if id, err := strconv.Atoi(p.Voice()); err == nil {
+		return id
+	}
+	return p.speakerID
+}
+
+// SetVoice 切换说话人，piper 多说话人模型的音色为说话人编号
+func (p *Provider) SetVoice(voice string) error {
+	if _, err := strconv.Atoi(voice); err != nil {
+		return fmt.Errorf("piper 音色需为说话人编号: %s", voice)
+	}
+	return p.BaseProvider.SetVoice(voice)
+}
+
+// synthesizeOnce 启动一次 piper 进程，通过 stdin 传入文本
+func (p *Provider) synthesizeOnce(text, outputFile string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout())
+	defer cancel()
+
+	args := append(append([]string(nil), p.args...), "--output_file", outputFile)
+	if speakerID := p.speaker(); speakerID >= 0 {
+		args = append(args, "--speaker", strconv.Itoa(speakerID))
+	}
+	cmd := exec.CommandContext(ctx, p.binary, args...)
+	cmd.Stdin = strings.NewReader(text + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("piper 合成失败: %v: %s", err, lastLine(stderr.String()))
+	}
+	return nil
+}
+
+// synthesizePersistent 向常驻进程提交一行 JSON，等待其输出生成的文件路径
+func (p *Provider) synthesizePersistent(text, outputFile string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cmd == nil {
+		if err := p.start(); err != nil {
+			return err
+		}
+	}
+
+	absOutput, err := filepath.Abs(outputFile)
+	if err != nil {
+		return fmt.Errorf("解析输出路径失败: %v", err)
+	}
+	req := map[string]interface{}{"text": text, "output_file": absOutput}
+	if speakerID := p.speaker(); speakerID >= 0 {
+		req["speaker_id"] = speakerID
+	}
+	line, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("序列化请求失败: %v", err)
+	}
+	if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+		p.stop()
+		return fmt.Errorf("写入 piper 进程失败: %v", err)
+	}
+
+	// piper 写完音频后在 stdout 输出文件路径
+	readErr := make(chan error, 1)
+	stdout := p.stdout
+	go func() {
+		_, err := stdout.ReadString('\n')
+		readErr <- err
+	}()
+
+	select {
+	case err := <-readErr:
+		if err != nil {
+			// 进程退出后 stderr 不再写入，可以安全读取
+			stderr := p.stderr
+			p.stop()
+			return fmt.Errorf("piper 进程异常退出: %v: %s", err, lastLine(stderr.String()))
+		}
+		return nil
+	case <-time.After(p.Timeout()):
+		// 超时后无法确定进程状态，重启进程以免后续请求读到错位的结果
+		p.stop()
+		return fmt.Errorf("piper 合成超时(%v)", p.Timeout())
+	}
+}
+
+// start 启动常驻 piper 进程
+func (p *Provider) start() error {
+	args := append(append([]string(nil), p.args...), "--json-input", "--output_dir", os.TempDir())
+	cmd := exec.Command(p.binary, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("创建 piper 输入管道失败: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("创建 piper 输出管道失败: %v", err)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动 piper 进程失败: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	p.cmd, p.stdin, p.stdout, p.stderr, p.done = cmd, stdin, bufio.NewReader(stdout), stderr, done
+	return nil
+}
+
+// stop 结束常驻进程，下次合成时重新启动
+func (p *Provider) stop() {
+	if p.cmd == nil {
+		return
+	}
+	p.stdin.Close()
+	select {
+	case <-p.done:
+	case <-time.After(time.Second):
+		p.cmd.Process.Kill()
+		<-p.done
+	}
+	p.cmd, p.stdin, p.stdout, p.stderr, p.done = nil, nil, nil, nil, nil
+}
+
+// lastLine 取 stderr 最后一行作为错误信息
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
+
+// Cleanup 结束常驻进程并清理临时文件
+func (p *Provider) Cleanup() error {
+	p.mu.Lock()
+	p.stop()
+	p.mu.Unlock()
+	return p.BaseProvider.Cleanup()
+}
+
+func init() {
+	// 注册 piper TTS 提供者
+	tts.RegisterProvider(providers.ProviderMeta{
+		Name:         "piper",
+		Description:  "Piper 本地语音合成",
+		Capabilities: []string{providers.CapabilityLocal},
+		Required:     []string{"model_path"},
+	}, func(config *tts.Config, deleteFile bool) (tts.Provider, error) {
+		return NewProvider(config, deleteFile)
+	})
+}
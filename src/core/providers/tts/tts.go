@@ -1,11 +1,16 @@
 package tts
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
+	"xiaozhi-server-go/src/configs"
 	"xiaozhi-server-go/src/core/providers"
+	"xiaozhi-server-go/src/core/utils"
 )
 
 // Config TTS配置结构
@@ -18,8 +23,147 @@ type Config struct {
 	AppID      string `yaml:"appid"`
 	Token      string `yaml:"token"`
 	Cluster    string `yaml:"cluster"`
+	Timeout    string `yaml:"timeout,omitempty"`
+	MaxRetries *int   `yaml:"max_retries,omitempty"`
+
+	SurportedVoices []VoiceInfo            `yaml:"surported_voices,omitempty"`
+	Extra           map[string]interface{} `yaml:",inline"`
+}
+
+// VoiceInfo 音色信息
+type VoiceInfo = configs.VoiceInfo
+
+// GetString 读取提供者专有的字符串参数
+func (c *Config) GetString(key, def string) string {
+	if v, ok := c.Extra[key].(string); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// GetInt 读取提供者专有的整数参数
+func (c *Config) GetInt(key string, def int) int {
+	switch v := c.Extra[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return def
+}
+
+// GetFloat 读取提供者专有的浮点参数
+func (c *Config) GetFloat(key string, def float64) float64 {
+	switch v := c.Extra[key].(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return def
+}
+
+// GetBool 读取提供者专有的布尔参数
+func (c *Config) GetBool(key string, def bool) bool {
+	if v, ok := c.Extra[key].(bool); ok {
+		return v
+	}
+	return def
+}
+
+// VoiceLister 可选接口，支持从服务端查询可用音色
+type VoiceLister interface {
+	ListVoices(ctx context.Context) ([]VoiceInfo, error)
+}
+
+// VoiceSetter 可选接口，支持在单个连接内临时切换音色，资源归还池时恢复为配置的音色
+type VoiceSetter interface {
+	SetVoice(voice string) error
+}
+
+// StreamSynthesizer 可选接口，支持边合成边返回音频，首个数据块到达即可开始下发；返回错误表示未能开始合成
+type StreamSynthesizer interface {
+	ToTTSStream(ctx context.Context, text string) (*Stream, error)
+}
+
+// Stream 流式合成的输出，Chunks 按顺序输出 mp3 数据块，合成结束、失败或 ctx 取消后关闭
+type Stream struct {
+	Chunks <-chan []byte
+	err    error
+}
+
+// Err 返回合成中途失败的原因，须在 Chunks 关闭后调用；正常结束或 ctx 取消时为 nil
+func (s *Stream) Err() error {
+	return s.err
+}
+
+// StreamBuffer 流式合成的通道缓冲，消费方下发音频慢于合成时暂存的数据块数
+const StreamBuffer = 64
+
+// RunStream 在后台执行合成，run 通过 emit 按顺序输出数据块，供 StreamSynthesizer 实现复用
+// 收到首个数据块后返回通道；输出任何数据前失败则返回错误，调用方可改用整句合成
+// 已输出部分数据后失败时提前关闭通道，失败原因由 Stream.Err 返回；ctx 取消后 emit 返回错误，run 应尽快退出
+func RunStream(ctx context.Context, run func(emit func([]byte) error) error) (*Stream, error) {
+	chunks := make(chan []byte, StreamBuffer)
+	stream := &Stream{Chunks: chunks}
+	started := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		emitted := false
+		err := run(func(data []byte) error {
+			if len(data) == 0 {
+				return nil
+			}
+			select {
+			case chunks <- data:
+			case <-ctx.Done():
+				return utils.Permanent(ctx.Err())
+			}
+			if !emitted {
+				emitted = true
+				started <- nil
+			}
+			return nil
+		})
+		if !emitted {
+			if err == nil {
+				err = fmt.Errorf("流式合成未返回音频数据")
+			}
+			started <- err
+		} else if err != nil && ctx.Err() == nil {
+			// 在关闭通道前写入，读到通道关闭的一方可见
+			stream.err = err
+		}
+	}()
+
+	select {
+	case err := <-started:
+		if err != nil {
+			return nil, err
+		}
+		return stream, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
+// CloneRequest 声音克隆请求
+type CloneRequest struct {
+	Name     string // 音色名称
+	Audio    []byte // 参考音频内容
+	Filename string // 参考音频文件名，用于识别音频格式
+	AudioURL string // 参考音频的公网地址，部分服务只接受 URL
+	Text     string // 参考音频对应的文本，可选
+}
+
+// VoiceCloner 可选接口，支持上传参考音频注册克隆音色，返回合成时使用的音色 id
+type VoiceCloner interface {
+	CloneVoice(ctx context.Context, req CloneRequest) (string, error)
+}
+
+// DefaultTimeout 未配置 timeout 时单次合成的超时时间
+const DefaultTimeout = 15 * time.Second
+
 // Provider TTS提供者接口
 type Provider interface {
 	providers.TTSProvider
@@ -29,6 +173,10 @@ type Provider interface {
 type BaseProvider struct {
 	config     *Config
 	deleteFile bool
+	logger     *utils.Logger // 运行日志，由 Create 注入，直接构造的实例为 nil
+
+	mu    sync.RWMutex
+	voice string // 临时切换的音色，为空时使用配置的音色
 }
 
 // Config 获取配置
@@ -36,6 +184,77 @@ func (p *BaseProvider) Config() *Config {
 	return p.config
 }
 
+// Logger 获取运行日志，未注入时返回 nil
+func (p *BaseProvider) Logger() *utils.Logger {
+	return p.logger
+}
+
+// SetLogger 注入运行日志
+func (p *BaseProvider) SetLogger(logger *utils.Logger) {
+	p.logger = logger
+}
+
+// Voice 获取当前使用的音色
+// 配置由同类型的所有实例共享，切换音色只记录在实例上，不修改配置
+func (p *BaseProvider) Voice() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.voice != "" {
+		return p.voice
+	}
+	return p.config.Voice
+}
+
+// SetVoice 切换当前实例的音色
+func (p *BaseProvider) SetVoice(voice string) error {
+	if voice == "" {
+		return fmt.Errorf("音色不能为空")
+	}
+	p.mu.Lock()
+	p.voice = voice
+	p.mu.Unlock()
+	return nil
+}
+
+// Reset 恢复为配置的音色，资源归还池时调用
+func (p *BaseProvider) Reset() error {
+	p.mu.Lock()
+	p.voice = ""
+	p.mu.Unlock()
+	return nil
+}
+
+// Timeout 获取单次合成的超时时间
+func (p *BaseProvider) Timeout() time.Duration {
+	return utils.ParseTimeout(p.config.Timeout, DefaultTimeout)
+}
+
+// RetryPolicy 获取网络调用的重试策略
+func (p *BaseProvider) RetryPolicy() utils.RetryPolicy {
+	policy := utils.DefaultRetryPolicy()
+	if p.config.MaxRetries != nil {
+		policy = policy.WithMaxRetries(*p.config.MaxRetries)
+	}
+	return policy
+}
+
+// SurportedVoices 获取配置的可切换音色列表
+func (p *BaseProvider) SurportedVoices() []VoiceInfo {
+	return p.config.SurportedVoices
+}
+
+// OutputFile 在输出目录下生成本次合成的音频文件路径
+func (p *BaseProvider) OutputFile(prefix, ext string) (string, error) {
+	outputDir := p.config.OutputDir
+	if outputDir == "" {
+		outputDir = "tmp"
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("创建输出目录失败: %v", err)
+	}
+	return filepath.Join(outputDir, fmt.Sprintf("%s_%d.%s", prefix, time.Now().UnixNano(), ext)), nil
+}
+
 // DeleteFile 获取是否删除文件标志
 func (p *BaseProvider) DeleteFile() bool {
 	return p.deleteFile
@@ -78,26 +297,35 @@ func (p *BaseProvider) Cleanup() error {
 // Factory TTS工厂函数类型
 type Factory func(config *Config, deleteFile bool) (Provider, error)
 
-var (
-	factories = make(map[string]Factory)
-)
+var factories = providers.NewRegistry[Factory]("TTS")
 
-// Register 注册TTS提供者工厂
+// Register 注册TTS提供者工厂，不声明元数据
 func Register(name string, factory Factory) {
-	factories[name] = factory
+	RegisterProvider(providers.ProviderMeta{Name: name}, factory)
 }
 
-// Create 创建TTS提供者实例
-func Create(name string, config *Config, deleteFile bool) (Provider, error) {
-	factory, ok := factories[name]
+// RegisterProvider 注册TTS提供者工厂及其能力与必填配置
+func RegisterProvider(meta providers.ProviderMeta, factory Factory) {
+	factories.Register(meta, factory)
+}
+
+// Create 创建TTS提供者实例，先按元数据校验必填配置，嵌入 BaseProvider 的实例同时注入运行日志
+func Create(name string, config *Config, deleteFile bool, logger *utils.Logger) (Provider, error) {
+	factory, meta, ok := factories.Lookup(name)
 	if !ok {
-		return nil, fmt.Errorf("未知的TTS提供者: %s", name)
+		return nil, factories.UnknownError(name)
+	}
+	if err := providers.CheckRequired(meta, providers.ConfigValues(config)); err != nil {
+		return nil, err
 	}
 
 	provider, err := factory(config, deleteFile)
 	if err != nil {
 		return nil, fmt.Errorf("创建TTS提供者失败: %v", err)
 	}
+	if setter, ok := provider.(interface{ SetLogger(*utils.Logger) }); ok {
+		setter.SetLogger(logger)
+	}
 
 	if err := provider.Initialize(); err != nil {
 		return nil, fmt.Errorf("初始化TTS提供者失败: %v", err)
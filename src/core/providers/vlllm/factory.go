@@ -2,28 +2,36 @@ package vlllm
 
 import (
 	"fmt"
+	"time"
 
 	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/utils"
 )
 
 // Factory VLLLM工厂函数类型
 type Factory func(config *Config, logger *utils.Logger) (*Provider, error)
 
-var (
-	factories = make(map[string]Factory)
-)
+var factories = providers.NewRegistry[Factory]("VLLLM")
 
-// Register 注册VLLLM提供者工厂
+// Register 注册VLLLM提供者工厂，不声明元数据
 func Register(name string, factory Factory) {
-	factories[name] = factory
+	RegisterProvider(providers.ProviderMeta{Name: name}, factory)
+}
+
+// RegisterProvider 注册VLLLM提供者工厂及其能力与必填配置
+func RegisterProvider(meta providers.ProviderMeta, factory Factory) {
+	factories.Register(meta, factory)
 }
 
-// Create 创建VLLLM提供者实例
+// Create 创建VLLLM提供者实例，先按元数据校验必填配置
 func Create(name string, vlllmConfig *configs.VLLMConfig, logger *utils.Logger) (*Provider, error) {
-	factory, ok := factories[name]
+	factory, meta, ok := factories.Lookup(name)
 	if !ok {
-		return nil, fmt.Errorf("未知的VLLLM提供者: %s", name)
+		return nil, factories.UnknownError(name)
+	}
+	if err := providers.CheckRequired(meta, providers.ConfigValues(vlllmConfig)); err != nil {
+		return nil, err
 	}
 
 	// 转换配置格式
@@ -35,6 +43,7 @@ func Create(name string, vlllmConfig *configs.VLLMConfig, logger *utils.Logger)
 		Temperature: vlllmConfig.Temperature,
 		MaxTokens:   vlllmConfig.MaxTokens,
 		TopP:        vlllmConfig.TopP,
+		Timeout:     utils.ParseTimeout(vlllmConfig.Timeout, 30*time.Second),
 		Security:    vlllmConfig.Security,
 		Data:        vlllmConfig.Extra,
 	}
@@ -61,9 +70,5 @@ func Create(name string, vlllmConfig *configs.VLLMConfig, logger *utils.Logger)
 
 // GetRegisteredProviders 获取已注册的提供者列表
 func GetRegisteredProviders() []string {
-	var providers []string
-	for name := range factories {
-		providers = append(providers, name)
-	}
-	return providers
+	return factories.Names()
 } 
\ No newline at end of file
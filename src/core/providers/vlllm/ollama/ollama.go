@@ -1,6 +1,7 @@
 package ollama
 
 import (
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/vlllm"
 	"xiaozhi-server-go/src/core/utils"
 )
@@ -29,5 +30,9 @@ func NewProvider(config *vlllm.Config, logger *utils.Logger) (*vlllm.Provider, e
 
 // init 注册Ollama VLLLM提供者
 func init() {
-	vlllm.Register("ollama", NewProvider)
+	vlllm.RegisterProvider(providers.ProviderMeta{
+		Name:         "ollama",
+		Description:  "Ollama 本地多模态模型",
+		Capabilities: []string{providers.CapabilityVision, providers.CapabilityLocal},
+	}, NewProvider)
 } 
\ No newline at end of file
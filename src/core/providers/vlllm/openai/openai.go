@@ -1,6 +1,7 @@
 package openai
 
 import (
+	"xiaozhi-server-go/src/core/providers"
 	"xiaozhi-server-go/src/core/providers/vlllm"
 	"xiaozhi-server-go/src/core/utils"
 )
@@ -29,5 +30,10 @@ func NewProvider(config *vlllm.Config, logger *utils.Logger) (*vlllm.Provider, e
 
 // init 注册OpenAI VLLLM提供者
 func init() {
-	vlllm.Register("openai", NewProvider)
+	vlllm.RegisterProvider(providers.ProviderMeta{
+		Name:         "openai",
+		Description:  "OpenAI 兼容的多模态接口",
+		Capabilities: []string{providers.CapabilityVision},
+		Required:     []string{"api_key"},
+	}, NewProvider)
 } 
\ No newline at end of file
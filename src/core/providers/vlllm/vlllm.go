@@ -27,6 +27,7 @@ type Config struct {
 	Temperature float64
 	MaxTokens   int
 	TopP        float64
+	Timeout     time.Duration // 单次请求超时时间
 	Security    configs.SecurityConfig
 	Data        map[string]interface{}
 }
@@ -79,6 +80,7 @@ func NewProvider(config *Config, logger *utils.Logger) (*Provider, error) {
 		Temperature: config.Temperature,
 		MaxTokens:   config.MaxTokens,
 		TopP:        config.TopP,
+		Timeout:     config.Timeout.String(),
 		Security:    config.Security,
 	}
 
@@ -92,7 +94,7 @@ func NewProvider(config *Config, logger *utils.Logger) (*Provider, error) {
 		config:         config,
 		imageProcessor: imageProcessor,
 		logger:         logger,
-		httpClient:     &http.Client{Timeout: 30 * time.Second},
+		httpClient:     &http.Client{Timeout: config.Timeout},
 	}
 
 	return provider, nil
@@ -154,6 +156,9 @@ func (p *Provider) ResponseWithImage(ctx context.Context, sessionID string, mess
 		return nil, fmt.Errorf("图片处理失败: %v", err)
 	}
 
+	// 整个请求（含流式读取）受 timeout 约束，goroutine 结束时释放
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+
 	p.logger.Info("开始调用多模态API", map[string]interface{}{
 		"type":        p.config.Type,
 		"model_name":  p.config.ModelName,
@@ -162,14 +167,33 @@ func (p *Provider) ResponseWithImage(ctx context.Context, sessionID string, mess
 	})
 
 	// 根据类型调用对应的多模态API
+	var responseChan <-chan string
 	switch strings.ToLower(p.config.Type) {
 	case "openai":
-		return p.responseWithOpenAIVision(ctx, messages, base64Image, text, imageData.Format)
+		responseChan, err = p.responseWithOpenAIVision(ctx, messages, base64Image, text, imageData.Format)
 	case "ollama":
-		return p.responseWithOllamaVision(ctx, messages, base64Image, text, imageData.Format)
+		responseChan, err = p.responseWithOllamaVision(ctx, messages, base64Image, text, imageData.Format)
 	default:
-		return nil, fmt.Errorf("不支持的VLLLM类型: %s", p.config.Type)
+		err = fmt.Errorf("不支持的VLLLM类型: %s", p.config.Type)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
 	}
+	return withCancel(responseChan, cancel), nil
+}
+
+// withCancel 转发响应通道，读取完毕后释放超时上下文
+func withCancel(in <-chan string, cancel context.CancelFunc) <-chan string {
+	out := make(chan string, 10)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for content := range in {
+			out <- content
+		}
+	}()
+	return out
 }
 
 // responseWithOpenAIVision 使用OpenAI Vision API
@@ -0,0 +1,101 @@
+package punctuation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+// Restorer 标点恢复器，为没有标点的识别结果补全标点
+type Restorer interface {
+	Restore(text string) string
+}
+
+// New 按配置创建标点恢复器，未启用时返回 nil
+func New(config configs.PunctuationConfig) (Restorer, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	switch config.Type {
+	case "", "rule":
+		return RuleRestorer{}, nil
+	case "sherpa_onnx":
+		return newModelRestorer(config)
+	default:
+		return nil, fmt.Errorf("不支持的标点恢复类型: %s", config.Type)
+	}
+}
+
+// HasPunctuation 判断文本中是否已有标点
+func HasPunctuation(text string) bool {
+	return strings.IndexFunc(text, unicode.IsPunct) >= 0
+}
+
+// questionSuffixes 句末语气词，出现时按问句处理
+var questionSuffixes = []string{"吗", "呢", "么", "没有", "不是", "好不好", "对不对", "行不行", "可以不"}
+
+// questionWords 疑问词，出现时按问句处理
+var questionWords = []string{"什么", "怎么", "为什么", "为啥", "哪", "谁", "几点", "几个", "多少", "多久", "是不是", "有没有", "能不能", "会不会", "可不可以", "要不要"}
+
+// englishQuestionWords 英文疑问句开头
+var englishQuestionWords = []string{"what", "how", "why", "when", "where", "who", "which", "is", "are", "do", "does", "can", "could", "will", "would"}
+
+// RuleRestorer 按规则恢复标点：去掉中文字之间的空格，按疑问词判断补问号或句号
+type RuleRestorer struct{}
+
+// Restore 实现 Restorer 接口，已有标点的文本原样返回
+func (RuleRestorer) Restore(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" || HasPunctuation(text) {
+		return text
+	}
+	text = joinWords(strings.Fields(text))
+
+	if isASCII(text) {
+		first := strings.ToLower(strings.Fields(text)[0])
+		for _, word := range englishQuestionWords {
+			if first == word {
+				return text + "?"
+			}
+		}
+		return text + "."
+	}
+	for _, suffix := range questionSuffixes {
+		if strings.HasSuffix(text, suffix) {
+			return text + "？"
+		}
+	}
+	for _, word := range questionWords {
+		if strings.Contains(text, word) {
+			return text + "？"
+		}
+	}
+	return text + "。"
+}
+
+// joinWords 拼接分词结果，中文字之间不留空格，英文单词之间保留空格
+func joinWords(words []string) string {
+	var sb strings.Builder
+	for i, word := range words {
+		if i > 0 {
+			prev := []rune(words[i-1])
+			if !unicode.Is(unicode.Han, prev[len(prev)-1]) && !unicode.Is(unicode.Han, []rune(word)[0]) {
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteString(word)
+	}
+	return sb.String()
+}
+
+// isASCII 判断是否为纯英文文本
+func isASCII(text string) bool {
+	for _, r := range text {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
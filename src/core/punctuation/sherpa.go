@@ -0,0 +1,60 @@
+//go:build sherpa_onnx
+
+package punctuation
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"xiaozhi-server-go/src/configs"
+
+	sherpa "github.com/k2-fsa/sherpa-onnx-go/sherpa_onnx"
+)
+
+// modelRestorer 使用 sherpa-onnx CT-Transformer 标点模型补全句中与句末标点
+type modelRestorer struct {
+	mu    sync.Mutex // 模型推理串行执行
+	model *sherpa.OfflinePunctuation
+}
+
+func newModelRestorer(config configs.PunctuationConfig) (Restorer, error) {
+	if config.Model == "" {
+		return nil, fmt.Errorf("sherpa_onnx 标点恢复缺少 model 配置")
+	}
+	if _, err := os.Stat(config.Model); err != nil {
+		return nil, fmt.Errorf("标点模型文件不可用: %v", err)
+	}
+	numThreads := config.NumThreads
+	if numThreads <= 0 {
+		numThreads = 1
+	}
+
+	modelConfig := sherpa.OfflinePunctuationConfig{
+		Model: sherpa.OfflinePunctuationModelConfig{
+			CtTransformer: config.Model,
+			Provider:      "cpu",
+		},
+	}
+	// NumThreads 为 cgo 类型，包外只能通过反射赋值
+	reflect.ValueOf(&modelConfig.Model).Elem().FieldByName("NumThreads").SetInt(int64(numThreads))
+
+	model := sherpa.NewOfflinePunctuation(&modelConfig)
+	if model == nil {
+		return nil, fmt.Errorf("加载标点模型失败: %s", config.Model)
+	}
+	return &modelRestorer{model: model}, nil
+}
+
+// Restore 实现 Restorer 接口，已有标点的文本原样返回
+func (r *modelRestorer) Restore(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" || HasPunctuation(text) {
+		return text
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.model.AddPunct(joinWords(strings.Fields(text)))
+}
@@ -0,0 +1,14 @@
+//go:build !sherpa_onnx
+
+package punctuation
+
+import (
+	"fmt"
+
+	"xiaozhi-server-go/src/configs"
+)
+
+// newModelRestorer 未启用 sherpa_onnx 编译标签时不支持标点模型
+func newModelRestorer(config configs.PunctuationConfig) (Restorer, error) {
+	return nil, fmt.Errorf("当前程序未包含 sherpa_onnx 支持，请使用 go build -tags sherpa_onnx 重新编译")
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"xiaozhi-server-go/src/dnd"
+	"xiaozhi-server-go/src/push"
+)
+
+const (
+	pushQueueSize   = 4               // 每个连接排队等待执行的推送数，超出时直接返回 busy
+	pushWaitTimeout = 3 * time.Second // 文本处理协程正忙于本轮对话时，等待推送开始执行的最长时间
+)
+
+// pushTask 一次排队的推送播报；started 由先到的一方置位，超时放弃与开始执行互斥
+type pushTask struct {
+	text      string
+	interrupt bool
+	started   atomic.Bool
+	result    chan push.Result
+}
+
+// PushSpeak 实现 push.Runtime，在设备的连接上主动播报，deviceID 为空时向所有在线连接广播
+func (ws *WebSocketServer) PushSpeak(deviceID, text string, interrupt bool) []push.Result {
+	var targets []*ConnectionContext
+	ws.activeConnections.Range(func(key, value interface{}) bool {
+		ctx, ok := value.(*ConnectionContext)
+		if !ok || ctx.handler == nil {
+			return true
+		}
+		if deviceID != "" && ctx.handler.deviceID != deviceID {
+			return true
+		}
+		targets = append(targets, ctx)
+		return true
+	})
+
+	// 各连接的推送要等各自的处理协程执行，并发等待避免广播时逐个排队
+	results := make([]push.Result, len(targets))
+	var wg sync.WaitGroup
+	for i, ctx := range targets {
+		wg.Add(1)
+		go func(i int, ctx *ConnectionContext) {
+			defer wg.Done()
+			results[i] = ctx.handler.pushSpeak(text, interrupt)
+			results[i].ClientID = ctx.clientID
+		}(i, ctx)
+	}
+	wg.Wait()
+	if len(results) == 0 && deviceID != "" {
+		results = append(results, push.Result{DeviceID: deviceID, Status: push.StatusOffline})
+	}
+	return results
+}
+
+// pushSpeak 把推送交给连接的文本处理协程执行并等待结果，在调用方（HTTP 请求）的协程中运行
+// 处理协程正忙于本轮对话、超时仍未开始执行时返回 busy
+func (h *ConnectionHandler) pushSpeak(text string, interrupt bool) push.Result {
+	task := &pushTask{text: text, interrupt: interrupt, result: make(chan push.Result, 1)}
+	select {
+	case <-h.stopChan:
+		return push.Result{DeviceID: h.deviceID, Status: push.StatusOffline}
+	case h.pushQueue <- task:
+	default:
+		return push.Result{DeviceID: h.deviceID, Status: push.StatusBusy}
+	}
+
+	timer := time.NewTimer(pushWaitTimeout)
+	defer timer.Stop()
+	select {
+	case result := <-task.result:
+		return result
+	case <-h.stopChan:
+		return push.Result{DeviceID: h.deviceID, Status: push.StatusOffline}
+	case <-timer.C:
+		if task.started.CompareAndSwap(false, true) {
+			return push.Result{DeviceID: h.deviceID, Status: push.StatusBusy}
+		}
+	}
+	// 已开始执行，等待本次播报的结果
+	select {
+	case result := <-task.result:
+		return result
+	case <-h.stopChan:
+		return push.Result{DeviceID: h.deviceID, Status: push.StatusOffline}
+	}
+}
+
+// runPushTask 在文本处理协程中执行排队的推送，调用方已超时放弃的推送不再执行
+func (h *ConnectionHandler) runPushTask(task *pushTask) {
+	if !task.started.CompareAndSwap(false, true) {
+		h.logger.Info(fmt.Sprintf("推送播报等待超时，已放弃: %s", task.text))
+		return
+	}
+	task.result <- h.speakPush(task.text, task.interrupt)
+}
+
+// speakPush 把推送的文本作为新的一轮播报，与提醒一样在免打扰期间不播报
+// 正在播报或拾音时按 interrupt 决定打断还是放弃，打断后旧轮次未下发的分段与暂停的分批回复随之丢弃
+func (h *ConnectionHandler) speakPush(text string, interrupt bool) push.Result {
+	result := push.Result{DeviceID: h.deviceID}
+	switch h.voice.State() {
+	case VoiceClosing:
+		result.Status = push.StatusOffline
+		return result
+	case VoiceSpeaking, VoiceListening:
+		if !interrupt {
+			result.Status = push.StatusBusy
+			return result
+		}
+	}
+	if h.deviceID != "" && dnd.Check(h.deviceID, h.now()) != nil {
+		result.Status = push.StatusDND
+		return result
+	}
+
+	if h.voice.State() == VoiceSpeaking {
+		h.logger.Info("收到推送播报，打断当前播报")
+		h.stopSpeakByServer()
+		h.sendTTSMessage("stop", "", 0)
+		h.clearSpeakStatus()
+	}
+	h.touchActiveTime()
+	h.logger.Info(fmt.Sprintf("推送播报: %s", text))
+	if err := h.proactiveSpeak(text); err != nil {
+		h.logger.Error(fmt.Sprintf("推送播报失败: %v", err))
+		result.Status, result.Message = push.StatusFailed, err.Error()
+		return result
+	}
+	result.Status = push.StatusSent
+	return result
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/dnd"
+	"xiaozhi-server-go/src/reminder"
+	"xiaozhi-server-go/src/task"
+)
+
+const (
+	reminderRetryDelay   = 5 * time.Second // 到点时设备正在播报，推迟后重试
+	reminderDeliverDelay = 3 * time.Second // 设备连接后等待握手完成再补播离线期间到点的提醒
+)
+
+// reminderScheduler 把持久化的提醒挂到任务系统的定时任务上，到点后推送给设备播报
+// 服务端共享一个实例，启动时恢复库中待播报的提醒
+type reminderScheduler struct {
+	taskMgr *task.TaskManager
+	logger  *utils.Logger
+	lookup  func(deviceID string) *ConnectionHandler // 查找设备当前的连接，离线时返回 nil
+}
+
+func newReminderScheduler(taskMgr *task.TaskManager, logger *utils.Logger, lookup func(string) *ConnectionHandler) *reminderScheduler {
+	return &reminderScheduler{taskMgr: taskMgr, logger: logger, lookup: lookup}
+}
+
+func reminderTaskID(id uint) string {
+	return fmt.Sprintf("reminder-%d", id)
+}
+
+// restore 恢复库中所有待播报的提醒，已过期的立即触发
+func (s *reminderScheduler) restore() {
+	pending, err := reminder.ListPending("")
+	if err != nil {
+		s.logger.Warn(fmt.Sprintf("恢复提醒失败: %v", err))
+		return
+	}
+	for _, r := range pending {
+		if err := s.schedule(r, r.RemindAt); err != nil {
+			s.logger.Warn(fmt.Sprintf("恢复提醒 %d 失败: %v", r.ID, err))
+		}
+	}
+	if len(pending) > 0 {
+		s.logger.Info(fmt.Sprintf("已恢复 %d 个待播报的提醒", len(pending)))
+	}
+}
+
+// schedule 在 at 时刻触发提醒
+func (s *reminderScheduler) schedule(r reminder.Reminder, at time.Time) error {
+	params := map[string]interface{}{"action": "reminder", "content": r.Content}
+	t, _ := task.NewTask(task.TaskTypeScheduled, params, task.NewActionCallback(
+		func(interface{}) { s.fire(r) },
+		func(err error) { s.logger.Error(fmt.Sprintf("提醒 %d 执行失败: %v", r.ID, err)) },
+	))
+	t.ID = reminderTaskID(r.ID)
+	t.ScheduledTime = &at
+	return s.taskMgr.SubmitTask(r.DeviceID, t)
+}
+
+// cancel 取消尚未触发的提醒任务
+func (s *reminderScheduler) cancel(id uint) {
+	s.taskMgr.CancelTask(reminderTaskID(id))
+}
+
+// fire 提醒到点，设备在线时播报，离线时保持待播报状态，下次连接时补播
+func (s *reminderScheduler) fire(r reminder.Reminder) {
+	h := s.lookup(r.DeviceID)
+	if h == nil {
+		s.logger.Info(fmt.Sprintf("提醒 %d 到点时设备 %s 不在线，重新连接后补播", r.ID, r.DeviceID))
+		return
+	}
+	s.deliver(h, r)
+}
+
+// deliver 在设备连接上播报提醒，设备正在播报时推迟；免打扰期间按设置延后或静默
+func (s *reminderScheduler) deliver(h *ConnectionHandler, r reminder.Reminder) {
+	switch h.voice.State() {
+	case VoiceClosing:
+		return
+	case VoiceSpeaking:
+		if err := s.schedule(r, time.Now().Add(reminderRetryDelay)); err != nil {
+			s.logger.Warn(fmt.Sprintf("推迟提醒 %d 失败: %v", r.ID, err))
+		}
+		return
+	}
+	now := h.now()
+	if setting := dnd.Check(r.DeviceID, now); setting != nil {
+		if setting.ReminderMode == dnd.ReminderSilent {
+			if _, err := reminder.SetStatus(r.ID, reminder.StatusDone); err != nil {
+				s.logger.Error(fmt.Sprintf("更新提醒 %d 状态失败: %v", r.ID, err))
+			}
+			h.logger.Info(fmt.Sprintf("免打扰期间静默提醒 %d: %s", r.ID, r.Content))
+			return
+		}
+		end := setting.EndAt(now)
+		h.logger.Info(fmt.Sprintf("免打扰期间延后提醒 %d 到 %s", r.ID, end.Format("01-02 15:04")))
+		if err := s.schedule(r, end); err != nil {
+			s.logger.Warn(fmt.Sprintf("延后提醒 %d 失败: %v", r.ID, err))
+		}
+		return
+	}
+	// 先标记为已播报，避免定时任务与连接补播重复播报
+	ok, err := reminder.SetStatus(r.ID, reminder.StatusDone)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("更新提醒 %d 状态失败: %v", r.ID, err))
+		return
+	}
+	if !ok {
+		return
+	}
+	h.logger.Info(fmt.Sprintf("播报提醒 %d: %s", r.ID, r.Content))
+	if err := h.proactiveSpeak("提醒你，" + r.Content); err != nil {
+		h.logger.Error(fmt.Sprintf("播报提醒失败: %v", err))
+	}
+}
+
+// deliverDue 补播设备离线期间到点的提醒
+func (s *reminderScheduler) deliverDue(h *ConnectionHandler) {
+	due, err := reminder.ListDue(h.deviceID, time.Now())
+	if err != nil {
+		h.logger.Warn(fmt.Sprintf("查询待补播的提醒失败: %v", err))
+		return
+	}
+	for _, r := range due {
+		s.deliver(h, r)
+	}
+}
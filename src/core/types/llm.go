@@ -88,12 +88,29 @@ type FunctionCall struct {
 	Arguments string `json:"arguments"`
 }
 
+// Usage 单次请求的 token 用量
+type Usage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	CachedTokens        int `json:"cached_tokens,omitempty"`         // 输入中命中 prompt 缓存的部分
+	CacheCreationTokens int `json:"cache_creation_tokens,omitempty"` // 本次写入 prompt 缓存的部分
+}
+
+// Add 累加用量
+func (u *Usage) Add(other *Usage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.CachedTokens += other.CachedTokens
+	u.CacheCreationTokens += other.CacheCreationTokens
+}
+
 // Response LLM响应结构
 type Response struct {
 	Content    string     `json:"content,omitempty"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	StopReason string     `json:"stop_reason,omitempty"`
 	Error      string     `json:"error,omitempty"`
+	Usage      *Usage     `json:"usage,omitempty"` // 流式输出结束时返回，不支持的提供者为空
 }
 
 // Provider 基础提供者接口
@@ -117,3 +134,18 @@ type LLMProvider interface {
 	Response(ctx context.Context, sessionID string, messages []Message) (<-chan string, error)
 	ResponseWithFunctions(ctx context.Context, sessionID string, messages []Message, tools []openai.Tool) (<-chan Response, error)
 }
+
+// ResponseSchema 结构化输出约束
+type ResponseSchema struct {
+	Name        string          // schema 名称，只能包含字母、数字、下划线和短横线
+	Description string          // 输出内容说明
+	Schema      json.RawMessage // JSON Schema，为空时只要求输出合法的 JSON 对象
+	Strict      bool            // 是否要求严格遵循 schema，仅部分模型支持
+}
+
+// SchemaLLMProvider 支持结构化输出的大语言模型提供者，供意图识别、记忆总结等内部调用使用
+type SchemaLLMProvider interface {
+	LLMProvider
+	// ResponseWithSchema 非流式请求，返回符合 schema 的 JSON 文本
+	ResponseWithSchema(ctx context.Context, sessionID string, messages []Message, schema *ResponseSchema) (string, error)
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
@@ -218,8 +219,21 @@ func SaveAudioToWavFile(data []byte, fileName string, sampleRate int, channels i
 	return nil
 }
 
+// PCMToWav 为 PCM 数据加上 WAV 头，用于上传到需要文件格式的识别接口
+func PCMToWav(data []byte, sampleRate, channels, bitsPerSample int) []byte {
+	wav := make([]byte, 0, 44+len(data))
+	wav = append(wav, wavHeader(len(data), sampleRate, channels, bitsPerSample)...)
+	return append(wav, data...)
+}
+
 // 写入WAV文件头
 func writeWavHeader(file *os.File, dataSize int, sampleRate, channels, bitsPerSample int) error {
+	_, err := file.Write(wavHeader(dataSize, sampleRate, channels, bitsPerSample))
+	return err
+}
+
+// wavHeader 构造44字节的WAV文件头
+func wavHeader(dataSize int, sampleRate, channels, bitsPerSample int) []byte {
 	// RIFF块
 	header := make([]byte, 44)
 	copy(header[0:4], []byte("RIFF"))
@@ -282,8 +296,7 @@ func writeWavHeader(file *os.File, dataSize int, sampleRate, channels, bitsPerSa
 	header[42] = byte(dataSize >> 16)
 	header[43] = byte(dataSize >> 24)
 
-	_, err := file.Write(header)
-	return err
+	return header
 }
 
 // 保留原来的函数，但使用新函数
@@ -314,7 +327,15 @@ func ReadPCMDataFromWavFile(filePath string) ([]byte, error) {
 	return pcmData, nil
 }
 
+// AudioToPCMData 将 mp3、wav 或 ogg opus 音频文件解码为16位单声道 PCM，返回PCM数据与时长(秒)
 func AudioToPCMData(audioFile string) ([][]byte, float64, error) {
+	if IsWavFile(audioFile) {
+		return WavToPCMData(audioFile, defaultOutputSampleRate)
+	}
+	if IsOggFile(audioFile) {
+		return OggOpusToPCMData(audioFile, defaultOutputSampleRate)
+	}
+
 	file, err := os.Open(audioFile)
 	if err != nil {
 		return nil, 0, fmt.Errorf("打开音频文件失败: %v", err)
@@ -328,12 +349,6 @@ func AudioToPCMData(audioFile string) ([][]byte, float64, error) {
 
 	mp3SampleRate := decoder.SampleRate()
 
-	// 检查采样率是否支持
-	supportedRates := map[int]bool{8000: true, 12000: true, 16000: true, 24000: true, 48000: true}
-	if !supportedRates[mp3SampleRate] {
-		return nil, 0, fmt.Errorf("MP3采样率 %dHz 不被Opus直接支持，需要重采样", mp3SampleRate)
-	}
-
 	// decoder.Length() 返回解码后的PCM数据总字节数 (16-bit little-endian stereo)
 	pcmBytes := make([]byte, decoder.Length())
 	// ReadFull确保读取所有请求的字节，否则返回错误
@@ -379,37 +394,224 @@ func AudioToPCMData(audioFile string) ([][]byte, float64, error) {
 
 	// 函数签名要求返回 [][]byte.
 	// 将整个单声道PCM数据作为外部切片中的单个段/切片返回.
-	return [][]byte{monoPcmDataBytes}, duration, nil
+	// 下发固定使用24000Hz编码，其他采样率(如44100Hz)先重采样
+	return [][]byte{ResamplePCM16(monoPcmDataBytes, mp3SampleRate, defaultOutputSampleRate)}, duration, nil
 }
 
-// AudioToOpusData 将音频文件转换为Opus数据块
-func AudioToOpusData(audioFile string) ([][]byte, float64, error) {
-	// 先将MP3转为PCM
-	pcmData, duration, err := AudioToPCMData(audioFile)
+// defaultOutputSampleRate 下发音频的采样率，与 Opus 编码使用的采样率一致
+const defaultOutputSampleRate = 24000
+
+// OutputSampleRate AudioToPCMData 输出 PCM 的采样率
+const OutputSampleRate = defaultOutputSampleRate
+
+// IsWavFile 按文件头判断是否为 wav 文件
+func IsWavFile(audioFile string) bool {
+	file, err := os.Open(audioFile)
 	if err != nil {
-		return nil, 0, fmt.Errorf("PCM转换失败: %v", err)
+		return false
 	}
+	defer file.Close()
 
-	if len(pcmData) == 0 {
-		return nil, 0, fmt.Errorf("PCM转换结果为空")
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return false
 	}
+	return string(header[0:4]) == "RIFF" && string(header[8:12]) == "WAVE"
+}
 
-	// 打开MP3文件获取采样率
+// WavToPCMData 读取16位 PCM 编码的 wav 文件，混合为单声道并重采样到 targetRate
+func WavToPCMData(audioFile string, targetRate int) ([][]byte, float64, error) {
+	data, err := os.ReadFile(audioFile)
+	if err != nil {
+		return nil, 0, fmt.Errorf("读取WAV文件失败: %v", err)
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, fmt.Errorf("不是有效的WAV文件")
+	}
+
+	// 逐块解析，兼容带 LIST 等附加块的文件
+	var channels, sampleRate, bitsPerSample int
+	var pcm []byte
+	for offset := 12; offset+8 <= len(data); {
+		chunkID := string(data[offset : offset+4])
+		chunkSize := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := data[offset+8:]
+		// 流式写入的 wav 数据长度可能为0或超出实际长度
+		if chunkSize > len(body) || (chunkID == "data" && chunkSize == 0) {
+			chunkSize = len(body)
+		}
+		body = body[:chunkSize]
+
+		switch chunkID {
+		case "fmt ":
+			if len(body) < 16 {
+				return nil, 0, fmt.Errorf("WAV格式块长度不足")
+			}
+			if format := binary.LittleEndian.Uint16(body[0:2]); format != 1 && format != 0xFFFE {
+				return nil, 0, fmt.Errorf("仅支持PCM编码的WAV，当前格式: %d", format)
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(body[14:16]))
+		case "data":
+			pcm = body
+		}
+		offset += 8 + chunkSize + chunkSize%2 // 块按偶数字节对齐
+	}
+
+	if sampleRate == 0 || channels == 0 {
+		return nil, 0, fmt.Errorf("WAV文件缺少格式信息")
+	}
+	if bitsPerSample != 16 {
+		return nil, 0, fmt.Errorf("仅支持16位WAV，当前位深: %d", bitsPerSample)
+	}
+
+	// 多声道取平均混为单声道
+	frames := len(pcm) / (2 * channels)
+	mono := make([]byte, frames*2)
+	for i := 0; i < frames; i++ {
+		var sum int32
+		for c := 0; c < channels; c++ {
+			sum += int32(int16(binary.LittleEndian.Uint16(pcm[(i*channels+c)*2:])))
+		}
+		binary.LittleEndian.PutUint16(mono[i*2:], uint16(int16(sum/int32(channels))))
+	}
+	if frames == 0 {
+		return [][]byte{}, 0, nil
+	}
+
+	duration := float64(frames) / float64(sampleRate)
+	return [][]byte{ResamplePCM16(mono, sampleRate, targetRate)}, duration, nil
+}
+
+// IsOggFile 按文件头判断是否为 ogg 封装的音频
+func IsOggFile(audioFile string) bool {
 	file, err := os.Open(audioFile)
 	if err != nil {
-		return nil, 0, fmt.Errorf("打开音频文件失败: %v", err)
+		return false
 	}
 	defer file.Close()
 
-	// 检查MP3文件格式是否有效
-	_, err = mp3.NewDecoder(file)
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return false
+	}
+	return string(header) == "OggS"
+}
+
+// OggOpusToPCMData 解封装 ogg opus 文件并解码为 targetRate 的16位单声道 PCM
+// libopus 可直接按任意支持的采样率解码，无需额外重采样
+func OggOpusToPCMData(audioFile string, targetRate int) ([][]byte, float64, error) {
+	data, err := os.ReadFile(audioFile)
 	if err != nil {
-		return nil, 0, fmt.Errorf("创建MP3解码器失败: %v", err)
+		return nil, 0, fmt.Errorf("读取OGG文件失败: %v", err)
 	}
+	packets, err := readOggPackets(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(packets) == 0 || len(packets[0]) < 8 || string(packets[0][:8]) != "OpusHead" {
+		return nil, 0, fmt.Errorf("OGG文件不是Opus编码")
+	}
+
+	decoder, err := NewOpusDecoder(&OpusDecoderConfig{SampleRate: targetRate, MaxChannels: 1})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer decoder.Close()
+
+	// 前两个包为 OpusHead 与 OpusTags
+	var pcm []byte
+	for _, packet := range packets[min(2, len(packets)):] {
+		frame, err := decoder.Decode(packet)
+		if err != nil {
+			return nil, 0, err
+		}
+		pcm = append(pcm, frame...)
+	}
+	if len(pcm) == 0 {
+		return [][]byte{}, 0, nil
+	}
+	duration := float64(len(pcm)/2) / float64(targetRate)
+	return [][]byte{pcm}, duration, nil
+}
+
+// readOggPackets 按页解析 ogg 流并拼接出完整的数据包，只读取第一条逻辑流
+func readOggPackets(data []byte) ([][]byte, error) {
+	var packets [][]byte
+	var current []byte
+	var serial uint32
+	for offset := 0; offset < len(data); {
+		if len(data)-offset < 27 || string(data[offset:offset+4]) != "OggS" {
+			return nil, fmt.Errorf("OGG页头无效(偏移%d)", offset)
+		}
+		pageSerial := binary.LittleEndian.Uint32(data[offset+14 : offset+18])
+		segments := int(data[offset+26])
+		bodyStart := offset + 27 + segments
+		if bodyStart > len(data) {
+			return nil, fmt.Errorf("OGG页数据不完整")
+		}
+		if offset == 0 {
+			serial = pageSerial
+		}
+
+		pos := bodyStart
+		for _, size := range data[offset+27 : bodyStart] {
+			if pos+int(size) > len(data) {
+				return nil, fmt.Errorf("OGG页数据不完整")
+			}
+			if pageSerial == serial {
+				current = append(current, data[pos:pos+int(size)]...)
+				// 长度小于255的分段表示数据包结束，否则延续到下一分段或下一页
+				if size < 255 {
+					packets = append(packets, current)
+					current = nil
+				}
+			}
+			pos += int(size)
+		}
+		offset = pos
+	}
+	return packets, nil
+}
+
+// ResamplePCM16 对16位单声道 PCM 做线性插值重采样
+func ResamplePCM16(data []byte, fromRate, toRate int) []byte {
+	if fromRate == toRate || fromRate <= 0 || toRate <= 0 || len(data) < 2 {
+		return data
+	}
+	inSamples := len(data) / 2
+	outSamples := int(int64(inSamples) * int64(toRate) / int64(fromRate))
+	out := make([]byte, outSamples*2)
+	ratio := float64(fromRate) / float64(toRate)
+	for i := 0; i < outSamples; i++ {
+		pos := float64(i) * ratio
+		idx := int(pos)
+		frac := pos - float64(idx)
+		a := float64(int16(binary.LittleEndian.Uint16(data[idx*2:])))
+		b := a
+		if idx+1 < inSamples {
+			b = float64(int16(binary.LittleEndian.Uint16(data[(idx+1)*2:])))
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(a+(b-a)*frac)))
+	}
+	return out
+}
 
-	// 获取采样率 (固定使用24000Hz作为Opus编码的采样率)
-	// 如果采样率不是24000Hz，PCMSlicesToOpusData会处理重采样
-	opusSampleRate := 24000
+// AudioToOpusData 将音频文件转换为Opus数据块
+func AudioToOpusData(audioFile string) ([][]byte, float64, error) {
+	// 先将音频解码为PCM
+	pcmData, duration, err := AudioToPCMData(audioFile)
+	if err != nil {
+		return nil, 0, fmt.Errorf("PCM转换失败: %v", err)
+	}
+
+	if len(pcmData) == 0 {
+		return nil, 0, fmt.Errorf("PCM转换结果为空")
+	}
+
+	// 固定使用24000Hz作为Opus编码的采样率，AudioToPCMData 已统一重采样
+	opusSampleRate := defaultOutputSampleRate
 	channels := 1
 
 	// 将PCM转换为Opus
@@ -635,8 +837,47 @@ func MP3ToOpusFile(inputFile, outputFile string, bitrate int) error {
 	return SaveAudioFile(opusData, outputFile)
 }
 
-// PCMSlicesToOpusData 将PCM数据切片批量编码为Opus格式
+// PCMSlicesToOpusData 将PCM数据切片批量编码为60ms帧的Opus格式
 func PCMSlicesToOpusData(pcmSlices [][]byte, sampleRate int, channels int, bitrate int) ([][]byte, error) {
+	return PCMSlicesToOpusFrames(pcmSlices, sampleRate, channels, OpusEncodeOptions{Bitrate: bitrate}, 60)
+}
+
+// opusFrameSizes 下发音频支持的Opus帧时长(毫秒)
+var opusFrameSizes = map[int]opus.FrameSizeType{
+	10: opus.Framesize10Ms,
+	20: opus.Framesize20Ms,
+	40: opus.Framesize40Ms,
+	60: opus.Framesize60Ms,
+}
+
+// ValidOpusFrameDuration 判断帧时长是否可用于下发音频
+func ValidOpusFrameDuration(ms int) bool {
+	_, ok := opusFrameSizes[ms]
+	return ok
+}
+
+// OpusEncodeOptions Opus 编码参数，零值使用编码器默认行为
+type OpusEncodeOptions struct {
+	Bitrate    int  // 码率(bps)，0 由编码器自动选择
+	Complexity int  // 复杂度 1-10，0 使用编码器默认值
+	DTX        bool // 静音段只输出极小的 DTX 包
+	CBR        bool // 固定码率，关闭 VBR
+}
+
+// maxOpusDTXPacketSize DTX 状态下编码器输出的包不超过 2 字节
+const maxOpusDTXPacketSize = 2
+
+// IsOpusDTXPacket 判断是否为 DTX 静音包
+func IsOpusDTXPacket(packet []byte) bool {
+	return len(packet) <= maxOpusDTXPacketSize
+}
+
+// PCMSlicesToOpusFrames 将PCM数据切片批量编码为指定帧时长(10/20/40/60ms)的Opus帧
+func PCMSlicesToOpusFrames(pcmSlices [][]byte, sampleRate int, channels int, opts OpusEncodeOptions, frameMs int) ([][]byte, error) {
+	frameSize, ok := opusFrameSizes[frameMs]
+	if !ok {
+		return nil, fmt.Errorf("不支持的Opus帧时长 %dms，仅支持10/20/40/60ms", frameMs)
+	}
 	if len(pcmSlices) == 0 {
 		return nil, fmt.Errorf("PCM数据切片为空")
 	}
@@ -652,7 +893,11 @@ func PCMSlicesToOpusData(pcmSlices [][]byte, sampleRate int, channels int, bitra
 		SampleRate:    sampleRate,
 		MaxChannels:   channels,
 		Application:   opus.AppVoIP,
-		FrameDuration: opus.Framesize60Ms, // 使用60ms帧长
+		FrameDuration: frameSize,
+		Bitrate:       opts.Bitrate,
+		Complexity:    opts.Complexity,
+		EnableDTX:     opts.DTX,
+		DisableVBR:    opts.CBR,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("创建Opus编码器失败: %v", err)
@@ -662,8 +907,8 @@ func PCMSlicesToOpusData(pcmSlices [][]byte, sampleRate int, channels int, bitra
 	// 所有编码后的Opus数据包
 	var allOpusPackets [][]byte
 
-	// 计算每帧样本数 (60ms帧)
-	samplesPerFrame := (sampleRate * 60) / 1000 // 60ms帧
+	// 计算每帧样本数
+	samplesPerFrame := (sampleRate * frameMs) / 1000
 	// 每个样本的字节数 (16位 = 2字节)
 	bytesPerSample := 2 * channels
 	// 每帧字节数
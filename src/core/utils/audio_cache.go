@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// AudioFrameCache 音频文件解码编码结果的缓存，按文件路径、修改时间与编码参数缓存下发用的帧，
+// 缓存总字节数超过上限时淘汰最久未使用的条目，并发安全
+type AudioFrameCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	entries  map[string]*list.Element
+	order    *list.List // 最近使用的在前
+}
+
+type audioCacheEntry struct {
+	key      string
+	frames   [][]byte
+	duration float64
+	size     int64
+}
+
+// NewAudioFrameCache 创建缓存上限为 maxBytes 字节的缓存
+func NewAudioFrameCache(maxBytes int64) *AudioFrameCache {
+	return &AudioFrameCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// audioCacheKey 文件被覆盖写入后修改时间或大小变化，旧条目不再命中，由 LRU 自然淘汰
+func audioCacheKey(path, variant string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s\x00%d\x00%d\x00%s", path, info.ModTime().UnixNano(), info.Size(), variant), nil
+}
+
+// Get 查询文件按 variant 编码的帧与时长(秒)，返回的帧由缓存共享，调用方不能修改
+func (c *AudioFrameCache) Get(path, variant string) ([][]byte, float64, bool) {
+	key, err := audioCacheKey(path, variant)
+	if err != nil {
+		return nil, 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, 0, false
+	}
+	c.order.MoveToFront(elem)
+	entry := elem.Value.(*audioCacheEntry)
+	return entry.frames, entry.duration, true
+}
+
+// Put 缓存文件按 variant 编码的帧，单个文件超过缓存上限时不缓存
+func (c *AudioFrameCache) Put(path, variant string, frames [][]byte, duration float64) {
+	key, err := audioCacheKey(path, variant)
+	if err != nil {
+		return
+	}
+	var size int64
+	for _, frame := range frames {
+		size += int64(len(frame))
+	}
+	if size == 0 || size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.order.PushFront(&audioCacheEntry{key: key, frames: frames, duration: duration, size: size})
+	c.size += size
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove 删除条目，调用方需持有锁
+func (c *AudioFrameCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*audioCacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+	opus "github.com/qrtc/opus-go"
+)
+
+// chunkReader 把按顺序到达的数据块拼接为连续的读取流，通道关闭即读到末尾
+type chunkReader struct {
+	chunks <-chan []byte
+	buf    []byte
+}
+
+// ChunkReader 将数据块通道包装为 io.Reader，供流式解码使用
+func ChunkReader(chunks <-chan []byte) io.Reader {
+	return &chunkReader{chunks: chunks}
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		chunk, ok := <-r.chunks
+		if !ok {
+			return 0, io.EOF
+		}
+		r.buf = chunk
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// mp3StreamReadSize 每次从解码器读取的 PCM 字节数，约为 go-mp3 两帧的双声道输出
+const mp3StreamReadSize = 1152 * 4 * 2
+
+// DecodeMP3Stream 边读取边解码 mp3，每解码出一段就以 OutputSampleRate 的16位单声道 PCM 回调 onPCM
+// 分段重采样在段边界不做插值，mp3 采样率与 OutputSampleRate 一致时无损
+func DecodeMP3Stream(r io.Reader, onPCM func(pcm []byte) error) error {
+	decoder, err := mp3.NewDecoder(r)
+	if err != nil {
+		return fmt.Errorf("创建MP3解码器失败: %v", err)
+	}
+	sampleRate := decoder.SampleRate()
+
+	buf := make([]byte, mp3StreamReadSize)
+	for {
+		n, err := io.ReadFull(decoder, buf)
+		// go-mp3 输出为双声道，按4字节对齐后混为单声道
+		if n -= n % 4; n > 0 {
+			pcm := ResamplePCM16(stereoToMono(buf[:n]), sampleRate, defaultOutputSampleRate)
+			if cbErr := onPCM(pcm); cbErr != nil {
+				return cbErr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("解码MP3数据失败: %v", err)
+		}
+	}
+}
+
+// stereoToMono 把16位小端双声道 PCM 按左右声道平均混为单声道
+func stereoToMono(pcm []byte) []byte {
+	samples := len(pcm) / 4
+	mono := make([]byte, samples*2)
+	for i := 0; i < samples; i++ {
+		left := int16(uint16(pcm[i*4]) | uint16(pcm[i*4+1])<<8)
+		right := int16(uint16(pcm[i*4+2]) | uint16(pcm[i*4+3])<<8)
+		sample := int16((int32(left) + int32(right)) / 2)
+		mono[i*2] = byte(sample)
+		mono[i*2+1] = byte(sample >> 8)
+	}
+	return mono
+}
+
+// OpusStreamEncoder 持续编码 PCM 的 Opus 编码器，不足一帧的数据留到下次编码
+type OpusStreamEncoder struct {
+	encoder       *opus.OpusEncoder
+	bytesPerFrame int
+	pending       []byte
+}
+
+// NewOpusStreamEncoder 创建指定帧时长(10/20/40/60ms)的流式 Opus 编码器，用完需调用 Close
+func NewOpusStreamEncoder(sampleRate int, channels int, opts OpusEncodeOptions, frameMs int) (*OpusStreamEncoder, error) {
+	frameSize, ok := opusFrameSizes[frameMs]
+	if !ok {
+		return nil, fmt.Errorf("不支持的Opus帧时长 %dms，仅支持10/20/40/60ms", frameMs)
+	}
+	supportedRates := map[int]bool{8000: true, 12000: true, 16000: true, 24000: true, 48000: true}
+	if !supportedRates[sampleRate] {
+		return nil, fmt.Errorf("采样率 %dHz 不被Opus支持，仅支持8000/12000/16000/24000/48000Hz", sampleRate)
+	}
+
+	encoder, err := opus.CreateOpusEncoder(&opus.OpusEncoderConfig{
+		SampleRate:    sampleRate,
+		MaxChannels:   channels,
+		Application:   opus.AppVoIP,
+		FrameDuration: frameSize,
+		Bitrate:       opts.Bitrate,
+		Complexity:    opts.Complexity,
+		EnableDTX:     opts.DTX,
+		DisableVBR:    opts.CBR,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建Opus编码器失败: %v", err)
+	}
+	return &OpusStreamEncoder{
+		encoder:       encoder,
+		bytesPerFrame: sampleRate * frameMs / 1000 * 2 * channels,
+	}, nil
+}
+
+// Encode 编码凑满整帧的部分，返回编码出的 Opus 帧，编码失败的帧跳过
+func (e *OpusStreamEncoder) Encode(pcm []byte) [][]byte {
+	e.pending = append(e.pending, pcm...)
+	var packets [][]byte
+	for len(e.pending) >= e.bytesPerFrame {
+		if packet := e.encodeFrame(e.pending[:e.bytesPerFrame]); packet != nil {
+			packets = append(packets, packet)
+		}
+		e.pending = e.pending[e.bytesPerFrame:]
+	}
+	return packets
+}
+
+// Flush 把剩余不足一帧的数据补静音后编码
+func (e *OpusStreamEncoder) Flush() [][]byte {
+	// 确保PCM数据长度是偶数
+	if len(e.pending)%2 != 0 {
+		e.pending = e.pending[:len(e.pending)-1]
+	}
+	if len(e.pending) == 0 {
+		return nil
+	}
+	frame := make([]byte, e.bytesPerFrame)
+	copy(frame, e.pending)
+	e.pending = nil
+	if packet := e.encodeFrame(frame); packet != nil {
+		return [][]byte{packet}
+	}
+	return nil
+}
+
+func (e *OpusStreamEncoder) encodeFrame(framePcm []byte) []byte {
+	outBuf := make([]byte, len(framePcm))
+	n, err := e.encoder.Encode(framePcm, outBuf)
+	if err != nil || n == 0 {
+		return nil
+	}
+	return outBuf[:n]
+}
+
+// Close 释放编码器
+func (e *OpusStreamEncoder) Close() {
+	e.encoder.Close()
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -21,3 +22,33 @@ func MinDuration(a, b time.Duration) time.Duration {
 	}
 	return b
 }
+
+// ParseTimeout 解析配置中的超时时间，支持 "30s" 形式的字符串或以秒为单位的数字，
+// 未配置或格式错误时返回默认值
+func ParseTimeout(value interface{}, def time.Duration) time.Duration {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return def
+		}
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		if sec, err := strconv.ParseFloat(v, 64); err == nil && sec > 0 {
+			return time.Duration(sec * float64(time.Second))
+		}
+	case int:
+		if v > 0 {
+			return time.Duration(v) * time.Second
+		}
+	case float64:
+		if v > 0 {
+			return time.Duration(v * float64(time.Second))
+		}
+	case time.Duration:
+		if v > 0 {
+			return v
+		}
+	}
+	return def
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// LoudnessOptions 响度归一化参数
+type LoudnessOptions struct {
+	TargetLUFS float64 // 目标积分响度
+	MaxGain    float64 // 最大提升量(dB)，避免把噪声或极轻的音频放得过大
+	Ceiling    float64 // 限幅器的峰值上限(dBFS)
+}
+
+// biquad 二阶 IIR 滤波器
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting 按 ITU-R BS.1770 构造 K 计权滤波器：高频搁架 + RLB 高通，系数按采样率计算
+func kWeighting(sampleRate int) (*biquad, *biquad) {
+	fs := float64(sampleRate)
+
+	// 高频搁架
+	gain, q, fc := 3.999843853973347, 0.7071752369554196, 1681.974450955533
+	a := math.Pow(10, gain/40)
+	w0 := 2 * math.Pi * fc / fs
+	alpha := math.Sin(w0) / (2 * q)
+	cos := math.Cos(w0)
+	a0 := (a + 1) - (a-1)*cos + 2*math.Sqrt(a)*alpha
+	shelf := &biquad{
+		b0: a * ((a + 1) + (a-1)*cos + 2*math.Sqrt(a)*alpha) / a0,
+		b1: -2 * a * ((a - 1) + (a+1)*cos) / a0,
+		b2: a * ((a + 1) + (a-1)*cos - 2*math.Sqrt(a)*alpha) / a0,
+		a1: 2 * ((a - 1) - (a+1)*cos) / a0,
+		a2: ((a + 1) - (a-1)*cos - 2*math.Sqrt(a)*alpha) / a0,
+	}
+
+	// 高通
+	q, fc = 0.5003270373238773, 38.13547087602444
+	w0 = 2 * math.Pi * fc / fs
+	alpha = math.Sin(w0) / (2 * q)
+	cos = math.Cos(w0)
+	a0 = 1 + alpha
+	highpass := &biquad{
+		b0: (1 + cos) / 2 / a0,
+		b1: -(1 + cos) / a0,
+		b2: (1 + cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+	return shelf, highpass
+}
+
+// pcm16ToFloat 把16位小端 PCM 转为 [-1, 1) 的浮点样本
+func pcm16ToFloat(data []byte) []float64 {
+	samples := make([]float64, len(data)/2)
+	for i := range samples {
+		samples[i] = float64(int16(binary.LittleEndian.Uint16(data[i*2:]))) / 32768
+	}
+	return samples
+}
+
+// IntegratedLoudness 计算单声道16位 PCM 的积分响度(LUFS)，
+// 400ms 块、75% 重叠，先按 -70 LUFS 绝对门限、再按低于均值 10 LU 的相对门限剔除静音块；
+// 音频短于一个块时整段作为一个块，全部被门限剔除时返回 -Inf
+func IntegratedLoudness(pcm []byte, sampleRate int) float64 {
+	samples := pcm16ToFloat(pcm)
+	if len(samples) == 0 {
+		return math.Inf(-1)
+	}
+	shelf, highpass := kWeighting(sampleRate)
+	squared := make([]float64, len(samples))
+	for i, s := range samples {
+		y := highpass.process(shelf.process(s))
+		squared[i] = y * y
+	}
+
+	blockSize := sampleRate * 400 / 1000
+	step := blockSize / 4
+	if len(squared) < blockSize {
+		blockSize, step = len(squared), len(squared)
+	}
+	// 前缀和求每块的均方
+	prefix := make([]float64, len(squared)+1)
+	for i, v := range squared {
+		prefix[i+1] = prefix[i] + v
+	}
+	var blocks []float64
+	for start := 0; start+blockSize <= len(squared); start += step {
+		blocks = append(blocks, (prefix[start+blockSize]-prefix[start])/float64(blockSize))
+	}
+
+	loudness := func(power float64) float64 { return -0.691 + 10*math.Log10(power) }
+	gated := func(threshold float64) (float64, int) {
+		sum, n := 0.0, 0
+		for _, power := range blocks {
+			if power > 0 && loudness(power) > threshold {
+				sum += power
+				n++
+			}
+		}
+		return sum, n
+	}
+	sum, n := gated(-70)
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	sum, n = gated(loudness(sum/float64(n)) - 10)
+	if n == 0 {
+		return math.Inf(-1)
+	}
+	return loudness(sum / float64(n))
+}
+
+// NormalizeLoudness 把单声道16位 PCM 调整到目标响度，再经峰值限幅器防止削波
+// 静音或无法测量响度时原样返回
+func NormalizeLoudness(pcm []byte, sampleRate int, opts LoudnessOptions) []byte {
+	measured := IntegratedLoudness(pcm, sampleRate)
+	if math.IsInf(measured, -1) || math.IsNaN(measured) {
+		return pcm
+	}
+	gainDB := opts.TargetLUFS - measured
+	if opts.MaxGain > 0 && gainDB > opts.MaxGain {
+		gainDB = opts.MaxGain
+	}
+	gain := math.Pow(10, gainDB/20)
+	ceiling := math.Pow(10, math.Min(opts.Ceiling, 0)/20)
+
+	// 瞬时压限、按 50ms 时间常数恢复的限幅器
+	release := 1 - math.Exp(-1/(0.05*float64(sampleRate)))
+	reduction := 1.0
+	out := make([]byte, len(pcm)-len(pcm)%2)
+	for i := 0; i+1 < len(pcm); i += 2 {
+		x := float64(int16(binary.LittleEndian.Uint16(pcm[i:]))) / 32768 * gain
+		if peak := math.Abs(x) * reduction; peak > ceiling {
+			reduction = ceiling / math.Abs(x)
+		}
+		y := x * reduction
+		reduction += (1 - reduction) * release
+		binary.LittleEndian.PutUint16(out[i:], uint16(int16(math.Max(-1, math.Min(y, 32767.0/32768))*32768)))
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// DefaultRealIPHeaders 默认用于解析真实客户端IP的请求头
+var DefaultRealIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+// RealIPResolver 基于可信代理列表解析真实客户端IP
+// 只有直连地址属于可信代理时才会采信转发头，避免客户端伪造 X-Forwarded-For
+type RealIPResolver struct {
+	trusted []*net.IPNet
+	headers []string
+}
+
+// NewRealIPResolver 创建真实IP解析器，proxies 支持单个IP或CIDR
+func NewRealIPResolver(proxies []string, headers []string) (*RealIPResolver, error) {
+	r := &RealIPResolver{headers: headers}
+	if len(r.headers) == 0 {
+		r.headers = DefaultRealIPHeaders
+	}
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的可信代理地址: %s", p)
+			}
+			if ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("无效的可信代理地址: %s", p)
+		}
+		r.trusted = append(r.trusted, ipNet)
+	}
+	return r, nil
+}
+
+// Headers 返回用于解析的请求头列表
+func (r *RealIPResolver) Headers() []string {
+	return r.headers
+}
+
+// isTrusted 判断IP是否属于可信代理
+func (r *RealIPResolver) isTrusted(ip net.IP) bool {
+	for _, ipNet := range r.trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 解析请求的真实客户端IP
+func (r *RealIPResolver) ClientIP(req *http.Request) string {
+	remoteIP := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		remoteIP = host
+	}
+	ip := net.ParseIP(remoteIP)
+	if ip == nil || !r.isTrusted(ip) {
+		return remoteIP
+	}
+
+	for _, header := range r.headers {
+		value := req.Header.Get(header)
+		if value == "" {
+			continue
+		}
+		// X-Forwarded-For 从右往左跳过可信代理，第一个不可信地址即为真实客户端
+		items := strings.Split(value, ",")
+		for i := len(items) - 1; i >= 0; i-- {
+			candidate := strings.TrimSpace(items[i])
+			candidateIP := net.ParseIP(candidate)
+			if candidateIP == nil {
+				break
+			}
+			if i == 0 || !r.isTrusted(candidateIP) {
+				return candidate
+			}
+		}
+	}
+	return remoteIP
+}
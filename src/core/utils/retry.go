@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy 指数退避重试策略
+type RetryPolicy struct {
+	MaxAttempts  int                                               // 最大尝试次数（含首次），<=1 表示不重试
+	InitialDelay time.Duration                                     // 首次重试前的等待时间
+	MaxDelay     time.Duration                                     // 单次等待上限
+	Multiplier   float64                                           // 退避倍数
+	Jitter       float64                                           // 抖动比例 0~1，实际等待在 delay*(1±Jitter) 之间
+	Retryable    func(err error) bool                              // 可重试错误判断，为空时使用 IsRetryableError
+	OnRetry      func(attempt int, err error, delay time.Duration) // 每次重试前回调，可用于日志
+}
+
+// DefaultRetryPolicy 默认策略：最多3次，500ms 起步，翻倍退避，上限5秒，20%抖动
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: 500 * time.Millisecond,
+		MaxDelay:     5 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+	}
+}
+
+// WithMaxRetries 按配置的重试次数（不含首次）调整策略，负数表示沿用原值
+func (p RetryPolicy) WithMaxRetries(retries int) RetryPolicy {
+	if retries >= 0 {
+		p.MaxAttempts = retries + 1
+	}
+	return p
+}
+
+// Backoff 计算第 attempt 次重试（从1开始）前的等待时间
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if p.InitialDelay <= 0 {
+		return 0
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay = delay * (1 - jitter + 2*jitter*rand.Float64())
+	}
+	return time.Duration(delay)
+}
+
+// permanentError 标记不可重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 包装错误使 Retry 立即返回，不再重试
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// HTTPStatusError 携带 HTTP 状态码的错误，429 与 5xx 视为可重试
+type HTTPStatusError struct {
+	StatusCode int
+	Status     string
+	Body       string // 响应体的开头部分，由 DoHTTP 填写，供调用方按服务的错误码细分
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP响应错误: %d %s", e.StatusCode, e.Status)
+}
+
+// MaxErrorBodyPreview 错误响应体写入错误信息的最大字节数
+const MaxErrorBodyPreview = 512
+
+// ReadErrorBody 读取响应体的开头部分用于错误信息，不关闭响应体
+func ReadErrorBody(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, MaxErrorBodyPreview))
+	return strings.TrimSpace(string(data))
+}
+
+// DoHTTP 发送请求，非 2xx 响应关闭响应体并返回 HTTPStatusError 以便按状态码决定是否重试
+func DoHTTP(client *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body := ReadErrorBody(resp.Body)
+		return nil, fmt.Errorf("%s: %w", body, &HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body})
+	}
+	return resp, nil
+}
+
+// DoHTTPBody 与 DoHTTP 相同，成功时读取并返回完整响应体
+func DoHTTPBody(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := DoHTTP(client, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	return data, nil
+}
+
+// IsRetryableStatus 判断 HTTP 状态码是否值得重试
+func IsRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
+
+// IsRetryableError 默认的可重试判断：网络超时、连接被重置/拒绝、意外 EOF、429/5xx
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var perm *permanentError
+	if errors.As(err, &perm) {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return IsRetryableStatus(statusErr.StatusCode)
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// 部分库只返回文本错误
+	msg := strings.ToLower(err.Error())
+	for _, keyword := range []string{"connection reset", "connection refused", "broken pipe", "i/o timeout", "tls handshake timeout", "unexpected eof"} {
+		if strings.Contains(msg, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Retry 按策略执行 fn，直到成功、遇到不可重试错误、次数用尽或 ctx 结束
+// fn 的参数为当前尝试序号（从1开始）
+func Retry(ctx context.Context, policy RetryPolicy, fn func(attempt int) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryableError
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err = fn(attempt)
+		if err == nil {
+			return nil
+		}
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		if attempt == maxAttempts || !retryable(err) {
+			return err
+		}
+
+		delay := policy.Backoff(attempt)
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%v（重试被取消: %v）", err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+// RetryWithResult 带返回值的 Retry
+func RetryWithResult[T any](ctx context.Context, policy RetryPolicy, fn func(attempt int) (T, error)) (T, error) {
+	var result T
+	err := Retry(ctx, policy, func(attempt int) error {
+		r, err := fn(attempt)
+		if err != nil {
+			return err
+		}
+		result = r
+		return nil
+	})
+	return result, err
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // splitAtLastPunctuation 在最后一个标点符号处分割文本
@@ -24,6 +26,90 @@ func SplitAtLastPunctuation(text string) (string, int) {
 	return text[:lastIndex+len("。")], lastIndex + len("。")
 }
 
+// sentenceBreaks 句末标点，拆分长文本时优先在这些位置断开
+const sentenceBreaks = "。！？；!?;\n"
+
+// clauseBreaks 句中停顿标点，找不到句末标点时在这些位置断开
+const clauseBreaks = "，、：,:"
+
+// SplitTextByLength 将超长文本拆分为不超过 maxLen 字节的多段
+// 优先在句末标点处断开，其次在逗号等停顿处，都没有时按字符边界硬切，不会截断多字节字符
+func SplitTextByLength(text string, maxLen int) []string {
+	var pieces []string
+	for len(text) > maxLen {
+		cut := lastBreak(text[:maxLen], sentenceBreaks)
+		if cut <= 0 {
+			cut = lastBreak(text[:maxLen], clauseBreaks)
+		}
+		if cut <= 0 {
+			cut = maxLen
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				_, cut = utf8.DecodeRuneInString(text)
+			}
+		}
+		if piece := strings.TrimSpace(text[:cut]); piece != "" {
+			pieces = append(pieces, piece)
+		}
+		text = text[cut:]
+	}
+	if piece := strings.TrimSpace(text); piece != "" {
+		pieces = append(pieces, piece)
+	}
+	return pieces
+}
+
+// lastBreak 返回 text 中最后一个断句标点之后的位置，没有时返回 -1
+func lastBreak(text string, breaks string) int {
+	idx := strings.LastIndexAny(text, breaks)
+	if idx < 0 {
+		return -1
+	}
+	_, size := utf8.DecodeRuneInString(text[idx:])
+	return idx + size
+}
+
+// TextSimilarity 计算两段文本的相似度（0~1），忽略标点与空白，按相邻字符二元组的 Dice 系数计算
+func TextSimilarity(a, b string) float64 {
+	ra, rb := normalizeRunes(a), normalizeRunes(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	if string(ra) == string(rb) {
+		return 1
+	}
+	if len(ra) < 2 || len(rb) < 2 {
+		return 0
+	}
+	bigrams := make(map[[2]rune]int, len(ra))
+	for i := 0; i < len(ra)-1; i++ {
+		bigrams[[2]rune{ra[i], ra[i+1]}]++
+	}
+	common := 0
+	for i := 0; i < len(rb)-1; i++ {
+		key := [2]rune{rb[i], rb[i+1]}
+		if bigrams[key] > 0 {
+			bigrams[key]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(ra)+len(rb)-2)
+}
+
+// normalizeRunes 去掉标点与空白并转小写
+func normalizeRunes(text string) []rune {
+	runes := make([]rune, 0, len(text))
+	for _, r := range text {
+		if unicode.IsPunct(r) || unicode.IsSpace(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		runes = append(runes, unicode.ToLower(r))
+	}
+	return runes
+}
+
 func RemoveMarkdownSyntax(text string) string {
 	// 定义需要保留的标点（中文、英文常用标点）
 	//preservedPunct := `[.,!?;，。！？、；：]`
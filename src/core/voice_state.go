@@ -0,0 +1,166 @@
+package core
+
+import "sync"
+
+// VoiceState 连接的讲话状态
+type VoiceState int
+
+const (
+	VoiceIdle      VoiceState = iota // 空闲：服务端没有播报，客户端没有拾音
+	VoiceListening                   // 拾音：客户端正在上传语音，服务端没有播报
+	VoiceSpeaking                    // 播报：服务端正在生成或下发回复
+	VoiceClosing                     // 告别：播完告别语后关闭连接
+)
+
+func (s VoiceState) String() string {
+	switch s {
+	case VoiceIdle:
+		return "Idle"
+	case VoiceListening:
+		return "Listening"
+	case VoiceSpeaking:
+		return "Speaking"
+	case VoiceClosing:
+		return "Closing"
+	default:
+		return "Unknown"
+	}
+}
+
+// VoiceEvent 驱动讲话状态变化的事件
+type VoiceEvent int
+
+const (
+	EventListenStart     VoiceEvent = iota // 客户端开始拾音
+	EventListenStop                        // 客户端停止拾音
+	EventSpeakStart                        // 服务端开始一轮回复
+	EventTTSDone                           // 本轮回复播放完毕，或没有需要播放的内容
+	EventUserBargeIn                       // 用户打断服务端播报
+	EventFarewell                          // 播报告别语，播完后关闭连接
+	EventServerInterrupt                   // 服务端主动打断播报，如推送播报，不计入用户打断
+)
+
+func (e VoiceEvent) String() string {
+	switch e {
+	case EventListenStart:
+		return "ListenStart"
+	case EventListenStop:
+		return "ListenStop"
+	case EventSpeakStart:
+		return "SpeakStart"
+	case EventTTSDone:
+		return "TTSDone"
+	case EventUserBargeIn:
+		return "UserBargeIn"
+	case EventFarewell:
+		return "Farewell"
+	case EventServerInterrupt:
+		return "ServerInterrupt"
+	default:
+		return "Unknown"
+	}
+}
+
+// VoiceTransition 一次事件处理前后的状态
+type VoiceTransition struct {
+	Event VoiceEvent
+	From  VoiceState
+	To    VoiceState
+}
+
+// VoiceStateMachine 讲话状态机，各协程只通过 Fire 投递事件改变状态
+// 拾音与打断是与主状态正交的标志：realtime 模式下客户端在服务端播报时也在拾音，
+// 打断标志在开始新一轮回复前保持，供仍在发送音频的协程判断是否停止
+type VoiceStateMachine struct {
+	mu           sync.Mutex
+	state        VoiceState
+	listening    bool
+	interrupted  bool
+	byServer     bool // 最近一次打断来自服务端
+	onTransition func(VoiceTransition)
+}
+
+// NewVoiceStateMachine 创建讲话状态机，onTransition 在每次事件处理后调用（不持有锁）
+func NewVoiceStateMachine(onTransition func(VoiceTransition)) *VoiceStateMachine {
+	return &VoiceStateMachine{state: VoiceIdle, onTransition: onTransition}
+}
+
+// Fire 投递事件并返回状态变化
+func (m *VoiceStateMachine) Fire(event VoiceEvent) VoiceTransition {
+	m.mu.Lock()
+	t := VoiceTransition{Event: event, From: m.state}
+	switch event {
+	case EventListenStart:
+		m.listening = true
+		if m.state == VoiceIdle {
+			m.state = VoiceListening
+		}
+	case EventListenStop:
+		m.listening = false
+		if m.state == VoiceListening {
+			m.state = VoiceIdle
+		}
+	case EventSpeakStart:
+		m.interrupted = false
+		m.byServer = false
+		if m.state != VoiceClosing {
+			m.state = VoiceSpeaking
+		}
+	case EventTTSDone:
+		if m.state == VoiceSpeaking {
+			m.state = m.quietState()
+		}
+	case EventUserBargeIn, EventServerInterrupt:
+		m.interrupted = true
+		m.byServer = event == EventServerInterrupt
+		if m.state == VoiceSpeaking {
+			m.state = m.quietState()
+		}
+	case EventFarewell:
+		m.state = VoiceClosing
+	}
+	t.To = m.state
+	callback := m.onTransition
+	m.mu.Unlock()
+
+	if callback != nil {
+		callback(t)
+	}
+	return t
+}
+
+// quietState 服务端不再播报时应处的状态，调用方需持有锁
+func (m *VoiceStateMachine) quietState() VoiceState {
+	if m.listening {
+		return VoiceListening
+	}
+	return VoiceIdle
+}
+
+// State 返回当前状态
+func (m *VoiceStateMachine) State() VoiceState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// Listening 客户端是否在拾音
+func (m *VoiceStateMachine) Listening() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.listening
+}
+
+// Interrupted 本轮回复是否已被打断，被打断后不再合成和下发音频
+func (m *VoiceStateMachine) Interrupted() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.interrupted
+}
+
+// InterruptedByServer 本轮回复是否被服务端打断，而不是被用户打断
+func (m *VoiceStateMachine) InterruptedByServer() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.interrupted && m.byServer
+}
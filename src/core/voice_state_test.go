@@ -0,0 +1,99 @@
+package core
+
+import "testing"
+
+func TestVoiceStateMachineBargeInWhileListening(t *testing.T) {
+	m := NewVoiceStateMachine(nil)
+	m.Fire(EventListenStart)
+	m.Fire(EventSpeakStart)
+	if m.State() != VoiceSpeaking || !m.Listening() {
+		t.Fatalf("realtime 模式播报时应为 Speaking 且仍在拾音，实际 %s, listening=%t", m.State(), m.Listening())
+	}
+
+	tr := m.Fire(EventUserBargeIn)
+	if tr.From != VoiceSpeaking || tr.To != VoiceListening {
+		t.Fatalf("拾音中打断应从 Speaking 回到 Listening，实际 %s -> %s", tr.From, tr.To)
+	}
+	if !m.Interrupted() {
+		t.Fatal("打断后应保持打断标志")
+	}
+
+	m.Fire(EventSpeakStart)
+	if m.Interrupted() {
+		t.Fatal("开始新一轮回复时应清除打断标志")
+	}
+}
+
+func TestVoiceStateMachineBargeInWithoutListening(t *testing.T) {
+	m := NewVoiceStateMachine(nil)
+	m.Fire(EventSpeakStart)
+	if tr := m.Fire(EventUserBargeIn); tr.To != VoiceIdle {
+		t.Fatalf("未拾音时打断应回到 Idle，实际 %s", tr.To)
+	}
+	// 空闲时的打断不改变状态
+	if tr := m.Fire(EventUserBargeIn); tr.From != VoiceIdle || tr.To != VoiceIdle {
+		t.Fatalf("空闲时打断不应改变状态，实际 %s -> %s", tr.From, tr.To)
+	}
+}
+
+func TestVoiceStateMachineListenAndSpeak(t *testing.T) {
+	m := NewVoiceStateMachine(nil)
+	steps := []struct {
+		event VoiceEvent
+		want  VoiceState
+	}{
+		{EventListenStart, VoiceListening},
+		{EventListenStop, VoiceIdle},
+		{EventSpeakStart, VoiceSpeaking},
+		{EventListenStart, VoiceSpeaking}, // 播报中开始拾音不改变主状态
+		{EventTTSDone, VoiceListening},
+		{EventListenStop, VoiceIdle},
+		{EventTTSDone, VoiceIdle}, // 未在播报时的 TTSDone 不改变状态
+	}
+	for i, step := range steps {
+		if tr := m.Fire(step.event); tr.To != step.want {
+			t.Fatalf("第 %d 步 %s 后状态为 %s，期望 %s", i+1, step.event, tr.To, step.want)
+		}
+	}
+}
+
+func TestVoiceStateMachineFarewellThenClose(t *testing.T) {
+	var transitions []VoiceTransition
+	m := NewVoiceStateMachine(func(tr VoiceTransition) { transitions = append(transitions, tr) })
+	m.Fire(EventSpeakStart)
+	m.Fire(EventFarewell)
+	if m.State() != VoiceClosing {
+		t.Fatalf("告别后应为 Closing，实际 %s", m.State())
+	}
+
+	// 告别语播完、再开始新的回复或打断都不能离开 Closing，连接随后关闭
+	for _, event := range []VoiceEvent{EventTTSDone, EventSpeakStart, EventUserBargeIn, EventListenStart, EventListenStop} {
+		if tr := m.Fire(event); tr.To != VoiceClosing {
+			t.Fatalf("Closing 状态下 %s 后变为 %s", event, tr.To)
+		}
+	}
+
+	if len(transitions) != 7 {
+		t.Fatalf("回调次数为 %d，期望每次事件一次共 7 次", len(transitions))
+	}
+	if farewell := transitions[1]; farewell.Event != EventFarewell || farewell.From != VoiceSpeaking || farewell.To != VoiceClosing {
+		t.Fatalf("告别的状态变化记录为 %+v", farewell)
+	}
+}
+
+func TestVoiceStateMachineServerInterrupt(t *testing.T) {
+	m := NewVoiceStateMachine(nil)
+	m.Fire(EventSpeakStart)
+	if tr := m.Fire(EventServerInterrupt); tr.From != VoiceSpeaking || tr.To != VoiceIdle {
+		t.Fatalf("服务端打断应从 Speaking 回到 Idle，实际 %s -> %s", tr.From, tr.To)
+	}
+	if !m.Interrupted() || !m.InterruptedByServer() {
+		t.Fatal("服务端打断后应标记为被服务端打断")
+	}
+
+	m.Fire(EventSpeakStart)
+	m.Fire(EventUserBargeIn)
+	if !m.Interrupted() || m.InterruptedByServer() {
+		t.Fatal("用户打断不应标记为被服务端打断")
+	}
+}
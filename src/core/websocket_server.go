@@ -3,13 +3,27 @@ package core
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"xiaozhi-server-go/src/auth"
+	"xiaozhi-server-go/src/calendar"
 	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/chat"
 	"xiaozhi-server-go/src/core/pool"
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/punctuation"
 	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/device"
+	"xiaozhi-server-go/src/metrics"
 	"xiaozhi-server-go/src/task"
+	"xiaozhi-server-go/src/transcript"
 
 	"github.com/gorilla/websocket"
 )
@@ -61,8 +75,21 @@ type WebSocketServer struct {
 	upgrader          Upgrader
 	logger            *utils.Logger
 	taskMgr           *task.TaskManager
-	poolManager       *pool.PoolManager // 替换providers
-	activeConnections sync.Map          // 存储 clientID -> *ConnectionContext
+	reminders         *reminderScheduler     // 提醒调度，到点推送给在线设备
+	greetings         *greetingCache         // 欢迎语音频缓存，未启用欢迎语时为 nil
+	quickReplies      *greetingCache         // 唤醒词快速回复音频缓存，未启用快速回复时为 nil
+	quickReplyWarming sync.Map               // 正在预合成快速回复的音色
+	careLimiter       *careLimiter           // 主动关怀频控
+	holidays          *calendar.HolidayBook  // 法定节假日查询，按年缓存
+	companions        *companionHub          // 按设备ID管理的伴随连接
+	authenticator     *auth.Authenticator    // 伴随连接握手鉴权，未启用伴随连接时为 nil
+	poolManager       *pool.PoolManager      // 替换providers
+	activeConnections sync.Map               // 存储 clientID -> *ConnectionContext
+	realIP            *utils.RealIPResolver  // 基于可信代理解析真实客户端IP
+	responseCache     *chat.ResponseCache    // LLM 回复缓存，未启用时为 nil
+	audioCache        *utils.AudioFrameCache // 音频帧缓存，未启用时为 nil
+	punctuation       punctuation.Restorer   // ASR 结果标点恢复，未启用时为 nil
+	serving           atomic.Bool            // Serve 正在接受连接，供看门狗探测
 }
 
 // Upgrader WebSocket升级器接口
@@ -95,6 +122,39 @@ func NewWebSocketServer(config *configs.Config, logger *utils.Logger) (*WebSocke
 			return tm
 		}(),
 	}
+	realIP, err := utils.NewRealIPResolver(config.Server.TrustedProxies, config.Server.RealIPHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("解析可信代理配置失败: %v", err)
+	}
+	ws.realIP = realIP
+	ws.reminders = newReminderScheduler(ws.taskMgr, logger, ws.findHandler)
+	ws.reminders.restore()
+	if err := transcript.FinishStale(); err != nil {
+		logger.Warn(fmt.Sprintf("清理未结束的转写记录失败: %v", err))
+	}
+	ws.careLimiter = newCareLimiter()
+	ws.holidays = calendar.NewHolidayBook(config.Calendar)
+	ws.companions = newCompanionHub()
+	if config.Companion.Enabled {
+		authenticator, err := auth.NewAuthenticator(config, logger)
+		if err != nil {
+			return nil, fmt.Errorf("创建伴随连接鉴权失败: %v", err)
+		}
+		ws.authenticator = authenticator
+	}
+
+	if cacheConfig := config.LLMCache; cacheConfig.Enabled {
+		ttl := utils.ParseTimeout(cacheConfig.TTL, time.Hour)
+		ws.responseCache = chat.NewResponseCache(ttl, cacheConfig.MaxEntries, cacheConfig.HistoryTurns)
+		logger.Info(fmt.Sprintf("已启用LLM回复缓存，有效期 %s", ttl))
+	}
+
+	restorer, err := punctuation.New(config.Punctuation)
+	if err != nil {
+		return nil, fmt.Errorf("初始化标点恢复失败: %v", err)
+	}
+	ws.punctuation = restorer
+
 	// 初始化资源池管理器
 	poolManager, err := pool.NewPoolManager(config, logger)
 	if err != nil {
@@ -102,18 +162,41 @@ func NewWebSocketServer(config *configs.Config, logger *utils.Logger) (*WebSocke
 		return nil, fmt.Errorf("初始化资源池管理器失败: %v", err)
 	}
 	ws.poolManager = poolManager
+
+	if cfg := config.AudioOutput.Cache; cfg.Enabled {
+		maxSize := cfg.MaxSize
+		if maxSize <= 0 {
+			maxSize = 32
+		}
+		ws.audioCache = utils.NewAudioFrameCache(int64(maxSize) << 20)
+	}
+	if config.Greeting.Enabled {
+		ws.greetings = newGreetingCache(logger)
+		go ws.preloadGreetings()
+	}
+	if config.QuickReply.Enabled {
+		ws.quickReplies = newGreetingCache(logger)
+		go ws.preloadQuickReplies()
+	}
 	return ws, nil
 }
 
-// Start 启动WebSocket服务器
-func (ws *WebSocketServer) Start(ctx context.Context) error {
+// Addr 返回 WebSocket 服务的监听地址
+func (ws *WebSocketServer) Addr() string {
+	return fmt.Sprintf("%s:%d", ws.config.Server.IP, ws.config.Server.Port)
+}
+
+// Start 在已监听的 listener 上启动WebSocket服务器，阻塞到服务关闭
+// 监听由调用方在启动阶段同步完成，端口被占用等错误能在通知就绪前返回
+func (ws *WebSocketServer) Start(ctx context.Context, listener net.Listener) error {
 	// 检查资源池是否正常
 	if ws.poolManager == nil {
 		ws.logger.Error("资源池管理器未初始化")
+		listener.Close()
 		return fmt.Errorf("资源池管理器未初始化")
 	}
 
-	addr := fmt.Sprintf("%s:%d", ws.config.Server.IP, ws.config.Server.Port)
+	addr := ws.Addr()
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", ws.handleWebSocket)
@@ -135,7 +218,9 @@ func (ws *WebSocketServer) Start(ctx context.Context) error {
 	}()
 
 	// 启动服务器
-	if err := ws.server.ListenAndServe(); err != nil {
+	ws.serving.Store(true)
+	defer ws.serving.Store(false)
+	if err := ws.server.Serve(listener); err != nil {
 		if err == http.ErrServerClosed {
 			ws.logger.Info("服务器已正常关闭")
 			return nil
@@ -208,6 +293,8 @@ func (ws *WebSocketServer) Stop() error {
 			return true
 		})
 
+		ws.companions.closeAll()
+
 		// 关闭资源池
 		if ws.poolManager != nil {
 			ws.poolManager.Close()
@@ -221,8 +308,45 @@ func (ws *WebSocketServer) Stop() error {
 	return nil
 }
 
+// Drain 停止接收新连接，等待现有会话自然结束（最长等待timeout），之后关闭服务器并释放资源
+func (ws *WebSocketServer) Drain(timeout time.Duration) error {
+	if ws.server == nil {
+		return nil
+	}
+	ws.logger.Info(fmt.Sprintf("WebSocket服务器停止接收新连接，等待 %d 个会话结束（最长 %v）", ws.GetActiveConnectionsCount(), timeout))
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Shutdown 只关闭监听器与空闲连接，已升级的WebSocket连接不受影响
+	if err := ws.server.Shutdown(drainCtx); err != nil {
+		ws.logger.Warn(fmt.Sprintf("停止监听时出错: %v", err))
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		count := ws.GetActiveConnectionsCount()
+		if count == 0 {
+			ws.logger.Info("所有会话已自然结束")
+			break
+		}
+		select {
+		case <-drainCtx.Done():
+			ws.logger.Warn(fmt.Sprintf("等待会话结束超时，强制关闭剩余 %d 个连接", count))
+			return ws.Stop()
+		case <-ticker.C:
+		}
+	}
+	return ws.Stop()
+}
+
 // handleWebSocket 处理WebSocket连接
 func (ws *WebSocketServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if isCompanionRequest(r) {
+		ws.handleCompanion(w, r)
+		return
+	}
 	conn, err := ws.upgrader.Upgrade(w, r)
 	if err != nil {
 		ws.logger.Error(fmt.Sprintf("WebSocket升级失败: %v", err))
@@ -230,9 +354,20 @@ func (ws *WebSocketServer) handleWebSocket(w http.ResponseWriter, r *http.Reques
 	}
 
 	clientID := fmt.Sprintf("%p", conn)
+	clientIP := ws.realIP.ClientIP(r)
+	deviceID := r.Header.Get("Device-Id")
+	if deviceID == "" {
+		deviceID = r.URL.Query().Get("device-id")
+	}
 
-	// 从资源池获取提供者集合
-	providerSet, err := ws.poolManager.GetProviderSet()
+	// 从资源池获取提供者集合，设备配置了模块选择时使用对应的提供者，获取失败时回退到全局配置
+	config, modules := ws.config.ForDevice(deviceID)
+	providerSet, err := ws.poolManager.GetProviderSetFor(modules)
+	if err != nil && len(modules) > 0 {
+		ws.logger.Warn(fmt.Sprintf("获取设备 %s 的提供者失败，使用全局配置: %v", deviceID, err))
+		config = ws.config
+		providerSet, err = ws.poolManager.GetProviderSet()
+	}
 	if err != nil {
 		ws.logger.Error(fmt.Sprintf("获取提供者集合失败: %v", err))
 		conn.Close()
@@ -240,9 +375,32 @@ func (ws *WebSocketServer) handleWebSocket(w http.ResponseWriter, r *http.Reques
 	}
 
 	// 创建新的连接处理器
-	handler := NewConnectionHandler(ws.config, providerSet, ws.logger)
+	handler := NewConnectionHandler(config, providerSet, ws.logger)
 
 	handler.taskMgr = ws.taskMgr
+	handler.reminders = ws.reminders
+	handler.greetings = ws.greetings
+	handler.quickReplies = ws.quickReplies
+	if ws.quickReplies != nil {
+		handler.prewarmQuickReplies = ws.prewarmQuickReplies
+	}
+	handler.careLimiter = ws.careLimiter
+	handler.holidays = ws.holidays
+	handler.summarizeTranscript = ws.summarizeTranscript
+	handler.responseCache = ws.responseCache
+	handler.audioCache = ws.audioCache
+	handler.punctuation = ws.punctuation
+	handler.clientIP = clientIP
+	handler.deviceID = deviceID
+	// 官方固件在握手头中声明二进制协议版本，hello 中的 version 可再覆盖
+	if version, err := strconv.Atoi(r.Header.Get("Protocol-Version")); err == nil {
+		handler.caps.version.Store(int32(negotiateVersion(version)))
+	}
+
+	// 启用伴随连接时，设备下发的文本消息同步给同一设备的 App
+	if ws.config.Companion.Enabled && handler.deviceID != "" {
+		conn = &mirrorConn{Conn: conn, deviceID: handler.deviceID, hub: ws.companions}
+	}
 
 	// 创建连接上下文
 	connCtx := &ConnectionContext{
@@ -256,23 +414,113 @@ func (ws *WebSocketServer) handleWebSocket(w http.ResponseWriter, r *http.Reques
 
 	// 存储连接上下文
 	ws.activeConnections.Store(clientID, connCtx)
+	metrics.ActiveConnections.Inc()
 
-	ws.logger.Info(fmt.Sprintf("客户端 %s 连接已建立，资源已分配", clientID))
+	ws.logger.Info(fmt.Sprintf("客户端 %s (%s) 连接已建立，资源已分配", clientID, clientIP))
+	if handler.deviceID != "" {
+		ws.companions.broadcastStatus(handler.deviceID, true)
+		if err := device.MarkConnected(handler.deviceID, clientIP, handler.connectedAt); err != nil {
+			ws.logger.Warn(fmt.Sprintf("登记设备 %s 的连接失败: %v", handler.deviceID, err))
+		}
+	}
 
 	// 启动连接处理，并在结束时清理资源
 	go func() {
 		defer func() {
 			// 连接结束时清理
 			ws.activeConnections.Delete(clientID)
+			metrics.ActiveConnections.Dec()
 			if err := connCtx.Close(); err != nil {
 				ws.logger.Error(fmt.Sprintf("清理连接上下文失败: %v", err))
 			}
+			if handler.deviceID != "" && ws.findHandler(handler.deviceID) == nil {
+				ws.companions.broadcastStatus(handler.deviceID, false)
+			}
+			if handler.deviceID != "" {
+				if err := device.MarkDisconnected(handler.deviceID, handler.dialogueRounds.Load(), handler.lastActive()); err != nil {
+					ws.logger.Warn(fmt.Sprintf("记录设备 %s 的断开失败: %v", handler.deviceID, err))
+				}
+			}
 		}()
 
 		handler.Handle(conn)
 	}()
 }
 
+// preloadGreetings 从资源池借用一组提供者预合成欢迎语，用完归还
+func (ws *WebSocketServer) preloadGreetings() {
+	set, err := ws.poolManager.GetProviderSet()
+	if err != nil {
+		ws.logger.Warn(fmt.Sprintf("预合成欢迎语时获取提供者失败: %v", err))
+		return
+	}
+	defer ws.poolManager.ReturnProviderSet(set)
+	if set.TTS == nil {
+		return
+	}
+	ws.greetings.preload(ws.config, set.TTS)
+}
+
+// preloadQuickReplies 启动时预合成快速回复：默认音色的默认词组，以及每个角色用其音色的词组
+func (ws *WebSocketServer) preloadQuickReplies() {
+	cfg := ws.config.QuickReply
+	ws.prewarmQuickReplies("", selectQuickReplyWords(cfg, "", ""))
+	for _, role := range ws.config.Roles {
+		ws.prewarmQuickReplies(role.Voice, selectQuickReplyWords(cfg, role.Name, role.Language))
+	}
+}
+
+// prewarmQuickReplies 从资源池借用一组提供者，用指定音色合成尚未缓存的快速回复，voice 为空时使用配置的音色
+// 同一音色同时只预合成一次
+func (ws *WebSocketServer) prewarmQuickReplies(voice string, words []string) {
+	ttsName := ws.config.SelectedModule["TTS"]
+	var missing []string
+	for _, word := range words {
+		if !ws.quickReplies.has(greetingKey(ttsName, voice, word)) {
+			missing = append(missing, word)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	if _, running := ws.quickReplyWarming.LoadOrStore(voice, true); running {
+		return
+	}
+	defer ws.quickReplyWarming.Delete(voice)
+
+	set, err := ws.poolManager.GetProviderSet()
+	if err != nil {
+		ws.logger.Warn(fmt.Sprintf("预合成快速回复时获取提供者失败: %v", err))
+		return
+	}
+	defer ws.poolManager.ReturnProviderSet(set)
+	if set.TTS == nil {
+		return
+	}
+	if voice != "" {
+		setter, ok := set.TTS.(tts.VoiceSetter)
+		if !ok {
+			return
+		}
+		if err := setter.SetVoice(voice); err != nil {
+			ws.logger.Warn(fmt.Sprintf("预合成快速回复时切换音色 %s 失败: %v", voice, err))
+			return
+		}
+	}
+	for _, word := range missing {
+		path, err := set.TTS.ToTTS(word)
+		if err != nil {
+			ws.logger.Warn(fmt.Sprintf("预合成快速回复失败: %s, %v", word, err))
+			continue
+		}
+		ws.quickReplies.store(greetingKey(ttsName, voice, word), path)
+		if ws.config.DeleteAudio {
+			os.Remove(path)
+		}
+	}
+	ws.logger.Info(fmt.Sprintf("快速回复音频预合成完成，音色: %s，共 %d 条", voice, len(missing)))
+}
+
 // GetPoolStats 获取资源池统计信息（用于监控）
 func (ws *WebSocketServer) GetPoolStats() map[string]map[string]int {
 	if ws.poolManager == nil {
@@ -281,6 +529,104 @@ func (ws *WebSocketServer) GetPoolStats() map[string]map[string]int {
 	return ws.poolManager.GetDetailedStats()
 }
 
+// CheckAlive 供看门狗探测服务是否存活：WebSocket 服务仍在接受连接，且资源池的锁能正常获取
+// 资源池死锁时本方法不会返回，由调用方按超时判定失败
+func (ws *WebSocketServer) CheckAlive() error {
+	if !ws.serving.Load() {
+		return fmt.Errorf("WebSocket 服务未在运行")
+	}
+	if ws.poolManager == nil {
+		return fmt.Errorf("资源池管理器未初始化")
+	}
+	ws.poolManager.GetDetailedStats()
+	return nil
+}
+
+// GetProviderHealth 返回启动时各模块的连通性检查结果（用于健康检查接口）
+func (ws *WebSocketServer) GetProviderHealth() []metrics.ProviderHealth {
+	if ws.poolManager == nil {
+		return nil
+	}
+	results := ws.poolManager.GetHealthResults()
+	modules := make([]string, 0, len(results))
+	for module := range results {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	health := make([]metrics.ProviderHealth, 0, len(modules))
+	for _, module := range modules {
+		result := results[module]
+		h := metrics.ProviderHealth{
+			Module:     module,
+			Success:    result.Success,
+			Optional:   module == "VLLLM",
+			Mode:       "basic",
+			DurationMs: result.Duration.Milliseconds(),
+			CheckedAt:  result.Timestamp,
+			Details:    result.Details,
+		}
+		if result.CheckMode == pool.FunctionalCheck {
+			h.Mode = "functional"
+		}
+		if result.Error != nil {
+			h.Error = result.Error.Error()
+		}
+		health = append(health, h)
+	}
+	return health
+}
+
+// findHandler 查找设备当前的连接处理器，设备不在线时返回 nil
+func (ws *WebSocketServer) findHandler(deviceID string) *ConnectionHandler {
+	var found *ConnectionHandler
+	ws.activeConnections.Range(func(key, value interface{}) bool {
+		if ctx, ok := value.(*ConnectionContext); ok && ctx.handler != nil && ctx.handler.deviceID == deviceID {
+			found = ctx.handler
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// ConnectedDevices 实现 device.Runtime，返回所有带设备ID的在线连接
+func (ws *WebSocketServer) ConnectedDevices() []device.Connection {
+	var conns []device.Connection
+	ws.activeConnections.Range(func(key, value interface{}) bool {
+		ctx, ok := value.(*ConnectionContext)
+		if !ok || ctx.handler == nil || ctx.handler.deviceID == "" {
+			return true
+		}
+		h := ctx.handler
+		conns = append(conns, device.Connection{
+			DeviceID:     h.deviceID,
+			ClientID:     ctx.clientID,
+			ClientIP:     h.clientIP,
+			SessionID:    h.sessionID,
+			ConnectedAt:  h.connectedAt,
+			LastActiveAt: h.lastActive(),
+			TalkRounds:   h.dialogueRounds.Load(),
+		})
+		return true
+	})
+	return conns
+}
+
+// DisconnectDevice 实现 device.Runtime，关闭设备的全部连接，返回关闭的连接数
+// 只关闭底层连接，资源由连接协程退出时统一归还
+func (ws *WebSocketServer) DisconnectDevice(deviceID string) int {
+	closed := 0
+	ws.activeConnections.Range(func(key, value interface{}) bool {
+		if ctx, ok := value.(*ConnectionContext); ok && ctx.handler != nil && ctx.handler.deviceID == deviceID {
+			ws.logger.Info(fmt.Sprintf("管理接口强制断开设备 %s 的连接 %s", deviceID, ctx.clientID))
+			ctx.conn.Close()
+			closed++
+		}
+		return true
+	})
+	return closed
+}
+
 // GetActiveConnectionsCount 获取活跃连接数
 func (ws *WebSocketServer) GetActiveConnectionsCount() int {
 	count := 0
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	mu     sync.Mutex
+	db     *gorm.DB
+	models []interface{} // 各模块在 init 中注册的数据模型，Init 时统一迁移
+)
+
+// RegisterModel 注册需要自动迁移的数据模型，在各模块的 init 中调用
+func RegisterModel(model ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	models = append(models, model...)
+}
+
+// Init 打开数据库并迁移所有已注册的模型
+func Init(config *configs.Config) (*gorm.DB, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if db != nil {
+		return db, nil
+	}
+
+	dbType := config.Database.Type
+	if dbType == "" {
+		dbType = "sqlite"
+	}
+
+	var dialector gorm.Dialector
+	switch dbType {
+	case "sqlite":
+		dsn := config.Database.DSN
+		if dsn == "" {
+			dsn = "data/xiaozhi.db"
+		}
+		if dir := filepath.Dir(dsn); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("创建数据库目录失败: %v", err)
+			}
+		}
+		dialector = sqlite.Open(dsn)
+	default:
+		return nil, fmt.Errorf("不支持的数据库类型: %s", dbType)
+	}
+
+	conn, err := gorm.Open(dialector, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %v", err)
+	}
+
+	// sqlite 单写者，限制连接数避免 database is locked
+	if dbType == "sqlite" {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.SetMaxOpenConns(1)
+		}
+	}
+
+	if len(models) > 0 {
+		if err := conn.AutoMigrate(models...); err != nil {
+			return nil, fmt.Errorf("数据库迁移失败: %v", err)
+		}
+	}
+
+	db = conn
+	return db, nil
+}
+
+// GetDB 获取已初始化的数据库连接，未初始化时返回 nil
+func GetDB() *gorm.DB {
+	mu.Lock()
+	defer mu.Unlock()
+	return db
+}
+
+// Close 关闭数据库连接
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if db == nil {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	db = nil
+	return sqlDB.Close()
+}
@@ -0,0 +1,244 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"xiaozhi-server-go/src/audit"
+	"xiaozhi-server-go/src/auth"
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/profile"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Runtime 在线连接的来源，由 WebSocket 服务实现
+type Runtime interface {
+	ConnectedDevices() []Connection
+	DisconnectDevice(deviceID string) int
+}
+
+// Connection 设备的一个在线连接
+type Connection struct {
+	DeviceID     string    `json:"device_id"`
+	ClientID     string    `json:"client_id"`
+	ClientIP     string    `json:"client_ip"`
+	SessionID    string    `json:"session_id"`
+	ConnectedAt  time.Time `json:"connected_at"`
+	LastActiveAt time.Time `json:"last_active_at"`
+	TalkRounds   int64     `json:"talk_rounds"` // 本连接的对话轮次
+}
+
+// Info 设备详情：注册表记录合并在线连接，talk_rounds 与 last_active_at 包含在线连接的最新数据
+type Info struct {
+	Device
+	TTS    string       `json:"tts"`   // 用户或管理员指定音色所属的 TTS 配置名称
+	Voice  string       `json:"voice"` // 用户或管理员指定的音色，为空表示使用默认音色
+	Online bool         `json:"online"`
+	Active []Connection `json:"active_connections"`
+}
+
+// Service 设备管理接口，挂在 /api/admin 下
+type Service struct {
+	config  *configs.Config
+	runtime Runtime
+	logger  *utils.Logger
+}
+
+// NewService 创建设备管理服务
+func NewService(config *configs.Config, runtime Runtime, logger *utils.Logger) *Service {
+	return &Service{config: config, runtime: runtime, logger: logger}
+}
+
+// settingRequest PUT 请求体，字段缺省表示不修改，传空串表示恢复默认
+type settingRequest struct {
+	Prompt *string `json:"prompt"`
+	Voice  *string `json:"voice"`
+}
+
+// Start 注册设备管理相关路由
+func (s *Service) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	group := apiGroup.Group("/admin/devices")
+
+	// 列出设备，online=true 时只列出在线设备，否则分页列出注册表中的设备，最近活跃的在前
+	group.GET("", func(c *gin.Context) {
+		live := s.liveConnections()
+		if c.Query("online") == "true" {
+			ids := make([]string, 0, len(live))
+			for id := range live {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			items := make([]*Info, 0, len(ids))
+			for _, id := range ids {
+				record, err := Get(id)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+					return
+				}
+				items = append(items, s.info(id, record, live[id]))
+			}
+			c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"items": items, "total": len(items)}})
+			return
+		}
+
+		page, pageSize := pagination(c)
+		devices, total, err := List(page, pageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		items := make([]*Info, 0, len(devices))
+		for i := range devices {
+			items = append(items, s.info(devices[i].DeviceID, &devices[i], live[devices[i].DeviceID]))
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+			"items": items, "total": total, "page": page, "page_size": pageSize,
+		}})
+	})
+
+	// 查询设备详情与统计
+	group.GET("/:id", func(c *gin.Context) {
+		deviceID := c.Param("id")
+		record, err := Get(deviceID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		active := s.liveConnections()[deviceID]
+		if record == nil && len(active) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "设备不存在"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": s.info(deviceID, record, active)})
+	})
+
+	// 设置设备的提示词与音色，设备下次连接时生效，在线设备可通过强制断开让设置立即生效
+	group.PUT("/:id", func(c *gin.Context) {
+		deviceID := c.Param("id")
+		var req settingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": fmt.Sprintf("请求格式错误: %v", err)})
+			return
+		}
+		if req.Prompt == nil && req.Voice == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "prompt 与 voice 至少需要设置一项"})
+			return
+		}
+
+		record, err := Get(deviceID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		if req.Prompt != nil {
+			if record, err = SavePrompt(deviceID, *req.Prompt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+				return
+			}
+			// 清除用户在对话中切换的角色，否则设备重连后仍恢复角色的提示词
+			if err := profile.SaveRole(deviceID, "", false); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+				return
+			}
+		}
+		if req.Voice != nil {
+			// 音色随设备生效的主TTS保存，与用户在对话中切换音色一致
+			config, _ := s.config.ForDevice(deviceID)
+			if err := profile.SaveVoice(deviceID, config.SelectedModule["TTS"], *req.Voice); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+				return
+			}
+		}
+		s.logger.Info(fmt.Sprintf("设备 %s 的管理设置已更新，操作者: %s", deviceID, c.GetString(auth.ContextKeySubject)))
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": s.info(deviceID, record, s.liveConnections()[deviceID])})
+	})
+
+	// 强制断开设备的全部连接，操作写入审计日志
+	group.POST("/:id/disconnect", func(c *gin.Context) {
+		deviceID := c.Param("id")
+		closed := s.runtime.DisconnectDevice(deviceID)
+		if closed == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "设备不在线"})
+			return
+		}
+		operator := c.GetString(auth.ContextKeySubject)
+		detail := fmt.Sprintf("断开连接 %d 个", closed)
+		if err := audit.Record(operator, audit.ActionDisconnectDevice, deviceID, detail); err != nil {
+			s.logger.Error(err.Error())
+		}
+		s.logger.Info(fmt.Sprintf("设备 %s 已被强制断开，%s，操作者: %s", deviceID, detail, operator))
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"disconnected": closed}})
+	})
+
+	// 删除设备的注册表记录，不影响在线连接，设备再次连接时重新登记
+	group.DELETE("/:id", func(c *gin.Context) {
+		if err := Delete(c.Param("id")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	return nil
+}
+
+// liveConnections 按设备ID分组的在线连接
+func (s *Service) liveConnections() map[string][]Connection {
+	live := make(map[string][]Connection)
+	for _, conn := range s.runtime.ConnectedDevices() {
+		live[conn.DeviceID] = append(live[conn.DeviceID], conn)
+	}
+	return live
+}
+
+// info 合并注册表记录、设备档案中的音色与在线连接，record 可为 nil
+func (s *Service) info(deviceID string, record *Device, active []Connection) *Info {
+	info := &Info{Online: len(active) > 0, Active: active}
+	if record != nil {
+		info.Device = *record
+	}
+	info.DeviceID = deviceID
+	if info.Active == nil {
+		info.Active = []Connection{}
+	}
+	for _, conn := range active {
+		info.TalkRounds += conn.TalkRounds
+		if info.LastActiveAt == nil || conn.LastActiveAt.After(*info.LastActiveAt) {
+			lastActive := conn.LastActiveAt
+			info.LastActiveAt = &lastActive
+		}
+	}
+	if p, err := profile.Get(deviceID); err != nil {
+		s.logger.Warn(fmt.Sprintf("查询设备 %s 的档案失败: %v", deviceID, err))
+	} else if p != nil {
+		info.TTS, info.Voice = p.TTS, p.Voice
+	}
+	return info
+}
+
+// pagination 解析 page 与 page_size 查询参数，page 从 1 开始，page_size 最大 100
+func pagination(c *gin.Context) (page, pageSize int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err = strconv.Atoi(c.Query("page_size"))
+	if err != nil || pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
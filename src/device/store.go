@@ -0,0 +1,127 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/database"
+
+	"gorm.io/gorm"
+)
+
+// Device 设备注册表中的一条记录，连接建立与断开时更新，累计设备的使用情况
+type Device struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	DeviceID         string     `gorm:"size:64;uniqueIndex" json:"device_id"`
+	ClientIP         string     `gorm:"size:64" json:"client_ip"` // 最近一次连接的客户端IP
+	Prompt           string     `gorm:"type:text" json:"prompt"`  // 通过管理接口设置的提示词，为空表示使用配置的提示词
+	ConnectCount     int64      `json:"connect_count"`            // 累计连接次数
+	TalkRounds       int64      `json:"talk_rounds"`              // 已结束的连接累计的对话轮次
+	LastConnectedAt  *time.Time `json:"last_connected_at"`
+	LastActiveAt     *time.Time `gorm:"index" json:"last_active_at"` // 最近一次交互时间，连接断开时写入
+	LastDisconnectAt *time.Time `json:"last_disconnect_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+func init() {
+	database.RegisterModel(&Device{})
+}
+
+// Get 查询设备记录，不存在时返回 nil
+func Get(deviceID string) (*Device, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var record Device
+	if err := db.Where("device_id = ?", deviceID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询设备记录失败: %v", err)
+	}
+	return &record, nil
+}
+
+// List 分页列出设备，最近活跃的在前，返回本页记录与总数
+func List(page, pageSize int) ([]Device, int64, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, 0, fmt.Errorf("数据库未初始化")
+	}
+	query := db.Model(&Device{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询设备记录失败: %v", err)
+	}
+	var devices []Device
+	err := query.Order("last_active_at desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&devices).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询设备记录失败: %v", err)
+	}
+	return devices, total, nil
+}
+
+// update 读取设备记录并修改后保存，记录不存在时新建
+func update(deviceID string, modify func(record *Device)) (*Device, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	record := &Device{}
+	err := db.Where("device_id = ?", deviceID).First(record).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询设备记录失败: %v", err)
+	}
+	record.DeviceID = deviceID
+	modify(record)
+	if err := db.Save(record).Error; err != nil {
+		return nil, fmt.Errorf("保存设备记录失败: %v", err)
+	}
+	return record, nil
+}
+
+// MarkConnected 记录设备建立连接
+func MarkConnected(deviceID, clientIP string, at time.Time) error {
+	_, err := update(deviceID, func(record *Device) {
+		record.ClientIP = clientIP
+		record.ConnectCount++
+		record.LastConnectedAt = &at
+		record.LastActiveAt = &at
+	})
+	return err
+}
+
+// MarkDisconnected 记录设备断开连接，累加本次连接的对话轮次
+func MarkDisconnected(deviceID string, talkRounds int64, lastActive time.Time) error {
+	now := time.Now()
+	_, err := update(deviceID, func(record *Device) {
+		record.TalkRounds += talkRounds
+		record.LastActiveAt = &lastActive
+		record.LastDisconnectAt = &now
+	})
+	return err
+}
+
+// SavePrompt 保存管理接口为设备设置的提示词，为空表示恢复使用配置的提示词
+func SavePrompt(deviceID, prompt string) (*Device, error) {
+	return update(deviceID, func(record *Device) { record.Prompt = prompt })
+}
+
+// Delete 删除设备记录，设备再次连接时重新登记
+func Delete(deviceID string) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	result := db.Where("device_id = ?", deviceID).Delete(&Device{})
+	if result.Error != nil {
+		return fmt.Errorf("删除设备记录失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("设备记录不存在")
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package dnd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"xiaozhi-server-go/src/auth"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Service 设备免打扰管理接口
+type Service struct {
+	logger *utils.Logger
+}
+
+// NewService 创建免打扰管理服务
+func NewService(logger *utils.Logger) *Service {
+	return &Service{logger: logger}
+}
+
+// settingRequest PUT 请求体，until 为 RFC3339 时间，为空表示不设临时免打扰
+type settingRequest struct {
+	Start        string `json:"start"`
+	End          string `json:"end"`
+	Until        string `json:"until"`
+	ReminderMode string `json:"reminder_mode"`
+}
+
+// Start 注册免打扰相关路由
+func (s *Service) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	group := apiGroup.Group("/dnd")
+
+	// 查询设备的免打扰设置，active 表示当前是否处于免打扰
+	group.GET("/:device_id", func(c *gin.Context) {
+		record, err := Get(c.Param("device_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		if record == nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "设备未设置免打扰"})
+			return
+		}
+		now := time.Now()
+		data := gin.H{"setting": record, "active": record.Active(now)}
+		if record.Active(now) {
+			data["active_until"] = record.EndAt(now)
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+	})
+
+	// 设置设备的免打扰，已存在时覆盖
+	group.PUT("/:device_id", func(c *gin.Context) {
+		var req settingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": fmt.Sprintf("请求格式错误: %v", err)})
+			return
+		}
+		setting := &Setting{
+			DeviceID:     c.Param("device_id"),
+			Start:        req.Start,
+			End:          req.End,
+			ReminderMode: req.ReminderMode,
+		}
+		if req.Until != "" {
+			until, err := time.Parse(time.RFC3339, req.Until)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": fmt.Sprintf("until 格式应为 RFC3339: %v", err)})
+				return
+			}
+			setting.Until = &until
+		}
+		record, err := Save(setting)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		s.logger.Info(fmt.Sprintf("设备 %s 免打扰设置已更新，操作者: %s", record.DeviceID, c.GetString(auth.ContextKeySubject)))
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": record})
+	})
+
+	// 删除设备的免打扰设置
+	group.DELETE("/:device_id", func(c *gin.Context) {
+		if err := Delete(c.Param("device_id")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	return nil
+}
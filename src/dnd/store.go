@@ -0,0 +1,182 @@
+package dnd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/database"
+
+	"gorm.io/gorm"
+)
+
+// 免打扰期间到点提醒的处理方式
+const (
+	ReminderDelay  = "delay"  // 延后到免打扰结束后播报
+	ReminderSilent = "silent" // 不播报，直接标记为已完成
+)
+
+// Setting 设备的免打扰设置，每台设备一条
+// 每天固定的时段与临时的截止时间可以同时存在，任一生效即处于免打扰
+type Setting struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	DeviceID     string     `gorm:"size:64;uniqueIndex" json:"device_id"`
+	Start        string     `gorm:"size:5" json:"start"` // 每天免打扰的开始时刻 HH:MM，与 end 同时为空表示没有固定时段
+	End          string     `gorm:"size:5" json:"end"`   // 结束时刻 HH:MM，早于 start 时表示跨过零点
+	Until        *time.Time `json:"until,omitempty"`     // 临时免打扰的截止时间，如“今晚别吵我”
+	ReminderMode string     `gorm:"size:16" json:"reminder_mode"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func init() {
+	database.RegisterModel(&Setting{})
+}
+
+func (s *Setting) validate() error {
+	if s.DeviceID == "" {
+		return fmt.Errorf("设备ID不能为空")
+	}
+	s.Start, s.End = strings.TrimSpace(s.Start), strings.TrimSpace(s.End)
+	if (s.Start == "") != (s.End == "") {
+		return fmt.Errorf("start 与 end 需同时设置")
+	}
+	if s.Start != "" {
+		if _, ok := parseClock(s.Start); !ok {
+			return fmt.Errorf("start 格式应为 HH:MM: %s", s.Start)
+		}
+		if _, ok := parseClock(s.End); !ok {
+			return fmt.Errorf("end 格式应为 HH:MM: %s", s.End)
+		}
+		if s.Start == s.End {
+			return fmt.Errorf("start 与 end 不能相同")
+		}
+	}
+	switch s.ReminderMode {
+	case "":
+		s.ReminderMode = ReminderDelay
+	case ReminderDelay, ReminderSilent:
+	default:
+		return fmt.Errorf("reminder_mode 只能是 %s 或 %s", ReminderDelay, ReminderSilent)
+	}
+	return nil
+}
+
+// Active 判断 now 是否处于免打扰
+func (s *Setting) Active(now time.Time) bool {
+	return s.temporaryActive(now) || s.dailyActive(now)
+}
+
+func (s *Setting) temporaryActive(now time.Time) bool {
+	return s.Until != nil && now.Before(*s.Until)
+}
+
+func (s *Setting) dailyActive(now time.Time) bool {
+	start, okStart := parseClock(s.Start)
+	end, okEnd := parseClock(s.End)
+	if !okStart || !okEnd {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start > end {
+		return minute >= start || minute < end
+	}
+	return minute >= start && minute < end
+}
+
+// EndAt 返回本次免打扰的结束时间，now 不在免打扰中时返回 now
+func (s *Setting) EndAt(now time.Time) time.Time {
+	end := now
+	if s.temporaryActive(now) {
+		end = *s.Until
+	}
+	// 临时免打扰结束时可能正好落在每天的固定时段内，继续顺延
+	if s.dailyActive(end) {
+		minutes, _ := parseClock(s.End)
+		at := time.Date(end.Year(), end.Month(), end.Day(), minutes/60, minutes%60, 0, 0, end.Location())
+		if !at.After(end) {
+			at = at.AddDate(0, 0, 1)
+		}
+		end = at
+	}
+	return end
+}
+
+// parseClock 解析 HH:MM，返回当天的分钟数
+func parseClock(clock string) (int, bool) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// Get 查询设备的免打扰设置，未设置时返回 nil
+func Get(deviceID string) (*Setting, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var record Setting
+	if err := db.Where("device_id = ?", deviceID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询免打扰设置失败: %v", err)
+	}
+	return &record, nil
+}
+
+// Save 保存设备的免打扰设置，已存在时覆盖
+func Save(setting *Setting) (*Setting, error) {
+	if err := setting.validate(); err != nil {
+		return nil, err
+	}
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	record := &Setting{}
+	err := db.Where("device_id = ?", setting.DeviceID).First(record).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询免打扰设置失败: %v", err)
+	}
+	record.DeviceID = setting.DeviceID
+	record.Start = setting.Start
+	record.End = setting.End
+	record.Until = setting.Until
+	record.ReminderMode = setting.ReminderMode
+	if err := db.Save(record).Error; err != nil {
+		return nil, fmt.Errorf("保存免打扰设置失败: %v", err)
+	}
+	return record, nil
+}
+
+// Delete 删除设备的免打扰设置
+func Delete(deviceID string) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	result := db.Where("device_id = ?", deviceID).Delete(&Setting{})
+	if result.Error != nil {
+		return fmt.Errorf("删除免打扰设置失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("设备 %s 未设置免打扰", deviceID)
+	}
+	return nil
+}
+
+// Check 查询设备当前是否处于免打扰，未设置或查询失败时返回 nil
+func Check(deviceID string, now time.Time) *Setting {
+	if deviceID == "" {
+		return nil
+	}
+	setting, err := Get(deviceID)
+	if err != nil || setting == nil || !setting.Active(now) {
+		return nil
+	}
+	return setting
+}
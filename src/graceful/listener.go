@@ -0,0 +1,105 @@
+package graceful
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// EnvListenFDs 子进程继承的监听器名称列表，按fd顺序以逗号分隔
+	EnvListenFDs = "XIAOZHI_LISTEN_FDS"
+	// listenFDStart 继承的fd起始编号（0/1/2为标准输入输出）
+	listenFDStart = 3
+)
+
+var (
+	mu        sync.Mutex
+	listeners = make(map[string]net.Listener) // 已创建的监听器 name -> listener
+	order     []string                        // 监听器创建顺序，决定传给子进程的fd顺序
+	inherited map[string]int                  // 从父进程继承的监听器 name -> fd
+
+	inheritedAtStart = os.Getenv(EnvListenFDs) != ""
+)
+
+func loadInherited() {
+	if inherited != nil {
+		return
+	}
+	inherited = make(map[string]int)
+	names := os.Getenv(EnvListenFDs)
+	if names == "" {
+		return
+	}
+	for i, name := range strings.Split(names, ",") {
+		if name != "" {
+			inherited[name] = listenFDStart + i
+		}
+	}
+	// 避免再往下一代进程泄露
+	os.Unsetenv(EnvListenFDs)
+}
+
+// Listen 创建名为name的TCP监听器，若父进程传递了同名fd则直接复用
+func Listen(name, addr string) (net.Listener, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	loadInherited()
+
+	var ln net.Listener
+	if fd, ok := inherited[name]; ok {
+		file := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("复用继承的监听器 %s 失败: %v", name, err)
+		}
+		delete(inherited, name)
+		ln = l
+	} else {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		ln = l
+	}
+
+	if _, exists := listeners[name]; !exists {
+		order = append(order, name)
+	}
+	listeners[name] = ln
+	return ln, nil
+}
+
+// IsInherited 当前进程是否由平滑重启拉起
+func IsInherited() bool {
+	return inheritedAtStart
+}
+
+// listenerFiles 导出当前所有监听器的文件描述符（dup），用于传递给子进程
+func listenerFiles() ([]string, []*os.File, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	names := make([]string, 0, len(order))
+	files := make([]*os.File, 0, len(order))
+	for _, name := range order {
+		tcpLn, ok := listeners[name].(*net.TCPListener)
+		if !ok {
+			continue
+		}
+		f, err := tcpLn.File()
+		if err != nil {
+			for _, opened := range files {
+				opened.Close()
+			}
+			return nil, nil, fmt.Errorf("获取监听器 %s 的文件描述符失败: %v", name, err)
+		}
+		names = append(names, name)
+		files = append(files, f)
+	}
+	return names, files, nil
+}
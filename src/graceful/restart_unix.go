@@ -0,0 +1,60 @@
+//go:build !windows
+
+package graceful
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// RestartSignals 触发平滑重启的信号
+func RestartSignals() []os.Signal {
+	return []os.Signal{syscall.SIGUSR2}
+}
+
+// IsRestartSignal 判断信号是否为平滑重启信号
+func IsRestartSignal(sig os.Signal) bool {
+	return sig == syscall.SIGUSR2
+}
+
+// Restart 以相同参数启动新进程，并把当前所有监听器的fd传递给它
+// 返回新进程的pid，调用方随后应停止接收新连接并等待现有会话结束
+func Restart() (int, error) {
+	names, files, err := listenerFiles()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	execPath, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("获取可执行文件路径失败: %v", err)
+	}
+
+	env := make([]string, 0, len(os.Environ())+1)
+	for _, kv := range os.Environ() {
+		// WATCHDOG_PID 指向老进程，由新进程接管 MAINPID 后重新生效
+		if !strings.HasPrefix(kv, EnvListenFDs+"=") && !strings.HasPrefix(kv, "WATCHDOG_PID=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, EnvListenFDs+"="+strings.Join(names, ","))
+
+	cmd := exec.Command(execPath, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = env
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("启动新进程失败: %v", err)
+	}
+	return cmd.Process.Pid, nil
+}
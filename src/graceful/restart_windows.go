@@ -0,0 +1,23 @@
+//go:build windows
+
+package graceful
+
+import (
+	"fmt"
+	"os"
+)
+
+// RestartSignals Windows 不支持基于信号的平滑重启
+func RestartSignals() []os.Signal {
+	return nil
+}
+
+// IsRestartSignal 判断信号是否为平滑重启信号
+func IsRestartSignal(sig os.Signal) bool {
+	return false
+}
+
+// Restart Windows 下不支持继承监听fd
+func Restart() (int, error) {
+	return 0, fmt.Errorf("当前平台不支持平滑重启")
+}
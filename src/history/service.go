@@ -0,0 +1,104 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"xiaozhi-server-go/src/audit"
+	"xiaozhi-server-go/src/auth"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// Service 按设备查询历史会话与消息的接口
+type Service struct {
+	logger *utils.Logger
+}
+
+// NewService 创建历史会话服务
+func NewService(logger *utils.Logger) *Service {
+	return &Service{logger: logger}
+}
+
+// Start 注册历史会话相关路由
+func (s *Service) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	// 清除设备的长期记忆：已落库的全部会话与问答，操作写入审计日志；挂在 /admin 下，仅管理员可用
+	apiGroup.DELETE("/admin/devices/:id/memory", func(c *gin.Context) {
+		deviceID := c.Param("id")
+		sessions, messages, err := ClearDevice(deviceID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		operator := c.GetString(auth.ContextKeySubject)
+		detail := fmt.Sprintf("删除会话 %d 个，消息 %d 条", sessions, messages)
+		if err := audit.Record(operator, audit.ActionClearMemory, deviceID, detail); err != nil {
+			s.logger.Error(err.Error())
+		}
+		s.logger.Info(fmt.Sprintf("设备 %s 的长期记忆已清除，%s，操作者: %s", deviceID, detail, operator))
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"sessions": sessions, "messages": messages}})
+	})
+
+	group := apiGroup.Group("/devices/:id/sessions")
+
+	// 分页列出设备的会话，最新的在前
+	group.GET("", func(c *gin.Context) {
+		page, pageSize := pagination(c)
+		sessions, total, err := ListSessions(c.Param("id"), page, pageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+			"items": sessions, "total": total, "page": page, "page_size": pageSize,
+		}})
+	})
+
+	// 分页列出会话中的问答，按时间顺序
+	group.GET("/:sid/messages", func(c *gin.Context) {
+		session, err := GetSession(c.Param("sid"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		if session == nil || session.DeviceID != c.Param("id") {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "会话不存在"})
+			return
+		}
+		page, pageSize := pagination(c)
+		messages, total, err := ListMessages(session.ID, page, pageSize)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+			"items": messages, "total": total, "page": page, "page_size": pageSize,
+		}})
+	})
+
+	return nil
+}
+
+// pagination 解析 page 与 page_size 查询参数，page 从 1 开始，page_size 最大 100
+func pagination(c *gin.Context) (page, pageSize int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	pageSize, err = strconv.Atoi(c.Query("page_size"))
+	if err != nil || pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
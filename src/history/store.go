@@ -0,0 +1,242 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/core/types"
+	"xiaozhi-server-go/src/database"
+
+	"gorm.io/gorm"
+)
+
+// Session 设备的一次连接会话
+type Session struct {
+	ID           string     `gorm:"primaryKey;size:64" json:"id"`
+	DeviceID     string     `gorm:"size:64;index" json:"device_id"`
+	ClientIP     string     `gorm:"size:64" json:"client_ip"`
+	StartedAt    time.Time  `gorm:"index" json:"started_at"`
+	EndedAt      *time.Time `json:"ended_at,omitempty"`
+	MessageCount int        `json:"message_count"`
+}
+
+// Message 会话中的一条对话消息
+type Message struct {
+	ID         uint             `gorm:"primaryKey" json:"id"`
+	SessionID  string           `gorm:"size:64;index" json:"session_id"`
+	Role       string           `gorm:"size:16" json:"role"` // user / assistant / tool
+	Content    string           `json:"content"`
+	ToolCalls  []types.ToolCall `gorm:"serializer:json" json:"tool_calls,omitempty"` // assistant 发起的工具调用
+	ToolCallID string           `gorm:"size:64" json:"tool_call_id,omitempty"`       // tool 消息对应的调用
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// ForgetMark 设备最近一次要求忘掉对话的时间，重新连接载入历史时不再取此前的消息
+type ForgetMark struct {
+	DeviceID  string    `gorm:"primaryKey;size:64" json:"device_id"`
+	ForgetAt  time.Time `json:"forget_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func init() {
+	database.RegisterModel(&Session{}, &Message{}, &ForgetMark{})
+}
+
+// StartSession 创建会话记录
+func StartSession(id, deviceID, clientIP string, startedAt time.Time) (*Session, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	session := &Session{ID: id, DeviceID: deviceID, ClientIP: clientIP, StartedAt: startedAt}
+	if err := db.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("保存会话记录失败: %v", err)
+	}
+	return session, nil
+}
+
+// EndSession 记录会话结束时间
+func EndSession(id string, endedAt time.Time) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	if err := db.Model(&Session{}).Where("id = ?", id).Update("ended_at", endedAt).Error; err != nil {
+		return fmt.Errorf("更新会话记录失败: %v", err)
+	}
+	return nil
+}
+
+// AddMessage 向 message.SessionID 对应的会话追加一条消息并累加消息数
+func AddMessage(message *Message) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Session{}).Where("id = ?", message.SessionID).
+			Update("message_count", gorm.Expr("message_count + 1")).Error
+	})
+	if err != nil {
+		return fmt.Errorf("保存会话消息失败: %v", err)
+	}
+	return nil
+}
+
+// RecentMessages 按时间顺序返回设备最近 turns 轮对话的消息，从第 turns 条最近的用户消息开始；
+// 不包括 excludeSession 会话，since 非零时只取此后的消息
+func RecentMessages(deviceID, excludeSession string, turns int, since time.Time) ([]Message, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	scope := func() *gorm.DB {
+		query := db.Model(&Message{}).
+			Joins("JOIN sessions ON sessions.id = messages.session_id").
+			Where("sessions.device_id = ? AND messages.session_id <> ?", deviceID, excludeSession)
+		if !since.IsZero() {
+			query = query.Where("messages.created_at >= ?", since)
+		}
+		return query
+	}
+
+	var starts []uint
+	err := scope().Where("messages.role = ?", "user").Order("messages.id desc").
+		Limit(turns).Pluck("messages.id", &starts).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询会话消息失败: %v", err)
+	}
+	if len(starts) == 0 {
+		return nil, nil
+	}
+	var messages []Message
+	err = scope().Where("messages.id >= ?", starts[len(starts)-1]).Order("messages.id asc").Find(&messages).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询会话消息失败: %v", err)
+	}
+	return messages, nil
+}
+
+// MarkForget 记录设备在 at 时刻要求忘掉之前的对话，重复调用时覆盖
+func MarkForget(deviceID string, at time.Time) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	if err := db.Save(&ForgetMark{DeviceID: deviceID, ForgetAt: at}).Error; err != nil {
+		return fmt.Errorf("保存遗忘时间失败: %v", err)
+	}
+	return nil
+}
+
+// ForgetTime 返回设备最近一次要求忘掉对话的时间，没有记录时为零值
+func ForgetTime(deviceID string) (time.Time, error) {
+	db := database.GetDB()
+	if db == nil {
+		return time.Time{}, fmt.Errorf("数据库未初始化")
+	}
+	var mark ForgetMark
+	if err := db.First(&mark, "device_id = ?", deviceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("查询遗忘时间失败: %v", err)
+	}
+	return mark.ForgetAt, nil
+}
+
+// UpdateMessage 修改消息内容，用于回复被打断后截断为已播出的部分
+func UpdateMessage(id uint, content string) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	if err := db.Model(&Message{}).Where("id = ?", id).Update("content", content).Error; err != nil {
+		return fmt.Errorf("更新会话消息失败: %v", err)
+	}
+	return nil
+}
+
+// GetSession 查询会话，不存在时返回 nil
+func GetSession(id string) (*Session, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var session Session
+	if err := db.First(&session, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询会话记录失败: %v", err)
+	}
+	return &session, nil
+}
+
+// ListSessions 按开始时间倒序分页列出设备的会话，返回本页记录与总数
+func ListSessions(deviceID string, page, pageSize int) ([]Session, int64, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, 0, fmt.Errorf("数据库未初始化")
+	}
+	query := db.Model(&Session{}).Where("device_id = ?", deviceID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询会话记录失败: %v", err)
+	}
+	var sessions []Session
+	err := query.Order("started_at desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&sessions).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询会话记录失败: %v", err)
+	}
+	return sessions, total, nil
+}
+
+// ListMessages 按时间顺序分页列出会话的消息，返回本页记录与总数
+func ListMessages(sessionID string, page, pageSize int) ([]Message, int64, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, 0, fmt.Errorf("数据库未初始化")
+	}
+	query := db.Model(&Message{}).Where("session_id = ?", sessionID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询会话消息失败: %v", err)
+	}
+	var messages []Message
+	err := query.Order("id asc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&messages).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询会话消息失败: %v", err)
+	}
+	return messages, total, nil
+}
+
+// ClearDevice 删除设备的全部会话与消息，返回删除的会话数与消息数
+func ClearDevice(deviceID string) (sessions, messages int64, err error) {
+	db := database.GetDB()
+	if db == nil {
+		return 0, 0, fmt.Errorf("数据库未初始化")
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		ids := tx.Model(&Session{}).Select("id").Where("device_id = ?", deviceID)
+		result := tx.Where("session_id IN (?)", ids).Delete(&Message{})
+		if result.Error != nil {
+			return result.Error
+		}
+		messages = result.RowsAffected
+		result = tx.Where("device_id = ?", deviceID).Delete(&Session{})
+		if result.Error != nil {
+			return result.Error
+		}
+		sessions = result.RowsAffected
+		return tx.Where("device_id = ?", deviceID).Delete(&ForgetMark{}).Error
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("清除会话记录失败: %v", err)
+	}
+	return sessions, messages, nil
+}
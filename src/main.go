@@ -10,35 +10,102 @@ import (
 	"syscall"
 	"time"
 
+	"xiaozhi-server-go/src/audit"
+	"xiaozhi-server-go/src/auth"
 	"xiaozhi-server-go/src/configs"
 	"xiaozhi-server-go/src/core"
 	"xiaozhi-server-go/src/core/utils"
+	"xiaozhi-server-go/src/database"
+	"xiaozhi-server-go/src/device"
+	"xiaozhi-server-go/src/dnd"
+	"xiaozhi-server-go/src/graceful"
+	"xiaozhi-server-go/src/history"
+	"xiaozhi-server-go/src/metrics"
+	"xiaozhi-server-go/src/middleware"
 	"xiaozhi-server-go/src/ota"
+	"xiaozhi-server-go/src/push"
+	"xiaozhi-server-go/src/systemd"
+	"xiaozhi-server-go/src/transcript"
+	"xiaozhi-server-go/src/voiceclone"
+	"xiaozhi-server-go/src/winsvc"
 
 	// 导入所有providers以确保init函数被调用
+	_ "xiaozhi-server-go/src/core/providers/asr/aliyun"
+	_ "xiaozhi-server-go/src/core/providers/asr/azure"
 	_ "xiaozhi-server-go/src/core/providers/asr/doubao"
+	_ "xiaozhi-server-go/src/core/providers/asr/funasr"
+	_ "xiaozhi-server-go/src/core/providers/asr/google"
+	_ "xiaozhi-server-go/src/core/providers/asr/gosherpa"
+	_ "xiaozhi-server-go/src/core/providers/asr/iflytek"
+	_ "xiaozhi-server-go/src/core/providers/asr/openai"
+	_ "xiaozhi-server-go/src/core/providers/asr/sherpaonnx"
+	_ "xiaozhi-server-go/src/core/providers/asr/tencent"
+	_ "xiaozhi-server-go/src/core/providers/asr/vosk"
+	_ "xiaozhi-server-go/src/core/providers/llm/anthropic"
+	_ "xiaozhi-server-go/src/core/providers/llm/coze"
+	_ "xiaozhi-server-go/src/core/providers/llm/dashscope"
+	_ "xiaozhi-server-go/src/core/providers/llm/dify"
+	_ "xiaozhi-server-go/src/core/providers/llm/gemini"
 	_ "xiaozhi-server-go/src/core/providers/llm/ollama"
 	_ "xiaozhi-server-go/src/core/providers/llm/openai"
+	_ "xiaozhi-server-go/src/core/providers/llm/zhipu"
+	_ "xiaozhi-server-go/src/core/providers/tts/azure"
+	_ "xiaozhi-server-go/src/core/providers/tts/cosyvoice"
 	_ "xiaozhi-server-go/src/core/providers/tts/doubao"
 	_ "xiaozhi-server-go/src/core/providers/tts/edge"
+	_ "xiaozhi-server-go/src/core/providers/tts/elevenlabs"
+	_ "xiaozhi-server-go/src/core/providers/tts/fishaudio"
+	_ "xiaozhi-server-go/src/core/providers/tts/localhttp"
+	_ "xiaozhi-server-go/src/core/providers/tts/piper"
 	_ "xiaozhi-server-go/src/core/providers/vlllm/ollama"
 	_ "xiaozhi-server-go/src/core/providers/vlllm/openai"
 
+	_ "time/tzdata" // 内置时区数据，没有系统时区库的环境也能按设备时区计算
+
 	"github.com/gin-gonic/gin"
 	"golang.org/x/sync/errgroup"
 )
 
+// 进程退出码，便于运维脚本判断失败原因
+const (
+	ExitOK           = 0 // 正常退出
+	ExitRuntimeError = 1 // 运行期间服务异常退出
+	ExitConfigError  = 2 // 配置文件加载或解析失败
+	ExitLoggerError  = 3 // 日志系统初始化失败
+	ExitWSError      = 4 // WebSocket 服务（含资源池）启动失败
+	ExitHTTPError    = 5 // HTTP 服务启动失败（如端口被占用）
+	ExitDBError      = 6 // 数据库初始化失败
+)
+
+// startupError 携带退出码的启动错误
+type startupError struct {
+	code int
+	err  error
+}
+
+func (e *startupError) Error() string {
+	return e.err.Error()
+}
+
+// exitCodeOf 从错误中取出退出码，未携带时使用默认值
+func exitCodeOf(err error, defaultCode int) int {
+	if se, ok := err.(*startupError); ok {
+		return se.code
+	}
+	return defaultCode
+}
+
 func LoadConfigAndLogger() (*configs.Config, *utils.Logger, error) {
 	// 加载配置,默认使用.config.yaml
 	config, configPath, err := configs.LoadConfig()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, &startupError{code: ExitConfigError, err: fmt.Errorf("加载配置文件 %s 失败: %v", configPath, err)}
 	}
 
 	// 初始化日志系统
 	logger, err := utils.NewLogger(config)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, &startupError{code: ExitLoggerError, err: fmt.Errorf("初始化日志系统失败: %v", err)}
 	}
 	logger.Info(fmt.Sprintf("日志系统初始化成功, 配置文件路径: %s", configPath))
 
@@ -52,9 +119,16 @@ func StartWSServer(config *configs.Config, logger *utils.Logger, g *errgroup.Gro
 		return nil, err
 	}
 
+	// 在启动阶段同步监听，端口被占用时直接返回错误，避免在监听成功前通知就绪
+	// 平滑重启时会直接复用父进程传下来的监听fd
+	listener, err := graceful.Listen("websocket", wsServer.Addr())
+	if err != nil {
+		return nil, fmt.Errorf("监听地址 %s 失败: %v", wsServer.Addr(), err)
+	}
+
 	// 启动 WebSocket 服务
 	g.Go(func() error {
-		if err := wsServer.Start(context.Background()); err != nil {
+		if err := wsServer.Start(context.Background(), listener); err != nil {
 			logger.Error("WebSocket 服务运行失败", err)
 			return err
 		}
@@ -65,34 +139,107 @@ func StartWSServer(config *configs.Config, logger *utils.Logger, g *errgroup.Gro
 	return wsServer, nil
 }
 
-func StartHttpServer(config *configs.Config, logger *utils.Logger, g *errgroup.Group) (*http.Server, error) {
+func StartHttpServer(config *configs.Config, wsServer *core.WebSocketServer, logger *utils.Logger, g *errgroup.Group) (*http.Server, error) {
 	// 初始化Gin引擎
 	if config.Log.LogLevel == "debug" {
 		gin.SetMode(gin.DebugMode)
 	} else {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	router := gin.Default()
-	router.SetTrustedProxies([]string{"0.0.0.0"})
+	// 不使用 gin.Default 自带的日志，访问日志统一写入 Logger
+	router := gin.New()
+	router.Use(gin.Recovery(), middleware.AccessLog(config.Web.AccessLog, logger))
+	// 可信代理与 WebSocket 服务共用同一份配置，c.ClientIP() 即为真实客户端IP
+	if err := router.SetTrustedProxies(config.Server.TrustedProxies); err != nil {
+		logger.Error("可信代理配置无效", err)
+		return nil, err
+	}
+	if len(config.Server.RealIPHeaders) > 0 {
+		router.RemoteIPHeaders = config.Server.RealIPHeaders
+	}
+	// 全局跨域处理，需在注册路由前挂载
+	cors, err := middleware.CORS(config.Web.CORS)
+	if err != nil {
+		logger.Error("跨域配置无效", err)
+		return nil, err
+	}
+	router.Use(cors)
+
+	// API路由全部挂载到/api前缀下，统一经过鉴权中间件（按路由前缀决定鉴权模式）
+	authenticator, err := auth.NewAuthenticator(config, logger)
+	if err != nil {
+		logger.Error("鉴权配置无效", err)
+		return nil, err
+	}
+	apiGroup := router.Group("/api", authenticator.Middleware())
+	if err := auth.NewService(authenticator).Start(context.Background(), router, apiGroup); err != nil {
+		logger.Error("鉴权服务启动失败", err)
+		return nil, err
+	}
 
-	// API路由全部挂载到/api前缀下
-	apiGroup := router.Group("/api")
-	otaService := ota.NewDefaultOTAService(config.Web.Websocket)
+	otaService := ota.NewDefaultOTAService(config.Web.Websocket, config.Web.OTA, logger)
 	if err := otaService.Start(context.Background(), router, apiGroup); err != nil {
 		logger.Error("OTA 服务启动失败", err)
 		return nil, err
 	}
 
+	if err := voiceclone.NewService(config, logger).Start(context.Background(), router, apiGroup); err != nil {
+		logger.Error("声音克隆服务启动失败", err)
+		return nil, err
+	}
+
+	if err := dnd.NewService(logger).Start(context.Background(), router, apiGroup); err != nil {
+		logger.Error("免打扰服务启动失败", err)
+		return nil, err
+	}
+
+	if err := transcript.NewService(logger).Start(context.Background(), router, apiGroup); err != nil {
+		logger.Error("会议转写服务启动失败", err)
+		return nil, err
+	}
+
+	if err := history.NewService(logger).Start(context.Background(), router, apiGroup); err != nil {
+		logger.Error("历史会话服务启动失败", err)
+		return nil, err
+	}
+
+	if err := device.NewService(config, wsServer, logger).Start(context.Background(), router, apiGroup); err != nil {
+		logger.Error("设备管理服务启动失败", err)
+		return nil, err
+	}
+
+	if err := push.NewService(wsServer, logger).Start(context.Background(), router, apiGroup); err != nil {
+		logger.Error("推送服务启动失败", err)
+		return nil, err
+	}
+
+	if err := audit.NewService().Start(context.Background(), router, apiGroup); err != nil {
+		logger.Error("审计日志服务启动失败", err)
+		return nil, err
+	}
+
+	if err := metrics.NewService(config.Web.Metrics, wsServer).Start(context.Background(), router, apiGroup); err != nil {
+		logger.Error("监控指标服务启动失败", err)
+		return nil, err
+	}
+
 	// HTTP Server（支持优雅关机）
 	httpServer := &http.Server{
 		Addr:    ":" + strconv.Itoa(config.Web.Port),
 		Handler: router,
 	}
 
+	// 平滑重启时复用父进程的监听fd
+	listener, err := graceful.Listen("http", httpServer.Addr)
+	if err != nil {
+		logger.Error("HTTP 服务监听失败", err)
+		return nil, err
+	}
+
 	g.Go(func() error {
 		logger.Info(fmt.Sprintf("Gin 服务已启动，访问地址: http://0.0.0.0:%d", config.Web.Port))
-		// ListenAndServe 返回 ErrServerClosed 时表示正常关闭
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		// Serve 返回 ErrServerClosed 时表示正常关闭
+		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 			logger.Error("HTTP 服务启动失败", err)
 			return err
 		}
@@ -102,17 +249,47 @@ func StartHttpServer(config *configs.Config, logger *utils.Logger, g *errgroup.G
 	return httpServer, nil
 }
 
+// getDrainTimeout 平滑重启时老进程等待会话结束的上限
+func getDrainTimeout(config *configs.Config) time.Duration {
+	if t, err := time.ParseDuration(config.Server.GracefulRestart.DrainTimeout); err == nil && t > 0 {
+		return t
+	}
+	return 5 * time.Minute
+}
+
 // 优雅关机处理
-func ShutdownServer(httpServer *http.Server, wsServer *core.WebSocketServer, ctx context.Context, logger *utils.Logger, g *errgroup.Group) {
+func ShutdownServer(config *configs.Config, httpServer *http.Server, wsServer *core.WebSocketServer, ctx context.Context, logger *utils.Logger, g *errgroup.Group) error {
 	// 监听系统信号
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	if config.Server.GracefulRestart.Enabled {
+		signal.Notify(sigChan, graceful.RestartSignals()...)
+	}
 
-	select {
-	case sig := <-sigChan:
-		logger.Info("接收到系统信号，准备关闭服务", sig)
-	case <-ctx.Done():
-		logger.Info("服务上下文已取消，准备关闭服务")
+	restarting := false
+	for {
+		select {
+		case sig := <-sigChan:
+			if graceful.IsRestartSignal(sig) {
+				// 先拉起新进程，成功后老进程才进入排空流程
+				pid, err := graceful.Restart()
+				if err != nil {
+					logger.Error("平滑重启失败，继续使用当前进程提供服务", err)
+					continue
+				}
+				logger.Info(fmt.Sprintf("新进程已启动(pid=%d)，当前进程停止接收新连接", pid))
+				systemd.Notify(systemd.StateReloading)
+				restarting = true
+			} else {
+				logger.Info("接收到系统信号，准备关闭服务", sig)
+			}
+		case <-ctx.Done():
+			logger.Info("服务上下文已取消，准备关闭服务")
+		}
+		break
+	}
+	if !restarting {
+		systemd.Notify(systemd.StateStopping)
 	}
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -125,9 +302,15 @@ func ShutdownServer(httpServer *http.Server, wsServer *core.WebSocketServer, ctx
 		logger.Info("HTTP 服务已优雅关闭")
 	}
 
-	// 再关闭 WebSocket 服务
-	if err := wsServer.Stop(); err != nil {
-		logger.Error("WebSocket 服务关闭失败", err)
+	// 再关闭 WebSocket 服务；平滑重启时等待现有会话自然结束
+	var wsErr error
+	if restarting {
+		wsErr = wsServer.Drain(getDrainTimeout(config))
+	} else {
+		wsErr = wsServer.Stop()
+	}
+	if wsErr != nil {
+		logger.Error("WebSocket 服务关闭失败", wsErr)
 	} else {
 		logger.Info("WebSocket 服务已关闭")
 	}
@@ -135,38 +318,143 @@ func ShutdownServer(httpServer *http.Server, wsServer *core.WebSocketServer, ctx
 	// 等待 errgroup 中其他 goroutine 退出
 	if err := g.Wait(); err != nil {
 		logger.Error("服务退出时出现错误", err)
-		// logger.Close()
-		os.Exit(1)
+		return err
 	}
+	return nil
 }
 
-func main() {
+// notifyReady 服务启动完成后通知 systemd，并按需启动看门狗心跳
+func notifyReady(ctx context.Context, wsServer *core.WebSocketServer, logger *utils.Logger) {
+	var err error
+	if graceful.IsInherited() {
+		// 平滑重启拉起的新进程需要告知 systemd 主进程已变更
+		_, err = systemd.NotifyMainPID(os.Getpid())
+	} else {
+		_, err = systemd.Notify(systemd.StateReady)
+	}
+	if err != nil {
+		logger.Warn(fmt.Sprintf("通知 systemd 失败: %v", err))
+	}
+
+	// 心跳前探测 WebSocket 服务仍在运行、资源池可响应，停止运行或卡死时停止心跳由 systemd 拉起
+	started := systemd.StartWatchdog(ctx, wsServer.CheckAlive, func(err error) {
+		logger.Warn(fmt.Sprintf("看门狗存活探测失败，跳过本次心跳: %v", err))
+	})
+	if started {
+		logger.Info(fmt.Sprintf("systemd 看门狗已启用，超时时间 %v", systemd.WatchdogInterval()))
+	}
+}
+
+// run 启动全部服务并阻塞到退出，stop 关闭时触发优雅关机（Windows 服务停止）
+func run(stop <-chan struct{}) int {
 	// 加载配置和初始化日志系统
 	config, logger, err := LoadConfigAndLogger()
 	if err != nil {
 		fmt.Println("加载配置或初始化日志系统失败:", err)
-		os.Exit(1)
+		return exitCodeOf(err, ExitConfigError)
+	}
+
+	// 初始化数据库
+	if _, err := database.Init(config); err != nil {
+		logger.Error("初始化数据库失败:", err)
+		return ExitDBError
+	}
+	defer database.Close()
+
+	baseCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if stop != nil {
+		go func() {
+			select {
+			case <-stop:
+				logger.Info("收到服务停止请求")
+				cancel()
+			case <-baseCtx.Done():
+			}
+		}()
 	}
 
 	// 用 errgroup 管理两个服务
-	g, ctx := errgroup.WithContext(context.Background())
+	g, ctx := errgroup.WithContext(baseCtx)
 
 	// 启动 WebSocket 服务
 	wsServer, err := StartWSServer(config, logger, g)
 	if err != nil {
 		logger.Error("启动 WebSocket 服务失败:", err)
-		os.Exit(1)
+		return ExitWSError
 	}
 
 	// 启动 Http 服务
-	httpServer, err := StartHttpServer(config, logger, g)
+	httpServer, err := StartHttpServer(config, wsServer, logger, g)
 	if err != nil {
 		logger.Error("启动 Http 服务失败:", err)
-		os.Exit(1)
+		return ExitHTTPError
 	}
 
+	// 通知 systemd 服务已就绪
+	notifyReady(ctx, wsServer, logger)
+
 	// 启动优雅关机处理
-	ShutdownServer(httpServer, wsServer, ctx, logger, g)
+	if err := ShutdownServer(config, httpServer, wsServer, ctx, logger, g); err != nil {
+		return ExitRuntimeError
+	}
 
 	logger.Info("服务已成功关闭，程序退出")
+	return ExitOK
+}
+
+// handleServiceCommand 处理 Windows 服务管理子命令，返回是否已处理
+func handleServiceCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	var err error
+	switch args[0] {
+	case "install":
+		if err = winsvc.Install(); err == nil {
+			fmt.Printf("服务 %s 安装成功，可执行 %s start 启动\n", winsvc.ServiceName, os.Args[0])
+		}
+	case "uninstall":
+		if err = winsvc.Uninstall(); err == nil {
+			fmt.Printf("服务 %s 已卸载\n", winsvc.ServiceName)
+		}
+	case "start":
+		if err = winsvc.Start(); err == nil {
+			fmt.Printf("服务 %s 已启动\n", winsvc.ServiceName)
+		}
+	case "stop":
+		if err = winsvc.Stop(); err == nil {
+			fmt.Printf("服务 %s 已停止\n", winsvc.ServiceName)
+		}
+	default:
+		return false
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(ExitRuntimeError)
+	}
+	return true
+}
+
+func main() {
+	// install/uninstall/start/stop 子命令用于管理 Windows 服务
+	if handleServiceCommand(os.Args[1:]) {
+		return
+	}
+
+	// 由服务控制管理器启动时，配置与日志目录均相对于服务所在目录
+	if winsvc.IsWindowsService() {
+		if err := winsvc.ChdirToExecutable(); err != nil {
+			os.Exit(ExitConfigError)
+		}
+		code, err := winsvc.Run(run)
+		if err != nil {
+			os.Exit(ExitRuntimeError)
+		}
+		os.Exit(code)
+	}
+
+	os.Exit(run(nil))
 }
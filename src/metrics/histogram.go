@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultLatencyBuckets 语音链路各环节耗时的默认分桶上界（秒）
+var DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10}
+
+// 语音链路耗时分布，标签值使用 provider 的 type
+var (
+	ASRLatency           = newLatencyHistogram("xiaozhi_asr_latency_seconds", "停止拾音到最终识别结果的耗时", "asr")
+	LLMFirstTokenLatency = newLatencyHistogram("xiaozhi_llm_first_token_seconds", "发起 LLM 请求到首个输出的耗时", "llm")
+	TTSSynthesisLatency  = newLatencyHistogram("xiaozhi_tts_synthesis_seconds", "单句 TTS 合成耗时", "tts")
+)
+
+// newLatencyHistogram 创建并注册按 DefaultLatencyBuckets 分桶的耗时直方图
+func newLatencyHistogram(name, help string, labelNames ...string) *prometheus.HistogramVec {
+	return promauto.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: DefaultLatencyBuckets}, labelNames)
+}
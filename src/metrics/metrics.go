@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 连接与轮次级监控指标，标签值使用 provider 的 type（如 doubao、openai）
+var (
+	ActiveConnections = promauto.NewGauge(prometheus.GaugeOpts{Name: "xiaozhi_active_connections", Help: "当前活跃的 WebSocket 连接数"})
+	DialogueRounds    = promauto.NewCounterVec(prometheus.CounterOpts{Name: "xiaozhi_dialogue_rounds_total", Help: "累计对话轮次"}, []string{"llm"})
+	BargeIns          = promauto.NewCounterVec(prometheus.CounterOpts{Name: "xiaozhi_barge_in_total", Help: "用户打断服务端播报的次数"}, []string{"mode"})
+	ASRResults        = promauto.NewCounterVec(prometheus.CounterOpts{Name: "xiaozhi_asr_results_total", Help: "ASR 最终识别结果数，empty 为 true 表示空结果"}, []string{"asr", "empty"})
+	TTSFailures       = promauto.NewCounterVec(prometheus.CounterOpts{Name: "xiaozhi_tts_failures_total", Help: "TTS 合成失败次数"}, []string{"tts"})
+	AudioFramesSent   = promauto.NewCounterVec(prometheus.CounterOpts{Name: "xiaozhi_audio_frames_sent_total", Help: "下发给客户端的音频帧数"}, []string{"format"})
+	PoolResources     = NewGaugeFunc("xiaozhi_pool_resources", "资源池中的实例数，state 为 available 表示空闲、total 表示总数", "pool", "state")
+)
+
+// Sample 采集时计算的一个指标值，Labels 与创建时的标签名一一对应
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// GaugeFunc 在每次采集时调用回调计算取值的一组仪表，用于资源池等由其他模块持有、标签值在运行中才确定的状态；
+// client_golang 自带的 GaugeFunc 不支持标签，这里实现 prometheus.Collector
+type GaugeFunc struct {
+	desc *prometheus.Desc
+
+	mu      sync.Mutex
+	collect func() []Sample
+}
+
+// NewGaugeFunc 创建并注册采集时计算的仪表，需通过 Set 设置回调
+func NewGaugeFunc(name, help string, labelNames ...string) *GaugeFunc {
+	g := &GaugeFunc{desc: prometheus.NewDesc(name, help, labelNames, nil)}
+	prometheus.MustRegister(g)
+	return g
+}
+
+// Set 设置采集回调，重复设置时后者覆盖前者
+func (g *GaugeFunc) Set(collect func() []Sample) {
+	g.mu.Lock()
+	g.collect = collect
+	g.mu.Unlock()
+}
+
+// Describe 实现 prometheus.Collector
+func (g *GaugeFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+// Collect 实现 prometheus.Collector，未设置回调时不输出样本
+func (g *GaugeFunc) Collect(ch chan<- prometheus.Metric) {
+	g.mu.Lock()
+	collect := g.collect
+	g.mu.Unlock()
+	if collect == nil {
+		return
+	}
+	for _, s := range collect() {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, s.Value, s.Labels...)
+	}
+}
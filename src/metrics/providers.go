@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 提供者耗时统计的类别
+const (
+	StageASR           = "asr"             // 停止拾音到最终识别结果
+	StageLLMFirstToken = "llm_first_token" // 发起请求到首个输出
+	StageTTS           = "tts"             // 单句合成
+)
+
+// ProviderCalls 提供者调用次数，耗时分布见 stageHistograms，近期分位数通过 stats 接口查看
+var ProviderCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "xiaozhi_provider_calls_total",
+	Help: "提供者调用次数，success 为 false 表示调用失败",
+}, []string{"stage", "provider", "success"})
+
+// ProviderStat 单个提供者在某一环节的耗时统计
+type ProviderStat struct {
+	Stage       string  `json:"stage"`
+	Provider    string  `json:"provider"`
+	Calls       int64   `json:"calls"`
+	Failures    int64   `json:"failures"`
+	SuccessRate float64 `json:"success_rate"` // 0~1
+	AvgMs       float64 `json:"avg_ms"`
+	P95Ms       float64 `json:"p95_ms"` // 最近 latencyWindow 次调用的 P95 耗时
+	MaxMs       float64 `json:"max_ms"`
+}
+
+// stageHistograms 各环节对应的耗时直方图，只记录成功的调用
+var stageHistograms = map[string]*prometheus.HistogramVec{
+	StageASR:           ASRLatency,
+	StageLLMFirstToken: LLMFirstTokenLatency,
+	StageTTS:           TTSSynthesisLatency,
+}
+
+type providerKey struct {
+	stage    string
+	provider string
+}
+
+var (
+	providerMu      sync.Mutex
+	providerRecords = make(map[providerKey]*latencyRecord)
+)
+
+// ObserveProvider 记录一次提供者调用的耗时与结果，provider 为配置中的 type
+func ObserveProvider(stage, provider string, elapsed time.Duration, err error) {
+	success := "true"
+	if err != nil {
+		success = "false"
+	}
+	ProviderCalls.WithLabelValues(stage, provider, success).Inc()
+	if err == nil {
+		if h := stageHistograms[stage]; h != nil {
+			h.WithLabelValues(provider).Observe(elapsed.Seconds())
+		}
+	}
+
+	key := providerKey{stage: stage, provider: provider}
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	r, ok := providerRecords[key]
+	if !ok {
+		r = newLatencyRecord()
+		providerRecords[key] = r
+	}
+	r.observe(elapsed, err != nil)
+}
+
+// ProviderStats 返回全部提供者的耗时统计，按环节与提供者排序
+func ProviderStats() []ProviderStat {
+	providerMu.Lock()
+	stats := make([]ProviderStat, 0, len(providerRecords))
+	for key, r := range providerRecords {
+		stats = append(stats, ProviderStat{
+			Stage:       key.stage,
+			Provider:    key.provider,
+			Calls:       r.calls,
+			Failures:    r.failures,
+			SuccessRate: float64(r.calls-r.failures) / float64(r.calls),
+			AvgMs:       r.totalMs / float64(r.calls),
+			P95Ms:       percentile(r.latencies, 0.95),
+			MaxMs:       r.maxMs,
+		})
+	}
+	providerMu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Stage != stats[j].Stage {
+			return stats[i].Stage < stats[j].Stage
+		}
+		return stats[i].Provider < stats[j].Provider
+	})
+	return stats
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Runtime 运行时状态的来源，由 WebSocket 服务实现
+type Runtime interface {
+	GetPoolStats() map[string]map[string]int
+	GetActiveConnectionsCount() int
+	GetProviderHealth() []ProviderHealth
+}
+
+// ProviderHealth 启动时连通性检查的结果
+type ProviderHealth struct {
+	Module     string                 `json:"module"` // ASR/LLM/TTS/VLLLM
+	Success    bool                   `json:"success"`
+	Optional   bool                   `json:"optional,omitempty"` // 可选模块检查失败不影响整体状态
+	Error      string                 `json:"error,omitempty"`
+	Mode       string                 `json:"mode"` // basic 或 functional
+	DurationMs int64                  `json:"duration_ms"`
+	CheckedAt  time.Time              `json:"checked_at"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// Service 监控指标接口
+type Service struct {
+	config  configs.MetricsConfig
+	runtime Runtime
+}
+
+// NewService 创建监控指标服务，runtime 为 nil 时不注册健康检查与运行统计接口
+func NewService(config configs.MetricsConfig, runtime Runtime) *Service {
+	return &Service{config: config, runtime: runtime}
+}
+
+// Start 注册指标抓取路由，挂在根路由下，不经过 /api 鉴权；执行统计、健康检查与运行统计接口挂在 /api 下
+func (s *Service) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	apiGroup.GET("/stats/tools", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": ToolStats()})
+	})
+	if s.runtime != nil {
+		apiGroup.GET("/health", s.handleHealth)
+		apiGroup.GET("/metrics", s.handleMetrics)
+	}
+
+	if !s.config.Enabled {
+		return nil
+	}
+	path := s.config.Path
+	if path == "" {
+		path = "/metrics"
+	}
+	engine.GET(path, gin.WrapH(promhttp.Handler()))
+	return nil
+}
+
+// handleHealth 返回资源池、连接数与连通性检查结果，非可选模块检查失败时返回 503 便于负载均衡摘除
+func (s *Service) handleHealth(c *gin.Context) {
+	health := s.runtime.GetProviderHealth()
+	status := "ok"
+	for _, h := range health {
+		if !h.Success && !h.Optional {
+			status = "degraded"
+			break
+		}
+	}
+	code := http.StatusOK
+	if status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{"success": status == "ok", "data": gin.H{
+		"status":             status,
+		"active_connections": s.runtime.GetActiveConnectionsCount(),
+		"pools":              s.runtime.GetPoolStats(),
+		"providers":          health,
+	}})
+}
+
+// handleMetrics 返回运行统计：资源池、连接数与各提供者的调用耗时
+func (s *Service) handleMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"active_connections": s.runtime.GetActiveConnectionsCount(),
+		"pools":              s.runtime.GetPoolStats(),
+		"providers":          ProviderStats(),
+		"tools":              ToolStats(),
+	}})
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// latencyWindow 每个工具或提供者保留最近多少次调用的耗时用于计算分位数
+const latencyWindow = 512
+
+// ToolCalls 工具调用次数，Prometheus 侧只暴露计数，耗时分位数通过 stats 接口查看
+var ToolCalls = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "xiaozhi_tool_calls_total",
+	Help: "工具调用次数，success 为 false 表示调用失败",
+}, []string{"tool", "success"})
+
+// ToolStat 单个工具的执行统计
+type ToolStat struct {
+	Tool        string  `json:"tool"`
+	Calls       int64   `json:"calls"`
+	Failures    int64   `json:"failures"`
+	SuccessRate float64 `json:"success_rate"` // 0~1
+	AvgMs       float64 `json:"avg_ms"`
+	P95Ms       float64 `json:"p95_ms"` // 最近 latencyWindow 次调用的 P95 耗时
+	MaxMs       float64 `json:"max_ms"`
+}
+
+// latencyRecord 调用次数、失败次数与最近若干次耗时
+type latencyRecord struct {
+	calls     int64
+	failures  int64
+	totalMs   float64
+	maxMs     float64
+	latencies []float64 // 环形缓冲
+	next      int
+}
+
+func newLatencyRecord() *latencyRecord {
+	return &latencyRecord{latencies: make([]float64, 0, latencyWindow)}
+}
+
+// observe 记录一次调用，调用方需持有锁
+func (r *latencyRecord) observe(elapsed time.Duration, failed bool) {
+	ms := float64(elapsed.Microseconds()) / 1000
+	r.calls++
+	if failed {
+		r.failures++
+	}
+	r.totalMs += ms
+	if ms > r.maxMs {
+		r.maxMs = ms
+	}
+	if len(r.latencies) < latencyWindow {
+		r.latencies = append(r.latencies, ms)
+	} else {
+		r.latencies[r.next] = ms
+		r.next = (r.next + 1) % latencyWindow
+	}
+}
+
+var (
+	toolMu      sync.Mutex
+	toolRecords = make(map[string]*latencyRecord)
+)
+
+// ObserveTool 记录一次工具调用的耗时与结果
+func ObserveTool(tool string, elapsed time.Duration, err error) {
+	success := "true"
+	if err != nil {
+		success = "false"
+	}
+	ToolCalls.WithLabelValues(tool, success).Inc()
+
+	toolMu.Lock()
+	defer toolMu.Unlock()
+	r, ok := toolRecords[tool]
+	if !ok {
+		r = newLatencyRecord()
+		toolRecords[tool] = r
+	}
+	r.observe(elapsed, err != nil)
+}
+
+// ToolStats 返回全部工具的执行统计，按 P95 耗时从高到低排列
+func ToolStats() []ToolStat {
+	toolMu.Lock()
+	stats := make([]ToolStat, 0, len(toolRecords))
+	for tool, r := range toolRecords {
+		stats = append(stats, ToolStat{
+			Tool:        tool,
+			Calls:       r.calls,
+			Failures:    r.failures,
+			SuccessRate: float64(r.calls-r.failures) / float64(r.calls),
+			AvgMs:       r.totalMs / float64(r.calls),
+			P95Ms:       percentile(r.latencies, 0.95),
+			MaxMs:       r.maxMs,
+		})
+	}
+	toolMu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].P95Ms != stats[j].P95Ms {
+			return stats[i].P95Ms > stats[j].P95Ms
+		}
+		return stats[i].Tool < stats[j].Tool
+	})
+	return stats
+}
+
+// percentile 按最近邻取分位数，不修改入参
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	idx := int(float64(len(sorted))*p+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
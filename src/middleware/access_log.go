@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLog 结构化访问日志，写入统一的 Logger
+// 错误响应（>=400）与慢请求总是记录，其余请求按采样率记录
+func AccessLog(cfg configs.AccessLogConfig, logger *utils.Logger) gin.HandlerFunc {
+	if !cfg.Enabled {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	sampleRate := cfg.SampleRate
+	if sampleRate <= 0 || sampleRate > 1 {
+		sampleRate = 1
+	}
+	slowThreshold := time.Second
+	if d, err := time.ParseDuration(cfg.SlowThreshold); err == nil && d > 0 {
+		slowThreshold = d
+	}
+	skip := make(map[string]bool, len(cfg.SkipPaths))
+	for _, p := range cfg.SkipPaths {
+		skip[p] = true
+	}
+	tagged := logger.WithTag("HTTP")
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+
+		if skip[path] {
+			return
+		}
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		slow := latency >= slowThreshold
+		if status < 400 && !slow && sampleRate < 1 && rand.Float64() >= sampleRate {
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = path
+		}
+		fields := map[string]interface{}{
+			"method":     c.Request.Method,
+			"path":       path,
+			"route":      route,
+			"query":      c.Request.URL.RawQuery,
+			"status":     status,
+			"latency_ms": latency.Milliseconds(),
+			"client_ip":  c.ClientIP(),
+			"size":       c.Writer.Size(),
+			"user_agent": c.Request.UserAgent(),
+			"slow":       slow,
+		}
+		if len(c.Errors) > 0 {
+			fields["errors"] = c.Errors.String()
+		}
+
+		msg := fmt.Sprintf("%s %s %d %v %s", c.Request.Method, path, status, latency, c.ClientIP())
+		switch {
+		case status >= 500:
+			tagged.Error(msg, fields)
+		case slow:
+			tagged.Warn("[慢请求] "+msg, fields)
+		case status >= 400:
+			tagged.Warn(msg, fields)
+		default:
+			tagged.Info(msg, fields)
+		}
+	}
+}
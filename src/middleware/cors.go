@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/configs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 未配置时使用的默认值，与原 OTA 接口手写的 header 保持一致
+var (
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	defaultCORSHeaders = []string{"client-id", "content-type", "device-id", "authorization", "x-api-key"}
+)
+
+// CORS 根据全局配置处理跨域请求，预检请求直接返回 204
+// 允许全部 origin 的同时允许携带凭证会让任意网站以用户身份调用接口，此时返回错误
+func CORS(cfg configs.CORSConfig) (gin.HandlerFunc, error) {
+	if !cfg.Enabled {
+		return func(c *gin.Context) {
+			c.Next()
+		}, nil
+	}
+
+	allowAll := false
+	allowed := make(map[string]bool, len(cfg.AllowOrigins))
+	for _, origin := range cfg.AllowOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[strings.TrimRight(origin, "/")] = true
+	}
+	if allowAll && cfg.AllowCredentials {
+		return nil, errors.New("allow_origins 包含 * 时不能开启 allow_credentials，请改为填写具体的 origin")
+	}
+
+	methods := cfg.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	headers := cfg.AllowHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(headers, ", ")
+	exposeHeaders := strings.Join(cfg.ExposeHeaders, ", ")
+
+	maxAge := ""
+	if d, err := time.ParseDuration(cfg.MaxAge); err == nil && d > 0 {
+		maxAge = strconv.Itoa(int(d.Seconds()))
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			// 非浏览器跨域请求（设备、curl）不处理
+			c.Next()
+			return
+		}
+
+		if !allowAll && !allowed[strings.TrimRight(origin, "/")] {
+			if c.Request.Method == http.MethodOptions {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		// 允许全部时返回 *，否则回显请求的 origin
+		if allowAll {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Vary", "Origin")
+		}
+		if cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if exposeHeaders != "" {
+			c.Header("Access-Control-Expose-Headers", exposeHeaders)
+		}
+
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Header("Access-Control-Allow-Methods", allowMethods)
+			c.Header("Access-Control-Allow-Headers", allowHeaders)
+			if maxAge != "" {
+				c.Header("Access-Control-Max-Age", maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}, nil
+}
@@ -0,0 +1,106 @@
+package ota
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+const maxThrottleChunk = 32 << 10 // 限速时单次写入的最大字节数
+
+// parseByteSize 解析 512KB、10MB 形式的字节数，支持 B/KB/MB/GB（按 1024 换算），为空时返回 0
+func parseByteSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+	units := []struct {
+		suffix string
+		scale  int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}}
+	scale := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			scale = unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("无效的字节数: %s", value)
+	}
+	return int64(n * float64(scale)), nil
+}
+
+// newDownloadLimiter 按每秒字节数创建限速器，突发量不超过单次写入的字节数
+func newDownloadLimiter(bytesPerSecond int64) *rate.Limiter {
+	burst := int64(maxThrottleChunk)
+	if bytesPerSecond < burst {
+		burst = bytesPerSecond
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(burst))
+}
+
+// throttledWriter 按限速器分块写出响应体，客户端断开时停止等待
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > w.limiter.Burst() {
+			n = w.limiter.Burst()
+		}
+		if err := w.limiter.WaitN(w.ctx, n); err != nil {
+			return written, err
+		}
+		m, err := w.ResponseWriter.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// serveFirmware 下发固件文件，支持 Range 断点续传；有上传记录时以 sha256 作为 ETag，
+// 设备续传时携带 If-Range 可确保前后两段来自同一个固件
+func (s *DefaultOTAService) serveFirmware(c *gin.Context, path string, record *Firmware) {
+	file, err := os.Open(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "open firmware failed"})
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "open firmware failed"})
+		return
+	}
+
+	name := filepath.Base(path)
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Disposition", "attachment; filename="+name)
+	if record != nil {
+		c.Header("ETag", `"`+record.SHA256+`"`)
+	}
+
+	var w http.ResponseWriter = c.Writer
+	if s.limiter != nil {
+		w = &throttledWriter{ResponseWriter: c.Writer, ctx: c.Request.Context(), limiter: s.limiter}
+	}
+	http.ServeContent(w, c.Request, name, info.ModTime(), file)
+}
@@ -0,0 +1,213 @@
+package ota
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"xiaozhi-server-go/src/database"
+
+	"gorm.io/gorm"
+)
+
+// otaDir 固件存放目录，文件名为 <版本>.bin
+const otaDir = "ota_bin"
+
+// Firmware 通过接口上传的固件元数据，下发前按 SHA256 校验文件
+type Firmware struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Version   string    `gorm:"size:32;uniqueIndex" json:"version"`
+	Size      int64     `json:"size"`
+	SHA256    string    `gorm:"size:64" json:"sha256"`     // 十六进制
+	Signature string    `gorm:"size:128" json:"signature"` // 对 SHA256 摘要的 ed25519 签名（base64），未配置签名密钥时为空
+	Note      string    `gorm:"size:255" json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 固件表名
+func (Firmware) TableName() string {
+	return "ota_firmwares"
+}
+
+func init() {
+	database.RegisterModel(&Firmware{})
+}
+
+// firmwarePath 固件文件路径
+func firmwarePath(version string) string {
+	return filepath.Join(otaDir, version+".bin")
+}
+
+// validVersion 版本号会作为文件名，不能包含路径
+func validVersion(version string) bool {
+	return version != "" && version != "." && version != ".." && filepath.Base(version) == version
+}
+
+// ListFirmwares 按版本从新到旧列出已上传的固件
+func ListFirmwares() ([]Firmware, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var firmwares []Firmware
+	if err := db.Find(&firmwares).Error; err != nil {
+		return nil, fmt.Errorf("查询固件失败: %v", err)
+	}
+	sort.Slice(firmwares, func(i, j int) bool {
+		return compareVersion(firmwares[j].Version, firmwares[i].Version) < 0
+	})
+	return firmwares, nil
+}
+
+// GetFirmware 查询固件元数据，没有记录时返回 nil
+func GetFirmware(version string) (*Firmware, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var record Firmware
+	if err := db.Where("version = ?", version).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询固件失败: %v", err)
+	}
+	return &record, nil
+}
+
+// SaveFirmware 保存固件元数据，同版本已存在时覆盖
+func SaveFirmware(firmware *Firmware) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	existing, err := GetFirmware(firmware.Version)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		firmware.ID = existing.ID
+		firmware.CreatedAt = existing.CreatedAt
+	}
+	if err := db.Save(firmware).Error; err != nil {
+		return fmt.Errorf("保存固件失败: %v", err)
+	}
+	return nil
+}
+
+// DeleteFirmware 删除固件元数据与文件
+func DeleteFirmware(version string) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	result := db.Where("version = ?", version).Delete(&Firmware{})
+	if result.Error != nil {
+		return fmt.Errorf("删除固件失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("固件 %s 不存在", version)
+	}
+	if err := os.Remove(firmwarePath(version)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除固件文件失败: %v", err)
+	}
+	return nil
+}
+
+// parseSigningKey 解析 base64 编码的 ed25519 私钥，支持 32 字节种子或 64 字节私钥，为空时返回 nil
+func parseSigningKey(key string) (ed25519.PrivateKey, error) {
+	if key == "" {
+		return nil, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("固件签名私钥不是有效的 base64: %v", err)
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, fmt.Errorf("固件签名私钥长度无效: %d 字节", len(raw))
+	}
+}
+
+// signDigest 对固件 SHA256 摘要签名，未配置私钥时返回空串
+func signDigest(key ed25519.PrivateKey, sum string) (string, error) {
+	if key == nil {
+		return "", nil
+	}
+	digest, err := hex.DecodeString(sum)
+	if err != nil {
+		return "", fmt.Errorf("无效的 SHA256: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, digest)), nil
+}
+
+// checksumCache 按文件大小与修改时间缓存 SHA256，避免每次 OTA 检查都重新读取整个固件
+type checksumCache struct {
+	mu      sync.Mutex
+	entries map[string]checksumEntry
+}
+
+type checksumEntry struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// sum 计算文件的 SHA256，文件未变化时使用缓存
+func (c *checksumCache) sum(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.sum, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]checksumEntry)
+	}
+	c.entries[path] = checksumEntry{size: info.Size(), modTime: info.ModTime(), sum: sum}
+	c.mu.Unlock()
+	return sum, nil
+}
+
+// verify 校验固件文件与记录的 SHA256 是否一致
+func (c *checksumCache) verify(record *Firmware) error {
+	sum, err := c.sum(firmwarePath(record.Version))
+	if err != nil {
+		return fmt.Errorf("读取固件 %s 失败: %v", record.Version, err)
+	}
+	if sum != record.SHA256 {
+		return fmt.Errorf("固件 %s 校验和不一致，文件可能被篡改: 记录 %s，实际 %s", record.Version, record.SHA256, sum)
+	}
+	return nil
+}
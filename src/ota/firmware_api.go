@@ -0,0 +1,127 @@
+package ota
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+
+	"xiaozhi-server-go/src/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxFirmwareSize = 32 << 20 // 固件大小上限
+
+// registerFirmwareRoutes 注册固件上传与管理接口
+func (s *DefaultOTAService) registerFirmwareRoutes(apiGroup *gin.RouterGroup) {
+	group := apiGroup.Group("/ota/firmwares")
+
+	// 按版本从新到旧列出已上传的固件
+	group.GET("", func(c *gin.Context) {
+		firmwares, err := ListFirmwares()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": firmwares})
+	})
+
+	// 查询固件元数据
+	group.GET("/:version", func(c *gin.Context) {
+		record, err := GetFirmware(c.Param("version"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		if record == nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "固件不存在"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": record})
+	})
+
+	// 上传固件，表单字段：file(文件)、version（默认取文件名）、note；同版本已存在时覆盖
+	group.POST("", func(c *gin.Context) {
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "缺少固件文件: " + err.Error()})
+			return
+		}
+		if file.Size > maxFirmwareSize {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "固件不能超过32MB"})
+			return
+		}
+		version := c.PostForm("version")
+		if version == "" {
+			version = strings.TrimSuffix(file.Filename, ".bin")
+		}
+		if !validVersion(version) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效的固件版本: " + version})
+			return
+		}
+
+		record, err := s.storeFirmware(version, file)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		record.Note = c.PostForm("note")
+		if err := SaveFirmware(record); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		s.logger.Info(fmt.Sprintf("上传固件 %s: %d 字节, sha256 %s，操作者: %s", version, record.Size, record.SHA256, c.GetString(auth.ContextKeySubject)))
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": record})
+	})
+
+	// 删除固件元数据与文件
+	group.DELETE("/:version", func(c *gin.Context) {
+		if err := DeleteFirmware(c.Param("version")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+}
+
+// storeFirmware 写入固件文件并计算校验和与签名，先写临时文件再替换，避免下发写了一半的固件
+func (s *DefaultOTAService) storeFirmware(version string, header *multipart.FileHeader) (*Firmware, error) {
+	src, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取固件失败: %v", err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(otaDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建固件目录失败: %v", err)
+	}
+	tmp, err := os.CreateTemp(otaDir, version+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("创建固件文件失败: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(src, maxFirmwareSize))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("写入固件文件失败: %v", err)
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	signature, err := signDigest(s.signingKey, sum)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), firmwarePath(version)); err != nil {
+		return nil, fmt.Errorf("保存固件文件失败: %v", err)
+	}
+	return &Firmware{Version: version, Size: size, SHA256: sum, Signature: signature}, nil
+}
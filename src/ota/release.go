@@ -0,0 +1,144 @@
+package ota
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/database"
+
+	"gorm.io/gorm"
+)
+
+// 发布状态
+const (
+	ReleaseActive     = "active"      // 发布中，命中的设备会收到升级提示
+	ReleasePaused     = "paused"      // 暂停，不再提示升级，可恢复
+	ReleaseRolledBack = "rolled_back" // 已回滚，不再提示升级，设备回到上一个仍在发布的版本
+)
+
+// Release 固件灰度发布，指定设备列表中的设备总是命中，其余设备按 MAC 哈希取百分比
+type Release struct {
+	ID         uint     `gorm:"primaryKey" json:"id"`
+	Version    string   `gorm:"size:32;index" json:"version"` // 发布的固件版本，需已存在于 ota_bin
+	Channel    string   `gorm:"size:32" json:"channel"`       // 目标渠道，为空时不限渠道
+	Percentage int      `json:"percentage"`                   // 灰度百分比 0~100
+	Devices    []string `gorm:"serializer:json" json:"devices"`
+	Status     string   `gorm:"size:16;index" json:"status"`
+	Note       string   `gorm:"size:255" json:"note"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 发布表名
+func (Release) TableName() string {
+	return "ota_releases"
+}
+
+func init() {
+	database.RegisterModel(&Release{})
+}
+
+// Targets 判断设备是否在本次发布的范围内
+func (r *Release) Targets(device DeviceInfo) bool {
+	if r.Status != ReleaseActive {
+		return false
+	}
+	if r.Channel != "" && !strings.EqualFold(r.Channel, device.Channel) {
+		return false
+	}
+	for _, id := range r.Devices {
+		if strings.EqualFold(id, device.ID) || strings.EqualFold(id, device.MAC) {
+			return true
+		}
+	}
+	return rolloutBucket(r.ID, device.MAC) < r.Percentage
+}
+
+// rolloutBucket 设备在某次发布中的灰度分桶（0~99），同一设备在同一发布中的分桶固定，
+// 扩大百分比时已命中的设备不会掉出
+func rolloutBucket(releaseID uint, mac string) int {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d:%s", releaseID, strings.ToLower(mac))
+	return int(h.Sum32() % 100)
+}
+
+// validate 检查发布内容
+func (r *Release) validate() error {
+	if !validVersion(r.Version) {
+		return fmt.Errorf("无效的固件版本: %s", r.Version)
+	}
+	if r.Percentage < 0 || r.Percentage > 100 {
+		return fmt.Errorf("灰度百分比需在 0~100 之间: %d", r.Percentage)
+	}
+	return nil
+}
+
+// ListReleases 按创建时间从新到旧列出发布
+func ListReleases() ([]Release, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var releases []Release
+	if err := db.Order("id desc").Find(&releases).Error; err != nil {
+		return nil, fmt.Errorf("查询固件发布失败: %v", err)
+	}
+	return releases, nil
+}
+
+// GetRelease 查询单次发布，不存在时返回 nil
+func GetRelease(id uint) (*Release, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var release Release
+	if err := db.First(&release, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询固件发布失败: %v", err)
+	}
+	return &release, nil
+}
+
+// SaveRelease 创建或更新发布，ID 为 0 时创建
+func SaveRelease(release *Release) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	if err := release.validate(); err != nil {
+		return err
+	}
+	if err := db.Save(release).Error; err != nil {
+		return fmt.Errorf("保存固件发布失败: %v", err)
+	}
+	return nil
+}
+
+// releaseVersions 返回命中设备的发布版本，按版本从新到旧；没有任何发布记录时 managed 为 false，
+// 此时沿用扫描 ota_bin 目录选最新固件的方式
+func releaseVersions(device DeviceInfo) (versions []string, managed bool, err error) {
+	releases, err := ListReleases()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(releases) == 0 {
+		return nil, false, nil
+	}
+	for i := range releases {
+		if releases[i].Targets(device) {
+			versions = append(versions, releases[i].Version)
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return compareVersion(versions[j], versions[i]) < 0
+	})
+	return versions, true, nil
+}
@@ -0,0 +1,141 @@
+package ota
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"xiaozhi-server-go/src/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerReleaseRoutes 注册固件灰度发布接口
+func (s *DefaultOTAService) registerReleaseRoutes(apiGroup *gin.RouterGroup) {
+	group := apiGroup.Group("/ota/releases")
+
+	// 按创建时间从新到旧列出发布
+	group.GET("", func(c *gin.Context) {
+		releases, err := ListReleases()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": releases})
+	})
+
+	// 查询单次发布
+	group.GET("/:id", func(c *gin.Context) {
+		release, ok := s.findRelease(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": release})
+	})
+
+	// 创建发布，固件需已上传；创建后立即生效
+	group.POST("", func(c *gin.Context) {
+		var release Release
+		if err := c.ShouldBindJSON(&release); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "解析失败: " + err.Error()})
+			return
+		}
+		if !validVersion(release.Version) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效的固件版本: " + release.Version})
+			return
+		}
+		if _, err := os.Stat(firmwarePath(release.Version)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "固件不存在: " + release.Version})
+			return
+		}
+		release.ID = 0
+		release.Status = ReleaseActive
+		if err := SaveRelease(&release); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		s.logger.Info(fmt.Sprintf("创建固件发布 %d: 版本 %s, 渠道 %q, 灰度 %d%%, 指定设备 %d 台，操作者: %s",
+			release.ID, release.Version, release.Channel, release.Percentage, len(release.Devices), c.GetString(auth.ContextKeySubject)))
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": release})
+	})
+
+	// 调整灰度范围，只更新百分比与指定设备列表
+	group.PATCH("/:id", func(c *gin.Context) {
+		release, ok := s.findRelease(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			Percentage *int     `json:"percentage"`
+			Devices    []string `json:"devices"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "解析失败: " + err.Error()})
+			return
+		}
+		if req.Percentage != nil {
+			release.Percentage = *req.Percentage
+		}
+		if req.Devices != nil {
+			release.Devices = req.Devices
+		}
+		if err := SaveRelease(release); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": release})
+	})
+
+	// 暂停、恢复与回滚
+	group.POST("/:id/pause", s.transitRelease(ReleasePaused, ReleaseActive))
+	group.POST("/:id/resume", s.transitRelease(ReleaseActive, ReleasePaused))
+	group.POST("/:id/rollback", s.transitRelease(ReleaseRolledBack, ReleaseActive, ReleasePaused))
+}
+
+// findRelease 按路径中的 ID 查询发布，失败时直接写出错误响应
+func (s *DefaultOTAService) findRelease(c *gin.Context) (*Release, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效的发布ID"})
+		return nil, false
+	}
+	release, err := GetRelease(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return nil, false
+	}
+	if release == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "固件发布不存在"})
+		return nil, false
+	}
+	return release, true
+}
+
+// transitRelease 把发布切换到 to 状态，只允许从 from 中的状态切换
+func (s *DefaultOTAService) transitRelease(to string, from ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		release, ok := s.findRelease(c)
+		if !ok {
+			return
+		}
+		allowed := false
+		for _, status := range from {
+			if release.Status == status {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": fmt.Sprintf("发布当前状态为 %s，不能切换为 %s", release.Status, to)})
+			return
+		}
+		release.Status = to
+		if err := SaveRelease(release); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		s.logger.Info(fmt.Sprintf("固件发布 %d (%s) 状态切换为 %s，操作者: %s", release.ID, release.Version, to, c.GetString(auth.ContextKeySubject)))
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": release})
+	}
+}
@@ -0,0 +1,192 @@
+package ota
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/database"
+
+	"gorm.io/gorm"
+)
+
+// Rule OTA 差异化配置规则，按优先级从高到低匹配，命中第一条后使用其配置
+// 匹配条件留空表示不限制
+type Rule struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	Name     string `gorm:"size:64" json:"name"`
+	Priority int    `gorm:"index" json:"priority"` // 数值越大越先匹配
+	Enabled  bool   `json:"enabled"`
+
+	// 匹配条件
+	Model      string `gorm:"size:64" json:"model"`       // 设备型号，对应请求中的 board.type
+	MACPrefix  string `gorm:"size:32" json:"mac_prefix"`  // MAC 地址前缀，不区分大小写
+	VersionMin string `gorm:"size:32" json:"version_min"` // 当前固件版本下限（含）
+	VersionMax string `gorm:"size:32" json:"version_max"` // 当前固件版本上限（含）
+
+	// 下发配置，留空时使用默认值
+	WebsocketURL    string          `gorm:"size:255" json:"websocket_url"`
+	FirmwareVersion string          `gorm:"size:32" json:"firmware_version"` // 指定下发的固件版本，对应 ota_bin/<版本>.bin
+	Features        map[string]bool `gorm:"serializer:json" json:"features"` // 灰度功能开关
+	Channel         string          `gorm:"size:32" json:"channel"`          // 设备所属的发布渠道，为空时为 stable
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 规则表名
+func (Rule) TableName() string {
+	return "ota_rules"
+}
+
+func init() {
+	database.RegisterModel(&Rule{})
+}
+
+// DeviceInfo OTA 请求中用于匹配规则的设备信息
+type DeviceInfo struct {
+	ID      string // 请求头中的 device-id
+	Model   string
+	MAC     string
+	Version string
+	Channel string // 由命中的规则决定
+}
+
+// defaultChannel 没有命中规则或规则未指定渠道时设备所属的渠道
+const defaultChannel = "stable"
+
+// Matches 判断设备是否满足规则的匹配条件
+func (r *Rule) Matches(device DeviceInfo) bool {
+	if !r.Enabled {
+		return false
+	}
+	if r.Model != "" && !strings.EqualFold(r.Model, device.Model) {
+		return false
+	}
+	if r.MACPrefix != "" && !strings.HasPrefix(strings.ToLower(device.MAC), strings.ToLower(r.MACPrefix)) {
+		return false
+	}
+	if r.VersionMin != "" && compareVersion(device.Version, r.VersionMin) < 0 {
+		return false
+	}
+	if r.VersionMax != "" && compareVersion(device.Version, r.VersionMax) > 0 {
+		return false
+	}
+	return true
+}
+
+// validate 检查规则内容，指定的固件版本不能包含路径
+func (r *Rule) validate() error {
+	if r.FirmwareVersion != "" && filepath.Base(r.FirmwareVersion) != r.FirmwareVersion {
+		return fmt.Errorf("无效的固件版本: %s", r.FirmwareVersion)
+	}
+	return nil
+}
+
+// ListRules 按匹配顺序列出所有规则
+func ListRules() ([]Rule, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var rules []Rule
+	if err := db.Order("priority desc, id asc").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("查询OTA规则失败: %v", err)
+	}
+	return rules, nil
+}
+
+// GetRule 查询单条规则，不存在时返回 nil
+func GetRule(id uint) (*Rule, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var rule Rule
+	if err := db.First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询OTA规则失败: %v", err)
+	}
+	return &rule, nil
+}
+
+// SaveRule 创建或更新规则，ID 为 0 时创建
+func SaveRule(rule *Rule) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	if err := db.Save(rule).Error; err != nil {
+		return fmt.Errorf("保存OTA规则失败: %v", err)
+	}
+	return nil
+}
+
+// DeleteRule 删除规则
+func DeleteRule(id uint) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	result := db.Delete(&Rule{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除OTA规则失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("OTA规则 %d 不存在", id)
+	}
+	return nil
+}
+
+// MatchRule 返回设备命中的第一条规则，没有命中时返回 nil
+func MatchRule(device DeviceInfo) (*Rule, error) {
+	rules, err := ListRules()
+	if err != nil {
+		return nil, err
+	}
+	for i := range rules {
+		if rules[i].Matches(device) {
+			return &rules[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// compareVersion 按段比较版本号，数字段按数值比较，返回 -1/0/1
+func compareVersion(a, b string) int {
+	aV := strings.Split(a, ".")
+	bV := strings.Split(b, ".")
+	for i := 0; i < len(aV) && i < len(bV); i++ {
+		if aV[i] == bV[i] {
+			continue
+		}
+		aN, aErr := strconv.Atoi(aV[i])
+		bN, bErr := strconv.Atoi(bV[i])
+		if aErr == nil && bErr == nil {
+			if aN < bN {
+				return -1
+			}
+			return 1
+		}
+		if aV[i] < bV[i] {
+			return -1
+		}
+		return 1
+	}
+	switch {
+	case len(aV) < len(bV):
+		return -1
+	case len(aV) > len(bV):
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,108 @@
+package ota
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerRuleRoutes 注册 OTA 规则管理接口
+func (s *DefaultOTAService) registerRuleRoutes(apiGroup *gin.RouterGroup) {
+	group := apiGroup.Group("/ota/rules")
+
+	// 按匹配顺序列出所有规则
+	group.GET("", func(c *gin.Context) {
+		rules, err := ListRules()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": rules})
+	})
+
+	// 查询单条规则
+	group.GET("/:id", func(c *gin.Context) {
+		id, ok := ruleID(c)
+		if !ok {
+			return
+		}
+		rule, err := GetRule(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		if rule == nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "OTA规则不存在"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+	})
+
+	// 创建规则
+	group.POST("", func(c *gin.Context) {
+		var rule Rule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "解析失败: " + err.Error()})
+			return
+		}
+		rule.ID = 0
+		if err := SaveRule(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+	})
+
+	// 整体更新规则
+	group.PUT("/:id", func(c *gin.Context) {
+		id, ok := ruleID(c)
+		if !ok {
+			return
+		}
+		existing, err := GetRule(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		if existing == nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "OTA规则不存在"})
+			return
+		}
+		var rule Rule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "解析失败: " + err.Error()})
+			return
+		}
+		rule.ID = id
+		rule.CreatedAt = existing.CreatedAt
+		if err := SaveRule(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+	})
+
+	// 删除规则
+	group.DELETE("/:id", func(c *gin.Context) {
+		id, ok := ruleID(c)
+		if !ok {
+			return
+		}
+		if err := DeleteRule(id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+}
+
+// ruleID 解析路径中的规则 ID，无效时直接返回 400
+func ruleID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效的规则ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -2,6 +2,9 @@ package ota
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -9,26 +12,50 @@ import (
 	"strings"
 	"time"
 
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/utils"
+
 	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
 )
 
 type DefaultOTAService struct {
 	UpdateURL string
+	config    configs.OTAConfig
+	logger    *utils.Logger
+
+	signingKey ed25519.PrivateKey // 固件签名私钥，未配置时为 nil
+	checksums  checksumCache
+	limiter    *rate.Limiter // 所有固件下载共享的限速器，未配置限速时为 nil
 }
 
 // NewDefaultOTAService 构造函数
-func NewDefaultOTAService(updateURL string) *DefaultOTAService {
-	return &DefaultOTAService{UpdateURL: updateURL}
+func NewDefaultOTAService(updateURL string, config configs.OTAConfig, logger *utils.Logger) *DefaultOTAService {
+	return &DefaultOTAService{UpdateURL: updateURL, config: config, logger: logger}
 }
 
 // Start 实现 OTAService 接口，注册所有 OTA 相关路由
 func (s *DefaultOTAService) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	key, err := parseSigningKey(s.config.SigningKey)
+	if err != nil {
+		return err
+	}
+	s.signingKey = key
+	limit, err := parseByteSize(s.config.DownloadRateLimit)
+	if err != nil {
+		return fmt.Errorf("固件下载限速配置无效: %v", err)
+	}
+	if limit > 0 {
+		s.limiter = newDownloadLimiter(limit)
+		s.logger.Info(fmt.Sprintf("固件下载限速: %s/s", s.config.DownloadRateLimit))
+	}
+	if key != nil {
+		s.logger.Info(fmt.Sprintf("已启用固件签名，验签公钥(base64): %s", base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))))
+	}
+
 	// OTA 主接口（支持 OPTIONS/GET/POST）
+	// 跨域 header 由全局 CORS 中间件统一处理
 	apiGroup.Any("/ota/", func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Headers", "client-id, content-type, device-id")
-		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Origin", "*")
-
 		switch c.Request.Method {
 		case http.MethodOptions:
 			c.Status(http.StatusOK)
@@ -46,70 +73,152 @@ func (s *DefaultOTAService) Start(ctx context.Context, engine *gin.Engine, apiGr
 				return
 			}
 
-			// 从请求体取版本号
-			version := "1.0.0"
-			if app, ok := body["application"].(map[string]interface{}); ok {
-				if v, ok := app["version"].(string); ok {
-					version = v
-				}
+			device := parseDeviceInfo(deviceID, body)
+			// 规则不可用时按默认配置下发，不影响设备升级
+			rule, _ := MatchRule(device)
+			if rule != nil && rule.Channel != "" {
+				device.Channel = rule.Channel
 			}
 
-			// 扫描 ota_bin 目录，选最新固件
-			otaDir := filepath.Join(".", "ota_bin")
-			_ = os.MkdirAll(otaDir, 0755)
-			bins, _ := filepath.Glob(filepath.Join(otaDir, "*.bin"))
-			firmwareURL := ""
-			if len(bins) > 0 {
-				sort.Slice(bins, func(i, j int) bool {
-					return versionLess(bins[j], bins[i])
-				})
-				latest := filepath.Base(bins[0])
-				version = strings.TrimSuffix(latest, ".bin")
-				firmwareURL = "/ota_bin/" + latest
+			websocketURL := s.UpdateURL
+			if rule != nil && rule.WebsocketURL != "" {
+				websocketURL = rule.WebsocketURL
 			}
-
-			c.JSON(http.StatusOK, gin.H{
+			resp := gin.H{
 				"server_time": gin.H{
 					"timestamp":       time.Now().UnixNano() / 1e6,
 					"timezone_offset": 8 * 60,
 				},
-				"firmware": gin.H{
-					"version": version,
-					"url":     firmwareURL,
-				},
+				"firmware": s.pickFirmware(device, rule),
 				"websocket": gin.H{
-					"url": s.UpdateURL,
+					"url": websocketURL,
 				},
-			})
+			}
+			if rule != nil && len(rule.Features) > 0 {
+				resp["features"] = rule.Features
+			}
+			c.JSON(http.StatusOK, resp)
 		default:
 			c.String(http.StatusMethodNotAllowed, "不支持的方法: %s", c.Request.Method)
 		}
 	})
 
-	// OTA 固件下载
+	// OTA 固件下载，有上传记录的固件下载前再次校验，防止检查之后文件被替换
 	engine.GET("/ota_bin/:filename", func(c *gin.Context) {
 		fname := c.Param("filename")
-		p := filepath.Join("ota_bin", fname)
+		p := filepath.Join(otaDir, fname)
 		if _, err := os.Stat(p); os.IsNotExist(err) {
 			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "file not found"})
 			return
 		}
-		c.Header("Content-Type", "application/octet-stream")
-		c.Header("Content-Disposition", "attachment; filename="+fname)
-		c.File(p)
+		record, err := s.checkFirmware(strings.TrimSuffix(fname, ".bin"))
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("拒绝下载固件 %s: %v", fname, err))
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "firmware verification failed"})
+			return
+		}
+		s.serveFirmware(c, p, record)
 	})
 
+	s.registerRuleRoutes(apiGroup)
+	s.registerFirmwareRoutes(apiGroup)
+	s.registerReleaseRoutes(apiGroup)
 	return nil
 }
 
-// 按语义比较两个版本号 a < b
-func versionLess(a, b string) bool {
-	aV := strings.Split(strings.TrimSuffix(filepath.Base(a), ".bin"), ".")
-	bV := strings.Split(strings.TrimSuffix(filepath.Base(b), ".bin"), ".")
-	for i := 0; i < len(aV) && i < len(bV); i++ {
-		if aV[i] != bV[i] {
-			return aV[i] < bV[i]
+// parseDeviceInfo 从 OTA 请求中取设备型号、MAC 与当前固件版本
+func parseDeviceInfo(deviceID string, body map[string]interface{}) DeviceInfo {
+	device := DeviceInfo{ID: deviceID, MAC: deviceID, Version: "1.0.0", Channel: defaultChannel}
+	if mac, ok := body["mac_address"].(string); ok && mac != "" {
+		device.MAC = mac
+	}
+	if app, ok := body["application"].(map[string]interface{}); ok {
+		if v, ok := app["version"].(string); ok {
+			device.Version = v
 		}
 	}
-	return len(aV) < len(bV)
+	if board, ok := body["board"].(map[string]interface{}); ok {
+		if t, ok := board["type"].(string); ok {
+			device.Model = t
+		}
+	}
+	return device
+}
+
+// pickFirmware 选择下发的固件：先尝试规则指定的版本，再尝试命中设备的发布；
+// 没有任何发布记录时按版本从新到旧扫描 ota_bin 目录
+// 有上传记录的固件必须通过校验和检查，没有记录的固件仅在未要求校验时下发
+// 没有可用固件时返回设备当前版本与空地址，设备不会提示升级
+func (s *DefaultOTAService) pickFirmware(device DeviceInfo, rule *Rule) gin.H {
+	_ = os.MkdirAll(otaDir, 0755)
+	var candidates []string
+	if rule != nil && rule.FirmwareVersion != "" {
+		candidates = append(candidates, rule.FirmwareVersion)
+	}
+	released, managed, err := releaseVersions(device)
+	if err != nil {
+		s.logger.Warn(fmt.Sprintf("查询固件发布失败，按目录中的最新固件下发: %v", err))
+	}
+	if managed {
+		candidates = append(candidates, released...)
+	} else {
+		bins, _ := filepath.Glob(filepath.Join(otaDir, "*.bin"))
+		sort.Slice(bins, func(i, j int) bool {
+			return versionLess(bins[j], bins[i])
+		})
+		for _, bin := range bins {
+			candidates = append(candidates, strings.TrimSuffix(filepath.Base(bin), ".bin"))
+		}
+	}
+
+	for _, version := range candidates {
+		if _, err := os.Stat(firmwarePath(version)); err != nil {
+			continue
+		}
+		firmware := gin.H{
+			"version": version,
+			"url":     "/ota_bin/" + version + ".bin",
+		}
+		record, err := s.checkFirmware(version)
+		if err != nil {
+			s.logger.Warn(fmt.Sprintf("跳过固件 %s: %v", version, err))
+			continue
+		}
+		if record != nil {
+			firmware["size"] = record.Size
+			firmware["sha256"] = record.SHA256
+			if record.Signature != "" {
+				firmware["signature"] = record.Signature
+			}
+		}
+		return firmware
+	}
+	return gin.H{"version": device.Version, "url": ""}
+}
+
+// checkFirmware 校验固件文件，返回其上传记录；没有记录且要求校验时返回错误
+func (s *DefaultOTAService) checkFirmware(version string) (*Firmware, error) {
+	record, err := GetFirmware(version)
+	if err != nil {
+		if s.config.RequireChecksum {
+			return nil, err
+		}
+		// 数据库不可用时按未记录的固件处理
+		return nil, nil
+	}
+	if record == nil {
+		if s.config.RequireChecksum {
+			return nil, fmt.Errorf("没有上传记录，不能校验")
+		}
+		return nil, nil
+	}
+	if err := s.checksums.verify(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// 按语义比较两个版本号 a < b
+func versionLess(a, b string) bool {
+	return compareVersion(strings.TrimSuffix(filepath.Base(a), ".bin"), strings.TrimSuffix(filepath.Base(b), ".bin")) < 0
 }
@@ -0,0 +1,76 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/database"
+
+	"gorm.io/gorm"
+)
+
+// Profile 设备档案，保存用户在对话中做出的需要跨会话保留的选择
+type Profile struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	DeviceID  string    `gorm:"size:64;uniqueIndex" json:"device_id"`
+	Role      string    `gorm:"size:64" json:"role"`   // 最近切换的角色名称，为空表示使用默认提示词
+	TTS       string    `gorm:"size:64" json:"tts"`    // 保存音色时的主 TTS 配置名称
+	Voice     string    `gorm:"size:128" json:"voice"` // 用户指定的音色，为空表示使用角色或配置的音色
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func init() {
+	database.RegisterModel(&Profile{})
+}
+
+// Get 查询设备档案，不存在时返回 nil
+func Get(deviceID string) (*Profile, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var record Profile
+	if err := db.Where("device_id = ?", deviceID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询设备档案失败: %v", err)
+	}
+	return &record, nil
+}
+
+// update 读取设备档案并修改后保存，档案不存在时新建
+func update(deviceID string, modify func(record *Profile)) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	record := &Profile{}
+	err := db.Where("device_id = ?", deviceID).First(record).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("查询设备档案失败: %v", err)
+	}
+	record.DeviceID = deviceID
+	modify(record)
+	if err := db.Save(record).Error; err != nil {
+		return fmt.Errorf("保存设备档案失败: %v", err)
+	}
+	return nil
+}
+
+// SaveRole 保存设备最近切换的角色，角色绑定音色时清除之前指定的音色
+func SaveRole(deviceID, role string, clearVoice bool) error {
+	return update(deviceID, func(record *Profile) {
+		record.Role = role
+		if clearVoice {
+			record.TTS, record.Voice = "", ""
+		}
+	})
+}
+
+// SaveVoice 保存用户指定的音色
+func SaveVoice(deviceID, ttsName, voice string) error {
+	return update(deviceID, func(record *Profile) { record.TTS, record.Voice = ttsName, voice })
+}
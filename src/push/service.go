@@ -0,0 +1,132 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"xiaozhi-server-go/src/auth"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 单个连接的推送结果
+const (
+	StatusSent    = "sent"    // 已开始播报
+	StatusBusy    = "busy"    // 设备正在播报或拾音，未要求打断
+	StatusDND     = "dnd"     // 设备处于免打扰
+	StatusOffline = "offline" // 设备不在线或正在断开
+	StatusFailed  = "failed"  // 播报失败
+)
+
+// maxTextLen 单次推送的文本上限（字符数），更长的内容应拆成多次推送
+const maxTextLen = 1000
+
+// Runtime 推送的执行方，由 WebSocket 服务实现
+type Runtime interface {
+	// PushSpeak 在设备连接上播报文本，deviceID 为空时向所有在线设备广播；interrupt 为 true 时打断正在进行的播报
+	PushSpeak(deviceID, text string, interrupt bool) []Result
+}
+
+// Result 一个连接的推送结果
+type Result struct {
+	DeviceID string `json:"device_id"`
+	ClientID string `json:"client_id,omitempty"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Service 服务端主动推送接口
+type Service struct {
+	runtime Runtime
+	logger  *utils.Logger
+}
+
+// NewService 创建推送服务
+func NewService(runtime Runtime, logger *utils.Logger) *Service {
+	return &Service{runtime: runtime, logger: logger}
+}
+
+// speakRequest 播报请求体，device_id 为空时需显式设置 broadcast 才会向所有设备广播
+type speakRequest struct {
+	DeviceID  string `json:"device_id"`
+	Text      string `json:"text"`
+	Broadcast bool   `json:"broadcast"`
+	Interrupt bool   `json:"interrupt"`
+}
+
+// Start 注册推送相关路由
+func (s *Service) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	group := apiGroup.Group("/push")
+
+	// 向设备推送一段语音播报，作为新的一轮写入设备的对话历史
+	group.POST("/speak", func(c *gin.Context) {
+		var req speakRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": fmt.Sprintf("请求格式错误: %v", err)})
+			return
+		}
+		req.Text = strings.TrimSpace(req.Text)
+		if req.Text == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "text 不能为空"})
+			return
+		}
+		if len([]rune(req.Text)) > maxTextLen {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": fmt.Sprintf("text 不能超过 %d 个字符", maxTextLen)})
+			return
+		}
+		if req.DeviceID == "" && !req.Broadcast {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "需要指定 device_id，或设置 broadcast 向所有设备广播"})
+			return
+		}
+		if req.DeviceID != "" && req.Broadcast {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "device_id 与 broadcast 不能同时设置"})
+			return
+		}
+
+		results := s.runtime.PushSpeak(req.DeviceID, req.Text, req.Interrupt)
+		sent := 0
+		for _, r := range results {
+			if r.Status == StatusSent {
+				sent++
+			}
+		}
+		target := req.DeviceID
+		if req.Broadcast {
+			target = "全部设备"
+		}
+		s.logger.Info(fmt.Sprintf("推送播报到 %s，成功 %d/%d，操作者: %s", target, sent, len(results), c.GetString(auth.ContextKeySubject)))
+
+		// 指定设备时按结果返回对应的状态码，广播时逐个返回结果；Runtime 对指定设备至少返回一条结果
+		if req.DeviceID != "" && sent == 0 {
+			code := http.StatusInternalServerError
+			switch results[0].Status {
+			case StatusOffline:
+				code = http.StatusNotFound
+			case StatusBusy, StatusDND:
+				code = http.StatusConflict
+			}
+			c.JSON(code, gin.H{"success": false, "message": failureMessage(results[0]), "data": gin.H{"results": results}})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"sent": sent, "total": len(results), "results": results}})
+	})
+
+	return nil
+}
+
+// failureMessage 指定设备推送失败时的提示
+func failureMessage(r Result) string {
+	switch r.Status {
+	case StatusBusy:
+		return "设备正在播报或拾音，可设置 interrupt 打断后播报"
+	case StatusDND:
+		return "设备处于免打扰"
+	case StatusOffline:
+		return "设备不在线"
+	default:
+		return r.Message
+	}
+}
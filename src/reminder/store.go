@@ -0,0 +1,107 @@
+package reminder
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/database"
+
+	"gorm.io/gorm"
+)
+
+// 提醒状态
+const (
+	StatusPending   = "pending"   // 等待到点播报，设备离线时到点后保持该状态，重新连接时补播
+	StatusDone      = "done"      // 已播报
+	StatusCancelled = "cancelled" // 用户已取消
+)
+
+// Reminder 设备的定时提醒
+type Reminder struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	DeviceID  string    `gorm:"size:64;index" json:"device_id"`
+	Content   string    `gorm:"size:255" json:"content"`
+	RemindAt  time.Time `gorm:"index" json:"remind_at"`
+	Status    string    `gorm:"size:16;index" json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func init() {
+	database.RegisterModel(&Reminder{})
+}
+
+// Create 创建提醒
+func Create(deviceID, content string, remindAt time.Time) (*Reminder, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	record := &Reminder{DeviceID: deviceID, Content: content, RemindAt: remindAt, Status: StatusPending}
+	if err := db.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("保存提醒失败: %v", err)
+	}
+	return record, nil
+}
+
+// ListPending 列出待播报的提醒，deviceID 为空时列出所有设备的提醒，按提醒时间排序
+func ListPending(deviceID string) ([]Reminder, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	query := db.Where("status = ?", StatusPending)
+	if deviceID != "" {
+		query = query.Where("device_id = ?", deviceID)
+	}
+	var reminders []Reminder
+	if err := query.Order("remind_at asc").Find(&reminders).Error; err != nil {
+		return nil, fmt.Errorf("查询提醒失败: %v", err)
+	}
+	return reminders, nil
+}
+
+// ListDue 列出设备已到点但尚未播报的提醒
+func ListDue(deviceID string, now time.Time) ([]Reminder, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var reminders []Reminder
+	err := db.Where("status = ? AND device_id = ? AND remind_at <= ?", StatusPending, deviceID, now).
+		Order("remind_at asc").Find(&reminders).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询提醒失败: %v", err)
+	}
+	return reminders, nil
+}
+
+// Get 查询提醒，不存在时返回 nil
+func Get(id uint) (*Reminder, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var record Reminder
+	if err := db.First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询提醒失败: %v", err)
+	}
+	return &record, nil
+}
+
+// SetStatus 更新提醒状态，只更新仍处于待播报状态的提醒，返回是否更新成功
+func SetStatus(id uint, status string) (bool, error) {
+	db := database.GetDB()
+	if db == nil {
+		return false, fmt.Errorf("数据库未初始化")
+	}
+	result := db.Model(&Reminder{}).Where("id = ? AND status = ?", id, StatusPending).Update("status", status)
+	if result.Error != nil {
+		return false, fmt.Errorf("更新提醒失败: %v", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,73 @@
+package reminder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 绝对时间支持的格式，不带日期时按今天计算
+var clockLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"15:04:05",
+	"15:04",
+}
+
+// ResolveTime 计算提醒时间
+// delay 为相对时长（如 10m、1h30m），clock 为绝对时间（HH:MM 或 YYYY-MM-DD HH:MM），二者取其一；
+// dayOffset 为只给出时刻时相对今天的天数（0 今天，1 明天），只给出时刻且未指定天数时，已过的时刻顺延到明天
+func ResolveTime(now time.Time, delay, clock string, dayOffset int) (time.Time, error) {
+	delay = strings.TrimSpace(delay)
+	clock = strings.TrimSpace(clock)
+	switch {
+	case delay != "":
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("无法解析相对时间: %s", delay)
+		}
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("相对时间需大于 0: %s", delay)
+		}
+		return now.Add(d), nil
+	case clock != "":
+		for _, layout := range clockLayouts {
+			t, err := time.ParseInLocation(layout, clock, now.Location())
+			if err != nil {
+				continue
+			}
+			if strings.HasPrefix(layout, "2006") {
+				return t, nil
+			}
+			at := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+			if dayOffset > 0 {
+				return at.AddDate(0, 0, dayOffset), nil
+			}
+			if !at.After(now) {
+				at = at.AddDate(0, 0, 1)
+			}
+			return at, nil
+		}
+		return time.Time{}, fmt.Errorf("无法解析提醒时间: %s", clock)
+	default:
+		return time.Time{}, fmt.Errorf("缺少提醒时间")
+	}
+}
+
+// Describe 把提醒时间说成口语，如“今天18:30”“明天08:00”“10月20日09:00”，按 now 的时区表述
+func Describe(now, at time.Time) string {
+	at = at.In(now.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch day := at.Sub(today); {
+	case day >= 0 && day < 24*time.Hour:
+		return "今天" + at.Format("15:04")
+	case day >= 24*time.Hour && day < 48*time.Hour:
+		return "明天" + at.Format("15:04")
+	case day >= 48*time.Hour && day < 72*time.Hour:
+		return "后天" + at.Format("15:04")
+	default:
+		return fmt.Sprintf("%d月%d日", at.Month(), at.Day()) + at.Format("15:04")
+	}
+}
@@ -0,0 +1,113 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// StateReady 服务启动完成
+	StateReady = "READY=1"
+	// StateStopping 服务开始关闭
+	StateStopping = "STOPPING=1"
+	// StateReloading 服务开始重新加载（平滑重启）
+	StateReloading = "RELOADING=1"
+	// StateWatchdog 看门狗心跳
+	StateWatchdog = "WATCHDOG=1"
+)
+
+// Notify 向 systemd 发送状态通知（sd_notify 协议）
+// 未以 systemd Type=notify 方式运行时（NOTIFY_SOCKET 为空）返回 false 且不报错
+func Notify(state string) (bool, error) {
+	socketAddr := os.Getenv("NOTIFY_SOCKET")
+	if socketAddr == "" {
+		return false, nil
+	}
+	// 以@开头的为抽象命名空间socket
+	if socketAddr[0] == '@' {
+		socketAddr = "\x00" + socketAddr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketAddr, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("连接 systemd 通知socket失败: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("发送 systemd 通知失败: %v", err)
+	}
+	return true, nil
+}
+
+// NotifyMainPID 通知 systemd 主进程已变更为pid，用于平滑重启后新进程接管
+func NotifyMainPID(pid int) (bool, error) {
+	return Notify(fmt.Sprintf("MAINPID=%d\n%s", pid, StateReady))
+}
+
+// WatchdogInterval 返回 systemd 配置的看门狗超时时间，未启用时返回0
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	// WATCHDOG_PID 不是当前进程时说明看门狗不是给我们的
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != "" {
+		if pid, err := strconv.Atoi(pidStr); err != nil || pid != os.Getpid() {
+			return 0
+		}
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// StartWatchdog 按看门狗超时的一半周期发送心跳
+// check 用于探测主循环是否存活，返回错误或在半个周期内未返回时跳过本次心跳，
+// 连续错过心跳后由 systemd 负责重启服务
+func StartWatchdog(ctx context.Context, check func() error, onMiss func(error)) bool {
+	timeout := WatchdogInterval()
+	if timeout == 0 {
+		return false
+	}
+	interval := timeout / 2
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := runCheck(check, interval); err != nil {
+					if onMiss != nil {
+						onMiss(err)
+					}
+					continue
+				}
+				Notify(StateWatchdog)
+			}
+		}
+	}()
+	return true
+}
+
+// runCheck 在超时时间内执行存活探测
+func runCheck(check func() error, timeout time.Duration) error {
+	if check == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- check()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("存活探测超时(%v)", timeout)
+	}
+}
@@ -80,6 +80,11 @@ func (tm *TaskManager) scheduleTask(clientID string, task *Task) error {
 	return nil
 }
 
+// CancelTask cancels a scheduled task that has not been executed yet
+func (tm *TaskManager) CancelTask(taskID string) bool {
+	return tm.scheduledTasks.RemoveTask(taskID)
+}
+
 // ScheduledTasks manages scheduled tasks
 type ScheduledTasks struct {
 	tasks    map[string]*Task
@@ -115,6 +120,17 @@ func (st *ScheduledTasks) AddTask(task *Task) {
 	st.tasks[task.ID] = task
 }
 
+// RemoveTask removes a scheduled task, returns false if it does not exist
+func (st *ScheduledTasks) RemoveTask(taskID string) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.tasks[taskID]; !ok {
+		return false
+	}
+	delete(st.tasks, taskID)
+	return true
+}
+
 // run processes scheduled tasks
 func (st *ScheduledTasks) run() {
 	for {
@@ -112,6 +112,9 @@ func (t *Task) executeScheduled() {
 			case "play_music":
 				// Handle music playback
 				t.Result = "Music played successfully"
+			case "reminder":
+				// 提醒的播报由回调完成，结果为提醒内容
+				t.Result = params["content"]
 			default:
 				t.Error = fmt.Errorf("unknown scheduled action: %v", action)
 			}
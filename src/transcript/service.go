@@ -0,0 +1,110 @@
+package transcript
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultListLimit = 50
+
+// Service 会议转写结果查询与下载接口
+type Service struct {
+	logger *utils.Logger
+}
+
+// NewService 创建会议转写服务
+func NewService(logger *utils.Logger) *Service {
+	return &Service{logger: logger}
+}
+
+// Start 注册会议转写相关路由
+func (s *Service) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	group := apiGroup.Group("/transcripts")
+
+	// 列出转写记录，可按 device_id 过滤
+	group.GET("", func(c *gin.Context) {
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultListLimit)))
+		if err != nil || limit <= 0 {
+			limit = defaultListLimit
+		}
+		records, err := List(c.Query("device_id"), limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": records})
+	})
+
+	// 查询转写记录，包含逐句内容与摘要
+	group.GET("/:id", func(c *gin.Context) {
+		record, ok := s.find(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": record})
+	})
+
+	// 下载转写结果，format=txt（默认）为带时间戳的文本，format=json 为完整记录
+	group.GET("/:id/download", func(c *gin.Context) {
+		record, ok := s.find(c)
+		if !ok {
+			return
+		}
+		name := fmt.Sprintf("transcript-%d-%s", record.ID, record.StartedAt.Format("20060102-150405"))
+		switch c.DefaultQuery("format", "txt") {
+		case "json":
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, name))
+			c.JSON(http.StatusOK, record)
+		case "txt":
+			content := record.Text()
+			if record.Summary != "" {
+				content = "摘要\n" + record.Summary + "\n\n转写\n" + content
+			}
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.txt"`, name))
+			c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(content))
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "format 只能是 txt 或 json"})
+		}
+	})
+
+	// 删除转写记录
+	group.DELETE("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效的记录ID"})
+			return
+		}
+		if err := Delete(uint(id)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	return nil
+}
+
+// find 按路径参数查询记录，失败时已写入响应
+func (s *Service) find(c *gin.Context) (*Transcript, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "无效的记录ID"})
+		return nil, false
+	}
+	record, err := Get(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return nil, false
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "转写记录不存在"})
+		return nil, false
+	}
+	return record, true
+}
@@ -0,0 +1,152 @@
+package transcript
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"xiaozhi-server-go/src/database"
+
+	"gorm.io/gorm"
+)
+
+// 转写记录状态
+const (
+	StatusRecording   = "recording"   // 正在转写
+	StatusSummarizing = "summarizing" // 转写结束，正在生成摘要
+	StatusDone        = "done"        // 已完成，摘要生成失败时 summary 为空
+)
+
+// Segment 一句转写结果，时间为相对转写开始的毫秒数
+type Segment struct {
+	Start   int64  `json:"start"`
+	End     int64  `json:"end"`
+	Speaker string `json:"speaker,omitempty"` // ASR 区分说话人时的说话人标识
+	Text    string `json:"text"`
+}
+
+// Transcript 一次会议转写
+type Transcript struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	DeviceID  string     `gorm:"size:64;index" json:"device_id"`
+	SessionID string     `gorm:"size:64" json:"session_id"`
+	Status    string     `gorm:"size:16" json:"status"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Segments  []Segment  `gorm:"serializer:json" json:"segments"`
+	Summary   string     `json:"summary"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func init() {
+	database.RegisterModel(&Transcript{})
+}
+
+// Text 返回逐句带时间戳与说话人的纯文本
+func (t *Transcript) Text() string {
+	var sb strings.Builder
+	for _, s := range t.Segments {
+		sb.WriteString("[" + formatOffset(s.Start) + "] ")
+		if s.Speaker != "" {
+			sb.WriteString(s.Speaker + ": ")
+		}
+		sb.WriteString(s.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// formatOffset 将毫秒数格式化为 HH:MM:SS
+func formatOffset(ms int64) string {
+	sec := ms / 1000
+	return fmt.Sprintf("%02d:%02d:%02d", sec/3600, sec/60%60, sec%60)
+}
+
+// Create 开始一次转写
+func Create(deviceID, sessionID string, startedAt time.Time) (*Transcript, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	record := &Transcript{DeviceID: deviceID, SessionID: sessionID, Status: StatusRecording, StartedAt: startedAt}
+	if err := db.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("保存转写记录失败: %v", err)
+	}
+	return record, nil
+}
+
+// Save 保存转写内容与状态
+func Save(record *Transcript) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	if err := db.Save(record).Error; err != nil {
+		return fmt.Errorf("保存转写记录失败: %v", err)
+	}
+	return nil
+}
+
+// Get 查询转写记录，不存在时返回 nil
+func Get(id uint) (*Transcript, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var record Transcript
+	if err := db.First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询转写记录失败: %v", err)
+	}
+	return &record, nil
+}
+
+// List 按开始时间倒序列出转写记录，不含逐句内容，deviceID 为空时列出所有设备
+func List(deviceID string, limit int) ([]Transcript, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	query := db.Omit("segments").Order("started_at desc").Limit(limit)
+	if deviceID != "" {
+		query = query.Where("device_id = ?", deviceID)
+	}
+	var records []Transcript
+	if err := query.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询转写记录失败: %v", err)
+	}
+	return records, nil
+}
+
+// Delete 删除转写记录
+func Delete(id uint) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	result := db.Delete(&Transcript{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除转写记录失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("转写记录不存在")
+	}
+	return nil
+}
+
+// FinishStale 将服务重启前未结束的转写标记为完成，没有摘要
+func FinishStale() error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	err := db.Model(&Transcript{}).Where("status <> ?", StatusDone).Update("status", StatusDone).Error
+	if err != nil {
+		return fmt.Errorf("更新转写记录失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package voiceclone
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"xiaozhi-server-go/src/auth"
+	"xiaozhi-server-go/src/configs"
+	"xiaozhi-server-go/src/core/pool"
+	"xiaozhi-server-go/src/core/providers/tts"
+	"xiaozhi-server-go/src/core/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxAudioSize = 10 << 20 // 参考音频大小上限
+	cloneTimeout = 2 * time.Minute
+)
+
+// Service 声音克隆注册接口
+type Service struct {
+	config *configs.Config
+	logger *utils.Logger
+}
+
+// NewService 创建声音克隆服务
+func NewService(config *configs.Config, logger *utils.Logger) *Service {
+	return &Service{config: config, logger: logger}
+}
+
+// Start 注册声音克隆相关路由，与 OTAService 保持一致的注册方式
+func (s *Service) Start(ctx context.Context, engine *gin.Engine, apiGroup *gin.RouterGroup) error {
+	group := apiGroup.Group("/voice_clone")
+
+	// 查询设备的克隆音色
+	group.GET("/:device_id", func(c *gin.Context) {
+		record, err := GetDeviceVoice(c.Param("device_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		if record == nil {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "设备未注册克隆音色"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": record})
+	})
+
+	// 上传参考音频注册克隆音色，表单字段：audio(文件)、audio_url、name、text、tts
+	group.POST("/:device_id", func(c *gin.Context) {
+		deviceID := c.Param("device_id")
+		clone := tts.CloneRequest{
+			Name:     c.PostForm("name"),
+			AudioURL: c.PostForm("audio_url"),
+			Text:     c.PostForm("text"),
+		}
+		if clone.Name == "" {
+			clone.Name = deviceID
+		}
+		if file, err := c.FormFile("audio"); err == nil {
+			if file.Size > maxAudioSize {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "参考音频不能超过10MB"})
+				return
+			}
+			audio, err := readAudio(file)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+				return
+			}
+			clone.Audio = audio
+			clone.Filename = filepath.Base(file.Filename)
+		}
+		if len(clone.Audio) == 0 && clone.AudioURL == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "需要上传 audio 文件或提供 audio_url"})
+			return
+		}
+
+		ttsName := c.PostForm("tts")
+		if ttsName == "" {
+			ttsName = s.config.SelectedModule["TTS"]
+		}
+		cloneCtx, cancel := context.WithTimeout(c.Request.Context(), cloneTimeout)
+		defer cancel()
+		voice, err := s.clone(cloneCtx, ttsName, clone)
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("设备 %s 声音克隆失败: %v", deviceID, err))
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		record, err := SaveDeviceVoice(deviceID, ttsName, voice, clone.Name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		s.logger.Info(fmt.Sprintf("设备 %s 注册克隆音色: %s (%s)，操作者: %s", deviceID, voice, ttsName, c.GetString(auth.ContextKeySubject)))
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": record})
+	})
+
+	// 删除设备的克隆音色
+	group.DELETE("/:device_id", func(c *gin.Context) {
+		if err := DeleteDeviceVoice(c.Param("device_id")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	return nil
+}
+
+// clone 临时创建指定配置的 TTS 提供者完成克隆，不占用资源池中的实例
+func (s *Service) clone(ctx context.Context, ttsName string, clone tts.CloneRequest) (string, error) {
+	factory := pool.NewTTSFactory(ttsName, s.config, s.logger)
+	if factory == nil {
+		return "", fmt.Errorf("找不到TTS配置: %s", ttsName)
+	}
+	resource, err := factory.Create()
+	if err != nil {
+		return "", err
+	}
+	defer factory.Destroy(resource)
+
+	cloner, ok := resource.(tts.VoiceCloner)
+	if !ok {
+		return "", fmt.Errorf("TTS %s 不支持声音克隆", ttsName)
+	}
+	return cloner.CloneVoice(ctx, clone)
+}
+
+// readAudio 读取上传的参考音频内容
+func readAudio(header *multipart.FileHeader) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取参考音频失败: %v", err)
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxAudioSize))
+	if err != nil {
+		return nil, fmt.Errorf("读取参考音频失败: %v", err)
+	}
+	return data, nil
+}
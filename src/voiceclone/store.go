@@ -0,0 +1,80 @@
+package voiceclone
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"xiaozhi-server-go/src/database"
+
+	"gorm.io/gorm"
+)
+
+// DeviceVoice 设备专属的克隆音色，每台设备只保留最近一次注册的音色
+type DeviceVoice struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	DeviceID  string    `gorm:"size:64;uniqueIndex" json:"device_id"`
+	TTS       string    `gorm:"size:64" json:"tts"` // 注册音色使用的 TTS 配置名称
+	Voice     string    `gorm:"size:128" json:"voice"`
+	Name      string    `gorm:"size:64" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func init() {
+	database.RegisterModel(&DeviceVoice{})
+}
+
+// SaveDeviceVoice 保存设备的克隆音色，已存在时覆盖
+func SaveDeviceVoice(deviceID, ttsName, voice, name string) (*DeviceVoice, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+
+	record := &DeviceVoice{}
+	err := db.Where("device_id = ?", deviceID).First(record).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询设备音色失败: %v", err)
+	}
+	record.DeviceID = deviceID
+	record.TTS = ttsName
+	record.Voice = voice
+	record.Name = name
+	if err := db.Save(record).Error; err != nil {
+		return nil, fmt.Errorf("保存设备音色失败: %v", err)
+	}
+	return record, nil
+}
+
+// GetDeviceVoice 查询设备的克隆音色，未注册时返回 nil
+func GetDeviceVoice(deviceID string) (*DeviceVoice, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, fmt.Errorf("数据库未初始化")
+	}
+	var record DeviceVoice
+	if err := db.Where("device_id = ?", deviceID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询设备音色失败: %v", err)
+	}
+	return &record, nil
+}
+
+// DeleteDeviceVoice 删除设备的克隆音色，设备恢复使用默认音色
+func DeleteDeviceVoice(deviceID string) error {
+	db := database.GetDB()
+	if db == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+	result := db.Where("device_id = ?", deviceID).Delete(&DeviceVoice{})
+	if result.Error != nil {
+		return fmt.Errorf("删除设备音色失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("设备 %s 未注册克隆音色", deviceID)
+	}
+	return nil
+}
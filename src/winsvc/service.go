@@ -0,0 +1,11 @@
+package winsvc
+
+// 服务名称与描述，install/uninstall/run 均使用该名称
+const (
+	ServiceName        = "xiaozhi-server"
+	ServiceDisplayName = "Xiaozhi Server"
+	ServiceDescription = "小智 AI 后端服务（WebSocket 语音对话 + OTA）"
+)
+
+// RunFunc 服务主体，stop 关闭时应优雅退出并返回进程退出码
+type RunFunc func(stop <-chan struct{}) int
@@ -0,0 +1,42 @@
+//go:build !windows
+
+package winsvc
+
+import "fmt"
+
+var errUnsupported = fmt.Errorf("Windows 服务仅在 Windows 平台可用")
+
+// IsWindowsService 非 Windows 平台始终返回 false
+func IsWindowsService() bool {
+	return false
+}
+
+// ChdirToExecutable 非 Windows 平台无需处理
+func ChdirToExecutable() error {
+	return nil
+}
+
+// Run 非 Windows 平台不支持
+func Run(run RunFunc) (int, error) {
+	return 1, errUnsupported
+}
+
+// Install 非 Windows 平台不支持
+func Install() error {
+	return errUnsupported
+}
+
+// Uninstall 非 Windows 平台不支持
+func Uninstall() error {
+	return errUnsupported
+}
+
+// Start 非 Windows 平台不支持
+func Start() error {
+	return errUnsupported
+}
+
+// Stop 非 Windows 平台不支持
+func Stop() error {
+	return errUnsupported
+}
@@ -0,0 +1,184 @@
+//go:build windows
+
+package winsvc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// IsWindowsService 当前进程是否由 Windows 服务控制管理器(SCM)启动
+func IsWindowsService() bool {
+	isService, err := svc.IsWindowsService()
+	return err == nil && isService
+}
+
+// ChdirToExecutable 切换工作目录到可执行文件所在目录
+// SCM 启动服务时工作目录为 C:\Windows\System32，配置文件与日志目录需要按服务目录解析
+func ChdirToExecutable() error {
+	execPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("获取可执行文件路径失败: %v", err)
+	}
+	return os.Chdir(filepath.Dir(execPath))
+}
+
+// handler 实现 svc.Handler
+type handler struct {
+	run      RunFunc
+	exitCode int
+}
+
+func (h *handler) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+	changes <- svc.Status{State: svc.StartPending}
+
+	stop := make(chan struct{})
+	done := make(chan int, 1)
+	go func() {
+		done <- h.run(stop)
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: accepted}
+
+	for {
+		select {
+		case code := <-done:
+			h.exitCode = code
+			changes <- svc.Status{State: svc.StopPending}
+			return code != 0, uint32(code)
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				changes <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				close(stop)
+				h.exitCode = <-done
+				return h.exitCode != 0, uint32(h.exitCode)
+			}
+		}
+	}
+}
+
+// Run 以 Windows 服务方式运行，阻塞直到服务停止，返回退出码
+func Run(run RunFunc) (int, error) {
+	h := &handler{run: run}
+	if err := svc.Run(ServiceName, h); err != nil {
+		return 1, fmt.Errorf("运行 Windows 服务失败: %v", err)
+	}
+	return h.exitCode, nil
+}
+
+// Install 注册为开机自启的 Windows 服务
+func Install() error {
+	execPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("获取可执行文件路径失败: %v", err)
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("连接服务控制管理器失败（需要管理员权限）: %v", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(ServiceName); err == nil {
+		s.Close()
+		return fmt.Errorf("服务 %s 已存在", ServiceName)
+	}
+
+	s, err := m.CreateService(ServiceName, execPath, mgr.Config{
+		DisplayName: ServiceDisplayName,
+		Description: ServiceDescription,
+		StartType:   mgr.StartAutomatic,
+	})
+	if err != nil {
+		return fmt.Errorf("创建服务失败: %v", err)
+	}
+	defer s.Close()
+
+	// 异常退出后自动重启
+	recovery := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.NoAction},
+	}
+	if err := s.SetRecoveryActions(recovery, 24*60*60); err != nil {
+		return fmt.Errorf("设置服务恢复策略失败: %v", err)
+	}
+	return nil
+}
+
+// Uninstall 停止并删除 Windows 服务
+func Uninstall() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("连接服务控制管理器失败（需要管理员权限）: %v", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(ServiceName)
+	if err != nil {
+		return fmt.Errorf("服务 %s 未安装", ServiceName)
+	}
+	defer s.Close()
+
+	// 先尝试停止正在运行的服务
+	if status, err := s.Query(); err == nil && status.State != svc.Stopped {
+		s.Control(svc.Stop)
+		deadline := time.Now().Add(15 * time.Second)
+		for time.Now().Before(deadline) {
+			time.Sleep(500 * time.Millisecond)
+			if status, err = s.Query(); err != nil || status.State == svc.Stopped {
+				break
+			}
+		}
+	}
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("删除服务失败: %v", err)
+	}
+	return nil
+}
+
+// Start 启动已安装的服务
+func Start() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("连接服务控制管理器失败（需要管理员权限）: %v", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(ServiceName)
+	if err != nil {
+		return fmt.Errorf("服务 %s 未安装", ServiceName)
+	}
+	defer s.Close()
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("启动服务失败: %v", err)
+	}
+	return nil
+}
+
+// Stop 停止正在运行的服务
+func Stop() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("连接服务控制管理器失败（需要管理员权限）: %v", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(ServiceName)
+	if err != nil {
+		return fmt.Errorf("服务 %s 未安装", ServiceName)
+	}
+	defer s.Close()
+	if _, err := s.Control(svc.Stop); err != nil {
+		return fmt.Errorf("停止服务失败: %v", err)
+	}
+	return nil
+}